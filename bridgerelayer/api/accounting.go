@@ -0,0 +1,87 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// AccountingColumns are the columns of the CSV accounting export
+var AccountingColumns = []string{
+	"day", "token", "bridged_in", "transfers_in", "bridged_out", "transfers_out", "net_flow", "fees_ethereum", "fees_substrate",
+}
+
+// GET /accounting?token=<token>&since=<period>&format=<json|csv>
+func (se *Server) handleAccounting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		since = defaultStatsPeriod
+	}
+	period, err := ParsePeriod(since)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q, expected json or csv", format))
+		return
+	}
+
+	flows, err := se.accounting.List(r.URL.Query().Get("token"), time.Now().Add(-period))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		_ = WriteAccountingCSV(w, flows)
+		return
+	}
+	writeJSON(w, http.StatusOK, flows)
+}
+
+// WriteAccountingCSV writes the flows of tokens as CSV, with a header of AccountingColumns
+func WriteAccountingCSV(w io.Writer, flows []*store.TokenFlow) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(AccountingColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, flow := range flows {
+		err = writer.Write([]string{
+			flow.Day,
+			flow.Token,
+			flow.BridgedIn,
+			strconv.FormatUint(flow.TransfersIn, 10),
+			flow.BridgedOut,
+			strconv.FormatUint(flow.TransfersOut, 10),
+			flow.NetFlow,
+			flow.FeesEthereum,
+			flow.FeesSubstrate,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rollout enables and disables relaying the messages of each app per direction
+type Rollout interface {
+	Apps() []AppRollout
+	SetEnabled(app string, direction string, enabled bool) error
+}
+
+// AppRollout reports whether the messages of an app are relayed in each direction
+type AppRollout struct {
+	App        string          `json:"app"`
+	Directions map[string]bool `json:"directions"`
+}
+
+// DirectionUpdate enables or disables a direction of an app
+type DirectionUpdate struct {
+	Direction string `json:"direction"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// GET /apps
+func (se *Server) handleApps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, se.rollout.Apps())
+}
+
+// POST /apps/<name>/directions
+func (se *Server) handleApp(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/apps/"), "/")
+	if len(parts) != 2 || parts[1] != "directions" || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	app := parts[0]
+
+	var update DirectionUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = se.rollout.SetEnabled(app, update.Direction, update.Enabled)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	se.log.WithFields(logrus.Fields{
+		"app":       app,
+		"direction": update.Direction,
+		"enabled":   update.Enabled,
+	}).Info("Updated relayed directions of app")
+
+	writeJSON(w, http.StatusOK, se.rollout.Apps())
+}
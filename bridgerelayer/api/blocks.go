@@ -0,0 +1,103 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// GET /blocks/holes
+func (se *Server) handleHoles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	holes, err := se.repairer.Holes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, holes)
+}
+
+// POST /blocks/repair?chain=<chain>
+func (se *Server) handleRepair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	chain := r.URL.Query().Get("chain")
+	if chain == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing chain"))
+		return
+	}
+
+	repaired, err := se.repairer.RepairHoles(r.Context(), chain)
+	if err != nil {
+		se.log.WithFields(logrus.Fields{
+			"chain":    chain,
+			"repaired": len(repaired),
+		}).WithError(err).Error("Failed to repair skipped blocks")
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	se.log.WithFields(logrus.Fields{
+		"chain":    chain,
+		"repaired": len(repaired),
+	}).Info("Repaired skipped blocks")
+
+	writeJSON(w, http.StatusOK, repaired)
+}
+
+// POST /blocks/replay?chain=<chain>&from=<block>&to=<block>, identifying blocks by number or hash
+func (se *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("chain")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing chain"))
+		return
+	}
+
+	var bounds [2]chain.BlockRef
+	for i, bound := range []string{"from", "to"} {
+		if query.Get(bound) == "" {
+			continue
+		}
+		value, err := chain.ParseBlockRef(query.Get(bound))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s block: %w", bound, err))
+			return
+		}
+		bounds[i] = value
+	}
+
+	replayed, err := se.repairer.ReplayBlocks(r.Context(), name, bounds[0], bounds[1])
+	if err != nil {
+		se.log.WithField("chain", name).WithError(err).Error("Failed to replay blocks")
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	se.log.WithFields(logrus.Fields{
+		"chain": name,
+		"from":  replayed.Start,
+		"to":    replayed.End,
+	}).Info("Replayed blocks")
+
+	writeJSON(w, http.StatusOK, replayed)
+}
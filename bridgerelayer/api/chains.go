@@ -0,0 +1,75 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Stopper halts and resumes all submissions to a chain in an emergency
+type Stopper interface {
+	Halts() []ChainHalt
+	Stop(chain string, reason string) error
+	Resume(chain string) error
+}
+
+// ChainHalt reports whether the submissions to a chain are halted, and why
+type ChainHalt struct {
+	Chain   string `json:"chain"`
+	Halted  bool   `json:"halted"`
+	Reasons string `json:"reasons,omitempty"`
+}
+
+// StopRequest gives the reason of an emergency stop
+type StopRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GET /chains
+func (se *Server) handleChains(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, se.stopper.Halts())
+}
+
+// POST /chains/<name>/stop
+// POST /chains/<name>/resume
+func (se *Server) handleChain(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/chains/"), "/")
+	if len(parts) != 2 || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	chain := parts[0]
+
+	var err error
+	switch parts[1] {
+	case "stop":
+		var request StopRequest
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = se.stopper.Stop(chain, request.Reason)
+	case "resume":
+		err = se.stopper.Resume(chain)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, se.stopper.Halts())
+}
@@ -0,0 +1,237 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// Client talks to the admin API of a running relayer
+type Client struct {
+	endpoint string
+	http     *http.Client
+}
+
+func NewClient(endpoint string) *Client {
+	return &Client{
+		endpoint: endpoint,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (cl *Client) ListMessages(label string, tag string) ([]*store.MessageRecord, error) {
+	query := url.Values{"label": {label}, "tag": {tag}}
+	var records []*store.MessageRecord
+	err := cl.do(http.MethodGet, "/messages?"+query.Encode(), nil, &records)
+	return records, err
+}
+
+func (cl *Client) GetMessage(id string) (*store.MessageRecord, error) {
+	var record store.MessageRecord
+	err := cl.do(http.MethodGet, "/messages/"+url.PathEscape(id), nil, &record)
+	return &record, err
+}
+
+func (cl *Client) Annotate(id string, annotation store.Annotation) (*store.MessageRecord, error) {
+	var record store.MessageRecord
+	err := cl.do(http.MethodPost, "/messages/"+url.PathEscape(id)+"/annotations", annotation, &record)
+	return &record, err
+}
+
+// Boost escalates the submission of a queued message
+func (cl *Client) Boost(id string) (*chain.Boost, error) {
+	var boost chain.Boost
+	err := cl.do(http.MethodPost, "/messages/"+url.PathEscape(id)+"/boost", nil, &boost)
+	return &boost, err
+}
+
+// Stats returns the daily statistics of a channel, or of all channels if empty, over
+// a period such as 30d
+func (cl *Client) Stats(channel string, since string) ([]*store.ChannelStats, error) {
+	var days []*store.ChannelStats
+	query := url.Values{"channel": {channel}, "since": {since}}
+	err := cl.do(http.MethodGet, "/stats?"+query.Encode(), nil, &days)
+	return days, err
+}
+
+// Accounting returns the daily flow of a token, or of all tokens if empty, over a period
+func (cl *Client) Accounting(token string, since string) ([]*store.TokenFlow, error) {
+	var flows []*store.TokenFlow
+	query := url.Values{"token": {token}, "since": {since}}
+	err := cl.do(http.MethodGet, "/accounting?"+query.Encode(), nil, &flows)
+	return flows, err
+}
+
+// Holes returns the blocks which were skipped by the listeners of each chain
+func (cl *Client) Holes() (map[string][]store.Interval, error) {
+	var holes map[string][]store.Interval
+	err := cl.do(http.MethodGet, "/blocks/holes", nil, &holes)
+	return holes, err
+}
+
+// Repair reprocesses the skipped blocks of a chain, returning the repaired ranges
+func (cl *Client) Repair(chain string) ([]store.Interval, error) {
+	var repaired []store.Interval
+	// repairs can take much longer than other requests, so no timeout is applied
+	err := cl.send(&http.Client{}, http.MethodPost, "/blocks/repair?chain="+url.QueryEscape(chain), nil, &repaired)
+	return repaired, err
+}
+
+// Replay re-emits the messages of a range of blocks of a chain, returning the replayed range.
+// Unset bounds default to the range configured for the chain, and to the latest block.
+func (cl *Client) Replay(name string, from chain.BlockRef, to chain.BlockRef) (*store.Interval, error) {
+	var replayed store.Interval
+	query := url.Values{
+		"chain": {name},
+		"from":  {from.String()},
+		"to":    {to.String()},
+	}
+	// replays can take much longer than other requests, so no timeout is applied
+	err := cl.send(&http.Client{}, http.MethodPost, "/blocks/replay?"+query.Encode(), nil, &replayed)
+	return &replayed, err
+}
+
+// Proof returns the message which the relayer submits for the event at an index of a block
+func (cl *Client) Proof(chain string, block uint64, index uint64) (*Proof, error) {
+	var proof Proof
+	query := url.Values{
+		"chain": {chain},
+		"block": {strconv.FormatUint(block, 10)},
+		"index": {strconv.FormatUint(index, 10)},
+	}
+	err := cl.do(http.MethodGet, "/proofs?"+query.Encode(), nil, &proof)
+	return &proof, err
+}
+
+// Apps returns the directions in which the messages of each app are relayed
+func (cl *Client) Apps() ([]AppRollout, error) {
+	var apps []AppRollout
+	err := cl.do(http.MethodGet, "/apps", nil, &apps)
+	return apps, err
+}
+
+// SetDirection enables or disables relaying the messages of an app in a direction
+func (cl *Client) SetDirection(app string, direction string, enabled bool) ([]AppRollout, error) {
+	var apps []AppRollout
+	update := DirectionUpdate{Direction: direction, Enabled: enabled}
+	err := cl.do(http.MethodPost, "/apps/"+url.PathEscape(app)+"/directions", update, &apps)
+	return apps, err
+}
+
+// Halts returns whether the submissions to each chain are halted
+func (cl *Client) Halts() ([]ChainHalt, error) {
+	var halts []ChainHalt
+	err := cl.do(http.MethodGet, "/chains", nil, &halts)
+	return halts, err
+}
+
+// Stop halts all submissions to a chain until it is resumed
+func (cl *Client) Stop(chain string, reason string) ([]ChainHalt, error) {
+	var halts []ChainHalt
+	err := cl.do(http.MethodPost, "/chains/"+url.PathEscape(chain)+"/stop", StopRequest{Reason: reason}, &halts)
+	return halts, err
+}
+
+// Resume withdraws the emergency stop of a chain
+func (cl *Client) Resume(chain string) ([]ChainHalt, error) {
+	var halts []ChainHalt
+	err := cl.do(http.MethodPost, "/chains/"+url.PathEscape(chain)+"/resume", nil, &halts)
+	return halts, err
+}
+
+// Logs returns up to limit of the most recent log entries of the relay, all if zero
+func (cl *Client) Logs(limit int) ([]LogEntry, error) {
+	var entries []LogEntry
+	err := cl.do(http.MethodGet, "/logs?limit="+strconv.Itoa(limit), nil, &entries)
+	return entries, err
+}
+
+// DeadLetters returns the messages whose delivery failed permanently
+func (cl *Client) DeadLetters() ([]*store.DeadLetter, error) {
+	var letters []*store.DeadLetter
+	err := cl.do(http.MethodGet, "/dead-letters", nil, &letters)
+	return letters, err
+}
+
+func (cl *Client) GetDeadLetter(id string) (*store.DeadLetter, error) {
+	var letter store.DeadLetter
+	err := cl.do(http.MethodGet, "/dead-letters/"+url.PathEscape(id), nil, &letter)
+	return &letter, err
+}
+
+// Requeue routes a dead-lettered message again, removing it from the dead-letter queue
+func (cl *Client) Requeue(id string) (*store.DeadLetter, error) {
+	var letter store.DeadLetter
+	err := cl.do(http.MethodPost, "/dead-letters/"+url.PathEscape(id)+"/requeue", nil, &letter)
+	return &letter, err
+}
+
+// Diagnostics returns the state of the writers captured while their queues backed up
+func (cl *Client) Diagnostics() ([]*chain.WriterDiagnostics, error) {
+	var diagnostics []*chain.WriterDiagnostics
+	err := cl.do(http.MethodGet, "/diagnostics", nil, &diagnostics)
+	return diagnostics, err
+}
+
+func (cl *Client) GetDiagnostics(id string) (*chain.WriterDiagnostics, error) {
+	var diagnostics chain.WriterDiagnostics
+	err := cl.do(http.MethodGet, "/diagnostics/"+url.PathEscape(id), nil, &diagnostics)
+	return &diagnostics, err
+}
+
+// Status returns the status feed, for a client of a status server endpoint
+func (cl *Client) Status() (*Status, error) {
+	var status Status
+	err := cl.do(http.MethodGet, "/status", nil, &status)
+	return &status, err
+}
+
+func (cl *Client) do(method string, path string, body interface{}, result interface{}) error {
+	return cl.send(cl.http, method, path, body, result)
+}
+
+func (cl *Client) send(client *http.Client, method string, path string, body interface{}, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	request, err := http.NewRequest(method, cl.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		err = json.NewDecoder(response.Body).Decode(&apiErr)
+		if err != nil || apiErr.Error == "" {
+			return fmt.Errorf("request failed: %s", response.Status)
+		}
+		return fmt.Errorf("request failed: %s", apiErr.Error)
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
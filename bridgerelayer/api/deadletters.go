@@ -0,0 +1,79 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// DeadLetters holds the messages whose delivery failed permanently until they are requeued
+type DeadLetters interface {
+	List() ([]*store.DeadLetter, error)
+	Get(id string) (*store.DeadLetter, error)
+	Requeue(ctx context.Context, id string) (*store.DeadLetter, error)
+}
+
+// GET /dead-letters
+func (se *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+	if se.deadLetters == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dead-letter queue is disabled"))
+		return
+	}
+
+	letters, err := se.deadLetters.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, letters)
+}
+
+// GET /dead-letters/<id>
+// POST /dead-letters/<id>/requeue
+func (se *Server) handleDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if se.deadLetters == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dead-letter queue is disabled"))
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/dead-letters/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		letter, err := se.deadLetters.Get(id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, letter)
+	case len(parts) == 2 && parts[1] == "requeue" && r.Method == http.MethodPost:
+		letter, err := se.deadLetters.Requeue(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		se.log.WithFields(logrus.Fields{
+			"messageID": id,
+			"target":    letter.Target,
+		}).Info("Requeued dead-lettered message")
+
+		writeJSON(w, http.StatusOK, letter)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
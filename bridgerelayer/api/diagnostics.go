@@ -0,0 +1,42 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GET /diagnostics
+func (se *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	diagnostics, err := se.diagnostics.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diagnostics)
+}
+
+// GET /diagnostics/<id>
+func (se *Server) handleDiagnostic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	diagnostics, err := se.diagnostics.Get(strings.TrimPrefix(r.URL.Path, "/diagnostics/"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diagnostics)
+}
@@ -0,0 +1,237 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type HealthConfig struct {
+	// Listen address of the liveness and readiness probes. The probes are disabled if empty.
+	Address string `mapstructure:"address"`
+	// Seconds without listener progress after which the relayer is reported dead. Defaults to 120.
+	StaleAfter uint64 `mapstructure:"stale-after"`
+	// Blocks by which a listener may lag behind the head of its chain while ready. Defaults to 50.
+	MaxLag uint64 `mapstructure:"max-lag"`
+	// Consecutive failed calls after which an endpoint is reported disconnected. Defaults to 3.
+	MaxRPCErrors int `mapstructure:"max-rpc-errors"`
+	// Seconds without a completed submission after which a writer with queued messages is
+	// reported as not draining. Defaults to 300.
+	StallAfter uint64 `mapstructure:"stall-after"`
+}
+
+const (
+	defaultHealthStaleAfter = 120
+	defaultHealthMaxLag     = 50
+	defaultMaxRPCErrors     = 3
+	defaultStallAfter       = 300
+)
+
+// HealthSource is a chain whose listener and writer are probed
+type HealthSource interface {
+	Name() string
+	WriterGate() *chain.Gate
+	Progress() *chain.Progress
+	RPCStats() []*chain.RPCStats
+	WriterQueues() []chain.QueueStats
+	LastSubmission() time.Time
+}
+
+// Health is the outcome of a probe
+type Health struct {
+	// Whether the probe succeeded
+	OK     bool          `json:"ok"`
+	Chains []ChainHealth `json:"chains"`
+}
+
+// ChainHealth reports the state of the listener and writer of a chain
+type ChainHealth struct {
+	Name string `json:"name"`
+	// Whether all endpoints of the chain answer calls
+	Connected bool `json:"connected"`
+	// Whether the listener made progress recently
+	Progressing bool   `json:"progressing"`
+	Lag         uint64 `json:"lag"`
+	// Messages queued or being submitted to the chain
+	Pending int `json:"pending"`
+	// Whether the writer submits its pending messages. Halted writers keep their messages
+	// queued on purpose, and are reported as draining.
+	Draining bool `json:"draining"`
+	Halted   bool `json:"halted"`
+	// Reasons for which the chain fails the probe
+	Problems []string `json:"problems,omitempty"`
+}
+
+// HealthServer serves liveness and readiness probes, for example for Kubernetes. The relayer
+// is live while the listeners of all chains make progress, and restarting it is expected to
+// help otherwise. It is ready once all endpoints also answer calls, the listeners keep up
+// with the heads of their chains and the writers drain their queues.
+type HealthServer struct {
+	config     *HealthConfig
+	sources    []HealthSource
+	mux        *http.ServeMux
+	staleAfter time.Duration
+	maxLag     uint64
+	maxErrors  int
+	stallAfter time.Duration
+	// listeners which have not made progress yet are given until staleAfter after the start
+	startedAt time.Time
+	log       *logrus.Entry
+}
+
+func NewHealthServer(config *HealthConfig, sources []HealthSource, log *logrus.Entry) *HealthServer {
+	hs := &HealthServer{
+		config:     config,
+		sources:    sources,
+		mux:        http.NewServeMux(),
+		staleAfter: time.Duration(config.StaleAfter) * time.Second,
+		maxLag:     config.MaxLag,
+		maxErrors:  config.MaxRPCErrors,
+		stallAfter: time.Duration(config.StallAfter) * time.Second,
+		startedAt:  time.Now(),
+		log:        log,
+	}
+	if hs.staleAfter == 0 {
+		hs.staleAfter = defaultHealthStaleAfter * time.Second
+	}
+	if hs.maxLag == 0 {
+		hs.maxLag = defaultHealthMaxLag
+	}
+	if hs.maxErrors <= 0 {
+		hs.maxErrors = defaultMaxRPCErrors
+	}
+	if hs.stallAfter == 0 {
+		hs.stallAfter = defaultStallAfter * time.Second
+	}
+
+	hs.mux.HandleFunc("/healthz", hs.handleHealthz)
+	hs.mux.HandleFunc("/readyz", hs.handleReadyz)
+
+	return hs
+}
+
+func (hs *HealthServer) Start(ctx context.Context, eg *errgroup.Group) error {
+	address, err := serve(ctx, eg, hs.config.Address, hs)
+	if err != nil {
+		return err
+	}
+
+	hs.log.WithField("address", address.String()).Info("Started health probes")
+
+	return nil
+}
+
+func (hs *HealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hs.mux.ServeHTTP(w, r)
+}
+
+// GET /healthz
+func (hs *HealthServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	hs.probe(w, r, hs.Live)
+}
+
+// GET /readyz
+func (hs *HealthServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	hs.probe(w, r, hs.Ready)
+}
+
+func (hs *HealthServer) probe(w http.ResponseWriter, r *http.Request, check func(time.Time) *Health) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	health := check(time.Now())
+	status := http.StatusOK
+	if !health.OK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// Live reports whether the listeners of all chains make progress
+func (hs *HealthServer) Live(now time.Time) *Health {
+	return hs.check(now, false)
+}
+
+// Ready reports whether the relayer is live, connected, caught up and draining its queues
+func (hs *HealthServer) Ready(now time.Time) *Health {
+	return hs.check(now, true)
+}
+
+func (hs *HealthServer) check(now time.Time, ready bool) *Health {
+	health := &Health{OK: true, Chains: []ChainHealth{}}
+
+	for _, source := range hs.sources {
+		ch := hs.chainHealth(source, now)
+
+		problems := []string{}
+		if !ch.Progressing {
+			problems = append(problems, "listener is not making progress")
+		}
+		if ready {
+			if !ch.Connected {
+				problems = append(problems, "endpoint is not answering calls")
+			}
+			if ch.Lag > hs.maxLag {
+				problems = append(problems, fmt.Sprintf("listener lags %d blocks behind", ch.Lag))
+			}
+			if !ch.Draining {
+				problems = append(problems, "writer is not draining its queue")
+			}
+		}
+		if len(problems) > 0 {
+			ch.Problems = problems
+			health.OK = false
+		}
+
+		health.Chains = append(health.Chains, ch)
+	}
+
+	return health
+}
+
+func (hs *HealthServer) chainHealth(source HealthSource, now time.Time) ChainHealth {
+	progress := source.Progress().Snapshot()
+	halted, _ := source.WriterGate().Halted()
+
+	ch := ChainHealth{
+		Name:      source.Name(),
+		Connected: true,
+		Lag:       progress.Lag(),
+		Halted:    halted,
+	}
+
+	updatedAt := progress.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = hs.startedAt
+	}
+	ch.Progressing = now.Sub(updatedAt) <= hs.staleAfter
+
+	for _, stats := range source.RPCStats() {
+		if stats.ConsecutiveErrors() >= hs.maxErrors {
+			ch.Connected = false
+		}
+	}
+
+	for _, queue := range source.WriterQueues() {
+		ch.Pending += queue.Queued + queue.InFlight
+	}
+
+	submittedAt := source.LastSubmission()
+	if submittedAt.IsZero() {
+		submittedAt = hs.startedAt
+	}
+	ch.Draining = ch.Pending == 0 || halted || now.Sub(submittedAt) <= hs.stallAfter
+
+	return ch
+}
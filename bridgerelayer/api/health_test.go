@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+func TestHealthServer(t *testing.T) {
+	eth := newSource("Ethereum")
+	sub := newSource("Substrate")
+	server := api.NewHealthServer(&api.HealthConfig{StaleAfter: 60, MaxLag: 10, MaxRPCErrors: 2, StallAfter: 30}, []api.HealthSource{eth, sub}, logrus.NewEntry(logrus.New()))
+
+	now := time.Now()
+	eth.progress.Update(100, 100)
+	sub.progress.Update(50, 45)
+
+	assert.True(t, server.Live(now).OK)
+	ready := server.Ready(now)
+	assert.True(t, ready.OK)
+	assert.Equal(t, uint64(5), ready.Chains[1].Lag)
+	assert.True(t, ready.Chains[0].Connected)
+
+	// lagging listeners, failing endpoints and stalled writers are not ready, but live
+	sub.progress.Update(80, 45)
+	eth.stats.Observe("eth_blockNumber", time.Millisecond, fmt.Errorf("connection refused"))
+	eth.stats.Observe("eth_blockNumber", time.Millisecond, fmt.Errorf("connection refused"))
+	eth.queued = 3
+	eth.submitted = now.Add(-time.Minute)
+
+	assert.True(t, server.Live(now).OK)
+	ready = server.Ready(now)
+	assert.False(t, ready.OK)
+	assert.False(t, ready.Chains[0].Connected)
+	assert.False(t, ready.Chains[0].Draining)
+	assert.Equal(t, 3, ready.Chains[0].Pending)
+	assert.Len(t, ready.Chains[0].Problems, 2)
+	assert.Equal(t, []string{"listener lags 35 blocks behind"}, ready.Chains[1].Problems)
+
+	// halted writers keep their queues on purpose
+	eth.gate.Halt("maintenance")
+	assert.True(t, server.Ready(now).Chains[0].Draining)
+
+	// listeners which stopped making progress are no longer live
+	live := server.Live(now.Add(2 * time.Minute))
+	assert.False(t, live.OK)
+	assert.Equal(t, []string{"listener is not making progress"}, live.Chains[0].Problems)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"ok":true`)
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Logs keeps the most recent log entries of the relay
+type Logs interface {
+	// RecentLogs returns up to limit of the most recent entries, oldest first, or all kept
+	// entries if the limit is zero
+	RecentLogs(limit int) []LogEntry
+}
+
+// LogEntry is a log entry, with secrets and endpoint credentials redacted
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// GET /logs?limit=<entries>
+func (se *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", value))
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, se.logs.RecentLogs(limit))
+}
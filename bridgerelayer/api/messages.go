@@ -0,0 +1,109 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// Booster escalates the submission of messages queued in the writers
+type Booster interface {
+	Boost(id string) (*chain.Boost, error)
+}
+
+// GET /messages?label=<label>&tag=<tag>
+func (se *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	query := r.URL.Query()
+	records, err := se.messages.List(query.Get("label"), query.Get("tag"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// GET /messages/<id>
+// POST /messages/<id>/annotations
+// POST /messages/<id>/boost
+func (se *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/messages/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		record, err := se.messages.Get(id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, record)
+	case len(parts) == 2 && parts[1] == "annotations" && r.Method == http.MethodPost:
+		var annotation store.Annotation
+		err := json.NewDecoder(r.Body).Decode(&annotation)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		record, err := se.messages.Annotate(id, annotation)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		se.log.WithFields(logrus.Fields{
+			"messageID": id,
+			"label":     annotation.Label,
+			"author":    annotation.Author,
+		}).Info("Annotated message")
+
+		writeJSON(w, http.StatusOK, record)
+	case len(parts) == 2 && parts[1] == "boost" && r.Method == http.MethodPost:
+		record, err := se.messages.Get(id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		if record.Status != store.StatusRouted {
+			writeError(w, http.StatusConflict, fmt.Errorf("message is %s, not awaiting delivery", record.Status))
+			return
+		}
+
+		boost, err := se.booster.Boost(id)
+		if err == chain.ErrNotQueued {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, boost)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if err == store.ErrNotFound {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Prover rebuilds the messages which the relayer generates for the events of each chain
+type Prover interface {
+	Proof(ctx context.Context, chain string, block uint64, index uint64) (*Proof, error)
+}
+
+// Proof is the message which the relayer submits for an event, including the data by
+// which the target chain verifies it, so that users can relay the event themselves
+type Proof struct {
+	// ID of the message, under which the relayer records it
+	ID          string `json:"id"`
+	Source      string `json:"source"`
+	BlockNumber uint64 `json:"blockNumber"`
+	Index       uint64 `json:"index"`
+	AppID       string `json:"appId"`
+	// Encoded payload, as recorded in the message store
+	Payload string `json:"payload"`
+}
+
+// GET /proofs?chain=<chain>&block=<number>&index=<index>
+func (se *Server) handleProofs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	query := r.URL.Query()
+	name := query.Get("chain")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing chain"))
+		return
+	}
+	block, err := strconv.ParseUint(query.Get("block"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid block: %s", query.Get("block")))
+		return
+	}
+	index, err := strconv.ParseUint(query.Get("index"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid index: %s", query.Get("index")))
+		return
+	}
+
+	proof, err := se.prover.Proof(r.Context(), name, block, index)
+	if errors.Is(err, chain.ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, proof)
+}
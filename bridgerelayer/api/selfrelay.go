@@ -0,0 +1,82 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ErrPaymentRequired is returned when a self-relay request lacks a valid payment
+var ErrPaymentRequired = errors.New("payment required")
+
+// SelfRelayer delivers the messages of events which users submit themselves
+type SelfRelayer interface {
+	SelfRelay(ctx context.Context, request *SelfRelayRequest) (*SelfRelayResult, error)
+}
+
+// SelfRelayRequest references an event whose message a user asks to be relayed
+type SelfRelayRequest struct {
+	Chain string `json:"chain"`
+	Block uint64 `json:"block"`
+	// Index of the event in the block, the log index on Ethereum
+	Index uint64 `json:"index"`
+	// Hash of the Ethereum transaction paying the fee, if the relayer charges one
+	Payment string `json:"payment,omitempty"`
+}
+
+// SelfRelayResult reports what became of a self-relayed message
+type SelfRelayResult struct {
+	ID string `json:"id"`
+	// queued if the message was queued for delivery, or the status under which the
+	// relayer already recorded it
+	Status string `json:"status"`
+}
+
+// POST /relay
+func (ss *StatusServer) handleRelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	if !ss.limiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded"))
+		return
+	}
+
+	var request SelfRelayRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.Chain == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing chain"))
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	result, err := ss.relayer.SelfRelay(r.Context(), &request)
+	if errors.Is(err, chain.ErrEventNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, ErrPaymentRequired) {
+		writeError(w, http.StatusPaymentRequired, err)
+		return
+	} else if err != nil {
+		ss.log.WithError(err).WithField("chain", request.Chain).Warn("Failed to self-relay message")
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
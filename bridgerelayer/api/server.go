@@ -0,0 +1,146 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package api implements the relayer's admin HTTP API, along with a client for it,
+// and the public status feed.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type Config struct {
+	// Listen address of the admin API, for example 127.0.0.1:8081. The API is disabled if empty.
+	Address string `mapstructure:"address"`
+}
+
+// Server serves the admin API
+type Server struct {
+	config   *Config
+	mux      *http.ServeMux
+	messages *store.Messages
+	stats    *store.Stats
+	// daily flow of each token, empty unless accounting is enabled
+	accounting *store.Accounting
+	repairer   Repairer
+	prover     Prover
+	rollout    Rollout
+	stopper    Stopper
+	logs       Logs
+	// nil if the dead-letter queue is disabled
+	deadLetters DeadLetters
+	booster     Booster
+	diagnostics *store.Diagnostics
+	log         *logrus.Entry
+}
+
+// Repairer reports and reprocesses blocks which were skipped by the listeners, and replays
+// ranges of blocks
+type Repairer interface {
+	Holes() (map[string][]store.Interval, error)
+	RepairHoles(ctx context.Context, chain string) ([]store.Interval, error)
+	ReplayBlocks(ctx context.Context, name string, from chain.BlockRef, to chain.BlockRef) (store.Interval, error)
+}
+
+func NewServer(config *Config, messages *store.Messages, stats *store.Stats, accounting *store.Accounting, repairer Repairer, prover Prover, rollout Rollout, stopper Stopper, logs Logs, deadLetters DeadLetters, booster Booster, diagnostics *store.Diagnostics, log *logrus.Entry) *Server {
+	se := &Server{
+		config:      config,
+		mux:         http.NewServeMux(),
+		messages:    messages,
+		stats:       stats,
+		accounting:  accounting,
+		repairer:    repairer,
+		prover:      prover,
+		rollout:     rollout,
+		stopper:     stopper,
+		logs:        logs,
+		deadLetters: deadLetters,
+		booster:     booster,
+		diagnostics: diagnostics,
+		log:         log,
+	}
+
+	se.mux.HandleFunc("/messages", se.handleMessages)
+	se.mux.HandleFunc("/messages/", se.handleMessage)
+	se.mux.HandleFunc("/stats", se.handleStats)
+	se.mux.HandleFunc("/accounting", se.handleAccounting)
+	se.mux.HandleFunc("/blocks/holes", se.handleHoles)
+	se.mux.HandleFunc("/blocks/repair", se.handleRepair)
+	se.mux.HandleFunc("/blocks/replay", se.handleReplay)
+	se.mux.HandleFunc("/proofs", se.handleProofs)
+	se.mux.HandleFunc("/apps", se.handleApps)
+	se.mux.HandleFunc("/apps/", se.handleApp)
+	se.mux.HandleFunc("/chains", se.handleChains)
+	se.mux.HandleFunc("/chains/", se.handleChain)
+	se.mux.HandleFunc("/logs", se.handleLogs)
+	se.mux.HandleFunc("/dead-letters", se.handleDeadLetters)
+	se.mux.HandleFunc("/dead-letters/", se.handleDeadLetter)
+	se.mux.HandleFunc("/diagnostics", se.handleDiagnostics)
+	se.mux.HandleFunc("/diagnostics/", se.handleDiagnostic)
+	se.mux.Handle("/metrics", promhttp.Handler())
+
+	return se
+}
+
+func (se *Server) Start(ctx context.Context, eg *errgroup.Group) error {
+	address, err := serve(ctx, eg, se.config.Address, se.mux)
+	if err != nil {
+		return err
+	}
+
+	se.log.WithField("address", address.String()).Info("Started admin API")
+
+	return nil
+}
+
+// serve runs an HTTP server on the address until the context is cancelled
+func serve(ctx context.Context, eg *errgroup.Group, address string, handler http.Handler) (net.Addr, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Handler: handler}
+
+	eg.Go(func() error {
+		err := server.Serve(listener)
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	})
+
+	eg.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	})
+
+	return listener.Addr(), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
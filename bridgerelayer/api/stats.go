@@ -0,0 +1,58 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStatsPeriod is the period reported if none is given
+const defaultStatsPeriod = "30d"
+
+// GET /stats?channel=<channel>&since=<period>
+func (se *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		since = defaultStatsPeriod
+	}
+	period, err := ParsePeriod(since)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	days, err := se.stats.List(r.URL.Query().Get("channel"), time.Now().Add(-period))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, days)
+}
+
+// ParsePeriod parses a period given in days, such as 30d, or as a duration such as 12h
+func ParsePeriod(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(value, "d"), 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid period: %s", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	period, err := time.ParseDuration(value)
+	if err != nil || period < 0 {
+		return 0, fmt.Errorf("invalid period: %s", value)
+	}
+	return period, nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type StatusConfig struct {
+	// Listen address of the public status feed. The feed is disabled if empty.
+	Address string `mapstructure:"address"`
+	// Requests per second served across all clients
+	RateLimit float64 `mapstructure:"rate-limit"`
+	// Seconds without listener progress after which a chain is reported unhealthy
+	StaleAfter uint64 `mapstructure:"stale-after"`
+}
+
+const (
+	defaultStatusRateLimit  = 5
+	defaultStatusStaleAfter = 120
+	// status responses are cached so that bursts of requests don't each query the chains
+	statusCacheTTL = time.Second
+)
+
+// StatusSource is a chain whose status is published on the status feed
+type StatusSource interface {
+	Name() string
+	WriterGate() *chain.Gate
+	Progress() *chain.Progress
+	RPCStats() []*chain.RPCStats
+	WriterQueues() []chain.QueueStats
+}
+
+// Sequences reports the bridge-wide sequence numbers of the messages relayed from and to
+// each chain, so that the ordering of messages can be followed across both directions
+type Sequences interface {
+	// Latest returns the latest sequence number assigned to any message
+	Latest() uint64
+	// Recorded returns the latest sequence number of the messages recorded from a chain
+	Recorded(chain string) uint64
+	// Delivered returns the highest sequence number of the messages delivered to a chain
+	Delivered(chain string) uint64
+}
+
+// Tasks reports the state of the periodic maintenance tasks run by the relayer
+type Tasks interface {
+	Tasks() []TaskStatus
+}
+
+// TaskStatus is the state of a periodic maintenance task. The errors of failed runs are
+// left to the logs, as they may reveal endpoints and other internals.
+type TaskStatus struct {
+	Name    string    `json:"name"`
+	Running bool      `json:"running"`
+	LastRun time.Time `json:"lastRun"`
+	// Time at which the task last succeeded, zero if it never did
+	LastSuccess time.Time `json:"lastSuccess"`
+	NextRun     time.Time `json:"nextRun"`
+	// Duration of the last run
+	DurationMs float64 `json:"durationMs"`
+	Runs       uint64  `json:"runs"`
+	Failures   uint64  `json:"failures"`
+	// Failures since the last successful run
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// Runs which were skipped because the previous run was still in progress
+	Skipped uint64 `json:"skipped"`
+}
+
+// Identity identifies a relayer instance, so that the relayers of a fleet can be
+// inventoried and their versions and configurations compared
+type Identity struct {
+	// Address of the relayer's Ethereum key
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Environment string `json:"environment"`
+	Version     string `json:"version"`
+	// Hash of the configuration, with secrets removed
+	ConfigHash string    `json:"configHash"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// Status is the public view of the bridge
+type Status struct {
+	Healthy   bool          `json:"healthy"`
+	Paused    bool          `json:"paused"`
+	Relayer   *Identity     `json:"relayer,omitempty"`
+	Chains    []ChainStatus `json:"chains"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	// Latest sequence number assigned to a message, across both directions
+	Sequence uint64 `json:"sequence"`
+	// Periodic maintenance tasks, omitted if none are scheduled
+	Tasks []TaskStatus `json:"tasks,omitempty"`
+}
+
+type ChainStatus struct {
+	Name           string `json:"name"`
+	Healthy        bool   `json:"healthy"`
+	Paused         bool   `json:"paused"`
+	LatestBlock    uint64 `json:"latestBlock"`
+	ProcessedBlock uint64 `json:"processedBlock"`
+	Lag            uint64 `json:"lag"`
+	// Call statistics of each endpoint, whose URLs are reduced to their scheme and host
+	RPC []chain.EndpointStats `json:"rpc"`
+	// Messages pending submission to each app on the chain
+	Queues []chain.QueueStats `json:"queues"`
+	// Sequence numbers of the latest message recorded from the chain, and of the latest
+	// message confirmed as delivered to it
+	RecordedSequence  uint64 `json:"recordedSequence"`
+	DeliveredSequence uint64 `json:"deliveredSequence"`
+}
+
+// StatusServer serves the public status feed. It is unauthenticated and deliberately
+// exposes nothing but aggregate health, queue depths and RPC statistics, with endpoint
+// URLs reduced to their host, so it is served apart from the admin API. If self-relay
+// is enabled, it also accepts the events which users ask to be relayed.
+type StatusServer struct {
+	config     *StatusConfig
+	identity   *Identity
+	sources    []StatusSource
+	mux        *http.ServeMux
+	limiter    *rate.Limiter
+	staleAfter time.Duration
+	mutex      sync.Mutex
+	cached     *Status
+	// nil if sequence numbers are not reported
+	sequences Sequences
+	// nil if no maintenance tasks are scheduled
+	tasks Tasks
+	// delivers the messages which users submit, nil if self-relay is disabled
+	relayer SelfRelayer
+	log     *logrus.Entry
+}
+
+func NewStatusServer(config *StatusConfig, identity *Identity, sources []StatusSource, sequences Sequences, tasks Tasks, relayer SelfRelayer, log *logrus.Entry) *StatusServer {
+	limit := config.RateLimit
+	if limit <= 0 {
+		limit = defaultStatusRateLimit
+	}
+
+	staleAfter := config.StaleAfter
+	if staleAfter == 0 {
+		staleAfter = defaultStatusStaleAfter
+	}
+
+	ss := &StatusServer{
+		config:     config,
+		identity:   identity,
+		sources:    sources,
+		sequences:  sequences,
+		tasks:      tasks,
+		relayer:    relayer,
+		mux:        http.NewServeMux(),
+		limiter:    rate.NewLimiter(rate.Limit(limit), int(limit)+1),
+		staleAfter: time.Duration(staleAfter) * time.Second,
+		log:        log,
+	}
+
+	ss.mux.HandleFunc("/status", ss.handleStatus)
+	if relayer != nil {
+		ss.mux.HandleFunc("/relay", ss.handleRelay)
+	}
+
+	return ss
+}
+
+func (ss *StatusServer) Start(ctx context.Context, eg *errgroup.Group) error {
+	address, err := serve(ctx, eg, ss.config.Address, ss)
+	if err != nil {
+		return err
+	}
+
+	ss.log.WithField("address", address.String()).Info("Started status feed")
+
+	return nil
+}
+
+func (ss *StatusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ss.mux.ServeHTTP(w, r)
+}
+
+// GET /status
+func (ss *StatusServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	if !ss.limiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded"))
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJSON(w, http.StatusOK, ss.Status(time.Now()))
+}
+
+// Status returns the status of the bridge, cached for a short time
+func (ss *StatusServer) Status(now time.Time) *Status {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.cached != nil && now.Sub(ss.cached.UpdatedAt) < statusCacheTTL {
+		return ss.cached
+	}
+
+	status := &Status{
+		Healthy:   true,
+		Relayer:   ss.identity,
+		Chains:    []ChainStatus{},
+		UpdatedAt: now,
+	}
+	if ss.sequences != nil {
+		status.Sequence = ss.sequences.Latest()
+	}
+	if ss.tasks != nil {
+		status.Tasks = ss.tasks.Tasks()
+	}
+
+	for _, source := range ss.sources {
+		progress := source.Progress().Snapshot()
+		paused, _ := source.WriterGate().Halted()
+
+		cs := ChainStatus{
+			Name:           source.Name(),
+			Healthy:        !progress.UpdatedAt.IsZero() && now.Sub(progress.UpdatedAt) <= ss.staleAfter,
+			Paused:         paused,
+			LatestBlock:    progress.Latest,
+			ProcessedBlock: progress.Processed,
+			Lag:            progress.Lag(),
+			RPC:            []chain.EndpointStats{},
+			Queues:         source.WriterQueues(),
+		}
+		for _, stats := range source.RPCStats() {
+			cs.RPC = append(cs.RPC, stats.Snapshot())
+		}
+		if ss.sequences != nil {
+			cs.RecordedSequence = ss.sequences.Recorded(source.Name())
+			cs.DeliveredSequence = ss.sequences.Delivered(source.Name())
+		}
+
+		status.Healthy = status.Healthy && cs.Healthy
+		status.Paused = status.Paused || cs.Paused
+		status.Chains = append(status.Chains, cs)
+	}
+
+	ss.cached = status
+	return status
+}
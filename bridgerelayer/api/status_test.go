@@ -0,0 +1,106 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type source struct {
+	name     string
+	gate     *chain.Gate
+	progress *chain.Progress
+	stats    *chain.RPCStats
+	// messages queued for the writer, and when it last submitted one
+	queued    int
+	submitted time.Time
+}
+
+func (s *source) Name() string              { return s.name }
+func (s *source) WriterGate() *chain.Gate   { return s.gate }
+func (s *source) Progress() *chain.Progress { return s.progress }
+func (s *source) RPCStats() []*chain.RPCStats {
+	return []*chain.RPCStats{s.stats}
+}
+func (s *source) WriterQueues() []chain.QueueStats {
+	return []chain.QueueStats{{App: "default", Queued: s.queued}}
+}
+func (s *source) LastSubmission() time.Time { return s.submitted }
+
+type sequences map[string]uint64
+
+func (s sequences) Latest() uint64                { return s["latest"] }
+func (s sequences) Recorded(chain string) uint64  { return s[chain+"/recorded"] }
+func (s sequences) Delivered(chain string) uint64 { return s[chain+"/delivered"] }
+
+type tasks []api.TaskStatus
+
+func (t tasks) Tasks() []api.TaskStatus { return t }
+
+func newSource(name string) *source {
+	stats := chain.NewRPCStats(name, "wss://rpc.example.com/v3/secret", &chain.RPCConfig{}, logrus.NewEntry(logrus.New()))
+	return &source{name: name, gate: chain.NewGate(), progress: chain.NewProgress(name), stats: stats}
+}
+
+func TestStatusServer_Status(t *testing.T) {
+	eth := newSource("Ethereum")
+	sub := newSource("Substrate")
+	server := api.NewStatusServer(&api.StatusConfig{StaleAfter: 60}, &api.Identity{ID: "0x01", Label: "relayer-1"}, []api.StatusSource{eth, sub}, sequences{"latest": 7, "Ethereum/recorded": 7, "Substrate/delivered": 6}, tasks{{Name: "replication", Runs: 3, Failures: 1}}, nil, logrus.NewEntry(logrus.New()))
+
+	now := time.Now()
+	eth.progress.Update(100, 100)
+	sub.progress.Update(50, 45)
+	sub.gate.Halt("maintenance")
+
+	status := server.Status(now)
+	assert.True(t, status.Healthy)
+	assert.True(t, status.Paused)
+	assert.Equal(t, uint64(5), status.Chains[1].Lag)
+	assert.True(t, status.Chains[1].Paused)
+	assert.False(t, status.Chains[0].Paused)
+	assert.Equal(t, "default", status.Chains[0].Queues[0].App)
+	assert.Equal(t, "relayer-1", status.Relayer.Label)
+	assert.Equal(t, uint64(7), status.Sequence)
+	assert.Equal(t, uint64(7), status.Chains[0].RecordedSequence)
+	assert.Equal(t, uint64(6), status.Chains[1].DeliveredSequence)
+	require.Len(t, status.Tasks, 1)
+	assert.Equal(t, "replication", status.Tasks[0].Name)
+	assert.Equal(t, uint64(1), status.Tasks[0].Failures)
+
+	// rpc statistics are published with endpoints redacted
+	eth.stats.Observe("eth_getLogs", time.Second, nil)
+	status = server.Status(now.Add(2 * time.Second))
+	require.Len(t, status.Chains[0].RPC, 1)
+	assert.Equal(t, "wss://rpc.example.com", status.Chains[0].RPC[0].Endpoint)
+	assert.Equal(t, uint64(1), status.Chains[0].RPC[0].Methods[0].Calls)
+
+	// chains which stopped making progress are unhealthy
+	status = server.Status(now.Add(2 * time.Minute))
+	assert.False(t, status.Healthy)
+	assert.False(t, status.Chains[0].Healthy)
+}
+
+func TestStatusServer_RateLimit(t *testing.T) {
+	server := api.NewStatusServer(&api.StatusConfig{RateLimit: 1}, nil, nil, nil, nil, nil, logrus.NewEntry(logrus.New()))
+
+	codes := map[int]int{}
+	for i := 0; i < 5; i++ {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+		codes[recorder.Code]++
+	}
+
+	assert.Equal(t, 2, codes[http.StatusOK])
+	assert.Equal(t, 3, codes[http.StatusTooManyRequests])
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package bridge embeds the relayer in other Go services. A bridge runs the same services as
+// the relay binary, configured in the same way, with hooks through which the service observes
+// the relayed messages and keeps the state of the relay in a store of its own:
+//
+//	config, err := bridge.LoadConfig("relay.toml")
+//	...
+//	b, err := bridge.NewBridge(config, bridge.OnConfirmed(func(msg *chain.Message, receipt *chain.Receipt) {
+//		...
+//	}))
+//	...
+//	err = b.Run(ctx)
+package bridge
+
+import (
+	"context"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// Config is the configuration of a bridge, that of the relay
+type Config = core.Config
+
+// Bridge is a relay embedded in another service
+type Bridge struct {
+	relay *core.Relay
+}
+
+// Option customizes a bridge
+type Option func(options *core.Options)
+
+// LoadConfig reads the configuration of a bridge from a file, or from the default locations
+// of the relay if path is empty, and its keys from the secret provider
+func LoadConfig(path string) (*Config, error) {
+	return core.LoadConfig(path, false)
+}
+
+// LoadExplorerConfig reads the configuration of a bridge in explorer mode, which needs no keys
+func LoadExplorerConfig(path string) (*Config, error) {
+	return core.LoadConfig(path, true)
+}
+
+// NewBridge creates a bridge from its configuration, without starting it
+func NewBridge(config *Config, options ...Option) (*Bridge, error) {
+	opts := &core.Options{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	relay, err := core.NewRelayWith(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Bridge{relay: relay}, nil
+}
+
+// Run runs the bridge until ctx is done or one of its services fails. The bridge can't be
+// run again once Run returns, as its store is closed.
+func (b *Bridge) Run(ctx context.Context) error {
+	return b.relay.Run(ctx)
+}
+
+// WithExplorer runs the bridge in explorer mode, which only records the observed messages
+// without delivering them. The configuration must be read with LoadExplorerConfig.
+func WithExplorer() Option {
+	return func(options *core.Options) {
+		options.Explorer = true
+	}
+}
+
+// WithStore keeps the state of the bridge in a database of the service, instead of the one
+// of the store section. The bridge closes the database once it stops.
+func WithStore(db store.DB) Option {
+	return func(options *core.Options) {
+		options.DB = db
+	}
+}
+
+// WithEventFeed reports the events observed by the listeners to a feed
+func WithEventFeed(feed chain.EventFeed) Option {
+	return func(options *core.Options) {
+		options.Events = append(options.Events, feed)
+	}
+}
+
+// WithReceiptLog reports the deliveries of messages to a receipt log
+func WithReceiptLog(receipts chain.ReceiptLog) Option {
+	return func(options *core.Options) {
+		options.Receipts = append(options.Receipts, receipts)
+	}
+}
+
+// OnObserved calls fn with each event observed by the listeners, and the message
+// generated for it if any
+func OnObserved(fn func(event *chain.ObservedEvent)) Option {
+	return WithEventFeed(observer(fn))
+}
+
+// WithSubscription hands the events observed by the listeners to a consumer reading them from
+// the journal at its own pace, without holding back the listeners or the other consumers. Its
+// cursor is recorded under its name, so that it resumes from the events it didn't consume
+// once the bridge is run again on the same store. The events carry no message.
+func WithSubscription(name string, consumer core.EventConsumer) Option {
+	return func(options *core.Options) {
+		if options.Subscribers == nil {
+			options.Subscribers = make(map[string]core.EventConsumer)
+		}
+		options.Subscribers[name] = consumer
+	}
+}
+
+// Subscribe calls fn with each observed event from a subscription of its own, retrying an
+// event after a delay while fn fails. See WithSubscription.
+func Subscribe(name string, fn func(ctx context.Context, event *chain.ObservedEvent) error) Option {
+	return WithSubscription(name, consumer(fn))
+}
+
+// OnSubmitted calls fn with each message once its delivery is submitted
+func OnSubmitted(fn func(msg *chain.Message, receipt *chain.Receipt)) Option {
+	return WithReceiptLog(&deliveries{submitted: fn})
+}
+
+// OnConfirmed calls fn with each message once its delivery is confirmed
+func OnConfirmed(fn func(msg *chain.Message, receipt *chain.Receipt)) Option {
+	return WithReceiptLog(&deliveries{confirmed: fn})
+}
+
+type observer func(event *chain.ObservedEvent)
+
+func (ob observer) Observed(event *chain.ObservedEvent) {
+	ob(event)
+}
+
+type consumer func(ctx context.Context, event *chain.ObservedEvent) error
+
+func (co consumer) Consume(ctx context.Context, event *chain.ObservedEvent) error {
+	return co(ctx, event)
+}
+
+// deliveries is a receipt log calling functions for the submitted or confirmed deliveries
+type deliveries struct {
+	submitted func(msg *chain.Message, receipt *chain.Receipt)
+	confirmed func(msg *chain.Message, receipt *chain.Receipt)
+}
+
+func (de *deliveries) Submitted(msg *chain.Message, receipt *chain.Receipt) {
+	if de.submitted != nil {
+		de.submitted(msg, receipt)
+	}
+}
+
+func (de *deliveries) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	if de.confirmed != nil {
+		de.confirmed(msg, receipt)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package bridge
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// closingDB records whether the bridge closed it
+type closingDB struct {
+	*store.MemoryDB
+	closed bool
+}
+
+func (cd *closingDB) Close() error {
+	cd.closed = true
+	return cd.MemoryDB.Close()
+}
+
+func TestOptions(t *testing.T) {
+	var observed, submitted, confirmed, consumed int
+	db := store.NewMemoryDB()
+
+	options := &core.Options{}
+	for _, option := range []Option{
+		WithExplorer(),
+		WithStore(db),
+		OnObserved(func(event *chain.ObservedEvent) { observed++ }),
+		OnSubmitted(func(msg *chain.Message, receipt *chain.Receipt) { submitted++ }),
+		OnConfirmed(func(msg *chain.Message, receipt *chain.Receipt) { confirmed++ }),
+		Subscribe("indexer", func(ctx context.Context, event *chain.ObservedEvent) error {
+			consumed++
+			return nil
+		}),
+	} {
+		option(options)
+	}
+
+	assert.True(t, options.Explorer)
+	assert.Equal(t, db, options.DB)
+	require.Len(t, options.Events, 1)
+	require.Len(t, options.Receipts, 2)
+
+	options.Events[0].Observed(&chain.ObservedEvent{})
+	for _, receipts := range options.Receipts {
+		receipts.Submitted(&chain.Message{}, &chain.Receipt{})
+		receipts.Confirmed(&chain.Message{}, &chain.Receipt{})
+	}
+	assert.Equal(t, 1, observed)
+	assert.Equal(t, 1, submitted)
+	assert.Equal(t, 1, confirmed)
+
+	require.Contains(t, options.Subscribers, "indexer")
+	require.NoError(t, options.Subscribers["indexer"].Consume(context.Background(), &chain.ObservedEvent{}))
+	assert.Equal(t, 1, consumed)
+}
+
+func TestBridge_Run(t *testing.T) {
+	config := &Config{}
+	config.Eth.ReadOnly = true
+	config.Sub.ReadOnly = true
+
+	db := &closingDB{MemoryDB: store.NewMemoryDB()}
+	b, err := NewBridge(config, WithExplorer(), WithStore(db))
+	require.NoError(t, err)
+
+	// failures to start are returned once the bridge stopped, and closed its store
+	assert.Error(t, b.Run(context.Background()))
+	assert.True(t, db.closed)
+}
+
+func TestBridge_RestoresLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logger := log.StandardLogger()
+	formatter := logger.Formatter
+	hooks := len(logger.Hooks[log.InfoLevel])
+
+	config := &Config{}
+	config.Eth.ReadOnly = true
+	config.Sub.ReadOnly = true
+	config.API.Address = "127.0.0.1:0"
+	config.Privacy.Mode = "redact"
+	config.Privacy.AuditLog = filepath.Join(dir, "audit.log")
+	config.Privacy.AuditKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	// the privacy formatter and the audit and support log hooks are removed once the bridge
+	// stops
+	b, err := NewBridge(config, WithExplorer(), WithStore(store.NewMemoryDB()))
+	require.NoError(t, err)
+	assert.Len(t, logger.Hooks[log.InfoLevel], hooks+2)
+	assert.Error(t, b.Run(context.Background()))
+	assert.Equal(t, formatter, logger.Formatter)
+	assert.Len(t, logger.Hooks[log.InfoLevel], hooks)
+
+	// and if the bridge can't be built
+	config.Eth.PrivateKey = "invalid"
+	_, err = NewBridge(config, WithStore(store.NewMemoryDB()))
+	assert.Error(t, err)
+	assert.Equal(t, formatter, logger.Formatter)
+	assert.Len(t, logger.Hooks[log.InfoLevel], hooks)
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type BackoffConfig struct {
+	// Delay in milliseconds before the first retry of a failed call. Defaults to 1000.
+	InitialDelay uint64 `mapstructure:"initial-delay"`
+	// Upper bound in milliseconds of the delay between retries. Defaults to 60000.
+	MaxDelay uint64 `mapstructure:"max-delay"`
+	// Factor by which the delay grows with each retry. Defaults to 2.
+	Multiplier float64 `mapstructure:"multiplier"`
+	// Share of each delay which is randomized, so that relayers which lost the same node
+	// don't retry in lockstep. Defaults to 0.2.
+	Jitter float64 `mapstructure:"jitter"`
+	// Attempts after which a call is given up. Zero retries without limit.
+	MaxAttempts int `mapstructure:"max-attempts"`
+	// Seconds after the first attempt at which a call is given up. Zero retries without limit.
+	MaxElapsed uint64 `mapstructure:"max-elapsed"`
+}
+
+const (
+	defaultInitialDelay = 1000
+	defaultMaxDelay     = 60000
+	defaultMultiplier   = 2
+	defaultJitter       = 0.2
+)
+
+// ErrorClass tells whether a failed call is worth retrying
+type ErrorClass string
+
+const (
+	// ErrorTransient is a failure which may not recur, such as a lost connection or a node
+	// which is overloaded or still syncing
+	ErrorTransient ErrorClass = "transient"
+	// ErrorFatal is a failure which recurs on every attempt, such as a rejected transaction
+	ErrorFatal ErrorClass = "fatal"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (pe *permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe *permanentError) Unwrap() error {
+	return pe.err
+}
+
+// Permanent marks an error as fatal, so that the call which failed is not retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// rpcError is implemented by the errors returned by JSON-RPC servers
+type rpcError interface {
+	ErrorCode() int
+}
+
+// fatalCodes are the JSON-RPC error codes of requests which fail on every attempt
+var fatalCodes = map[int]bool{
+	-32700: true, // parse error
+	-32600: true, // invalid request
+	-32601: true, // method not found
+	-32602: true, // invalid params
+	1010:   true, // Substrate: invalid transaction
+	1013:   true, // Substrate: transaction already imported
+	1014:   true, // Substrate: priority too low
+}
+
+// fatalMessages are the messages of transactions which Ethereum nodes reject on every attempt
+var fatalMessages = []string{
+	"nonce too low",
+	"insufficient funds",
+	"already known",
+	"known transaction",
+	"replacement transaction underpriced",
+	"execution reverted",
+	"intrinsic gas too low",
+	"exceeds block gas limit",
+	"invalid sender",
+}
+
+// Classify tells whether an error is worth retrying. Errors marked as permanent, errors which
+// stop the relayer and requests or transactions which the node rejects are fatal. All others,
+// such as connection failures, timeouts and rate limits, are treated as transient.
+func Classify(err error) ErrorClass {
+	var permanent *permanentError
+	if errors.As(err, &permanent) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, ErrConsumerStopped) ||
+		errors.Is(err, ErrUntrustedHistory) {
+		return ErrorFatal
+	}
+
+	var coded rpcError
+	if errors.As(err, &coded) && fatalCodes[coded.ErrorCode()] {
+		return ErrorFatal
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, fatal := range fatalMessages {
+		if strings.Contains(message, fatal) {
+			return ErrorFatal
+		}
+	}
+
+	return ErrorTransient
+}
+
+// Backoff retries failed calls with exponentially growing, randomized delays, until they
+// succeed, fail fatally or exhaust the configured budget. A nil Backoff makes a single attempt.
+type Backoff struct {
+	chain      string
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	attempts   int
+	elapsed    time.Duration
+}
+
+func NewBackoff(chain string, config *BackoffConfig) *Backoff {
+	bo := &Backoff{
+		chain:      chain,
+		initial:    time.Duration(config.InitialDelay) * time.Millisecond,
+		max:        time.Duration(config.MaxDelay) * time.Millisecond,
+		multiplier: config.Multiplier,
+		jitter:     config.Jitter,
+		attempts:   config.MaxAttempts,
+		elapsed:    time.Duration(config.MaxElapsed) * time.Second,
+	}
+	if bo.initial == 0 {
+		bo.initial = defaultInitialDelay * time.Millisecond
+	}
+	if bo.max == 0 {
+		bo.max = defaultMaxDelay * time.Millisecond
+	}
+	if bo.multiplier < 1 {
+		bo.multiplier = defaultMultiplier
+	}
+	if bo.jitter <= 0 || bo.jitter > 1 {
+		bo.jitter = defaultJitter
+	}
+	return bo
+}
+
+// Delay returns the randomized delay before a retry, counted from one. Delays grow by the
+// multiplier up to the maximum, and are randomized by the jitter in both directions.
+func (bo *Backoff) Delay(retry int) time.Duration {
+	delay := float64(bo.initial) * math.Pow(bo.multiplier, float64(retry-1))
+	if delay > float64(bo.max) {
+		delay = float64(bo.max)
+	}
+
+	delay *= 1 - bo.jitter + 2*bo.jitter*rand.Float64()
+	if delay > float64(bo.max) {
+		delay = float64(bo.max)
+	}
+	return time.Duration(delay)
+}
+
+// Retry calls fn until it succeeds, and returns the error of the last attempt once the error
+// is fatal or the budget of attempts and time is exhausted. Retries are logged with the name
+// of the operation.
+func (bo *Backoff) Retry(ctx context.Context, log *logrus.Entry, operation string, fn func() error) error {
+	if bo == nil {
+		return fn()
+	}
+
+	started := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if Classify(err) == ErrorFatal {
+			return err
+		}
+
+		if bo.attempts > 0 && attempt >= bo.attempts {
+			return fmt.Errorf("%s failed after %d attempts: %w", operation, attempt, err)
+		}
+
+		delay := bo.Delay(attempt)
+		if bo.elapsed > 0 && time.Since(started)+delay > bo.elapsed {
+			return fmt.Errorf("%s failed for %s: %w", operation, time.Since(started).Round(time.Second), err)
+		}
+
+		metrics.RPCRetries.WithLabelValues(bo.chain).Inc()
+		log.WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"delay":     delay,
+		}).Warn("Retrying failed call")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
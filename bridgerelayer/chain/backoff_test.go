@@ -0,0 +1,122 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type codedError int
+
+func (ce codedError) Error() string  { return fmt.Sprintf("rpc error %d", int(ce)) }
+func (ce codedError) ErrorCode() int { return int(ce) }
+
+func TestClassify(t *testing.T) {
+	transient := []error{
+		errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"),
+		errors.New("429 Too Many Requests"),
+		context.DeadlineExceeded,
+		codedError(-32005),
+	}
+	for _, err := range transient {
+		assert.Equal(t, chain.ErrorTransient, chain.Classify(err), err.Error())
+	}
+
+	fatal := []error{
+		chain.Permanent(errors.New("invalid payload")),
+		fmt.Errorf("submit: %w", chain.Permanent(errors.New("invalid payload"))),
+		context.Canceled,
+		chain.ErrConsumerStopped,
+		fmt.Errorf("verify: %w", chain.ErrUntrustedHistory),
+		codedError(-32601),
+		codedError(1010),
+		errors.New("nonce too low"),
+		errors.New("Insufficient funds for gas * price + value"),
+	}
+	for _, err := range fatal {
+		assert.Equal(t, chain.ErrorFatal, chain.Classify(err), err.Error())
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	backoff := chain.NewBackoff("Ethereum", &chain.BackoffConfig{InitialDelay: 100, MaxDelay: 1000, Jitter: 0.1})
+
+	for i := 0; i < 20; i++ {
+		assert.InDelta(t, float64(100*time.Millisecond), float64(backoff.Delay(1)), float64(10*time.Millisecond))
+		assert.InDelta(t, float64(400*time.Millisecond), float64(backoff.Delay(3)), float64(40*time.Millisecond))
+		// delays are capped, jitter included
+		assert.LessOrEqual(t, int64(backoff.Delay(10)), int64(time.Second))
+		assert.GreaterOrEqual(t, int64(backoff.Delay(10)), int64(900*time.Millisecond))
+	}
+}
+
+func TestBackoff_Retry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+	ctx := context.Background()
+
+	backoff := chain.NewBackoff("Ethereum", &chain.BackoffConfig{InitialDelay: 1, MaxDelay: 5, MaxAttempts: 4})
+
+	// transient failures are retried until the call succeeds
+	calls := 0
+	err := backoff.Retry(ctx, log, "fetch", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// fatal failures are returned at once
+	calls = 0
+	err = backoff.Retry(ctx, log, "submit", func() error {
+		calls++
+		return errors.New("nonce too low")
+	})
+	assert.EqualError(t, err, "nonce too low")
+	assert.Equal(t, 1, calls)
+
+	// calls are given up once the attempts are exhausted
+	calls = 0
+	unavailable := errors.New("service unavailable")
+	err = backoff.Retry(ctx, log, "fetch", func() error {
+		calls++
+		return unavailable
+	})
+	assert.True(t, errors.Is(err, unavailable))
+	assert.Equal(t, 4, calls)
+
+	// or once the time budget is exhausted
+	backoff = chain.NewBackoff("Ethereum", &chain.BackoffConfig{InitialDelay: 600, MaxElapsed: 1})
+	calls = 0
+	err = backoff.Retry(ctx, log, "fetch", func() error {
+		calls++
+		return unavailable
+	})
+	assert.True(t, errors.Is(err, unavailable))
+	assert.Equal(t, 2, calls)
+
+	// a nil backoff makes a single attempt
+	var none *chain.Backoff
+	calls = 0
+	err = none.Retry(ctx, log, "fetch", func() error {
+		calls++
+		return unavailable
+	})
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// BalanceConfig monitors the balances of the accounts which pay for the submissions of a
+// writer
+type BalanceConfig struct {
+	// Seconds between checks of the balances. Zero disables monitoring.
+	Interval uint64 `mapstructure:"interval"`
+	// Balance in base units of the native asset below which a warning is logged. Disabled
+	// if empty.
+	Threshold string `mapstructure:"threshold"`
+	// Whether submissions are halted while a balance can't cover the estimated cost of
+	// the next transaction
+	Pause bool `mapstructure:"pause"`
+}
+
+// ReasonInsufficientFunds halts writers whose account can't pay for the next transaction,
+// followed by the account
+const ReasonInsufficientFunds = "insufficient funds"
+
+// balanceTimeout bounds the calls made by each check of a balance
+const balanceTimeout = 30 * time.Second
+
+// Balance returns the balance of an account, in base units of the native asset
+type Balance func(ctx context.Context) (*big.Int, error)
+
+// NextCost returns the estimated cost of the next transaction paid by an account, in base
+// units of the native asset, or nil if it can't be estimated yet. Monitors without a
+// NextCost only compare the balance to the threshold.
+type NextCost func(ctx context.Context) (*big.Int, error)
+
+// BalanceMonitor periodically checks the balance of an account which pays for the
+// submissions of a writer, exporting it as a metric. It logs a warning while the balance is
+// below the threshold, and raises an alert while it can't cover the estimated cost of the
+// next transaction, halting the writer until it is funded again if configured to.
+type BalanceMonitor struct {
+	chain     string
+	account   string
+	interval  time.Duration
+	threshold *big.Int
+	pause     bool
+	gate      *Gate
+	balance   Balance
+	cost      NextCost
+	// whether the balance was last below the threshold, or below the cost
+	low       bool
+	uncovered bool
+	log       *logrus.Entry
+}
+
+func NewBalanceMonitor(chain string, account string, config *BalanceConfig, gate *Gate, balance Balance, cost NextCost, log *logrus.Entry) (*BalanceMonitor, error) {
+	var threshold *big.Int
+	if config.Threshold != "" {
+		var ok bool
+		threshold, ok = new(big.Int).SetString(config.Threshold, 10)
+		if !ok || threshold.Sign() < 0 {
+			return nil, fmt.Errorf("invalid balance threshold: %s", config.Threshold)
+		}
+	}
+
+	return &BalanceMonitor{
+		chain:     chain,
+		account:   account,
+		interval:  time.Duration(config.Interval) * time.Second,
+		threshold: threshold,
+		pause:     config.Pause,
+		gate:      gate,
+		balance:   balance,
+		cost:      cost,
+		log:       log.WithField("account", account),
+	}, nil
+}
+
+// Enabled returns whether a check interval is configured
+func (bm *BalanceMonitor) Enabled() bool {
+	return bm.interval > 0
+}
+
+// Interval returns the interval between checks of the balance, which the relay schedules
+func (bm *BalanceMonitor) Interval() time.Duration {
+	return bm.interval
+}
+
+// Check reads the balance and the estimated cost of the next transaction, and updates the
+// warnings and the halt of the writer they cause. The state is left unchanged if the
+// balance can't be read.
+func (bm *BalanceMonitor) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, balanceTimeout)
+	defer cancel()
+
+	balance, err := bm.balance(ctx)
+	if err != nil {
+		return err
+	}
+	value, _ := new(big.Float).SetInt(balance).Float64()
+	metrics.AccountBalance.WithLabelValues(bm.chain, bm.account).Set(value)
+
+	fields := logrus.Fields{"balance": balance.String()}
+
+	low := bm.threshold != nil && balance.Cmp(bm.threshold) < 0
+	if low {
+		bm.log.WithFields(fields).WithField("threshold", bm.threshold.String()).Warn("Balance of relayer account is below its threshold")
+	} else if bm.low {
+		bm.log.WithFields(fields).Info("Balance of relayer account is above its threshold again")
+	}
+	bm.low = low
+
+	if bm.cost == nil {
+		return nil
+	}
+	cost, err := bm.cost(ctx)
+	if err != nil {
+		return fmt.Errorf("estimate cost of next transaction: %w", err)
+	}
+	if cost == nil {
+		return nil
+	}
+	fields["cost"] = cost.String()
+
+	reason := ReasonInsufficientFunds + ": " + bm.account
+	uncovered := balance.Cmp(cost) < 0
+	switch {
+	case uncovered && !bm.uncovered:
+		if bm.pause {
+			bm.gate.Halt(reason)
+			bm.log.WithFields(fields).Error("ALERT: relayer account can't pay for the next transaction, halted submissions")
+		} else {
+			bm.log.WithFields(fields).Error("ALERT: relayer account can't pay for the next transaction")
+		}
+	case !uncovered && bm.uncovered:
+		if bm.pause {
+			bm.gate.Release(reason)
+		}
+		bm.log.WithFields(fields).Info("Relayer account can pay for the next transaction again")
+	}
+	bm.uncovered = uncovered
+	return nil
+}
+
+// CheckBalances checks the balances of several accounts, returning the first failure once
+// all of them were checked
+func CheckBalances(ctx context.Context, monitors []*BalanceMonitor) error {
+	var failure error
+	for _, monitor := range monitors {
+		err := monitor.Check(ctx)
+		if err != nil && failure == nil {
+			failure = fmt.Errorf("check balance of %s: %w", monitor.account, err)
+		}
+	}
+	return failure
+}
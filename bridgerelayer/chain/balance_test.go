@@ -0,0 +1,99 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestBalanceMonitor(t *testing.T) {
+	gate := chain.NewGate()
+	balance := big.NewInt(1000)
+	var cost *big.Int
+	monitor, err := chain.NewBalanceMonitor("ethereum", "0xaa", &chain.BalanceConfig{Interval: 60, Threshold: "500", Pause: true}, gate,
+		func(ctx context.Context) (*big.Int, error) { return balance, nil },
+		func(ctx context.Context) (*big.Int, error) { return cost, nil },
+		logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	assert.True(t, monitor.Enabled())
+
+	// the balance isn't compared to a cost until one is estimated
+	balance = big.NewInt(10)
+	require.NoError(t, monitor.Check(context.Background()))
+	halted, _ := gate.Halted()
+	assert.False(t, halted)
+
+	cost = big.NewInt(100)
+	require.NoError(t, monitor.Check(context.Background()))
+	halted, reason := gate.Halted()
+	assert.True(t, halted)
+	assert.Contains(t, reason, chain.ReasonInsufficientFunds)
+
+	// the writer stays halted while the balance can't be read
+	failing, err := chain.NewBalanceMonitor("ethereum", "0xaa", &chain.BalanceConfig{Pause: true}, gate,
+		func(ctx context.Context) (*big.Int, error) { return nil, fmt.Errorf("unavailable") }, nil,
+		logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	assert.False(t, failing.Enabled())
+	assert.Error(t, failing.Check(context.Background()))
+
+	balance = big.NewInt(200)
+	require.NoError(t, monitor.Check(context.Background()))
+	halted, _ = gate.Halted()
+	assert.False(t, halted)
+}
+
+func TestBalanceMonitor_NoPause(t *testing.T) {
+	gate := chain.NewGate()
+	monitor, err := chain.NewBalanceMonitor("substrate", "alice", &chain.BalanceConfig{Interval: 60}, gate,
+		func(ctx context.Context) (*big.Int, error) { return big.NewInt(1), nil },
+		func(ctx context.Context) (*big.Int, error) { return big.NewInt(100), nil },
+		logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	require.NoError(t, monitor.Check(context.Background()))
+	halted, _ := gate.Halted()
+	assert.False(t, halted)
+}
+
+func TestNewBalanceMonitor_InvalidThreshold(t *testing.T) {
+	for _, threshold := range []string{"1e18", "-1", "lots"} {
+		_, err := chain.NewBalanceMonitor("ethereum", "0xaa", &chain.BalanceConfig{Threshold: threshold}, chain.NewGate(), nil, nil, logrus.NewEntry(logrus.New()))
+		assert.Error(t, err, threshold)
+	}
+}
+
+func TestCheckBalances(t *testing.T) {
+	checked := 0
+	newMonitor := func(account string, err error) *chain.BalanceMonitor {
+		monitor, newErr := chain.NewBalanceMonitor("ethereum", account, &chain.BalanceConfig{Interval: 60}, chain.NewGate(),
+			func(ctx context.Context) (*big.Int, error) {
+				checked++
+				if err != nil {
+					return nil, err
+				}
+				return big.NewInt(1), nil
+			}, nil,
+			logrus.NewEntry(logrus.New()))
+		require.NoError(t, newErr)
+		return monitor
+	}
+
+	// the accounts following a failing one are still checked
+	err := chain.CheckBalances(context.Background(), []*chain.BalanceMonitor{
+		newMonitor("0xaa", fmt.Errorf("unavailable")),
+		newMonitor("0xbb", nil),
+	})
+	assert.EqualError(t, err, "check balance of 0xaa: unavailable")
+	assert.Equal(t, 2, checked)
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BlockRef identifies a block by number, or by hash so that an operation applies to exactly
+// that block, which must then be on the canonical chain. The zero BlockRef is unset.
+type BlockRef struct {
+	Number uint64
+	// 0x-prefixed hash, empty if the block is identified by number
+	Hash string
+}
+
+// ParseBlockRef parses a block number, or a 0x-prefixed block hash
+func ParseBlockRef(value string) (BlockRef, error) {
+	if strings.HasPrefix(value, "0x") {
+		hash, err := parseHash(value)
+		if err != nil {
+			return BlockRef{}, fmt.Errorf("%s is not a block hash: %w", value, err)
+		}
+		return BlockRef{Hash: fmt.Sprintf("%#x", hash)}, nil
+	}
+
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return BlockRef{}, fmt.Errorf("%s is neither a block number nor a block hash", value)
+	}
+	return BlockRef{Number: number}, nil
+}
+
+// Pinned returns whether the block is identified by hash
+func (br BlockRef) Pinned() bool {
+	return br.Hash != ""
+}
+
+func (br BlockRef) String() string {
+	if br.Pinned() {
+		return br.Hash
+	}
+	return strconv.FormatUint(br.Number, 10)
+}
+
+// VerifyCanonical checks that a pinned block is the block of its height on the canonical chain
+func VerifyCanonical(hash string, number uint64, canonical string) error {
+	if !strings.EqualFold(hash, canonical) {
+		return fmt.Errorf("block %s is not on the canonical chain, whose block %d is %s", hash, number, canonical)
+	}
+	return nil
+}
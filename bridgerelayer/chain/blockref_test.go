@@ -0,0 +1,35 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestParseBlockRef(t *testing.T) {
+	ref, err := chain.ParseBlockRef("1200")
+	assert.NoError(t, err)
+	assert.Equal(t, chain.BlockRef{Number: 1200}, ref)
+	assert.False(t, ref.Pinned())
+	assert.Equal(t, "1200", ref.String())
+
+	ref, err = chain.ParseBlockRef("0x9A07C4F5E0E5D3A1B6A0C1D2E3F405162738495A6B7C8D9E0F1A2B3C4D5E6F71")
+	assert.NoError(t, err)
+	assert.True(t, ref.Pinned())
+	assert.Equal(t, "0x9a07c4f5e0e5d3a1b6a0c1d2e3f405162738495a6b7c8d9e0f1a2b3c4d5e6f71", ref.String())
+
+	for _, value := range []string{"", "latest", "-1", "0x9a07", "0xzz"} {
+		_, err = chain.ParseBlockRef(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestVerifyCanonical(t *testing.T) {
+	assert.NoError(t, chain.VerifyCanonical("0xAB", 12, "0xab"))
+	assert.EqualError(t, chain.VerifyCanonical("0xab", 12, "0xcd"), "block 0xab is not on the canonical chain, whose block 12 is 0xcd")
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"time"
+)
+
+// BudgetConfig bounds the time to deliver the messages of an app, measured from when
+// they were observed by the listener. Disabled if the latency is zero.
+type BudgetConfig struct {
+	// Seconds from observing a message until it should be delivered
+	Latency uint64 `mapstructure:"latency"`
+	// Fraction of the budget consumed at which submission is escalated. Defaults to 0.5.
+	EscalateAt float64 `mapstructure:"escalate-at"`
+	// Fraction of the budget consumed at which an alert is raised. Defaults to 0.8.
+	AlertAt float64 `mapstructure:"alert-at"`
+}
+
+const (
+	defaultBudgetEscalateAt = 0.5
+	defaultBudgetAlertAt    = 0.8
+)
+
+// Budget is the latency budget of the messages of an app
+type Budget struct {
+	latency    time.Duration
+	escalateAt float64
+	alertAt    float64
+}
+
+// NewBudget returns nil if the budget is disabled
+func NewBudget(config *BudgetConfig) *Budget {
+	if config == nil || config.Latency == 0 {
+		return nil
+	}
+
+	escalateAt := config.EscalateAt
+	if escalateAt <= 0 {
+		escalateAt = defaultBudgetEscalateAt
+	}
+
+	alertAt := config.AlertAt
+	if alertAt <= 0 {
+		alertAt = defaultBudgetAlertAt
+	}
+
+	return &Budget{
+		latency:    time.Duration(config.Latency) * time.Second,
+		escalateAt: escalateAt,
+		alertAt:    alertAt,
+	}
+}
+
+// Consumed returns the fraction of the budget used by a message observed at the given
+// time, which exceeds 1 once the budget is depleted. Messages which were not observed
+// by a listener, such as those built by the console, consume nothing.
+func (b *Budget) Consumed(observedAt time.Time, now time.Time) float64 {
+	if observedAt.IsZero() {
+		return 0
+	}
+	return float64(now.Sub(observedAt)) / float64(b.latency)
+}
+
+// Escalate returns whether the submission of a message should be escalated
+func (b *Budget) Escalate(consumed float64) bool {
+	return consumed >= b.escalateAt
+}
+
+// Alert returns whether the delivery of a message is at risk of missing its budget
+func (b *Budget) Alert(consumed float64) bool {
+	return consumed >= b.alertAt
+}
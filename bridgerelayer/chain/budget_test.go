@@ -0,0 +1,36 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	assert.Nil(t, NewBudget(nil))
+	assert.Nil(t, NewBudget(&BudgetConfig{}))
+
+	budget := NewBudget(&BudgetConfig{Latency: 100})
+	now := time.Now()
+
+	consumed := budget.Consumed(now.Add(-40*time.Second), now)
+	assert.InDelta(t, 0.4, consumed, 1e-9)
+	assert.False(t, budget.Escalate(consumed))
+	assert.False(t, budget.Alert(consumed))
+
+	consumed = budget.Consumed(now.Add(-80*time.Second), now)
+	assert.True(t, budget.Escalate(consumed))
+	assert.True(t, budget.Alert(consumed))
+
+	// messages which were not observed by a listener have no deadline
+	assert.Zero(t, budget.Consumed(time.Time{}, now))
+
+	budget = NewBudget(&BudgetConfig{Latency: 100, EscalateAt: 0.9, AlertAt: 0.95})
+	consumed = budget.Consumed(now.Add(-80*time.Second), now)
+	assert.False(t, budget.Escalate(consumed))
+	assert.False(t, budget.Alert(consumed))
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize bounds the memory retained by pooled buffers. Buffers which grew
+// beyond it while encoding an unusually large payload are left to the garbage collector.
+const maxPooledBufferSize = 64 * 1024
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// GetBuffer returns an empty buffer from the pool
+func GetBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// PutBuffer returns a buffer to the pool. The buffer must not be used afterwards.
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffers.Put(buf)
+}
+
+// CopyBytes returns a copy of the buffer's contents which outlives the buffer
+func CopyBytes(buf *bytes.Buffer) []byte {
+	return append(make([]byte, 0, buf.Len()), buf.Bytes()...)
+}
@@ -5,17 +5,96 @@ package chain
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 )
 
 type Message struct {
-	AppID   [20]byte
-	Payload interface{}
+	// ID assigned to the message by the message store, empty if it was not recorded
+	ID string
+	// Bridge-wide sequence number assigned by the message store, zero if it was not recorded
+	Sequence uint64
+	AppID    [20]byte
+	Payload  interface{}
+	// Time at which the listener observed the message, zero if it was built otherwise
+	ObservedAt time.Time
+	// Name of the chain whose event generated the message, empty if it was built otherwise
+	Source string
+	// Identifier of the source chain, the chain ID of Ethereum or the genesis hash of
+	// Substrate, empty if unknown
+	SourceChainID string
+	// Block of the source chain whose event generated the message, zero if unknown
+	SourceBlock uint64
+	// Hash of SourceBlock, empty if unknown
+	SourceHash string
+	// Index of the event within SourceBlock, the log index on Ethereum
+	EventIndex uint64
+	// Whether the message was replayed by an operator, so that it is routed even if it
+	// duplicates a message which was already routed
+	Replayed bool
+	// Ethereum network to which the message is delivered, empty for the network of the
+	// ethereum section of the configuration
+	Network string
+	// Name of the routing override matching the recipient of the message, whose lane
+	// delivers it if it has one, empty if none matched
+	Override string
+	// Whether the message skips the rate limit of its lane and is submitted escalated,
+	// as set by its routing override
+	Priority bool
+	// Context of the last span of the pipeline which handled the message, from which the
+	// spans of its delivery descend. Invalid if the message isn't traced.
+	Trace tracing.SpanContext
+}
+
+// Call is the payload of messages delivered as an arbitrary call of the target chain,
+// rather than through the entry point of its bridge
+type Call struct {
+	// Module and call, for example Assets.mint, resolved by the writer
+	Name string
+	// Encoded arguments of the call
+	Args []byte
 }
 
+// Chain relays the messages of a chain. Its listener observes the events of the chain and
+// its writer delivers the messages routed to it. Further facilities of the relayer, such as
+// hole repair, proofs or the kill switch, discover what a chain supports through the
+// interfaces it implements, such as Listener, Writer or those of the core package.
 type Chain interface {
 	Name() string
 	Start(ctx context.Context, eg *errgroup.Group) error
 	Stop()
 }
+
+// Listener observes the events of a chain, sending the messages built from those which are
+// relayed
+type Listener interface {
+	Start(ctx context.Context, eg *errgroup.Group) error
+	Progress() *Progress
+}
+
+// Writer delivers the messages routed to a chain
+type Writer interface {
+	Start(ctx context.Context, eg *errgroup.Group) error
+	// Write delivers a message without waiting for its confirmation
+	Write(ctx context.Context, msg *Message) error
+	// Gate halts and resumes the deliveries
+	Gate() *Gate
+	Queues() []QueueStats
+	// LastSubmission is zero if nothing was submitted yet
+	LastSubmission() time.Time
+}
+
+// Connection connects the components of a chain to one of its nodes
+type Connection interface {
+	Connect(ctx context.Context) error
+	Close()
+	// Stats may be nil
+	Stats() *RPCStats
+}
+
+// ErrEventNotFound is returned when a block has no relayed event at the requested index
+var ErrEventNotFound = errors.New("no relayed event at this index")
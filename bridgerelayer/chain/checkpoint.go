@@ -0,0 +1,302 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CheckpointConfig pins a trusted block of a chain. Pinning is disabled if the hash is empty.
+type CheckpointConfig struct {
+	Number    uint64 `mapstructure:"number" json:"number"`
+	Hash      string `mapstructure:"hash" json:"hash"`
+	StateRoot string `mapstructure:"state-root" json:"stateRoot"`
+	// Maximum number of blocks walked through parent hashes to connect a block to the
+	// checkpoint. Pin a more recent checkpoint if the relayer starts further from it.
+	MaxDistance uint64 `mapstructure:"max-distance" json:"-"`
+}
+
+const defaultCheckpointMaxDistance = 10000
+
+// ErrUntrustedHistory is returned for blocks which are not known to descend from the checkpoint
+var ErrUntrustedHistory = errors.New("untrusted chain history")
+
+// Header is the part of a block header needed to verify ancestry
+type Header struct {
+	Number     uint64
+	Hash       [32]byte
+	ParentHash [32]byte
+	StateRoot  [32]byte
+}
+
+// HeaderByHash fetches the header of a block from a node
+type HeaderByHash func(hash [32]byte) (*Header, error)
+
+// Checkpoint verifies that the blocks served by a node descend from a trusted checkpoint,
+// so that a malicious node cannot feed the relayer an alternative chain history.
+//
+// Blocks are connected to the checkpoint by walking their parent hashes back to the most
+// recent verified block. Verified blocks deeper than the reorg depth of the chain become
+// the new anchor of later walks, so that walks stay short while the relayer is running.
+type Checkpoint struct {
+	config     *CheckpointConfig
+	hash       [32]byte
+	stateRoot  [32]byte
+	reorgDepth uint64
+	mutex      sync.Mutex
+	pinned     bool
+	anchor     Header
+	verified   map[[32]byte]Header
+	// height of the anchor last recorded in a CheckpointStore
+	saved uint64
+}
+
+// NewCheckpoint parses a checkpoint config, returning nil if pinning is disabled.
+// Blocks up to reorgDepth below the highest verified block may still be replaced.
+func NewCheckpoint(config *CheckpointConfig, reorgDepth uint64) (*Checkpoint, error) {
+	if config.Hash == "" {
+		return nil, nil
+	}
+
+	hash, err := parseHash(config.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint hash: %w", err)
+	}
+
+	stateRoot, err := parseHash(config.StateRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint state root: %w", err)
+	}
+
+	return &Checkpoint{
+		config:     config,
+		hash:       hash,
+		stateRoot:  stateRoot,
+		reorgDepth: reorgDepth,
+		verified:   make(map[[32]byte]Header),
+	}, nil
+}
+
+// LoadCheckpoint returns the checkpoint recorded in the store if it is more recent than the
+// configured one, for example after restoring the snapshot of another instance, and the
+// configured checkpoint otherwise
+func LoadCheckpoint(config *CheckpointConfig, store CheckpointStore, source string) (*CheckpointConfig, error) {
+	if store == nil {
+		return config, nil
+	}
+
+	stored, err := store.LoadCheckpoint(source)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || (config.Hash != "" && stored.Number <= config.Number) {
+		return config, nil
+	}
+
+	result := *stored
+	result.MaxDistance = config.MaxDistance
+	return &result, nil
+}
+
+// Number returns the height of the checkpoint
+func (cp *Checkpoint) Number() uint64 {
+	return cp.config.Number
+}
+
+// Pin verifies the header which a node serves at the height of the checkpoint
+func (cp *Checkpoint) Pin(header *Header) error {
+	if header.Number != cp.config.Number {
+		return fmt.Errorf("%w: expected checkpoint header %d, got %d", ErrUntrustedHistory, cp.config.Number, header.Number)
+	}
+	if header.Hash != cp.hash {
+		return fmt.Errorf("%w: block %d has hash 0x%x, not the pinned 0x%x", ErrUntrustedHistory, header.Number, header.Hash, cp.hash)
+	}
+	if header.StateRoot != cp.stateRoot {
+		return fmt.Errorf("%w: block %d has state root 0x%x, not the pinned 0x%x", ErrUntrustedHistory, header.Number, header.StateRoot, cp.stateRoot)
+	}
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	cp.pinned = true
+	cp.anchor = *header
+
+	return nil
+}
+
+// Anchor returns the most recent verified block which is deeper than the reorg depth, as a
+// checkpoint from which later instances can verify. False is returned until pinned.
+func (cp *Checkpoint) Anchor() (CheckpointConfig, bool) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if !cp.pinned {
+		return CheckpointConfig{}, false
+	}
+
+	return CheckpointConfig{
+		Number:    cp.anchor.Number,
+		Hash:      fmt.Sprintf("0x%x", cp.anchor.Hash),
+		StateRoot: fmt.Sprintf("0x%x", cp.anchor.StateRoot),
+	}, true
+}
+
+// Save records the anchor in the store if it advanced since it was last saved
+func (cp *Checkpoint) Save(store CheckpointStore, source string) error {
+	anchor, ok := cp.Anchor()
+	if !ok {
+		return nil
+	}
+
+	cp.mutex.Lock()
+	saved := cp.saved
+	cp.mutex.Unlock()
+	if anchor.Number <= saved {
+		return nil
+	}
+
+	err := store.SaveCheckpoint(source, &anchor)
+	if err != nil {
+		return err
+	}
+
+	cp.mutex.Lock()
+	cp.saved = anchor.Number
+	cp.mutex.Unlock()
+	return nil
+}
+
+// Connect verifies that a block descends from the checkpoint, fetching its ancestors
+// from the node as needed
+func (cp *Checkpoint) Connect(header *Header, fetch HeaderByHash) error {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if !cp.pinned {
+		return fmt.Errorf("checkpoint is not pinned")
+	}
+
+	if header.Number < cp.config.Number {
+		return fmt.Errorf("%w: block %d precedes the checkpoint at %d", ErrUntrustedHistory, header.Number, cp.config.Number)
+	}
+
+	if _, ok := cp.verified[header.Hash]; ok || header.Hash == cp.anchor.Hash {
+		return nil
+	}
+
+	if header.Number < cp.anchor.Number {
+		return cp.connectBelowAnchor(header, fetch)
+	}
+
+	// Walk back until reaching a verified block or the height of the anchor
+	path := []Header{}
+	current := *header
+	for {
+		if _, ok := cp.verified[current.Hash]; ok {
+			break
+		}
+		if current.Number == cp.anchor.Number {
+			if current.Hash != cp.anchor.Hash {
+				return fmt.Errorf("%w: block %d does not descend from the checkpoint", ErrUntrustedHistory, header.Number)
+			}
+			break
+		}
+		if uint64(len(path)) >= cp.maxDistance() {
+			return fmt.Errorf("%w: block %d is more than %d blocks from the last verified block", ErrUntrustedHistory, header.Number, cp.maxDistance())
+		}
+
+		path = append(path, current)
+
+		parent, err := fetch(current.ParentHash)
+		if err != nil {
+			return err
+		}
+		if parent.Number+1 != current.Number || parent.Hash != current.ParentHash {
+			return fmt.Errorf("%w: node served an inconsistent parent of block %d", ErrUntrustedHistory, current.Number)
+		}
+		current = *parent
+	}
+
+	for _, verified := range path {
+		cp.verified[verified.Hash] = verified
+	}
+	cp.advance(header)
+
+	return nil
+}
+
+// connectBelowAnchor verifies an older block, as reprocessed by repairs, by walking back
+// from the anchor to its height
+func (cp *Checkpoint) connectBelowAnchor(header *Header, fetch HeaderByHash) error {
+	if cp.anchor.Number-header.Number > cp.maxDistance() {
+		return fmt.Errorf("%w: block %d is more than %d blocks below the last verified block", ErrUntrustedHistory, header.Number, cp.maxDistance())
+	}
+
+	current := cp.anchor
+	for current.Number > header.Number {
+		parent, err := fetch(current.ParentHash)
+		if err != nil {
+			return err
+		}
+		if parent.Number+1 != current.Number || parent.Hash != current.ParentHash {
+			return fmt.Errorf("%w: node served an inconsistent parent of block %d", ErrUntrustedHistory, current.Number)
+		}
+		current = *parent
+	}
+
+	if current.Hash != header.Hash {
+		return fmt.Errorf("%w: block %d does not descend from the checkpoint", ErrUntrustedHistory, header.Number)
+	}
+	return nil
+}
+
+// advance moves the anchor to the ancestor of head at reorg depth, forgetting older blocks
+func (cp *Checkpoint) advance(head *Header) {
+	if head.Number < cp.reorgDepth || head.Number-cp.reorgDepth <= cp.anchor.Number {
+		return
+	}
+	target := head.Number - cp.reorgDepth
+
+	current := cp.verified[head.Hash]
+	for current.Number > target {
+		parent, ok := cp.verified[current.ParentHash]
+		if !ok {
+			return
+		}
+		current = parent
+	}
+
+	cp.anchor = current
+	for hash, verified := range cp.verified {
+		if verified.Number <= target {
+			delete(cp.verified, hash)
+		}
+	}
+}
+
+func (cp *Checkpoint) maxDistance() uint64 {
+	if cp.config.MaxDistance == 0 {
+		return defaultCheckpointMaxDistance
+	}
+	return cp.config.MaxDistance
+}
+
+func parseHash(value string) ([32]byte, error) {
+	var hash [32]byte
+
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return hash, err
+	}
+	if len(data) != len(hash) {
+		return hash, fmt.Errorf("expected 32 bytes, got %d", len(data))
+	}
+
+	copy(hash[:], data)
+	return hash, nil
+}
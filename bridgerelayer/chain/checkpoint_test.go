@@ -0,0 +1,160 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// testChain builds headers from..to whose hashes are derived from a fork id
+type testChain map[[32]byte]*chain.Header
+
+func (tc testChain) extend(parent *chain.Header, to uint64, fork byte) *chain.Header {
+	current := parent
+	for number := parent.Number + 1; number <= to; number++ {
+		header := &chain.Header{Number: number, ParentHash: current.Hash}
+		header.Hash[0] = fork
+		header.Hash[1] = byte(number >> 8)
+		header.Hash[2] = byte(number)
+		tc[header.Hash] = header
+		current = header
+	}
+	return current
+}
+
+func (tc testChain) fetch(hash [32]byte) (*chain.Header, error) {
+	header, ok := tc[hash]
+	if !ok {
+		return nil, fmt.Errorf("unknown block")
+	}
+	return header, nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	genesis := &chain.Header{Number: 100, Hash: [32]byte{1}, StateRoot: [32]byte{2}}
+	blocks := testChain{genesis.Hash: genesis}
+
+	config := chain.CheckpointConfig{
+		Number:    100,
+		Hash:      "0x0100000000000000000000000000000000000000000000000000000000000000",
+		StateRoot: "0x0200000000000000000000000000000000000000000000000000000000000000",
+	}
+	cp, err := chain.NewCheckpoint(&config, 2)
+	require.NoError(t, err)
+
+	// headers which don't match the checkpoint are refused
+	assert.Error(t, cp.Pin(&chain.Header{Number: 100, Hash: [32]byte{1}}))
+	require.NoError(t, cp.Pin(genesis))
+
+	head := blocks.extend(genesis, 110, 0xaa)
+	assert.NoError(t, cp.Connect(head, blocks.fetch))
+
+	// a reorg within the reorg depth still connects
+	parent, _ := blocks.fetch(head.ParentHash)
+	parent, _ = blocks.fetch(parent.ParentHash)
+	reorg := blocks.extend(parent, 111, 0xbb)
+	assert.NoError(t, cp.Connect(reorg, blocks.fetch))
+
+	// blocks of an alternative history are refused
+	forged := blocks.extend(&chain.Header{Number: 100, Hash: [32]byte{9}}, 112, 0xcc)
+	err = cp.Connect(forged, blocks.fetch)
+	assert.True(t, errors.Is(err, chain.ErrUntrustedHistory))
+
+	// as are blocks preceding the checkpoint
+	assert.Error(t, cp.Connect(&chain.Header{Number: 99}, blocks.fetch))
+
+	// older blocks are verified by walking back from the anchor
+	older := head
+	for older.Number > 103 {
+		older, _ = blocks.fetch(older.ParentHash)
+	}
+	assert.NoError(t, cp.Connect(older, blocks.fetch))
+	assert.Error(t, cp.Connect(&chain.Header{Number: 103, Hash: [32]byte{7}}, blocks.fetch))
+}
+
+func TestCheckpoint_MaxDistance(t *testing.T) {
+	genesis := &chain.Header{Number: 0, Hash: [32]byte{1}}
+	blocks := testChain{genesis.Hash: genesis}
+
+	config := chain.CheckpointConfig{
+		Hash:        "0x0100000000000000000000000000000000000000000000000000000000000000",
+		StateRoot:   "0x0000000000000000000000000000000000000000000000000000000000000000",
+		MaxDistance: 5,
+	}
+	cp, err := chain.NewCheckpoint(&config, 0)
+	require.NoError(t, err)
+	require.NoError(t, cp.Pin(genesis))
+
+	assert.Error(t, cp.Connect(blocks.extend(genesis, 10, 0xaa), blocks.fetch))
+}
+
+func TestNewCheckpoint_Disabled(t *testing.T) {
+	cp, err := chain.NewCheckpoint(&chain.CheckpointConfig{}, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+
+	_, err = chain.NewCheckpoint(&chain.CheckpointConfig{Hash: "0x01"}, 0)
+	assert.Error(t, err)
+}
+
+type testCheckpointStore map[string]*chain.CheckpointConfig
+
+func (ts testCheckpointStore) LoadCheckpoint(source string) (*chain.CheckpointConfig, error) {
+	return ts[source], nil
+}
+
+func (ts testCheckpointStore) SaveCheckpoint(source string, checkpoint *chain.CheckpointConfig) error {
+	ts[source] = checkpoint
+	return nil
+}
+
+func TestCheckpoint_Save(t *testing.T) {
+	genesis := &chain.Header{Number: 0, Hash: [32]byte{1}}
+	blocks := testChain{genesis.Hash: genesis}
+
+	config := chain.CheckpointConfig{
+		Hash:        "0x0100000000000000000000000000000000000000000000000000000000000000",
+		StateRoot:   "0x0000000000000000000000000000000000000000000000000000000000000000",
+		MaxDistance: 20,
+	}
+	cp, err := chain.NewCheckpoint(&config, 2)
+	require.NoError(t, err)
+	require.NoError(t, cp.Pin(genesis))
+
+	head := blocks.extend(genesis, 10, 0xaa)
+	require.NoError(t, cp.Connect(head, blocks.fetch))
+
+	checkpoints := testCheckpointStore{}
+	require.NoError(t, cp.Save(checkpoints, "test"))
+	saved := checkpoints["test"]
+	require.NotNil(t, saved)
+	assert.Equal(t, uint64(8), saved.Number)
+
+	// a more recent stored checkpoint takes precedence over the configured one
+	loaded, err := chain.LoadCheckpoint(&config, checkpoints, "test")
+	require.NoError(t, err)
+	assert.Equal(t, saved.Hash, loaded.Hash)
+	assert.Equal(t, uint64(20), loaded.MaxDistance)
+
+	// which connects later blocks from the advanced anchor
+	restored, err := chain.NewCheckpoint(loaded, 2)
+	require.NoError(t, err)
+	anchor := head
+	for anchor.Number > 8 {
+		anchor, _ = blocks.fetch(anchor.ParentHash)
+	}
+	require.NoError(t, restored.Pin(anchor))
+	assert.NoError(t, restored.Connect(blocks.extend(head, 12, 0xaa), blocks.fetch))
+
+	loaded, err = chain.LoadCheckpoint(&config, nil, "test")
+	require.NoError(t, err)
+	assert.Equal(t, &config, loaded)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClockConfig configures the sanity checks of block timestamps
+type ClockConfig struct {
+	// Seconds a block timestamp may lie in the future of local time. Zero disables the check.
+	MaxClockSkew uint64 `mapstructure:"max-clock-skew"`
+	// Seconds a block may lag behind local time. Zero disables the check.
+	MaxBlockAge uint64 `mapstructure:"max-block-age"`
+}
+
+// ClockMonitor validates the timestamps of the latest blocks served by a node against
+// local time. Future-dated blocks point at clock skew or a misbehaving node, while stale
+// blocks are an early warning that the node has stopped syncing.
+type ClockMonitor struct {
+	maxSkew time.Duration
+	maxAge  time.Duration
+	mutex   sync.Mutex
+	failing bool
+	log     *logrus.Entry
+}
+
+func NewClockMonitor(config *ClockConfig, log *logrus.Entry) *ClockMonitor {
+	return &ClockMonitor{
+		maxSkew: time.Duration(config.MaxClockSkew) * time.Second,
+		maxAge:  time.Duration(config.MaxBlockAge) * time.Second,
+		log:     log,
+	}
+}
+
+// Enabled returns whether any check is configured
+func (cm *ClockMonitor) Enabled() bool {
+	return cm.maxSkew > 0 || cm.maxAge > 0
+}
+
+// Observe checks the timestamp of the latest block. Alerts are logged when a check
+// starts failing, and again once timestamps are back within tolerance.
+func (cm *ClockMonitor) Observe(number uint64, timestamp time.Time) {
+	err := CheckTimestamp(timestamp, time.Now(), cm.maxSkew, cm.maxAge)
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	log := cm.log.WithFields(logrus.Fields{
+		"blockNumber": number,
+		"timestamp":   timestamp.UTC().Format(time.RFC3339),
+	})
+
+	if err != nil && !cm.failing {
+		log.WithError(err).Error("ALERT: node is serving blocks with implausible timestamps")
+	} else if err == nil && cm.failing {
+		log.Info("Block timestamps are back within tolerance")
+	}
+	cm.failing = err != nil
+}
+
+// CheckTimestamp verifies that a block timestamp is neither further in the future than
+// maxSkew nor further in the past than maxAge. Zero durations disable a check.
+func CheckTimestamp(timestamp time.Time, now time.Time, maxSkew time.Duration, maxAge time.Duration) error {
+	offset := timestamp.Sub(now)
+
+	if maxSkew > 0 && offset > maxSkew {
+		return fmt.Errorf("block is %s in the future, exceeding the tolerated clock skew of %s", offset, maxSkew)
+	}
+
+	if maxAge > 0 && -offset > maxAge {
+		return fmt.Errorf("block is %s old, exceeding the maximum age of %s", -offset, maxAge)
+	}
+
+	return nil
+}
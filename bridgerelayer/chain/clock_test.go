@@ -0,0 +1,30 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Now()
+
+	assert.NoError(t, chain.CheckTimestamp(now, now, time.Minute, time.Minute))
+	assert.NoError(t, chain.CheckTimestamp(now.Add(30*time.Second), now, time.Minute, time.Minute))
+	assert.NoError(t, chain.CheckTimestamp(now.Add(-30*time.Second), now, time.Minute, time.Minute))
+
+	// future-dated
+	assert.Error(t, chain.CheckTimestamp(now.Add(2*time.Minute), now, time.Minute, time.Minute))
+	// stale
+	assert.Error(t, chain.CheckTimestamp(now.Add(-2*time.Minute), now, time.Minute, time.Minute))
+
+	// disabled checks
+	assert.NoError(t, chain.CheckTimestamp(now.Add(time.Hour), now, 0, time.Minute))
+	assert.NoError(t, chain.CheckTimestamp(now.Add(-time.Hour), now, time.Minute, 0))
+}
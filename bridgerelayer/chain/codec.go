@@ -0,0 +1,156 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+var (
+	payloadsMutex sync.RWMutex
+	// payload types by kind, and kinds by payload type
+	payloadTypes = map[string]reflect.Type{}
+	payloadKinds = map[reflect.Type]string{}
+)
+
+func init() {
+	RegisterPayload("bytes", []byte{})
+	RegisterPayload("call", Call{})
+}
+
+// RegisterPayload makes a payload type known to EncodeMessage and DecodeMessage, under a
+// kind which is recorded with each encoded message. Payloads must be SCALE-encodable.
+func RegisterPayload(kind string, payload interface{}) {
+	payloadsMutex.Lock()
+	defer payloadsMutex.Unlock()
+
+	payloadType := reflect.TypeOf(payload)
+	payloadTypes[kind] = payloadType
+	payloadKinds[payloadType] = kind
+}
+
+// encodedMessage is a message with its payload in its canonical encoding
+type encodedMessage struct {
+	ID          string    `json:"id"`
+	Sequence    uint64    `json:"sequence"`
+	AppID       [20]byte  `json:"appId"`
+	Kind        string    `json:"kind"`
+	Payload     []byte    `json:"payload"`
+	ObservedAt  time.Time `json:"observedAt"`
+	SourceBlock uint64    `json:"sourceBlock,omitempty"`
+	Replayed    bool      `json:"replayed,omitempty"`
+	Network     string    `json:"network,omitempty"`
+	Override    string    `json:"override,omitempty"`
+	Priority    bool      `json:"priority,omitempty"`
+	// empty for messages encoded before their provenance was recorded
+	Source        string `json:"source,omitempty"`
+	SourceChainID string `json:"sourceChainId,omitempty"`
+	SourceHash    string `json:"sourceHash,omitempty"`
+	EventIndex    uint64 `json:"eventIndex,omitempty"`
+	// W3C traceparent of the last span of the message, empty if it isn't traced
+	Trace string `json:"trace,omitempty"`
+}
+
+// EncodeMessage encodes a message, so that it can be persisted and decoded again by
+// DecodeMessage
+func EncodeMessage(msg *Message) ([]byte, error) {
+	payloadsMutex.RLock()
+	kind, ok := payloadKinds[reflect.TypeOf(msg.Payload)]
+	payloadsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unregistered payload type %T", msg.Payload)
+	}
+
+	payload, err := CanonicalPayload(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(encodedMessage{
+		ID:            msg.ID,
+		Sequence:      msg.Sequence,
+		AppID:         msg.AppID,
+		Kind:          kind,
+		Payload:       payload,
+		ObservedAt:    msg.ObservedAt,
+		SourceBlock:   msg.SourceBlock,
+		Replayed:      msg.Replayed,
+		Network:       msg.Network,
+		Override:      msg.Override,
+		Priority:      msg.Priority,
+		Source:        msg.Source,
+		SourceChainID: msg.SourceChainID,
+		SourceHash:    msg.SourceHash,
+		EventIndex:    msg.EventIndex,
+		Trace:         msg.Trace.String(),
+	})
+}
+
+// DecodeMessage decodes a message encoded by EncodeMessage
+func DecodeMessage(data []byte) (*Message, error) {
+	var encoded encodedMessage
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadsMutex.RLock()
+	payloadType, ok := payloadTypes[encoded.Kind]
+	payloadsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown payload kind %s", encoded.Kind)
+	}
+
+	payload, err := decodePayload(payloadType, encoded.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s payload: %w", encoded.Kind, err)
+	}
+
+	// messages whose trace can't be parsed are delivered without joining it
+	trace, _ := tracing.ParseSpanContext(encoded.Trace)
+
+	return &Message{
+		ID:            encoded.ID,
+		Sequence:      encoded.Sequence,
+		AppID:         encoded.AppID,
+		Payload:       payload,
+		ObservedAt:    encoded.ObservedAt,
+		SourceBlock:   encoded.SourceBlock,
+		Replayed:      encoded.Replayed,
+		Network:       encoded.Network,
+		Override:      encoded.Override,
+		Priority:      encoded.Priority,
+		Source:        encoded.Source,
+		SourceChainID: encoded.SourceChainID,
+		SourceHash:    encoded.SourceHash,
+		EventIndex:    encoded.EventIndex,
+		Trace:         trace,
+	}, nil
+}
+
+// DecodePayloadAs decodes a payload in its canonical encoding into the type of another
+// payload, such as a payload re-encoded by a migration
+func DecodePayloadAs(like interface{}, data []byte) (interface{}, error) {
+	return decodePayload(reflect.TypeOf(like), data)
+}
+
+func decodePayload(payloadType reflect.Type, data []byte) (interface{}, error) {
+	if payloadType == reflect.TypeOf([]byte{}) {
+		return data, nil
+	}
+	value := reflect.New(payloadType)
+	err := types.DecodeFromBytes(data, value.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return value.Elem().Interface(), nil
+}
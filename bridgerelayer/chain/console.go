@@ -0,0 +1,42 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ConsoleCommand is a command of the developer console, run through the connections of a chain
+type ConsoleCommand struct {
+	Name  string
+	Usage string
+	Help  string
+	// Args is the minimum number of arguments
+	Args int
+	// Run returns a result which the console prints as JSON
+	Run func(ctx context.Context, args []string) (interface{}, error)
+}
+
+// ParseHexArg decodes a 0x-prefixed hex argument of a console command
+func ParseHexArg(name string, value string) ([]byte, error) {
+	data, err := hexutil.Decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return data, nil
+}
+
+// ParseBlockArg parses a block number argument of a console command
+func ParseBlockArg(value string) (*big.Int, error) {
+	number, ok := new(big.Int).SetString(strings.TrimPrefix(value, "#"), 10)
+	if !ok || number.Sign() < 0 {
+		return nil, fmt.Errorf("invalid block number %q", value)
+	}
+	return number, nil
+}
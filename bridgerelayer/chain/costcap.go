@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// ErrCostExceeded is returned for messages whose estimated cost exceeds the cap of the writer
+var ErrCostExceeded = errors.New("estimated cost of message exceeds cap")
+
+// CostCap refuses to submit messages whose estimated execution units, gas or weight, or fee
+// exceed a cap, so that a pathological payload can't drain the relayer account. The fee can
+// also be capped in the reporting currency of the pricer, so that the caps of all chains are
+// set in one currency and follow the prices of their native assets. Refused messages fail
+// permanently, and are moved to the dead-letter queue if one is configured.
+type CostCap struct {
+	chain string
+	// name of the execution units in errors, such as gas or weight
+	unit     string
+	maxUnits uint64
+	maxFee   *big.Int
+	// fee in the reporting currency of the pricer, nil if it isn't capped
+	maxCost *big.Rat
+	pricer  Pricer
+}
+
+// NewCostCap creates a cap of the given execution units and fee, in base units of the native
+// asset, and cost, in the reporting currency of the pricer. Zero units and an empty fee or
+// cost disable either cap. Costs can only be capped with a pricer.
+func NewCostCap(chain string, unit string, maxUnits uint64, maxFee string, maxCost string, pricer Pricer) (*CostCap, error) {
+	var fee *big.Int
+	if maxFee != "" {
+		var ok bool
+		fee, ok = new(big.Int).SetString(maxFee, 10)
+		if !ok || fee.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid max fee of messages: %s", maxFee)
+		}
+	}
+
+	var cost *big.Rat
+	if maxCost != "" {
+		if pricer == nil {
+			return nil, fmt.Errorf("max cost of messages requires a reporting currency")
+		}
+		var ok bool
+		cost, ok = new(big.Rat).SetString(maxCost)
+		if !ok || cost.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid max cost of messages: %s", maxCost)
+		}
+	}
+
+	return &CostCap{
+		chain:    chain,
+		unit:     unit,
+		maxUnits: maxUnits,
+		maxFee:   fee,
+		maxCost:  cost,
+		pricer:   pricer,
+	}, nil
+}
+
+// Enabled returns whether messages are capped by their units or fee
+func (cc *CostCap) Enabled() bool {
+	return cc != nil && (cc.maxUnits > 0 || cc.maxFee != nil || cc.maxCost != nil)
+}
+
+// Check returns a permanent error wrapping ErrCostExceeded if the estimated units, fee or
+// cost of a message of an app exceed their cap. A nil fee is not checked. Fees which can't
+// be converted into the reporting currency fail the check with a transient error.
+func (cc *CostCap) Check(ctx context.Context, app string, units uint64, fee *big.Int) error {
+	if !cc.Enabled() {
+		return nil
+	}
+
+	var cost *big.Rat
+	if cc.maxCost != nil && fee != nil {
+		var err error
+		cost, err = cc.pricer.Convert(ctx, cc.chain, fee)
+		if err != nil {
+			return fmt.Errorf("convert fee of message: %w", err)
+		}
+	}
+
+	var err error
+	switch {
+	case cc.maxUnits > 0 && units > cc.maxUnits:
+		err = fmt.Errorf("%w: %d %s above %d", ErrCostExceeded, units, cc.unit, cc.maxUnits)
+	case cc.maxFee != nil && fee != nil && fee.Cmp(cc.maxFee) > 0:
+		err = fmt.Errorf("%w: fee of %s above %s", ErrCostExceeded, fee, cc.maxFee)
+	case cost != nil && cost.Cmp(cc.maxCost) > 0:
+		err = fmt.Errorf("%w: cost of %s %s above %s", ErrCostExceeded, cost.FloatString(costDecimals), cc.pricer.Currency(), cc.maxCost.FloatString(costDecimals))
+	default:
+		return nil
+	}
+
+	metrics.CostCapExceeded.WithLabelValues(cc.chain, app).Inc()
+	return Permanent(err)
+}
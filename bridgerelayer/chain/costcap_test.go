@@ -0,0 +1,91 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestCostCap(t *testing.T) {
+	costCap, err := chain.NewCostCap("substrate", "weight", 1000, "500", "", nil)
+	require.NoError(t, err)
+	assert.True(t, costCap.Enabled())
+
+	assert.NoError(t, costCap.Check(context.Background(), "eth", 1000, big.NewInt(500)))
+	// fees which couldn't be estimated are not checked
+	assert.NoError(t, costCap.Check(context.Background(), "eth", 10, nil))
+
+	err = costCap.Check(context.Background(), "eth", 1001, big.NewInt(1))
+	assert.True(t, errors.Is(err, chain.ErrCostExceeded))
+	assert.Equal(t, chain.ErrorFatal, chain.Classify(err))
+
+	err = costCap.Check(context.Background(), "eth", 1, big.NewInt(501))
+	assert.True(t, errors.Is(err, chain.ErrCostExceeded))
+}
+
+func TestCostCap_Disabled(t *testing.T) {
+	costCap, err := chain.NewCostCap("ethereum", "gas", 0, "", "", nil)
+	require.NoError(t, err)
+	assert.False(t, costCap.Enabled())
+	assert.NoError(t, costCap.Check(context.Background(), "eth", 1<<40, big.NewInt(1<<40)))
+
+	var none *chain.CostCap
+	assert.False(t, none.Enabled())
+
+	for _, fee := range []string{"0", "-5", "1e18"} {
+		_, err = chain.NewCostCap("ethereum", "gas", 0, fee, "", nil)
+		assert.Error(t, err, fee)
+	}
+}
+
+// fixedPricer values each base unit of the native asset of every chain at a price
+type fixedPricer struct {
+	price *big.Rat
+	err   error
+}
+
+func (fp *fixedPricer) Currency() string {
+	return "USD"
+}
+
+func (fp *fixedPricer) Convert(ctx context.Context, chain string, amount *big.Int) (*big.Rat, error) {
+	if fp.err != nil {
+		return nil, fp.err
+	}
+	return new(big.Rat).Mul(new(big.Rat).SetInt(amount), fp.price), nil
+}
+
+func TestCostCap_Cost(t *testing.T) {
+	pricer := &fixedPricer{price: big.NewRat(1, 100)}
+	costCap, err := chain.NewCostCap("ethereum", "gas", 0, "", "2.5", pricer)
+	require.NoError(t, err)
+	assert.True(t, costCap.Enabled())
+
+	// fees are capped at their value in the reporting currency
+	assert.NoError(t, costCap.Check(context.Background(), "eth", 1, big.NewInt(250)))
+	err = costCap.Check(context.Background(), "eth", 1, big.NewInt(251))
+	assert.True(t, errors.Is(err, chain.ErrCostExceeded))
+	assert.Contains(t, err.Error(), "cost of 2.510000 USD above 2.500000")
+
+	// fees which can't be valued are retried
+	pricer.err = errors.New("no price of ETH in USD")
+	err = costCap.Check(context.Background(), "eth", 1, big.NewInt(1))
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, chain.ErrCostExceeded))
+	assert.NotEqual(t, chain.ErrorFatal, chain.Classify(err))
+
+	// costs can't be capped without a reporting currency
+	_, err = chain.NewCostCap("ethereum", "gas", 0, "", "2.5", nil)
+	assert.Error(t, err)
+	_, err = chain.NewCostCap("ethereum", "gas", 0, "", "-1", pricer)
+	assert.Error(t, err)
+}
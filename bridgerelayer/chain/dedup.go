@@ -0,0 +1,69 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"sync"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// dedupWindow is the number of recent events remembered by a listener. It covers
+// the overlap between re-polled, repaired and resubscribed ranges of blocks.
+const dedupWindow = 8192
+
+// EventKey identifies an event independently of the endpoint it was observed from
+type EventKey struct {
+	BlockHash [32]byte
+	Index     uint64
+}
+
+// Deduplicator remembers the most recently observed events, so that an event seen
+// again from another endpoint, a repair or a resubscription is only enqueued once.
+// The oldest events are forgotten once the window is full.
+type Deduplicator struct {
+	chain  string
+	mutex  sync.Mutex
+	seen   map[EventKey]bool
+	ring   []EventKey
+	next   int
+	filled bool
+}
+
+func NewDeduplicator(chain string) *Deduplicator {
+	return newDeduplicator(chain, dedupWindow)
+}
+
+func newDeduplicator(chain string, window int) *Deduplicator {
+	return &Deduplicator{
+		chain: chain,
+		seen:  make(map[EventKey]bool, window),
+		ring:  make([]EventKey, window),
+	}
+}
+
+// Observe records an event, returning false if it was already observed
+func (d *Deduplicator) Observe(key EventKey) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.seen[key] {
+		metrics.DuplicateEvents.WithLabelValues(d.chain).Inc()
+		return false
+	}
+
+	if d.filled {
+		delete(d.seen, d.ring[d.next])
+	}
+	d.ring[d.next] = key
+	d.seen[key] = true
+
+	d.next++
+	if d.next == len(d.ring) {
+		d.next = 0
+		d.filled = true
+	}
+
+	return true
+}
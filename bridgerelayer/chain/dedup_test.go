@@ -0,0 +1,28 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicator_Observe(t *testing.T) {
+	d := newDeduplicator("test", 2)
+
+	a := EventKey{BlockHash: [32]byte{1}, Index: 0}
+	b := EventKey{BlockHash: [32]byte{1}, Index: 1}
+	c := EventKey{BlockHash: [32]byte{2}, Index: 0}
+
+	assert.True(t, d.Observe(a))
+	assert.True(t, d.Observe(b))
+	assert.False(t, d.Observe(a))
+	assert.False(t, d.Observe(b))
+
+	// the oldest event is forgotten once the window is full
+	assert.True(t, d.Observe(c))
+	assert.False(t, d.Observe(b))
+	assert.True(t, d.Observe(a))
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// DiagnosticsConfig captures the state of a writer whose queues back up
+type DiagnosticsConfig struct {
+	// Messages queued in the writer, across all apps, from which its state is captured and
+	// an alert is raised. Zero disables diagnostics.
+	QueueDepth int `mapstructure:"queue-depth"`
+	// Minimum seconds between two captures while the queues stay backed up. Defaults to 300.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+const (
+	defaultDiagnosticsInterval = 300 * time.Second
+	// diagnosticsTimeout bounds the calls made to capture the state of a writer
+	diagnosticsTimeout = 10 * time.Second
+)
+
+// WriterDiagnostics is the state of a writer captured while its queues were backed up, from
+// which the cause of the backpressure can be found after it cleared
+type WriterDiagnostics struct {
+	// ID assigned by the diagnostic log, empty until it was recorded
+	ID         string    `json:"id"`
+	Chain      string    `json:"chain"`
+	CapturedAt time.Time `json:"capturedAt"`
+	// Messages queued in the writer when the capture was triggered
+	Queued int          `json:"queued"`
+	Queues []QueueStats `json:"queues"`
+	// When a submission last completed, nil if none did
+	LastSubmission *time.Time `json:"lastSubmission,omitempty"`
+	// Nonces of the accounts from which the writer submits
+	Nonces []NonceState `json:"nonces"`
+	// Submissions awaiting their inclusion or confirmation
+	Pending []PendingSubmission `json:"pending"`
+	RPC     []EndpointStats     `json:"rpc"`
+	// Fees offered by new submissions, by name, in base units of the native asset
+	Fees map[string]string `json:"fees,omitempty"`
+	// Parts of the state which could not be captured
+	Problems []string `json:"problems,omitempty"`
+}
+
+// NonceState is the nonce of an account of a writer, as tracked locally and on chain
+type NonceState struct {
+	Account string `json:"account"`
+	// Next nonce assigned locally, zero if it is not tracked
+	Next uint64 `json:"next"`
+	// Pending nonce reported by the node
+	OnChain uint64 `json:"onChain"`
+	// Nonces being used by submissions which are being sent
+	Reserved int `json:"reserved,omitempty"`
+	// Nonces of failed submissions which are reused next
+	Released []uint64 `json:"released,omitempty"`
+}
+
+// PendingSubmission is a submission of a writer awaiting its inclusion or confirmation
+type PendingSubmission struct {
+	Hash         string    `json:"hash"`
+	Nonce        uint64    `json:"nonce"`
+	GasPrice     string    `json:"gasPrice,omitempty"`
+	Rebroadcasts int       `json:"rebroadcasts,omitempty"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+// DiagnosticLog records the state of writers captured while their queues were backed up
+type DiagnosticLog interface {
+	// RecordDiagnostics assigns an ID to the diagnostics and records them
+	RecordDiagnostics(diagnostics *WriterDiagnostics) error
+}
+
+// Inspect captures the chain-specific state of a writer into its diagnostics, recording the
+// parts which it could not capture as problems
+type Inspect func(ctx context.Context, diagnostics *WriterDiagnostics)
+
+// BackpressureMonitor detects writers which consume messages more slowly than they arrive.
+// Once the messages queued in a writer reach the configured depth, its state is captured,
+// recorded in the diagnostic log and an alert is raised, so that the cause of the
+// backpressure is known from the moment it happened. Captures are made at most once per
+// interval while the queues stay backed up, and run in the background so that the
+// dispatch of messages is not held up by the calls they make.
+type BackpressureMonitor struct {
+	chain    string
+	depth    int
+	interval time.Duration
+	inspect  Inspect
+	// nil if the diagnostics are only logged
+	diagnostics DiagnosticLog
+	mutex       sync.Mutex
+	capturing   bool
+	capturedAt  time.Time
+	log         *logrus.Entry
+}
+
+func NewBackpressureMonitor(chain string, config *DiagnosticsConfig, inspect Inspect, log *logrus.Entry) *BackpressureMonitor {
+	interval := time.Duration(config.Interval) * time.Second
+	if interval == 0 {
+		interval = defaultDiagnosticsInterval
+	}
+
+	return &BackpressureMonitor{
+		chain:    chain,
+		depth:    config.QueueDepth,
+		interval: interval,
+		inspect:  inspect,
+		log:      log,
+	}
+}
+
+// Enabled returns whether a queue depth is configured
+func (bm *BackpressureMonitor) Enabled() bool {
+	return bm.depth > 0
+}
+
+// Record sets the log in which the diagnostics are recorded. It must be set before running.
+func (bm *BackpressureMonitor) Record(diagnostics DiagnosticLog) {
+	bm.diagnostics = diagnostics
+}
+
+// Observe checks the number of messages queued in the writer, capturing its state if they
+// reached the depth
+func (bm *BackpressureMonitor) Observe(ctx context.Context, queued int) {
+	if queued < bm.depth {
+		return
+	}
+
+	now := time.Now()
+	bm.mutex.Lock()
+	if bm.capturing || (!bm.capturedAt.IsZero() && now.Sub(bm.capturedAt) < bm.interval) {
+		bm.mutex.Unlock()
+		return
+	}
+	bm.capturing = true
+	bm.capturedAt = now
+	bm.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			bm.mutex.Lock()
+			bm.capturing = false
+			bm.mutex.Unlock()
+		}()
+		bm.capture(ctx, queued, now)
+	}()
+}
+
+// capture captures, records and reports the state of the writer
+func (bm *BackpressureMonitor) capture(ctx context.Context, queued int, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+
+	diagnostics := &WriterDiagnostics{
+		Chain:      bm.chain,
+		CapturedAt: now.UTC(),
+		Queued:     queued,
+		Queues:     []QueueStats{},
+		Nonces:     []NonceState{},
+		Pending:    []PendingSubmission{},
+		RPC:        []EndpointStats{},
+	}
+	bm.inspect(ctx, diagnostics)
+	metrics.WriterDiagnostics.WithLabelValues(bm.chain).Inc()
+
+	log := bm.log.WithFields(logrus.Fields{
+		"queued":  queued,
+		"depth":   bm.depth,
+		"pending": len(diagnostics.Pending),
+	})
+	if len(diagnostics.Problems) > 0 {
+		log = log.WithField("problems", diagnostics.Problems)
+	}
+
+	if bm.diagnostics != nil {
+		err := bm.diagnostics.RecordDiagnostics(diagnostics)
+		if err != nil {
+			log.WithError(err).Error("Failed to record writer diagnostics")
+		}
+	}
+	if diagnostics.ID != "" {
+		log = log.WithField("diagnostics", diagnostics.ID)
+	}
+	log.Error("ALERT: writer is falling behind, captured its state")
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type diagnosticLog struct {
+	mutex    sync.Mutex
+	recorded []*chain.WriterDiagnostics
+}
+
+func (dl *diagnosticLog) RecordDiagnostics(diagnostics *chain.WriterDiagnostics) error {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	diagnostics.ID = fmt.Sprint(len(dl.recorded))
+	dl.recorded = append(dl.recorded, diagnostics)
+	return nil
+}
+
+func (dl *diagnosticLog) list() []*chain.WriterDiagnostics {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	return append([]*chain.WriterDiagnostics{}, dl.recorded...)
+}
+
+func TestBackpressureMonitor(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	// the writer is stuck on its first submission
+	release := make(chan struct{})
+	submit := func(ctx context.Context, _ *chain.Message, _ bool) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	}
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, log)
+	defer close(release)
+
+	inspect := func(_ context.Context, diagnostics *chain.WriterDiagnostics) {
+		diagnostics.Queues = dispatcher.Queues()
+		diagnostics.Nonces = append(diagnostics.Nonces, chain.NonceState{Account: "0x1", Next: 8, OnChain: 5})
+		diagnostics.Problems = append(diagnostics.Problems, "gas price: timeout")
+	}
+	monitor := chain.NewBackpressureMonitor("Ethereum", &chain.DiagnosticsConfig{QueueDepth: 3}, inspect, log)
+	recorded := &diagnosticLog{}
+	monitor.Record(recorded)
+	dispatcher.Monitor(monitor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := make(chan chain.Message)
+	go func() {
+		_ = dispatcher.Run(ctx, messages)
+	}()
+
+	messages <- chain.Message{Payload: 0}
+	assert.Eventually(t, func() bool {
+		return dispatcher.Queues()[0].InFlight == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// nothing is captured until the queue reaches the depth
+	for i := 1; i < 3; i++ {
+		messages <- chain.Message{Payload: i}
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, recorded.list())
+
+	messages <- chain.Message{Payload: 3}
+	assert.Eventually(t, func() bool {
+		return len(recorded.list()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	diagnostics := recorded.list()[0]
+	assert.Equal(t, "Ethereum", diagnostics.Chain)
+	assert.Equal(t, 3, diagnostics.Queued)
+	require.Len(t, diagnostics.Queues, 1)
+	assert.Equal(t, 1, diagnostics.Queues[0].InFlight)
+	assert.Equal(t, []chain.NonceState{{Account: "0x1", Next: 8, OnChain: 5}}, diagnostics.Nonces)
+	assert.Equal(t, []string{"gas price: timeout"}, diagnostics.Problems)
+
+	// the queue staying backed up is not captured again within the interval
+	messages <- chain.Message{Payload: 4}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, recorded.list(), 1)
+}
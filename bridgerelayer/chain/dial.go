@@ -0,0 +1,259 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// DialConfig sets how the connections to the endpoints of a chain are dialed, for relayers
+// in dual-stack or egress-restricted networks
+type DialConfig struct {
+	// Address family dialed first when an endpoint resolves to both, ipv4 or ipv6, or the
+	// only family dialed if suffixed with -only, for example ipv6-only. Addresses are dialed
+	// in the order of the resolver if empty.
+	Prefer string `mapstructure:"prefer"`
+	// Name of the network interface, or local IP address, from which connections are made.
+	// Connections are made from any address if empty.
+	Interface string `mapstructure:"interface"`
+	// DNS server, as ip:port, resolving the endpoints instead of the resolver of the system.
+	// The port defaults to 53.
+	Resolver string `mapstructure:"resolver"`
+}
+
+// Dialer dials the connections to endpoints according to a DialConfig. A nil Dialer dials as
+// the RPC client does by default.
+type Dialer struct {
+	// ipv4 or ipv6, empty if neither is preferred
+	prefer string
+	only   bool
+	// interface whose addresses connections are made from, nil if bound to local or none
+	iface    *net.Interface
+	local    net.IP
+	resolver *net.Resolver
+}
+
+// handshakeTimeout bounds the TLS and websocket handshakes of a connection
+const handshakeTimeout = 30 * time.Second
+
+// NewDialer creates a dialer, or returns nil if the configuration is empty
+func NewDialer(config *DialConfig) (*Dialer, error) {
+	if *config == (DialConfig{}) {
+		return nil, nil
+	}
+
+	di := &Dialer{resolver: net.DefaultResolver}
+
+	switch config.Prefer {
+	case "":
+	case "ipv4", "ipv6":
+		di.prefer = config.Prefer
+	case "ipv4-only", "ipv6-only":
+		di.prefer = strings.TrimSuffix(config.Prefer, "-only")
+		di.only = true
+	default:
+		return nil, fmt.Errorf("unknown address family %q, expected ipv4, ipv6, ipv4-only or ipv6-only", config.Prefer)
+	}
+
+	if config.Interface != "" {
+		di.local = net.ParseIP(config.Interface)
+		if di.local == nil {
+			iface, err := net.InterfaceByName(config.Interface)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s: %w", config.Interface, err)
+			}
+			di.iface = iface
+		}
+	}
+
+	if config.Resolver != "" {
+		server := config.Resolver
+		if net.ParseIP(server) != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		host, _, err := net.SplitHostPort(server)
+		if err != nil || net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("resolver %s is not an IP address with an optional port", config.Resolver)
+		}
+		di.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				return di.dialAddress(ctx, network, net.ParseIP(host), server)
+			},
+		}
+	}
+
+	return di, nil
+}
+
+// DialContext dials an address, as host:port, trying each address of the host in order of
+// preference until a connection is made
+func (di *Dialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := di.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	ips = di.order(ips)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s has no %s address", host, di.prefer)
+	}
+
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := di.dialAddress(ctx, network, ip, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// order sorts addresses by preference, stably, dropping those of the other family if only
+// the preferred family is dialed
+func (di *Dialer) order(ips []net.IP) []net.IP {
+	if di.prefer == "" {
+		return ips
+	}
+
+	var preferred, others []net.IP
+	for _, ip := range ips {
+		if family(ip) == di.prefer {
+			preferred = append(preferred, ip)
+		} else {
+			others = append(others, ip)
+		}
+	}
+	if di.only {
+		return preferred
+	}
+	return append(preferred, others...)
+}
+
+// dialAddress dials a resolved address, from the local address which can reach it
+func (di *Dialer) dialAddress(ctx context.Context, network string, ip net.IP, address string) (net.Conn, error) {
+	local, err := di.localIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	if local != nil {
+		if strings.HasPrefix(network, "udp") {
+			dialer.LocalAddr = &net.UDPAddr{IP: local}
+		} else {
+			dialer.LocalAddr = &net.TCPAddr{IP: local}
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// localIP returns the local address from which an address is dialed, nil if any
+func (di *Dialer) localIP(remote net.IP) (net.IP, error) {
+	if di.local != nil {
+		if family(di.local) != family(remote) {
+			return nil, fmt.Errorf("local address %s can't reach %s address %s", di.local, family(remote), remote)
+		}
+		return di.local, nil
+	}
+	if di.iface == nil {
+		return nil, nil
+	}
+
+	addrs, err := di.iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", di.iface.Name, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || family(ipNet.IP) != family(remote) {
+			continue
+		}
+		// link-local addresses only reach their own link
+		if ipNet.IP.IsLinkLocalUnicast() && !remote.IsLinkLocalUnicast() {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	return nil, fmt.Errorf("interface %s has no %s address", di.iface.Name, family(remote))
+}
+
+func family(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// HTTPClient returns an HTTP client whose connections are made through the dialer
+func (di *Dialer) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         di.DialContext,
+			TLSHandshakeTimeout: handshakeTimeout,
+		},
+	}
+}
+
+// WebsocketDialer returns a websocket dialer whose connections are made through the dialer
+func (di *Dialer) WebsocketDialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		NetDialContext:   di.DialContext,
+		HandshakeTimeout: handshakeTimeout,
+		ReadBufferSize:   1024,
+		WriteBufferSize:  1024,
+	}
+}
+
+// DialRPC connects an RPC client to an endpoint over HTTP or websocket through a dialer,
+// or as the RPC client does by default if the dialer is nil. IPC endpoints are local and
+// always dialed by default.
+func DialRPC(ctx context.Context, endpoint string, dialer *Dialer) (*rpc.Client, error) {
+	if dialer == nil {
+		return rpc.DialContext(ctx, endpoint)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return rpc.DialHTTPWithClient(endpoint, dialer.HTTPClient())
+	case "ws", "wss":
+		return rpc.DialWebsocketWithDialer(ctx, endpoint, "", *dialer.WebsocketDialer())
+	default:
+		return rpc.DialContext(ctx, endpoint)
+	}
+}
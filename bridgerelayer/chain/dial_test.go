@@ -0,0 +1,225 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestNewDialer(t *testing.T) {
+	dialer, err := chain.NewDialer(&chain.DialConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, dialer)
+
+	for _, config := range []chain.DialConfig{
+		{Prefer: "ipv5"},
+		{Interface: "no-such-interface0"},
+		{Resolver: "dns.example.com:53"},
+	} {
+		_, err := chain.NewDialer(&config)
+		assert.Error(t, err, "%+v", config)
+	}
+
+	for _, config := range []chain.DialConfig{
+		{Prefer: "ipv6-only"},
+		{Interface: "127.0.0.1"},
+		{Resolver: "10.0.0.1"},
+		{Resolver: "[::1]:5353"},
+	} {
+		dialer, err := chain.NewDialer(&config)
+		assert.NoError(t, err, "%+v", config)
+		assert.NotNil(t, dialer)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dialer, _ := chain.NewDialer(&chain.DialConfig{Prefer: "ipv6-only"})
+	_, err = dialer.DialContext(ctx, "tcp", "127.0.0.1:"+port)
+	assert.EqualError(t, err, "127.0.0.1 has no ipv6 address")
+
+	dialer, _ = chain.NewDialer(&chain.DialConfig{Prefer: "ipv6", Interface: "127.0.0.1"})
+	conn, err := dialer.DialContext(ctx, "tcp", "127.0.0.1:"+port)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "127.0.0.1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+		conn.Close()
+	}
+
+	// the local address must be of the family of the endpoint
+	dialer, _ = chain.NewDialer(&chain.DialConfig{Interface: "::1"})
+	_, err = dialer.DialContext(ctx, "tcp", "127.0.0.1:"+port)
+	assert.EqualError(t, err, "local address ::1 can't reach ipv4 address 127.0.0.1")
+
+	// connections are made from the address of the interface of the family of the endpoint
+	loopback := loopbackInterface(t)
+	if loopback != "" {
+		dialer, _ = chain.NewDialer(&chain.DialConfig{Interface: loopback})
+		conn, err = dialer.DialContext(ctx, "tcp", "127.0.0.1:"+port)
+		if assert.NoError(t, err) {
+			assert.True(t, conn.LocalAddr().(*net.TCPAddr).IP.IsLoopback())
+			conn.Close()
+		}
+	}
+}
+
+func TestDialer_Resolver(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+	go serveDNS(server, net.IPv4(127, 0, 0, 1))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dialer, err := chain.NewDialer(&chain.DialConfig{Resolver: server.LocalAddr().String()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", "relay-endpoint.test:"+port)
+	if assert.NoError(t, err) {
+		assert.Equal(t, listener.Addr().String(), conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
+
+type echoService struct{}
+
+func (echoService) Echo(value string) string {
+	return value
+}
+
+func TestDialRPC(t *testing.T) {
+	server := rpc.NewServer()
+	err := server.RegisterName("test", echoService{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Stop()
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	wsServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer wsServer.Close()
+
+	dialer, _ := chain.NewDialer(&chain.DialConfig{Prefer: "ipv4-only"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, endpoint := range []string{
+		strings.Replace(httpServer.URL, "127.0.0.1", "localhost", 1),
+		strings.Replace(wsServer.URL, "http://127.0.0.1", "ws://localhost", 1),
+	} {
+		client, err := chain.DialRPC(ctx, endpoint, dialer)
+		if !assert.NoError(t, err, endpoint) {
+			continue
+		}
+
+		var result string
+		err = client.CallContext(ctx, &result, "test_echo", "relayed")
+		assert.NoError(t, err, endpoint)
+		assert.Equal(t, "relayed", result)
+		client.Close()
+	}
+}
+
+// loopbackInterface returns the name of the loopback interface, empty if there is none
+func loopbackInterface(t *testing.T) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Log(err)
+		return ""
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+	return ""
+}
+
+// serveDNS answers the A queries of any name with ip, and the other queries with no records
+func serveDNS(conn net.PacketConn, ip net.IP) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := buf[:n]
+		if len(query) < 12 {
+			continue
+		}
+
+		// the question ends after the labels of its name, its type and class
+		end := 12
+		for end < len(query) && query[end] != 0 {
+			end += int(query[end]) + 1
+		}
+		end += 5
+		if end > len(query) {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(query[end-4:])
+
+		response := append([]byte{}, query[:end]...)
+		// a response to a recursive query, with one question and no authority or additional records
+		binary.BigEndian.PutUint16(response[2:], 0x8180)
+		binary.BigEndian.PutUint16(response[4:], 1)
+		binary.BigEndian.PutUint16(response[6:], 0)
+		binary.BigEndian.PutUint16(response[8:], 0)
+		binary.BigEndian.PutUint16(response[10:], 0)
+		if qtype == 1 {
+			binary.BigEndian.PutUint16(response[6:], 1)
+			// a record of the name of the question, of type A and class IN, for 60 seconds
+			response = append(response, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			response = append(response, ip.To4()...)
+		}
+		conn.WriteTo(response, addr)
+	}
+}
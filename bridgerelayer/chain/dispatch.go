@@ -0,0 +1,603 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// ThrottleConfig bounds the submission of messages for an app. Zero values disable a limit.
+type ThrottleConfig struct {
+	// Maximum number of submissions per minute
+	Rate float64 `mapstructure:"rate"`
+	// Maximum number of messages of the app submitted at once. Defaults to 1, so that
+	// messages are submitted in order.
+	Concurrency int `mapstructure:"concurrency"`
+	// Number of messages queued for the app. While full, the writer waits before
+	// accepting further messages for any app.
+	QueueSize int `mapstructure:"queue-size"`
+}
+
+const (
+	defaultThrottleQueueSize = 64
+	// defaultLane is shared by apps without a throttle
+	defaultLane = "default"
+)
+
+// Submit delivers a message to its target chain, handling any failure. Escalated
+// messages are submitted with additional effort to meet their latency budget.
+type Submit func(ctx context.Context, msg *Message, escalate bool)
+
+// ErrNotQueued is returned when boosting a message which isn't waiting in the queue of a
+// writer, as it is already being submitted, was delivered, or hasn't reached the writer yet
+var ErrNotQueued = errors.New("message is not queued for submission")
+
+// Boost reports a queued message boosted by an operator
+type Boost struct {
+	MessageID string `json:"messageId"`
+	Chain     string `json:"chain"`
+	App       string `json:"app"`
+	// Whether the lane of the app submits messages in order, so that the messages queued
+	// ahead of the boosted message are escalated with it
+	Ordered bool `json:"ordered"`
+	// Messages queued in the lane when it was boosted
+	Queued int `json:"queued"`
+}
+
+// QueueStats are the pending messages of an app
+type QueueStats struct {
+	App      string `json:"app"`
+	Queued   int    `json:"queued"`
+	InFlight int    `json:"inFlight"`
+}
+
+// Dispatcher feeds the messages read by a writer into a lane per app, so that the rate and
+// concurrency of submissions can be bounded for each app independently. Apps without a
+// throttle share a default lane, which submits one message at a time without a rate limit.
+//
+// The latency budget of a lane is reviewed as each message leaves its queue. Messages which
+// consumed enough of their budget skip the rate limit and are submitted escalated. Recorded
+// messages are also reviewed as they enter the queue and while they wait in it, so that a
+// message held back by a halt or a backlog is alerted on before its turn comes.
+//
+// Operators can also boost a queued message, for example to escalate a stuck high-profile
+// transfer, so that it skips the rate limit and is submitted escalated. A boost doesn't
+// reorder a queue: in lanes which submit in order, the messages queued ahead of a boosted
+// message skip the rate limit and are escalated too, so that they don't hold it back, while
+// in other lanes they only skip the rate limit.
+//
+// Messages routed through an override are fed into the lane of their override if it has
+// one, rather than the lane of their app. Messages with priority skip the rate limit and
+// are submitted escalated, like boosted messages.
+//
+// Submissions of all lanes can also be paced by a throughput tuner, which escalated
+// messages don't skip, as exceeding the capacity of the chain would delay them further.
+type Dispatcher struct {
+	// unix time in nanoseconds at which a submission last completed, accessed atomically,
+	// first for 64-bit alignment
+	lastSubmission int64
+	chain          string
+	gate           *Gate
+	submit         Submit
+	lanes          map[[20]byte]*lane
+	// lanes of the routing overrides which have one, by override name
+	overrides map[string]*lane
+	fallback  *lane
+	// nil if submissions are not tuned
+	tuner *ThroughputTuner
+	// nil if backpressure is not monitored
+	monitor *BackpressureMonitor
+	mutex   sync.Mutex
+	// recorded messages waiting in a queue, by ID, and the IDs of those boosted
+	queued  map[string]*queuedMessage
+	boosted map[string]bool
+	log     *logrus.Entry
+}
+
+type lane struct {
+	// accessed atomically, first for 64-bit alignment
+	inFlight    int64
+	name        string
+	queue       chan Message
+	concurrency int
+	limiter     *rate.Limiter
+	budget      *Budget
+	// number of boosted messages in the queue, and a channel closed when a message is
+	// boosted, guarded by the mutex of the dispatcher
+	boosts int
+	wake   chan struct{}
+}
+
+// queuedMessage is a recorded message waiting in the queue of a lane
+type queuedMessage struct {
+	lane       *lane
+	id         string
+	sequence   uint64
+	observedAt time.Time
+	// budget alert last raised for the message, which isn't raised again
+	alert budgetAlert
+}
+
+// budgetAlert is the alert raised for a message which consumed its latency budget
+type budgetAlert int
+
+const (
+	budgetAlertNone budgetAlert = iota
+	budgetAlertAtRisk
+	budgetAlertExceeded
+)
+
+// budgetReviews is the number of reviews of the messages waiting in a queue over the
+// shortest latency budget
+const budgetReviews = 10
+
+func NewDispatcher(chain string, gate *Gate, submit Submit, log *logrus.Entry) *Dispatcher {
+	return &Dispatcher{
+		chain:     chain,
+		gate:      gate,
+		submit:    submit,
+		lanes:     make(map[[20]byte]*lane),
+		overrides: make(map[string]*lane),
+		fallback:  newLane(defaultLane, &ThrottleConfig{}),
+		queued:    make(map[string]*queuedMessage),
+		boosted:   make(map[string]bool),
+		log:       log,
+	}
+}
+
+// AddLane throttles the submissions of an app and enforces their latency budget, either
+// of which may be nil. Lanes must be added before running.
+func (d *Dispatcher) AddLane(name string, appID [20]byte, throttle *ThrottleConfig, budget *BudgetConfig) {
+	if throttle == nil {
+		throttle = &ThrottleConfig{}
+	}
+
+	ln := newLane(name, throttle)
+	ln.budget = NewBudget(budget)
+	d.lanes[appID] = ln
+
+	fields := logrus.Fields{
+		"app":         name,
+		"rate":        throttle.Rate,
+		"concurrency": ln.concurrency,
+	}
+	if ln.budget != nil {
+		fields["budget"] = ln.budget.latency
+	}
+	d.log.WithFields(fields).Info("Configured submission lane for app")
+}
+
+// AddOverride gives the messages of a routing override a lane of their own, if the
+// override throttles them or enforces their latency budget. Lanes must be added before
+// running.
+func (d *Dispatcher) AddOverride(config *OverrideConfig) {
+	if config.Throttle == nil && config.Budget == nil {
+		return
+	}
+
+	throttle := config.Throttle
+	if throttle == nil {
+		throttle = &ThrottleConfig{}
+	}
+
+	ln := newLane(overrideLanePrefix+config.Name, throttle)
+	ln.budget = NewBudget(config.Budget)
+	d.overrides[config.Name] = ln
+
+	fields := logrus.Fields{
+		"override":    config.Name,
+		"rate":        throttle.Rate,
+		"concurrency": ln.concurrency,
+	}
+	if ln.budget != nil {
+		fields["budget"] = ln.budget.latency
+	}
+	d.log.WithFields(fields).Info("Configured submission lane for routing override")
+}
+
+// Tune paces the submissions of all lanes by a throughput tuner. It must be set before
+// running.
+func (d *Dispatcher) Tune(tuner *ThroughputTuner) {
+	if tuner.Enabled() {
+		d.tuner = tuner
+	}
+}
+
+// Monitor reports the messages queued in all lanes to a backpressure monitor. It must be set
+// before running.
+func (d *Dispatcher) Monitor(monitor *BackpressureMonitor) {
+	if monitor.Enabled() {
+		d.monitor = monitor
+	}
+}
+
+// Run dispatches messages to the lanes of their apps until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context, messages <-chan Message) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for _, ln := range d.all() {
+		ln := ln
+		for i := 0; i < ln.concurrency; i++ {
+			eg.Go(func() error {
+				return d.work(ctx, ln)
+			})
+		}
+	}
+
+	if interval := d.reviewInterval(); interval > 0 {
+		eg.Go(func() error {
+			return d.reviewQueued(ctx, interval)
+		})
+	}
+
+	eg.Go(func() error {
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case msg := <-messages:
+				ln := d.lane(&msg)
+				d.enqueue(ln, &msg)
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case ln.queue <- msg:
+					metrics.AppQueueDepth.WithLabelValues(d.chain, ln.name).Set(float64(len(ln.queue)))
+				}
+
+				if d.monitor != nil {
+					d.monitor.Observe(ctx, d.depth())
+				}
+			}
+		}
+	})
+
+	return eg.Wait()
+}
+
+func (d *Dispatcher) work(ctx context.Context, ln *lane) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-ln.queue:
+			metrics.AppQueueDepth.WithLabelValues(d.chain, ln.name).Set(float64(len(ln.queue)))
+
+			err := d.gate.Wait(ctx)
+			if err != nil {
+				return err
+			}
+
+			boosted, ahead, alert := d.dequeue(ln, &msg)
+			escalate := d.review(ln, &msg, alert) || boosted || msg.Priority
+
+			if ln.limiter != nil && !escalate && !ahead {
+				ahead, err = d.throttle(ctx, ln)
+				if err != nil {
+					return err
+				}
+			}
+			if ahead && ln.concurrency == 1 {
+				escalate = true
+			}
+
+			if d.tuner != nil {
+				err = d.tuner.Wait(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			metrics.AppInFlight.WithLabelValues(d.chain, ln.name).Set(float64(atomic.AddInt64(&ln.inFlight, 1)))
+			d.submit(ctx, &msg, escalate)
+			atomic.StoreInt64(&d.lastSubmission, time.Now().UnixNano())
+			metrics.AppInFlight.WithLabelValues(d.chain, ln.name).Set(float64(atomic.AddInt64(&ln.inFlight, -1)))
+
+			if d.tuner != nil {
+				d.tuner.Done()
+			}
+		}
+	}
+}
+
+// lane returns the lane of a message, that of its routing override if it has one, or else
+// that of its app
+func (d *Dispatcher) lane(msg *Message) *lane {
+	if ln, ok := d.overrides[msg.Override]; ok && msg.Override != "" {
+		return ln
+	}
+	if ln, ok := d.lanes[msg.AppID]; ok {
+		return ln
+	}
+	return d.fallback
+}
+
+// throttle waits for the rate limiter of a lane, returning early if a message queued in the
+// lane is boosted meanwhile, and whether it did
+func (d *Dispatcher) throttle(ctx context.Context, ln *lane) (bool, error) {
+	reservation := ln.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return false, nil
+	}
+
+	d.mutex.Lock()
+	boosted, wake := ln.boosts > 0, ln.wake
+	d.mutex.Unlock()
+	if boosted {
+		reservation.Cancel()
+		return true, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return false, nil
+	case <-wake:
+		reservation.Cancel()
+		return true, nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return false, ctx.Err()
+	}
+}
+
+// review checks how much of its lane's latency budget a message has consumed, raising an
+// alert if it is at risk, unless it was raised while the message was queued, and returns
+// whether its submission should be escalated
+func (d *Dispatcher) review(ln *lane, msg *Message, alert budgetAlert) bool {
+	if ln.budget == nil {
+		return false
+	}
+
+	consumed := ln.budget.Consumed(msg.ObservedAt, time.Now())
+	queued := &queuedMessage{lane: ln, id: msg.ID, sequence: msg.Sequence, observedAt: msg.ObservedAt, alert: alert}
+	d.alertBudget(queued, consumed)
+
+	escalate := ln.budget.Escalate(consumed)
+	if escalate {
+		metrics.BudgetEscalations.WithLabelValues(d.chain, ln.name).Inc()
+		d.budgetLog(queued).Info("Escalating submission of message")
+	}
+
+	return escalate
+}
+
+// alertBudget raises an alert for a message which consumed most or all of its lane's
+// latency budget, unless the alert was already raised for it
+func (d *Dispatcher) alertBudget(queued *queuedMessage, consumed float64) {
+	ln := queued.lane
+	alert := budgetAlertNone
+	if consumed >= 1 {
+		alert = budgetAlertExceeded
+	} else if ln.budget.Alert(consumed) {
+		alert = budgetAlertAtRisk
+	}
+	if alert <= queued.alert {
+		return
+	}
+	queued.alert = alert
+
+	if alert == budgetAlertExceeded {
+		metrics.BudgetExceeded.WithLabelValues(d.chain, ln.name).Inc()
+		d.budgetLog(queued).Error("ALERT: message exceeded its latency budget")
+	} else {
+		metrics.BudgetAlerts.WithLabelValues(d.chain, ln.name).Inc()
+		d.budgetLog(queued).Warn("ALERT: message consumed most of its latency budget")
+	}
+}
+
+func (d *Dispatcher) budgetLog(queued *queuedMessage) *logrus.Entry {
+	return d.log.WithFields(logrus.Fields{
+		"app":       queued.lane.name,
+		"messageID": queued.id,
+		"sequence":  queued.sequence,
+		"waited":    time.Since(queued.observedAt).Round(time.Second),
+		"budget":    queued.lane.budget.latency,
+	})
+}
+
+// reviewInterval returns the interval between reviews of the budgets of queued messages, a
+// fraction of the shortest budget, zero if no lane has a budget
+func (d *Dispatcher) reviewInterval() time.Duration {
+	var shortest time.Duration
+	for _, ln := range d.all() {
+		if ln.budget != nil && (shortest == 0 || ln.budget.latency < shortest) {
+			shortest = ln.budget.latency
+		}
+	}
+	return shortest / budgetReviews
+}
+
+// reviewQueued raises the budget alerts of the recorded messages waiting in the queues at
+// each interval
+func (d *Dispatcher) reviewQueued(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		d.mutex.Lock()
+		for _, queued := range d.queued {
+			if queued.lane.budget != nil {
+				d.alertBudget(queued, queued.lane.budget.Consumed(queued.observedAt, now))
+			}
+		}
+		d.mutex.Unlock()
+	}
+}
+
+// Boost escalates the submission of a queued message, along with the messages queued ahead
+// of it in ordered lanes. Returns ErrNotQueued if the message isn't queued.
+func (d *Dispatcher) Boost(id string) (*Boost, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	queued, ok := d.queued[id]
+	if !ok {
+		return nil, ErrNotQueued
+	}
+	ln := queued.lane
+
+	boost := &Boost{
+		MessageID: id,
+		Chain:     d.chain,
+		App:       ln.name,
+		Ordered:   ln.concurrency == 1,
+		Queued:    len(ln.queue),
+	}
+	if d.boosted[id] {
+		return boost, nil
+	}
+	d.boosted[id] = true
+	ln.boosts++
+	close(ln.wake)
+	ln.wake = make(chan struct{})
+
+	metrics.BoostedMessages.WithLabelValues(d.chain, ln.name).Inc()
+	d.log.WithFields(logrus.Fields{
+		"app":       ln.name,
+		"messageID": id,
+		"ordered":   boost.Ordered,
+		"queued":    boost.Queued,
+	}).Info("Boosted queued message")
+
+	return boost, nil
+}
+
+// enqueue records that a message is waiting in the queue of a lane, so that it can be boosted
+// and its budget reviewed while it waits, raising the alert of its budget at once if it is
+// already at risk
+func (d *Dispatcher) enqueue(ln *lane, msg *Message) {
+	if msg.ID == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	queued := &queuedMessage{lane: ln, id: msg.ID, sequence: msg.Sequence, observedAt: msg.ObservedAt}
+	if ln.budget != nil {
+		d.alertBudget(queued, ln.budget.Consumed(msg.ObservedAt, time.Now()))
+	}
+	d.queued[msg.ID] = queued
+}
+
+// dequeue records that a message left the queue of its lane, returning whether it was
+// boosted, and otherwise whether a boosted message is queued behind it, along with the
+// budget alert raised for it while it was queued
+func (d *Dispatcher) dequeue(ln *lane, msg *Message) (bool, bool, budgetAlert) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	alert := budgetAlertNone
+	if queued, ok := d.queued[msg.ID]; ok && msg.ID != "" && queued.lane == ln {
+		alert = queued.alert
+		delete(d.queued, msg.ID)
+	}
+	if d.boosted[msg.ID] {
+		delete(d.boosted, msg.ID)
+		ln.boosts--
+		d.log.WithFields(logrus.Fields{
+			"app":       ln.name,
+			"messageID": msg.ID,
+		}).Info("Submitting boosted message")
+		return true, false, alert
+	}
+	return false, ln.boosts > 0, alert
+}
+
+// Queues returns the pending messages of each lane, sorted by app name
+func (d *Dispatcher) Queues() []QueueStats {
+	result := []QueueStats{}
+	for _, ln := range d.all() {
+		result = append(result, QueueStats{
+			App:      ln.name,
+			Queued:   len(ln.queue),
+			InFlight: int(atomic.LoadInt64(&ln.inFlight)),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].App < result[j].App
+	})
+	return result
+}
+
+// depth returns the number of messages queued in all lanes
+func (d *Dispatcher) depth() int {
+	queued := len(d.fallback.queue)
+	for _, ln := range d.lanes {
+		queued += len(ln.queue)
+	}
+	for _, ln := range d.overrides {
+		queued += len(ln.queue)
+	}
+	return queued
+}
+
+// LastSubmission returns when a submission last completed, successfully or not, zero if
+// none did
+func (d *Dispatcher) LastSubmission() time.Time {
+	nanos := atomic.LoadInt64(&d.lastSubmission)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (d *Dispatcher) all() []*lane {
+	result := []*lane{d.fallback}
+	for _, ln := range d.lanes {
+		result = append(result, ln)
+	}
+	for _, ln := range d.overrides {
+		result = append(result, ln)
+	}
+	return result
+}
+
+func newLane(name string, config *ThrottleConfig) *lane {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultThrottleQueueSize
+	}
+
+	var limiter *rate.Limiter
+	if config.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(config.Rate/60), 1)
+	}
+
+	return &lane{
+		name:        name,
+		queue:       make(chan Message, queueSize),
+		concurrency: concurrency,
+		limiter:     limiter,
+		wake:        make(chan struct{}),
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestDispatcher(t *testing.T) {
+	nft := [20]byte{1}
+	erc20 := [20]byte{2}
+
+	submitted := make(chan chain.Message, 10)
+	submit := func(_ context.Context, msg *chain.Message, _ bool) {
+		submitted <- *msg
+	}
+
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, logrus.NewEntry(logrus.New()))
+	dispatcher.AddLane("nft", nft, &chain.ThrottleConfig{Rate: 1}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+
+	for i := 0; i < 3; i++ {
+		messages <- chain.Message{AppID: nft, Payload: i}
+	}
+	for i := 0; i < 2; i++ {
+		messages <- chain.Message{AppID: erc20, Payload: i}
+	}
+
+	// one nft message is submitted before the rate limit applies, while erc20 messages flow freely
+	received := map[[20]byte]int{}
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-submitted:
+			received[msg.AppID]++
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for submission")
+		}
+	}
+	assert.Equal(t, map[[20]byte]int{nft: 1, erc20: 2}, received)
+
+	select {
+	case msg := <-submitted:
+		t.Fatalf("unexpected submission for app %x", msg.AppID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the second nft message waits for the rate limiter, the third is queued
+	queues := dispatcher.Queues()
+	assert.Equal(t, []chain.QueueStats{
+		{App: "default", Queued: 0},
+		{App: "nft", Queued: 1},
+	}, queues)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestDispatcher_Budget(t *testing.T) {
+	nft := [20]byte{1}
+
+	type submission struct {
+		msg      chain.Message
+		escalate bool
+	}
+	submitted := make(chan submission, 10)
+	submit := func(_ context.Context, msg *chain.Message, escalate bool) {
+		submitted <- submission{*msg, escalate}
+	}
+
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, logrus.NewEntry(logrus.New()))
+	dispatcher.AddLane("nft", nft, &chain.ThrottleConfig{Rate: 1}, &chain.BudgetConfig{Latency: 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+
+	// messages which consumed most of their budget skip the rate limit
+	observedAt := time.Now().Add(-8 * time.Second)
+	for i := 0; i < 3; i++ {
+		messages <- chain.Message{AppID: nft, Payload: i, ObservedAt: observedAt}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case s := <-submitted:
+			assert.True(t, s.escalate)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for submission")
+		}
+	}
+
+	// fresh messages are rate limited, so only the first is submitted
+	for i := 0; i < 2; i++ {
+		messages <- chain.Message{AppID: nft, Payload: i, ObservedAt: time.Now()}
+	}
+	select {
+	case s := <-submitted:
+		assert.False(t, s.escalate)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for submission")
+	}
+	select {
+	case s := <-submitted:
+		t.Fatalf("unexpected submission of message %v", s.msg.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+// alertHook hands the levels of the alerts logged to a channel
+type alertHook chan logrus.Level
+
+func (ah alertHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (ah alertHook) Fire(entry *logrus.Entry) error {
+	if strings.HasPrefix(entry.Message, "ALERT:") {
+		ah <- entry.Level
+	}
+	return nil
+}
+
+func TestDispatcher_BudgetQueued(t *testing.T) {
+	nft := [20]byte{1}
+
+	submitted := make(chan chain.Message, 10)
+	submit := func(_ context.Context, msg *chain.Message, _ bool) {
+		submitted <- *msg
+	}
+
+	alerts := make(alertHook, 10)
+	logger := logrus.New()
+	logger.AddHook(alerts)
+	gate := chain.NewGate()
+	gate.Halt("maintenance")
+	dispatcher := chain.NewDispatcher("Ethereum", gate, submit, logrus.NewEntry(logger))
+	dispatcher.AddLane("nft", nft, &chain.ThrottleConfig{Rate: 1}, &chain.BudgetConfig{Latency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+
+	// messages at risk are alerted on as they are queued, and once their budget is depleted
+	// while they wait, rather than as they leave the queue
+	messages <- chain.Message{ID: "a", AppID: nft, Payload: 1, ObservedAt: time.Now().Add(-900 * time.Millisecond)}
+	for _, level := range []logrus.Level{logrus.WarnLevel, logrus.ErrorLevel} {
+		select {
+		case alert := <-alerts:
+			assert.Equal(t, level, alert)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s alert", level)
+		}
+	}
+
+	// the alerts aren't raised again once the message is submitted
+	gate.Resume()
+	select {
+	case msg := <-submitted:
+		assert.Equal(t, "a", msg.ID)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for submission")
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Empty(t, alerts)
+}
+
+func TestDispatcher_Boost(t *testing.T) {
+	nft := [20]byte{1}
+
+	type submission struct {
+		msg      chain.Message
+		escalate bool
+	}
+	submitted := make(chan submission, 10)
+	submit := func(_ context.Context, msg *chain.Message, escalate bool) {
+		submitted <- submission{*msg, escalate}
+	}
+
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, logrus.NewEntry(logrus.New()))
+	dispatcher.AddLane("nft", nft, &chain.ThrottleConfig{Rate: 1}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+
+	// the first message is submitted, the second waits for the rate limiter and the third is queued
+	for _, id := range []string{"a", "b", "c"} {
+		messages <- chain.Message{ID: id, AppID: nft, ObservedAt: time.Now()}
+	}
+	s := <-submitted
+	assert.Equal(t, "a", s.msg.ID)
+	assert.False(t, s.escalate)
+	for dispatcher.Queues()[1].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := dispatcher.Boost("a")
+	assert.Equal(t, chain.ErrNotQueued, err)
+
+	// boosting the third message releases the second, which is escalated to preserve the order
+	boost, err := dispatcher.Boost("c")
+	assert.NoError(t, err)
+	assert.Equal(t, &chain.Boost{MessageID: "c", Chain: "Ethereum", App: "nft", Ordered: true, Queued: 1}, boost)
+	for _, id := range []string{"b", "c"} {
+		select {
+		case s := <-submitted:
+			assert.Equal(t, id, s.msg.ID)
+			assert.True(t, s.escalate)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for submission")
+		}
+	}
+
+	// later messages are rate limited again
+	messages <- chain.Message{ID: "d", AppID: nft, ObservedAt: time.Now()}
+	select {
+	case s := <-submitted:
+		t.Fatalf("unexpected submission of message %s", s.msg.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestDispatcher_Overrides(t *testing.T) {
+	nft := [20]byte{1}
+
+	type submission struct {
+		msg      chain.Message
+		escalate bool
+	}
+	submitted := make(chan submission, 10)
+	submit := func(_ context.Context, msg *chain.Message, escalate bool) {
+		submitted <- submission{*msg, escalate}
+	}
+
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, logrus.NewEntry(logrus.New()))
+	dispatcher.AddLane("nft", nft, &chain.ThrottleConfig{Rate: 1}, nil)
+	dispatcher.AddOverride(&chain.OverrideConfig{Name: "exchange", Throttle: &chain.ThrottleConfig{Concurrency: 2}})
+	// overrides without a throttle or budget share the lane of their app
+	dispatcher.AddOverride(&chain.OverrideConfig{Name: "treasury", Priority: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+
+	// the first message of the app consumes its rate limit
+	messages <- chain.Message{AppID: nft, Payload: 0}
+	s := <-submitted
+	assert.False(t, s.escalate)
+
+	// messages of an override with a lane bypass the rate limit of their app, and those with
+	// priority skip it escalated
+	messages <- chain.Message{AppID: nft, Payload: 1, Override: "exchange"}
+	messages <- chain.Message{AppID: nft, Payload: 2, Override: "treasury", Priority: true}
+	received := map[interface{}]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case s := <-submitted:
+			received[s.msg.Payload] = s.escalate
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for submission")
+		}
+	}
+	assert.Equal(t, map[interface{}]bool{1: false, 2: true}, received)
+
+	queues := dispatcher.Queues()
+	assert.Equal(t, []chain.QueueStats{
+		{App: "default", Queued: 0},
+		{App: "nft", Queued: 0},
+		{App: "override:exchange", Queued: 0},
+	}, queues)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// DivergenceConfig enables comparing the heads reported by the endpoints of a chain
+type DivergenceConfig struct {
+	// Seconds between comparisons. Zero disables them.
+	Interval uint64 `mapstructure:"interval"`
+	// Further endpoints whose heads are compared with those of the relayer's endpoints,
+	// without being used otherwise
+	Endpoints []string `mapstructure:"endpoints"`
+	// Blocks by which the head of an endpoint may lag behind the highest head before it is
+	// alerted on. Defaults to 5.
+	MaxLag uint64 `mapstructure:"max-lag"`
+}
+
+const defaultDivergenceMaxLag = 5
+
+// Head is a block reported by an endpoint
+type Head struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+// HeadSource reports the heads of a chain as served by one endpoint
+type HeadSource interface {
+	// Endpoint returns the redacted endpoint
+	Endpoint() string
+	// Heads returns the latest head and the finalized head
+	Heads(ctx context.Context) (Head, Head, error)
+	// BlockHash returns the hash of the block at a height
+	BlockHash(ctx context.Context, number uint64) (string, error)
+}
+
+// EndpointHeads are the heads of an endpoint compared to those of the others
+type EndpointHeads struct {
+	Endpoint  string `json:"endpoint"`
+	Latest    Head   `json:"latest"`
+	Finalized Head   `json:"finalized"`
+	// Blocks by which the heads lag behind the highest heads of all endpoints
+	LatestLag    uint64 `json:"latestLag"`
+	FinalizedLag uint64 `json:"finalizedLag"`
+	// Whether the endpoint serves another block than the first endpoint at a height which
+	// all endpoints finalized
+	Forked bool `json:"forked"`
+	// Description of the fork, if any
+	Fork  string `json:"fork,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// DivergenceMonitor periodically compares the latest and finalized heads served by the
+// endpoints of a chain, so that a provider which falls behind or follows another fork is
+// noticed before the relayer depends on it. Heads and lags are exported as metrics, and
+// alerts are logged when an endpoint starts lagging or forks, and once it recovers. Forks
+// are judged against the first endpoint, the one the listener reads from.
+type DivergenceMonitor struct {
+	chain    string
+	sources  []HeadSource
+	interval time.Duration
+	maxLag   uint64
+	mutex    sync.Mutex
+	// endpoints currently alerted on, by endpoint
+	lagging map[string]bool
+	forked  map[string]bool
+	last    []EndpointHeads
+	log     *logrus.Entry
+}
+
+func NewDivergenceMonitor(chain string, config *DivergenceConfig, sources []HeadSource, log *logrus.Entry) *DivergenceMonitor {
+	maxLag := config.MaxLag
+	if maxLag == 0 {
+		maxLag = defaultDivergenceMaxLag
+	}
+
+	return &DivergenceMonitor{
+		chain:    chain,
+		sources:  sources,
+		interval: time.Duration(config.Interval) * time.Second,
+		maxLag:   maxLag,
+		lagging:  make(map[string]bool),
+		forked:   make(map[string]bool),
+		log:      log,
+	}
+}
+
+// Interval returns the interval between comparisons, which the relay schedules
+func (dm *DivergenceMonitor) Interval() time.Duration {
+	return dm.interval
+}
+
+// Heads returns the outcome of the last comparison
+func (dm *DivergenceMonitor) Heads() []EndpointHeads {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	return append([]EndpointHeads{}, dm.last...)
+}
+
+// Check compares the heads of all endpoints, returning them with their lags. Endpoints which
+// fail to answer are reported with their error, and left out of the comparison.
+func (dm *DivergenceMonitor) Check(ctx context.Context) []EndpointHeads {
+	heads := make([]EndpointHeads, len(dm.sources))
+	var bestLatest, bestFinalized uint64
+	for i, source := range dm.sources {
+		heads[i].Endpoint = source.Endpoint()
+
+		latest, finalized, err := source.Heads(ctx)
+		if err != nil {
+			heads[i].Error = err.Error()
+			continue
+		}
+		heads[i].Latest = latest
+		heads[i].Finalized = finalized
+
+		if latest.Number > bestLatest {
+			bestLatest = latest.Number
+		}
+		if finalized.Number > bestFinalized {
+			bestFinalized = finalized.Number
+		}
+	}
+
+	for i := range heads {
+		if heads[i].Error != "" {
+			continue
+		}
+		heads[i].LatestLag = bestLatest - heads[i].Latest.Number
+		heads[i].FinalizedLag = bestFinalized - heads[i].Finalized.Number
+	}
+
+	dm.compareForks(ctx, heads)
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	for _, endpoint := range heads {
+		dm.report(&endpoint)
+	}
+	dm.last = heads
+
+	return heads
+}
+
+// compareForks compares the blocks served by each endpoint at the lowest height which all
+// answering endpoints finalized with the block served by the first endpoint
+func (dm *DivergenceMonitor) compareForks(ctx context.Context, heads []EndpointHeads) {
+	if len(heads) < 2 || heads[0].Error != "" {
+		return
+	}
+
+	height := heads[0].Finalized.Number
+	for _, endpoint := range heads[1:] {
+		if endpoint.Error == "" && endpoint.Finalized.Number < height {
+			height = endpoint.Finalized.Number
+		}
+	}
+
+	reference, err := dm.sources[0].BlockHash(ctx, height)
+	if err != nil {
+		heads[0].Error = err.Error()
+		return
+	}
+
+	for i := 1; i < len(heads); i++ {
+		if heads[i].Error != "" {
+			continue
+		}
+
+		hash, err := dm.sources[i].BlockHash(ctx, height)
+		if err != nil {
+			heads[i].Error = err.Error()
+			continue
+		}
+		heads[i].Forked = hash != reference
+		if heads[i].Forked {
+			heads[i].Fork = fmt.Sprintf("serves block %s at height %d, where %s serves %s", hash, height, heads[0].Endpoint, reference)
+		}
+	}
+}
+
+// report exports the heads of an endpoint and logs alerts as it starts or stops diverging
+func (dm *DivergenceMonitor) report(heads *EndpointHeads) {
+	log := dm.log.WithFields(logrus.Fields{
+		"endpoint":     heads.Endpoint,
+		"latest":       heads.Latest.Number,
+		"finalized":    heads.Finalized.Number,
+		"latestLag":    heads.LatestLag,
+		"finalizedLag": heads.FinalizedLag,
+	})
+
+	if heads.Forked {
+		metrics.EndpointForks.WithLabelValues(dm.chain, heads.Endpoint).Inc()
+		if !dm.forked[heads.Endpoint] {
+			log.WithField("fork", heads.Fork).Error("ALERT: endpoint serves another fork of the chain")
+		}
+	} else if dm.forked[heads.Endpoint] && heads.Error == "" {
+		log.Info("Endpoint is back on the same fork")
+	}
+
+	if heads.Error != "" {
+		log.WithField("error", heads.Error).Debug("Failed to compare heads of endpoint")
+		return
+	}
+	dm.forked[heads.Endpoint] = heads.Forked
+
+	metrics.EndpointHead.WithLabelValues(dm.chain, heads.Endpoint, "latest").Set(float64(heads.Latest.Number))
+	metrics.EndpointHead.WithLabelValues(dm.chain, heads.Endpoint, "finalized").Set(float64(heads.Finalized.Number))
+	metrics.EndpointLag.WithLabelValues(dm.chain, heads.Endpoint, "latest").Set(float64(heads.LatestLag))
+	metrics.EndpointLag.WithLabelValues(dm.chain, heads.Endpoint, "finalized").Set(float64(heads.FinalizedLag))
+
+	lagging := heads.LatestLag > dm.maxLag || heads.FinalizedLag > dm.maxLag
+	if lagging && !dm.lagging[heads.Endpoint] {
+		log.Error("ALERT: endpoint lags behind the other endpoints of the chain")
+	} else if !lagging && dm.lagging[heads.Endpoint] {
+		log.Info("Endpoint caught up with the other endpoints of the chain")
+	}
+	dm.lagging[heads.Endpoint] = lagging
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type fakeHeads struct {
+	endpoint  string
+	latest    uint64
+	finalized uint64
+	// hashes of blocks by height, "0x<height>" if absent
+	hashes map[uint64]string
+	err    error
+}
+
+func (fh *fakeHeads) Endpoint() string {
+	return fh.endpoint
+}
+
+func (fh *fakeHeads) Heads(ctx context.Context) (chain.Head, chain.Head, error) {
+	if fh.err != nil {
+		return chain.Head{}, chain.Head{}, fh.err
+	}
+	return chain.Head{Number: fh.latest, Hash: fh.hash(fh.latest)}, chain.Head{Number: fh.finalized, Hash: fh.hash(fh.finalized)}, nil
+}
+
+func (fh *fakeHeads) BlockHash(ctx context.Context, number uint64) (string, error) {
+	return fh.hash(number), nil
+}
+
+func (fh *fakeHeads) hash(number uint64) string {
+	if hash, ok := fh.hashes[number]; ok {
+		return hash
+	}
+	return fmt.Sprintf("0x%x", number)
+}
+
+func TestDivergenceMonitor(t *testing.T) {
+	primary := &fakeHeads{endpoint: "primary", latest: 110, finalized: 100}
+	behind := &fakeHeads{endpoint: "behind", latest: 102, finalized: 90}
+	forked := &fakeHeads{endpoint: "forked", latest: 111, finalized: 101, hashes: map[uint64]string{90: "0xother"}}
+	failing := &fakeHeads{endpoint: "failing", err: fmt.Errorf("connection refused")}
+
+	config := chain.DivergenceConfig{Interval: 1}
+	monitor := chain.NewDivergenceMonitor("Test", &config, []chain.HeadSource{primary, behind, forked, failing}, logrus.NewEntry(logrus.New()))
+
+	heads := monitor.Check(context.Background())
+	assert.Len(t, heads, 4)
+
+	assert.Equal(t, uint64(110), heads[0].Latest.Number)
+	assert.Equal(t, uint64(1), heads[0].LatestLag)
+	assert.Equal(t, uint64(1), heads[0].FinalizedLag)
+	assert.False(t, heads[0].Forked)
+
+	assert.Equal(t, uint64(9), heads[1].LatestLag)
+	assert.Equal(t, uint64(11), heads[1].FinalizedLag)
+	assert.False(t, heads[1].Forked)
+
+	// forks are compared at the lowest finalized height
+	assert.Equal(t, uint64(0), heads[2].LatestLag)
+	assert.True(t, heads[2].Forked)
+	assert.Contains(t, heads[2].Fork, "0xother")
+
+	assert.Equal(t, "connection refused", heads[3].Error)
+	assert.False(t, heads[3].Forked)
+
+	assert.Equal(t, heads, monitor.Heads())
+
+	// once the endpoint catches up on the same fork
+	behind.latest, behind.finalized = 110, 100
+	forked.hashes = nil
+	heads = monitor.Check(context.Background())
+	assert.Equal(t, uint64(1), heads[1].LatestLag)
+	assert.False(t, heads[2].Forked)
+}
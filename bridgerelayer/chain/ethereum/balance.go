@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// setupBalances monitors the balances of the accounts paying for the submissions of the
+// writer: the relayer account, and the sponsor of forwarded deliveries, or the smart account
+// of user operations instead. The cost of the next transaction is estimated at the current
+// fees for the gas limit of a single delivery. It isn't estimated for user operations,
+// whose gas may be paid by a paymaster, nor for a relayer account whose deliveries are
+// all sponsored.
+func (wr *Writer) setupBalances() error {
+	if wr.config.Balance.Interval == 0 {
+		return nil
+	}
+
+	type payer struct {
+		account common.Address
+		cost    chain.NextCost
+	}
+	var payers []payer
+	if wr.bundler != nil {
+		payers = append(payers, payer{account: wr.bundler.Account()})
+	} else {
+		var cost chain.NextCost
+		if len(wr.forwarders) < len(wr.config.Apps) {
+			cost = wr.nextCost(gasLimit)
+		}
+		payers = append(payers, payer{account: wr.conn.Keypair().CommonAddress(), cost: cost})
+		if wr.sponsor != nil && len(wr.forwarders) > 0 {
+			payers = append(payers, payer{account: wr.sponsor.CommonAddress(), cost: wr.nextCost(gasLimit + forwarderGasOverhead)})
+		}
+	}
+
+	for _, payer := range payers {
+		account := payer.account
+		balance := func(ctx context.Context) (*big.Int, error) {
+			return wr.conn.Client().BalanceAt(ctx, account, nil)
+		}
+		monitor, err := chain.NewBalanceMonitor(Name, account.Hex(), &wr.config.Balance, wr.gate, balance, payer.cost, wr.log)
+		if err != nil {
+			return err
+		}
+		wr.balances = append(wr.balances, monitor)
+	}
+	return nil
+}
+
+// BalanceInterval returns the interval between checks of the balances, zero if they aren't
+// monitored
+func (wr *Writer) BalanceInterval() time.Duration {
+	return time.Duration(wr.config.Balance.Interval) * time.Second
+}
+
+// CheckBalances checks the balances of the accounts paying for the submissions of the
+// writer, none until it started
+func (wr *Writer) CheckBalances(ctx context.Context) error {
+	return chain.CheckBalances(ctx, wr.balances)
+}
+
+// nextCost estimates the cost of a transaction with the given gas limit at the fees it
+// would currently be priced at
+func (wr *Writer) nextCost(gas uint64) chain.NextCost {
+	return func(ctx context.Context) (*big.Int, error) {
+		fees, err := wr.txFees(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).Mul(fees.gasPrice, new(big.Int).SetUint64(gas)), nil
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type BatchConfig struct {
+	// Maximum number of messages submitted in one transaction. Messages are submitted one
+	// per transaction if zero or one.
+	Size int `mapstructure:"size"`
+	// Seconds for which messages are accumulated after the first of a batch, before the
+	// batch is submitted even if it isn't full. Defaults to 5.
+	Window uint64 `mapstructure:"window"`
+	// Gas limit of batch transactions. Defaults to the gas limit of single deliveries for
+	// each message of the batch.
+	GasLimit uint64 `mapstructure:"gas-limit"`
+}
+
+const defaultBatchWindow = 5 * time.Second
+
+type batchedMessage struct {
+	msg      chain.Message
+	escalate bool
+}
+
+// Batcher accumulates the messages of an app, so that they are submitted together in one
+// call of the submitBatch entry point of the app rather than one transaction each. A batch
+// is submitted once it is full, or once the window elapsed since its first message. The
+// app reports the result of each message of a batch by a BatchResult event, so that the
+// messages which failed are retried or skipped on their own, while the others are
+// confirmed. A batch is escalated if any of its messages is.
+type Batcher struct {
+	name     string
+	app      common.Address
+	size     int
+	window   time.Duration
+	gasLimit uint64
+	queue    chan batchedMessage
+}
+
+// batchResult is the BatchResult event by which an app reports the result of a message
+type batchResult struct {
+	Index   *big.Int
+	Success bool
+}
+
+func NewBatcher(name string, app common.Address, config *BatchConfig) *Batcher {
+	window := time.Duration(config.Window) * time.Second
+	if window == 0 {
+		window = defaultBatchWindow
+	}
+
+	return &Batcher{
+		name:     name,
+		app:      app,
+		size:     config.Size,
+		window:   window,
+		gasLimit: config.GasLimit,
+		queue:    make(chan batchedMessage, config.Size),
+	}
+}
+
+// add appends a message to the next batch, waiting while the batch is full
+func (ba *Batcher) add(ctx context.Context, msg *chain.Message, escalate bool) {
+	select {
+	case ba.queue <- batchedMessage{*msg, escalate}:
+	case <-ctx.Done():
+	}
+}
+
+// next waits for the first message of a batch, and then for the batch to fill up or for its
+// window to elapse
+func (ba *Batcher) next(ctx context.Context) ([]batchedMessage, error) {
+	var batch []batchedMessage
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case bm := <-ba.queue:
+		batch = append(batch, bm)
+	}
+
+	timer := time.NewTimer(ba.window)
+	defer timer.Stop()
+
+	for len(batch) < ba.size {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return batch, nil
+		case bm := <-ba.queue:
+			batch = append(batch, bm)
+		}
+	}
+	return batch, nil
+}
+
+// gas returns the gas limit of a batch transaction
+func (ba *Batcher) gas(messages int) uint64 {
+	if ba.gasLimit > 0 {
+		return ba.gasLimit
+	}
+	return gasLimit * uint64(messages)
+}
+
+// batchLoop submits the batches of an app until the context is cancelled
+func (wr *Writer) batchLoop(ctx context.Context, batcher *Batcher) error {
+	wr.log.WithFields(logrus.Fields{
+		"app":    batcher.name,
+		"size":   batcher.size,
+		"window": batcher.window,
+	}).Info("Batching deliveries of app")
+
+	for {
+		batch, err := batcher.next(ctx)
+		if err != nil {
+			return err
+		}
+		wr.handleBatch(ctx, batcher, batch)
+	}
+}
+
+// handleBatch submits a batch of messages, handling any failure. A batch of one message is
+// submitted as a single delivery.
+func (wr *Writer) handleBatch(ctx context.Context, batcher *Batcher, batch []batchedMessage) {
+	msgs := []chain.Message{}
+	payloads := [][]byte{}
+	escalate := false
+	for _, bm := range batch {
+		payload, ok := bm.msg.Payload.([]byte)
+		if !ok {
+			msg := bm.msg
+			wr.handleOne(ctx, &msg, bm.escalate)
+			continue
+		}
+		msgs = append(msgs, bm.msg)
+		payloads = append(payloads, payload)
+		escalate = escalate || bm.escalate
+	}
+
+	switch len(msgs) {
+	case 0:
+		return
+	case 1:
+		wr.handleOne(ctx, &msgs[0], escalate)
+		return
+	}
+
+	metrics.BatchSize.WithLabelValues(Name, batcher.name).Observe(float64(len(msgs)))
+
+	attempts := 0
+	err := wr.backoff.Retry(ctx, wr.log, "submit batch", func() error {
+		attempts++
+		return wr.deliverBatch(ctx, batcher, msgs, payloads, escalate)
+	})
+	// the messages of a batch exceeding the cost cap are delivered on their own, so that
+	// only those exceeding it are refused
+	if errors.Is(err, chain.ErrCostExceeded) {
+		for i := range msgs {
+			wr.handleOne(ctx, &msgs[i], escalate)
+		}
+		return
+	}
+	if err != nil {
+		wr.log.WithError(err).WithField("messages", len(msgs)).Error("Error submitting batch of messages to ethereum")
+		for i := range msgs {
+			metrics.TransactionsFailed.WithLabelValues(Name, batcher.name).Inc()
+			chain.DeadLetter(ctx, wr.deadLetters, Name, &msgs[i], err, attempts, wr.log)
+		}
+	}
+}
+
+// deliverBatch submits a batch of messages in one call of the submitBatch entry point of
+// their app
+func (wr *Writer) deliverBatch(ctx context.Context, batcher *Batcher, msgs []chain.Message, payloads [][]byte, escalate bool) error {
+	wr.log.WithFields(logrus.Fields{
+		"contractAddress": batcher.app.Hex(),
+		"messages":        len(msgs),
+		"firstSequence":   msgs[0].Sequence,
+	}).Info("Submitting batch of messages to Ethereum")
+
+	txData, err := wr.abi.Pack("submitBatch", payloads)
+	if err != nil {
+		return chain.Permanent(err)
+	}
+
+	err = wr.checkCost(ctx, batcher.name, batcher.app, txData, len(msgs), escalate)
+	if err != nil {
+		return err
+	}
+
+	hash, maxFee, err := wr.submit(ctx, batcher.app, batcher.gas(len(msgs)), txData, escalate)
+	if err != nil {
+		wr.throughput.Rejected()
+		return err
+	}
+
+	receipt := chain.Receipt{
+		Chain:        Name,
+		Hash:         hash.Hex(),
+		SubmittedAt:  time.Now().UTC(),
+		MaxFeePerGas: maxFee.String(),
+	}
+	for i := range msgs {
+		metrics.TransactionsSubmitted.WithLabelValues(Name, batcher.name).Inc()
+		if !msgs[i].ObservedAt.IsZero() {
+			metrics.SubmissionDelay.WithLabelValues(Name).Observe(time.Since(msgs[i].ObservedAt).Seconds())
+		}
+		if wr.receipts != nil {
+			submitted := receipt
+			wr.receipts.Submitted(&msgs[i], &submitted)
+		}
+	}
+
+	// batches are always confirmed, as the results of their messages are only known from
+	// their receipt
+	wr.throughput.Submitted()
+	go wr.confirmBatch(ctx, batcher, msgs, receipt, hash)
+
+	return nil
+}
+
+// confirmBatch waits for a batch to be confirmed, and reports the result of each of its
+// messages. Messages of a batch which reverted, and messages which failed in a batch which
+// succeeded, are delivered on their own.
+func (wr *Writer) confirmBatch(parent context.Context, batcher *Batcher, msgs []chain.Message, receipt chain.Receipt, hash common.Hash) {
+	ctx, cancel := context.WithTimeout(parent, confirmTimeout)
+	defer cancel()
+	defer wr.pending.remove(hash)
+
+	log := wr.log.WithFields(logrus.Fields{
+		"hash":     receipt.Hash,
+		"messages": len(msgs),
+	})
+
+	result, fee, ok := wr.await(ctx, hash, receipt.SubmittedAt, log)
+	if !ok {
+		wr.settleForwarded(hash, false)
+		return
+	}
+	wr.settleForwarded(hash, result.Status == types.ReceiptStatusSuccessful)
+
+	results := map[int]bool{}
+	if result.Status == types.ReceiptStatusSuccessful {
+		results = wr.batchResults(batcher, result, len(msgs))
+	} else {
+		log.WithField("blockNumber", result.BlockNumber).Error("Batch delivery failed on-chain")
+	}
+
+	// each message is charged an equal share of the fee
+	var feeShare *big.Int
+	if fee != nil {
+		feeShare = new(big.Int).Div(fee, big.NewInt(int64(len(msgs))))
+	}
+
+	delivered := 0
+	failed := []chain.Message{}
+	for i := range msgs {
+		if !results[i] {
+			metrics.TransactionsFailed.WithLabelValues(Name, batcher.name).Inc()
+			failed = append(failed, msgs[i])
+			continue
+		}
+
+		confirmed := receipt
+		wr.settle(ctx, &msgs[i], &confirmed, result, hash, feeShare, result.GasUsed/uint64(len(msgs)))
+		delivered++
+	}
+
+	if result.Status == types.ReceiptStatusSuccessful {
+		log.WithFields(logrus.Fields{
+			"blockNumber": result.BlockNumber,
+			"delivered":   delivered,
+			"failed":      len(failed),
+		}).Info("Batch delivery confirmed")
+		wr.tuner.Observe(time.Since(receipt.SubmittedAt))
+	}
+
+	cancel()
+	wr.redeliver(parent, failed, result.BlockNumber, log)
+}
+
+// redeliver delivers the failed messages of a batch included in a block on their own.
+// Messages whose call still reverts are retried or skipped like reverted single deliveries,
+// each apart, so that a message waiting out a transient revert doesn't hold back the others.
+func (wr *Writer) redeliver(ctx context.Context, failed []chain.Message, included *big.Int, log *logrus.Entry) {
+	for i := range failed {
+		msg := failed[i]
+		log.WithFields(msg.LogFields()).Error("Delivery of message in batch failed on-chain")
+
+		// messages whose call can't be checked are submitted again, and retried once their
+		// own delivery reverts
+		reason, err := wr.revertReason(ctx, &msg, nil)
+		if err == nil && reason != "" {
+			go wr.retry(ctx, msg, 0, included)
+			continue
+		}
+		wr.handleOne(ctx, &msg, false)
+	}
+}
+
+// batchResults returns the results of the messages of a batch by their index, from the
+// BatchResult events emitted by their app. Messages without a result failed.
+func (wr *Writer) batchResults(batcher *Batcher, receipt *types.Receipt, messages int) map[int]bool {
+	event := wr.abi.Events["BatchResult"]
+	results := map[int]bool{}
+	for _, entry := range receipt.Logs {
+		if entry.Address != batcher.app || len(entry.Topics) == 0 || entry.Topics[0] != event.ID {
+			continue
+		}
+
+		var result batchResult
+		err := wr.abi.Unpack(&result, "BatchResult", entry.Data)
+		if err != nil || !result.Index.IsInt64() || result.Index.Int64() >= int64(messages) {
+			wr.log.WithError(err).WithField("hash", receipt.TxHash.Hex()).Warn("Ignored malformed BatchResult event")
+			continue
+		}
+		results[int(result.Index.Int64())] = result.Success
+	}
+	return results
+}
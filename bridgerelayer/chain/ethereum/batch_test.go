@@ -0,0 +1,133 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestBatcher_Next(t *testing.T) {
+	ctx := context.Background()
+	batcher := NewBatcher("erc20", common.Address{1}, &BatchConfig{Size: 2})
+	batcher.window = 10 * time.Millisecond
+
+	// full batches are returned at once
+	for i := 0; i < 2; i++ {
+		batcher.add(ctx, &chain.Message{Sequence: uint64(i)}, i == 1)
+	}
+	batch, err := batcher.next(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+	assert.True(t, batch[1].escalate)
+
+	// and others once their window elapsed
+	batcher.add(ctx, &chain.Message{Sequence: 2}, false)
+	start := time.Now()
+	batch, err = batcher.next(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, uint64(2), batch[0].msg.Sequence)
+	assert.True(t, time.Since(start) >= batcher.window)
+
+	// the gas limit defaults to the gas of single deliveries
+	assert.Equal(t, 3*gasLimit, batcher.gas(3))
+}
+
+func TestWriter_Batch(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	app := common.Address{1}
+
+	config := &Config{
+		Apps: map[string]Application{
+			"erc20": {Address: app.Hex(), Batch: &BatchConfig{Size: 3}},
+		},
+	}
+	wr, err := NewWriter(config, conn, nil, nil, nil, nil, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	batcher := wr.batchers[app]
+	require.NotNil(t, batcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the messages of a batch are submitted in a single call
+	payloads := [][]byte{{1}, {2}, {3}}
+	batch := []batchedMessage{}
+	for _, payload := range payloads {
+		batch = append(batch, batchedMessage{msg: chain.Message{AppID: app, Payload: payload}})
+	}
+	wr.handleBatch(ctx, batcher, batch)
+
+	sent := client.Sent()
+	require.Len(t, sent, 1)
+	txData, err := wr.abi.Pack("submitBatch", payloads)
+	require.NoError(t, err)
+	assert.Equal(t, txData, sent[0].Data())
+	assert.Equal(t, 3*gasLimit, sent[0].Gas())
+
+	// whose results are reported by the app for each message
+	event := wr.abi.Events["BatchResult"]
+	resultLog := func(address common.Address, index int64, success bool) *types.Log {
+		data, err := event.Inputs.Pack(big.NewInt(index), success)
+		require.NoError(t, err)
+		return &types.Log{Address: address, Topics: []common.Hash{event.ID}, Data: data}
+	}
+	receipt := &types.Receipt{
+		Status: types.ReceiptStatusSuccessful,
+		TxHash: sent[0].Hash(),
+		Logs: []*types.Log{
+			resultLog(app, 0, true),
+			resultLog(app, 1, false),
+			resultLog(common.Address{2}, 2, true),
+			resultLog(app, 7, true),
+		},
+	}
+	assert.Equal(t, map[int]bool{0: true, 1: false}, wr.batchResults(batcher, receipt, 3))
+}
+
+func TestWriter_Redeliver(t *testing.T) {
+	// retries are disabled
+	wr, client, skipped := newRetryWriter(t, 0)
+	wr.DivertFailures(skipped)
+	ok, reverting := common.Address{1}, common.Address{2}
+	client.SetCallError(reverting, errors.New("execution reverted: invalid signature"))
+
+	// failed messages of a batch whose call no longer reverts are submitted on their own,
+	// and the others dead-lettered
+	failed := []chain.Message{
+		{ID: "a", AppID: ok, Payload: []byte{1}},
+		{ID: "b", AppID: reverting, Payload: []byte{2}},
+	}
+	wr.redeliver(context.Background(), failed, big.NewInt(1), wr.log)
+
+	sent := client.Sent()
+	require.Len(t, sent, 1)
+	txData, err := wr.abi.Pack("submit", []byte{1})
+	require.NoError(t, err)
+	assert.Equal(t, txData, sent[0].Data())
+	assert.Equal(t, ok, *sent[0].To())
+
+	assert.Eventually(t, func() bool {
+		return skipped.reason("b") == "dead-lettered after 1 attempts: reverted: invalid signature"
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, skipped.reason("a"))
+}
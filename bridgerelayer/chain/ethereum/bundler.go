@@ -0,0 +1,385 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// BundlerABI contains the EntryPoint and smart account functions used when
+// delivering messages as ERC-4337 user operations
+const BundlerABI = `
+[
+	{
+		"inputs": [
+			{ "internalType": "address", "name": "sender", "type": "address" },
+			{ "internalType": "uint192", "name": "key", "type": "uint192" }
+		],
+		"name": "getNonce",
+		"outputs": [
+			{ "internalType": "uint256", "name": "nonce", "type": "uint256" }
+		],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [
+			{ "internalType": "address", "name": "dest", "type": "address" },
+			{ "internalType": "uint256", "name": "value", "type": "uint256" },
+			{ "internalType": "bytes", "name": "func", "type": "bytes" }
+		],
+		"name": "execute",
+		"outputs": [],
+		"stateMutability": "nonpayable",
+		"type": "function"
+	}
+]
+`
+
+const (
+	defaultVerificationGasLimit = uint64(150000)
+	defaultPreVerificationGas   = uint64(50000)
+)
+
+var userOperationArgs = mustArguments("address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes32")
+
+var userOperationHashArgs = mustArguments("bytes32", "address", "uint256")
+
+func mustArguments(types ...string) abi.Arguments {
+	arguments := abi.Arguments{}
+	for _, t := range types {
+		typ, err := abi.NewType(t, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		arguments = append(arguments, abi.Argument{Type: typ})
+	}
+	return arguments
+}
+
+// UserOperation is an ERC-4337 (EntryPoint v0.6) user operation
+type UserOperation struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *hexutil.Big   `json:"nonce"`
+	InitCode             hexutil.Bytes  `json:"initCode"`
+	CallData             hexutil.Bytes  `json:"callData"`
+	CallGasLimit         *hexutil.Big   `json:"callGasLimit"`
+	VerificationGasLimit *hexutil.Big   `json:"verificationGasLimit"`
+	PreVerificationGas   *hexutil.Big   `json:"preVerificationGas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     hexutil.Bytes  `json:"paymasterAndData"`
+	Signature            hexutil.Bytes  `json:"signature"`
+}
+
+// Hash returns the user operation hash as computed by EntryPoint.getUserOpHash
+func (op *UserOperation) Hash(entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	packed, err := userOperationArgs.Pack(
+		op.Sender,
+		op.Nonce.ToInt(),
+		common.BytesToHash(crypto.Keccak256(op.InitCode)),
+		common.BytesToHash(crypto.Keccak256(op.CallData)),
+		op.CallGasLimit.ToInt(),
+		op.VerificationGasLimit.ToInt(),
+		op.PreVerificationGas.ToInt(),
+		op.MaxFeePerGas.ToInt(),
+		op.MaxPriorityFeePerGas.ToInt(),
+		common.BytesToHash(crypto.Keccak256(op.PaymasterAndData)),
+	)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	encoded, err := userOperationHashArgs.Pack(common.BytesToHash(crypto.Keccak256(packed)), entryPoint, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(crypto.Keccak256(encoded)), nil
+}
+
+// Sign signs the user operation as the owner of a SimpleAccount-style smart account
+func (op *UserOperation) Sign(entryPoint common.Address, chainID *big.Int, kp *secp256k1.Keypair) error {
+	hash, err := op.Hash(entryPoint, chainID)
+	if err != nil {
+		return err
+	}
+
+	signature, err := crypto.Sign(accounts.TextHash(hash.Bytes()), kp.PrivateKey())
+	if err != nil {
+		return err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+
+	op.Signature = signature
+
+	return nil
+}
+
+// Bundler delivers messages as user operations from a smart account via an ERC-4337 bundler
+type Bundler struct {
+	config     *BundlerConfig
+	abi        abi.ABI
+	client     *rpc.Client
+	entryPoint common.Address
+	account    common.Address
+	chainID    *big.Int
+	stats      *chain.RPCStats
+	// nil if the bundler is dialed by default
+	dialer *chain.Dialer
+	// max priority fee per gas, nil to offer the suggested one
+	tip *big.Int
+	// next nonce of the account, tracked locally so that a user operation sent before the
+	// previous one is included doesn't reuse its nonce. Unset after a failed delivery.
+	nonce      *big.Int
+	nonceMutex sync.Mutex
+}
+
+func NewBundler(config *BundlerConfig, stats *chain.RPCStats) (*Bundler, error) {
+	bundlerABI, err := abi.JSON(strings.NewReader(BundlerABI))
+	if err != nil {
+		return nil, err
+	}
+
+	if !common.IsHexAddress(config.EntryPoint) {
+		return nil, fmt.Errorf("invalid entry point address: %s", config.EntryPoint)
+	}
+
+	if !common.IsHexAddress(config.Account) {
+		return nil, fmt.Errorf("invalid smart account address: %s", config.Account)
+	}
+
+	tip, err := parseFeeCap("max priority fee per gas", config.MaxPriorityFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundler{
+		config:     config,
+		abi:        bundlerABI,
+		entryPoint: common.HexToAddress(config.EntryPoint),
+		account:    common.HexToAddress(config.Account),
+		stats:      stats,
+		tip:        tip,
+	}, nil
+}
+
+// DialThrough dials the bundler through a dialer, by default if nil. It must be set before
+// connecting.
+func (bu *Bundler) DialThrough(dialer *chain.Dialer) {
+	bu.dialer = dialer
+}
+
+func (bu *Bundler) Connect(ctx context.Context, conn Connection) error {
+	client, err := chain.DialRPC(ctx, bu.config.Endpoint, bu.dialer)
+	if err != nil {
+		return err
+	}
+
+	chainID, err := conn.Client().ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	bu.client = client
+	bu.chainID = chainID
+
+	return nil
+}
+
+func (bu *Bundler) Close() {
+	if bu.client != nil {
+		bu.client.Close()
+	}
+}
+
+// Account returns the address of the smart account sending user operations
+func (bu *Bundler) Account() common.Address {
+	return bu.account
+}
+
+// Submit packages a call to the given contract as a user operation signed by the
+// relayer account, offering the given max fee and the suggested priority fee, nil if
+// unknown, and sends it to the bundler, returning the user operation hash
+func (bu *Bundler) Submit(ctx context.Context, conn Connection, to common.Address, data []byte, maxFee *big.Int, tip *big.Int) (common.Hash, error) {
+	callData, err := bu.abi.Pack("execute", to, big.NewInt(0), data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	paymasterAndData, err := hexutil.Decode(bu.paymasterAndData())
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid paymaster data: %v", err)
+	}
+
+	onchain, err := bu.fetchNonce(ctx, conn)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	nonce := bu.nextNonce(onchain)
+
+	op := UserOperation{
+		Sender:               bu.account,
+		Nonce:                (*hexutil.Big)(nonce),
+		InitCode:             []byte{},
+		CallData:             callData,
+		CallGasLimit:         (*hexutil.Big)(new(big.Int).SetUint64(bu.callGasLimit())),
+		VerificationGasLimit: (*hexutil.Big)(new(big.Int).SetUint64(bu.verificationGasLimit())),
+		PreVerificationGas:   (*hexutil.Big)(new(big.Int).SetUint64(bu.preVerificationGas())),
+		MaxFeePerGas:         (*hexutil.Big)(maxFee),
+		MaxPriorityFeePerGas: (*hexutil.Big)(bu.priorityFee(maxFee, tip)),
+		PaymasterAndData:     paymasterAndData,
+	}
+
+	err = op.Sign(bu.entryPoint, bu.chainID, conn.Keypair())
+	if err != nil {
+		bu.ResetNonce()
+		return common.Hash{}, err
+	}
+
+	var hash common.Hash
+	err = bu.call(ctx, &hash, "eth_sendUserOperation", op, bu.entryPoint)
+	if err != nil {
+		bu.ResetNonce()
+		return common.Hash{}, err
+	}
+
+	return hash, nil
+}
+
+// priorityFee returns the max priority fee per gas offered by a user operation: the
+// configured one, or else the suggested one, capped at the max fee. Chains without a base
+// fee are offered the whole gas price, which the EntryPoint then charges.
+func (bu *Bundler) priorityFee(maxFee *big.Int, tip *big.Int) *big.Int {
+	if bu.tip != nil {
+		tip = bu.tip
+	}
+	if tip == nil || tip.Cmp(maxFee) > 0 {
+		return maxFee
+	}
+	return tip
+}
+
+// nextNonce reserves the next nonce of the account. The local counter is only used while
+// it is ahead of the nonce of the EntryPoint, which excludes user operations which are
+// still pending.
+func (bu *Bundler) nextNonce(onchain *big.Int) *big.Int {
+	bu.nonceMutex.Lock()
+	defer bu.nonceMutex.Unlock()
+
+	nonce := onchain
+	if bu.nonce != nil && bu.nonce.Cmp(nonce) > 0 {
+		nonce = bu.nonce
+	}
+	bu.nonce = new(big.Int).Add(nonce, big.NewInt(1))
+
+	return new(big.Int).Set(nonce)
+}
+
+// ResetNonce discards the locally tracked nonce after a failed delivery, so that the next
+// user operation takes the nonce of the EntryPoint, filling the gap left by the failure
+func (bu *Bundler) ResetNonce() {
+	bu.nonceMutex.Lock()
+	defer bu.nonceMutex.Unlock()
+	bu.nonce = nil
+}
+
+// UserOperationReceipt describes the inclusion of a user operation in a bundle
+type UserOperationReceipt struct {
+	Success bool `json:"success"`
+	Receipt struct {
+		TransactionHash common.Hash    `json:"transactionHash"`
+		BlockHash       common.Hash    `json:"blockHash"`
+		BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	} `json:"receipt"`
+	// Fee paid for the user operation in wei, and the gas it used
+	ActualGasCost *hexutil.Big `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big `json:"actualGasUsed"`
+}
+
+// Receipt returns the receipt of a user operation, or nil if it was not included yet
+func (bu *Bundler) Receipt(ctx context.Context, hash common.Hash) (*UserOperationReceipt, error) {
+	var receipt *UserOperationReceipt
+	err := bu.call(ctx, &receipt, "eth_getUserOperationReceipt", hash)
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// Stats returns the statistics of the calls made to the bundler
+func (bu *Bundler) Stats() *chain.RPCStats {
+	return bu.stats
+}
+
+func (bu *Bundler) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	done := bu.stats.Start(method, args...)
+	err := bu.client.CallContext(ctx, result, method, args...)
+	done(err)
+	return err
+}
+
+func (bu *Bundler) fetchNonce(ctx context.Context, conn Connection) (*big.Int, error) {
+	input, err := bu.abi.Pack("getNonce", bu.account, big.NewInt(0))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := conn.Client().CallContract(ctx, geth.CallMsg{To: &bu.entryPoint, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce *big.Int
+	err = bu.abi.Unpack(&nonce, "getNonce", output)
+	if err != nil {
+		return nil, err
+	}
+
+	return nonce, nil
+}
+
+func (bu *Bundler) callGasLimit() uint64 {
+	if bu.config.CallGasLimit == 0 {
+		return gasLimit
+	}
+	return bu.config.CallGasLimit
+}
+
+func (bu *Bundler) verificationGasLimit() uint64 {
+	if bu.config.VerificationGasLimit == 0 {
+		return defaultVerificationGasLimit
+	}
+	return bu.config.VerificationGasLimit
+}
+
+func (bu *Bundler) preVerificationGas() uint64 {
+	if bu.config.PreVerificationGas == 0 {
+		return defaultPreVerificationGas
+	}
+	return bu.config.PreVerificationGas
+}
+
+func (bu *Bundler) paymasterAndData() string {
+	if bu.config.PaymasterAndData == "" {
+		return "0x"
+	}
+	return bu.config.PaymasterAndData
+}
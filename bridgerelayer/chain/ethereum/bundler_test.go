@@ -0,0 +1,221 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func newUserOperation() ethereum.UserOperation {
+	return ethereum.UserOperation{
+		Sender:               common.HexToAddress("0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0"),
+		Nonce:                (*hexutil.Big)(big.NewInt(7)),
+		InitCode:             []byte{},
+		CallData:             []byte{0xb6, 0x1d, 0x27, 0xf6},
+		CallGasLimit:         (*hexutil.Big)(big.NewInt(2000000)),
+		VerificationGasLimit: (*hexutil.Big)(big.NewInt(150000)),
+		PreVerificationGas:   (*hexutil.Big)(big.NewInt(50000)),
+		MaxFeePerGas:         (*hexutil.Big)(big.NewInt(20000000000)),
+		MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(1000000000)),
+		PaymasterAndData:     []byte{},
+	}
+}
+
+func TestUserOperation_Sign(t *testing.T) {
+	kp := secp256k1.Alice()
+	entryPoint := common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	chainID := big.NewInt(1)
+
+	op := newUserOperation()
+	err := op.Sign(entryPoint, chainID, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := op.Hash(entryPoint, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature is excluded from the hash and recovers to the account owner
+	signature := append([]byte{}, op.Signature...)
+	signature[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash(hash.Bytes()), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, kp.CommonAddress(), crypto.PubkeyToAddress(*pub))
+
+	// The hash is bound to the entry point and chain
+	other, err := op.Hash(entryPoint, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, hash, other)
+}
+
+func TestUserOperation_Hash(t *testing.T) {
+	entryPoint := common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	op := newUserOperation()
+
+	hash, err := op.Hash(entryPoint, big.NewInt(1))
+	require.NoError(t, err)
+	assert.Equal(t, "0xdff1603550be4c535c38577eb6015d937db25e430687d30d8006e597cbf7188e", hash.Hex())
+
+	// EntryPoint.getUserOpHash hashes the ABI encoding of the operation, with its dynamic
+	// fields replaced by their hashes, along with the entry point and chain ID
+	word := func(value *big.Int) []byte {
+		return common.LeftPadBytes(value.Bytes(), 32)
+	}
+	var packed []byte
+	packed = append(packed, common.LeftPadBytes(op.Sender.Bytes(), 32)...)
+	packed = append(packed, word(op.Nonce.ToInt())...)
+	packed = append(packed, crypto.Keccak256(op.InitCode)...)
+	packed = append(packed, crypto.Keccak256(op.CallData)...)
+	packed = append(packed, word(op.CallGasLimit.ToInt())...)
+	packed = append(packed, word(op.VerificationGasLimit.ToInt())...)
+	packed = append(packed, word(op.PreVerificationGas.ToInt())...)
+	packed = append(packed, word(op.MaxFeePerGas.ToInt())...)
+	packed = append(packed, word(op.MaxPriorityFeePerGas.ToInt())...)
+	packed = append(packed, crypto.Keccak256(op.PaymasterAndData)...)
+
+	var encoded []byte
+	encoded = append(encoded, crypto.Keccak256(packed)...)
+	encoded = append(encoded, common.LeftPadBytes(entryPoint.Bytes(), 32)...)
+	encoded = append(encoded, word(big.NewInt(1))...)
+	assert.Equal(t, crypto.Keccak256Hash(encoded), hash)
+}
+
+// bundlerService serves eth_sendUserOperation, recording the user operations it accepts
+type bundlerService struct {
+	mutex sync.Mutex
+	ops   []ethereum.UserOperation
+	err   error
+}
+
+func (bs *bundlerService) SendUserOperation(op ethereum.UserOperation, _ common.Address) (common.Hash, error) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.err != nil {
+		return common.Hash{}, bs.err
+	}
+	bs.ops = append(bs.ops, op)
+	return common.BigToHash(big.NewInt(int64(len(bs.ops)))), nil
+}
+
+func (bs *bundlerService) fail(err error) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	bs.err = err
+}
+
+func (bs *bundlerService) nonces() []uint64 {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	nonces := []uint64{}
+	for _, op := range bs.ops {
+		nonces = append(nonces, op.Nonce.ToInt().Uint64())
+	}
+	return nonces
+}
+
+func TestBundler_Submit(t *testing.T) {
+	service := &bundlerService{}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", service))
+	defer server.Stop()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	config := &ethereum.BundlerConfig{
+		Endpoint:   httpServer.URL,
+		EntryPoint: "0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789",
+		Account:    "0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0",
+	}
+	bundler, err := ethereum.NewBundler(config, chain.NewRPCStats(ethereum.Name, config.Endpoint, &chain.RPCConfig{}, logrus.NewEntry(logger)))
+	require.NoError(t, err)
+
+	client := ethereum.NewMockClient(big.NewInt(1))
+	conn := ethereum.NewMockConnection(secp256k1.Alice(), client)
+	entryPoint := common.HexToAddress(config.EntryPoint)
+	setNonce := func(nonce int64) {
+		client.SetCallResult(entryPoint, common.LeftPadBytes(big.NewInt(nonce).Bytes(), 32))
+	}
+	setNonce(7)
+
+	ctx := context.Background()
+	require.NoError(t, bundler.Connect(ctx, conn))
+	defer bundler.Close()
+
+	app := common.HexToAddress("0x0102")
+	maxFee := big.NewInt(30000000000)
+	tip := big.NewInt(2000000000)
+
+	// user operations sent before the previous one is included take the nonces after it
+	for i := 0; i < 2; i++ {
+		_, err = bundler.Submit(ctx, conn, app, []byte{1}, maxFee, tip)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []uint64{7, 8}, service.nonces())
+
+	// the suggested priority fee is offered rather than the whole max fee, and the max fee
+	// without one
+	assert.Equal(t, tip, service.ops[0].MaxPriorityFeePerGas.ToInt())
+	assert.Equal(t, maxFee, service.ops[0].MaxFeePerGas.ToInt())
+	_, err = bundler.Submit(ctx, conn, app, []byte{1}, maxFee, nil)
+	require.NoError(t, err)
+	assert.Equal(t, maxFee, service.ops[2].MaxPriorityFeePerGas.ToInt())
+
+	// nonces are taken from the EntryPoint again after a failure, filling the gap it left
+	service.fail(fmt.Errorf("AA25 invalid account nonce"))
+	_, err = bundler.Submit(ctx, conn, app, []byte{1}, maxFee, tip)
+	assert.Error(t, err)
+	service.fail(nil)
+	setNonce(10)
+	_, err = bundler.Submit(ctx, conn, app, []byte{1}, maxFee, tip)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7, 8, 9, 10}, service.nonces())
+}
+
+func TestUserOperation_MarshalJSON(t *testing.T) {
+	op := newUserOperation()
+
+	encoded, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]string
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "0x7", fields["nonce"])
+	assert.Equal(t, "0x", fields["initCode"])
+	assert.Equal(t, "0xb61d27f6", fields["callData"])
+	assert.Equal(t, "0x1e8480", fields["callGasLimit"])
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Call builds the messages of an app which are delivered as an arbitrary Substrate call.
+// Events without the arguments field are delivered through Bridge.submit as usual.
+type Call struct {
+	name string
+	// events carrying the call arguments, with the position of the arguments among
+	// their non-indexed inputs, by event ID
+	events map[common.Hash]callEvent
+}
+
+type callEvent struct {
+	inputs   abi.Arguments
+	position int
+}
+
+func NewCall(config *CallConfig, contractABI *abi.ABI) (*Call, error) {
+	if len(strings.Split(config.Name, ".")) != 2 {
+		return nil, fmt.Errorf("call %q is not of the form Module.call", config.Name)
+	}
+
+	field := config.Field
+	if field == "" {
+		field = defaultCallArgsField
+	}
+
+	events := make(map[common.Hash]callEvent)
+	for _, event := range contractABI.Events {
+		inputs := event.Inputs.NonIndexed()
+		for i, input := range inputs {
+			if input.Name == field && input.Type.T == abi.BytesTy {
+				events[event.ID] = callEvent{inputs: inputs, position: i}
+			}
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no event has a bytes argument named %s", field)
+	}
+
+	return &Call{name: config.Name, events: events}, nil
+}
+
+// Message returns the message making the call for an event, or nil if the event
+// does not carry call arguments
+func (c *Call) Message(event *gethTypes.Log) (*chain.Message, error) {
+	if len(event.Topics) == 0 {
+		return nil, nil
+	}
+
+	ev, ok := c.events[event.Topics[0]]
+	if !ok {
+		return nil, nil
+	}
+
+	values, err := ev.inputs.UnpackValues(event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	args, ok := values[ev.position].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("call arguments are not bytes")
+	}
+
+	return &chain.Message{AppID: event.Address, Payload: chain.Call{Name: c.name, Args: args}, SourceBlock: event.BlockNumber}, nil
+}
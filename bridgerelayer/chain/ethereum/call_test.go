@@ -0,0 +1,75 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+const testCallABI = `[
+	{"type": "event", "name": "Dispatch", "anonymous": false, "inputs": [
+		{"indexed": true, "name": "_sender", "type": "address"},
+		{"indexed": false, "name": "_nonce", "type": "uint64"},
+		{"indexed": false, "name": "_args", "type": "bytes"}
+	]},
+	{"type": "event", "name": "Deposit", "anonymous": false, "inputs": [
+		{"indexed": false, "name": "_amount", "type": "uint256"}
+	]}
+]`
+
+func TestCall_Message(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testCallABI))
+	require.NoError(t, err)
+
+	call, err := NewCall(&CallConfig{Name: "Assets.mint"}, &contractABI)
+	require.NoError(t, err)
+
+	args := []byte{0x04, 0x01, 0x02}
+	data, err := contractABI.Events["Dispatch"].Inputs.NonIndexed().Pack(uint64(7), args)
+	require.NoError(t, err)
+
+	address := common.HexToAddress("0x01")
+	event := types.Log{
+		Address: address,
+		Topics:  []common.Hash{contractABI.Events["Dispatch"].ID, common.HexToHash("0x02")},
+		Data:    data,
+	}
+
+	msg, err := call.Message(&event)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.Equal(t, [20]byte(address), msg.AppID)
+	assert.Equal(t, chain.Call{Name: "Assets.mint", Args: args}, msg.Payload)
+
+	// events without call arguments are not calls
+	deposit := types.Log{Topics: []common.Hash{contractABI.Events["Deposit"].ID}, Data: make([]byte, 32)}
+	msg, err = call.Message(&deposit)
+	require.NoError(t, err)
+	assert.Nil(t, msg)
+
+	// malformed event data is an error
+	event.Data = data[:40]
+	_, err = call.Message(&event)
+	assert.Error(t, err)
+}
+
+func TestNewCall(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testCallABI))
+	require.NoError(t, err)
+
+	_, err = NewCall(&CallConfig{Name: "mint"}, &contractABI)
+	assert.Error(t, err)
+
+	_, err = NewCall(&CallConfig{Name: "Assets.mint", Field: "_amount"}, &contractABI)
+	assert.Error(t, err)
+}
@@ -5,10 +5,14 @@ package ethereum
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/ethereum/go-ethereum/common"
+
 	"github.com/sirupsen/logrus"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
 )
@@ -18,13 +22,99 @@ type Chain struct {
 	config   *Config
 	listener *Listener
 	writer   *Writer
-	conn     *Connection
+	drift    *DriftDetector
+	pause    *PauseWatcher
+	conn     Connection
+	// connection of the writer, the same as conn unless submissions have their own endpoint
+	submit Connection
+	// divergence monitor, nil if disabled, and the further endpoints it connects to
+	divergence *chain.DivergenceMonitor
+	references []Connection
 }
 
 const Name = "Ethereum"
 
+// the components of the chain implement the interfaces through which the relayer drives chains
+var (
+	_ chain.Chain    = &Chain{}
+	_ chain.Listener = &Listener{}
+	_ chain.Writer   = &Writer{}
+)
+
+// checkpointReorgDepth is the number of blocks kept between the head and the advancing checkpoint,
+// as Ethereum blocks are only probabilistically final
+const checkpointReorgDepth = 64
+
 // NewChain initializes a new instance of EthChain
-func NewChain(config *Config, ethMessages chan chain.Message, subMessages chan chain.Message) (*Chain, error) {
+func NewChain(config *Config, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
+	log := logrus.WithField("chain", Name)
+
+	kp, err := LoadKeypair(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, submit, err := newConnections(config, kp, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewChainWithConnections(config, conn, submit, ethMessages, subMessages, services)
+}
+
+// newConnections creates the connection through which the chain is read, and that through
+// which transactions are submitted, which is the same unless submissions have their own endpoint
+func newConnections(config *Config, kp *secp256k1.Keypair, log *logrus.Entry) (Connection, Connection, error) {
+	dialer, err := chain.NewDialer(&config.RPC.Dial)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conn Connection
+	if len(config.FallbackEndpoints) > 0 {
+		failover := NewFailoverConnection(append([]string{config.Endpoint}, config.FallbackEndpoints...), kp, &config.RPC, log)
+		failover.DialThrough(dialer)
+		conn = failover
+	} else {
+		rpcConn := NewConnection(config.Endpoint, kp, chain.NewRPCStats(Name, config.Endpoint, &config.RPC, log), log)
+		rpcConn.DialThrough(dialer)
+		conn = rpcConn
+	}
+
+	var submit Connection = conn
+	if !config.ReadOnly && config.SubmitEndpoint != "" && config.SubmitEndpoint != config.Endpoint {
+		submitConn := NewConnection(config.SubmitEndpoint, kp, chain.NewRPCStats(Name, config.SubmitEndpoint, &config.RPC, log), log)
+		submitConn.DialThrough(dialer)
+		submit = submitConn
+	}
+
+	return conn, submit, nil
+}
+
+// LoadKeypair loads the relayer key, which is replaced by a throwaway key if the chain
+// is read-only and no key was given, or by the address of the account if its key is held
+// by a remote signer
+func LoadKeypair(config *Config) (*secp256k1.Keypair, error) {
+	if config.RemoteSigner != nil {
+		if !common.IsHexAddress(config.RemoteSigner.Address) {
+			return nil, fmt.Errorf("invalid address of remote signer account: %s", config.RemoteSigner.Address)
+		}
+		return secp256k1.NewAddressKeypair(common.HexToAddress(config.RemoteSigner.Address)), nil
+	}
+	if config.ReadOnly && config.PrivateKey == "" {
+		return secp256k1.GenerateKeypair()
+	}
+	return secp256k1.NewKeypairFromString(config.PrivateKey)
+}
+
+// NewChainWithConnection initializes a chain whose components share the given connection
+func NewChainWithConnection(config *Config, conn Connection, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
+	return NewChainWithConnections(config, conn, conn, ethMessages, subMessages, services)
+}
+
+// NewChainWithConnections initializes a chain whose writer submits transactions through
+// its own connection, while the other components read the chain through conn
+func NewChainWithConnections(config *Config, conn Connection, submit Connection, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
 	log := logrus.WithField("chain", Name)
 
 	contracts, err := LoadContracts(config)
@@ -32,28 +122,72 @@ func NewChain(config *Config, ethMessages chan chain.Message, subMessages chan c
 		return nil, err
 	}
 
-	kp, err := secp256k1.NewKeypairFromString(config.PrivateKey)
+	err = chain.SeedCursor(services.Cursors, Name, config.StartBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpointConfig, err := chain.LoadCheckpoint(&config.Checkpoint, services.Checkpoints, Name)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := chain.NewCheckpoint(checkpointConfig, checkpointReorgDepth)
 	if err != nil {
 		return nil, err
 	}
 
-	conn := NewConnection(config.Endpoint, kp, log)
+	listener, err := NewListener(conn, ethMessages, services.Quarantine, services.Blocks, chain.NewClockMonitor(&config.Clock, log), chain.NewBackoff(Name, &config.RPC.Backoff), checkpoint, services.Checkpoints, services.Cursors, services.Events, services.ConsumerStopped, services.Invalidated, &config.Finality, contracts, log)
+	if err != nil {
+		return nil, err
+	}
 
-	listener, err := NewListener(conn, ethMessages, contracts, log)
+	overrides, err := chain.NewOverrides(config.Overrides)
 	if err != nil {
 		return nil, err
 	}
+	listener.Override(overrides)
 
-	writer, err := NewWriter(conn, subMessages, log)
+	writer, err := NewWriter(config, submit, subMessages, services.Receipts, services.Pricer, services.Skipped, log)
 	if err != nil {
 		return nil, err
 	}
+	writer.DivertFailures(services.DeadLetters)
+	writer.ReportBackpressure(services.Diagnostics)
+	writer.RecordIntents(Name, services.Intents)
+
+	var drift *DriftDetector
+	if config.DriftCheckInterval > 0 {
+		drift = NewDriftDetector(config, conn, contracts, log)
+	}
+
+	var pause *PauseWatcher
+	if config.Pause.Interval > 0 && !config.ReadOnly {
+		pause = NewPauseWatcher(&config.Pause, conn, contracts, writer.Gate(), log)
+	}
+
+	var divergence *chain.DivergenceMonitor
+	var references []Connection
+	if config.Divergence.Interval > 0 {
+		dialer, err := chain.NewDialer(&config.RPC.Dial)
+		if err != nil {
+			return nil, err
+		}
+		var sources []chain.HeadSource
+		sources, references = headSources(config, conn, submit, dialer, log)
+		divergence = chain.NewDivergenceMonitor(Name, &config.Divergence, sources, log)
+	}
 
 	return &Chain{
-		config:   config,
-		listener: listener,
-		writer:   writer,
-		conn:     conn,
+		config:     config,
+		divergence: divergence,
+		references: references,
+		listener:   listener,
+		writer:     writer,
+		drift:      drift,
+		pause:      pause,
+		conn:       conn,
+		submit:     submit,
 	}, nil
 }
 
@@ -63,14 +197,52 @@ func (ch *Chain) Start(ctx context.Context, eg *errgroup.Group) error {
 		return err
 	}
 
-	err = ch.listener.Start(ctx, eg)
-	if err != nil {
-		return err
+	if ch.submit != ch.conn {
+		err = ch.submit.Connect(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = ch.writer.Start(ctx, eg)
-	if err != nil {
-		return err
+	// nothing is relayed to contracts expecting payloads of another schema
+	if ch.config.Handshake.Enabled {
+		_, err = ch.Handshake(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.config.WriteOnly {
+		logrus.WithField("chain", Name).Info("Listener is disabled in write-only mode")
+	} else {
+		err = ch.listener.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check the pause state before the writer submits anything
+	if ch.pause != nil {
+		err = ch.pause.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.config.ReadOnly {
+		logrus.WithField("chain", Name).Info("Writer is disabled in read-only mode")
+	} else {
+		err = ch.writer.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.drift != nil {
+		err = ch.drift.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -80,8 +252,125 @@ func (ch *Chain) Stop() {
 	if ch.conn != nil {
 		ch.conn.Close()
 	}
+	if ch.submit != nil && ch.submit != ch.conn {
+		ch.submit.Close()
+	}
+	for _, reference := range ch.references {
+		reference.Close()
+	}
 }
 
 func (ch *Chain) Name() string {
 	return Name
 }
+
+// Divergence returns the monitor comparing the heads served by the endpoints of the chain,
+// nil if disabled
+func (ch *Chain) Divergence() *chain.DivergenceMonitor {
+	return ch.divergence
+}
+
+// WriterGate returns the gate through which submissions to this chain can be halted
+func (ch *Chain) WriterGate() *chain.Gate {
+	return ch.writer.Gate()
+}
+
+// AccountNonces returns the nonce of each account from which the writer sends transactions,
+// none if the chain is read-only
+func (ch *Chain) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	if ch.config.ReadOnly {
+		return nil, nil
+	}
+	return ch.writer.AccountNonces(ctx)
+}
+
+// LastSubmission returns when the writer last completed a submission, zero if it never did
+func (ch *Chain) LastSubmission() time.Time {
+	return ch.writer.LastSubmission()
+}
+
+// BalanceInterval returns the interval between checks of the balances of the accounts
+// paying for the submissions of the writer, zero if they aren't monitored or the chain is
+// read-only
+func (ch *Chain) BalanceInterval() time.Duration {
+	if ch.config.ReadOnly {
+		return 0
+	}
+	return ch.writer.BalanceInterval()
+}
+
+// CheckBalances checks the balances of the accounts paying for the submissions of the writer
+func (ch *Chain) CheckBalances(ctx context.Context) error {
+	return ch.writer.CheckBalances(ctx)
+}
+
+// RPCStats returns the call statistics of each endpoint used by this chain
+func (ch *Chain) RPCStats() []*chain.RPCStats {
+	stats := []*chain.RPCStats{ch.conn.Stats()}
+	if ch.submit != ch.conn {
+		stats = append(stats, ch.submit.Stats())
+	}
+	if bundler := ch.writer.Bundler(); bundler != nil {
+		stats = append(stats, bundler.Stats())
+	}
+	return stats
+}
+
+// WriterQueues returns the messages pending submission to each app on this chain
+func (ch *Chain) WriterQueues() []chain.QueueStats {
+	return ch.writer.Queues()
+}
+
+// Boost escalates the submission of a message queued in the writer
+func (ch *Chain) Boost(id string) (*chain.Boost, error) {
+	return ch.writer.Boost(id)
+}
+
+// Progress returns the block processing progress of this chain's listener
+func (ch *Chain) Progress() *chain.Progress {
+	return ch.listener.Progress()
+}
+
+// Repair reprocesses a range of blocks which were skipped by the listener
+func (ch *Chain) Repair(ctx context.Context, from uint64, to uint64) error {
+	return ch.listener.Repair(ctx, from, to)
+}
+
+// Replay re-emits the messages of the events of a range of blocks, which defaults to the
+// configured range, without moving the cursor of the listener. Returns the replayed range.
+func (ch *Chain) Replay(ctx context.Context, from uint64, to uint64) (uint64, uint64, error) {
+	if from == 0 {
+		from = ch.config.StartBlock
+	}
+	if to == 0 {
+		to = ch.config.EndBlock
+	}
+	if from == 0 {
+		return 0, 0, fmt.Errorf("no start block given for the replay of %s", Name)
+	}
+
+	last, err := ch.listener.Replay(ctx, from, to)
+	return from, last, err
+}
+
+// CanonicalBlock returns the number of the block with a hash, verifying that it is on the
+// canonical chain
+func (ch *Chain) CanonicalBlock(ctx context.Context, hash string) (uint64, error) {
+	header, err := ch.conn.Client().HeaderByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		return 0, fmt.Errorf("block %s: %w", hash, err)
+	}
+
+	canonical, err := ch.conn.Client().HeaderByNumber(ctx, header.Number)
+	if err != nil {
+		return 0, err
+	}
+
+	number := header.Number.Uint64()
+	return number, chain.VerifyCanonical(hash, number, canonical.Hash().Hex())
+}
+
+// EventMessage rebuilds the message which the listener generates for an event of a block
+func (ch *Chain) EventMessage(ctx context.Context, number uint64, index uint64) (*chain.Message, error) {
+	return ch.listener.EventMessage(ctx, number, index)
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestNewChainWithConnections(t *testing.T) {
+	kp := secp256k1.Alice()
+	read := NewMockConnection(kp, NewMockClient(big.NewInt(1)))
+	submit := NewMockConnection(kp, NewMockClient(big.NewInt(1)))
+
+	ch, err := NewChainWithConnections(&Config{}, read, submit, make(chan chain.Message), make(chan chain.Message), &chain.Services{})
+	require.NoError(t, err)
+
+	// only the writer submits through the submission connection
+	assert.Equal(t, Connection(read), ch.listener.conn)
+	assert.Equal(t, Connection(submit), ch.writer.conn)
+	assert.Len(t, ch.RPCStats(), 2)
+
+	ch, err = NewChainWithConnection(&Config{}, read, make(chan chain.Message), make(chan chain.Message), &chain.Services{})
+	require.NoError(t, err)
+	assert.Equal(t, Connection(read), ch.writer.conn)
+	assert.Len(t, ch.RPCStats(), 1)
+}
+
+func TestNewChain_ReadOnly(t *testing.T) {
+	config := &Config{
+		Endpoint:       "ws://localhost:8545",
+		SubmitEndpoint: "ws://localhost:8546",
+		Pause:          PauseConfig{Interval: 10},
+		ReadOnly:       true,
+	}
+
+	// read-only chains need no key, and don't connect to the submission endpoint
+	ch, err := NewChain(config, make(chan chain.Message), make(chan chain.Message), &chain.Services{})
+	require.NoError(t, err)
+	assert.Equal(t, ch.conn, ch.submit)
+	assert.Nil(t, ch.pause)
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Client is the part of the Ethereum JSON-RPC API used by the relayer
+type Client interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	NetworkID(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, number *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	// FeeHistory samples the base fees and priority fees of the latest blocks
+	FeeHistory(ctx context.Context, blocks uint64, percentiles []float64) (*FeeHistory, error)
+	// SendRawTransaction sends an encoded transaction, such as a dynamic fee transaction
+	// which geth can't represent
+	SendRawTransaction(ctx context.Context, raw []byte) error
+	// EffectiveGasPrice returns the gas price paid by an included transaction, nil if the
+	// node doesn't report it
+	EffectiveGasPrice(ctx context.Context, hash common.Hash) (*big.Int, error)
+	Close()
+}
+
+// instrumentedClient is an ethclient.Client which records statistics of the calls made by the
+// relayer. Calls are recorded under the name of the JSON-RPC method they are made with.
+// Methods which ethclient doesn't wrap are called through rpc.
+type instrumentedClient struct {
+	*ethclient.Client
+	rpc   *rpc.Client
+	stats *chain.RPCStats
+}
+
+func (cl *instrumentedClient) ChainID(ctx context.Context) (*big.Int, error) {
+	done := cl.stats.Start("eth_chainId")
+	id, err := cl.Client.ChainID(ctx)
+	done(err)
+	return id, err
+}
+
+func (cl *instrumentedClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	done := cl.stats.Start("net_version")
+	id, err := cl.Client.NetworkID(ctx)
+	done(err)
+	return id, err
+}
+
+func (cl *instrumentedClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	done := cl.stats.Start("eth_getBlockByNumber", number)
+	header, err := cl.Client.HeaderByNumber(ctx, number)
+	done(err)
+	return header, err
+}
+
+func (cl *instrumentedClient) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	done := cl.stats.Start("eth_getBlockByHash", hash.Hex())
+	header, err := cl.Client.HeaderByHash(ctx, hash)
+	done(err)
+	return header, err
+}
+
+func (cl *instrumentedClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	done := cl.stats.Start("eth_gasPrice")
+	price, err := cl.Client.SuggestGasPrice(ctx)
+	done(err)
+	return price, err
+}
+
+func (cl *instrumentedClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	done := cl.stats.Start("eth_getTransactionCount", account.Hex(), "pending")
+	nonce, err := cl.Client.PendingNonceAt(ctx, account)
+	done(err)
+	return nonce, err
+}
+
+func (cl *instrumentedClient) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	done := cl.stats.Start("eth_getBalance", account.Hex(), number)
+	balance, err := cl.Client.BalanceAt(ctx, account, number)
+	done(err)
+	return balance, err
+}
+
+func (cl *instrumentedClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	done := cl.stats.Start("eth_sendRawTransaction", tx.Hash().Hex())
+	err := cl.Client.SendTransaction(ctx, tx)
+	done(err)
+	return err
+}
+
+func (cl *instrumentedClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	done := cl.stats.Start("eth_getTransactionReceipt", hash.Hex())
+	receipt, err := cl.Client.TransactionReceipt(ctx, hash)
+	// pending transactions are not failures of the endpoint
+	if err == ethereum.NotFound {
+		done(nil)
+	} else {
+		done(err)
+	}
+	return receipt, err
+}
+
+func (cl *instrumentedClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	done := cl.stats.Start("eth_getTransactionByHash", hash.Hex())
+	tx, pending, err := cl.Client.TransactionByHash(ctx, hash)
+	done(err)
+	return tx, pending, err
+}
+
+func (cl *instrumentedClient) CallContract(ctx context.Context, msg ethereum.CallMsg, number *big.Int) ([]byte, error) {
+	done := cl.stats.Start("eth_call", msg.To, number)
+	result, err := cl.Client.CallContract(ctx, msg, number)
+	done(err)
+	return result, err
+}
+
+func (cl *instrumentedClient) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	done := cl.stats.Start("eth_estimateGas", msg.To)
+	gas, err := cl.Client.EstimateGas(ctx, msg)
+	done(err)
+	return gas, err
+}
+
+func (cl *instrumentedClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	done := cl.stats.Start("eth_getLogs", query.FromBlock, query.ToBlock, query.Addresses)
+	logs, err := cl.Client.FilterLogs(ctx, query)
+	done(err)
+	return logs, err
+}
+
+func (cl *instrumentedClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	done := cl.stats.Start("eth_subscribe", "logs", query.Addresses)
+	sub, err := cl.Client.SubscribeFilterLogs(ctx, query, ch)
+	done(err)
+	return sub, err
+}
+
+func (cl *instrumentedClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	done := cl.stats.Start("eth_subscribe", "newHeads")
+	sub, err := cl.Client.SubscribeNewHead(ctx, ch)
+	done(err)
+	return sub, err
+}
+
+func (cl *instrumentedClient) FeeHistory(ctx context.Context, blocks uint64, percentiles []float64) (*FeeHistory, error) {
+	done := cl.stats.Start("eth_feeHistory", blocks)
+	var history FeeHistory
+	err := cl.rpc.CallContext(ctx, &history, "eth_feeHistory", hexutil.Uint64(blocks), "latest", percentiles)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+func (cl *instrumentedClient) SendRawTransaction(ctx context.Context, raw []byte) error {
+	done := cl.stats.Start("eth_sendRawTransaction")
+	err := cl.rpc.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Encode(raw))
+	done(err)
+	return err
+}
+
+func (cl *instrumentedClient) EffectiveGasPrice(ctx context.Context, hash common.Hash) (*big.Int, error) {
+	done := cl.stats.Start("eth_getTransactionReceipt", hash.Hex())
+	var receipt *struct {
+		EffectiveGasPrice *hexutil.Big `json:"effectiveGasPrice"`
+	}
+	err := cl.rpc.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	if receipt.EffectiveGasPrice == nil {
+		return nil, nil
+	}
+	return receipt.EffectiveGasPrice.ToInt(), nil
+}
@@ -1,12 +1,174 @@
 package ethereum
 
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
 type Config struct {
 	Endpoint   string                 `mapstructure:"endpoint"`
 	PrivateKey string                 `mapstructure:"private-key"`
+	SponsorKey string                 `mapstructure:"sponsor-key"`
 	Apps       map[string]Application `mapstructure:"apps"`
+	Bundler    *BundlerConfig         `mapstructure:"bundler"`
+	// Signs the transactions of the writer with an external signer holding the key, in
+	// which case PrivateKey is ignored
+	RemoteSigner *RemoteSignerConfig `mapstructure:"remote-signer"`
+	// Endpoints serving the same network as Endpoint, in order of preference, to which the
+	// relayer fails over when Endpoint fails, and from which it fails back once Endpoint
+	// recovers. Submissions fail over too unless SubmitEndpoint is set.
+	FallbackEndpoints []string `mapstructure:"fallback-endpoints"`
+	// Endpoint through which transactions are submitted, while all other calls go to
+	// Endpoint. Endpoint is used for submissions too if empty.
+	SubmitEndpoint string `mapstructure:"submit-endpoint"`
+	// Comparison of the heads served by the endpoints of the chain
+	Divergence chain.DivergenceConfig `mapstructure:"divergence"`
+	// Interval in seconds between event signature drift checks. Zero disables them.
+	DriftCheckInterval uint64 `mapstructure:"drift-check-interval"`
+	// Number of recent blocks scanned by each drift check
+	DriftCheckBlocks uint64            `mapstructure:"drift-check-blocks"`
+	Clock            chain.ClockConfig `mapstructure:"clock"`
+	Pause            PauseConfig       `mapstructure:"pause"`
+	RPC              chain.RPCConfig   `mapstructure:"rpc"`
+	// Trusted block which all relayed history must descend from. Disabled if the hash is empty.
+	Checkpoint chain.CheckpointConfig `mapstructure:"checkpoint"`
+	// Percentage added to the suggested gas price of transactions escalated to meet the
+	// latency budget of their message. Zero disables the bump.
+	EscalationFeeBump uint64 `mapstructure:"escalation-fee-bump"`
+	// Tuning of the gas price toward a target inclusion delay
+	FeeTuning FeeTuningConfig `mapstructure:"fee-tuning"`
+	// Retries of deliveries which reverted, once a gas-free call no longer reverts
+	Retry RetryConfig `mapstructure:"retry"`
+	// Tracking of deliveries until their confirmation depth, and rebroadcasts of those
+	// which are stuck in the mempool
+	Confirmations ConfirmationConfig `mapstructure:"confirmations"`
+	// Pricing of deliveries as EIP-1559 transactions, from the fees of recent blocks
+	DynamicFees DynamicFeeConfig `mapstructure:"dynamic-fees"`
+	// Confirmations which events must have before they are relayed, by network
+	Finality FinalityConfig `mapstructure:"finality"`
+	// Assignment of transaction nonces from a local counter per account
+	Nonces NonceConfig `mapstructure:"nonces"`
+	// Cap on the estimated cost of each delivered message
+	CostCap CostCapConfig `mapstructure:"cost-cap"`
+	// Monitoring of the balances of the accounts paying for transactions
+	Balance chain.BalanceConfig `mapstructure:"balance"`
+	// Tuning of the deliveries pending inclusion and their rate to the capacity of the chain
+	AutoTune chain.AutoTuneConfig `mapstructure:"auto-tune"`
+	// Capture of the state of the writer once its queues back up
+	Diagnostics chain.DiagnosticsConfig `mapstructure:"diagnostics"`
+	// Negotiation of the payload schema version with the app contracts at startup
+	Handshake chain.HandshakeConfig `mapstructure:"handshake"`
+	// Block from which the listener starts if it has no recorded cursor, and the first block
+	// replayed by default. The listener starts from the latest block if zero.
+	StartBlock uint64 `mapstructure:"start-block"`
+	// Last block replayed by default, the latest block if zero
+	EndBlock uint64 `mapstructure:"end-block"`
+	// Routing overrides of the messages to their recipients, copied from the overrides
+	// section of the configuration
+	Overrides []chain.OverrideConfig
+	// Whether the writer is disabled, so that nothing is submitted and no key is needed.
+	// Set in explorer mode.
+	ReadOnly bool
+	// Whether the listener is disabled, so that no events of the chain are relayed. Set when
+	// only the direction to the chain is relayed.
+	WriteOnly bool
+}
+
+// PauseConfig enables halting the writer while any app contract reports paused()
+type PauseConfig struct {
+	// Interval in seconds between checks. Zero disables the watcher.
+	Interval uint64 `mapstructure:"interval"`
 }
 
 type Application struct {
-	Address string `mapstructure:"address"`
-	AbiPath string `mapstructure:"abi"`
+	Address   string           `mapstructure:"address"`
+	AbiPath   string           `mapstructure:"abi"`
+	Forwarder *ForwarderConfig `mapstructure:"forwarder"`
+	Limits    Limits           `mapstructure:"limits"`
+	// Derivation of Substrate recipients from Ethereum addresses. Disabled if unset.
+	Derivation *DerivationConfig `mapstructure:"derive-recipient"`
+	// Bounds on the submission of messages to the app. Unthrottled apps share a single lane.
+	Throttle *chain.ThrottleConfig `mapstructure:"throttle"`
+	// Latency budget of the app's messages, measured from when their event was observed
+	Budget *chain.BudgetConfig `mapstructure:"budget"`
+	// Batching of the app's messages into calls of its submitBatch entry point. Messages
+	// are submitted one per transaction if unset.
+	Batch *BatchConfig `mapstructure:"batch"`
+	// Delivery of the app's events as an arbitrary Substrate call, instead of Bridge.submit.
+	// Disabled if unset.
+	Call *CallConfig `mapstructure:"call"`
+	// Directions in which the app's messages are not relayed, ethereum-to-substrate or
+	// substrate-to-ethereum. They can be enabled at runtime through the admin API.
+	DisabledDirections []string `mapstructure:"disabled-directions"`
+	// Whether each of the app's messages carries a nonce, so that a message seen again is
+	// always a duplicate and is suppressed permanently. Messages of other apps are only
+	// suppressed within the duplicate window.
+	Nonced bool `mapstructure:"nonced"`
+}
+
+const (
+	defaultRecipientField = "_recipient"
+	defaultCallArgsField  = "_args"
+)
+
+const (
+	// FieldPolicyQuarantine quarantines events with oversized arguments
+	FieldPolicyQuarantine = "quarantine"
+	// FieldPolicyTruncate truncates oversized arguments before the event is relayed
+	FieldPolicyTruncate = "truncate"
+)
+
+// DerivationConfig selects how the recipient of an app's events is derived
+type DerivationConfig struct {
+	// Name of the derivation scheme, such as evm-hashed or zero-padded
+	Scheme string `mapstructure:"scheme"`
+	// Name of the bytes32 event argument holding the recipient, "_recipient" by default
+	Field string `mapstructure:"field"`
+}
+
+// CallConfig selects the Substrate call made for an app's events, whose arguments are
+// taken from the events already SCALE-encoded
+type CallConfig struct {
+	// Module and call, for example Assets.mint, resolved through the runtime metadata
+	Name string `mapstructure:"name"`
+	// Name of the bytes event argument holding the encoded call arguments, "_args" by default
+	Field string `mapstructure:"field"`
+}
+
+// Limits bound the events of an app which are accepted for relaying, so that
+// messages exceeding the limits of Substrate are rejected before being queued.
+// Zero values disable a limit.
+type Limits struct {
+	// Maximum size in bytes of the SCALE-encoded message payload
+	MaxPayloadSize int `mapstructure:"max-payload-size"`
+	// Maximum size in bytes of the event's data
+	MaxDataSize int `mapstructure:"max-data-size"`
+	// Maximum number of event topics
+	MaxTopics int `mapstructure:"max-topics"`
+	// Maximum sizes in bytes of unbounded string or bytes event arguments, such as memos
+	// or URIs, by argument name
+	MaxFieldSizes map[string]int `mapstructure:"max-field-sizes"`
+	// Handling of events whose arguments exceed their size, quarantine by default or truncate
+	FieldPolicy string `mapstructure:"field-policy"`
+}
+
+// ForwarderConfig enables gas-sponsored delivery through an EIP-2771 forwarder
+type ForwarderConfig struct {
+	Address       string `mapstructure:"address"`
+	DomainName    string `mapstructure:"domain-name"`
+	DomainVersion string `mapstructure:"domain-version"`
+}
+
+// BundlerConfig enables delivery as ERC-4337 user operations from a smart account
+// owned by the relayer key. Gas limits default to conservative values when unset.
+type BundlerConfig struct {
+	Endpoint             string `mapstructure:"endpoint"`
+	EntryPoint           string `mapstructure:"entry-point"`
+	Account              string `mapstructure:"account"`
+	CallGasLimit         uint64 `mapstructure:"call-gas-limit"`
+	VerificationGasLimit uint64 `mapstructure:"verification-gas-limit"`
+	PreVerificationGas   uint64 `mapstructure:"pre-verification-gas"`
+	PaymasterAndData     string `mapstructure:"paymaster-and-data"`
+	// Max priority fee per gas in wei offered by user operations. Taken from the priority
+	// fees of recent blocks if empty, or the whole gas price on chains without a base fee.
+	MaxPriorityFeePerGas string `mapstructure:"max-priority-fee-per-gas"`
 }
@@ -0,0 +1,234 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+const (
+	// interval between receipt lookups of a submitted message
+	confirmPollInterval = 5 * time.Second
+	// time after which the writer stops waiting for a submission to be included
+	confirmTimeout = 30 * time.Minute
+)
+
+// confirm waits for a submitted transaction or user operation to be included in a block and
+// buried under the confirmation depth, reports the successful delivery to the receipt log and
+// tunes the gas price and throughput by its delay. Transactions which are stuck in the
+// mempool are rebroadcast with a bumped gas price while waiting, and reverted deliveries are
+// retried or skipped, given the checks already made of earlier reverts.
+func (wr *Writer) confirm(parent context.Context, msg chain.Message, receipt chain.Receipt, hash common.Hash, checks int) {
+	ctx, cancel := context.WithTimeout(parent, confirmTimeout)
+	defer cancel()
+	defer wr.pending.remove(hash)
+
+	log := wr.log.WithFields(logrus.Fields{
+		"hash":     receipt.Hash,
+		"sequence": msg.Sequence,
+	})
+
+	result, fee, ok := wr.await(ctx, hash, receipt.SubmittedAt, log)
+	if !ok {
+		// a user operation or forwarded request which was never included leaves a gap in
+		// the nonces of its signer
+		if wr.bundler != nil && parent.Err() == nil {
+			wr.bundler.ResetNonce()
+		}
+		wr.settleForwarded(hash, false)
+		return
+	}
+
+	if result.Status != types.ReceiptStatusSuccessful {
+		metrics.TransactionsFailed.WithLabelValues(Name, wr.app(&msg)).Inc()
+		log.WithField("blockNumber", result.BlockNumber).Error("Delivery failed on-chain")
+		wr.settleForwarded(hash, false)
+		cancel()
+		wr.retry(parent, msg, checks, result.BlockNumber)
+		return
+	}
+	wr.settleForwarded(hash, true)
+
+	wr.settle(ctx, &msg, &receipt, result, hash, fee, result.GasUsed)
+	log.WithFields(logrus.Fields{
+		"blockNumber": receipt.BlockNumber,
+		"blockHash":   receipt.BlockHash,
+	}).Info("Delivery confirmed")
+
+	wr.tuner.Observe(receipt.ConfirmedAt.Sub(receipt.SubmittedAt))
+}
+
+// await waits for a submission to be included in a block, rebroadcasting it while it is
+// stuck in the mempool, and for a successful one to be buried under the confirmation depth.
+// Returns its receipt and the fee paid for it, or false if the context ended first.
+func (wr *Writer) await(ctx context.Context, hash common.Hash, submittedAt time.Time, log *logrus.Entry) (*types.Receipt, *big.Int, bool) {
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+
+	included := false
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				log.Warn("Gave up waiting for delivery to be confirmed")
+				wr.throughput.Dropped()
+			} else {
+				wr.throughput.Forget()
+			}
+			return nil, nil, false
+		case <-ticker.C:
+			result, fee, err := wr.fetchReceipt(ctx, hash)
+			if err != nil {
+				log.WithError(err).Debug("Failed to fetch receipt")
+				continue
+			}
+			if result == nil {
+				wr.rebroadcast(ctx, hash, log)
+				continue
+			}
+
+			// inclusion is observed once, even if the delivery is then reorganized out
+			if !included {
+				included = true
+				wr.throughput.Included(time.Since(submittedAt))
+				if wr.throughput.Enabled() {
+					wr.updateCapacity(ctx, result.BlockNumber)
+				}
+			}
+
+			if result.Status != types.ReceiptStatusSuccessful {
+				return result, fee, true
+			}
+
+			if !wr.confirmed(ctx, result.BlockNumber, log) {
+				continue
+			}
+			return result, fee, true
+		}
+	}
+}
+
+// settle reports the confirmed delivery of a message to the receipt log, with its share of
+// the fee and of the gas used by the submission
+func (wr *Writer) settle(ctx context.Context, msg *chain.Message, receipt *chain.Receipt, result *types.Receipt, hash common.Hash, fee *big.Int, gasUsed uint64) {
+	// the delivery may have been included by a rebroadcast of the transaction
+	if gasPrice := wr.pending.gasPrice(hash); gasPrice != nil {
+		receipt.MaxFeePerGas = gasPrice.String()
+	}
+	receipt.Hash = result.TxHash.Hex()
+
+	metrics.TransactionsConfirmed.WithLabelValues(Name, wr.app(msg)).Inc()
+	receipt.Confirm(result.BlockNumber.Uint64(), result.BlockHash.Hex())
+	if fee != nil {
+		err := receipt.Charge(ctx, wr.pricer, fee)
+		if err != nil {
+			wr.log.WithError(err).WithField("hash", receipt.Hash).Warn("Failed to convert delivery fee")
+		}
+		recordGasPrice(receipt, fee, gasUsed)
+	}
+
+	if wr.receipts != nil {
+		wr.receipts.Confirmed(msg, receipt)
+	}
+}
+
+// updateCapacity caps the deliveries pending inclusion at those whose gas limit fits in the
+// block share of the gas limit of a recent block
+func (wr *Writer) updateCapacity(ctx context.Context, number *big.Int) {
+	header, err := wr.conn.Client().HeaderByNumber(ctx, number)
+	if err != nil {
+		wr.log.WithError(err).Debug("Failed to fetch block gas limit")
+		return
+	}
+	wr.throughput.SetBlockCapacity(header.GasLimit, gasLimit)
+}
+
+// fetchReceipt returns the receipt of a submission and the fee paid for it in wei, or nil
+// if it was not included yet. Transactions are looked up by the hashes of all their
+// broadcasts. The fee is nil if it could not be determined.
+func (wr *Writer) fetchReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, *big.Int, error) {
+	if wr.bundler == nil {
+		for _, broadcast := range wr.pending.hashes(hash) {
+			receipt, err := wr.conn.Client().TransactionReceipt(ctx, broadcast)
+			if err == ethereum.NotFound {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			return receipt, wr.transactionFee(ctx, receipt), nil
+		}
+		return nil, nil, nil
+	}
+
+	opReceipt, err := wr.bundler.Receipt(ctx, hash)
+	if err != nil || opReceipt == nil {
+		return nil, nil, err
+	}
+
+	receipt := &types.Receipt{
+		Status:      types.ReceiptStatusFailed,
+		TxHash:      opReceipt.Receipt.TransactionHash,
+		BlockHash:   opReceipt.Receipt.BlockHash,
+		BlockNumber: new(big.Int).SetUint64(uint64(opReceipt.Receipt.BlockNumber)),
+	}
+	if opReceipt.Success {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+
+	if opReceipt.ActualGasUsed != nil {
+		receipt.GasUsed = opReceipt.ActualGasUsed.ToInt().Uint64()
+	}
+
+	var fee *big.Int
+	if opReceipt.ActualGasCost != nil {
+		fee = opReceipt.ActualGasCost.ToInt()
+	}
+	return receipt, fee, nil
+}
+
+// recordGasPrice records the effective gas price of a delivery from its fee, and counts
+// the part of its max fee which it reserved but didn't pay as overpayment
+func recordGasPrice(receipt *chain.Receipt, fee *big.Int, gasUsed uint64) {
+	if gasUsed == 0 {
+		return
+	}
+	used := new(big.Int).SetUint64(gasUsed)
+	gasPrice := new(big.Int).Div(fee, used)
+	receipt.GasPrice = gasPrice.String()
+
+	maxFee, ok := new(big.Int).SetString(receipt.MaxFeePerGas, 10)
+	if !ok || maxFee.Cmp(gasPrice) <= 0 {
+		return
+	}
+	overpayment, _ := new(big.Float).SetInt(new(big.Int).Mul(maxFee.Sub(maxFee, gasPrice), used)).Float64()
+	metrics.GasOverpayment.WithLabelValues(Name).Add(overpayment)
+}
+
+// transactionFee returns the fee paid for an included transaction, or nil if neither the
+// transaction nor its effective gas price could be fetched
+func (wr *Writer) transactionFee(ctx context.Context, receipt *types.Receipt) *big.Int {
+	tx, _, err := wr.conn.Client().TransactionByHash(ctx, receipt.TxHash)
+	if err == nil {
+		return new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice())
+	}
+
+	// geth can't decode dynamic fee transactions, whose gas price is read from their receipt
+	gasPrice, priceErr := wr.conn.Client().EffectiveGasPrice(ctx, receipt.TxHash)
+	if priceErr != nil || gasPrice == nil {
+		wr.log.WithError(err).WithField("hash", receipt.TxHash.Hex()).Debug("Failed to fetch transaction")
+		return nil
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), gasPrice)
+}
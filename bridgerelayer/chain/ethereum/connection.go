@@ -9,32 +9,55 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/sirupsen/logrus"
 
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
 )
 
-type Connection struct {
+// Connection is the access of the relayer to an Ethereum node, with the keypair of its account
+type Connection interface {
+	chain.Connection
+	// Client is only available once connected
+	Client() Client
+	Keypair() *secp256k1.Keypair
+}
+
+// RPCConnection connects to a node over JSON-RPC
+type RPCConnection struct {
 	endpoint string
 	kp       *secp256k1.Keypair
-	client   *ethclient.Client
-	log      *logrus.Entry
+	client   Client
+	stats    *chain.RPCStats
+	// nil if the endpoint is dialed by default
+	dialer *chain.Dialer
+	log    *logrus.Entry
 }
 
-func NewConnection(endpoint string, kp *secp256k1.Keypair, log *logrus.Entry) *Connection {
-	return &Connection{
+// NewConnection creates a connection whose calls are recorded in stats, which may be nil
+func NewConnection(endpoint string, kp *secp256k1.Keypair, stats *chain.RPCStats, log *logrus.Entry) *RPCConnection {
+	return &RPCConnection{
 		endpoint: endpoint,
 		kp:       kp,
+		stats:    stats,
 		log:      log,
 	}
 }
 
-func (co *Connection) Connect(ctx context.Context) error {
-	client, err := ethclient.Dial(co.endpoint)
+// DialThrough dials the endpoint through a dialer, by default if nil. It must be set before
+// connecting.
+func (co *RPCConnection) DialThrough(dialer *chain.Dialer) {
+	co.dialer = dialer
+}
+
+func (co *RPCConnection) Connect(ctx context.Context) error {
+	rpcClient, err := chain.DialRPC(ctx, co.endpoint, co.dialer)
 	if err != nil {
 		return err
 	}
+	client := ethclient.NewClient(rpcClient)
 
 	chainID, err := client.NetworkID(ctx)
 	if err != nil {
+		client.Close()
 		return err
 	}
 
@@ -43,13 +66,28 @@ func (co *Connection) Connect(ctx context.Context) error {
 		"chainID":  chainID,
 	}).Info("Connected to chain")
 
-	co.client = client
+	co.client = &instrumentedClient{Client: client, rpc: rpcClient, stats: co.stats}
 
 	return nil
 }
 
-func (co *Connection) Close() {
+func (co *RPCConnection) Close() {
 	if co.client != nil {
 		co.client.Close()
 	}
 }
+
+// Client returns the client of the connection
+func (co *RPCConnection) Client() Client {
+	return co.client
+}
+
+// Keypair returns the keypair of the relayer account
+func (co *RPCConnection) Keypair() *secp256k1.Keypair {
+	return co.kp
+}
+
+// Stats returns the statistics of the calls made over the connection
+func (co *RPCConnection) Stats() *chain.RPCStats {
+	return co.stats
+}
@@ -0,0 +1,299 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	geth "github.com/ethereum/go-ethereum"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ConsoleCommands returns the developer console commands of this chain, which query the
+// chain through its connection. Transactions are built and signed, but never sent.
+func ConsoleCommands(conn Connection, contracts []Contract, writer *Writer) []chain.ConsoleCommand {
+	co := &console{conn: conn, contracts: contracts, writer: writer}
+
+	return []chain.ConsoleCommand{
+		{
+			Name:  "head",
+			Usage: "head",
+			Help:  "Show the latest block header",
+			Run:   co.head,
+		},
+		{
+			Name:  "call",
+			Usage: "call <app> <method> [args...]",
+			Help:  "Call a view method of an app contract at the latest block",
+			Args:  2,
+			Run:   co.call,
+		},
+		{
+			Name:  "events",
+			Usage: "events <app> <from> [to]",
+			Help:  "Decode the app events of a range of blocks into messages",
+			Args:  2,
+			Run:   co.events,
+		},
+		{
+			Name:  "estimate",
+			Usage: "estimate <app> <payload>",
+			Help:  "Estimate the gas and fee of submitting a hex-encoded payload to an app",
+			Args:  2,
+			Run:   co.estimate,
+		},
+		{
+			Name:  "build",
+			Usage: "build <app> <payload>",
+			Help:  "Build and sign the transaction submitting a hex-encoded payload to an app, without sending it",
+			Args:  2,
+			Run:   co.build,
+		},
+	}
+}
+
+type console struct {
+	conn      Connection
+	contracts []Contract
+	writer    *Writer
+}
+
+func (co *console) head(ctx context.Context, _ []string) (interface{}, error) {
+	header, err := co.conn.Client().HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"number":     header.Number.Uint64(),
+		"hash":       header.Hash().Hex(),
+		"parentHash": header.ParentHash.Hex(),
+		"stateRoot":  header.Root.Hex(),
+		"timestamp":  header.Time,
+	}, nil
+}
+
+func (co *console) call(ctx context.Context, args []string) (interface{}, error) {
+	contract, err := co.contract(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := contract.ABI.Methods[args[1]]
+	if !ok {
+		return nil, fmt.Errorf("app %s has no method %s", contract.Name, args[1])
+	}
+	if len(args)-2 != len(method.Inputs) {
+		return nil, fmt.Errorf("method %s takes %d arguments", method.Sig, len(method.Inputs))
+	}
+
+	values := []interface{}{}
+	for i, input := range method.Inputs {
+		value, err := parseABIArg(input.Type, args[i+2])
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", input.Name, err)
+		}
+		values = append(values, value)
+	}
+
+	data, err := contract.ABI.Pack(method.Name, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := co.conn.Client().CallContract(ctx, geth.CallMsg{
+		From: co.conn.Keypair().CommonAddress(),
+		To:   &contract.Address,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return method.Outputs.UnpackValues(output)
+}
+
+func (co *console) events(ctx context.Context, args []string) (interface{}, error) {
+	contract, err := co.contract(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := chain.ParseBlockArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	to := from
+	if len(args) > 2 {
+		to, err = chain.ParseBlockArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := makeQuery(*contract)
+	query.FromBlock = from
+	query.ToBlock = to
+
+	logs, err := co.conn.Client().FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []interface{}{}
+	for _, event := range logs {
+		if contract.Derivation != nil {
+			event = contract.Derivation.Apply(event)
+		}
+
+		msg, err := contract.MakeMessage(event, co.writer.log)
+		if err != nil {
+			return nil, fmt.Errorf("decode event of transaction %s: %w", event.TxHash.Hex(), err)
+		}
+
+		result = append(result, map[string]interface{}{
+			"blockNumber": event.BlockNumber,
+			"txHash":      event.TxHash.Hex(),
+			"logIndex":    event.Index,
+			"payload":     msg.Payload,
+		})
+	}
+	return result, nil
+}
+
+func (co *console) estimate(ctx context.Context, args []string) (interface{}, error) {
+	msg, err := co.message(args)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, err := co.writer.EstimateGas(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	gasPrice, err := co.conn.Client().SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"gas":      gas,
+		"gasPrice": gasPrice.String(),
+		"fee":      new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas)).String(),
+	}, nil
+}
+
+func (co *console) build(ctx context.Context, args []string) (interface{}, error) {
+	msg, err := co.message(args)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := co.writer.Build(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"hash":     tx.Hash().Hex(),
+		"from":     co.conn.Keypair().CommonAddress().Hex(),
+		"to":       tx.To().Hex(),
+		"nonce":    tx.Nonce(),
+		"gas":      tx.Gas(),
+		"gasPrice": tx.GasPrice().String(),
+		"raw":      hexutil.Encode(raw),
+	}, nil
+}
+
+// message builds a message for the app and hex-encoded payload in args
+func (co *console) message(args []string) (*chain.Message, error) {
+	contract, err := co.contract(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := chain.ParseHexArg("payload", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &chain.Message{AppID: contract.Address, Payload: payload}, nil
+}
+
+func (co *console) contract(name string) (*Contract, error) {
+	for i := range co.contracts {
+		if strings.EqualFold(co.contracts[i].Name, name) {
+			return &co.contracts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown app: %s", name)
+}
+
+// parseABIArg converts a command argument to the Go type packed for an ABI type
+func parseABIArg(t abi.Type, value string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("invalid address %q", value)
+		}
+		return common.HexToAddress(value), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.BytesTy:
+		return hexutil.Decode(value)
+	case abi.FixedBytesTy:
+		data, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != t.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", t.Size, len(data))
+		}
+		result := reflect.New(t.GetType()).Elem()
+		reflect.Copy(result, reflect.ValueOf(data))
+		return result.Interface(), nil
+	case abi.UintTy, abi.IntTy:
+		number, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		if t.Size > 64 {
+			return number, nil
+		}
+		result := reflect.New(t.GetType()).Elem()
+		if t.T == abi.UintTy {
+			if number.Sign() < 0 || number.BitLen() > t.Size {
+				return nil, fmt.Errorf("%s out of range for uint%d", value, t.Size)
+			}
+			result.SetUint(number.Uint64())
+		} else {
+			if !number.IsInt64() || result.OverflowInt(number.Int64()) {
+				return nil, fmt.Errorf("%s out of range for int%d", value, t.Size)
+			}
+			result.SetInt(number.Int64())
+		}
+		return result.Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported argument type %s", t.String())
+	}
+}
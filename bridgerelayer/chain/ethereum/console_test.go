@@ -0,0 +1,55 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseABIArg(t *testing.T) {
+	parse := func(typ string, value string) (interface{}, error) {
+		parsed, err := abi.NewType(typ, "", nil)
+		require.NoError(t, err)
+		return parseABIArg(parsed, value)
+	}
+
+	value, err := parse("address", "0x0d27b0069241c03575669fed1badcbcc6e6eb6ea")
+	assert.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0x0d27b0069241c03575669fed1badcbcc6e6eb6ea"), value)
+
+	value, err = parse("uint8", "255")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(255), value)
+
+	_, err = parse("uint8", "256")
+	assert.Error(t, err)
+
+	value, err = parse("int64", "-5")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-5), value)
+
+	value, err = parse("uint256", "0x10")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(16), value)
+
+	value, err = parse("bytes4", "0x01020304")
+	assert.NoError(t, err)
+	assert.Equal(t, [4]byte{1, 2, 3, 4}, value)
+
+	_, err = parse("bytes32", "0x01")
+	assert.Error(t, err)
+
+	value, err = parse("bool", "true")
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+
+	_, err = parse("uint256[]", "1")
+	assert.Error(t, err)
+}
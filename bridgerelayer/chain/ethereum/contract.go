@@ -4,17 +4,29 @@
 package ethereum
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/mitchellh/go-homedir"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 )
 
 type Contract struct {
 	Name    string
 	Address common.Address
 	ABI     *abi.ABI
+	Limits  Limits
+	// Limits of the event arguments, nil if none
+	Fields *FieldLimits
+	// Derivation of event recipients, nil if disabled
+	Derivation *Derivation
+	// Substrate call made for events, nil if disabled
+	Call *Call
 }
 
 func LoadContracts(config *Config) ([]Contract, error) {
@@ -31,12 +43,54 @@ func LoadContracts(config *Config) ([]Contract, error) {
 		if err != nil {
 			return nil, err
 		}
-		contracts = append(contracts, Contract{Name: name, Address: address, ABI: abi})
+
+		var derivation *Derivation
+		if app.Derivation != nil {
+			derivation, err = NewDerivation(app.Derivation, abi)
+			if err != nil {
+				return nil, fmt.Errorf("app %s: %w", name, err)
+			}
+		}
+
+		var call *Call
+		if app.Call != nil {
+			call, err = NewCall(app.Call, abi)
+			if err != nil {
+				return nil, fmt.Errorf("app %s: %w", name, err)
+			}
+		}
+
+		fields, err := NewFieldLimits(&app.Limits, abi, app.Call)
+		if err != nil {
+			return nil, fmt.Errorf("app %s: %w", name, err)
+		}
+
+		contracts = append(contracts, Contract{
+			Name:       name,
+			Address:    address,
+			ABI:        abi,
+			Limits:     app.Limits,
+			Fields:     fields,
+			Derivation: derivation,
+			Call:       call,
+		})
 	}
 
 	return contracts, nil
 }
 
+// MakeMessage generates the message for an event of the contract, making the configured
+// call if the event carries call arguments
+func (c *Contract) MakeMessage(event gethTypes.Log, log *logrus.Entry) (*chain.Message, error) {
+	if c.Call != nil {
+		msg, err := c.Call.Message(&event)
+		if err != nil || msg != nil {
+			return msg, err
+		}
+	}
+	return MakeMessageFromEvent(event, log)
+}
+
 func loadContractABI(abiPath string) (*abi.ABI, error) {
 	f, err := os.Open(abiPath)
 	if err != nil {
@@ -0,0 +1,86 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+)
+
+// CostCapConfig caps the estimated cost of each message, above which it isn't submitted
+type CostCapConfig struct {
+	// Gas estimated with eth_estimateGas. Zero disables the cap.
+	MaxGas uint64 `mapstructure:"max-gas"`
+	// Fee in wei of the estimated gas at the price the delivery would be offered. Disabled if
+	// empty.
+	MaxFee string `mapstructure:"max-fee"`
+	// The same fee in the reporting currency of the pricing section, as a decimal. Disabled
+	// if empty.
+	MaxCost string `mapstructure:"max-cost"`
+}
+
+// checkCost estimates the gas of a call delivering messages to an app, and refuses the call
+// if the gas or its fee at the current price, averaged over the messages, exceeds the cap.
+// Calls which revert in the estimate are left to the handling of reverted deliveries.
+func (wr *Writer) checkCost(ctx context.Context, app string, address common.Address, txData []byte, messages int, escalate bool) error {
+	if !wr.costCap.Enabled() {
+		return nil
+	}
+
+	gas, err := wr.estimate(ctx, address, txData)
+	if err != nil {
+		if isRevert(err) {
+			wr.log.WithError(err).WithField("contractAddress", address.Hex()).Debug("Estimated call reverts, leaving its cost unchecked")
+			return nil
+		}
+		return fmt.Errorf("estimate gas of delivery: %w", err)
+	}
+	if _, ok := wr.forwarders[address]; ok {
+		gas += forwarderGasOverhead
+	}
+
+	fees, err := wr.txFees(ctx, escalate)
+	if err != nil {
+		return err
+	}
+	fee := new(big.Int).Mul(fees.gasPrice, new(big.Int).SetUint64(gas))
+
+	perMessage := gas / uint64(messages)
+	perMessageFee := fee.Div(fee, big.NewInt(int64(messages)))
+	err = wr.costCap.Check(ctx, app, perMessage, perMessageFee)
+	if err != nil {
+		wr.log.WithError(err).WithFields(logrus.Fields{
+			"contractAddress": address.Hex(),
+			"messages":        messages,
+			"gas":             gas,
+			"gasPrice":        fees.gasPrice.String(),
+		}).Warn("Refused delivery exceeding the cost cap")
+	}
+	return err
+}
+
+// estimate returns the gas used by a call of a contract from the relayer account
+func (wr *Writer) estimate(ctx context.Context, address common.Address, txData []byte) (uint64, error) {
+	return wr.conn.Client().EstimateGas(ctx, geth.CallMsg{
+		From: wr.conn.Keypair().CommonAddress(),
+		To:   &address,
+		Data: txData,
+	})
+}
+
+// isRevert returns whether a call failed as it reverted
+func isRevert(err error) bool {
+	for _, prefix := range revertPrefixes {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
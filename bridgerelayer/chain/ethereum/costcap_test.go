@@ -0,0 +1,59 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestWriter_CheckCost(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	// the mock estimates the gas limit of a delivery for every call
+	client := NewMockClient(big.NewInt(15))
+	client.SetGasPrice(big.NewInt(2))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+
+	config := &Config{CostCap: CostCapConfig{MaxGas: gasLimit - 1, MaxFee: "3000000"}}
+	wr, err := NewWriter(config, conn, nil, nil, nil, nil, log)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	app := common.Address{1}
+
+	err = wr.checkCost(ctx, "app", app, []byte{1}, 1, false)
+	assert.True(t, errors.Is(err, chain.ErrCostExceeded))
+	assert.Equal(t, chain.ErrorFatal, chain.Classify(err))
+
+	// the cost of batches is averaged over their messages
+	assert.NoError(t, wr.checkCost(ctx, "app", app, []byte{1}, 2, false))
+
+	// the fee is the estimated gas at the current price
+	client.SetGasPrice(big.NewInt(4))
+	err = wr.checkCost(ctx, "app", app, []byte{1}, 2, false)
+	assert.True(t, errors.Is(err, chain.ErrCostExceeded))
+
+	_, err = NewWriter(&Config{CostCap: CostCapConfig{MaxFee: "0x10"}}, conn, nil, nil, nil, nil, log)
+	assert.Error(t, err)
+}
+
+func TestIsRevert(t *testing.T) {
+	assert.True(t, isRevert(errors.New("execution reverted: not yet imported")))
+	assert.True(t, isRevert(errors.New("VM Exception while processing transaction: revert")))
+	assert.False(t, isRevert(errors.New("connection refused")))
+}
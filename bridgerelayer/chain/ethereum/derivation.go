@@ -0,0 +1,115 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/snowfork/go-substrate-rpc-client/blake2b"
+)
+
+// DeriveAccount derives the Substrate account which corresponds to an Ethereum address
+type DeriveAccount func(address common.Address) [32]byte
+
+var schemes = map[string]DeriveAccount{
+	"evm-hashed":  deriveHashed,
+	"zero-padded": derivePadded,
+}
+
+// RegisterDerivationScheme makes an address derivation scheme available to app configs
+func RegisterDerivationScheme(name string, derive DeriveAccount) {
+	schemes[name] = derive
+}
+
+// deriveHashed hashes the address with blake2b-256 after an "evm:" prefix, matching
+// the HashedAddressMapping of Frontier
+func deriveHashed(address common.Address) [32]byte {
+	hasher, _ := blake2b.New256(nil)
+	hasher.Write([]byte("evm:"))
+	hasher.Write(address.Bytes())
+
+	var account [32]byte
+	copy(account[:], hasher.Sum(nil))
+	return account
+}
+
+// derivePadded appends 12 zero bytes to the address
+func derivePadded(address common.Address) [32]byte {
+	var account [32]byte
+	copy(account[:], address.Bytes())
+	return account
+}
+
+// Derivation rewrites the recipient of an app's events before they are relayed.
+// A recipient is treated as an Ethereum address if it is a bytes32 word with 12
+// leading zero bytes, which is how an address is passed as a bytes32 argument.
+// Other recipients are taken to be Substrate accounts already and left unchanged.
+type Derivation struct {
+	derive DeriveAccount
+	// offsets of the recipient word in the data of each event, by event ID
+	offsets map[common.Hash]int
+}
+
+func NewDerivation(config *DerivationConfig, contractABI *abi.ABI) (*Derivation, error) {
+	derive, ok := schemes[config.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown address derivation scheme: %s", config.Scheme)
+	}
+
+	field := config.Field
+	if field == "" {
+		field = defaultRecipientField
+	}
+
+	offsets := make(map[common.Hash]int)
+	for _, event := range contractABI.Events {
+		position := 0
+		for _, input := range event.Inputs {
+			if input.Indexed {
+				continue
+			}
+			// events such as Unlock carry an Ethereum recipient, which is not derived
+			if input.Name == field && input.Type.T == abi.FixedBytesTy && input.Type.Size == 32 {
+				offsets[event.ID] = position * 32
+			}
+			position++
+		}
+	}
+
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("no event has a bytes32 recipient named %s", field)
+	}
+
+	return &Derivation{derive: derive, offsets: offsets}, nil
+}
+
+// Apply returns the event with its recipient derived, leaving the original unchanged
+func (d *Derivation) Apply(event gethTypes.Log) gethTypes.Log {
+	if len(event.Topics) == 0 {
+		return event
+	}
+
+	offset, ok := d.offsets[event.Topics[0]]
+	if !ok || len(event.Data) < offset+32 {
+		return event
+	}
+
+	word := event.Data[offset : offset+32]
+	if !bytes.Equal(word[:12], make([]byte, 12)) {
+		return event
+	}
+
+	account := d.derive(common.BytesToAddress(word[12:]))
+
+	data := make([]byte, len(event.Data))
+	copy(data, event.Data)
+	copy(data[offset:], account[:])
+	event.Data = data
+
+	return event
+}
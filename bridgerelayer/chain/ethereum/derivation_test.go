@@ -0,0 +1,64 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivation_Apply(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+
+	derivation, err := NewDerivation(&DerivationConfig{Scheme: "zero-padded"}, &contractABI)
+	require.NoError(t, err)
+
+	address := common.HexToAddress("0x89b4ab1ef20763630df9743acf155865600daff2")
+	transfer := contractABI.Events["Transfer"].ID
+
+	data := make([]byte, 96)
+	copy(data[32+12:], address.Bytes())
+	event := types.Log{Topics: []common.Hash{transfer}, Data: data}
+
+	derived := derivation.Apply(event)
+	account := derivePadded(address)
+	assert.Equal(t, account[:], derived.Data[32:64])
+	// the original event is left unchanged
+	assert.Equal(t, address.Bytes(), event.Data[44:64])
+
+	// recipients which are already Substrate accounts are not derived
+	substrate := make([]byte, 96)
+	substrate[32] = 0xd4
+	event = types.Log{Topics: []common.Hash{transfer}, Data: substrate}
+	assert.Equal(t, substrate, derivation.Apply(event).Data)
+
+	// events without a bytes32 recipient are not derived
+	unlock := types.Log{Topics: []common.Hash{contractABI.Events["Unlock"].ID}, Data: data}
+	assert.Equal(t, data, derivation.Apply(unlock).Data)
+}
+
+func TestNewDerivation(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+
+	_, err = NewDerivation(&DerivationConfig{Scheme: "unknown"}, &contractABI)
+	assert.Error(t, err)
+
+	_, err = NewDerivation(&DerivationConfig{Scheme: "evm-hashed", Field: "_amount"}, &contractABI)
+	assert.Error(t, err)
+}
+
+func TestDeriveHashed(t *testing.T) {
+	address := common.HexToAddress("0x89b4ab1ef20763630df9743acf155865600daff2")
+	account := deriveHashed(address)
+	assert.Equal(t, "cdb81568db0905ad50ab4fe89d762266fc65e8e17994b745a8c6e75d04b33215", hex.EncodeToString(account[:]))
+}
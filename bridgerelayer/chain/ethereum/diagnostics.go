@@ -0,0 +1,72 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// inspect captures the queues, nonces, pending transactions, RPC statistics and gas prices
+// of the writer into diagnostics
+func (wr *Writer) inspect(ctx context.Context, diagnostics *chain.WriterDiagnostics) {
+	diagnostics.Queues = wr.dispatcher.Queues()
+	if submittedAt := wr.dispatcher.LastSubmission(); !submittedAt.IsZero() {
+		diagnostics.LastSubmission = &submittedAt
+	}
+
+	diagnostics.RPC = append(diagnostics.RPC, wr.conn.Stats().Snapshot())
+	if wr.bundler != nil {
+		diagnostics.RPC = append(diagnostics.RPC, wr.bundler.Stats().Snapshot())
+	}
+
+	client := wr.conn.Client()
+
+	nonces := wr.nonces.Accounts()
+	if len(nonces) == 0 {
+		nonces = append(nonces, chain.NonceState{Account: wr.conn.Keypair().CommonAddress().Hex()})
+	}
+	for i := range nonces {
+		nonce, err := client.PendingNonceAt(ctx, common.HexToAddress(nonces[i].Account))
+		if err != nil {
+			diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("pending nonce of %s: %s", nonces[i].Account, err))
+			continue
+		}
+		nonces[i].OnChain = nonce
+	}
+	diagnostics.Nonces = nonces
+
+	for _, tx := range wr.pending.Pending() {
+		diagnostics.Pending = append(diagnostics.Pending, chain.PendingSubmission{
+			Hash:         tx.Hash,
+			Nonce:        tx.Nonce,
+			GasPrice:     tx.GasPrice,
+			Rebroadcasts: tx.Rebroadcasts,
+			SubmittedAt:  tx.SubmittedAt,
+		})
+	}
+
+	diagnostics.Fees = make(map[string]string)
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("gas price: %s", err))
+	} else {
+		diagnostics.Fees["gasPrice"] = gasPrice.String()
+	}
+
+	if wr.fees.Enabled() {
+		tip, feeCap, ok, err := wr.fees.Suggest(ctx, client)
+		if err != nil {
+			diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("dynamic fees: %s", err))
+		} else if ok {
+			tip, feeCap = wr.fees.limit(tip, feeCap)
+			diagnostics.Fees["maxPriorityFeePerGas"] = tip.String()
+			diagnostics.Fees["maxFeePerGas"] = feeCap.String()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// endpointHeads reports the heads served through a connection. Ethereum blocks are only
+// probabilistically final, so the finalized head is taken to be the block as deep below the
+// latest block as the checkpoint is kept.
+type endpointHeads struct {
+	endpoint string
+	conn     Connection
+	// whether the connection is made on first use, rather than by the chain
+	connected bool
+}
+
+// headSources returns the sources of the heads compared by the divergence monitor: the
+// connections of the chain, followed by further endpoints which are connected on first use
+// through dialer
+func headSources(config *Config, conn Connection, submit Connection, dialer *chain.Dialer, log *logrus.Entry) ([]chain.HeadSource, []Connection) {
+	sources := []chain.HeadSource{&endpointHeads{endpoint: config.Endpoint, conn: conn, connected: true}}
+	if submit != conn {
+		sources = append(sources, &endpointHeads{endpoint: config.SubmitEndpoint, conn: submit, connected: true})
+	}
+
+	var references []Connection
+	for _, endpoint := range config.Divergence.Endpoints {
+		reference := NewConnection(endpoint, conn.Keypair(), nil, log)
+		reference.DialThrough(dialer)
+		sources = append(sources, &endpointHeads{endpoint: endpoint, conn: reference})
+		references = append(references, reference)
+	}
+
+	return sources, references
+}
+
+func (eh *endpointHeads) Endpoint() string {
+	return chain.RedactEndpoint(eh.endpoint)
+}
+
+func (eh *endpointHeads) client(ctx context.Context) (Client, error) {
+	if !eh.connected {
+		err := eh.conn.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		eh.connected = true
+	}
+	return eh.conn.Client(), nil
+}
+
+func (eh *endpointHeads) Heads(ctx context.Context) (chain.Head, chain.Head, error) {
+	client, err := eh.client(ctx)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+	latest := chain.Head{Number: header.Number.Uint64(), Hash: header.Hash().Hex()}
+
+	if latest.Number <= checkpointReorgDepth {
+		return latest, chain.Head{}, nil
+	}
+
+	number := latest.Number - checkpointReorgDepth
+	hash, err := eh.BlockHash(ctx, number)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+
+	return latest, chain.Head{Number: number, Hash: hash}, nil
+}
+
+func (eh *endpointHeads) BlockHash(ctx context.Context, number uint64) (string, error) {
+	client, err := eh.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return "", err
+	}
+	return header.Hash().Hex(), nil
+}
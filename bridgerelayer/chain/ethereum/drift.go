@@ -0,0 +1,165 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	geth "github.com/ethereum/go-ethereum"
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultDriftCheckBlocks = 1000
+
+// SignatureDrift describes an event signature emitted by a watched contract which
+// is not part of the contract ABI the relayer was configured with
+type SignatureDrift struct {
+	Topic gethCommon.Hash
+	Count int
+	// NearMiss is set when the unknown event has the same shape as the event the
+	// listener filters on, which usually means a contract upgrade changed its signature
+	NearMiss bool
+}
+
+// DriftDetector periodically compares the event signatures the listener filters on
+// with those actually emitted by the watched contracts
+type DriftDetector struct {
+	conn      Connection
+	contracts []Contract
+	interval  time.Duration
+	blocks    uint64
+	log       *logrus.Entry
+}
+
+func NewDriftDetector(config *Config, conn Connection, contracts []Contract, log *logrus.Entry) *DriftDetector {
+	blocks := config.DriftCheckBlocks
+	if blocks == 0 {
+		blocks = defaultDriftCheckBlocks
+	}
+
+	return &DriftDetector{
+		conn:      conn,
+		contracts: contracts,
+		interval:  time.Duration(config.DriftCheckInterval) * time.Second,
+		blocks:    blocks,
+		log:       log,
+	}
+}
+
+func (dd *DriftDetector) Start(ctx context.Context, eg *errgroup.Group) error {
+	eg.Go(func() error {
+		ticker := time.NewTicker(dd.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				err := dd.check(ctx)
+				if err != nil {
+					dd.log.WithError(err).Warn("Failed to check contract event signatures")
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func (dd *DriftDetector) check(ctx context.Context) error {
+	head, err := dd.conn.Client().HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	toBlock := head.Number.Uint64()
+	fromBlock := uint64(0)
+	if toBlock > dd.blocks {
+		fromBlock = toBlock - dd.blocks
+	}
+
+	for _, contract := range dd.contracts {
+		logs, err := dd.conn.Client().FilterLogs(ctx, geth.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(fromBlock),
+			ToBlock:   new(big.Int).SetUint64(toBlock),
+			Addresses: []gethCommon.Address{contract.Address},
+		})
+		if err != nil {
+			return err
+		}
+
+		watchedSeen, drifts := detectDrift(contract, logs)
+		for _, drift := range drifts {
+			fields := logrus.Fields{
+				"contractName":    contract.Name,
+				"contractAddress": contract.Address.Hex(),
+				"topic":           drift.Topic.Hex(),
+				"count":           drift.Count,
+				"fromBlock":       fromBlock,
+				"toBlock":         toBlock,
+			}
+
+			if drift.NearMiss && !watchedSeen {
+				dd.log.WithFields(fields).Error("ALERT: contract emits an unknown event shaped like the watched event. " +
+					"The contract may have been upgraded and no messages are being relayed for it")
+			} else {
+				dd.log.WithFields(fields).Warn("Contract emits an event signature missing from its ABI")
+			}
+		}
+	}
+
+	return nil
+}
+
+// detectDrift returns whether the watched event was seen in the logs, along with any
+// event signatures which are not declared in the contract ABI
+func detectDrift(contract Contract, logs []gethTypes.Log) (bool, []SignatureDrift) {
+	known := make(map[gethCommon.Hash]bool)
+	for _, event := range contract.ABI.Events {
+		known[event.ID] = true
+	}
+
+	watched := contract.ABI.Events[watchedEvent]
+	watchedTopics := 1
+	for _, input := range watched.Inputs {
+		if input.Indexed {
+			watchedTopics++
+		}
+	}
+
+	watchedSeen := false
+	drifts := []SignatureDrift{}
+	index := make(map[gethCommon.Hash]int)
+
+	for _, log := range logs {
+		// Anonymous events have no signature topic
+		if len(log.Topics) == 0 {
+			continue
+		}
+
+		topic := log.Topics[0]
+		if topic == watched.ID {
+			watchedSeen = true
+		}
+		if known[topic] {
+			continue
+		}
+
+		i, ok := index[topic]
+		if !ok {
+			i = len(drifts)
+			index[topic] = i
+			drifts = append(drifts, SignatureDrift{Topic: topic, NearMiss: len(log.Topics) == watchedTopics})
+		}
+		drifts[i].Count++
+	}
+
+	return watchedSeen, drifts
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAppABI = `
+[
+	{
+		"anonymous": false,
+		"inputs": [
+			{ "indexed": false, "internalType": "address", "name": "_sender", "type": "address" },
+			{ "indexed": false, "internalType": "bytes32", "name": "_recipient", "type": "bytes32" },
+			{ "indexed": false, "internalType": "uint256", "name": "_amount", "type": "uint256" }
+		],
+		"name": "Transfer",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{ "indexed": false, "internalType": "bytes", "name": "_sender", "type": "bytes" },
+			{ "indexed": false, "internalType": "address", "name": "_recipient", "type": "address" },
+			{ "indexed": false, "internalType": "uint256", "name": "_amount", "type": "uint256" }
+		],
+		"name": "Unlock",
+		"type": "event"
+	}
+]
+`
+
+func TestDetectDrift(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := Contract{Name: "eth", ABI: &contractABI}
+
+	transfer := contractABI.Events["Transfer"].ID
+	unlock := contractABI.Events["Unlock"].ID
+	upgraded := common.BytesToHash(crypto.Keccak256([]byte("Transfer(address,bytes32,uint256,uint64)")))
+	unrelated := common.BytesToHash(crypto.Keccak256([]byte("Sweep(address)")))
+
+	seen, drifts := detectDrift(contract, []types.Log{
+		{Topics: []common.Hash{transfer}},
+		{Topics: []common.Hash{unlock}},
+	})
+	assert.True(t, seen)
+	assert.Empty(t, drifts)
+
+	seen, drifts = detectDrift(contract, []types.Log{
+		{Topics: []common.Hash{upgraded}},
+		{Topics: []common.Hash{unlock}},
+		{Topics: []common.Hash{upgraded}},
+		{Topics: []common.Hash{unrelated, unrelated}},
+		{Topics: []common.Hash{}},
+	})
+	assert.False(t, seen)
+	assert.Equal(t, []SignatureDrift{
+		{Topic: upgraded, Count: 2, NearMiss: true},
+		{Topic: unrelated, Count: 1, NearMiss: false},
+	}, drifts)
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// dynamicFeeTxType is the EIP-2718 type of EIP-1559 transactions
+const dynamicFeeTxType = 0x02
+
+// signedTransaction is a transaction signed by the writer, either a legacy transaction or a
+// dynamic fee transaction
+type signedTransaction interface {
+	Hash() common.Hash
+	Nonce() uint64
+	Gas() uint64
+	To() *common.Address
+	Data() []byte
+	// GasPrice is the max fee per gas of dynamic fee transactions
+	GasPrice() *big.Int
+}
+
+var _ signedTransaction = (*types.Transaction)(nil)
+
+// dynamicFeeTx is a signed EIP-1559 transaction calling a contract. The version of geth used
+// by the relayer predates typed transactions, so they are encoded and signed here.
+type dynamicFeeTx struct {
+	chainID   *big.Int
+	nonce     uint64
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+	gas       uint64
+	to        common.Address
+	data      []byte
+	// signature, with v the parity of the y-coordinate
+	v, r, s *big.Int
+	// EIP-2718 encoding of the signed transaction, and its hash
+	raw  []byte
+	hash common.Hash
+}
+
+// accessTuple is an entry of the access list of a transaction, which the writer leaves empty
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+// signDynamicFeeTx signs a dynamic fee transaction calling a contract without value
+func signDynamicFeeTx(kp *secp256k1.Keypair, chainID *big.Int, nonce uint64, to common.Address, gas uint64, gasTipCap *big.Int, gasFeeCap *big.Int, data []byte) (*dynamicFeeTx, error) {
+	tx := &dynamicFeeTx{
+		chainID:   chainID,
+		nonce:     nonce,
+		gasTipCap: gasTipCap,
+		gasFeeCap: gasFeeCap,
+		gas:       gas,
+		to:        to,
+		data:      data,
+	}
+
+	unsigned, err := tx.encode(false)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(unsigned), kp.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	tx.r = new(big.Int).SetBytes(sig[:32])
+	tx.s = new(big.Int).SetBytes(sig[32:64])
+	tx.v = new(big.Int).SetUint64(uint64(sig[64]))
+
+	tx.raw, err = tx.encode(true)
+	if err != nil {
+		return nil, err
+	}
+	tx.hash = crypto.Keccak256Hash(tx.raw)
+	return tx, nil
+}
+
+// encode returns the EIP-2718 encoding of the transaction, which is hashed for signing if
+// it is unsigned
+func (tx *dynamicFeeTx) encode(signed bool) ([]byte, error) {
+	fields := []interface{}{
+		tx.chainID,
+		tx.nonce,
+		tx.gasTipCap,
+		tx.gasFeeCap,
+		tx.gas,
+		tx.to,
+		new(big.Int),
+		tx.data,
+		[]accessTuple{},
+	}
+	if signed {
+		fields = append(fields, tx.v, tx.r, tx.s)
+	}
+
+	payload, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{dynamicFeeTxType}, payload...), nil
+}
+
+// decodeDynamicFeeTx decodes the EIP-2718 encoding of a signed dynamic fee transaction,
+// returning it with its sender
+func decodeDynamicFeeTx(raw []byte) (*dynamicFeeTx, common.Address, error) {
+	if len(raw) == 0 || raw[0] != dynamicFeeTxType {
+		return nil, common.Address{}, fmt.Errorf("not a dynamic fee transaction")
+	}
+
+	var fields struct {
+		ChainID    *big.Int
+		Nonce      uint64
+		GasTipCap  *big.Int
+		GasFeeCap  *big.Int
+		Gas        uint64
+		To         common.Address
+		Value      *big.Int
+		Data       []byte
+		AccessList []accessTuple
+		V, R, S    *big.Int
+	}
+	err := rlp.DecodeBytes(raw[1:], &fields)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if fields.Value.Sign() != 0 {
+		return nil, common.Address{}, fmt.Errorf("transaction carries a value of %s", fields.Value)
+	}
+
+	tx := &dynamicFeeTx{
+		chainID:   fields.ChainID,
+		nonce:     fields.Nonce,
+		gasTipCap: fields.GasTipCap,
+		gasFeeCap: fields.GasFeeCap,
+		gas:       fields.Gas,
+		to:        fields.To,
+		data:      fields.Data,
+		v:         fields.V,
+		r:         fields.R,
+		s:         fields.S,
+		raw:       raw,
+		hash:      crypto.Keccak256Hash(raw),
+	}
+
+	if !fields.V.IsUint64() || fields.V.Uint64() > 1 || !crypto.ValidateSignatureValues(byte(fields.V.Uint64()), fields.R, fields.S, true) {
+		return nil, common.Address{}, fmt.Errorf("invalid signature")
+	}
+	unsigned, err := tx.encode(false)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	sig := make([]byte, 65)
+	copy(sig[32-len(fields.R.Bytes()):32], fields.R.Bytes())
+	copy(sig[64-len(fields.S.Bytes()):64], fields.S.Bytes())
+	sig[64] = byte(fields.V.Uint64())
+	pub, err := crypto.SigToPub(crypto.Keccak256(unsigned), sig)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	return tx, crypto.PubkeyToAddress(*pub), nil
+}
+
+func (tx *dynamicFeeTx) Hash() common.Hash {
+	return tx.hash
+}
+
+func (tx *dynamicFeeTx) Nonce() uint64 {
+	return tx.nonce
+}
+
+func (tx *dynamicFeeTx) Gas() uint64 {
+	return tx.gas
+}
+
+func (tx *dynamicFeeTx) To() *common.Address {
+	to := tx.to
+	return &to
+}
+
+func (tx *dynamicFeeTx) Data() []byte {
+	return tx.data
+}
+
+func (tx *dynamicFeeTx) GasPrice() *big.Int {
+	return new(big.Int).Set(tx.gasFeeCap)
+}
+
+// GasTipCap returns the max priority fee per gas
+func (tx *dynamicFeeTx) GasTipCap() *big.Int {
+	return new(big.Int).Set(tx.gasTipCap)
+}
+
+// Raw returns the EIP-2718 encoding of the transaction, as sent to nodes
+func (tx *dynamicFeeTx) Raw() []byte {
+	return tx.raw
+}
@@ -0,0 +1,512 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// resubscribeTimeout bounds each attempt to subscribe again after a subscription was dropped
+const resubscribeTimeout = 10 * time.Second
+
+// FailoverConnection connects to several endpoints serving the same network, making its
+// calls to the one which a chain.EndpointPool selects. The subscriptions made through its
+// client follow the active endpoint, subscribing again when they are dropped or the
+// connection fails over, and fetching the logs emitted in between.
+type FailoverConnection struct {
+	endpoints []string
+	kp        *secp256k1.Keypair
+	pool      *chain.EndpointPool
+	backoff   *chain.Backoff
+	dial      func(ctx context.Context, endpoint string, stats *chain.RPCStats) (Client, error)
+	// nil if the endpoints are dialed by default
+	dialer *chain.Dialer
+	mutex  sync.RWMutex
+	// clients of the endpoints which were connected to, nil for the others
+	clients []Client
+	stats   []*chain.RPCStats
+	// network of the first endpoint connected to, which the others must serve
+	networkID *big.Int
+	client    *failoverClient
+	closed    chan struct{}
+	closeOnce sync.Once
+	log       *logrus.Entry
+}
+
+// NewFailoverConnection creates a connection to endpoints in order of preference, whose
+// calls are recorded in the statistics of each endpoint
+func NewFailoverConnection(endpoints []string, kp *secp256k1.Keypair, config *chain.RPCConfig, log *logrus.Entry) *FailoverConnection {
+	co := &FailoverConnection{
+		endpoints: endpoints,
+		kp:        kp,
+		backoff:   chain.NewBackoff(Name, &config.Backoff),
+		clients:   make([]Client, len(endpoints)),
+		closed:    make(chan struct{}),
+		log:       log,
+	}
+	for _, endpoint := range endpoints {
+		co.stats = append(co.stats, chain.NewRPCStats(Name, endpoint, config, log))
+	}
+	co.dial = func(ctx context.Context, endpoint string, stats *chain.RPCStats) (Client, error) {
+		return dialClient(ctx, endpoint, stats, co.dialer)
+	}
+	co.pool = chain.NewEndpointPool(Name, endpoints, &config.Failover, co.connect, log)
+	return co
+}
+
+// DialThrough dials the endpoints through a dialer, by default if nil. It must be set before
+// connecting.
+func (co *FailoverConnection) DialThrough(dialer *chain.Dialer) {
+	co.dialer = dialer
+}
+
+func dialClient(ctx context.Context, endpoint string, stats *chain.RPCStats, dialer *chain.Dialer) (Client, error) {
+	rpcClient, err := chain.DialRPC(ctx, endpoint, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedClient{Client: ethclient.NewClient(rpcClient), rpc: rpcClient, stats: stats}, nil
+}
+
+func (co *FailoverConnection) Connect(ctx context.Context) error {
+	err := co.pool.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	co.client = &failoverClient{co: co}
+	return nil
+}
+
+// connect dials the endpoint at an index unless it was connected to before, and checks
+// that it serves the network of the others
+func (co *FailoverConnection) connect(ctx context.Context, index int) error {
+	co.mutex.RLock()
+	client := co.clients[index]
+	expected := co.networkID
+	co.mutex.RUnlock()
+
+	dialed := client == nil
+	if dialed {
+		var err error
+		client, err = co.dial(ctx, co.endpoints[index], co.stats[index])
+		if err != nil {
+			return err
+		}
+	}
+
+	networkID, err := client.NetworkID(ctx)
+	if err == nil && expected != nil && networkID.Cmp(expected) != 0 {
+		err = chain.Permanent(fmt.Errorf("endpoint serves network %s instead of %s", networkID, expected))
+	}
+	if err != nil {
+		if dialed {
+			client.Close()
+		}
+		return err
+	}
+
+	co.mutex.Lock()
+	defer co.mutex.Unlock()
+
+	if co.networkID == nil {
+		co.networkID = networkID
+	}
+	if dialed {
+		co.clients[index] = client
+		co.log.WithFields(logrus.Fields{
+			"endpoint": chain.RedactEndpoint(co.endpoints[index]),
+			"chainID":  networkID,
+		}).Info("Connected to chain")
+	}
+	return nil
+}
+
+// active returns the client of the active endpoint, with its index
+func (co *FailoverConnection) active() (Client, int) {
+	index := co.pool.Active()
+
+	co.mutex.RLock()
+	defer co.mutex.RUnlock()
+	return co.clients[index], index
+}
+
+func (co *FailoverConnection) Close() {
+	co.closeOnce.Do(func() {
+		close(co.closed)
+
+		co.mutex.Lock()
+		defer co.mutex.Unlock()
+		for _, client := range co.clients {
+			if client != nil {
+				client.Close()
+			}
+		}
+	})
+}
+
+// Client returns the client of the connection, which makes each call to the active endpoint
+func (co *FailoverConnection) Client() Client {
+	return co.client
+}
+
+// Keypair returns the keypair of the relayer account
+func (co *FailoverConnection) Keypair() *secp256k1.Keypair {
+	return co.kp
+}
+
+// Stats returns the statistics of the calls made to the active endpoint
+func (co *FailoverConnection) Stats() *chain.RPCStats {
+	return co.stats[co.pool.Active()]
+}
+
+// Pool returns the pool selecting the active endpoint
+func (co *FailoverConnection) Pool() *chain.EndpointPool {
+	return co.pool
+}
+
+// failoverClient makes each call to the active endpoint of a connection, recording its
+// outcome in the health of the endpoint
+type failoverClient struct {
+	co *FailoverConnection
+}
+
+// observe records the outcome of a call. Missing receipts are the normal state of pending
+// transactions rather than failures of the endpoint.
+func (fc *failoverClient) observe(ctx context.Context, index int, err error) {
+	if err == geth.NotFound {
+		err = nil
+	}
+	fc.co.pool.Observe(ctx, index, err)
+}
+
+func (fc *failoverClient) ChainID(ctx context.Context) (*big.Int, error) {
+	client, index := fc.co.active()
+	id, err := client.ChainID(ctx)
+	fc.observe(ctx, index, err)
+	return id, err
+}
+
+func (fc *failoverClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	client, index := fc.co.active()
+	id, err := client.NetworkID(ctx)
+	fc.observe(ctx, index, err)
+	return id, err
+}
+
+func (fc *failoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	client, index := fc.co.active()
+	header, err := client.HeaderByNumber(ctx, number)
+	fc.observe(ctx, index, err)
+	return header, err
+}
+
+func (fc *failoverClient) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	client, index := fc.co.active()
+	header, err := client.HeaderByHash(ctx, hash)
+	fc.observe(ctx, index, err)
+	return header, err
+}
+
+func (fc *failoverClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	client, index := fc.co.active()
+	price, err := client.SuggestGasPrice(ctx)
+	fc.observe(ctx, index, err)
+	return price, err
+}
+
+func (fc *failoverClient) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	client, index := fc.co.active()
+	balance, err := client.BalanceAt(ctx, account, number)
+	fc.observe(ctx, index, err)
+	return balance, err
+}
+
+func (fc *failoverClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	client, index := fc.co.active()
+	nonce, err := client.PendingNonceAt(ctx, account)
+	fc.observe(ctx, index, err)
+	return nonce, err
+}
+
+func (fc *failoverClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	client, index := fc.co.active()
+	err := client.SendTransaction(ctx, tx)
+	fc.observe(ctx, index, err)
+	return err
+}
+
+func (fc *failoverClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	client, index := fc.co.active()
+	receipt, err := client.TransactionReceipt(ctx, hash)
+	fc.observe(ctx, index, err)
+	return receipt, err
+}
+
+func (fc *failoverClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	client, index := fc.co.active()
+	tx, pending, err := client.TransactionByHash(ctx, hash)
+	fc.observe(ctx, index, err)
+	return tx, pending, err
+}
+
+func (fc *failoverClient) CallContract(ctx context.Context, msg geth.CallMsg, number *big.Int) ([]byte, error) {
+	client, index := fc.co.active()
+	result, err := client.CallContract(ctx, msg, number)
+	fc.observe(ctx, index, err)
+	return result, err
+}
+
+func (fc *failoverClient) EstimateGas(ctx context.Context, msg geth.CallMsg) (uint64, error) {
+	client, index := fc.co.active()
+	gas, err := client.EstimateGas(ctx, msg)
+	fc.observe(ctx, index, err)
+	return gas, err
+}
+
+func (fc *failoverClient) FilterLogs(ctx context.Context, query geth.FilterQuery) ([]types.Log, error) {
+	client, index := fc.co.active()
+	logs, err := client.FilterLogs(ctx, query)
+	fc.observe(ctx, index, err)
+	return logs, err
+}
+
+func (fc *failoverClient) FeeHistory(ctx context.Context, blocks uint64, percentiles []float64) (*FeeHistory, error) {
+	client, index := fc.co.active()
+	history, err := client.FeeHistory(ctx, blocks, percentiles)
+	fc.observe(ctx, index, err)
+	return history, err
+}
+
+func (fc *failoverClient) SendRawTransaction(ctx context.Context, raw []byte) error {
+	client, index := fc.co.active()
+	err := client.SendRawTransaction(ctx, raw)
+	fc.observe(ctx, index, err)
+	return err
+}
+
+func (fc *failoverClient) EffectiveGasPrice(ctx context.Context, hash common.Hash) (*big.Int, error) {
+	client, index := fc.co.active()
+	price, err := client.EffectiveGasPrice(ctx, hash)
+	fc.observe(ctx, index, err)
+	return price, err
+}
+
+func (fc *failoverClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (geth.Subscription, error) {
+	fs := newFailoverSubscription(fc.co, "new heads")
+	fs.subscribe = func(ctx context.Context, client Client) (geth.Subscription, error) {
+		return client.SubscribeNewHead(ctx, ch)
+	}
+	return fs, fs.start(ctx)
+}
+
+// SubscribeFilterLogs subscribes to logs, fetching those emitted while the subscription was
+// dropped from the block of the last log delivered, or from the head when it subscribed
+// if none was. Logs of that block may be delivered again.
+func (fc *failoverClient) SubscribeFilterLogs(ctx context.Context, query geth.FilterQuery, ch chan<- types.Log) (geth.Subscription, error) {
+	fs := newFailoverSubscription(fc.co, "logs")
+	follower := &logFollower{query: query, ch: ch, logs: make(chan types.Log), quit: fs.quit, log: fs.log}
+	fs.subscribe = func(ctx context.Context, client Client) (geth.Subscription, error) {
+		return client.SubscribeFilterLogs(ctx, query, follower.logs)
+	}
+	fs.resumed = follower.resume
+
+	header, err := fc.HeaderByNumber(ctx, nil)
+	if err == nil {
+		follower.from = header.Number
+	}
+
+	err = fs.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go follower.run()
+	return fs, nil
+}
+
+func (fc *failoverClient) Close() {
+	fc.co.Close()
+}
+
+// failoverSubscription is a subscription which follows the active endpoint of a connection.
+// It subscribes again when it is dropped or the active endpoint changes, and only ends
+// once it is unsubscribed or the connection is closed.
+type failoverSubscription struct {
+	co        *FailoverConnection
+	subscribe func(ctx context.Context, client Client) (geth.Subscription, error)
+	// resumed is called with the client of each subscription after the first, and may be nil
+	resumed func(ctx context.Context, client Client)
+	err     chan error
+	quit    chan struct{}
+	once    sync.Once
+	log     *logrus.Entry
+}
+
+func newFailoverSubscription(co *FailoverConnection, name string) *failoverSubscription {
+	return &failoverSubscription{
+		co:   co,
+		err:  make(chan error),
+		quit: make(chan struct{}),
+		log:  co.log.WithField("subscription", name),
+	}
+}
+
+func (fs *failoverSubscription) start(ctx context.Context) error {
+	changed := fs.co.pool.Changed()
+	client, index := fs.co.active()
+	sub, err := fs.subscribe(ctx, client)
+	fs.co.pool.Observe(ctx, index, err)
+	if err != nil {
+		return err
+	}
+
+	go fs.run(sub, index, changed)
+	return nil
+}
+
+func (fs *failoverSubscription) run(sub geth.Subscription, index int, changed <-chan struct{}) {
+	defer close(fs.err)
+
+	for {
+		select {
+		case <-fs.quit:
+			sub.Unsubscribe()
+			return
+		case <-fs.co.closed:
+			fs.Unsubscribe()
+			sub.Unsubscribe()
+			return
+		case err := <-sub.Err():
+			fs.log.WithError(err).Warn("Subscription dropped, subscribing again")
+			fs.co.pool.Drop(index, err)
+		case <-changed:
+			sub.Unsubscribe()
+		}
+
+		sub, index, changed = fs.resubscribe()
+		if sub == nil {
+			return
+		}
+	}
+}
+
+// resubscribe subscribes to the active endpoint until it succeeds, returning a nil
+// subscription if the subscription ends first
+func (fs *failoverSubscription) resubscribe() (geth.Subscription, int, <-chan struct{}) {
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			select {
+			case <-fs.quit:
+				return nil, 0, nil
+			case <-fs.co.closed:
+				fs.Unsubscribe()
+				return nil, 0, nil
+			case <-time.After(fs.co.backoff.Delay(retry)):
+			}
+		}
+
+		changed := fs.co.pool.Changed()
+		client, index := fs.co.active()
+
+		ctx, cancel := context.WithTimeout(context.Background(), resubscribeTimeout)
+		sub, err := fs.subscribe(ctx, client)
+		fs.co.pool.Observe(ctx, index, err)
+		if err == nil && fs.resumed != nil {
+			fs.resumed(ctx, client)
+		}
+		cancel()
+
+		if err == nil {
+			fs.log.WithField("endpoint", chain.RedactEndpoint(fs.co.endpoints[index])).Info("Subscribed again")
+			return sub, index, changed
+		}
+		fs.log.WithError(err).WithField("retry", retry+1).Debug("Failed to subscribe again")
+	}
+}
+
+func (fs *failoverSubscription) Unsubscribe() {
+	fs.once.Do(func() {
+		close(fs.quit)
+	})
+}
+
+func (fs *failoverSubscription) Err() <-chan error {
+	return fs.err
+}
+
+// logFollower forwards the logs of the successive subscriptions of a failoverSubscription
+type logFollower struct {
+	query geth.FilterQuery
+	ch    chan<- types.Log
+	logs  chan types.Log
+	quit  <-chan struct{}
+	// held while logs are forwarded, so that missed logs are delivered before newer ones
+	mutex sync.Mutex
+	// block from which missed logs are fetched, nil if unknown
+	from *big.Int
+	log  *logrus.Entry
+}
+
+func (lf *logFollower) run() {
+	for {
+		select {
+		case <-lf.quit:
+			return
+		case log := <-lf.logs:
+			lf.mutex.Lock()
+			lf.forward(log)
+			lf.mutex.Unlock()
+		}
+	}
+}
+
+// forward delivers a log, returning false if the subscription ended first
+func (lf *logFollower) forward(log types.Log) bool {
+	select {
+	case <-lf.quit:
+		return false
+	case lf.ch <- log:
+		if !log.Removed {
+			lf.from = new(big.Int).SetUint64(log.BlockNumber)
+		}
+		return true
+	}
+}
+
+// resume fetches and delivers the logs emitted since the block of the last log delivered
+func (lf *logFollower) resume(ctx context.Context, client Client) {
+	lf.mutex.Lock()
+	defer lf.mutex.Unlock()
+
+	if lf.from == nil {
+		return
+	}
+
+	query := lf.query
+	query.FromBlock = lf.from
+	query.ToBlock = nil
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		lf.log.WithError(err).WithField("from", lf.from).Warn("Failed to fetch logs emitted while subscribing again")
+		return
+	}
+
+	for _, log := range logs {
+		if !lf.forward(log) {
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// flakyClient is an endpoint of a MockClient which can go down, failing its calls and
+// dropping its subscriptions
+type flakyClient struct {
+	*MockClient
+	mutex sync.Mutex
+	err   error
+	drops []chan error
+}
+
+func (fc *flakyClient) fail(err error) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	fc.err = err
+	for _, drop := range fc.drops {
+		drop <- err
+	}
+	fc.drops = nil
+}
+
+func (fc *flakyClient) failure() error {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return fc.err
+}
+
+func (fc *flakyClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	if err := fc.failure(); err != nil {
+		return nil, err
+	}
+	return fc.MockClient.NetworkID(ctx)
+}
+
+func (fc *flakyClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := fc.failure(); err != nil {
+		return nil, err
+	}
+	return fc.MockClient.HeaderByNumber(ctx, number)
+}
+
+// SubscribeFilterLogs forwards the logs of the chain until the endpoint goes down
+func (fc *flakyClient) SubscribeFilterLogs(ctx context.Context, query geth.FilterQuery, ch chan<- types.Log) (geth.Subscription, error) {
+	if err := fc.failure(); err != nil {
+		return nil, err
+	}
+
+	logs := make(chan types.Log)
+	_, err := fc.MockClient.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.mutex.Lock()
+	drop := make(chan error, 1)
+	fc.drops = append(fc.drops, drop)
+	fc.mutex.Unlock()
+
+	go func() {
+		for log := range logs {
+			if fc.failure() == nil {
+				ch <- log
+			}
+		}
+	}()
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		select {
+		case <-quit:
+			return nil
+		case err := <-drop:
+			return err
+		}
+	}), nil
+}
+
+func TestFailoverConnection(t *testing.T) {
+	mock := NewMockClient(big.NewInt(1))
+	primary := &flakyClient{MockClient: mock}
+	fallback := &flakyClient{MockClient: mock}
+	other := &flakyClient{MockClient: NewMockClient(big.NewInt(5))}
+
+	config := &chain.RPCConfig{Failover: chain.FailoverConfig{MaxErrors: 1}}
+	conn := NewFailoverConnection([]string{"ws://primary:8545", "ws://other:8545", "ws://fallback:8545"}, secp256k1.Alice(), config, logrus.NewEntry(logrus.New()))
+	conn.dial = func(_ context.Context, endpoint string, _ *chain.RPCStats) (Client, error) {
+		switch endpoint {
+		case "ws://primary:8545":
+			return primary, nil
+		case "ws://other:8545":
+			return other, nil
+		}
+		return fallback, nil
+	}
+	require.NoError(t, conn.Connect(context.Background()))
+	defer conn.Close()
+
+	logs := make(chan types.Log)
+	_, err := conn.Client().SubscribeFilterLogs(context.Background(), geth.FilterQuery{}, logs)
+	require.NoError(t, err)
+
+	address := common.HexToAddress("0x1")
+	mock.AddBlock(types.Log{Address: address})
+	assert.Equal(t, uint64(1), (<-logs).BlockNumber)
+
+	// the logs emitted while the subscription is dropped are fetched from the endpoint of
+	// the same network which the connection fails over to
+	changed := conn.Pool().Changed()
+	primary.fail(fmt.Errorf("websocket closed"))
+	mock.AddBlock(types.Log{Address: address})
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("connection didn't fail over")
+	}
+	assert.Equal(t, 2, conn.Pool().Active())
+
+	received := map[uint64]bool{}
+	for !received[2] {
+		select {
+		case log := <-logs:
+			received[log.BlockNumber] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("missed log was not delivered")
+		}
+	}
+
+	// calls are made to the active endpoint
+	header, err := conn.Client().HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), header.Number.Uint64())
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type DynamicFeeConfig struct {
+	// Whether deliveries are sent as EIP-1559 transactions on chains which support London.
+	// Legacy gas prices are used if disabled.
+	Enabled bool `mapstructure:"enabled"`
+	// Recent blocks whose priority fees are sampled. Defaults to 10.
+	Blocks uint64 `mapstructure:"blocks"`
+	// Percentile of the priority fees paid in each sampled block, whose median over the
+	// blocks is offered. Defaults to 50.
+	Percentile float64 `mapstructure:"percentile"`
+	// Percentage added to the base fee of the next block in the max fee per gas, so that a
+	// transaction stays includable while the base fee rises. Defaults to 100, which covers
+	// six full blocks.
+	BaseFeeHeadroom uint64 `mapstructure:"base-fee-headroom"`
+	// Highest max fee per gas in wei. Unlimited if empty.
+	MaxFeePerGas string `mapstructure:"max-fee-per-gas"`
+	// Highest max priority fee per gas in wei. Unlimited if empty.
+	MaxPriorityFeePerGas string `mapstructure:"max-priority-fee-per-gas"`
+}
+
+const (
+	defaultFeeHistoryBlocks = 10
+	defaultFeePercentile    = 50
+	defaultBaseFeeHeadroom  = 100
+)
+
+// defaultPriorityFee is offered when the sampled blocks paid no priority fees, 1 gwei
+var defaultPriorityFee = big.NewInt(1000000000)
+
+// FeeHistory is the result of eth_feeHistory
+type FeeHistory struct {
+	OldestBlock *hexutil.Big `json:"oldestBlock"`
+	// Base fees of the sampled blocks, followed by the base fee of the next block
+	BaseFeePerGas []*hexutil.Big `json:"baseFeePerGas"`
+	GasUsedRatio  []float64      `json:"gasUsedRatio"`
+	// Priority fees at the requested percentiles, per sampled block
+	Reward [][]*hexutil.Big `json:"reward"`
+}
+
+// FeeOracle suggests the fees of EIP-1559 transactions from the base fee of the next block
+// and the priority fees paid in recent blocks. Chains which don't support London, whose
+// nodes don't serve eth_feeHistory or report no base fee, are left to legacy gas prices.
+// The suggested fees are exported as metrics.
+type FeeOracle struct {
+	enabled    bool
+	blocks     uint64
+	percentile float64
+	headroom   uint64
+	// caps, nil if unlimited
+	maxFee *big.Int
+	maxTip *big.Int
+	mutex  sync.Mutex
+	// whether the chain was last found not to support London, to log changes
+	legacy bool
+	log    *logrus.Entry
+}
+
+func NewFeeOracle(config *DynamicFeeConfig, log *logrus.Entry) (*FeeOracle, error) {
+	fo := &FeeOracle{
+		enabled:    config.Enabled,
+		blocks:     config.Blocks,
+		percentile: config.Percentile,
+		headroom:   config.BaseFeeHeadroom,
+		log:        log,
+	}
+	if fo.blocks == 0 {
+		fo.blocks = defaultFeeHistoryBlocks
+	}
+	if fo.percentile == 0 {
+		fo.percentile = defaultFeePercentile
+	}
+	if fo.percentile < 0 || fo.percentile > 100 {
+		return nil, fmt.Errorf("fee percentile is not between 0 and 100")
+	}
+	if fo.headroom == 0 {
+		fo.headroom = defaultBaseFeeHeadroom
+	}
+
+	var err error
+	fo.maxFee, err = parseFeeCap("max fee per gas", config.MaxFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+	fo.maxTip, err = parseFeeCap("max priority fee per gas", config.MaxPriorityFeePerGas)
+	if err != nil {
+		return nil, err
+	}
+
+	return fo, nil
+}
+
+func parseFeeCap(name string, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	fee, ok := new(big.Int).SetString(value, 10)
+	if !ok || fee.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return fee, nil
+}
+
+// Enabled returns whether dynamic fee transactions are sent where the chain supports them
+func (fo *FeeOracle) Enabled() bool {
+	return fo.enabled
+}
+
+// Suggest returns the max priority fee and the max fee per gas to offer, or false if the
+// chain doesn't support London
+func (fo *FeeOracle) Suggest(ctx context.Context, client Client) (*big.Int, *big.Int, bool, error) {
+	history, err := client.FeeHistory(ctx, fo.blocks, []float64{fo.percentile})
+	if err != nil {
+		if unsupportedMethod(err) {
+			fo.setLegacy(true, err.Error())
+			return nil, nil, false, nil
+		}
+		return nil, nil, false, err
+	}
+
+	if len(history.BaseFeePerGas) == 0 || history.BaseFeePerGas[len(history.BaseFeePerGas)-1].ToInt().Sign() == 0 {
+		fo.setLegacy(true, "no base fee is reported")
+		return nil, nil, false, nil
+	}
+	fo.setLegacy(false, "")
+
+	baseFee := history.BaseFeePerGas[len(history.BaseFeePerGas)-1].ToInt()
+	tip := medianReward(history.Reward)
+	feeCap := new(big.Int).Add(bumpGasPrice(baseFee, fo.headroom), tip)
+
+	metrics.FeePerGas.WithLabelValues(Name, "base").Set(weiToFloat(baseFee))
+	metrics.FeePerGas.WithLabelValues(Name, "priority").Set(weiToFloat(tip))
+
+	return tip, feeCap, true, nil
+}
+
+// limit caps the fees offered by a transaction, and the priority fee at the max fee
+func (fo *FeeOracle) limit(tip *big.Int, feeCap *big.Int) (*big.Int, *big.Int) {
+	if fo.maxTip != nil && tip.Cmp(fo.maxTip) > 0 {
+		tip = fo.maxTip
+	}
+	if fo.maxFee != nil && feeCap.Cmp(fo.maxFee) > 0 {
+		feeCap = fo.maxFee
+	}
+	if tip.Cmp(feeCap) > 0 {
+		tip = feeCap
+	}
+	return new(big.Int).Set(tip), new(big.Int).Set(feeCap)
+}
+
+func (fo *FeeOracle) setLegacy(legacy bool, reason string) {
+	fo.mutex.Lock()
+	defer fo.mutex.Unlock()
+
+	if legacy && !fo.legacy {
+		fo.log.WithField("reason", reason).Warn("Chain doesn't support dynamic fees, falling back to legacy gas prices")
+	} else if !legacy && fo.legacy {
+		fo.log.Info("Chain supports dynamic fees again")
+	}
+	fo.legacy = legacy
+}
+
+// medianReward returns the median of the priority fees sampled from each block, or the
+// default priority fee if blocks paid none
+func medianReward(rewards [][]*hexutil.Big) *big.Int {
+	fees := []*big.Int{}
+	for _, block := range rewards {
+		if len(block) > 0 && block[0] != nil {
+			fees = append(fees, block[0].ToInt())
+		}
+	}
+	if len(fees) == 0 {
+		return new(big.Int).Set(defaultPriorityFee)
+	}
+
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].Cmp(fees[j]) < 0
+	})
+	median := new(big.Int).Set(fees[len(fees)/2])
+	if median.Sign() == 0 {
+		return new(big.Int).Set(defaultPriorityFee)
+	}
+	return median
+}
+
+// unsupportedMethod returns whether an RPC call failed as the node doesn't serve its method
+func unsupportedMethod(err error) bool {
+	if rpcErr, ok := err.(rpc.Error); ok && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "method not found") ||
+		strings.Contains(message, "does not exist") ||
+		strings.Contains(message, "not supported")
+}
+
+func weiToFloat(wei *big.Int) float64 {
+	value, _ := new(big.Float).SetInt(wei).Float64()
+	return value
+}
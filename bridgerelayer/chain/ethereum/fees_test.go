@@ -0,0 +1,150 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func feeHistory(nextBaseFee int64, rewards ...int64) *FeeHistory {
+	history := &FeeHistory{
+		OldestBlock:   (*hexutil.Big)(big.NewInt(1)),
+		BaseFeePerGas: []*hexutil.Big{},
+	}
+	for _, reward := range rewards {
+		history.BaseFeePerGas = append(history.BaseFeePerGas, (*hexutil.Big)(big.NewInt(nextBaseFee)))
+		history.GasUsedRatio = append(history.GasUsedRatio, 0.5)
+		history.Reward = append(history.Reward, []*hexutil.Big{(*hexutil.Big)(big.NewInt(reward))})
+	}
+	history.BaseFeePerGas = append(history.BaseFeePerGas, (*hexutil.Big)(big.NewInt(nextBaseFee)))
+	return history
+}
+
+func TestSignDynamicFeeTx(t *testing.T) {
+	kp := secp256k1.Alice()
+	to := common.Address{1}
+
+	tx, err := signDynamicFeeTx(kp, big.NewInt(15), 3, to, gasLimit, big.NewInt(2), big.NewInt(200), []byte{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, byte(dynamicFeeTxType), tx.Raw()[0])
+	assert.Equal(t, crypto.Keccak256Hash(tx.Raw()), tx.Hash())
+
+	decoded, sender, err := decodeDynamicFeeTx(tx.Raw())
+	require.NoError(t, err)
+	assert.Equal(t, kp.CommonAddress(), sender)
+	assert.Equal(t, big.NewInt(15), decoded.chainID)
+	assert.Equal(t, uint64(3), decoded.Nonce())
+	assert.Equal(t, to, *decoded.To())
+	assert.Equal(t, big.NewInt(2), decoded.GasTipCap())
+	assert.Equal(t, big.NewInt(200), decoded.GasPrice())
+	assert.Equal(t, []byte{1, 2, 3}, decoded.Data())
+
+	_, _, err = decodeDynamicFeeTx(tx.Raw()[1:])
+	assert.Error(t, err)
+}
+
+func TestFeeOracle_Suggest(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+	ctx := context.Background()
+	client := NewMockClient(big.NewInt(15))
+
+	_, err := NewFeeOracle(&DynamicFeeConfig{MaxFeePerGas: "-1"}, log)
+	assert.Error(t, err)
+
+	oracle, err := NewFeeOracle(&DynamicFeeConfig{Enabled: true}, log)
+	require.NoError(t, err)
+
+	// chains whose nodes don't serve the fee history are priced with legacy gas prices
+	_, _, ok, err := oracle.Suggest(ctx, client)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// as are chains which report no base fee
+	client.SetFeeHistory(feeHistory(0, 3))
+	_, _, ok, err = oracle.Suggest(ctx, client)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// the median priority fee is offered, on top of twice the next base fee
+	client.SetFeeHistory(feeHistory(100, 3, 1, 2))
+	tip, feeCap, ok, err := oracle.Suggest(ctx, client)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(2), tip)
+	assert.Equal(t, big.NewInt(202), feeCap)
+
+	// blocks without priority fees get the default priority fee
+	client.SetFeeHistory(feeHistory(100))
+	tip, _, _, err = oracle.Suggest(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, defaultPriorityFee, tip)
+
+	// fees are capped, with the priority fee no higher than the max fee
+	oracle, err = NewFeeOracle(&DynamicFeeConfig{Enabled: true, MaxFeePerGas: "150", MaxPriorityFeePerGas: "200"}, log)
+	require.NoError(t, err)
+	tip, feeCap = oracle.limit(big.NewInt(300), big.NewInt(400))
+	assert.Equal(t, big.NewInt(150), tip)
+	assert.Equal(t, big.NewInt(150), feeCap)
+}
+
+func TestWriter_DynamicFees(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	client.SetGasPrice(big.NewInt(100))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+
+	config := &Config{
+		DynamicFees:   DynamicFeeConfig{Enabled: true},
+		Confirmations: ConfirmationConfig{StuckTimeout: 60},
+	}
+	wr, err := NewWriter(config, conn, nil, nil, nil, nil, log)
+	require.NoError(t, err)
+	wr.chainID = big.NewInt(15)
+	wr.pending.timeout = time.Millisecond
+
+	ctx := context.Background()
+	app := common.Address{1}
+
+	// before London, legacy transactions are sent
+	_, gasPrice, err := wr.send(ctx, conn.Keypair(), app, gasLimit, []byte{1}, false)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), gasPrice)
+	require.Len(t, client.Sent(), 1)
+
+	// and dynamic fee transactions once the chain supports them
+	client.SetFeeHistory(feeHistory(100, 10))
+	hash, maxFee, err := wr.send(ctx, conn.Keypair(), app, gasLimit, []byte{2}, false)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(210), maxFee)
+	sent := client.sentDynamicFeeTxs()
+	require.Len(t, sent, 1)
+	assert.Equal(t, hash, sent[0].Hash())
+	assert.Equal(t, uint64(1), sent[0].Nonce())
+	assert.Equal(t, big.NewInt(10), sent[0].GasTipCap())
+
+	// stuck dynamic fee transactions are replaced with both fees bumped
+	time.Sleep(5 * time.Millisecond)
+	wr.rebroadcast(ctx, hash, log)
+	sent = client.sentDynamicFeeTxs()
+	require.Len(t, sent, 2)
+	assert.Equal(t, sent[0].Nonce(), sent[1].Nonce())
+	assert.Equal(t, big.NewInt(12), sent[1].GasTipCap())
+	assert.Equal(t, big.NewInt(236), sent[1].GasPrice())
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// FieldLimits bound the size of the unbounded string and bytes arguments of an app's events,
+// so that a single oversized event doesn't produce a message which the target chain refuses
+// and which blocks the messages ordered after it. Oversized events are either quarantined or
+// relayed with their arguments truncated, depending on the policy.
+type FieldLimits struct {
+	truncate bool
+	// limited arguments of each event, by event ID
+	events map[common.Hash]fieldEvent
+}
+
+type fieldEvent struct {
+	inputs abi.Arguments
+	// maximum sizes by position among the non-indexed inputs
+	sizes map[int]int
+}
+
+// NewFieldLimits returns the field limits of an app, or nil if it limits no argument. Limits
+// must name string or bytes arguments of the app's events. Call arguments are SCALE-encoded
+// and can't be truncated.
+func NewFieldLimits(limits *Limits, contractABI *abi.ABI, call *CallConfig) (*FieldLimits, error) {
+	if len(limits.MaxFieldSizes) == 0 {
+		return nil, nil
+	}
+
+	var truncate bool
+	switch limits.FieldPolicy {
+	case "", FieldPolicyQuarantine:
+	case FieldPolicyTruncate:
+		truncate = true
+	default:
+		return nil, fmt.Errorf("unknown field policy: %s", limits.FieldPolicy)
+	}
+
+	if truncate && call != nil {
+		field := call.Field
+		if field == "" {
+			field = defaultCallArgsField
+		}
+		if _, ok := limits.MaxFieldSizes[field]; ok {
+			return nil, fmt.Errorf("call arguments %s can't be truncated", field)
+		}
+	}
+
+	events := make(map[common.Hash]fieldEvent)
+	found := make(map[string]bool)
+	for _, event := range contractABI.Events {
+		inputs := event.Inputs.NonIndexed()
+		sizes := make(map[int]int)
+		for i, input := range inputs {
+			size, ok := limits.MaxFieldSizes[input.Name]
+			if !ok || size <= 0 {
+				continue
+			}
+			if input.Type.T != abi.StringTy && input.Type.T != abi.BytesTy {
+				return nil, fmt.Errorf("argument %s of event %s is not a string or bytes", input.Name, event.Name)
+			}
+			sizes[i] = size
+			found[input.Name] = true
+		}
+		if len(sizes) > 0 {
+			events[event.ID] = fieldEvent{inputs: inputs, sizes: sizes}
+		}
+	}
+
+	for name := range limits.MaxFieldSizes {
+		if !found[name] {
+			return nil, fmt.Errorf("no event has a string or bytes argument named %s", name)
+		}
+	}
+
+	return &FieldLimits{truncate: truncate, events: events}, nil
+}
+
+// Check verifies that the arguments of an event fit their limits
+func (fl *FieldLimits) Check(event *gethTypes.Log) error {
+	oversized, _, err := fl.oversized(event)
+	if err != nil || len(oversized) == 0 {
+		return err
+	}
+
+	positions := make([]int, 0, len(oversized))
+	for i := range oversized {
+		positions = append(positions, i)
+	}
+	sort.Ints(positions)
+
+	ev := fl.events[event.Topics[0]]
+	i := positions[0]
+	return fmt.Errorf("argument %s is %d bytes, exceeding the limit of %d", ev.inputs[i].Name, oversized[i], ev.sizes[i])
+}
+
+// Truncate returns the event with its oversized arguments truncated and re-encoded, and the
+// names of the truncated arguments, leaving the original unchanged. Events are returned as
+// they are unless the policy is to truncate. Strings are cut at a character boundary.
+func (fl *FieldLimits) Truncate(event gethTypes.Log) (gethTypes.Log, []string, error) {
+	if fl == nil || !fl.truncate {
+		return event, nil, nil
+	}
+
+	oversized, values, err := fl.oversized(&event)
+	if err != nil || len(oversized) == 0 {
+		return event, nil, err
+	}
+
+	ev := fl.events[event.Topics[0]]
+	truncated := []string{}
+	for i, input := range ev.inputs {
+		if _, ok := oversized[i]; !ok {
+			continue
+		}
+		size := ev.sizes[i]
+		switch value := values[i].(type) {
+		case string:
+			for size > 0 && !utf8.RuneStart(value[size]) {
+				size--
+			}
+			values[i] = value[:size]
+		case []byte:
+			values[i] = value[:size]
+		}
+		truncated = append(truncated, input.Name)
+	}
+
+	data, err := ev.inputs.Pack(values...)
+	if err != nil {
+		return event, nil, err
+	}
+	event.Data = data
+
+	return event, truncated, nil
+}
+
+// oversized returns the sizes of the arguments of an event which exceed their limits, by
+// position, along with the decoded arguments
+func (fl *FieldLimits) oversized(event *gethTypes.Log) (map[int]int, []interface{}, error) {
+	if fl == nil || len(event.Topics) == 0 {
+		return nil, nil, nil
+	}
+
+	ev, ok := fl.events[event.Topics[0]]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	values, err := ev.inputs.UnpackValues(event.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oversized := make(map[int]int)
+	for i, size := range ev.sizes {
+		var length int
+		switch value := values[i].(type) {
+		case string:
+			length = len(value)
+		case []byte:
+			length = len(value)
+		}
+		if length > size {
+			oversized[i] = length
+		}
+	}
+
+	return oversized, values, nil
+}
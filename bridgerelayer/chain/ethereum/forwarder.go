@@ -0,0 +1,311 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// ForwarderABI is the subset of an EIP-2771 MinimalForwarder used by the relayer
+const ForwarderABI = `
+[
+	{
+		"inputs": [
+			{
+				"internalType": "address",
+				"name": "from",
+				"type": "address"
+			}
+		],
+		"name": "getNonce",
+		"outputs": [
+			{
+				"internalType": "uint256",
+				"name": "",
+				"type": "uint256"
+			}
+		],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [
+			{
+				"components": [
+					{ "internalType": "address", "name": "from", "type": "address" },
+					{ "internalType": "address", "name": "to", "type": "address" },
+					{ "internalType": "uint256", "name": "value", "type": "uint256" },
+					{ "internalType": "uint256", "name": "gas", "type": "uint256" },
+					{ "internalType": "uint256", "name": "nonce", "type": "uint256" },
+					{ "internalType": "bytes", "name": "data", "type": "bytes" }
+				],
+				"internalType": "struct MinimalForwarder.ForwardRequest",
+				"name": "req",
+				"type": "tuple"
+			},
+			{
+				"internalType": "bytes",
+				"name": "signature",
+				"type": "bytes"
+			}
+		],
+		"name": "execute",
+		"outputs": [
+			{ "internalType": "bool", "name": "", "type": "bool" },
+			{ "internalType": "bytes", "name": "", "type": "bytes" }
+		],
+		"stateMutability": "payable",
+		"type": "function"
+	}
+]
+`
+
+var (
+	eip712DomainTypeHash   = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	forwardRequestTypeHash = crypto.Keccak256([]byte("ForwardRequest(address from,address to,uint256 value,uint256 gas,uint256 nonce,bytes data)"))
+)
+
+// ForwarderDomain is the EIP-712 signing domain of a forwarder contract
+type ForwarderDomain struct {
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract common.Address
+}
+
+// Separator returns the EIP-712 domain separator
+func (d *ForwarderDomain) Separator() []byte {
+	return crypto.Keccak256(
+		eip712DomainTypeHash,
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		math.U256Bytes(new(big.Int).Set(d.ChainID)),
+		common.LeftPadBytes(d.VerifyingContract.Bytes(), 32),
+	)
+}
+
+// ForwardRequest is a meta-transaction which a forwarder executes on behalf of From
+type ForwardRequest struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Gas   *big.Int
+	Nonce *big.Int
+	Data  []byte
+}
+
+// Digest returns the EIP-712 digest which the sender of the request signs
+func (r *ForwardRequest) Digest(domain *ForwarderDomain) []byte {
+	structHash := crypto.Keccak256(
+		forwardRequestTypeHash,
+		common.LeftPadBytes(r.From.Bytes(), 32),
+		common.LeftPadBytes(r.To.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(r.Value)),
+		math.U256Bytes(new(big.Int).Set(r.Gas)),
+		math.U256Bytes(new(big.Int).Set(r.Nonce)),
+		crypto.Keccak256(r.Data),
+	)
+
+	return crypto.Keccak256([]byte{0x19, 0x01}, domain.Separator(), structHash)
+}
+
+// Sign produces the signature expected by the forwarder's execute function
+func (r *ForwardRequest) Sign(domain *ForwarderDomain, kp *secp256k1.Keypair) ([]byte, error) {
+	signature, err := crypto.Sign(r.Digest(domain), kp.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// Solidity's ecrecover expects v in {27, 28}
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return signature, nil
+}
+
+// Forwarder wraps application calls into meta-transactions for a gas-sponsoring forwarder contract
+type Forwarder struct {
+	abi    abi.ABI
+	domain ForwarderDomain
+	// nonces of each signer, tracked locally so that requests signed before the previous
+	// one is mined do not reuse a nonce
+	nonces map[common.Address]*signerNonces
+	mutex  sync.Mutex
+}
+
+type signerNonces struct {
+	// next nonce, nil once it must be taken from the forwarder again
+	next *big.Int
+	// requests which were signed but not sent yet
+	reserved int
+	// transactions of requests which were sent but not settled yet
+	sent map[common.Hash]struct{}
+}
+
+func NewForwarder(config *ForwarderConfig, chainID *big.Int) (*Forwarder, error) {
+	forwarderABI, err := abi.JSON(strings.NewReader(ForwarderABI))
+	if err != nil {
+		return nil, err
+	}
+
+	if !common.IsHexAddress(config.Address) {
+		return nil, fmt.Errorf("invalid forwarder address: %s", config.Address)
+	}
+
+	return &Forwarder{
+		abi: forwarderABI,
+		domain: ForwarderDomain{
+			Name:              config.DomainName,
+			Version:           config.DomainVersion,
+			ChainID:           chainID,
+			VerifyingContract: common.HexToAddress(config.Address),
+		},
+		nonces: make(map[common.Address]*signerNonces),
+	}, nil
+}
+
+// Address returns the address of the forwarder contract
+func (fw *Forwarder) Address() common.Address {
+	return fw.domain.VerifyingContract
+}
+
+// Wrap builds and signs a forward request for a call to the given contract, returning
+// the call data for the forwarder's execute function
+func (fw *Forwarder) Wrap(ctx context.Context, conn Connection, to common.Address, gas uint64, data []byte) ([]byte, error) {
+	from := conn.Keypair().CommonAddress()
+
+	nonce, err := fw.nextNonce(ctx, conn, from)
+	if err != nil {
+		return nil, err
+	}
+
+	request := ForwardRequest{
+		From:  from,
+		To:    to,
+		Value: big.NewInt(0),
+		Gas:   new(big.Int).SetUint64(gas),
+		Nonce: nonce,
+		Data:  data,
+	}
+
+	signature, err := request.Sign(&fw.domain, conn.Keypair())
+	if err != nil {
+		fw.Reset(from)
+		return nil, err
+	}
+
+	data, err = fw.abi.Pack("execute", request, signature)
+	if err != nil {
+		fw.Reset(from)
+		return nil, err
+	}
+	return data, nil
+}
+
+// nextNonce reserves the next forwarder nonce for the signer, which must then be reported
+// as sent or reset. The local counter is reconciled with the forwarder's view so that
+// externally submitted requests are not reused, and is only used while requests of the
+// signer are in flight: a counter ahead of the forwarder without any means that requests
+// were dropped or reverted, and left a gap which the next request fills.
+func (fw *Forwarder) nextNonce(ctx context.Context, conn Connection, from common.Address) (*big.Int, error) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	onchain, err := fw.fetchNonce(ctx, conn, from)
+	if err != nil {
+		return nil, err
+	}
+
+	sn, ok := fw.nonces[from]
+	if !ok {
+		sn = &signerNonces{sent: make(map[common.Hash]struct{})}
+		fw.nonces[from] = sn
+	}
+
+	nonce := onchain
+	inFlight := sn.reserved + len(sn.sent)
+	if sn.next != nil && sn.next.Cmp(onchain) > 0 && inFlight > 0 {
+		nonce = sn.next
+	}
+
+	sn.next = new(big.Int).Add(nonce, big.NewInt(1))
+	sn.reserved++
+
+	return nonce, nil
+}
+
+// Sent reports that the transaction of the request last reserved for a signer was sent
+func (fw *Forwarder) Sent(from common.Address, hash common.Hash) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	if sn, ok := fw.nonces[from]; ok && sn.reserved > 0 {
+		sn.reserved--
+		sn.sent[hash] = struct{}{}
+	}
+}
+
+// Reset discards the locally tracked nonce for a signer after a request failed to be
+// signed or sent
+func (fw *Forwarder) Reset(from common.Address) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	if sn, ok := fw.nonces[from]; ok {
+		if sn.reserved > 0 {
+			sn.reserved--
+		}
+		sn.next = nil
+	}
+}
+
+// Settled reports whether the transaction of a sent request was executed. The nonce of a
+// request which wasn't is taken from the forwarder again. Transactions which aren't of this
+// forwarder are ignored.
+func (fw *Forwarder) Settled(hash common.Hash, executed bool) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	for _, sn := range fw.nonces {
+		if _, ok := sn.sent[hash]; ok {
+			delete(sn.sent, hash)
+			if !executed {
+				sn.next = nil
+			}
+			return
+		}
+	}
+}
+
+func (fw *Forwarder) fetchNonce(ctx context.Context, conn Connection, from common.Address) (*big.Int, error) {
+	input, err := fw.abi.Pack("getNonce", from)
+	if err != nil {
+		return nil, err
+	}
+
+	address := fw.Address()
+	output, err := conn.Client().CallContract(ctx, geth.CallMsg{From: from, To: &address, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce *big.Int
+	err = fw.abi.Unpack(&nonce, "getNonce", output)
+	if err != nil {
+		return nil, err
+	}
+
+	return nonce, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestForwardRequest_Sign(t *testing.T) {
+	kp := secp256k1.Alice()
+
+	domain := ethereum.ForwarderDomain{
+		Name:              "MinimalForwarder",
+		Version:           "0.0.1",
+		ChainID:           big.NewInt(1),
+		VerifyingContract: common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"),
+	}
+
+	request := ethereum.ForwardRequest{
+		From:  kp.CommonAddress(),
+		To:    common.HexToAddress("0xC4cE93a5699c68241fc2fB503Fb0f21724A624BB"),
+		Value: big.NewInt(0),
+		Gas:   big.NewInt(2000000),
+		Nonce: big.NewInt(3),
+		Data:  []byte{0, 1, 2},
+	}
+
+	signature, err := request.Sign(&domain, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, signature, 65)
+	assert.Contains(t, []byte{27, 28}, signature[64])
+
+	// Recover the signer the same way the forwarder contract does
+	signature[64] -= 27
+	pub, err := crypto.SigToPub(request.Digest(&domain), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, kp.CommonAddress(), crypto.PubkeyToAddress(*pub))
+
+	// The digest is bound to the domain
+	other := domain
+	other.ChainID = big.NewInt(42)
+	assert.NotEqual(t, request.Digest(&domain), request.Digest(&other))
+}
+
+func TestForwardRequest_Digest(t *testing.T) {
+	domain := ethereum.ForwarderDomain{
+		Name:              "MinimalForwarder",
+		Version:           "0.0.1",
+		ChainID:           big.NewInt(1),
+		VerifyingContract: common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"),
+	}
+
+	request := ethereum.ForwardRequest{
+		From:  common.HexToAddress("0x0c6CD6Dc5258EF556eA7c6dab2abE302fB60e0b6"),
+		To:    common.HexToAddress("0xC4cE93a5699c68241fc2fB503Fb0f21724A624BB"),
+		Value: big.NewInt(0),
+		Gas:   big.NewInt(2000000),
+		Nonce: big.NewInt(3),
+		Data:  []byte{0, 1, 2},
+	}
+
+	// hashed with the EIP712Domain and ForwardRequest type hashes of the MinimalForwarder,
+	// 0x8b73c3c6... and 0xdd8f4b70...
+	assert.Equal(t, "0xe5920f5c6e782baf0294a4f3cd1febd3b671913ec9c9b6b01c79a21d0c086370", hexutil.Encode(domain.Separator()))
+	assert.Equal(t, "0xc04b10d872ba1bee2bf111dc168e91b162c1f77322d0f1a13cb38d4f93eff25f", hexutil.Encode(request.Digest(&domain)))
+}
+
+// forwardedNonce returns the nonce of the request wrapped in the call data of execute
+func forwardedNonce(t *testing.T, data []byte) uint64 {
+	forwarderABI, err := abi.JSON(strings.NewReader(ethereum.ForwarderABI))
+	require.NoError(t, err)
+	args, err := forwarderABI.Methods["execute"].Inputs.UnpackValues(data[4:])
+	require.NoError(t, err)
+	return reflect.ValueOf(args[0]).FieldByName("Nonce").Interface().(*big.Int).Uint64()
+}
+
+func TestForwarder_Nonces(t *testing.T) {
+	config := &ethereum.ForwarderConfig{Address: "0x5FbDB2315678afecb367f032d93F642f64180aa3", DomainName: "MinimalForwarder", DomainVersion: "0.0.1"}
+	forwarder, err := ethereum.NewForwarder(config, big.NewInt(1))
+	require.NoError(t, err)
+
+	client := ethereum.NewMockClient(big.NewInt(1))
+	conn := ethereum.NewMockConnection(secp256k1.Alice(), client)
+	from := conn.Keypair().CommonAddress()
+	setNonce := func(nonce int64) {
+		client.SetCallResult(forwarder.Address(), common.LeftPadBytes(big.NewInt(nonce).Bytes(), 32))
+	}
+	setNonce(3)
+
+	ctx := context.Background()
+	wrap := func() uint64 {
+		data, err := forwarder.Wrap(ctx, conn, common.HexToAddress("0x0102"), 100000, []byte{1})
+		require.NoError(t, err)
+		return forwardedNonce(t, data)
+	}
+
+	// requests signed before the previous one is executed take the nonces after it
+	assert.Equal(t, uint64(3), wrap())
+	forwarder.Sent(from, common.Hash{1})
+	assert.Equal(t, uint64(4), wrap())
+	forwarder.Sent(from, common.Hash{2})
+
+	// a request which wasn't executed leaves a gap, which the next request fills
+	forwarder.Settled(common.Hash{1}, false)
+	assert.Equal(t, uint64(3), wrap())
+	forwarder.Sent(from, common.Hash{3})
+
+	forwarder.Settled(common.Hash{2}, true)
+	forwarder.Settled(common.Hash{3}, true)
+	setNonce(4)
+	assert.Equal(t, uint64(4), wrap())
+	forwarder.Sent(from, common.Hash{4})
+
+	// the forwarder's nonce is trusted once nothing is in flight, even when behind the local one
+	forwarder.Settled(common.Hash{4}, true)
+	assert.Equal(t, uint64(4), wrap())
+
+	// and a request which was never sent releases its nonce
+	forwarder.Reset(from)
+	assert.Equal(t, uint64(4), wrap())
+}
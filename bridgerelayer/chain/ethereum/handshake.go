@@ -0,0 +1,99 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// VersionABI contains the getter through which the app contracts report the payload schema
+// version they accept
+const VersionABI = `
+[
+	{
+		"inputs": [],
+		"name": "version",
+		"outputs": [{ "internalType": "uint256", "name": "", "type": "uint256" }],
+		"stateMutability": "view",
+		"type": "function"
+	}
+]
+`
+
+var versionABI = mustParseABI(VersionABI)
+
+// Handshake reads the payload schema version of the contract of each app, and refuses to
+// relay unless the relayer supports all of them
+func (ch *Chain) Handshake(ctx context.Context) (*chain.CompatibilityReport, error) {
+	report := chain.NewCompatibilityReport(ContractVersions(ctx, ch.conn.Client(), ch.config.Apps))
+	for _, component := range report.Components {
+		logrus.WithFields(logrus.Fields{
+			"chain":     Name,
+			"app":       component.App,
+			"component": component.Component,
+			"version":   component.Version,
+		}).Info("Negotiated payload schema version")
+	}
+	return report, report.Err()
+}
+
+// ContractVersions reads the payload schema version of the contract of each app, in the
+// order of their names
+func ContractVersions(ctx context.Context, client Client, apps map[string]Application) []chain.ComponentVersion {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make([]chain.ComponentVersion, 0, len(names))
+	for _, name := range names {
+		address := common.HexToAddress(apps[name].Address)
+		component := chain.ComponentVersion{Chain: Name, App: name, Component: address.Hex()}
+
+		version, err := contractVersion(ctx, client, address)
+		if err != nil {
+			component.Error = err.Error()
+		} else {
+			component.Version = version
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+func contractVersion(ctx context.Context, client Client, address common.Address) (uint64, error) {
+	input, err := versionABI.Pack("version")
+	if err != nil {
+		return 0, err
+	}
+
+	output, err := client.CallContract(ctx, geth.CallMsg{To: &address, Data: input}, nil)
+	if err != nil {
+		return 0, err
+	}
+	// contracts deployed before they were versioned have no version getter
+	if len(output) == 0 {
+		return 0, fmt.Errorf("contract has no version getter")
+	}
+
+	var version *big.Int
+	err = versionABI.Unpack(&version, "version", output)
+	if err != nil {
+		return 0, err
+	}
+	if !version.IsUint64() {
+		return 0, fmt.Errorf("version %s is out of range", version)
+	}
+	return version.Uint64(), nil
+}
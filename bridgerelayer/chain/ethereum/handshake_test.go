@@ -0,0 +1,48 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestContractVersions(t *testing.T) {
+	client := NewMockClient(big.NewInt(1))
+	apps := map[string]Application{
+		"eth":   {Address: "0x0000000000000000000000000000000000000001"},
+		"erc20": {Address: "0x0000000000000000000000000000000000000002"},
+		"dot":   {Address: "0x0000000000000000000000000000000000000003"},
+	}
+
+	output, err := versionABI.Methods["version"].Outputs.Pack(big.NewInt(chain.PayloadSchemaVersion))
+	require.NoError(t, err)
+	client.SetCallResult(common.HexToAddress(apps["eth"].Address), output)
+	client.SetCallError(common.HexToAddress(apps["erc20"].Address), errors.New("execution reverted"))
+
+	components := ContractVersions(context.Background(), client, apps)
+	require.Len(t, components, 3)
+
+	assert.Equal(t, "dot", components[0].App)
+	assert.Equal(t, "contract has no version getter", components[0].Error)
+	assert.Equal(t, "erc20", components[1].App)
+	assert.Equal(t, "execution reverted", components[1].Error)
+	assert.Equal(t, chain.ComponentVersion{
+		Chain:     Name,
+		App:       "eth",
+		Component: common.HexToAddress(apps["eth"].Address).Hex(),
+		Version:   chain.PayloadSchemaVersion,
+	}, components[2])
+
+	assert.Error(t, chain.NewCompatibilityReport(components).Err())
+	assert.NoError(t, chain.NewCompatibilityReport(components[2:]).Err())
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"sync"
+
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+// holds are the events whose routing override or receiving chain requires more
+// confirmations than the finality depth of the network, whose messages are enqueued once
+// their block has them.
+// Held events are only kept in memory: the cursor of the listener stays below the oldest of
+// them, so that they are fetched and held again after a restart.
+type holds struct {
+	mutex  sync.Mutex
+	events []heldEvent
+}
+
+type heldEvent struct {
+	event gethTypes.Log
+	msg   chain.Message
+	// head from which the block of the event has its confirmations
+	until uint64
+	// context of the span which decoded the event
+	trace tracing.SpanContext
+}
+
+// add holds the message of an event until the head reaches a height
+func (hs *holds) add(event gethTypes.Log, msg *chain.Message, until uint64, trace tracing.SpanContext) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+	hs.events = append(hs.events, heldEvent{event: event, msg: *msg, until: until, trace: trace})
+}
+
+// due removes and returns the held events confirmed at a head, in the order they were held
+func (hs *holds) due(head uint64) []heldEvent {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	var due []heldEvent
+	kept := hs.events[:0]
+	for _, held := range hs.events {
+		if held.until <= head {
+			due = append(due, held)
+		} else {
+			kept = append(kept, held)
+		}
+	}
+	hs.events = kept
+	return due
+}
+
+// rollback removes and returns the held events of the blocks above a height
+func (hs *holds) rollback(number uint64) []heldEvent {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	var orphaned []heldEvent
+	kept := hs.events[:0]
+	for _, held := range hs.events {
+		if held.event.BlockNumber > number {
+			orphaned = append(orphaned, held)
+		} else {
+			kept = append(kept, held)
+		}
+	}
+	hs.events = kept
+	return orphaned
+}
+
+// below caps a height at the block preceding that of the oldest held event
+func (hs *holds) below(number uint64) uint64 {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	for _, held := range hs.events {
+		if held.event.BlockNumber <= number && held.event.BlockNumber > 0 {
+			number = held.event.BlockNumber - 1
+		}
+	}
+	return number
+}
+
+// hold defers the message of an event until its block has the confirmations required of it
+func (li *Listener) hold(ctx context.Context, event gethTypes.Log, msg *chain.Message, confirmations uint64, until uint64) {
+	li.held.add(event, msg, until, tracing.FromContext(ctx))
+	li.log.WithFields(logrus.Fields{
+		"txHash":        event.TxHash.Hex(),
+		"logIndex":      event.Index,
+		"override":      msg.Override,
+		"confirmations": confirmations,
+		"until":         until,
+	}).Info("Holding message until its block has the confirmations required of it")
+}
+
+// releaseHeld enqueues the held messages whose blocks are confirmed at a head, dropping
+// those whose block the node no longer serves at its height. Returns an error if a message
+// was persisted instead of being queued.
+func (li *Listener) releaseHeld(ctx context.Context, head uint64) error {
+	for _, held := range li.held.due(head) {
+		event := held.event
+		if hash, ok := li.segment.hashAt(event.BlockNumber); ok && hash != event.BlockHash {
+			li.log.WithFields(logrus.Fields{
+				"txHash":      event.TxHash.Hex(),
+				"logIndex":    event.Index,
+				"blockNumber": event.BlockNumber,
+			}).Warn("Dropped held message whose block was replaced by a reorg")
+			continue
+		}
+
+		msg := held.msg
+		err := li.enqueue(tracing.ContextWith(ctx, held.trace), event, &msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropOrphanedHolds drops the held messages of the blocks above a fork, which were never
+// enqueued, so that the events of the replacing blocks are held instead
+func (li *Listener) dropOrphanedHolds(fork uint64) {
+	for _, held := range li.held.rollback(fork) {
+		li.log.WithFields(logrus.Fields{
+			"txHash":      held.event.TxHash.Hex(),
+			"logIndex":    held.event.Index,
+			"blockNumber": held.event.BlockNumber,
+		}).Warn("Dropped held message orphaned by a reorg")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestListener_Overrides(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+	contract := Contract{Name: "eth", Address: common.Address{1}, ABI: &contractABI}
+
+	exchange := [32]byte{0xd4}
+	transfer := func(recipient [32]byte) types.Log {
+		data, err := contractABI.Events[watchedEvent].Inputs.Pack(common.Address{2}, recipient, big.NewInt(1))
+		require.NoError(t, err)
+		return types.Log{
+			Address: contract.Address,
+			Topics:  []common.Hash{contractABI.Events[watchedEvent].ID},
+			Data:    data,
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	messages := make(chan chain.Message, 2)
+	cursors := store.NewCursors(store.NewMemoryDB())
+	finality := &FinalityConfig{Depths: map[string]uint64{"15": 1}}
+
+	listener, err := NewListener(conn, messages, nil, &processedBlocks{}, chain.NewClockMonitor(&chain.ClockConfig{}, log), nil, nil, nil, cursors, nil, nil, nil, finality, []Contract{contract}, log)
+	require.NoError(t, err)
+	overrides, err := chain.NewOverrides([]chain.OverrideConfig{{
+		Name:          "exchange",
+		Recipients:    []string{hexutil.Encode(exchange[:])},
+		Priority:      true,
+		Confirmations: 2,
+	}})
+	require.NoError(t, err)
+	listener.Override(overrides)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.headSubs) == 1
+	}, time.Second, time.Millisecond)
+
+	// the transfer to the exchange waits for two confirmations beyond the depth, while
+	// other transfers of the block are relayed at the depth
+	client.AddBlock()
+	client.AddBlock(transfer(exchange), transfer([32]byte{0x8e}))
+	client.AddBlock()
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "", msg.Override)
+		assert.False(t, msg.Priority)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// the cursor stays below the block of the held transfer
+	client.AddBlock()
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, messages, 0)
+	number, _, err := cursors.LoadCursor(Name)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), number)
+
+	client.AddBlock()
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "exchange", msg.Override)
+		assert.True(t, msg.Priority)
+		assert.Equal(t, uint64(2), msg.Payload.(Message).VerificationInput.AsBasic.BlockNumber)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for held message")
+	}
+	assert.Eventually(t, func() bool {
+		number, _, err := cursors.LoadCursor(Name)
+		return err == nil && number == 4
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"fmt"
+
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Check verifies that an event and the message generated from it fit the limits
+func (l *Limits) Check(event *gethTypes.Log, msg *chain.Message) error {
+	if l.MaxTopics > 0 && len(event.Topics) > l.MaxTopics {
+		return fmt.Errorf("event has %d topics, exceeding the limit of %d", len(event.Topics), l.MaxTopics)
+	}
+
+	if l.MaxDataSize > 0 && len(event.Data) > l.MaxDataSize {
+		return fmt.Errorf("event data is %d bytes, exceeding the limit of %d", len(event.Data), l.MaxDataSize)
+	}
+
+	if l.MaxPayloadSize > 0 {
+		payload, err := types.EncodeToBytes(msg.Payload)
+		if err != nil {
+			return err
+		}
+		if len(payload) > l.MaxPayloadSize {
+			return fmt.Errorf("payload is %d bytes, exceeding the limit of %d", len(payload), l.MaxPayloadSize)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+)
+
+func TestLimits_Check(t *testing.T) {
+	event := gethTypes.Log{
+		Address: gethCommon.HexToAddress("0xdeadbeef"),
+		Topics:  []gethCommon.Hash{{1}, {2}},
+		Data:    make([]byte, 64),
+	}
+
+	msg, err := ethereum.MakeMessageFromEvent(event, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	unlimited := ethereum.Limits{}
+	assert.NoError(t, unlimited.Check(&event, msg))
+
+	fits := ethereum.Limits{MaxPayloadSize: 1024, MaxDataSize: 64, MaxTopics: 2}
+	assert.NoError(t, fits.Check(&event, msg))
+
+	topics := ethereum.Limits{MaxTopics: 1}
+	assert.Error(t, topics.Check(&event, msg))
+
+	data := ethereum.Limits{MaxDataSize: 63}
+	assert.Error(t, data.Check(&event, msg))
+
+	payload := ethereum.Limits{MaxPayloadSize: 64}
+	assert.Error(t, payload.Check(&event, msg))
+}
+
+const testMemoABI = `[
+	{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+		{"indexed": true, "name": "_sender", "type": "address"},
+		{"indexed": false, "name": "_amount", "type": "uint256"},
+		{"indexed": false, "name": "_memo", "type": "string"},
+		{"indexed": false, "name": "_uri", "type": "bytes"}
+	]}
+]`
+
+func TestFieldLimits(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testMemoABI))
+	require.NoError(t, err)
+	inputs := contractABI.Events["Transfer"].Inputs.NonIndexed()
+
+	data, err := inputs.Pack(big.NewInt(5), "héllo wörld", []byte{1, 2, 3})
+	require.NoError(t, err)
+	event := gethTypes.Log{
+		Topics: []gethCommon.Hash{contractABI.Events["Transfer"].ID, {1}},
+		Data:   data,
+	}
+
+	// unlimited apps have no field limits
+	none, err := ethereum.NewFieldLimits(&ethereum.Limits{}, &contractABI, nil)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	assert.NoError(t, none.Check(&event))
+
+	// limits must name string or bytes arguments
+	_, err = ethereum.NewFieldLimits(&ethereum.Limits{MaxFieldSizes: map[string]int{"_amount": 8}}, &contractABI, nil)
+	assert.Error(t, err)
+	_, err = ethereum.NewFieldLimits(&ethereum.Limits{MaxFieldSizes: map[string]int{"_note": 8}}, &contractABI, nil)
+	assert.Error(t, err)
+	_, err = ethereum.NewFieldLimits(&ethereum.Limits{MaxFieldSizes: map[string]int{"_memo": 8}, FieldPolicy: "drop"}, &contractABI, nil)
+	assert.Error(t, err)
+
+	// oversized events are quarantined by default
+	quarantine, err := ethereum.NewFieldLimits(&ethereum.Limits{MaxFieldSizes: map[string]int{"_memo": 13, "_uri": 3}}, &contractABI, nil)
+	require.NoError(t, err)
+	assert.NoError(t, quarantine.Check(&event))
+
+	quarantine, err = ethereum.NewFieldLimits(&ethereum.Limits{MaxFieldSizes: map[string]int{"_memo": 8}}, &contractABI, nil)
+	require.NoError(t, err)
+	assert.EqualError(t, quarantine.Check(&event), "argument _memo is 13 bytes, exceeding the limit of 8")
+	unchanged, fields, err := quarantine.Truncate(event)
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+	assert.Equal(t, event, unchanged)
+
+	// or relayed with their arguments truncated at a character boundary
+	limits := ethereum.Limits{MaxFieldSizes: map[string]int{"_memo": 9, "_uri": 2}, FieldPolicy: ethereum.FieldPolicyTruncate}
+	truncate, err := ethereum.NewFieldLimits(&limits, &contractABI, nil)
+	require.NoError(t, err)
+
+	truncated, fields, err := truncate.Truncate(event)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_memo", "_uri"}, fields)
+	assert.Equal(t, data, event.Data)
+	assert.NoError(t, truncate.Check(&truncated))
+
+	values, err := inputs.UnpackValues(truncated.Data)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(5), values[0])
+	assert.Equal(t, "héllo w", values[1])
+	assert.Equal(t, []byte{1, 2}, values[2])
+
+	// call arguments can't be truncated
+	limits.MaxFieldSizes = map[string]int{"_uri": 2}
+	_, err = ethereum.NewFieldLimits(&limits, &contractABI, &ethereum.CallConfig{Name: "Assets.mint", Field: "_uri"})
+	assert.Error(t, err)
+}
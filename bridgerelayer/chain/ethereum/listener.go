@@ -5,6 +5,12 @@ package ethereum
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	geth "github.com/ethereum/go-ethereum"
 	gethCommon "github.com/ethereum/go-ethereum/common"
@@ -13,25 +19,85 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 )
 
 // Listener streams the Ethereum blockchain for application events
 type Listener struct {
-	conn      *Connection
-	contracts []Contract
-	messages  chan<- chain.Message
-	log       *logrus.Entry
+	conn       Connection
+	contracts  []Contract
+	messages   chan<- chain.Message
+	quarantine chain.Quarantine
+	blocks     chain.BlockLog
+	progress   *chain.Progress
+	clock      *chain.ClockMonitor
+	// retries calls which failed with a transient error, may be nil
+	backoff    *chain.Backoff
+	checkpoint *chain.Checkpoint
+	// records the advancing checkpoint, may be nil
+	checkpoints chain.CheckpointStore
+	// records the last handled block, from which the listener resumes, may be nil
+	cursors chain.CursorStore
+	// notified of observed events, may be nil
+	events chain.EventFeed
+	// closed once the consumer of messages stops, may be nil
+	stopped <-chan struct{}
+	// records the messages of events orphaned by reorgs, may be nil
+	invalidated chain.Invalidator
+	finality    *FinalityConfig
+	// confirmations before events are relayed, resolved from the chain ID once connected
+	depth uint64
+	// chain ID recorded in the provenance of messages, a string once connected
+	chainID atomic.Value
+	// recent blocks followed by the listener, to detect reorgs
+	segment *segment
+	// last block whose events were relayed, while the events of later blocks await their
+	// confirmations. Unused without a confirmation depth.
+	confirmed uint64
+	// events already enqueued, which are seen again when repaired or resubscribed
+	seen *chain.Deduplicator
+	// routes the messages to the recipients of routing overrides, nil if none is configured
+	overrides *chain.Overrides
+	// latest head seen by the listener, accessed atomically
+	head uint64
+	// confirmations which the chain receiving the messages requires of their events, held
+	// beyond the depth if greater, accessed atomically
+	required uint64
+	// events whose routing override requires further confirmations
+	held *holds
+	log  *logrus.Entry
 }
 
-func NewListener(conn *Connection, messages chan<- chain.Message, contracts []Contract, log *logrus.Entry) (*Listener, error) {
+func NewListener(conn Connection, messages chan<- chain.Message, quarantine chain.Quarantine, blocks chain.BlockLog, clock *chain.ClockMonitor, backoff *chain.Backoff, checkpoint *chain.Checkpoint, checkpoints chain.CheckpointStore, cursors chain.CursorStore, events chain.EventFeed, stopped <-chan struct{}, invalidated chain.Invalidator, finality *FinalityConfig, contracts []Contract, log *logrus.Entry) (*Listener, error) {
 	return &Listener{
-		conn:      conn,
-		contracts: contracts,
-		messages:  messages,
-		log:       log,
+		conn:        conn,
+		contracts:   contracts,
+		messages:    messages,
+		quarantine:  quarantine,
+		blocks:      blocks,
+		progress:    chain.NewProgress(Name),
+		clock:       clock,
+		backoff:     backoff,
+		checkpoint:  checkpoint,
+		checkpoints: checkpoints,
+		cursors:     cursors,
+		events:      events,
+		stopped:     stopped,
+		invalidated: invalidated,
+		finality:    finality,
+		seen:        chain.NewDeduplicator(Name),
+		held:        &holds{},
+		log:         log,
 	}, nil
 }
 
+// Override sets the routing overrides matched against the recipients of the events. It must
+// be set before starting.
+func (li *Listener) Override(overrides *chain.Overrides) {
+	li.overrides = overrides
+}
+
 func (li *Listener) Start(cxt context.Context, eg *errgroup.Group) error {
 	eg.Go(func() error {
 		return li.pollEvents(cxt)
@@ -43,16 +109,44 @@ func (li *Listener) Start(cxt context.Context, eg *errgroup.Group) error {
 func (li *Listener) pollEvents(ctx context.Context) error {
 	li.log.Info("Polling started")
 
+	if li.checkpoint != nil {
+		err := li.pinCheckpoint(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var chainID *big.Int
+	err := li.backoff.Retry(ctx, li.log, "fetch chain ID", func() error {
+		var err error
+		chainID, err = li.conn.Client().ChainID(ctx)
+		return err
+	})
+	if err != nil {
+		li.log.WithError(err).Error("Failed to fetch chain ID")
+		return err
+	}
+	li.chainID.Store(chainID.String())
+	li.depth = li.finality.depth(chainID)
+	li.segment = newSegment(li.depth)
+
+	// with a confirmation depth, the events of blocks are fetched once they are confirmed
+	// rather than pushed as they are emitted
 	events := make(chan gethTypes.Log)
 	for _, contract := range li.contracts {
+		if li.depth > 0 {
+			break
+		}
 		query := makeQuery(contract)
 
-		_, err := li.conn.client.SubscribeFilterLogs(ctx, query, events)
+		log := li.log.WithField("address", contract.Address.Hex())
+		err := li.backoff.Retry(ctx, log, "subscribe to application events", func() error {
+			_, err := li.conn.Client().SubscribeFilterLogs(ctx, query, events)
+			return err
+		})
 		if err != nil {
-			li.log.WithFields(logrus.Fields{
-				"address": contract.Address.Hex(),
-			}).Error("Failed to subscribe to application events")
-			continue
+			log.WithError(err).Error("Failed to subscribe to application events")
+			return err
 		}
 
 		li.log.WithFields(logrus.Fields{
@@ -61,33 +155,690 @@ func (li *Listener) pollEvents(ctx context.Context) error {
 		}).Info("Subscribed to contract events")
 	}
 
+	// Logs are pushed on a subscription of their own, and may arrive after the head of their block
+	heads := make(chan *gethTypes.Header)
+	err = li.backoff.Retry(ctx, li.log, "subscribe to new heads", func() error {
+		_, err := li.conn.Client().SubscribeNewHead(ctx, heads)
+		return err
+	})
+	if err != nil {
+		li.log.WithError(err).Error("Failed to subscribe to new heads")
+		return err
+	}
+
+	// events emitted while the relayer was down are fetched once the subscriptions are set
+	// up, so that none are missed in between
+	err = li.catchUp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if li.depth > 0 {
+		err = li.startConfirmed(ctx)
+		if err != nil {
+			return err
+		}
+		li.log.WithFields(logrus.Fields{
+			"depth":     li.depth,
+			"confirmed": li.confirmed,
+		}).Info("Relaying events once their blocks are confirmed")
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case head := <-heads:
+			number := head.Number.Uint64()
+			// blocks which can't be verified are left as holes, to be repaired from a trusted node
+			if li.verifyAncestry(ctx, head.Hash()) != nil {
+				continue
+			}
+			err := li.followHead(ctx, head)
+			if err != nil {
+				li.log.WithError(err).Warn("Failed to check new head for reorgs")
+			}
+			li.saveCheckpoint()
+			atomic.StoreUint64(&li.head, number)
+			err = li.releaseHeld(ctx, number)
+			if err != nil {
+				return err
+			}
+			if li.depth > 0 {
+				err = li.relayConfirmed(ctx, number)
+				if err != nil {
+					return err
+				}
+				li.progress.Update(number, li.confirmed)
+			} else if number > 0 {
+				// the logs of the head may not have been handled yet, so only its parent counts as
+				// handled, and the head is fetched again by the catch-up after a restart
+				li.progress.Update(number, number-1)
+				li.markProcessed(number - 1)
+				li.saveCursor(number - 1)
+			}
+			if li.clock.Enabled() {
+				li.clock.Observe(number, time.Unix(int64(head.Time), 0))
+			}
 		case event := <-events:
-			li.log.WithFields(logrus.Fields{
-				"address":     event.Address.Hex(),
-				"txHash":      event.TxHash.Hex(),
-				"blockNumber": event.BlockNumber,
-			}).Info("Witnessed transaction for application")
+			// logs of blocks which a reorg replaced are pushed again, flagged as removed
+			if event.Removed {
+				continue
+			}
+			err := li.handleEvent(ctx, event, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Repair reprocesses the events of a range of blocks which were skipped
+func (li *Listener) Repair(ctx context.Context, from uint64, to uint64) error {
+	for start := from; start <= to; start += repairBatchSize {
+		end := start + repairBatchSize - 1
+		if end > to {
+			end = to
+		}
+
+		traced, events, err := li.fetchEvents(ctx, start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			err := li.handleEvent(traced, event, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		for number := start; number <= end; number++ {
+			li.markProcessed(number)
+		}
+
+		li.log.WithFields(logrus.Fields{
+			"from": start,
+			"to":   end,
+		}).Info("Repaired blocks")
+	}
+
+	return nil
+}
+
+// Replay re-emits the messages of the events of a range of blocks, up to the latest block if
+// to is zero, including events which were already enqueued. Neither the cursor nor the
+// processed blocks are updated. Returns the last replayed block.
+func (li *Listener) Replay(ctx context.Context, from uint64, to uint64) (uint64, error) {
+	if to == 0 {
+		header, err := li.conn.Client().HeaderByNumber(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		to = header.Number.Uint64()
+	}
+	if from > to {
+		return 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+
+	for start := from; start <= to; start += repairBatchSize {
+		end := start + repairBatchSize - 1
+		if end > to {
+			end = to
+		}
+
+		traced, events, err := li.fetchEvents(ctx, start, end)
+		if err != nil {
+			return 0, err
+		}
 
-			msg, err := MakeMessageFromEvent(event, li.log)
+		for _, event := range events {
+			err := li.handleEvent(traced, event, true)
 			if err != nil {
-				li.log.WithFields(logrus.Fields{
-					"address":     event.Address.Hex(),
-					"txHash":      event.TxHash.Hex(),
-					"blockNumber": event.BlockNumber,
-				}).Error("Failed to generate message from ethereum event")
-			} else {
-				li.messages <- *msg
+				return 0, err
 			}
 		}
 	}
+
+	li.log.WithFields(logrus.Fields{
+		"from": from,
+		"to":   to,
+	}).Info("Replayed blocks")
+
+	return to, nil
 }
 
+// fetchEvents returns the events of all apps in a range of blocks, in the order they were
+// emitted, with the context of the span of their fetch from which the spans of their
+// messages descend
+func (li *Listener) fetchEvents(ctx context.Context, from uint64, to uint64) (context.Context, []gethTypes.Log, error) {
+	ctx, span := tracing.Start(ctx, "fetch events")
+	span.SetAttribute("chain", Name)
+	span.SetAttribute("from", from)
+	span.SetAttribute("to", to)
+
+	events, err := li.filterEvents(ctx, from, to)
+	span.End(err)
+	return ctx, events, err
+}
+
+func (li *Listener) filterEvents(ctx context.Context, from uint64, to uint64) ([]gethTypes.Log, error) {
+	var events []gethTypes.Log
+	for _, contract := range li.contracts {
+		query := makeQuery(contract)
+		query.FromBlock = new(big.Int).SetUint64(from)
+		query.ToBlock = new(big.Int).SetUint64(to)
+
+		var logs []gethTypes.Log
+		err := li.backoff.Retry(ctx, li.log, "fetch application events", func() error {
+			var err error
+			logs, err = li.conn.Client().FilterLogs(ctx, query)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, logs...)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		return events[i].Index < events[j].Index
+	})
+	return events, nil
+}
+
+// catchUp processes the blocks following the last handled one up to the latest block. Without
+// a recorded cursor, or if the cursor is ahead of the chain, which was reset, there is nothing
+// to catch up with. Blocks which can't be fetched are left as holes, to be repaired.
+func (li *Listener) catchUp(ctx context.Context) error {
+	if li.cursors == nil {
+		return nil
+	}
+
+	cursor, ok, err := li.cursors.LoadCursor(Name)
+	if err != nil {
+		li.log.WithError(err).Warn("Failed to load last handled block, starting from the latest block")
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	var header *gethTypes.Header
+	err = li.backoff.Retry(ctx, li.log, "fetch latest block", func() error {
+		var err error
+		header, err = li.conn.Client().HeaderByNumber(ctx, nil)
+		return err
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		li.log.WithError(err).Warn("Failed to fetch latest block, starting from the latest block")
+		return nil
+	}
+	latest := header.Number.Uint64()
+
+	log := li.log.WithFields(logrus.Fields{
+		"cursor": cursor,
+		"latest": latest,
+	})
+	if cursor > latest {
+		log.Warn("Last handled block is ahead of the chain, starting from the latest block")
+		return nil
+	}
+
+	// blocks within the confirmation depth are relayed once they are confirmed
+	if latest < li.depth {
+		return nil
+	}
+	latest -= li.depth
+	if cursor >= latest {
+		return nil
+	}
+
+	log.Info("Resuming from the last handled block")
+	err = li.Repair(ctx, cursor+1, latest)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, chain.ErrConsumerStopped) {
+		return err
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to catch up with the blocks following the last handled block, run the repair command to reprocess them")
+		return nil
+	}
+
+	li.saveCursor(latest)
+	return nil
+}
+
+// startConfirmed sets the last block whose events were relayed to the last handled block, or
+// without one, to the last confirmed block, from which the listener starts
+func (li *Listener) startConfirmed(ctx context.Context) error {
+	var header *gethTypes.Header
+	err := li.backoff.Retry(ctx, li.log, "fetch latest block", func() error {
+		var err error
+		header, err = li.conn.Client().HeaderByNumber(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	latest := header.Number.Uint64()
+
+	li.confirmed = 0
+	if latest > li.depth {
+		li.confirmed = latest - li.depth
+	}
+
+	if li.cursors == nil {
+		return nil
+	}
+	cursor, ok, err := li.cursors.LoadCursor(Name)
+	if err == nil && ok && cursor < li.confirmed {
+		li.confirmed = cursor
+	}
+	return nil
+}
+
+// EventMessage rebuilds the message which the listener generates for the event at a log
+// index of a block, without queueing it. Messages exceeding the limits of their app are
+// refused, as the listener would quarantine them.
+func (li *Listener) EventMessage(ctx context.Context, number uint64, index uint64) (*chain.Message, error) {
+	for _, contract := range li.contracts {
+		query := makeQuery(contract)
+		query.FromBlock = new(big.Int).SetUint64(number)
+		query.ToBlock = query.FromBlock
+
+		logs, err := li.conn.Client().FilterLogs(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range logs {
+			if event.BlockNumber != number || uint64(event.Index) != index {
+				continue
+			}
+
+			err = li.verifyAncestry(ctx, event.BlockHash)
+			if err != nil {
+				return nil, err
+			}
+			event = li.deriveRecipient(event)
+			event = li.truncateFields(event)
+			msg, err := li.makeMessage(event)
+			if err != nil {
+				return nil, err
+			}
+			err = li.checkLimits(&event, msg)
+			if err != nil {
+				return nil, err
+			}
+			li.override(&event).Apply(msg)
+			return msg, nil
+		}
+	}
+
+	return nil, chain.ErrEventNotFound
+}
+
+// handleEvent queues the message for an event, returning an error if it was persisted
+// instead, because the listener is shutting down or the consumer stopped. Replayed events
+// are queued even if they were already enqueued.
+func (li *Listener) handleEvent(ctx context.Context, event gethTypes.Log, replay bool) error {
+	li.log.WithFields(logrus.Fields{
+		"address":     event.Address.Hex(),
+		"txHash":      event.TxHash.Hex(),
+		"blockNumber": event.BlockNumber,
+	}).Info("Witnessed transaction for application")
+
+	if li.verifyAncestry(ctx, event.BlockHash) != nil {
+		return nil
+	}
+
+	if !li.seen.Observe(chain.EventKey{BlockHash: event.BlockHash, Index: uint64(event.Index)}) && !replay {
+		li.log.WithFields(logrus.Fields{
+			"txHash":   event.TxHash.Hex(),
+			"logIndex": event.Index,
+		}).Debug("Skipped event which was already enqueued")
+		return nil
+	}
+
+	// events are observed as they were emitted, before their recipient is derived
+	observed := event
+	event = li.deriveRecipient(event)
+	event = li.truncateFields(event)
+
+	ctx, decode := tracing.Start(ctx, "decode event")
+	decode.SetAttribute("chain", Name)
+	decode.SetAttribute("block", event.BlockNumber)
+	decode.SetAttribute("index", uint64(event.Index))
+	msg, err := li.makeMessage(event)
+	decode.End(err)
+	li.observe(&observed, msg)
+	if err != nil {
+		li.log.WithFields(logrus.Fields{
+			"address":     event.Address.Hex(),
+			"txHash":      event.TxHash.Hex(),
+			"blockNumber": event.BlockNumber,
+		}).Error("Failed to generate message from ethereum event")
+		return nil
+	}
+
+	app := li.appName(event.Address)
+	metrics.EventsDecoded.WithLabelValues(Name, app).Inc()
+
+	if err := li.checkLimits(&event, msg); err != nil {
+		li.reject(&event, msg, err)
+		return nil
+	}
+
+	override := li.override(&event)
+	override.Apply(msg)
+	msg.ObservedAt = time.Now()
+	msg.Replayed = replay
+	if confirmations := li.confirmations(override); confirmations > li.depth {
+		until := event.BlockNumber + confirmations
+		if until > atomic.LoadUint64(&li.head) {
+			li.hold(ctx, event, msg, confirmations, until)
+			return nil
+		}
+	}
+	return li.enqueue(ctx, event, msg)
+}
+
+// confirmations returns the confirmations which the block of an event must have before it
+// is relayed: the depth plus those of its routing override, or those required by the
+// receiving chain if more
+func (li *Listener) confirmations(override *chain.Override) uint64 {
+	confirmations := li.depth
+	if override != nil {
+		confirmations += override.Confirmations
+	}
+	if required := atomic.LoadUint64(&li.required); required > confirmations {
+		confirmations = required
+	}
+	return confirmations
+}
+
+// RequireConfirmations holds the events of later blocks until they have a number of
+// confirmations, such as those which the verifier of the receiving chain was changed to
+// require. Events are never relayed with fewer confirmations than the finality depth.
+func (li *Listener) RequireConfirmations(confirmations uint64) {
+	atomic.StoreUint64(&li.required, confirmations)
+}
+
+// enqueue hands a message off to the router, returning an error if it was persisted instead
+func (li *Listener) enqueue(ctx context.Context, event gethTypes.Log, msg *chain.Message) error {
+	_, enqueue := tracing.Start(ctx, "enqueue message")
+	// the spans of the delivery descend from the enqueue of the message
+	msg.Trace = enqueue.Context()
+	err := chain.Handoff(ctx, li.messages, li.stopped, li.quarantine, Name, *msg)
+	enqueue.End(err)
+	if err != nil {
+		li.log.WithError(err).WithFields(logrus.Fields{
+			"txHash":   event.TxHash.Hex(),
+			"logIndex": event.Index,
+		}).Warn("Persisted message which could not be queued")
+		return err
+	}
+	metrics.MessagesEnqueued.WithLabelValues(Name, li.appName(event.Address)).Inc()
+	if li.segment != nil {
+		li.segment.record(event.BlockNumber, event.BlockHash, *msg)
+	}
+	return nil
+}
+
+func (li *Listener) markProcessed(number uint64) {
+	metrics.BlocksProcessed.WithLabelValues(Name).Inc()
+	err := li.blocks.MarkProcessed(Name, number)
+	if err != nil {
+		li.log.WithError(err).WithField("blockNumber", number).Error("Failed to record processed block")
+	}
+}
+
+// checkLimits verifies a message against the limits of the app which emitted the event
+func (li *Listener) checkLimits(event *gethTypes.Log, msg *chain.Message) error {
+	for _, contract := range li.contracts {
+		if contract.Address == event.Address {
+			if err := contract.Fields.Check(event); err != nil {
+				return err
+			}
+			return contract.Limits.Check(event, msg)
+		}
+	}
+	return nil
+}
+
+// pinCheckpoint verifies the block which the node serves at the height of the checkpoint
+func (li *Listener) pinCheckpoint(ctx context.Context) error {
+	header, err := li.conn.Client().HeaderByNumber(ctx, new(big.Int).SetUint64(li.checkpoint.Number()))
+	if err != nil {
+		return err
+	}
+
+	err = li.checkpoint.Pin(toHeader(header))
+	if err != nil {
+		li.log.WithError(err).Error("Refusing to relay from a chain which does not match the checkpoint")
+		return err
+	}
+
+	li.log.WithField("blockNumber", li.checkpoint.Number()).Info("Verified trusted checkpoint")
+	return nil
+}
+
+// verifyAncestry checks that a block descends from the checkpoint, if one is pinned
+func (li *Listener) verifyAncestry(ctx context.Context, hash gethCommon.Hash) error {
+	if li.checkpoint == nil {
+		return nil
+	}
+
+	fetch := func(hash [32]byte) (*chain.Header, error) {
+		header, err := li.conn.Client().HeaderByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		return toHeader(header), nil
+	}
+
+	header, err := fetch(hash)
+	if err == nil {
+		err = li.checkpoint.Connect(header, fetch)
+	}
+
+	if errors.Is(err, chain.ErrUntrustedHistory) {
+		li.log.WithError(err).Error("Refusing to relay from a chain history which does not descend from the checkpoint")
+	} else if err != nil {
+		li.log.WithError(err).WithField("blockHash", hash.Hex()).Error("Failed to verify block ancestry")
+	}
+	return err
+}
+
+// saveCheckpoint records the checkpoint once it has advanced, so that it survives restarts
+func (li *Listener) saveCheckpoint() {
+	if li.checkpoint == nil || li.checkpoints == nil {
+		return
+	}
+
+	err := li.checkpoint.Save(li.checkpoints, Name)
+	if err != nil {
+		li.log.WithError(err).Warn("Failed to record checkpoint")
+	}
+}
+
+// saveCursor records the last handled block, so that the listener resumes from it after a restart
+func (li *Listener) saveCursor(number uint64) {
+	if li.cursors == nil {
+		return
+	}
+	number = li.held.below(number)
+
+	err := li.cursors.SaveCursor(Name, number)
+	if err != nil {
+		li.log.WithError(err).WithField("blockNumber", number).Warn("Failed to record last handled block")
+	}
+}
+
+// toHeader converts a header, computing its hash rather than trusting the node
+func toHeader(header *gethTypes.Header) *chain.Header {
+	return &chain.Header{
+		Number:     header.Number.Uint64(),
+		Hash:       header.Hash(),
+		ParentHash: header.ParentHash,
+		StateRoot:  header.Root,
+	}
+}
+
+// observe notifies the event feed of an event, decoded with the ABI of the app which emitted
+// it, and of the message generated for it, which is nil if it could not be generated
+func (li *Listener) observe(event *gethTypes.Log, msg *chain.Message) {
+	if li.events == nil {
+		return
+	}
+
+	for _, contract := range li.contracts {
+		if contract.Address != event.Address {
+			continue
+		}
+
+		observed, err := contract.Observe(event)
+		if err != nil {
+			li.log.WithError(err).WithFields(logrus.Fields{
+				"txHash":   event.TxHash.Hex(),
+				"logIndex": event.Index,
+			}).Warn("Failed to decode event for the event feed")
+			return
+		}
+		observed.Message = msg
+		li.events.Observed(observed)
+		return
+	}
+}
+
+// override returns the routing override of the recipient of an event, decoded with the ABI
+// of the app which emitted it, nil if the recipient has none
+func (li *Listener) override(event *gethTypes.Log) *chain.Override {
+	if li.overrides == nil {
+		return nil
+	}
+
+	for _, contract := range li.contracts {
+		if contract.Address != event.Address {
+			continue
+		}
+
+		observed, err := contract.Observe(event)
+		if err != nil {
+			return nil
+		}
+		return li.overrides.Match(observed.Fields)
+	}
+	return nil
+}
+
+// deriveRecipient applies the address derivation of the app which emitted the event
+func (li *Listener) deriveRecipient(event gethTypes.Log) gethTypes.Log {
+	for _, contract := range li.contracts {
+		if contract.Address == event.Address && contract.Derivation != nil {
+			return contract.Derivation.Apply(event)
+		}
+	}
+	return event
+}
+
+// truncateFields truncates the oversized event arguments of apps whose policy is to truncate
+// them. Events which fail to decode are left unchanged, and rejected by checkLimits.
+func (li *Listener) truncateFields(event gethTypes.Log) gethTypes.Log {
+	for _, contract := range li.contracts {
+		if contract.Address != event.Address || contract.Fields == nil {
+			continue
+		}
+
+		truncated, fields, err := contract.Fields.Truncate(event)
+		if err != nil || len(fields) == 0 {
+			return event
+		}
+
+		metrics.FieldsTruncated.WithLabelValues(Name, contract.Name).Inc()
+		li.log.WithFields(logrus.Fields{
+			"address":  event.Address.Hex(),
+			"txHash":   event.TxHash.Hex(),
+			"logIndex": event.Index,
+			"fields":   fields,
+		}).Warn("Truncated oversized event arguments")
+		return truncated
+	}
+	return event
+}
+
+// appName returns the name of the app which emitted an event, for labelling metrics
+func (li *Listener) appName(address gethCommon.Address) string {
+	for _, contract := range li.contracts {
+		if contract.Address == address {
+			return contract.Name
+		}
+	}
+	return "unknown"
+}
+
+// makeMessage generates the message for an event of the app which emitted it
+func (li *Listener) makeMessage(event gethTypes.Log) (*chain.Message, error) {
+	var msg *chain.Message
+	var err error
+	for _, contract := range li.contracts {
+		if contract.Address == event.Address {
+			msg, err = contract.MakeMessage(event, li.log)
+			break
+		}
+	}
+	if msg == nil && err == nil {
+		msg, err = MakeMessageFromEvent(event, li.log)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Source = Name
+	msg.SourceChainID, _ = li.chainID.Load().(string)
+	msg.SourceHash = event.BlockHash.Hex()
+	msg.EventIndex = uint64(event.Index)
+	return msg, nil
+}
+
+// reject quarantines a message instead of queueing it for delivery
+func (li *Listener) reject(event *gethTypes.Log, msg *chain.Message, reason error) {
+	log := li.log.WithFields(logrus.Fields{
+		"address":     event.Address.Hex(),
+		"txHash":      event.TxHash.Hex(),
+		"blockNumber": event.BlockNumber,
+		"reason":      reason,
+	})
+	log.Warn("Rejected message exceeding payload limits")
+
+	err := li.quarantine.Quarantine(Name, msg, reason.Error())
+	if err != nil {
+		log.WithError(err).Error("Failed to quarantine message")
+	}
+}
+
+// Progress returns the block processing progress of the listener
+func (li *Listener) Progress() *chain.Progress {
+	return li.progress
+}
+
+// repairBatchSize is the number of blocks whose logs are fetched in one request while repairing
+const repairBatchSize = 1000
+
+// watchedEvent is the application event which gets relayed
+const watchedEvent = "Transfer"
+
 func makeQuery(contract Contract) geth.FilterQuery {
-	signature := contract.ABI.Events["Transfer"].ID.Hex()
+	signature := contract.ABI.Events[watchedEvent].ID.Hex()
 	topic := gethCommon.HexToHash(signature)
 
 	return geth.FilterQuery{
@@ -0,0 +1,169 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type processedBlocks struct {
+	mutex   sync.Mutex
+	numbers []uint64
+}
+
+func (pb *processedBlocks) MarkProcessed(_ string, number uint64) error {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	pb.numbers = append(pb.numbers, number)
+	return nil
+}
+
+func (pb *processedBlocks) processed() []uint64 {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	return append([]uint64{}, pb.numbers...)
+}
+
+func TestListener_MockConnection(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+	contract := Contract{Name: "eth", Address: common.Address{1}, ABI: &contractABI}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	messages := make(chan chain.Message, 2)
+	blocks := &processedBlocks{}
+
+	listener, err := NewListener(conn, messages, nil, blocks, chain.NewClockMonitor(&chain.ClockConfig{}, log), nil, nil, nil, nil, nil, nil, nil, &FinalityConfig{}, []Contract{contract}, log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	// wait for the listener to subscribe before producing blocks
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.headSubs) == 1
+	}, time.Second, time.Millisecond)
+
+	transfer := types.Log{
+		Address: contract.Address,
+		Topics:  []common.Hash{contractABI.Events[watchedEvent].ID},
+	}
+	header := client.AddBlock(transfer)
+
+	msg := <-messages
+	assert.Equal(t, [20]byte(contract.Address), msg.AppID)
+	assert.Equal(t, header.Number.Uint64(), msg.Payload.(Message).VerificationInput.AsBasic.BlockNumber)
+	// only the parent of the head is marked processed, as the head's logs may still be arriving
+	assert.Eventually(t, func() bool {
+		return len(blocks.processed()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []uint64{0}, blocks.processed())
+
+	// repairing the block again does not enqueue the event a second time
+	require.NoError(t, listener.Repair(ctx, 1, 1))
+	assert.Len(t, messages, 0)
+	assert.Equal(t, []uint64{0, 1}, blocks.processed())
+
+	// the message can be rebuilt for users who relay the event themselves
+	rebuilt, err := listener.EventMessage(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, msg.Payload, rebuilt.Payload)
+	_, err = listener.EventMessage(ctx, 1, 1)
+	assert.Equal(t, chain.ErrEventNotFound, err)
+
+	// replaying the block enqueues the event again, without marking the block processed
+	last, err := listener.Replay(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), last)
+	require.Len(t, messages, 1)
+	replayed := <-messages
+	assert.True(t, replayed.Replayed)
+	assert.Equal(t, msg.Payload, replayed.Payload)
+	assert.Equal(t, []uint64{0, 1}, blocks.processed())
+}
+
+func TestListener_Resume(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+	contract := Contract{Name: "eth", Address: common.Address{1}, ABI: &contractABI}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	// the transfer was emitted while the relayer was down, after it handled block 1
+	client := NewMockClient(big.NewInt(15))
+	client.AddBlock()
+	client.AddBlock(types.Log{
+		Address: contract.Address,
+		Topics:  []common.Hash{contractABI.Events[watchedEvent].ID},
+	})
+	client.AddBlock()
+
+	cursors := store.NewCursors(store.NewMemoryDB())
+	require.NoError(t, cursors.SaveCursor(Name, 1))
+
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	messages := make(chan chain.Message, 2)
+	blocks := &processedBlocks{}
+
+	listener, err := NewListener(conn, messages, nil, blocks, chain.NewClockMonitor(&chain.ClockConfig{}, log), nil, nil, nil, cursors, nil, nil, nil, &FinalityConfig{}, []Contract{contract}, log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, uint64(2), msg.Payload.(Message).VerificationInput.AsBasic.BlockNumber)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// the cursor advances to the latest block once caught up, and then follows a block behind
+	// each new head, whose logs may not have been handled yet
+	assert.Eventually(t, func() bool {
+		number, _, err := cursors.LoadCursor(Name)
+		return err == nil && number == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []uint64{2, 3}, blocks.processed())
+
+	client.AddBlock()
+	client.AddBlock()
+	assert.Eventually(t, func() bool {
+		number, _, err := cursors.LoadCursor(Name)
+		return err == nil && number == 4
+	}, time.Second, time.Millisecond)
+	processed := blocks.processed()
+	assert.Equal(t, uint64(4), processed[len(processed)-1])
+}
@@ -4,7 +4,6 @@
 package ethereum
 
 import (
-	"bytes"
 	"encoding/hex"
 
 	etypes "github.com/ethereum/go-ethereum/core/types"
@@ -13,6 +12,10 @@ import (
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 )
 
+func init() {
+	chain.RegisterPayload("ethereum", Message{})
+}
+
 type Message struct {
 	Data              []byte
 	VerificationInput VerificationInput
@@ -70,14 +73,16 @@ func (v *VerificationInput) Decode(decoder scale.Decoder) error {
 
 func MakeMessageFromEvent(event etypes.Log, log *logrus.Entry) (*chain.Message, error) {
 	// RLP encode event log's Address, Topics, and Data
-	var buf bytes.Buffer
-	err := event.EncodeRLP(&buf)
+	buf := chain.GetBuffer()
+	defer chain.PutBuffer(buf)
+
+	err := event.EncodeRLP(buf)
 	if err != nil {
 		return nil, err
 	}
 
 	message := Message{
-		Data: buf.Bytes(),
+		Data: chain.CopyBytes(buf),
 		VerificationInput: VerificationInput{
 			IsBasic: true,
 			AsBasic: VerificationBasic{
@@ -87,14 +92,16 @@ func MakeMessageFromEvent(event etypes.Log, log *logrus.Entry) (*chain.Message,
 		},
 	}
 
-	value := hex.EncodeToString(message.Data)
-	log.WithFields(logrus.Fields{
-		"payload":     value,
-		"blockNumber": message.VerificationInput.AsBasic.BlockNumber,
-		"eventIndex":  message.VerificationInput.AsBasic.EventIndex,
-	}).Debug("Generated message from Ethereum log")
+	// skip hex encoding the payload unless it is logged
+	if log.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		log.WithFields(logrus.Fields{
+			"payload":     hex.EncodeToString(message.Data),
+			"blockNumber": message.VerificationInput.AsBasic.BlockNumber,
+			"eventIndex":  message.VerificationInput.AsBasic.EventIndex,
+		}).Debug("Generated message from Ethereum log")
+	}
 
-	msg := chain.Message{AppID: event.Address, Payload: message}
+	msg := chain.Message{AppID: event.Address, Payload: message, SourceBlock: event.BlockNumber}
 
 	return &msg, nil
 }
@@ -3,8 +3,12 @@ package ethereum_test
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"testing"
 
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
 	"github.com/snowfork/go-substrate-rpc-client/scale"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
 	"github.com/stretchr/testify/assert"
@@ -51,3 +55,25 @@ func TestMessage_EncodeDecode(t *testing.T) {
 
 	assert.Equal(t, input, decoded, "The two messages should be the same")
 }
+
+func BenchmarkMakeMessageFromEvent(b *testing.B) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	event := gethTypes.Log{
+		Address: gethCommon.HexToAddress("0xdeadbeef"),
+		Topics:  []gethCommon.Hash{{1}},
+		Data:    make([]byte, 96),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		_, err := ethereum.MakeMessageFromEvent(event, log)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// MockConnection is a Connection to an in-memory MockClient, so that components can be
+// tested without a node
+type MockConnection struct {
+	kp     *secp256k1.Keypair
+	client *MockClient
+}
+
+func NewMockConnection(kp *secp256k1.Keypair, client *MockClient) *MockConnection {
+	return &MockConnection{kp: kp, client: client}
+}
+
+func (mc *MockConnection) Connect(_ context.Context) error {
+	return nil
+}
+
+func (mc *MockConnection) Close() {}
+
+func (mc *MockConnection) Client() Client {
+	return mc.client
+}
+
+func (mc *MockConnection) Keypair() *secp256k1.Keypair {
+	return mc.kp
+}
+
+func (mc *MockConnection) Stats() *chain.RPCStats {
+	return nil
+}
+
+// MockClient is an in-memory chain. Blocks and their logs are added by tests, while
+// sent transactions are recorded and advance the pending nonce of their sender. Calls
+// fail once their context is done, like calls to a node.
+type MockClient struct {
+	mutex    sync.Mutex
+	chainID  *big.Int
+	gasPrice *big.Int
+	headers  []*types.Header
+	logs     []types.Log
+	calls    map[common.Address][]byte
+	// errors of calls to a contract, which take precedence over their output
+	callErrors map[common.Address]error
+	nonces     map[common.Address]uint64
+	balances   map[common.Address]*big.Int
+	sent       []*types.Transaction
+	// dynamic fee transactions which were sent, and the fee history reporting their support
+	sentDynamic []*dynamicFeeTx
+	feeHistory  *FeeHistory
+	receipts    map[common.Hash]*types.Receipt
+	headSubs    []chan<- *types.Header
+	logSubs     []logSubscription
+	// number of reorgs, which tells apart the blocks of each fork
+	forks int
+}
+
+type logSubscription struct {
+	query geth.FilterQuery
+	ch    chan<- types.Log
+}
+
+// NewMockClient creates a client whose chain starts with an empty genesis block
+func NewMockClient(chainID *big.Int) *MockClient {
+	return &MockClient{
+		chainID:    chainID,
+		gasPrice:   big.NewInt(1),
+		headers:    []*types.Header{{Number: big.NewInt(0), Difficulty: big.NewInt(0)}},
+		calls:      make(map[common.Address][]byte),
+		callErrors: make(map[common.Address]error),
+		nonces:     make(map[common.Address]uint64),
+		balances:   make(map[common.Address]*big.Int),
+		receipts:   make(map[common.Hash]*types.Receipt),
+	}
+}
+
+// AddBlock appends a block to the chain, assigning the block number and hash of each of its
+// logs, and pushes the logs and then the block to subscribers. Returns the header of the block.
+func (mc *MockClient) AddBlock(logs ...types.Log) *types.Header {
+	mc.mutex.Lock()
+
+	parent := mc.headers[len(mc.headers)-1]
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		Difficulty: big.NewInt(0),
+		Time:       parent.Time + 1,
+		Extra:      []byte{byte(mc.forks)},
+	}
+	mc.headers = append(mc.headers, header)
+
+	for i := range logs {
+		logs[i].BlockNumber = header.Number.Uint64()
+		logs[i].BlockHash = header.Hash()
+		logs[i].Index = uint(i)
+	}
+	mc.logs = append(mc.logs, logs...)
+
+	logSubs := append([]logSubscription{}, mc.logSubs...)
+	headSubs := append([]chan<- *types.Header{}, mc.headSubs...)
+
+	// subscribers are notified without holding the lock, as they call back into the client
+	mc.mutex.Unlock()
+
+	for i := range logs {
+		for _, sub := range logSubs {
+			if matchLog(sub.query, &logs[i]) {
+				sub.ch <- logs[i]
+			}
+		}
+	}
+
+	for _, ch := range headSubs {
+		ch <- header
+	}
+
+	return header
+}
+
+// Reorg removes the blocks above a height and their logs, so that the blocks added next
+// replace them on another fork
+func (mc *MockClient) Reorg(number uint64) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.headers = mc.headers[:number+1]
+	logs := []types.Log{}
+	for _, log := range mc.logs {
+		if log.BlockNumber <= number {
+			logs = append(logs, log)
+		}
+	}
+	mc.logs = logs
+	mc.forks++
+}
+
+// SetCallResult sets the output of all calls to a contract
+func (mc *MockClient) SetCallResult(address common.Address, output []byte) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.calls[address] = output
+}
+
+// SetCallError makes all calls to a contract fail, for example as reverted, or succeed
+// again if err is nil
+func (mc *MockClient) SetCallError(address common.Address, err error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if err == nil {
+		delete(mc.callErrors, address)
+		return
+	}
+	mc.callErrors[address] = err
+}
+
+// SetGasPrice sets the suggested gas price
+func (mc *MockClient) SetGasPrice(gasPrice *big.Int) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.gasPrice = gasPrice
+}
+
+// SetReceipt sets the receipt of a transaction, which is otherwise not found
+func (mc *MockClient) SetReceipt(hash common.Hash, receipt *types.Receipt) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.receipts[hash] = receipt
+}
+
+// Sent returns the legacy transactions which were sent, in order
+func (mc *MockClient) Sent() []*types.Transaction {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return append([]*types.Transaction{}, mc.sent...)
+}
+
+// SetFeeHistory sets the result of eth_feeHistory, which is served once set, so that the
+// chain supports dynamic fees
+func (mc *MockClient) SetFeeHistory(history *FeeHistory) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.feeHistory = history
+}
+
+// sentDynamicFeeTxs returns the dynamic fee transactions which were sent, in order
+func (mc *MockClient) sentDynamicFeeTxs() []*dynamicFeeTx {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return append([]*dynamicFeeTx{}, mc.sentDynamic...)
+}
+
+func (mc *MockClient) ChainID(ctx context.Context) (*big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return mc.chainID, nil
+}
+
+func (mc *MockClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return mc.chainID, nil
+}
+
+func (mc *MockClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if number == nil {
+		return mc.headers[len(mc.headers)-1], nil
+	}
+	if !number.IsUint64() || number.Uint64() >= uint64(len(mc.headers)) {
+		return nil, geth.NotFound
+	}
+	return mc.headers[number.Uint64()], nil
+}
+
+func (mc *MockClient) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	for _, header := range mc.headers {
+		if header.Hash() == hash {
+			return header, nil
+		}
+	}
+	return nil, geth.NotFound
+}
+
+func (mc *MockClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.gasPrice, nil
+}
+
+// SetPendingNonce sets the pending nonce of an account, as if transactions were sent or
+// dropped without the writer
+func (mc *MockClient) SetPendingNonce(account common.Address, nonce uint64) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.nonces[account] = nonce
+}
+
+func (mc *MockClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.nonces[account], nil
+}
+
+// SetBalance sets the balance of an account, which is zero until set
+func (mc *MockClient) SetBalance(account common.Address, balance *big.Int) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.balances[account] = balance
+}
+
+func (mc *MockClient) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if balance, ok := mc.balances[account]; ok {
+		return new(big.Int).Set(balance), nil
+	}
+	return new(big.Int), nil
+}
+
+func (mc *MockClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sender, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.sent = append(mc.sent, tx)
+	if tx.Nonce() >= mc.nonces[sender] {
+		mc.nonces[sender] = tx.Nonce() + 1
+	}
+	return nil
+}
+
+func (mc *MockClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	receipt, ok := mc.receipts[hash]
+	if !ok {
+		return nil, geth.NotFound
+	}
+	return receipt, nil
+}
+
+func (mc *MockClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	for _, tx := range mc.sent {
+		if tx.Hash() == hash {
+			_, ok := mc.receipts[hash]
+			return tx, !ok, nil
+		}
+	}
+	return nil, false, geth.NotFound
+}
+
+func (mc *MockClient) CallContract(ctx context.Context, msg geth.CallMsg, _ *big.Int) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if msg.To == nil {
+		return nil, nil
+	}
+	if err, ok := mc.callErrors[*msg.To]; ok {
+		return nil, err
+	}
+	return mc.calls[*msg.To], nil
+}
+
+func (mc *MockClient) EstimateGas(ctx context.Context, _ geth.CallMsg) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return gasLimit, nil
+}
+
+func (mc *MockClient) FilterLogs(ctx context.Context, query geth.FilterQuery) ([]types.Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	result := []types.Log{}
+	for i := range mc.logs {
+		if matchLog(query, &mc.logs[i]) {
+			result = append(result, mc.logs[i])
+		}
+	}
+	return result, nil
+}
+
+func (mc *MockClient) SubscribeFilterLogs(ctx context.Context, query geth.FilterQuery, ch chan<- types.Log) (geth.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.logSubs = append(mc.logSubs, logSubscription{query: query, ch: ch})
+	return newMockSubscription(), nil
+}
+
+func (mc *MockClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (geth.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.headSubs = append(mc.headSubs, ch)
+	return newMockSubscription(), nil
+}
+
+func (mc *MockClient) FeeHistory(ctx context.Context, blocks uint64, percentiles []float64) (*FeeHistory, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.feeHistory == nil {
+		return nil, &mockRPCError{code: -32601, message: "the method eth_feeHistory does not exist/is not available"}
+	}
+	return mc.feeHistory, nil
+}
+
+func (mc *MockClient) SendRawTransaction(ctx context.Context, raw []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx, sender, err := decodeDynamicFeeTx(raw)
+	if err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.sentDynamic = append(mc.sentDynamic, tx)
+	if tx.Nonce() >= mc.nonces[sender] {
+		mc.nonces[sender] = tx.Nonce() + 1
+	}
+	return nil
+}
+
+// EffectiveGasPrice charges dynamic fee transactions their max fee per gas, and returns nil
+// for legacy transactions
+func (mc *MockClient) EffectiveGasPrice(ctx context.Context, hash common.Hash) (*big.Int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if _, ok := mc.receipts[hash]; !ok {
+		return nil, geth.NotFound
+	}
+	for _, tx := range mc.sentDynamic {
+		if tx.Hash() == hash {
+			return tx.GasPrice(), nil
+		}
+	}
+	return nil, nil
+}
+
+func (mc *MockClient) Close() {}
+
+// mockRPCError is an error returned by a node
+type mockRPCError struct {
+	code    int
+	message string
+}
+
+func (e *mockRPCError) Error() string {
+	return e.message
+}
+
+func (e *mockRPCError) ErrorCode() int {
+	return e.code
+}
+
+// matchLog applies the addresses and block range of a query, ignoring topics
+func matchLog(query geth.FilterQuery, log *types.Log) bool {
+	if query.FromBlock != nil && log.BlockNumber < query.FromBlock.Uint64() {
+		return false
+	}
+	if query.ToBlock != nil && log.BlockNumber > query.ToBlock.Uint64() {
+		return false
+	}
+	if len(query.Addresses) == 0 {
+		return true
+	}
+	for _, address := range query.Addresses {
+		if address == log.Address {
+			return true
+		}
+	}
+	return false
+}
+
+// newMockSubscription returns a subscription which never fails
+func newMockSubscription() geth.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Network delivers messages to a further Ethereum network, such as a testnet relayed
+// alongside mainnet, through its own connection, key and apps. Only its writer runs, as
+// the events of its apps are not relayed back to Substrate.
+type Network struct {
+	name   string
+	config *Config
+	writer *Writer
+	conn   Connection
+	// connection of the writer, the same as conn unless submissions have their own endpoint
+	submit Connection
+}
+
+var _ chain.Chain = &Network{}
+
+// NewNetwork creates the writer of a further network, named after the identifier by which
+// messages are routed to it
+func NewNetwork(network string, config *Config, messages chan chain.Message, services *chain.Services) (*Network, error) {
+	log := logrus.WithFields(logrus.Fields{"chain": Name, "network": network})
+
+	kp, err := LoadKeypair(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, submit, err := newConnections(config, kp, log)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := NewWriter(config, submit, messages, services.Receipts, services.Pricer, services.Skipped, log)
+	if err != nil {
+		return nil, err
+	}
+	writer.DivertFailures(services.DeadLetters)
+	writer.ReportBackpressure(services.Diagnostics)
+	writer.RecordIntents(NetworkName(network), services.Intents)
+
+	return &Network{
+		name:   network,
+		config: config,
+		writer: writer,
+		conn:   conn,
+		submit: submit,
+	}, nil
+}
+
+// NetworkName is the name of the chain of a further network
+func NetworkName(network string) string {
+	return Name + "/" + network
+}
+
+func (nw *Network) Name() string {
+	return NetworkName(nw.name)
+}
+
+func (nw *Network) Start(ctx context.Context, eg *errgroup.Group) error {
+	err := nw.conn.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if nw.submit != nw.conn {
+		err = nw.submit.Connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if nw.config.ReadOnly {
+		logrus.WithFields(logrus.Fields{"chain": Name, "network": nw.name}).Info("Writer is disabled in read-only mode")
+		return nil
+	}
+	return nw.writer.Start(ctx, eg)
+}
+
+func (nw *Network) Stop() {
+	nw.conn.Close()
+	if nw.submit != nw.conn {
+		nw.submit.Close()
+	}
+}
+
+// WriterGate returns the gate through which submissions to this network can be halted
+func (nw *Network) WriterGate() *chain.Gate {
+	return nw.writer.Gate()
+}
+
+// AccountNonces returns the nonce of each account from which the writer sends transactions,
+// none if the chain is read-only
+func (nw *Network) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	if nw.config.ReadOnly {
+		return nil, nil
+	}
+	return nw.writer.AccountNonces(ctx)
+}
+
+// LastSubmission returns when the writer last completed a submission, zero if it never did
+func (nw *Network) LastSubmission() time.Time {
+	return nw.writer.LastSubmission()
+}
+
+// BalanceInterval returns the interval between checks of the balances of the accounts
+// paying for the submissions of the writer, zero if they aren't monitored or the network is
+// read-only
+func (nw *Network) BalanceInterval() time.Duration {
+	if nw.config.ReadOnly {
+		return 0
+	}
+	return nw.writer.BalanceInterval()
+}
+
+// CheckBalances checks the balances of the accounts paying for the submissions of the writer
+func (nw *Network) CheckBalances(ctx context.Context) error {
+	return nw.writer.CheckBalances(ctx)
+}
+
+// WriterQueues returns the messages pending submission to each app on this network
+func (nw *Network) WriterQueues() []chain.QueueStats {
+	return nw.writer.Queues()
+}
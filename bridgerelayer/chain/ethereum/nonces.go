@@ -0,0 +1,245 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type NonceConfig struct {
+	// Seconds after which the local nonce of an account is checked against the pending nonce
+	// reported by the node, to detect gaps and transactions sent by others from the same key.
+	// Defaults to 60.
+	ResyncInterval uint64 `mapstructure:"resync-interval"`
+	// Whether the transactions of each account are signed and sent one at a time, so that
+	// the node receives them in nonce order. They are sent concurrently if disabled.
+	Serialize bool `mapstructure:"serialize"`
+}
+
+const defaultNonceResyncInterval = 60 * time.Second
+
+// NonceManager assigns the nonces of the transactions sent by the writer from a local
+// counter per account, rather than asking the node for the pending nonce of each
+// transaction, which reuses the nonce of a concurrent transaction the node hasn't seen yet.
+//
+// The counter is checked against the pending nonce of the node when an account is first
+// used, after each resync interval, and after the node rejected a nonce. A node ahead of
+// the counter means that transactions were sent from the same key by someone else, whose
+// nonces are skipped. A node behind it while no transaction of the account is being sent
+// means a gap, left by a transaction dropped from the mempool, which holds back every later
+// transaction of the account, so the next transaction fills it. Nonces of transactions
+// which failed to send are reused, lowest first. Gaps and external transactions are
+// exported as metrics.
+type NonceManager struct {
+	resync    time.Duration
+	serialize bool
+	mutex     sync.Mutex
+	accounts  map[common.Address]*accountNonces
+	log       *logrus.Entry
+}
+
+type accountNonces struct {
+	// next nonce assigned from the counter
+	next     uint64
+	syncedAt time.Time
+	// whether the counter must be checked before the next nonce is assigned
+	stale bool
+	// nonces of transactions which failed to send, sorted
+	released []uint64
+	// nonces assigned to transactions which are being sent
+	reserved int
+	// held while a transaction of the account is being sent, if serialized
+	sending chan struct{}
+}
+
+// reservation is a nonce assigned to a transaction, which must be reported as sent or
+// failed
+type reservation struct {
+	nm      *NonceManager
+	account common.Address
+	value   uint64
+}
+
+func NewNonceManager(config *NonceConfig, log *logrus.Entry) *NonceManager {
+	resync := time.Duration(config.ResyncInterval) * time.Second
+	if resync == 0 {
+		resync = defaultNonceResyncInterval
+	}
+
+	return &NonceManager{
+		resync:    resync,
+		serialize: config.Serialize,
+		accounts:  make(map[common.Address]*accountNonces),
+		log:       log,
+	}
+}
+
+// reserve assigns the next nonce of an account, waiting for the transaction of the account
+// being sent if they are serialized
+func (nm *NonceManager) reserve(ctx context.Context, client Client, account common.Address) (*reservation, error) {
+	nm.mutex.Lock()
+	an, ok := nm.accounts[account]
+	if !ok {
+		an = &accountNonces{stale: true, sending: make(chan struct{}, 1)}
+		nm.accounts[account] = an
+	}
+	nm.mutex.Unlock()
+
+	if nm.serialize {
+		select {
+		case an.sending <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if an.stale || time.Since(an.syncedAt) >= nm.resync {
+		err := nm.sync(ctx, client, account, an)
+		if err != nil {
+			if nm.serialize {
+				<-an.sending
+			}
+			return nil, err
+		}
+	}
+
+	var nonce uint64
+	if len(an.released) > 0 {
+		nonce = an.released[0]
+		an.released = an.released[1:]
+	} else {
+		nonce = an.next
+		an.next++
+	}
+	an.reserved++
+
+	return &reservation{nm: nm, account: account, value: nonce}, nil
+}
+
+// Accounts returns the nonces tracked for each account, sorted by address. The nonces on
+// chain are not filled in.
+func (nm *NonceManager) Accounts() []chain.NonceState {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	states := make([]chain.NonceState, 0, len(nm.accounts))
+	for account, an := range nm.accounts {
+		states = append(states, chain.NonceState{
+			Account:  account.Hex(),
+			Next:     an.next,
+			Reserved: an.reserved,
+			Released: append([]uint64{}, an.released...),
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Account < states[j].Account
+	})
+	return states
+}
+
+// sync checks the counter of an account against the pending nonce of the node. Callers
+// must hold the mutex.
+func (nm *NonceManager) sync(ctx context.Context, client Client, account common.Address, an *accountNonces) error {
+	pending, err := client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	log := nm.log.WithFields(logrus.Fields{
+		"account": account.Hex(),
+		"pending": pending,
+		"local":   an.next,
+	})
+
+	synced := !an.syncedAt.IsZero()
+	switch {
+	case !synced:
+		an.next = pending
+	case pending > an.next:
+		metrics.NonceAnomalies.WithLabelValues(Name, "external").Inc()
+		log.Warn("Detected transactions sent by someone else from the relayer account, skipping their nonces")
+		an.next = pending
+	case pending < an.next && an.reserved == 0 && !an.isReleased(pending):
+		metrics.NonceAnomalies.WithLabelValues(Name, "gap").Inc()
+		log.Warn("Detected nonce gap left by a dropped transaction, filling it with the next transaction")
+		an.release(pending)
+	}
+
+	// nonces which the node already saw can't be reused
+	for len(an.released) > 0 && an.released[0] < pending {
+		an.released = an.released[1:]
+	}
+
+	an.stale = false
+	an.syncedAt = time.Now()
+	return nil
+}
+
+// sent reports that the transaction of a nonce was sent
+func (re *reservation) sent() {
+	re.done(func(an *accountNonces) {})
+}
+
+// failed reports that the transaction of a nonce failed to send, so that the nonce is
+// reused unless the node rejected it
+func (re *reservation) failed(err error) {
+	re.done(func(an *accountNonces) {
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "nonce too low") || strings.Contains(message, "nonce too high") {
+			an.stale = true
+			return
+		}
+		an.release(re.value)
+	})
+}
+
+func (re *reservation) done(update func(an *accountNonces)) {
+	nm := re.nm
+	nm.mutex.Lock()
+	an := nm.accounts[re.account]
+	an.reserved--
+	update(an)
+	nm.mutex.Unlock()
+
+	if nm.serialize {
+		<-an.sending
+	}
+}
+
+// release makes a nonce available again, giving back the top of the counter
+func (an *accountNonces) release(nonce uint64) {
+	if nonce+1 == an.next {
+		an.next--
+		return
+	}
+	if an.isReleased(nonce) {
+		return
+	}
+	an.released = append(an.released, nonce)
+	sort.Slice(an.released, func(i, j int) bool {
+		return an.released[i] < an.released[j]
+	})
+}
+
+func (an *accountNonces) isReleased(nonce uint64) bool {
+	for _, released := range an.released {
+		if released == nonce {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reserveNonce(t *testing.T, nm *NonceManager, client Client, account common.Address) *reservation {
+	re, err := nm.reserve(context.Background(), client, account)
+	require.NoError(t, err)
+	return re
+}
+
+func TestNonceManager(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient(big.NewInt(15))
+	account := common.Address{1}
+	client.SetPendingNonce(account, 5)
+	nm := NewNonceManager(&NonceConfig{}, logrus.NewEntry(logger))
+
+	// the counter starts at the pending nonce of the node, which isn't asked again
+	first := reserveNonce(t, nm, client, account)
+	second := reserveNonce(t, nm, client, account)
+	assert.Equal(t, uint64(5), first.value)
+	assert.Equal(t, uint64(6), second.value)
+	client.SetPendingNonce(account, 0)
+	third := reserveNonce(t, nm, client, account)
+	assert.Equal(t, uint64(7), third.value)
+
+	// nonces of failed transactions are reused, lowest first
+	first.failed(fmt.Errorf("connection refused"))
+	second.failed(fmt.Errorf("connection refused"))
+	third.sent()
+	assert.Equal(t, uint64(5), reserveNonce(t, nm, client, account).value)
+	assert.Equal(t, uint64(6), reserveNonce(t, nm, client, account).value)
+	assert.Equal(t, uint64(8), reserveNonce(t, nm, client, account).value)
+}
+
+func TestNonceManager_Resync(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient(big.NewInt(15))
+	account := common.Address{1}
+	nm := NewNonceManager(&NonceConfig{}, logrus.NewEntry(logger))
+
+	reserveNonce(t, nm, client, account).sent()
+	reserveNonce(t, nm, client, account).sent()
+
+	// nonces used by transactions sent by someone else are skipped once the node rejects one
+	client.SetPendingNonce(account, 4)
+	rejected := reserveNonce(t, nm, client, account)
+	assert.Equal(t, uint64(2), rejected.value)
+	rejected.failed(fmt.Errorf("nonce too low"))
+	external := reserveNonce(t, nm, client, account)
+	assert.Equal(t, uint64(4), external.value)
+	external.sent()
+
+	// a transaction dropped from the mempool leaves a gap, which the next transaction fills
+	nm.resync = time.Nanosecond
+	client.SetPendingNonce(account, 3)
+	assert.Equal(t, uint64(3), reserveNonce(t, nm, client, account).value)
+	assert.Equal(t, uint64(5), reserveNonce(t, nm, client, account).value)
+}
+
+func TestNonceManager_Serialize(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient(big.NewInt(15))
+	account := common.Address{1}
+	nm := NewNonceManager(&NonceConfig{Serialize: true}, logrus.NewEntry(logger))
+
+	// the next transaction of the account waits until the previous one was sent
+	first := reserveNonce(t, nm, client, account)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := nm.reserve(ctx, client, account)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	first.sent()
+	assert.Equal(t, uint64(1), reserveNonce(t, nm, client, account).value)
+
+	// other accounts aren't held back
+	assert.Equal(t, uint64(0), reserveNonce(t, nm, client, common.Address{2}).value)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Observe decodes an event of the contract with its ABI, for the event feed
+func (c *Contract) Observe(event *gethTypes.Log) (*chain.ObservedEvent, error) {
+	if len(event.Topics) == 0 {
+		return nil, fmt.Errorf("event has no topics")
+	}
+
+	definition, err := c.ABI.EventByID(event.Topics[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	err = definition.Inputs.NonIndexed().UnpackIntoMap(values, event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexed abi.Arguments
+	for _, input := range definition.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	err = abi.ParseTopicsIntoMap(values, indexed, event.Topics[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		fields[name] = jsonValue(reflect.ValueOf(value))
+	}
+
+	return &chain.ObservedEvent{
+		Chain:       Name,
+		App:         c.Name,
+		Name:        definition.Name,
+		BlockNumber: event.BlockNumber,
+		BlockHash:   event.BlockHash.Hex(),
+		TxHash:      event.TxHash.Hex(),
+		Index:       uint64(event.Index),
+		Fields:      fields,
+		ObservedAt:  time.Now(),
+	}, nil
+}
+
+// hexer is implemented by addresses and hashes
+type hexer interface {
+	Hex() string
+}
+
+// jsonValue converts a decoded ABI value into a JSON-friendly value, representing integers
+// which may not fit a JSON number as decimal strings, and byte values in hex
+func jsonValue(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch v := value.Interface().(type) {
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return hexutil.Encode(v)
+	case hexer:
+		return v.Hex()
+	}
+
+	switch value.Kind() {
+	case reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(data), value)
+			return hexutil.Encode(data)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = jsonValue(value.Index(i))
+		}
+		return items
+	case reflect.Int64, reflect.Uint64:
+		return fmt.Sprint(value.Interface())
+	}
+
+	return value.Interface()
+}
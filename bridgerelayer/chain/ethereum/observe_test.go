@@ -0,0 +1,53 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContract_Observe(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testCallABI))
+	require.NoError(t, err)
+
+	contract := Contract{Name: "eth", Address: common.HexToAddress("0x01"), ABI: &contractABI}
+
+	data, err := contractABI.Events["Dispatch"].Inputs.NonIndexed().Pack(uint64(7), []byte{0x04, 0x01})
+	require.NoError(t, err)
+
+	event := types.Log{
+		Address:     contract.Address,
+		Topics:      []common.Hash{contractABI.Events["Dispatch"].ID, common.HexToHash("0x02")},
+		Data:        data,
+		BlockNumber: 12,
+		BlockHash:   common.HexToHash("0x03"),
+		TxHash:      common.HexToHash("0x04"),
+		Index:       1,
+	}
+
+	observed, err := contract.Observe(&event)
+	require.NoError(t, err)
+	assert.Equal(t, Name, observed.Chain)
+	assert.Equal(t, "eth", observed.App)
+	assert.Equal(t, "Dispatch", observed.Name)
+	assert.Equal(t, uint64(12), observed.BlockNumber)
+	assert.Equal(t, event.TxHash.Hex(), observed.TxHash)
+	assert.Equal(t, map[string]interface{}{
+		"_sender": common.HexToAddress("0x02").Hex(),
+		"_nonce":  "7",
+		"_args":   "0x0401",
+	}, observed.Fields)
+
+	// events unknown to the ABI can't be decoded
+	event.Topics = []common.Hash{common.HexToHash("0x05")}
+	_, err = contract.Observe(&event)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ContractParameter reads an on-chain parameter through a getter of a contract, given by the
+// name of its app or its address. The getter is a view function returning a number or a
+// boolean, which takes no arguments or the address of the relayer account, such as
+// requiredConfirmations() or isRelayer(address).
+func (ch *Chain) ContractParameter(ctx context.Context, contract string, method string) (*big.Int, error) {
+	var address common.Address
+	if app, ok := ch.config.Apps[contract]; ok {
+		address = common.HexToAddress(app.Address)
+	} else if common.IsHexAddress(contract) {
+		address = common.HexToAddress(contract)
+	} else {
+		return nil, fmt.Errorf("%s is neither an app nor a contract address", contract)
+	}
+
+	input, err := packGetter(method, ch.conn.Keypair().CommonAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ch.conn.Client().CallContract(ctx, geth.CallMsg{To: &address, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(output) < common.HashLength {
+		return nil, fmt.Errorf("%s of %s returned %d bytes instead of a word", method, contract, len(output))
+	}
+
+	return new(big.Int).SetBytes(output[:common.HashLength]), nil
+}
+
+// packGetter encodes the call of a getter taking no arguments or the relayer address
+func packGetter(method string, relayer common.Address) ([]byte, error) {
+	open := strings.Index(method, "(")
+	if open <= 0 || !strings.HasSuffix(method, ")") {
+		return nil, fmt.Errorf("getter %q is not of the form name() or name(address)", method)
+	}
+
+	input := crypto.Keccak256([]byte(method))[:4]
+	switch method[open+1 : len(method)-1] {
+	case "":
+		return input, nil
+	case "address":
+		return append(input, common.LeftPadBytes(relayer.Bytes(), common.HashLength)...), nil
+	default:
+		return nil, fmt.Errorf("getter %q is not of the form name() or name(address)", method)
+	}
+}
+
+// RequireConfirmations holds the events of later blocks until they have a number of
+// confirmations, never fewer than the finality depth
+func (ch *Chain) RequireConfirmations(confirmations uint64) {
+	ch.listener.RequireConfirmations(confirmations)
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestPackGetter(t *testing.T) {
+	relayer := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	input, err := packGetter("paused()", relayer)
+	require.NoError(t, err)
+	assert.Equal(t, "0x5c975abb", hexutil.Encode(input))
+
+	input, err = packGetter("isRelayer(address)", relayer)
+	require.NoError(t, err)
+	assert.Len(t, input, 4+common.HashLength)
+	assert.Equal(t, relayer.Bytes(), input[len(input)-common.AddressLength:])
+
+	for _, method := range []string{"fee", "(address)", "fee(uint256)", "fee(address,address)"} {
+		_, err = packGetter(method, relayer)
+		assert.Error(t, err, method)
+	}
+}
+
+func TestListener_RequireConfirmations(t *testing.T) {
+	li := &Listener{depth: 12}
+	override := &chain.Override{Name: "exchange", Confirmations: 4}
+
+	assert.Equal(t, uint64(12), li.confirmations(nil))
+	assert.Equal(t, uint64(16), li.confirmations(override))
+
+	// the required confirmations apply if they exceed the depth and the override
+	li.RequireConfirmations(14)
+	assert.Equal(t, uint64(14), li.confirmations(nil))
+	assert.Equal(t, uint64(16), li.confirmations(override))
+
+	li.RequireConfirmations(6)
+	assert.Equal(t, uint64(12), li.confirmations(nil))
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"time"
+
+	geth "github.com/ethereum/go-ethereum"
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// PausableABI is the interface of OpenZeppelin's Pausable contracts
+const PausableABI = `[
+	{"inputs":[],"name":"paused","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},
+	{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"account","type":"address"}],"name":"Paused","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"account","type":"address"}],"name":"Unpaused","type":"event"}
+]`
+
+var pausableABI = mustParseABI(PausableABI)
+
+// PauseWatcher halts the writer while any of the app contracts is paused. The pause
+// state is polled, and rechecked immediately when a contract emits Paused or Unpaused.
+type PauseWatcher struct {
+	conn      Connection
+	contracts []Contract
+	gate      *chain.Gate
+	interval  time.Duration
+	paused    bool
+	log       *logrus.Entry
+}
+
+func NewPauseWatcher(config *PauseConfig, conn Connection, contracts []Contract, gate *chain.Gate, log *logrus.Entry) *PauseWatcher {
+	return &PauseWatcher{
+		conn:      conn,
+		contracts: contracts,
+		gate:      gate,
+		interval:  time.Duration(config.Interval) * time.Second,
+		log:       log,
+	}
+}
+
+func (pw *PauseWatcher) Start(ctx context.Context, eg *errgroup.Group) error {
+	addresses := make([]gethCommon.Address, len(pw.contracts))
+	for i, contract := range pw.contracts {
+		addresses[i] = contract.Address
+	}
+
+	events := make(chan gethTypes.Log)
+	_, err := pw.conn.Client().SubscribeFilterLogs(ctx, geth.FilterQuery{
+		Addresses: addresses,
+		Topics: [][]gethCommon.Hash{{
+			pausableABI.Events["Paused"].ID,
+			pausableABI.Events["Unpaused"].ID,
+		}},
+	}, events)
+	if err != nil {
+		pw.log.WithError(err).Warn("Failed to subscribe to pause events, relying on polling")
+	}
+
+	pw.check(ctx)
+
+	eg.Go(func() error {
+		ticker := time.NewTicker(pw.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				pw.check(ctx)
+			case <-events:
+				pw.check(ctx)
+			}
+		}
+	})
+
+	return nil
+}
+
+func (pw *PauseWatcher) check(ctx context.Context) {
+	paused := []string{}
+	for _, contract := range pw.contracts {
+		isPaused, ok := pw.isPaused(ctx, contract)
+		if !ok {
+			// Keep the current state if the pause state is unknown
+			return
+		}
+		if isPaused {
+			paused = append(paused, contract.Name)
+		}
+	}
+
+	if len(paused) > 0 && !pw.paused {
+		pw.log.WithField("apps", paused).Warn("App contracts are paused on-chain, halting writer")
+		pw.gate.Halt(chain.ReasonPausedOnChain)
+	} else if len(paused) == 0 && pw.paused {
+		pw.log.Info("App contracts are no longer paused, resuming writer")
+		pw.gate.Release(chain.ReasonPausedOnChain)
+	}
+	pw.paused = len(paused) > 0
+}
+
+// isPaused queries the pause state of a contract. Contracts which are not pausable
+// are reported as not paused.
+func (pw *PauseWatcher) isPaused(ctx context.Context, contract Contract) (bool, bool) {
+	data, err := pausableABI.Pack("paused")
+	if err != nil {
+		pw.log.WithError(err).Error("Failed to pack paused call")
+		return false, false
+	}
+
+	address := contract.Address
+	output, err := pw.conn.Client().CallContract(ctx, geth.CallMsg{To: &address, Data: data}, nil)
+	if err != nil {
+		pw.log.WithError(err).WithField("app", contract.Name).Warn("Failed to query pause state")
+		return false, false
+	}
+	if len(output) == 0 {
+		return false, true
+	}
+
+	var paused bool
+	err = pausableABI.Unpack(&paused, "paused", output)
+	if err != nil {
+		pw.log.WithError(err).WithField("app", contract.Name).Warn("Failed to decode pause state")
+		return false, false
+	}
+
+	return paused, true
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// VerifyPayment checks that a transaction succeeded in paying at least an amount of ETH
+// to the account of the relayer
+func (ch *Chain) VerifyPayment(ctx context.Context, hash [32]byte, amount *big.Int) error {
+	txHash := common.Hash(hash)
+
+	tx, pending, err := ch.conn.Client().TransactionByHash(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return fmt.Errorf("payment %s is still pending", txHash.Hex())
+	}
+
+	relayer := ch.conn.Keypair().CommonAddress()
+	if tx.To() == nil || *tx.To() != relayer {
+		return fmt.Errorf("payment %s is not made to the relayer %s", txHash.Hex(), relayer.Hex())
+	}
+	if tx.Value().Cmp(amount) < 0 {
+		return fmt.Errorf("payment %s of %s wei is below the fee of %s wei", txHash.Hex(), tx.Value(), amount)
+	}
+
+	receipt, err := ch.conn.Client().TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("payment %s failed", txHash.Hex())
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type ConfirmationConfig struct {
+	// Blocks which must be built on the block including a delivery before it is reported
+	// as confirmed. Deliveries are confirmed once they are included if zero.
+	Depth uint64 `mapstructure:"depth"`
+	// Seconds after which a transaction which was not included is rebroadcast with the same
+	// nonce and a bumped gas price. Zero disables rebroadcasts.
+	StuckTimeout uint64 `mapstructure:"stuck-timeout"`
+	// Percentage by which the gas price of each rebroadcast is bumped. Nodes only replace a
+	// transaction whose gas price is at least 10% higher. Defaults to 12.
+	GasBump uint64 `mapstructure:"gas-bump"`
+	// Highest gas price in wei offered by rebroadcasts. Unlimited if empty.
+	MaxGasPrice string `mapstructure:"max-gas-price"`
+}
+
+const (
+	defaultGasBump = 12
+	minGasBump     = 10
+)
+
+// pendingTx is a transaction awaiting confirmation, with the replacements which were
+// rebroadcast in its place
+type pendingTx struct {
+	kp *secp256k1.Keypair
+	// latest broadcast of the transaction
+	tx signedTransaction
+	// hashes of all broadcasts, oldest first, any of which may be included
+	hashes       []common.Hash
+	submittedAt  time.Time
+	broadcastAt  time.Time
+	rebroadcasts int
+}
+
+// PendingTransaction reports a transaction awaiting confirmation
+type PendingTransaction struct {
+	// Hash of the first broadcast of the transaction, by which the delivery is known
+	Hash         string    `json:"hash"`
+	Nonce        uint64    `json:"nonce"`
+	GasPrice     string    `json:"gasPrice"`
+	Rebroadcasts int       `json:"rebroadcasts"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+// PendingSet tracks the transactions of the writer from their submission until they reach
+// the confirmation depth, and replaces those which are stuck in the mempool beyond the
+// timeout with a copy offering a bumped gas price. A stuck transaction is usually
+// underpriced after the base fee rose, and holds back every later nonce of its account.
+// The size of the set and its oldest submission are exported as metrics.
+type PendingSet struct {
+	depth    uint64
+	timeout  time.Duration
+	bump     uint64
+	maxPrice *big.Int
+	mutex    sync.Mutex
+	// transactions by the hash of their first broadcast
+	txs map[common.Hash]*pendingTx
+}
+
+func NewPendingSet(config *ConfirmationConfig) (*PendingSet, error) {
+	ps := &PendingSet{
+		depth:   config.Depth,
+		timeout: time.Duration(config.StuckTimeout) * time.Second,
+		bump:    config.GasBump,
+		txs:     make(map[common.Hash]*pendingTx),
+	}
+	if ps.bump == 0 {
+		ps.bump = defaultGasBump
+	}
+	if ps.bump < minGasBump {
+		return nil, fmt.Errorf("gas bump of rebroadcasts must be at least %d%%", minGasBump)
+	}
+
+	if config.MaxGasPrice != "" {
+		maxPrice, ok := new(big.Int).SetString(config.MaxGasPrice, 10)
+		if !ok || maxPrice.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid max gas price of rebroadcasts: %s", config.MaxGasPrice)
+		}
+		ps.maxPrice = maxPrice
+	}
+
+	return ps, nil
+}
+
+// Enabled returns whether transactions are tracked, which is only needed to wait for a
+// confirmation depth or to rebroadcast stuck transactions
+func (ps *PendingSet) Enabled() bool {
+	return ps.depth > 0 || ps.timeout > 0
+}
+
+// Pending returns the transactions awaiting confirmation, oldest first
+func (ps *PendingSet) Pending() []PendingTransaction {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	pending := make([]PendingTransaction, 0, len(ps.txs))
+	for hash, ptx := range ps.txs {
+		pending = append(pending, PendingTransaction{
+			Hash:         hash.Hex(),
+			Nonce:        ptx.tx.Nonce(),
+			GasPrice:     ptx.tx.GasPrice().String(),
+			Rebroadcasts: ptx.rebroadcasts,
+			SubmittedAt:  ptx.submittedAt,
+		})
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].SubmittedAt.Before(pending[j].SubmittedAt)
+	})
+	return pending
+}
+
+func (ps *PendingSet) add(kp *secp256k1.Keypair, tx signedTransaction) {
+	if !ps.Enabled() {
+		return
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	now := time.Now()
+	ps.txs[tx.Hash()] = &pendingTx{
+		kp:          kp,
+		tx:          tx,
+		hashes:      []common.Hash{tx.Hash()},
+		submittedAt: now,
+		broadcastAt: now,
+	}
+	ps.report()
+}
+
+func (ps *PendingSet) remove(hash common.Hash) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if _, ok := ps.txs[hash]; !ok {
+		return
+	}
+	delete(ps.txs, hash)
+	ps.report()
+}
+
+// hashes returns the hashes of all broadcasts of a transaction, only the given hash if it
+// isn't tracked
+func (ps *PendingSet) hashes(hash common.Hash) []common.Hash {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ptx, ok := ps.txs[hash]
+	if !ok {
+		return []common.Hash{hash}
+	}
+	return append([]common.Hash{}, ptx.hashes...)
+}
+
+// gasPrice returns the gas price offered by the latest broadcast of a transaction, nil if
+// it isn't tracked
+func (ps *PendingSet) gasPrice(hash common.Hash) *big.Int {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ptx, ok := ps.txs[hash]
+	if !ok {
+		return nil
+	}
+	return ptx.tx.GasPrice()
+}
+
+// stuck returns a tracked transaction whose latest broadcast outlasted the timeout, nil if
+// it isn't stuck
+func (ps *PendingSet) stuck(hash common.Hash) *pendingTx {
+	if ps.timeout == 0 {
+		return nil
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ptx, ok := ps.txs[hash]
+	if !ok || time.Since(ptx.broadcastAt) < ps.timeout {
+		return nil
+	}
+	copied := *ptx
+	return &copied
+}
+
+// replaced records the rebroadcast of a transaction
+func (ps *PendingSet) replaced(hash common.Hash, tx signedTransaction) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ptx, ok := ps.txs[hash]
+	if !ok {
+		return
+	}
+	ptx.tx = tx
+	ptx.hashes = append(ptx.hashes, tx.Hash())
+	ptx.broadcastAt = time.Now()
+	ptx.rebroadcasts++
+}
+
+// bumpedPrice returns the gas price of the rebroadcast of a transaction, the higher of its
+// bumped gas price and the current gas price, capped by the highest price. False if the
+// transaction already offers the highest price.
+func (ps *PendingSet) bumpedPrice(current *big.Int, suggested *big.Int) (*big.Int, bool) {
+	price := bumpGasPrice(current, ps.bump)
+	if suggested.Cmp(price) > 0 {
+		price = suggested
+	}
+	if ps.maxPrice != nil && price.Cmp(ps.maxPrice) > 0 {
+		if current.Cmp(ps.maxPrice) >= 0 {
+			return nil, false
+		}
+		price = ps.maxPrice
+	}
+	return price, true
+}
+
+// report exports the size and the oldest submission of the set. Callers must hold the mutex.
+func (ps *PendingSet) report() {
+	var oldest time.Time
+	for _, ptx := range ps.txs {
+		if oldest.IsZero() || ptx.submittedAt.Before(oldest) {
+			oldest = ptx.submittedAt
+		}
+	}
+
+	metrics.PendingTransactions.WithLabelValues(Name).Set(float64(len(ps.txs)))
+	if oldest.IsZero() {
+		metrics.OldestPendingTransaction.WithLabelValues(Name).Set(0)
+	} else {
+		metrics.OldestPendingTransaction.WithLabelValues(Name).Set(float64(oldest.Unix()))
+	}
+}
+
+// rebroadcast replaces a transaction which is stuck in the mempool with a copy offering a
+// bumped gas price. Replacements which the node refuses because a broadcast of the
+// transaction was already included are left for the receipt lookup to find.
+func (wr *Writer) rebroadcast(ctx context.Context, hash common.Hash, log *logrus.Entry) {
+	ptx := wr.pending.stuck(hash)
+	if ptx == nil {
+		return
+	}
+
+	// nothing is sent while the writer is halted, stuck transactions included
+	if halted, reason := wr.gate.Halted(); halted {
+		log.WithField("reason", reason).Debug("Leaving stuck transaction while submissions are halted")
+		return
+	}
+
+	suggested, err := wr.txFees(ctx, false)
+	if err != nil {
+		log.WithError(err).Warn("Failed to price rebroadcast of stuck transaction")
+		return
+	}
+
+	gasPrice, ok := wr.pending.bumpedPrice(ptx.tx.GasPrice(), suggested.gasPrice)
+	if !ok {
+		log.WithField("gasPrice", ptx.tx.GasPrice()).Debug("Stuck transaction already offers the highest gas price")
+		return
+	}
+
+	// nodes only replace a dynamic fee transaction if both of its fees are bumped
+	fees := &txFees{gasPrice: gasPrice}
+	if dynamic, ok := ptx.tx.(*dynamicFeeTx); ok {
+		fees.tip = bumpGasPrice(dynamic.GasTipCap(), wr.pending.bump)
+		if suggested.tip != nil && suggested.tip.Cmp(fees.tip) > 0 {
+			fees.tip = suggested.tip
+		}
+		fees.tip, fees.gasPrice = wr.fees.limit(fees.tip, fees.gasPrice)
+	}
+
+	tx, err := wr.signWithFees(ctx, ptx.kp, ptx.tx.Nonce(), *ptx.tx.To(), ptx.tx.Gas(), fees, ptx.tx.Data())
+	if err != nil {
+		log.WithError(err).Error("Failed to sign rebroadcast of stuck transaction")
+		return
+	}
+
+	fields := logrus.Fields{
+		"nonce":            tx.Nonce(),
+		"previousHash":     ptx.hashes[len(ptx.hashes)-1].Hex(),
+		"previousGasPrice": ptx.tx.GasPrice(),
+		"gasPrice":         fees.gasPrice,
+		"pending":          time.Since(ptx.submittedAt).Round(time.Second),
+	}
+
+	err = wr.sendTransaction(ctx, tx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "nonce too low") {
+			log.WithFields(fields).Debug("Stuck transaction was included before its rebroadcast")
+			return
+		}
+		log.WithError(err).WithFields(fields).Warn("Failed to rebroadcast stuck transaction")
+		return
+	}
+
+	wr.pending.replaced(hash, tx)
+	metrics.TransactionRebroadcasts.WithLabelValues(Name).Inc()
+	log.WithFields(fields).WithField("txHash", tx.Hash().Hex()).Warn("Rebroadcast stuck transaction with a bumped gas price")
+}
+
+// confirmed returns whether the block including a delivery is buried under the
+// confirmation depth
+func (wr *Writer) confirmed(ctx context.Context, included *big.Int, log *logrus.Entry) bool {
+	if wr.pending.depth == 0 {
+		return true
+	}
+
+	header, err := wr.conn.Client().HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.WithError(err).Debug("Failed to fetch latest block")
+		return false
+	}
+
+	var confirmations uint64
+	if header.Number.Cmp(included) >= 0 {
+		confirmations = new(big.Int).Sub(header.Number, included).Uint64()
+	}
+	if confirmations < wr.pending.depth {
+		log.WithFields(logrus.Fields{
+			"blockNumber":   included,
+			"confirmations": confirmations,
+			"depth":         wr.pending.depth,
+		}).Debug("Waiting for delivery to reach confirmation depth")
+		return false
+	}
+	return true
+}
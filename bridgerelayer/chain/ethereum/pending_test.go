@@ -0,0 +1,139 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestPendingSet_BumpedPrice(t *testing.T) {
+	_, err := NewPendingSet(&ConfirmationConfig{GasBump: 5})
+	assert.Error(t, err)
+
+	pending, err := NewPendingSet(&ConfirmationConfig{StuckTimeout: 60, MaxGasPrice: "150"})
+	require.NoError(t, err)
+	assert.True(t, pending.Enabled())
+
+	// bumped by the default percentage, rounding up
+	price, ok := pending.bumpedPrice(big.NewInt(100), big.NewInt(90))
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(112), price)
+
+	// or raised to the current gas price if it rose further
+	price, ok = pending.bumpedPrice(big.NewInt(100), big.NewInt(130))
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(130), price)
+
+	// capped by the highest price, until the transaction offers it
+	price, ok = pending.bumpedPrice(big.NewInt(140), big.NewInt(90))
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(150), price)
+	_, ok = pending.bumpedPrice(big.NewInt(150), big.NewInt(90))
+	assert.False(t, ok)
+}
+
+func TestWriter_Rebroadcast(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	client.SetGasPrice(big.NewInt(100))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+
+	config := &Config{Confirmations: ConfirmationConfig{Depth: 2, StuckTimeout: 60}}
+	wr, err := NewWriter(config, conn, nil, nil, nil, nil, log)
+	require.NoError(t, err)
+	wr.pending.timeout = time.Millisecond
+
+	ctx := context.Background()
+	app := common.Address{1}
+	hash, _, err := wr.send(ctx, conn.Keypair(), app, gasLimit, []byte{1, 2, 3}, false)
+	require.NoError(t, err)
+	require.Len(t, wr.pending.Pending(), 1)
+
+	// stuck transactions are replaced by a copy with the same nonce and a bumped gas price
+	time.Sleep(5 * time.Millisecond)
+	wr.rebroadcast(ctx, hash, log)
+	sent := client.Sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, sent[0].Nonce(), sent[1].Nonce())
+	assert.Equal(t, sent[0].Data(), sent[1].Data())
+	assert.Equal(t, big.NewInt(112), sent[1].GasPrice())
+	assert.Equal(t, []common.Hash{hash, sent[1].Hash()}, wr.pending.hashes(hash))
+	assert.Equal(t, 1, wr.pending.Pending()[0].Rebroadcasts)
+
+	// which aren't stuck again until the timeout elapsed since the rebroadcast
+	wr.pending.timeout = time.Hour
+	wr.rebroadcast(ctx, hash, log)
+	assert.Len(t, client.Sent(), 2)
+
+	// the delivery is found by the receipt of whichever broadcast was included
+	result, _, err := wr.fetchReceipt(ctx, hash)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	block := client.AddBlock()
+	client.SetReceipt(sent[1].Hash(), &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: sent[1].Hash(), BlockNumber: block.Number})
+	result, _, err = wr.fetchReceipt(ctx, hash)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, sent[1].Hash(), result.TxHash)
+
+	// and confirmed once buried under the confirmation depth
+	assert.False(t, wr.confirmed(ctx, result.BlockNumber, log))
+	client.AddBlock()
+	assert.False(t, wr.confirmed(ctx, result.BlockNumber, log))
+	client.AddBlock()
+	assert.True(t, wr.confirmed(ctx, result.BlockNumber, log))
+
+	wr.pending.remove(hash)
+	assert.Empty(t, wr.pending.Pending())
+}
+
+func TestWriter_RebroadcastHalted(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	client.SetGasPrice(big.NewInt(100))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+
+	config := &Config{Confirmations: ConfirmationConfig{Depth: 2, StuckTimeout: 60}}
+	wr, err := NewWriter(config, conn, nil, nil, nil, nil, log)
+	require.NoError(t, err)
+	wr.pending.timeout = time.Millisecond
+
+	ctx := context.Background()
+	hash, _, err := wr.send(ctx, conn.Keypair(), common.Address{1}, gasLimit, []byte{1, 2, 3}, false)
+	require.NoError(t, err)
+
+	// stuck transactions aren't rebroadcast while the writer is halted
+	wr.Gate().Halt("emergency stop")
+	time.Sleep(5 * time.Millisecond)
+	wr.rebroadcast(ctx, hash, log)
+	assert.Len(t, client.Sent(), 1)
+
+	// nor is anything else sent
+	_, _, err = wr.send(ctx, conn.Keypair(), common.Address{1}, gasLimit, []byte{4}, false)
+	assert.Error(t, err)
+	assert.Len(t, client.Sent(), 1)
+
+	wr.Gate().Release("emergency stop")
+	wr.rebroadcast(ctx, hash, log)
+	assert.Len(t, client.Sent(), 2)
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ChainInfo describes an Ethereum network discovered through its RPC endpoint
+type ChainInfo struct {
+	ChainID     *big.Int
+	NetworkID   *big.Int
+	BlockNumber uint64
+}
+
+// Probe discovers the properties of the network behind an endpoint and verifies that
+// a contract is deployed at the address of each app, and that its ABI can be loaded. The
+// endpoint is dialed through dialer, by default if nil.
+func Probe(ctx context.Context, endpoint string, dialer *chain.Dialer, apps map[string]Application) (*ChainInfo, error) {
+	rpcClient, err := chain.DialRPC(ctx, endpoint, dialer)
+	if err != nil {
+		return nil, err
+	}
+	client := ethclient.NewClient(rpcClient)
+	defer client.Close()
+
+	var info ChainInfo
+
+	info.ChainID, err = client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info.NetworkID, err = client.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	info.BlockNumber = header.Number.Uint64()
+
+	contracts, err := LoadContracts(&Config{Apps: apps})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contract := range contracts {
+		code, err := client.CodeAt(ctx, contract.Address, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("app %s: no contract deployed at %s", contract.Name, contract.Address.Hex())
+		}
+	}
+
+	return &info, nil
+}
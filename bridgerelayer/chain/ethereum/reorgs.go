@@ -0,0 +1,295 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type FinalityConfig struct {
+	// Confirmations which the block of an event must have before the event is relayed, by
+	// chain ID, overriding the defaults of known networks. Events of networks without a
+	// depth are relayed as soon as they are emitted.
+	Depths map[string]uint64 `mapstructure:"depths"`
+}
+
+// defaultDepths are the confirmation depths of known networks by chain ID: mainnet, and the
+// Goerli, Holesky and Sepolia testnets
+var defaultDepths = map[string]uint64{
+	"1":        12,
+	"5":        6,
+	"17000":    6,
+	"11155111": 6,
+}
+
+// depth returns the confirmation depth of a network
+func (config *FinalityConfig) depth(chainID *big.Int) uint64 {
+	if depth, ok := config.Depths[chainID.String()]; ok {
+		return depth
+	}
+	return defaultDepths[chainID.String()]
+}
+
+// segment is the recent chain followed by the listener, from which new heads are checked for
+// reorgs. It keeps the hashes of the blocks up to checkpointReorgDepth below the confirmation
+// depth, and the messages relayed for the events of each block, so that those orphaned by a
+// reorg can be invalidated.
+type segment struct {
+	mutex sync.Mutex
+	size  int
+	// hashes of contiguous blocks, from the oldest to the head
+	first  uint64
+	hashes []gethCommon.Hash
+	// messages relayed for the events of each block, by block hash
+	relayed map[gethCommon.Hash]*relayedBlock
+}
+
+type relayedBlock struct {
+	number   uint64
+	messages []chain.Message
+}
+
+func newSegment(depth uint64) *segment {
+	return &segment{
+		size:    int(depth + checkpointReorgDepth),
+		relayed: make(map[gethCommon.Hash]*relayedBlock),
+	}
+}
+
+// hashAt returns the hash of a block of the segment
+func (sg *segment) hashAt(number uint64) (gethCommon.Hash, bool) {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	if len(sg.hashes) == 0 || number < sg.first || number >= sg.first+uint64(len(sg.hashes)) {
+		return gethCommon.Hash{}, false
+	}
+	return sg.hashes[number-sg.first], true
+}
+
+// add appends a block, replacing the blocks from its height up, and drops the oldest blocks
+// beyond the size of the segment
+func (sg *segment) add(number uint64, hash gethCommon.Hash) {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	if len(sg.hashes) > 0 && (number < sg.first || number > sg.first+uint64(len(sg.hashes))) {
+		sg.hashes = nil
+	}
+	if len(sg.hashes) == 0 {
+		sg.first = number
+	}
+	sg.hashes = append(sg.hashes[:number-sg.first], hash)
+
+	if len(sg.hashes) > sg.size {
+		dropped := len(sg.hashes) - sg.size
+		sg.hashes = append([]gethCommon.Hash{}, sg.hashes[dropped:]...)
+		sg.first += uint64(dropped)
+	}
+
+	for hash, block := range sg.relayed {
+		if block.number < sg.first {
+			delete(sg.relayed, hash)
+		}
+	}
+}
+
+// oldest returns the height of the first block of the segment
+func (sg *segment) oldest() uint64 {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	return sg.first
+}
+
+// top returns the height of the last block of the segment, if any
+func (sg *segment) top() (uint64, bool) {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	if len(sg.hashes) == 0 {
+		return 0, false
+	}
+	return sg.first + uint64(len(sg.hashes)) - 1, true
+}
+
+// rollback removes the blocks above a height, returning the messages relayed for their events
+func (sg *segment) rollback(number uint64) []chain.Message {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	var messages []chain.Message
+	for len(sg.hashes) > 0 && sg.first+uint64(len(sg.hashes))-1 > number {
+		last := len(sg.hashes) - 1
+		if block, ok := sg.relayed[sg.hashes[last]]; ok {
+			messages = append(block.messages, messages...)
+			delete(sg.relayed, sg.hashes[last])
+		}
+		sg.hashes = sg.hashes[:last]
+	}
+	return messages
+}
+
+// record notes a message relayed for an event of a block
+func (sg *segment) record(number uint64, hash gethCommon.Hash, msg chain.Message) {
+	sg.mutex.Lock()
+	defer sg.mutex.Unlock()
+
+	block, ok := sg.relayed[hash]
+	if !ok {
+		block = &relayedBlock{number: number}
+		sg.relayed[hash] = block
+	}
+	block.messages = append(block.messages, msg)
+}
+
+// followHead adds a new head to the segment. If the head doesn't extend the segment, its
+// ancestors are fetched back to the last block it shares with the segment, and the blocks
+// above that block are rolled back as orphaned.
+func (li *Listener) followHead(ctx context.Context, head *gethTypes.Header) error {
+	number := head.Number.Uint64()
+	top, followed := li.segment.top()
+	// heads following a gap wider than the segment restart it, as too many ancestors would be
+	// fetched to find the fork
+	if !followed || number == 0 || number > top+uint64(li.segment.size) {
+		li.segment.add(number, head.Hash())
+		return nil
+	}
+	if hash, ok := li.segment.hashAt(number); ok && hash == head.Hash() {
+		return nil
+	}
+
+	// ancestors of the head which aren't in the segment, from the head down
+	ancestors := []*gethTypes.Header{head}
+	parent := head.ParentHash
+	fork := number - 1
+	for {
+		if hash, ok := li.segment.hashAt(fork); ok && hash == parent {
+			break
+		}
+		if oldest := li.segment.oldest(); fork < oldest || fork == 0 {
+			li.log.WithFields(logrus.Fields{
+				"blockNumber": number,
+				"oldest":      oldest,
+			}).Error("ALERT: Reorg replaced every block followed by the listener")
+			break
+		}
+
+		header, err := li.conn.Client().HeaderByHash(ctx, parent)
+		if err != nil {
+			return fmt.Errorf("fetch ancestor %s of head %d: %w", parent.Hex(), number, err)
+		}
+		ancestors = append(ancestors, header)
+		parent = header.ParentHash
+		fork--
+	}
+
+	if top > fork {
+		li.reorged(fork, li.segment.rollback(fork))
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		li.segment.add(ancestors[i].Number.Uint64(), ancestors[i].Hash())
+	}
+	return nil
+}
+
+// reorged invalidates the messages relayed for events of orphaned blocks, and rewinds the
+// listener to the fork so that the events of the replacing blocks are relayed once they are
+// confirmed
+func (li *Listener) reorged(fork uint64, orphaned []chain.Message) {
+	metrics.Reorgs.WithLabelValues(Name).Inc()
+	log := li.log.WithFields(logrus.Fields{
+		"fork":     fork,
+		"orphaned": len(orphaned),
+	})
+	log.Warn("Detected reorg, rolling back to the fork")
+	li.dropOrphanedHolds(fork)
+
+	if len(orphaned) > 0 {
+		log.Error("ALERT: Reorg orphaned the events of relayed messages, which are invalidated")
+	}
+	for i := range orphaned {
+		metrics.OrphanedMessages.WithLabelValues(Name).Inc()
+		if li.invalidated == nil {
+			continue
+		}
+		err := li.invalidated.Invalidate(Name, &orphaned[i], fmt.Sprintf("event orphaned by a reorg above block %d", fork))
+		if err != nil {
+			log.WithError(err).Error("Failed to invalidate orphaned message")
+		}
+	}
+
+	if li.depth > 0 && li.confirmed > fork {
+		li.confirmed = fork
+		li.saveCursor(fork)
+	}
+}
+
+// relayConfirmed relays the events of the blocks which reached the confirmation depth below
+// a head, advancing up to the first block whose hash doesn't match the segment, which the
+// node reported from another fork. Returns an error if a message was persisted instead of
+// being queued.
+func (li *Listener) relayConfirmed(ctx context.Context, head uint64) error {
+	if head < li.depth {
+		return nil
+	}
+	target := head - li.depth
+
+	for start := li.confirmed + 1; start <= target; start += repairBatchSize {
+		end := start + repairBatchSize - 1
+		if end > target {
+			end = target
+		}
+
+		traced, events, err := li.fetchEvents(ctx, start, end)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			li.log.WithError(err).WithField("from", start).Warn("Failed to fetch events of confirmed blocks, retrying with the next head")
+			return nil
+		}
+
+		for _, event := range events {
+			if hash, ok := li.segment.hashAt(event.BlockNumber); ok && hash != event.BlockHash {
+				li.log.WithFields(logrus.Fields{
+					"blockNumber": event.BlockNumber,
+					"blockHash":   event.BlockHash.Hex(),
+				}).Warn("Node served events of another fork, waiting for the next head")
+				li.advanceConfirmed(event.BlockNumber - 1)
+				return nil
+			}
+			err := li.handleEvent(traced, event, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		li.advanceConfirmed(end)
+	}
+
+	return nil
+}
+
+// advanceConfirmed marks the blocks up to a height as processed, and records it as the last
+// handled block
+func (li *Listener) advanceConfirmed(number uint64) {
+	for confirmed := li.confirmed + 1; confirmed <= number; confirmed++ {
+		li.markProcessed(confirmed)
+	}
+	if number > li.confirmed {
+		li.confirmed = number
+		li.saveCursor(number)
+	}
+}
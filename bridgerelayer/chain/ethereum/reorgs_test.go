@@ -0,0 +1,207 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type invalidatedMessages struct {
+	mutex    sync.Mutex
+	messages []chain.Message
+}
+
+func (im *invalidatedMessages) Invalidate(_ string, msg *chain.Message, _ string) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+	im.messages = append(im.messages, *msg)
+	return nil
+}
+
+func (im *invalidatedMessages) count() int {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+	return len(im.messages)
+}
+
+func TestFinalityConfig_Depth(t *testing.T) {
+	config := &FinalityConfig{Depths: map[string]uint64{"5": 20, "15": 3}}
+	assert.Equal(t, uint64(12), config.depth(big.NewInt(1)))
+	assert.Equal(t, uint64(20), config.depth(big.NewInt(5)))
+	assert.Equal(t, uint64(3), config.depth(big.NewInt(15)))
+	assert.Equal(t, uint64(0), config.depth(big.NewInt(1337)))
+}
+
+func TestSegment(t *testing.T) {
+	sg := newSegment(0)
+	sg.size = 3
+
+	for number := uint64(1); number <= 4; number++ {
+		sg.add(number, common.Hash{byte(number)})
+	}
+	_, ok := sg.hashAt(1)
+	assert.False(t, ok)
+	hash, ok := sg.hashAt(4)
+	assert.True(t, ok)
+	assert.Equal(t, common.Hash{4}, hash)
+
+	sg.record(3, common.Hash{3}, chain.Message{Sequence: 1})
+	sg.record(4, common.Hash{4}, chain.Message{Sequence: 2})
+	// messages of blocks which were never followed are kept until they leave the segment
+	sg.record(3, common.Hash{9}, chain.Message{Sequence: 3})
+
+	orphaned := sg.rollback(2)
+	require.Len(t, orphaned, 2)
+	assert.Equal(t, uint64(1), orphaned[0].Sequence)
+	top, ok := sg.top()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), top)
+
+	// blocks added at a lower height replace those above it
+	sg.add(3, common.Hash{5})
+	sg.add(4, common.Hash{6})
+	sg.add(3, common.Hash{7})
+	top, _ = sg.top()
+	assert.Equal(t, uint64(3), top)
+
+	sg.add(6, common.Hash{8})
+	assert.Len(t, sg.relayed, 0)
+}
+
+func TestListener_Confirmations(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+	contract := Contract{Name: "eth", Address: common.Address{1}, ABI: &contractABI}
+	transfer := func(amount byte) types.Log {
+		return types.Log{
+			Address: contract.Address,
+			Topics:  []common.Hash{contractABI.Events[watchedEvent].ID},
+			Data:    []byte{amount},
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	messages := make(chan chain.Message, 2)
+	blocks := &processedBlocks{}
+	cursors := store.NewCursors(store.NewMemoryDB())
+	invalidated := &invalidatedMessages{}
+	finality := &FinalityConfig{Depths: map[string]uint64{"15": 2}}
+
+	listener, err := NewListener(conn, messages, nil, blocks, chain.NewClockMonitor(&chain.ClockConfig{}, log), nil, nil, nil, cursors, nil, nil, invalidated, finality, []Contract{contract}, log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.headSubs) == 1
+	}, time.Second, time.Millisecond)
+
+	// events are relayed once their block has the confirmation depth
+	client.AddBlock()
+	client.AddBlock(transfer(1))
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, messages, 0)
+
+	// a reorg replacing the block before it was confirmed only relays the replacing event
+	client.Reorg(1)
+	client.AddBlock(transfer(2))
+	client.AddBlock()
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, messages, 0)
+
+	client.AddBlock()
+	select {
+	case msg := <-messages:
+		assert.Equal(t, uint64(2), msg.Payload.(Message).VerificationInput.AsBasic.BlockNumber)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	assert.Eventually(t, func() bool {
+		number, _, err := cursors.LoadCursor(Name)
+		return err == nil && number == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []uint64{1, 2}, blocks.processed())
+
+	// a reorg deeper than the depth invalidates the relayed message, and rewinds the cursor
+	client.Reorg(1)
+	client.AddBlock()
+	assert.Eventually(t, func() bool {
+		return invalidated.count() == 1
+	}, time.Second, time.Millisecond)
+	number, _, err := cursors.LoadCursor(Name)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), number)
+	assert.Len(t, messages, 0)
+}
+
+func TestListener_ReorgWithoutDepth(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testAppABI))
+	require.NoError(t, err)
+	contract := Contract{Name: "eth", Address: common.Address{1}, ABI: &contractABI}
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	messages := make(chan chain.Message, 2)
+	invalidated := &invalidatedMessages{}
+
+	listener, err := NewListener(conn, messages, nil, &processedBlocks{}, chain.NewClockMonitor(&chain.ClockConfig{}, log), nil, nil, nil, nil, nil, nil, invalidated, &FinalityConfig{}, []Contract{contract}, log)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.headSubs) == 1
+	}, time.Second, time.Millisecond)
+
+	// events are relayed at once, and invalidated if a reorg replaces their block
+	client.AddBlock()
+	client.AddBlock(types.Log{
+		Address: contract.Address,
+		Topics:  []common.Hash{contractABI.Events[watchedEvent].ID},
+	})
+	<-messages
+
+	client.Reorg(1)
+	client.AddBlock()
+	assert.Eventually(t, func() bool {
+		return invalidated.count() == 1
+	}, time.Second, time.Millisecond)
+}
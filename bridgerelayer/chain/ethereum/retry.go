@@ -0,0 +1,207 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type RetryConfig struct {
+	// Gas-free checks made of a reverted delivery before it is skipped. Reverted
+	// deliveries are not retried if zero.
+	Attempts int `mapstructure:"attempts"`
+	// Seconds between checks
+	Interval uint64 `mapstructure:"interval"`
+	// Substrings of the revert reasons, matched regardless of case, which indicate a
+	// transient condition, "not yet imported" by default
+	Transient []string `mapstructure:"transient"`
+}
+
+const defaultRetryInterval = 30
+
+var defaultTransientReasons = []string{"not yet imported"}
+
+// revertPrefixes start the errors of reverted calls, as reported by geth and by ganache
+var revertPrefixes = []string{"execution reverted", "VM Exception while processing transaction: revert"}
+
+// Outcomes of the checks of reverted deliveries
+const (
+	retryOutcomeRetried = "retried"
+	retryOutcomeWaiting = "waiting"
+	retryOutcomeSkipped = "skipped"
+)
+
+// RetryPolicy decides whether a reverted delivery is worth retrying from the revert
+// reason of a call of its app, which costs no gas. Only reasons which indicate a
+// transient condition, such as a commitment which is not imported yet, are waited out.
+type RetryPolicy struct {
+	attempts  int
+	interval  time.Duration
+	transient []string
+}
+
+func NewRetryPolicy(config *RetryConfig) *RetryPolicy {
+	rp := &RetryPolicy{
+		attempts:  config.Attempts,
+		interval:  time.Duration(config.Interval) * time.Second,
+		transient: config.Transient,
+	}
+	if rp.interval == 0 {
+		rp.interval = defaultRetryInterval * time.Second
+	}
+	if len(rp.transient) == 0 {
+		rp.transient = defaultTransientReasons
+	}
+	return rp
+}
+
+// Enabled returns whether reverted deliveries are retried
+func (rp *RetryPolicy) Enabled() bool {
+	return rp.attempts > 0
+}
+
+// Transient returns whether a revert reason indicates a condition which clears by itself
+func (rp *RetryPolicy) Transient(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, transient := range rp.transient {
+		if strings.Contains(reason, strings.ToLower(transient)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry checks a reverted delivery until a gas-free call of its app no longer reverts,
+// and then submits it again. The message is skipped once the call reverts for a reason
+// which isn't transient, or once the attempts are used up. Checks made for earlier
+// reverts of the same message count toward the attempts. Without retries, the message is
+// skipped at once.
+func (wr *Writer) retry(ctx context.Context, msg chain.Message, checks int, reverted *big.Int) {
+	log := wr.log.WithField("contractAddress", common.Address(msg.AppID).Hex())
+
+	// the reason of the reverted attempt is recovered by replaying it on its parent block
+	prior, err := wr.revertReason(ctx, &msg, new(big.Int).Sub(reverted, big.NewInt(1)))
+	if err != nil {
+		log.WithError(err).Debug("Failed to replay reverted delivery")
+	}
+
+	if !wr.retries.Enabled() {
+		if prior == "" {
+			prior = "unknown"
+		}
+		// the reverted submission was the only attempt
+		wr.skip(ctx, &msg, prior, 1)
+		return
+	}
+
+	for ; checks < wr.retries.attempts; checks++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wr.retries.interval):
+		}
+
+		reason, err := wr.revertReason(ctx, &msg, nil)
+		if err != nil {
+			log.WithError(err).Warn("Failed to check reverted delivery")
+			continue
+		}
+
+		if reason == "" {
+			metrics.RevertRetries.WithLabelValues(Name, retryOutcomeRetried).Inc()
+			log.WithField("priorReason", prior).Info("Retrying reverted delivery")
+			err = wr.deliver(ctx, &msg, false, checks+1)
+			if err != nil {
+				log.WithError(err).Error("Failed to retry reverted delivery")
+			}
+			return
+		}
+
+		if reason != prior {
+			log.WithFields(logrus.Fields{
+				"priorReason": prior,
+				"reason":      reason,
+			}).Info("Revert reason of delivery changed")
+			prior = reason
+		}
+
+		if !wr.retries.Transient(reason) {
+			break
+		}
+
+		metrics.RevertRetries.WithLabelValues(Name, retryOutcomeWaiting).Inc()
+		log.WithField("reason", reason).Debug("Delivery still reverts for a transient reason")
+	}
+
+	wr.skip(ctx, &msg, prior, checks)
+}
+
+// skip gives up a delivery which keeps reverting after the given checks, and moves it to
+// the dead-letter queue or records it in the skip list
+func (wr *Writer) skip(ctx context.Context, msg *chain.Message, reason string, checks int) {
+	metrics.RevertRetries.WithLabelValues(Name, retryOutcomeSkipped).Inc()
+
+	log := wr.log.WithFields(logrus.Fields{
+		"contractAddress": common.Address(msg.AppID).Hex(),
+		"messageID":       msg.ID,
+		"reason":          reason,
+	})
+	log.Error("Skipped delivery which keeps reverting")
+
+	if wr.deadLetters != nil {
+		chain.DeadLetter(ctx, wr.deadLetters, Name, msg, fmt.Errorf("reverted: %s", reason), checks, wr.log)
+		return
+	}
+
+	if wr.skipped == nil || msg.ID == "" {
+		return
+	}
+	err := wr.skipped.Skip(msg, "reverted: "+reason)
+	if err != nil {
+		log.WithError(err).Error("Failed to record skipped message")
+	}
+}
+
+// revertReason calls the app of a message as delivering it directly from the relayer
+// account would, at a block or at the latest block if nil. The reason is empty if the
+// call succeeds, and "unknown" if it reverts without one.
+func (wr *Writer) revertReason(ctx context.Context, msg *chain.Message, number *big.Int) (string, error) {
+	txData, err := wr.abi.Pack("submit", msg.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	to := common.Address(msg.AppID)
+	_, err = wr.conn.Client().CallContract(ctx, geth.CallMsg{
+		From: wr.conn.Keypair().CommonAddress(),
+		To:   &to,
+		Gas:  gasLimit,
+		Data: txData,
+	}, number)
+	if err == nil {
+		return "", nil
+	}
+
+	for _, prefix := range revertPrefixes {
+		if strings.HasPrefix(err.Error(), prefix) {
+			reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(err.Error(), prefix), ":"))
+			if reason == "" {
+				reason = "unknown"
+			}
+			return reason, nil
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+type skipList struct {
+	mutex   sync.Mutex
+	reasons map[string]string
+}
+
+func (sl *skipList) Skip(msg *chain.Message, reason string) error {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	sl.reasons[msg.ID] = reason
+	return nil
+}
+
+// DeadLetter records messages moved to the dead-letter queue among the skipped messages
+func (sl *skipList) DeadLetter(target string, msg *chain.Message, err error, attempts int) error {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	sl.reasons[msg.ID] = fmt.Sprintf("dead-lettered after %d attempts: %v", attempts, err)
+	return nil
+}
+
+func (sl *skipList) reason(id string) string {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	return sl.reasons[id]
+}
+
+func newRetryWriter(t *testing.T, attempts int) (*Writer, *MockClient, *skipList) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient(big.NewInt(15))
+	conn := NewMockConnection(secp256k1.Alice(), client)
+	skipped := &skipList{reasons: make(map[string]string)}
+
+	wr, err := NewWriter(&Config{Retry: RetryConfig{Attempts: attempts}}, conn, nil, nil, nil, skipped, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	wr.retries.interval = time.Millisecond
+	// deliveries are packed with the message alone
+	wr.abi = mustParseABI(submitMessageABI)
+	return wr, client, skipped
+}
+
+const submitMessageABI = `[{
+	"inputs": [{ "internalType": "bytes", "name": "message", "type": "bytes" }],
+	"name": "submit",
+	"outputs": [],
+	"stateMutability": "nonpayable",
+	"type": "function"
+}]`
+
+func TestRetryPolicy(t *testing.T) {
+	policy := NewRetryPolicy(&RetryConfig{})
+	assert.False(t, policy.Enabled())
+	assert.True(t, policy.Transient("Commitment not yet imported"))
+	assert.False(t, policy.Transient("invalid signature"))
+
+	policy = NewRetryPolicy(&RetryConfig{Attempts: 1, Transient: []string{"stale"}})
+	assert.True(t, policy.Enabled())
+	assert.True(t, policy.Transient("stale nonce"))
+	assert.False(t, policy.Transient("not yet imported"))
+}
+
+func TestRetry_Persistent(t *testing.T) {
+	wr, client, skipped := newRetryWriter(t, 5)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: invalid signature"))
+
+	// reverts which retrying can't clear are skipped at the first check
+	wr.retry(context.Background(), chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+	assert.Equal(t, "reverted: invalid signature", skipped.reason("a"))
+	assert.Empty(t, client.Sent())
+}
+
+func TestRetry_Transient(t *testing.T) {
+	wr, client, skipped := newRetryWriter(t, 10000)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: commitment not yet imported"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		wr.retry(ctx, chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+		close(done)
+	}()
+
+	// transient reverts are waited out without submitting anything
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, client.Sent())
+
+	client.SetCallError(app, nil)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for retry")
+	}
+	assert.Len(t, client.Sent(), 1)
+	assert.Empty(t, skipped.reason("a"))
+}
+
+func TestRetry_Exhausted(t *testing.T) {
+	wr, client, skipped := newRetryWriter(t, 3)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: commitment not yet imported"))
+
+	wr.retry(context.Background(), chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+	assert.Equal(t, "reverted: commitment not yet imported", skipped.reason("a"))
+	assert.Empty(t, client.Sent())
+}
+
+func TestRetry_DeadLettered(t *testing.T) {
+	wr, client, skipped := newRetryWriter(t, 3)
+	wr.DivertFailures(skipped)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: commitment not yet imported"))
+
+	// deliveries which keep reverting are moved to the dead-letter queue instead of skipped
+	wr.retry(context.Background(), chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+	assert.Equal(t, "dead-lettered after 3 attempts: reverted: commitment not yet imported", skipped.reason("a"))
+	assert.Empty(t, client.Sent())
+}
+
+func TestRetry_Halted(t *testing.T) {
+	wr, client, _ := newRetryWriter(t, 10000)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: commitment not yet imported"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		wr.retry(ctx, chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+		close(done)
+	}()
+
+	// a retry which is due while the writer is halted waits for it to be resumed
+	wr.Gate().Halt("emergency stop")
+	client.SetCallError(app, nil)
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, client.Sent())
+
+	wr.Gate().Release("emergency stop")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for retry")
+	}
+	assert.Len(t, client.Sent(), 1)
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	wr, client, skipped := newRetryWriter(t, 0)
+	wr.DivertFailures(skipped)
+	app := common.Address{1}
+	client.SetCallError(app, errors.New("execution reverted: commitment not yet imported"))
+
+	// without retries, reverted deliveries are moved to the dead-letter queue at once
+	wr.retry(context.Background(), chain.Message{ID: "a", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+	assert.Equal(t, "dead-lettered after 1 attempts: reverted: commitment not yet imported", skipped.reason("a"))
+
+	// with an unknown reason if the reverted call can't be replayed
+	client.SetCallError(app, nil)
+	wr.retry(context.Background(), chain.Message{ID: "b", AppID: app, Payload: []byte{1}}, 0, big.NewInt(1))
+	assert.Equal(t, "dead-lettered after 1 attempts: reverted: unknown", skipped.reason("b"))
+	assert.Empty(t, client.Sent())
+}
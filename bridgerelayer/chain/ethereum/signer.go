@@ -0,0 +1,377 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// RemoteSignerConfig enables signing the transactions of the relayer account with an
+// external signer, such as Clef or web3signer, which holds its key instead of the relayer
+type RemoteSignerConfig struct {
+	// URL of the JSON-RPC endpoint of the signer
+	Endpoint string `mapstructure:"endpoint"`
+	// Address of the relayer account, whose key is held by the signer
+	Address string `mapstructure:"address"`
+	// Method signing a transaction, account_signTransaction for Clef by default, or
+	// eth_signTransaction for web3signer and the accounts of a node
+	Method string `mapstructure:"method"`
+	// Headers sent with each request, for example to authenticate with the signer
+	Headers map[string]string `mapstructure:"headers"`
+	// Seconds within which the signer must answer, including any manual approval.
+	// Defaults to 30.
+	Timeout uint64 `mapstructure:"timeout"`
+	// Highest gas price in wei, or max fee per gas of dynamic fee transactions, which the
+	// relayer requests a signature for. Unlimited if empty.
+	MaxGasPrice string `mapstructure:"max-gas-price"`
+	// Highest gas limit which the relayer requests a signature for. Unlimited if zero.
+	MaxGas uint64 `mapstructure:"max-gas"`
+	// Further policies vetting each transaction before it is signed, by the names they
+	// were registered under with RegisterSigningPolicy
+	Policies []string `mapstructure:"policies"`
+}
+
+const (
+	defaultSignMethod    = "account_signTransaction"
+	defaultSignerTimeout = 30
+)
+
+// SignRequest is a transaction of the relayer account to be signed, calling a contract
+// without value
+type SignRequest struct {
+	From    common.Address
+	To      common.Address
+	Nonce   uint64
+	Gas     uint64
+	ChainID *big.Int
+	// Gas price of legacy transactions, max fee per gas of dynamic fee transactions
+	GasPrice *big.Int
+	// Max priority fee per gas of dynamic fee transactions, nil for legacy transactions
+	GasTipCap *big.Int
+	Data      []byte
+}
+
+// SigningPolicy vets a transaction before the remote signer is asked to sign it,
+// returning an error to refuse it
+type SigningPolicy func(request *SignRequest) error
+
+var signingPolicies = map[string]SigningPolicy{}
+
+// RegisterSigningPolicy makes a signing policy available to remote signer configs
+func RegisterSigningPolicy(name string, policy SigningPolicy) {
+	signingPolicies[name] = policy
+}
+
+// RemoteSigner signs the transactions of the relayer account over the JSON-RPC API of an
+// external signer. Each transaction is vetted before it is sent to the signer: it must
+// call one of the app contracts, within the gas and price limits, and pass the configured
+// policies. The signed transaction is decoded and checked against the request, so that a
+// compromised or misconfigured signer can't make the relayer broadcast another one.
+type RemoteSigner struct {
+	config  *RemoteSignerConfig
+	address common.Address
+	method  string
+	timeout time.Duration
+	// contracts which transactions may call
+	targets     map[common.Address]bool
+	maxGasPrice *big.Int
+	policies    []SigningPolicy
+	// nil until connected, guarded by the mutex
+	client *rpc.Client
+	mutex  sync.Mutex
+	// nil if the signer is dialed by default
+	dialer *chain.Dialer
+	log    *logrus.Entry
+}
+
+func NewRemoteSigner(config *RemoteSignerConfig, targets []common.Address, log *logrus.Entry) (*RemoteSigner, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("missing endpoint of remote signer")
+	}
+	if !common.IsHexAddress(config.Address) {
+		return nil, fmt.Errorf("invalid address of remote signer account: %s", config.Address)
+	}
+
+	method := config.Method
+	if method == "" {
+		method = defaultSignMethod
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultSignerTimeout
+	}
+
+	var maxGasPrice *big.Int
+	if config.MaxGasPrice != "" {
+		var ok bool
+		maxGasPrice, ok = new(big.Int).SetString(config.MaxGasPrice, 10)
+		if !ok || maxGasPrice.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid max gas price of remote signer: %s", config.MaxGasPrice)
+		}
+	}
+
+	var policies []SigningPolicy
+	for _, name := range config.Policies {
+		policy, ok := signingPolicies[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing policy: %s", name)
+		}
+		policies = append(policies, policy)
+	}
+
+	allowed := make(map[common.Address]bool, len(targets))
+	for _, target := range targets {
+		allowed[target] = true
+	}
+
+	return &RemoteSigner{
+		config:      config,
+		address:     common.HexToAddress(config.Address),
+		method:      method,
+		timeout:     time.Duration(timeout) * time.Second,
+		targets:     allowed,
+		maxGasPrice: maxGasPrice,
+		policies:    policies,
+		log:         log,
+	}, nil
+}
+
+// DialThrough dials the signer through a dialer, by default if nil. It must be set before
+// connecting.
+func (rs *RemoteSigner) DialThrough(dialer *chain.Dialer) {
+	rs.dialer = dialer
+}
+
+// Connect dials the signer, which is otherwise dialed by the first signing request
+func (rs *RemoteSigner) Connect(ctx context.Context) error {
+	_, err := rs.connection(ctx)
+	return err
+}
+
+func (rs *RemoteSigner) connection(ctx context.Context) (*rpc.Client, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.client != nil {
+		return rs.client, nil
+	}
+	client, err := chain.DialRPC(ctx, rs.config.Endpoint, rs.dialer)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range rs.config.Headers {
+		client.SetHeader(name, value)
+	}
+	rs.client = client
+	return client, nil
+}
+
+func (rs *RemoteSigner) Close() {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.client != nil {
+		rs.client.Close()
+		rs.client = nil
+	}
+}
+
+// Address returns the relayer account whose transactions are signed
+func (rs *RemoteSigner) Address() common.Address {
+	return rs.address
+}
+
+// signArgs are the arguments of a transaction signing request, in the format shared by
+// Clef and eth_signTransaction
+type signArgs struct {
+	From                 common.Address `json:"from"`
+	To                   common.Address `json:"to"`
+	Gas                  hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big   `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big   `json:"value"`
+	Nonce                hexutil.Uint64 `json:"nonce"`
+	Data                 hexutil.Bytes  `json:"data"`
+	ChainID              *hexutil.Big   `json:"chainId"`
+}
+
+// signResult is the result of Clef and geth, while web3signer returns the raw transaction
+type signResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// Sign vets a transaction and has the signer sign it. Transactions refused by a policy or
+// by the signer, and signed transactions which don't match the request, fail permanently,
+// while those which couldn't reach the signer may be retried.
+func (rs *RemoteSigner) Sign(ctx context.Context, request *SignRequest) (signedTransaction, error) {
+	err := rs.vet(request)
+	if err != nil {
+		return nil, chain.Permanent(fmt.Errorf("refused by signing policy: %w", err))
+	}
+
+	args := signArgs{
+		From:    request.From,
+		To:      request.To,
+		Gas:     hexutil.Uint64(request.Gas),
+		Value:   (*hexutil.Big)(new(big.Int)),
+		Nonce:   hexutil.Uint64(request.Nonce),
+		Data:    request.Data,
+		ChainID: (*hexutil.Big)(request.ChainID),
+	}
+	if request.GasTipCap == nil {
+		args.GasPrice = (*hexutil.Big)(request.GasPrice)
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(request.GasPrice)
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(request.GasTipCap)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rs.timeout)
+	defer cancel()
+
+	client, err := rs.connection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+
+	var result json.RawMessage
+	err = client.CallContext(ctx, &result, rs.method, args)
+	if _, ok := err.(rpc.Error); ok {
+		return nil, chain.Permanent(fmt.Errorf("remote signer refused transaction: %w", err))
+	} else if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+
+	raw, err := decodeSignResult(result)
+	if err != nil {
+		return nil, chain.Permanent(fmt.Errorf("invalid response of remote signer: %w", err))
+	}
+
+	tx, err := rs.verify(request, raw)
+	if err != nil {
+		rs.log.WithError(err).WithFields(logrus.Fields{
+			"nonce":    request.Nonce,
+			"contract": request.To.Hex(),
+		}).Error("ALERT: remote signer returned a transaction which doesn't match the request")
+		return nil, chain.Permanent(fmt.Errorf("signed transaction doesn't match the request: %w", err))
+	}
+	return tx, nil
+}
+
+// vet applies the limits and policies of the signer to a transaction
+func (rs *RemoteSigner) vet(request *SignRequest) error {
+	if request.From != rs.address {
+		return fmt.Errorf("sender %s is not the account of the signer", request.From.Hex())
+	}
+	if !rs.targets[request.To] {
+		return fmt.Errorf("%s is not an app contract", request.To.Hex())
+	}
+	if rs.config.MaxGas > 0 && request.Gas > rs.config.MaxGas {
+		return fmt.Errorf("gas limit %d exceeds %d", request.Gas, rs.config.MaxGas)
+	}
+	if rs.maxGasPrice != nil && request.GasPrice.Cmp(rs.maxGasPrice) > 0 {
+		return fmt.Errorf("gas price %s exceeds %s", request.GasPrice, rs.maxGasPrice)
+	}
+	for _, policy := range rs.policies {
+		err := policy(request)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeSignResult returns the raw transaction of a result, either a hex string or an
+// object holding it
+func decodeSignResult(result json.RawMessage) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err == nil {
+		return raw, nil
+	}
+
+	var object signResult
+	err := json.Unmarshal(result, &object)
+	if err != nil {
+		return nil, err
+	}
+	if len(object.Raw) == 0 {
+		return nil, fmt.Errorf("result has no raw transaction")
+	}
+	return object.Raw, nil
+}
+
+// verify decodes a signed transaction and checks that it is the requested one, sent from
+// the relayer account
+func (rs *RemoteSigner) verify(request *SignRequest, raw []byte) (signedTransaction, error) {
+	if request.GasTipCap != nil {
+		tx, sender, err := decodeDynamicFeeTx(raw)
+		if err != nil {
+			return nil, err
+		}
+		if tx.chainID.Cmp(request.ChainID) != 0 {
+			return nil, fmt.Errorf("chain ID %s instead of %s", tx.chainID, request.ChainID)
+		}
+		if tx.gasTipCap.Cmp(request.GasTipCap) != 0 {
+			return nil, fmt.Errorf("max priority fee %s instead of %s", tx.gasTipCap, request.GasTipCap)
+		}
+		return tx, compareSigned(request, tx, sender)
+	}
+
+	var tx types.Transaction
+	err := rlp.DecodeBytes(raw, &tx)
+	if err != nil {
+		return nil, fmt.Errorf("not a legacy transaction: %w", err)
+	}
+
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		if tx.ChainId().Cmp(request.ChainID) != 0 {
+			return nil, fmt.Errorf("chain ID %s instead of %s", tx.ChainId(), request.ChainID)
+		}
+		signer = types.NewEIP155Signer(request.ChainID)
+	}
+	sender, err := types.Sender(signer, &tx)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Value().Sign() != 0 {
+		return nil, fmt.Errorf("transaction carries a value of %s", tx.Value())
+	}
+	return &tx, compareSigned(request, &tx, sender)
+}
+
+// compareSigned checks the fields shared by legacy and dynamic fee transactions
+func compareSigned(request *SignRequest, tx signedTransaction, sender common.Address) error {
+	switch {
+	case sender != request.From:
+		return fmt.Errorf("signed by %s instead of %s", sender.Hex(), request.From.Hex())
+	case tx.Nonce() != request.Nonce:
+		return fmt.Errorf("nonce %d instead of %d", tx.Nonce(), request.Nonce)
+	case tx.To() == nil || *tx.To() != request.To:
+		return fmt.Errorf("recipient differs from %s", request.To.Hex())
+	case tx.Gas() != request.Gas:
+		return fmt.Errorf("gas limit %d instead of %d", tx.Gas(), request.Gas)
+	case tx.GasPrice().Cmp(request.GasPrice) != 0:
+		return fmt.Errorf("gas price %s instead of %s", tx.GasPrice(), request.GasPrice)
+	case !bytes.Equal(tx.Data(), request.Data):
+		return fmt.Errorf("call data differs from the request")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+// testSigner serves account_signTransaction like Clef, signing with a keypair after
+// applying an optional tampering to the requested transaction
+type testSigner struct {
+	kp       *secp256k1.Keypair
+	requests int
+	tamper   func(args *signArgs)
+	refuse   bool
+}
+
+func (ts *testSigner) SignTransaction(args signArgs) (*signResult, error) {
+	ts.requests++
+	if ts.refuse {
+		return nil, errors.New("request denied")
+	}
+	if ts.tamper != nil {
+		ts.tamper(&args)
+	}
+
+	if args.MaxPriorityFeePerGas != nil {
+		tx, err := signDynamicFeeTx(ts.kp, args.ChainID.ToInt(), uint64(args.Nonce), args.To, uint64(args.Gas), args.MaxPriorityFeePerGas.ToInt(), args.MaxFeePerGas.ToInt(), args.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &signResult{Raw: tx.Raw()}, nil
+	}
+
+	tx := types.NewTransaction(uint64(args.Nonce), args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), args.Data)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(args.ChainID.ToInt()), ts.kp.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return &signResult{Raw: raw}, nil
+}
+
+func newTestRemoteSigner(t *testing.T, config *RemoteSignerConfig, contract common.Address) (*RemoteSigner, *testSigner, *http.Header, func()) {
+	service := &testSigner{kp: secp256k1.Alice()}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("account", service))
+
+	var headers http.Header
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		server.ServeHTTP(w, r)
+	}))
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	config.Endpoint = endpoint.URL
+	config.Address = secp256k1.Alice().CommonAddress().Hex()
+	signer, err := NewRemoteSigner(config, []common.Address{contract}, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	require.NoError(t, signer.Connect(context.Background()))
+
+	return signer, service, &headers, func() {
+		signer.Close()
+		endpoint.Close()
+		server.Stop()
+	}
+}
+
+func TestRemoteSigner_Sign(t *testing.T) {
+	contract := common.Address{1}
+	signer, service, headers, stop := newTestRemoteSigner(t, &RemoteSignerConfig{
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}, contract)
+	defer stop()
+
+	request := &SignRequest{
+		From:     secp256k1.Alice().CommonAddress(),
+		To:       contract,
+		Nonce:    4,
+		Gas:      gasLimit,
+		ChainID:  big.NewInt(15),
+		GasPrice: big.NewInt(100),
+		Data:     []byte{1, 2, 3},
+	}
+	tx, err := signer.Sign(context.Background(), request)
+	require.NoError(t, err)
+	legacy, ok := tx.(*types.Transaction)
+	require.True(t, ok)
+	sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(15)), legacy)
+	require.NoError(t, err)
+	assert.Equal(t, request.From, sender)
+	assert.Equal(t, uint64(4), tx.Nonce())
+	assert.Equal(t, "Bearer token", headers.Get("Authorization"))
+
+	request.GasTipCap = big.NewInt(2)
+	tx, err = signer.Sign(context.Background(), request)
+	require.NoError(t, err)
+	dynamic, ok := tx.(*dynamicFeeTx)
+	require.True(t, ok)
+	assert.Equal(t, big.NewInt(2), dynamic.GasTipCap())
+	assert.Equal(t, 2, service.requests)
+}
+
+func TestRemoteSigner_Policies(t *testing.T) {
+	contract := common.Address{1}
+	RegisterSigningPolicy("test-no-empty-calls", func(request *SignRequest) error {
+		if len(request.Data) == 0 {
+			return fmt.Errorf("empty call")
+		}
+		return nil
+	})
+	signer, service, _, stop := newTestRemoteSigner(t, &RemoteSignerConfig{
+		MaxGas:      gasLimit,
+		MaxGasPrice: "1000",
+		Policies:    []string{"test-no-empty-calls"},
+	}, contract)
+	defer stop()
+
+	valid := func() *SignRequest {
+		return &SignRequest{
+			From:     secp256k1.Alice().CommonAddress(),
+			To:       contract,
+			Gas:      gasLimit,
+			ChainID:  big.NewInt(15),
+			GasPrice: big.NewInt(1000),
+			Data:     []byte{1},
+		}
+	}
+
+	for name, change := range map[string]func(request *SignRequest){
+		"unknown contract": func(request *SignRequest) { request.To = common.Address{2} },
+		"other sender":     func(request *SignRequest) { request.From = common.Address{3} },
+		"gas limit":        func(request *SignRequest) { request.Gas = gasLimit + 1 },
+		"gas price":        func(request *SignRequest) { request.GasPrice = big.NewInt(1001) },
+		"custom policy":    func(request *SignRequest) { request.Data = nil },
+	} {
+		request := valid()
+		change(request)
+		_, err := signer.Sign(context.Background(), request)
+		assert.Error(t, err, name)
+		assert.Equal(t, chain.ErrorFatal, chain.Classify(err), name)
+	}
+	assert.Equal(t, 0, service.requests)
+
+	_, err := signer.Sign(context.Background(), valid())
+	require.NoError(t, err)
+
+	_, err = NewRemoteSigner(&RemoteSignerConfig{
+		Endpoint: "http://localhost:8550",
+		Address:  secp256k1.Alice().CommonAddress().Hex(),
+		Policies: []string{"unknown"},
+	}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestRemoteSigner_Verify(t *testing.T) {
+	contract := common.Address{1}
+	signer, service, _, stop := newTestRemoteSigner(t, &RemoteSignerConfig{}, contract)
+	defer stop()
+
+	request := func(dynamic bool) *SignRequest {
+		request := &SignRequest{
+			From:     secp256k1.Alice().CommonAddress(),
+			To:       contract,
+			Nonce:    1,
+			Gas:      gasLimit,
+			ChainID:  big.NewInt(15),
+			GasPrice: big.NewInt(100),
+			Data:     []byte{1, 2, 3},
+		}
+		if dynamic {
+			request.GasTipCap = big.NewInt(2)
+		}
+		return request
+	}
+
+	for name, tamper := range map[string]func(args *signArgs){
+		"recipient": func(args *signArgs) { args.To = common.Address{9} },
+		"nonce":     func(args *signArgs) { args.Nonce++ },
+		"data":      func(args *signArgs) { args.Data = []byte{4} },
+		"gas":       func(args *signArgs) { args.Gas++ },
+		"chain":     func(args *signArgs) { args.ChainID = (*hexutil.Big)(big.NewInt(1)) },
+	} {
+		service.tamper = tamper
+		for _, dynamic := range []bool{false, true} {
+			_, err := signer.Sign(context.Background(), request(dynamic))
+			assert.Error(t, err, name)
+			assert.Equal(t, chain.ErrorFatal, chain.Classify(err), name)
+		}
+	}
+
+	// the dynamic fee transactions of the test signer carry no value
+	service.tamper = func(args *signArgs) { args.Value = (*hexutil.Big)(big.NewInt(1)) }
+	_, err := signer.Sign(context.Background(), request(false))
+	assert.Error(t, err)
+
+	// a transaction signed by another account is refused
+	service.tamper = nil
+	service.kp = secp256k1.Bob()
+	_, err = signer.Sign(context.Background(), request(false))
+	assert.Error(t, err)
+
+	// refusals of the signer are not retried
+	service.kp = secp256k1.Alice()
+	service.refuse = true
+	_, err = signer.Sign(context.Background(), request(false))
+	assert.Error(t, err)
+	assert.Equal(t, chain.ErrorFatal, chain.Classify(err))
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	geth "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SupplyABI contains the getters through which the bank contracts expose locked balances
+const SupplyABI = `
+[
+	{
+		"inputs": [],
+		"name": "totalETH",
+		"outputs": [{ "internalType": "uint256", "name": "", "type": "uint256" }],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [{ "internalType": "address", "name": "", "type": "address" }],
+		"name": "totalTokens",
+		"outputs": [{ "internalType": "uint256", "name": "", "type": "uint256" }],
+		"stateMutability": "view",
+		"type": "function"
+	}
+]
+`
+
+var supplyABI = mustParseABI(SupplyABI)
+
+func mustParseABI(raw string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(raw))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// LockedSupply returns the balance of an asset locked in the bank contracts. The zero
+// address refers to ETH, which is locked in the ETH app. Other assets are ERC20 tokens
+// locked in the ERC20 app.
+func (ch *Chain) LockedSupply(ctx context.Context, asset [20]byte) (*big.Int, error) {
+	var method string
+	var app string
+	var args []interface{}
+
+	if asset == [20]byte{} {
+		method, app = "totalETH", "eth"
+	} else {
+		method, app = "totalTokens", "erc20"
+		args = append(args, common.Address(asset))
+	}
+
+	config, ok := ch.config.Apps[app]
+	if !ok {
+		return nil, fmt.Errorf("no %s app configured", app)
+	}
+	address := common.HexToAddress(config.Address)
+
+	input, err := supplyABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ch.conn.Client().CallContract(ctx, geth.CallMsg{To: &address, Data: input}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var supply *big.Int
+	err = supplyABI.Unpack(&supply, method, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return supply, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type FeeTuningConfig struct {
+	// Target seconds from the submission of a delivery to its confirmation. Zero disables
+	// tuning.
+	TargetDelay uint64 `mapstructure:"target-delay"`
+	// Percentage points by which the premium is raised or lowered after each delivery
+	Step uint64 `mapstructure:"step"`
+	// Highest premium, as a percentage of the suggested gas price
+	MaxPremium uint64 `mapstructure:"max-premium"`
+}
+
+const (
+	defaultTuningStep = 5
+	defaultMaxPremium = 100
+)
+
+// FeeTuner nudges the premium added to the suggested gas price toward the lowest which
+// gets deliveries included within the target delay. The premium is raised after each
+// delivery which took longer than the target, and lowered after each one which didn't,
+// so that it settles where deliveries are just in time rather than overpaying.
+type FeeTuner struct {
+	target     time.Duration
+	step       uint64
+	maxPremium uint64
+	mutex      sync.Mutex
+	premium    uint64
+	log        *logrus.Entry
+}
+
+func NewFeeTuner(config *FeeTuningConfig, log *logrus.Entry) *FeeTuner {
+	ft := &FeeTuner{
+		target:     time.Duration(config.TargetDelay) * time.Second,
+		step:       config.Step,
+		maxPremium: config.MaxPremium,
+		log:        log,
+	}
+	if ft.step == 0 {
+		ft.step = defaultTuningStep
+	}
+	if ft.maxPremium == 0 {
+		ft.maxPremium = defaultMaxPremium
+	}
+	return ft
+}
+
+// Enabled returns whether the premium is tuned
+func (ft *FeeTuner) Enabled() bool {
+	return ft.target > 0
+}
+
+// Premium returns the percentage currently added to the suggested gas price
+func (ft *FeeTuner) Premium() uint64 {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	return ft.premium
+}
+
+// Observe adjusts the premium after a delivery was confirmed with the given delay
+func (ft *FeeTuner) Observe(delay time.Duration) {
+	if !ft.Enabled() {
+		return
+	}
+
+	ft.mutex.Lock()
+	previous := ft.premium
+	if delay > ft.target {
+		ft.premium += ft.step
+		if ft.premium > ft.maxPremium {
+			ft.premium = ft.maxPremium
+		}
+	} else if ft.premium > ft.step {
+		ft.premium -= ft.step
+	} else {
+		ft.premium = 0
+	}
+	premium := ft.premium
+	ft.mutex.Unlock()
+
+	metrics.GasPremium.WithLabelValues(Name).Set(float64(premium))
+	if premium != previous {
+		ft.log.WithFields(logrus.Fields{
+			"delay":   delay.String(),
+			"premium": premium,
+		}).Debug("Tuned gas price premium")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestFeeTuner(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	tuner := NewFeeTuner(&FeeTuningConfig{TargetDelay: 60, Step: 10, MaxPremium: 25}, logrus.NewEntry(logger))
+	assert.True(t, tuner.Enabled())
+
+	// slow deliveries raise the premium up to the limit
+	for _, expected := range []uint64{10, 20, 25} {
+		tuner.Observe(2 * time.Minute)
+		assert.Equal(t, expected, tuner.Premium())
+	}
+
+	// fast deliveries lower it again
+	for _, expected := range []uint64{15, 5, 0, 0} {
+		tuner.Observe(30 * time.Second)
+		assert.Equal(t, expected, tuner.Premium())
+	}
+
+	disabled := NewFeeTuner(&FeeTuningConfig{}, logrus.NewEntry(logger))
+	disabled.Observe(time.Hour)
+	assert.False(t, disabled.Enabled())
+	assert.Equal(t, uint64(0), disabled.Premium())
+}
+
+func TestRecordGasPrice(t *testing.T) {
+	receipt := chain.Receipt{MaxFeePerGas: "30"}
+	recordGasPrice(&receipt, big.NewInt(2000), 100)
+	assert.Equal(t, "20", receipt.GasPrice)
+
+	// the gas price is unknown if no gas used was reported
+	receipt = chain.Receipt{MaxFeePerGas: "30"}
+	recordGasPrice(&receipt, big.NewInt(2000), 0)
+	assert.Empty(t, receipt.GasPrice)
+}
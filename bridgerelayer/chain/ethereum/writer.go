@@ -5,8 +5,11 @@ package ethereum
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -16,15 +19,65 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 )
 
 type Writer struct {
-	conn     *Connection
+	config   *Config
+	conn     Connection
 	abi      abi.ABI
 	messages <-chan chain.Message
-	log      *logrus.Entry
+	receipts chain.ReceiptLog
+	pricer   chain.Pricer
+	skipped  chain.SkipList
+	// nil unless failed deliveries are moved to a dead-letter queue
+	deadLetters chain.DeadLetterQueue
+	sponsor     *secp256k1.Keypair
+	forwarders  map[common.Address]*Forwarder
+	bundler     *Bundler
+	gate        *chain.Gate
+	dispatcher  *chain.Dispatcher
+	// accumulate the messages of apps whose deliveries are batched
+	batchers map[[20]byte]*Batcher
+	// names of the apps by their address, to label metrics
+	apps       map[[20]byte]string
+	tuner      *FeeTuner
+	throughput *chain.ThroughputTuner
+	retries    *RetryPolicy
+	// transactions awaiting their confirmation depth, tracked if enabled
+	pending *PendingSet
+	// fees of dynamic fee transactions, which are signed for chainID
+	fees    *FeeOracle
+	chainID *big.Int
+	// retries submissions which failed with a transient error
+	backoff *chain.Backoff
+	// assigns the nonces of transactions
+	nonces *NonceManager
+	// nil unless transactions are signed by an external signer instead of the keypair
+	signer *RemoteSigner
+	// refuses deliveries whose estimated cost exceeds the cap
+	costCap *chain.CostCap
+	// captures the state of the writer once its queues back up
+	monitor *chain.BackpressureMonitor
+	// monitors of the accounts paying for submissions, set up when the writer starts
+	balances []*chain.BalanceMonitor
+	// nil unless transactions are recorded before they are signed, under the chain name
+	intents    chain.IntentLog
+	intentName string
+	// user operations are submitted one at a time, as their nonce is read from the entry point
+	bundlerMutex sync.Mutex
+	log          *logrus.Entry
 }
 
+const (
+	// gas limit for application calls, in units
+	gasLimit = uint64(2000000)
+	// additional gas for the forwarder to verify and dispatch a meta-transaction
+	forwarderGasOverhead = uint64(100000)
+)
+
 const RawABI = `
 [
 	{
@@ -44,26 +97,201 @@ const RawABI = `
 		"outputs": [],
 		"stateMutability": "nonpayable",
 		"type": "function"
-	  }
+	  },
+	{
+		"inputs": [
+			{
+				"internalType": "bytes[]",
+				"name": "messages",
+				"type": "bytes[]"
+			}
+		],
+		"name": "submitBatch",
+		"outputs": [],
+		"stateMutability": "nonpayable",
+		"type": "function"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{
+				"indexed": false,
+				"internalType": "uint256",
+				"name": "index",
+				"type": "uint256"
+			},
+			{
+				"indexed": false,
+				"internalType": "bool",
+				"name": "success",
+				"type": "bool"
+			}
+		],
+		"name": "BatchResult",
+		"type": "event"
+	}
 ]
 `
 
-func NewWriter(conn *Connection, messages <-chan chain.Message, log *logrus.Entry) (*Writer, error) {
+func NewWriter(config *Config, conn Connection, messages <-chan chain.Message, receipts chain.ReceiptLog, pricer chain.Pricer, skipped chain.SkipList, log *logrus.Entry) (*Writer, error) {
 	contractABI, err := abi.JSON(strings.NewReader(RawABI))
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Writer{
-		conn:     conn,
-		abi:      contractABI,
-		messages: messages,
-		log:      log,
-	}, nil
+	var sponsor *secp256k1.Keypair
+	if config.SponsorKey != "" {
+		sponsor, err = secp256k1.NewKeypairFromString(config.SponsorKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var bundler *Bundler
+	if config.Bundler != nil {
+		bundler, err = NewBundler(config.Bundler, chain.NewRPCStats(Name, config.Bundler.Endpoint, &config.RPC, log))
+		if err != nil {
+			return nil, err
+		}
+		dialer, err := chain.NewDialer(&config.RPC.Dial)
+		if err != nil {
+			return nil, err
+		}
+		bundler.DialThrough(dialer)
+	}
+
+	var signer *RemoteSigner
+	if config.RemoteSigner != nil {
+		if sponsor != nil || bundler != nil {
+			return nil, fmt.Errorf("a remote signer cannot be used together with a sponsor or bundler submission")
+		}
+		targets := make([]common.Address, 0, len(config.Apps))
+		for _, app := range config.Apps {
+			targets = append(targets, common.HexToAddress(app.Address))
+		}
+		signer, err = NewRemoteSigner(config.RemoteSigner, targets, log)
+		if err != nil {
+			return nil, err
+		}
+		dialer, err := chain.NewDialer(&config.RPC.Dial)
+		if err != nil {
+			return nil, err
+		}
+		signer.DialThrough(dialer)
+	}
+
+	pending, err := NewPendingSet(&config.Confirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	fees, err := NewFeeOracle(&config.DynamicFees, log)
+	if err != nil {
+		return nil, err
+	}
+
+	costCap, err := chain.NewCostCap(Name, "gas", config.CostCap.MaxGas, config.CostCap.MaxFee, config.CostCap.MaxCost, pricer)
+	if err != nil {
+		return nil, err
+	}
+
+	wr := &Writer{
+		config:     config,
+		conn:       conn,
+		abi:        contractABI,
+		messages:   messages,
+		receipts:   receipts,
+		pricer:     pricer,
+		skipped:    skipped,
+		sponsor:    sponsor,
+		forwarders: make(map[common.Address]*Forwarder),
+		bundler:    bundler,
+		signer:     signer,
+		gate:       chain.NewGate(),
+		tuner:      NewFeeTuner(&config.FeeTuning, log),
+		throughput: chain.NewThroughputTuner(Name, &config.AutoTune, log),
+		retries:    NewRetryPolicy(&config.Retry),
+		pending:    pending,
+		fees:       fees,
+		backoff:    chain.NewBackoff(Name, &config.RPC.Backoff),
+		nonces:     NewNonceManager(&config.Nonces, log),
+		costCap:    costCap,
+		log:        log,
+	}
+
+	wr.dispatcher = chain.NewDispatcher(Name, wr.gate, wr.handle, log)
+	wr.dispatcher.Tune(wr.throughput)
+	wr.monitor = chain.NewBackpressureMonitor(Name, &config.Diagnostics, wr.inspect, log)
+	wr.dispatcher.Monitor(wr.monitor)
+	wr.apps = make(map[[20]byte]string, len(config.Apps))
+	wr.batchers = make(map[[20]byte]*Batcher)
+	for name, app := range config.Apps {
+		wr.apps[common.HexToAddress(app.Address)] = name
+		if app.Batch != nil && app.Batch.Size > 1 {
+			wr.batchers[common.HexToAddress(app.Address)] = NewBatcher(name, common.HexToAddress(app.Address), app.Batch)
+		}
+		if app.Throttle != nil || app.Budget != nil {
+			wr.dispatcher.AddLane(name, common.HexToAddress(app.Address), app.Throttle, app.Budget)
+		}
+	}
+	for i := range config.Overrides {
+		wr.dispatcher.AddOverride(&config.Overrides[i])
+	}
+
+	return wr, nil
 }
 
 func (wr *Writer) Start(ctx context.Context, eg *errgroup.Group) error {
+	err := wr.loadForwarders(ctx)
+	if err != nil {
+		return err
+	}
+
+	if wr.fees.Enabled() || wr.signer != nil {
+		wr.chainID, err = wr.conn.Client().ChainID(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if wr.signer != nil {
+		if len(wr.forwarders) > 0 {
+			return fmt.Errorf("forwarders cannot be used together with a remote signer")
+		}
+
+		err = wr.signer.Connect(ctx)
+		if err != nil {
+			return err
+		}
+
+		wr.log.WithFields(logrus.Fields{
+			"endpoint": wr.signer.config.Endpoint,
+			"account":  wr.signer.Address().Hex(),
+		}).Info("Signing transactions with remote signer")
+	}
+
+	if wr.bundler != nil {
+		if len(wr.forwarders) > 0 {
+			return fmt.Errorf("forwarders cannot be used together with bundler submission")
+		}
+
+		err = wr.bundler.Connect(ctx, wr.conn)
+		if err != nil {
+			return err
+		}
+
+		wr.log.WithFields(logrus.Fields{
+			"endpoint": wr.bundler.config.Endpoint,
+			"account":  wr.bundler.Account().Hex(),
+		}).Info("Delivering messages as user operations")
+	}
+
+	err = wr.setupBalances()
+	if err != nil {
+		return err
+	}
+
 	eg.Go(func() error {
 		return wr.writeLoop(ctx)
 	})
@@ -71,67 +299,539 @@ func (wr *Writer) Start(ctx context.Context, eg *errgroup.Group) error {
 }
 
 func (wr *Writer) writeLoop(ctx context.Context) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case msg := <-wr.messages:
-			err := wr.Write(ctx, &msg)
-			if err != nil {
-				wr.log.WithError(err).Error("Error submitting message to ethereum")
-			}
+	if wr.bundler != nil {
+		defer wr.bundler.Close()
+	}
+	if wr.signer != nil {
+		defer wr.signer.Close()
+	}
+
+	if len(wr.batchers) == 0 {
+		return wr.dispatcher.Run(ctx, wr.messages)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, batcher := range wr.batchers {
+		batcher := batcher
+		eg.Go(func() error {
+			return wr.batchLoop(ctx, batcher)
+		})
+	}
+	eg.Go(func() error {
+		return wr.dispatcher.Run(ctx, wr.messages)
+	})
+	return eg.Wait()
+}
+
+// handle submits a message, or adds it to the batch of its app if its deliveries are batched
+func (wr *Writer) handle(ctx context.Context, msg *chain.Message, escalate bool) {
+	if batcher, ok := wr.batchers[msg.AppID]; ok {
+		batcher.add(ctx, msg, escalate)
+		return
+	}
+	wr.handleOne(ctx, msg, escalate)
+}
+
+// handleOne submits a message in its own transaction
+func (wr *Writer) handleOne(ctx context.Context, msg *chain.Message, escalate bool) {
+	attempts := 0
+	err := wr.backoff.Retry(ctx, wr.log, "submit message", func() error {
+		attempts++
+		return wr.write(ctx, msg, escalate)
+	})
+	if err != nil {
+		metrics.TransactionsFailed.WithLabelValues(Name, wr.app(msg)).Inc()
+		wr.log.WithError(err).WithFields(msg.LogFields()).Error("Error submitting message to ethereum")
+		chain.DeadLetter(ctx, wr.deadLetters, Name, msg, err, attempts, wr.log)
+	}
+}
+
+// DivertFailures moves the messages whose submission failed permanently, or whose delivery
+// keeps reverting, to a dead-letter queue
+func (wr *Writer) DivertFailures(queue chain.DeadLetterQueue) {
+	wr.deadLetters = queue
+}
+
+// ReportBackpressure records the state of the writer captured once its queues back up in a
+// diagnostic log
+func (wr *Writer) ReportBackpressure(diagnostics chain.DiagnosticLog) {
+	wr.monitor.Record(diagnostics)
+}
+
+// RecordIntents records the nonce of each transaction in an intent log before it is signed,
+// under the name of the chain to which the writer submits
+func (wr *Writer) RecordIntents(name string, intents chain.IntentLog) {
+	wr.intentName = name
+	wr.intents = intents
+}
+
+// recordIntent records a transaction of an account in the intent log, if there is one
+func (wr *Writer) recordIntent(kp *secp256k1.Keypair, nonce uint64) error {
+	if wr.intents == nil {
+		return nil
+	}
+	err := wr.intents.RecordIntent(wr.intentName, kp.CommonAddress().Hex(), nonce)
+	if err != nil {
+		return fmt.Errorf("record intent: %w", err)
+	}
+	return nil
+}
+
+// AccountNonces returns the pending nonce of each account from which the writer sends
+// transactions, by address
+func (wr *Writer) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	accounts := []common.Address{wr.conn.Keypair().CommonAddress()}
+	if wr.sponsor != nil {
+		accounts = append(accounts, wr.sponsor.CommonAddress())
+	}
+
+	nonces := make(map[string]uint64, len(accounts))
+	for _, account := range accounts {
+		nonce, err := wr.conn.Client().PendingNonceAt(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+		nonces[account.Hex()] = nonce
+	}
+	return nonces, nil
+}
+
+// app returns the name of the app to which a message is delivered, for labelling metrics
+func (wr *Writer) app(msg *chain.Message) string {
+	if name, ok := wr.apps[msg.AppID]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Queues returns the messages pending submission to each app
+func (wr *Writer) Queues() []chain.QueueStats {
+	return wr.dispatcher.Queues()
+}
+
+// Boost escalates the submission of a queued message
+func (wr *Writer) Boost(id string) (*chain.Boost, error) {
+	return wr.dispatcher.Boost(id)
+}
+
+// Bundler returns the bundler through which user operations are submitted, nil if disabled
+func (wr *Writer) Bundler() *Bundler {
+	return wr.bundler
+}
+
+// LastSubmission returns when a submission last completed, zero if none did
+func (wr *Writer) LastSubmission() time.Time {
+	return wr.dispatcher.LastSubmission()
+}
+
+// Gate returns the gate through which submissions can be halted
+func (wr *Writer) Gate() *chain.Gate {
+	return wr.gate
+}
+
+// loadForwarders sets up meta-transaction forwarders for apps which are configured to use one
+func (wr *Writer) loadForwarders(ctx context.Context) error {
+	for name, app := range wr.config.Apps {
+		if app.Forwarder == nil {
+			continue
+		}
+
+		if wr.sponsor == nil {
+			return fmt.Errorf("app %s uses a forwarder but no sponsor key is configured", name)
+		}
+
+		chainID, err := wr.conn.Client().ChainID(ctx)
+		if err != nil {
+			return err
+		}
+
+		forwarder, err := NewForwarder(app.Forwarder, chainID)
+		if err != nil {
+			return err
 		}
+
+		wr.forwarders[common.HexToAddress(app.Address)] = forwarder
+
+		wr.log.WithFields(logrus.Fields{
+			"app":              name,
+			"forwarderAddress": forwarder.Address().Hex(),
+			"sponsor":          wr.sponsor.CommonAddress().Hex(),
+		}).Info("Delivering app messages through forwarder")
 	}
+
+	return nil
 }
 
 // Submit sends a SCALE-encoded message to an application deployed on the Ethereum network
 func (wr *Writer) Write(ctx context.Context, msg *chain.Message) error {
+	return wr.write(ctx, msg, false)
+}
+
+// write submits a message, bumping the gas price of escalated transactions
+func (wr *Writer) write(ctx context.Context, msg *chain.Message, escalate bool) error {
+	return wr.deliver(ctx, msg, escalate, 0)
+}
 
+// deliver submits a message, given the checks already made of its earlier reverted
+// deliveries
+func (wr *Writer) deliver(ctx context.Context, msg *chain.Message, escalate bool, checks int) error {
 	address := common.Address(msg.AppID)
 
-	wr.log.WithFields(logrus.Fields{
-		"contractAddress": address.Hex(),
-	}).Info("Submitting message to Ethereum")
+	wr.log.WithFields(msg.LogFields()).WithField("contractAddress", address.Hex()).Info("Submitting message to Ethereum")
 
-	nonce, err := wr.conn.client.PendingNonceAt(ctx, wr.conn.kp.CommonAddress())
+	txData, err := wr.abi.Pack("submit", msg.Payload)
 	if err != nil {
-		return err
+		return chain.Permanent(err)
 	}
 
-	value := big.NewInt(0)      // in wei (0 eth)
-	gasLimit := uint64(2000000) // in units
-	gasPrice, err := wr.conn.client.SuggestGasPrice(ctx)
+	err = wr.checkCost(ctx, wr.app(msg), address, txData, 1, escalate)
 	if err != nil {
 		return err
 	}
 
-	txData, err := wr.abi.Pack("submit", msg.Payload)
+	ctx, span := tracing.Start(tracing.ContextWith(ctx, msg.Trace), "submit message")
+	span.SetAttribute("chain", Name)
+	span.SetAttribute("app", wr.app(msg))
+	span.SetAttribute("messageID", msg.ID)
+	hash, maxFee, err := wr.submit(ctx, address, gasLimit, txData, escalate)
+	span.End(err)
 	if err != nil {
+		wr.throughput.Rejected()
 		return err
 	}
+	// the confirmation of the delivery descends from its submission
+	if span != nil {
+		msg.Trace = span.Context()
+	}
+
+	metrics.TransactionsSubmitted.WithLabelValues(Name, wr.app(msg)).Inc()
+	// the delay is observed for the first delivery only, not for retries of reverted ones
+	if checks == 0 && !msg.ObservedAt.IsZero() {
+		metrics.SubmissionDelay.WithLabelValues(Name).Observe(time.Since(msg.ObservedAt).Seconds())
+	}
+
+	// deliveries are confirmed for the receipt log, to tune the gas price and throughput,
+	// to retry reverts, to track their confirmation depth and to settle forwarder nonces
+	if wr.receipts != nil || wr.tuner.Enabled() || wr.throughput.Enabled() || wr.retries.Enabled() || wr.pending.Enabled() || len(wr.forwarders) > 0 {
+		receipt := chain.Receipt{
+			Chain:        Name,
+			Hash:         hash.Hex(),
+			SubmittedAt:  time.Now().UTC(),
+			MaxFeePerGas: maxFee.String(),
+		}
+		if wr.receipts != nil {
+			wr.receipts.Submitted(msg, &receipt)
+		}
+		wr.throughput.Submitted()
+		go wr.confirm(ctx, *msg, receipt, hash, checks)
+	}
+
+	return nil
+}
 
-	tx := types.NewTransaction(nonce, address, value, gasLimit, gasPrice, txData)
-	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, wr.conn.kp.PrivateKey())
+// submit sends the call through the configured delivery path, returning the hash of
+// the transaction or user operation and the max fee per gas it offered. The fees of user
+// operations are not escalated. Retries of reverted deliveries and of the failed members of
+// batches don't pass through the dispatcher, so submissions wait here while the writer is
+// halted too.
+func (wr *Writer) submit(ctx context.Context, address common.Address, gas uint64, txData []byte, escalate bool) (common.Hash, *big.Int, error) {
+	err := wr.gate.Wait(ctx)
 	if err != nil {
-		return err
+		return common.Hash{}, nil, err
+	}
+
+	if wr.bundler != nil {
+		return wr.sendUserOperation(ctx, address, txData)
 	}
 
-	err = wr.conn.client.SendTransaction(ctx, signedTx)
+	forwarder, ok := wr.forwarders[address]
+	if !ok {
+		return wr.send(ctx, wr.conn.Keypair(), address, gas, txData, escalate)
+	}
+
+	// The relayer account signs the forward request, while the sponsor
+	// account pays for gas and is the sender of the actual transaction
+	forwardData, err := forwarder.Wrap(ctx, wr.conn, address, gas, txData)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	hash, gasPrice, err := wr.send(ctx, wr.sponsor, forwarder.Address(), gas+forwarderGasOverhead, forwardData, escalate)
+	if err != nil {
+		forwarder.Reset(wr.conn.Keypair().CommonAddress())
+		return common.Hash{}, nil, err
+	}
+	forwarder.Sent(wr.conn.Keypair().CommonAddress(), hash)
+
+	return hash, gasPrice, nil
+}
+
+// settleForwarded reports to the forwarders whether a transaction was executed, so that
+// the nonce of a forwarded request which wasn't is taken from its forwarder again
+func (wr *Writer) settleForwarded(hash common.Hash, executed bool) {
+	for _, forwarder := range wr.forwarders {
+		forwarder.Settled(hash, executed)
+	}
+}
+
+// sendUserOperation submits the call to the bundler, with the relayer's smart account as sender
+func (wr *Writer) sendUserOperation(ctx context.Context, address common.Address, txData []byte) (common.Hash, *big.Int, error) {
+	wr.bundlerMutex.Lock()
+	defer wr.bundlerMutex.Unlock()
+
+	fees, err := wr.userOperationFees(ctx)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	hash, err := wr.bundler.Submit(ctx, wr.conn, address, txData, fees.gasPrice, fees.tip)
 	if err != nil {
+		wr.log.WithError(err).WithFields(logrus.Fields{
+			"contractAddress": address.Hex(),
+			"account":         wr.bundler.Account().Hex(),
+		}).Error("Failed to submit user operation")
+		return common.Hash{}, nil, err
+	}
+
+	wr.log.WithFields(logrus.Fields{
+		"userOpHash":      hash.Hex(),
+		"contractAddress": address.Hex(),
+	}).Info("User operation submitted")
+
+	return hash, fees.gasPrice, nil
+}
+
+// userOperationFees returns the fees of a user operation, which are suggested from the fee
+// history of the chain even if transactions are sent with legacy gas prices, so that the
+// priority fee doesn't take up the whole gas price
+func (wr *Writer) userOperationFees(ctx context.Context) (*txFees, error) {
+	tip, feeCap, ok, err := wr.fees.Suggest(ctx, wr.conn.Client())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		tip, feeCap = wr.fees.limit(wr.premium(tip, false), wr.premium(feeCap, false))
+		return &txFees{gasPrice: feeCap, tip: tip}, nil
+	}
+
+	gasPrice, err := wr.gasPrice(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return &txFees{gasPrice: gasPrice}, nil
+}
+
+// send signs a transaction calling the given contract and submits it, returning its hash
+// and gas price, the max fee per gas of dynamic fee transactions
+func (wr *Writer) send(ctx context.Context, kp *secp256k1.Keypair, address common.Address, gas uint64, txData []byte, escalate bool) (common.Hash, *big.Int, error) {
+	_, build := tracing.Start(ctx, "build transaction")
+	signedTx, nonce, err := wr.build(ctx, kp, address, gas, txData, escalate)
+	build.End(err)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	_, span := tracing.Start(ctx, "send transaction")
+	span.SetAttribute("txHash", signedTx.Hash().Hex())
+	err = wr.sendTransaction(ctx, signedTx)
+	span.End(err)
+	if err != nil {
+		nonce.failed(err)
 		wr.log.WithError(err).WithFields(logrus.Fields{
 			"txHash":          signedTx.Hash().Hex(),
 			"contractAddress": address.Hex(),
-			"nonce":           nonce,
-			"gasLimit":        gasLimit,
-			"gasPrice":        gasPrice,
+			"nonce":           nonce.value,
+			"gasLimit":        gas,
+			"gasPrice":        signedTx.GasPrice(),
 		}).Error("Failed to submit transaction")
-		return err
+		return common.Hash{}, nil, err
 	}
+	nonce.sent()
 
 	wr.log.WithFields(logrus.Fields{
 		"txHash":          signedTx.Hash().Hex(),
 		"contractAddress": address.Hex(),
 	}).Info("Transaction submitted")
+	wr.pending.add(kp, signedTx)
 
-	return nil
+	return signedTx.Hash(), signedTx.GasPrice(), nil
+}
+
+// build signs a transaction calling the given contract with the fees and the nonce it is
+// sent with, which must be reported as sent or failed
+func (wr *Writer) build(ctx context.Context, kp *secp256k1.Keypair, address common.Address, gas uint64, txData []byte, escalate bool) (signedTransaction, *reservation, error) {
+	fees, err := wr.txFees(ctx, escalate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce, err := wr.nonces.reserve(ctx, wr.conn.Client(), kp.CommonAddress())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = wr.recordIntent(kp, nonce.value)
+	if err != nil {
+		nonce.failed(err)
+		return nil, nil, err
+	}
+
+	signedTx, err := wr.signWithFees(ctx, kp, nonce.value, address, gas, fees, txData)
+	if err != nil {
+		nonce.failed(err)
+		// transactions which couldn't reach the remote signer are signed again on retry
+		if wr.signer != nil {
+			return nil, nil, err
+		}
+		return nil, nil, chain.Permanent(err)
+	}
+
+	return signedTx, nonce, nil
+}
+
+// sendTransaction sends a legacy or dynamic fee transaction, unless the writer is halted
+func (wr *Writer) sendTransaction(ctx context.Context, tx signedTransaction) error {
+	if halted, reason := wr.gate.Halted(); halted {
+		return fmt.Errorf("submissions are halted: %s", reason)
+	}
+	if dynamic, ok := tx.(*dynamicFeeTx); ok {
+		return wr.conn.Client().SendRawTransaction(ctx, dynamic.Raw())
+	}
+	return wr.conn.Client().SendTransaction(ctx, tx.(*types.Transaction))
+}
+
+// sign builds and signs a transaction calling the given contract
+func (wr *Writer) sign(ctx context.Context, kp *secp256k1.Keypair, nonce uint64, address common.Address, gas uint64, gasPrice *big.Int, txData []byte) (*types.Transaction, error) {
+	if wr.signer != nil {
+		tx, err := wr.signer.Sign(ctx, wr.signRequest(kp, nonce, address, gas, &txFees{gasPrice: gasPrice}, txData))
+		if err != nil {
+			return nil, err
+		}
+		return tx.(*types.Transaction), nil
+	}
+
+	value := big.NewInt(0) // in wei (0 eth)
+	tx := types.NewTransaction(nonce, address, value, gas, gasPrice, txData)
+	return types.SignTx(tx, types.HomesteadSigner{}, kp.PrivateKey())
+}
+
+// signWithFees signs a dynamic fee transaction if the fees include a priority fee, and a
+// legacy transaction otherwise
+func (wr *Writer) signWithFees(ctx context.Context, kp *secp256k1.Keypair, nonce uint64, address common.Address, gas uint64, fees *txFees, txData []byte) (signedTransaction, error) {
+	if fees.tip == nil {
+		return wr.sign(ctx, kp, nonce, address, gas, fees.gasPrice, txData)
+	}
+	if wr.signer != nil {
+		return wr.signer.Sign(ctx, wr.signRequest(kp, nonce, address, gas, fees, txData))
+	}
+	return signDynamicFeeTx(kp, wr.chainID, nonce, address, gas, fees.tip, fees.gasPrice, txData)
+}
+
+// signRequest returns the request for the remote signer to sign a transaction
+func (wr *Writer) signRequest(kp *secp256k1.Keypair, nonce uint64, address common.Address, gas uint64, fees *txFees, txData []byte) *SignRequest {
+	return &SignRequest{
+		From:      kp.CommonAddress(),
+		To:        address,
+		Nonce:     nonce,
+		Gas:       gas,
+		ChainID:   wr.chainID,
+		GasPrice:  fees.gasPrice,
+		GasTipCap: fees.tip,
+		Data:      txData,
+	}
+}
+
+// txFees are the fees offered by a transaction
+type txFees struct {
+	// gas price of legacy transactions, max fee per gas of dynamic fee transactions
+	gasPrice *big.Int
+	// max priority fee per gas, nil for legacy transactions
+	tip *big.Int
+}
+
+// txFees returns the fees suggested by the fee oracle if they are enabled and supported by
+// the chain, and the gas price otherwise, plus the tuned premium and escalation bump
+func (wr *Writer) txFees(ctx context.Context, escalate bool) (*txFees, error) {
+	if wr.fees.Enabled() {
+		tip, feeCap, ok, err := wr.fees.Suggest(ctx, wr.conn.Client())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			tip, feeCap = wr.fees.limit(wr.premium(tip, escalate), wr.premium(feeCap, escalate))
+			return &txFees{gasPrice: feeCap, tip: tip}, nil
+		}
+	}
+
+	gasPrice, err := wr.gasPrice(ctx, escalate)
+	if err != nil {
+		return nil, err
+	}
+	return &txFees{gasPrice: gasPrice}, nil
+}
+
+// gasPrice returns the suggested gas price plus the tuned premium, bumped by the
+// configured percentage for escalated transactions
+func (wr *Writer) gasPrice(ctx context.Context, escalate bool) (*big.Int, error) {
+	gasPrice, err := wr.conn.Client().SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wr.premium(gasPrice, escalate), nil
+}
+
+// premium adds the tuned premium to a fee, bumped by the configured percentage for
+// escalated transactions
+func (wr *Writer) premium(fee *big.Int, escalate bool) *big.Int {
+	if premium := wr.tuner.Premium(); premium > 0 {
+		fee = bumpGasPrice(fee, premium)
+	}
+	if escalate {
+		fee = bumpGasPrice(fee, wr.config.EscalationFeeBump)
+	}
+	return fee
+}
+
+// bumpGasPrice adds a percentage to a gas price, rounding up
+func bumpGasPrice(gasPrice *big.Int, percent uint64) *big.Int {
+	bumped := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// Build signs the transaction submitting a message directly to its app without sending
+// it, using the pending nonce of the relayer account
+func (wr *Writer) Build(ctx context.Context, msg *chain.Message) (*types.Transaction, error) {
+	txData, err := wr.abi.Pack("submit", msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	gasPrice, err := wr.gasPrice(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := wr.conn.Client().PendingNonceAt(ctx, wr.conn.Keypair().CommonAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	// built transactions are sent by the caller, so they are intended like any other
+	err = wr.recordIntent(wr.conn.Keypair(), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return wr.sign(ctx, wr.conn.Keypair(), nonce, common.Address(msg.AppID), gasLimit, gasPrice, txData)
+}
+
+// EstimateGas returns the gas used by submitting a message directly to its app
+func (wr *Writer) EstimateGas(ctx context.Context, msg *chain.Message) (uint64, error) {
+	txData, err := wr.abi.Pack("submit", msg.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	return wr.estimate(ctx, common.Address(msg.AppID), txData)
 }
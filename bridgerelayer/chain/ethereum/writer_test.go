@@ -44,14 +44,14 @@ func TestWrite(t *testing.T) {
 	logger, hook := test.NewNullLogger()
 	log := logger.WithField("chain", "Ethereum")
 
-	conn := ethereum.NewConnection("ws://localhost:9545", kp, log)
+	conn := ethereum.NewConnection("ws://localhost:9545", kp, nil, log)
 
 	messages := make(chan chain.Message, 1)
 	ctx, cancel := context.WithCancel(context.Background())
 	eg, ctx := errgroup.WithContext(ctx)
 	defer cancel()
 
-	writer, err := ethereum.NewWriter(conn, messages, log)
+	writer, err := ethereum.NewWriter(&ethereum.Config{}, conn, messages, nil, nil, nil, log)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,304 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type FailoverConfig struct {
+	// Consecutive failed calls after which the active endpoint is abandoned for the
+	// healthiest of the others. Defaults to 3.
+	MaxErrors int `mapstructure:"max-errors"`
+	// Seconds between attempts to switch back to a preferred endpoint while a fallback is
+	// active. Defaults to 60.
+	FailbackInterval uint64 `mapstructure:"failback-interval"`
+}
+
+const (
+	defaultFailoverErrors   = 3
+	defaultFailbackInterval = 60
+	// weight of the outcome of each call in the health score of an endpoint
+	healthWeight = 0.2
+	// time given to connect to an endpoint and check it when switching to it
+	switchTimeout = 15 * time.Second
+)
+
+// EndpointDialer connects to the endpoint at an index of a pool, or reuses the client of an
+// earlier connection, and checks that it serves the chain of the others
+type EndpointDialer func(ctx context.Context, index int) error
+
+// EndpointPool selects which of the endpoints of a chain its calls are made to. The outcome
+// of each call is recorded in the health score of its endpoint, which falls toward 0 as
+// calls fail and recovers toward 1 as they succeed. Once the active endpoint fails enough
+// calls in a row, or its subscriptions drop, the pool fails over to the healthiest endpoint
+// it can connect to. While a fallback is active, the endpoints preferred over it are tried
+// again periodically, and the pool fails back to the first of them which connects.
+type EndpointPool struct {
+	chain     string
+	endpoints []*poolEndpoint
+	maxErrors int
+	failback  time.Duration
+	dial      EndpointDialer
+	mutex     sync.Mutex
+	active    int
+	// closed and replaced whenever the active endpoint changes
+	changed chan struct{}
+	// whether a failover or failback is in progress
+	switching bool
+	probedAt  time.Time
+	log       *logrus.Entry
+}
+
+type poolEndpoint struct {
+	// redacted endpoint, naming it in logs and metrics
+	name        string
+	score       float64
+	consecutive int
+}
+
+// EndpointHealth is a point-in-time view of the health of an endpoint of a pool
+type EndpointHealth struct {
+	Endpoint          string  `json:"endpoint"`
+	Score             float64 `json:"score"`
+	ConsecutiveErrors int     `json:"consecutiveErrors"`
+	Active            bool    `json:"active"`
+}
+
+// NewEndpointPool creates a pool of endpoints in order of preference
+func NewEndpointPool(chain string, endpoints []string, config *FailoverConfig, dial EndpointDialer, log *logrus.Entry) *EndpointPool {
+	ep := &EndpointPool{
+		chain:     chain,
+		maxErrors: config.MaxErrors,
+		failback:  time.Duration(config.FailbackInterval) * time.Second,
+		dial:      dial,
+		changed:   make(chan struct{}),
+		log:       log,
+	}
+	if ep.maxErrors <= 0 {
+		ep.maxErrors = defaultFailoverErrors
+	}
+	if ep.failback == 0 {
+		ep.failback = defaultFailbackInterval * time.Second
+	}
+	for _, endpoint := range endpoints {
+		ep.endpoints = append(ep.endpoints, &poolEndpoint{name: RedactEndpoint(endpoint), score: 1})
+	}
+	return ep
+}
+
+// Connect makes the first endpoint which connects the active one, returning the error of
+// the preferred endpoint if none does
+func (ep *EndpointPool) Connect(ctx context.Context) error {
+	var firstErr error
+	for index, endpoint := range ep.endpoints {
+		err := ep.dial(ctx, index)
+		ep.record(index, err)
+		if err == nil {
+			ep.switchTo(index, "")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		ep.log.WithError(err).WithField("endpoint", endpoint.name).Warn("Failed to connect to endpoint, trying the next")
+	}
+	return fmt.Errorf("failed to connect to any of %d endpoints: %w", len(ep.endpoints), firstErr)
+}
+
+// Active returns the index of the endpoint to which calls are made
+func (ep *EndpointPool) Active() int {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	return ep.active
+}
+
+// Changed returns a channel which is closed once the active endpoint changes
+func (ep *EndpointPool) Changed() <-chan struct{} {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	return ep.changed
+}
+
+// Observe records the outcome of a call to the endpoint at an index. Calls abandoned with
+// their context and requests which the node rejects don't count against an endpoint.
+// Failovers and failbacks which the outcome triggers happen in the background.
+func (ep *EndpointPool) Observe(ctx context.Context, index int, err error) {
+	if err != nil && (ctx.Err() != nil || Classify(err) == ErrorFatal) {
+		err = nil
+	}
+	ep.record(index, err)
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	if ep.switching || len(ep.endpoints) == 1 {
+		return
+	}
+	if err != nil && index == ep.active && ep.endpoints[index].consecutive >= ep.maxErrors {
+		ep.switching = true
+		go ep.failover(index, err)
+	} else if err == nil && ep.active != 0 && time.Since(ep.probedAt) >= ep.failback {
+		ep.switching = true
+		ep.probedAt = time.Now()
+		go ep.failbackFrom(ep.active)
+	}
+}
+
+// Drop records that a subscription to the endpoint at an index was dropped, failing over
+// right away if it is the active endpoint
+func (ep *EndpointPool) Drop(index int, err error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	endpoint := ep.endpoints[index]
+	endpoint.score *= 1 - healthWeight
+	endpoint.consecutive = ep.maxErrors
+	metrics.EndpointHealth.WithLabelValues(ep.chain, endpoint.name).Set(endpoint.score)
+
+	if ep.switching || len(ep.endpoints) == 1 || index != ep.active {
+		return
+	}
+	ep.switching = true
+	go ep.failover(index, err)
+}
+
+// Health returns the health of each endpoint, in order of preference
+func (ep *EndpointPool) Health() []EndpointHealth {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	health := make([]EndpointHealth, len(ep.endpoints))
+	for index, endpoint := range ep.endpoints {
+		health[index] = EndpointHealth{
+			Endpoint:          endpoint.name,
+			Score:             endpoint.score,
+			ConsecutiveErrors: endpoint.consecutive,
+			Active:            index == ep.active,
+		}
+	}
+	return health
+}
+
+// failover switches from a failed endpoint to the healthiest of the others which connects,
+// staying on the failed endpoint if none does
+func (ep *EndpointPool) failover(from int, cause error) {
+	defer ep.done()
+
+	ep.log.WithError(cause).WithField("endpoint", ep.endpoints[from].name).Warn("Endpoint is failing, failing over")
+	for _, index := range ep.candidates(from) {
+		if ep.try(index) {
+			ep.switchTo(index, "failover")
+			return
+		}
+	}
+	ep.log.WithField("endpoint", ep.endpoints[from].name).Error("ALERT: No other endpoint of the chain could be connected to, staying on the failing endpoint")
+}
+
+// failbackFrom switches from a fallback to the first endpoint preferred over it which connects
+func (ep *EndpointPool) failbackFrom(from int) {
+	defer ep.done()
+
+	for index := 0; index < from; index++ {
+		if ep.try(index) {
+			ep.switchTo(index, "failback")
+			return
+		}
+	}
+}
+
+// try connects to the endpoint at an index, recording the outcome in its health
+func (ep *EndpointPool) try(index int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), switchTimeout)
+	defer cancel()
+
+	err := ep.dial(ctx, index)
+	ep.record(index, err)
+	if err != nil {
+		ep.log.WithError(err).WithField("endpoint", ep.endpoints[index].name).Debug("Failed to connect to endpoint")
+		return false
+	}
+	return true
+}
+
+// candidates orders the endpoints other than one by health, then by preference
+func (ep *EndpointPool) candidates(except int) []int {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	var indexes []int
+	for index := range ep.endpoints {
+		if index != except {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return ep.endpoints[indexes[i]].score > ep.endpoints[indexes[j]].score
+	})
+	return indexes
+}
+
+func (ep *EndpointPool) record(index int, err error) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	endpoint := ep.endpoints[index]
+	outcome := 1.0
+	if err != nil {
+		outcome = 0
+		endpoint.consecutive++
+	} else {
+		endpoint.consecutive = 0
+	}
+	endpoint.score = (1-healthWeight)*endpoint.score + healthWeight*outcome
+	metrics.EndpointHealth.WithLabelValues(ep.chain, endpoint.name).Set(endpoint.score)
+}
+
+// switchTo makes the endpoint at an index the active one, counting the switch under reason
+// unless it is the first connection
+func (ep *EndpointPool) switchTo(index int, reason string) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	from := ep.endpoints[ep.active]
+	ep.active = index
+	close(ep.changed)
+	ep.changed = make(chan struct{})
+	ep.probedAt = time.Now()
+
+	for i, endpoint := range ep.endpoints {
+		active := 0.0
+		if i == index {
+			active = 1
+		}
+		metrics.EndpointActive.WithLabelValues(ep.chain, endpoint.name).Set(active)
+	}
+	if reason == "" {
+		return
+	}
+
+	metrics.EndpointSwitches.WithLabelValues(ep.chain, ep.endpoints[index].name, reason).Inc()
+	ep.log.WithFields(logrus.Fields{
+		"from":   from.name,
+		"to":     ep.endpoints[index].name,
+		"reason": reason,
+	}).Warn("Switched endpoint")
+}
+
+func (ep *EndpointPool) done() {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	ep.switching = false
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// endpoints tells which endpoints of a pool can be connected to
+type endpoints struct {
+	mutex sync.Mutex
+	down  map[int]bool
+}
+
+func (ep *endpoints) set(index int, down bool) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	ep.down[index] = down
+}
+
+func (ep *endpoints) dial(_ context.Context, index int) error {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	if ep.down[index] {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func waitChanged(t *testing.T, changed <-chan struct{}) {
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("active endpoint didn't change")
+	}
+}
+
+func TestEndpointPool(t *testing.T) {
+	ctx := context.Background()
+	state := &endpoints{down: map[int]bool{0: true}}
+	pool := chain.NewEndpointPool("Ethereum", []string{"ws://a:8545", "ws://b:8545", "ws://c:8545"},
+		&chain.FailoverConfig{MaxErrors: 2, FailbackInterval: 1}, state.dial, logrus.NewEntry(logrus.New()))
+
+	// the first endpoint which connects is the active one
+	require.NoError(t, pool.Connect(ctx))
+	assert.Equal(t, 1, pool.Active())
+	health := pool.Health()
+	assert.Less(t, health[0].Score, health[2].Score)
+	assert.True(t, health[1].Active)
+
+	// rejected requests and abandoned calls are no failures of the endpoint
+	pool.Observe(ctx, 1, fmt.Errorf("execution reverted"))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	pool.Observe(cancelled, 1, fmt.Errorf("connection reset"))
+	assert.Equal(t, 0, pool.Health()[1].ConsecutiveErrors)
+
+	// the healthiest endpoint is failed over to, as the preferred one is still down
+	changed := pool.Changed()
+	pool.Observe(ctx, 1, fmt.Errorf("connection reset"))
+	assert.Equal(t, 1, pool.Active())
+	pool.Observe(ctx, 1, fmt.Errorf("connection reset"))
+	waitChanged(t, changed)
+	assert.Equal(t, 2, pool.Active())
+
+	// once the preferred endpoint recovers, the pool fails back to it after the interval
+	state.set(0, false)
+	changed = pool.Changed()
+	pool.Observe(ctx, 2, nil)
+	assert.Equal(t, 2, pool.Active())
+	time.Sleep(time.Second)
+	pool.Observe(ctx, 2, nil)
+	waitChanged(t, changed)
+	assert.Equal(t, 0, pool.Active())
+
+	// a dropped subscription fails over right away
+	changed = pool.Changed()
+	pool.Drop(0, fmt.Errorf("websocket closed"))
+	waitChanged(t, changed)
+	assert.NotEqual(t, 0, pool.Active())
+}
+
+func TestEndpointPool_NoneConnects(t *testing.T) {
+	state := &endpoints{down: map[int]bool{0: true, 1: true}}
+	pool := chain.NewEndpointPool("Substrate", []string{"ws://a:9944", "ws://b:9944"},
+		&chain.FailoverConfig{}, state.dial, logrus.NewEntry(logrus.New()))
+
+	err := pool.Connect(context.Background())
+	assert.EqualError(t, err, "failed to connect to any of 2 endpoints: connection refused")
+}
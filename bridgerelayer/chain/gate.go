@@ -0,0 +1,102 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReasonPausedOnChain halts writers while the bridge protocol itself is paused on-chain
+const ReasonPausedOnChain = "paused on-chain"
+
+// Gate halts and resumes message processing. While halted, pending messages are
+// left queued rather than being dropped. A gate can be halted for several reasons
+// at once, and only opens again once every reason has been released.
+type Gate struct {
+	mutex   sync.Mutex
+	reasons map[string]bool
+	open    chan struct{}
+}
+
+func NewGate() *Gate {
+	open := make(chan struct{})
+	close(open)
+	return &Gate{reasons: make(map[string]bool), open: open}
+}
+
+// Halt stops processing until the reason is released, or Resume is called
+func (g *Gate) Halt(reason string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.reasons[reason] = true
+	select {
+	case <-g.open:
+		g.open = make(chan struct{})
+	default:
+	}
+}
+
+// Release withdraws a reason for halting, continuing processing if no other reasons remain
+func (g *Gate) Release(reason string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.reasons, reason)
+	if len(g.reasons) == 0 {
+		g.openLocked()
+	}
+}
+
+// Resume continues processing, releasing all reasons for halting
+func (g *Gate) Resume() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.reasons = make(map[string]bool)
+	g.openLocked()
+}
+
+func (g *Gate) openLocked() {
+	select {
+	case <-g.open:
+	default:
+		close(g.open)
+	}
+}
+
+// Halted returns whether the gate is halted, and why
+func (g *Gate) Halted() (bool, string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.reasons) == 0 {
+		return false, ""
+	}
+
+	reasons := make([]string, 0, len(g.reasons))
+	for reason := range g.reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	return true, strings.Join(reasons, ", ")
+}
+
+// Wait blocks while the gate is halted
+func (g *Gate) Wait(ctx context.Context) error {
+	g.mutex.Lock()
+	open := g.open
+	g.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-open:
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestGate(t *testing.T) {
+	gate := chain.NewGate()
+
+	err := gate.Wait(context.Background())
+	assert.NoError(t, err)
+
+	gate.Halt("maintenance")
+	halted, reason := gate.Halted()
+	assert.True(t, halted)
+	assert.Equal(t, "maintenance", reason)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = gate.Wait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	done := make(chan error)
+	go func() {
+		done <- gate.Wait(context.Background())
+	}()
+	gate.Resume()
+	assert.NoError(t, <-done)
+
+	halted, _ = gate.Halted()
+	assert.False(t, halted)
+}
+
+func TestGate_Release(t *testing.T) {
+	gate := chain.NewGate()
+
+	gate.Halt("paused on-chain")
+	gate.Halt("drill")
+
+	halted, reason := gate.Halted()
+	assert.True(t, halted)
+	assert.Equal(t, "drill, paused on-chain", reason)
+
+	gate.Release("drill")
+	halted, reason = gate.Halted()
+	assert.True(t, halted)
+	assert.Equal(t, "paused on-chain", reason)
+
+	gate.Release("paused on-chain")
+	halted, _ = gate.Halted()
+	assert.False(t, halted)
+	assert.NoError(t, gate.Wait(context.Background()))
+}
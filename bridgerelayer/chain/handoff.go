@@ -0,0 +1,37 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConsumerStopped is returned when a message is handed to a consumer which stopped
+var ErrConsumerStopped = errors.New("consumer of messages stopped")
+
+// Handoff sends a message observed by a listener to its consumer. Rather than blocking
+// the listener forever, it gives up once the context is done or the consumer stopped,
+// as signalled by closing stopped, which is nil if the consumer isn't monitored. The
+// message is then persisted to the quarantine, if given, so that it isn't lost.
+func Handoff(ctx context.Context, messages chan<- Message, stopped <-chan struct{}, quarantine Quarantine, source string, msg Message) error {
+	var err error
+	select {
+	case messages <- msg:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-stopped:
+		err = ErrConsumerStopped
+	}
+
+	if quarantine != nil {
+		qerr := quarantine.Quarantine(source, &msg, fmt.Sprintf("unsent: %v", err))
+		if qerr != nil {
+			return fmt.Errorf("%w, and failed to persist message: %v", err, qerr)
+		}
+	}
+	return err
+}
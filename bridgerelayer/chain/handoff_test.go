@@ -0,0 +1,46 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type quarantine struct {
+	reasons []string
+}
+
+func (q *quarantine) Quarantine(_ string, _ *chain.Message, reason string) error {
+	q.reasons = append(q.reasons, reason)
+	return nil
+}
+
+func TestHandoff(t *testing.T) {
+	messages := make(chan chain.Message, 1)
+	stopped := make(chan struct{})
+	unsent := &quarantine{}
+
+	err := chain.Handoff(context.Background(), messages, stopped, unsent, "Ethereum", chain.Message{ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", (<-messages).ID)
+
+	// the consumer doesn't read the message once it stopped
+	close(stopped)
+	messages <- chain.Message{}
+	err = chain.Handoff(context.Background(), messages, stopped, unsent, "Ethereum", chain.Message{ID: "b"})
+	assert.Equal(t, chain.ErrConsumerStopped, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = chain.Handoff(ctx, messages, nil, unsent, "Ethereum", chain.Message{ID: "c"})
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, []string{"unsent: consumer of messages stopped", "unsent: context canceled"}, unsent.reasons)
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MinPayloadSchemaVersion is the oldest payload schema version of the bridge components with
+// which the relayer can relay. Components reporting a version outside of
+// MinPayloadSchemaVersion to PayloadSchemaVersion are refused.
+const MinPayloadSchemaVersion = 1
+
+// HandshakeConfig negotiates the payload schema version with the bridge components of a
+// chain at startup, so that the relayer refuses to relay to components expecting payloads it
+// doesn't encode
+type HandshakeConfig struct {
+	// Whether the versions are read and compared at startup. Disabled by default, as
+	// components deployed before they were versioned report none.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// ComponentVersion is the payload schema version reported by a bridge component, a contract
+// or pallet of an app
+type ComponentVersion struct {
+	Chain string `json:"chain"`
+	App   string `json:"app"`
+	// Contract address, or the pallet and item from which the version was read
+	Component string `json:"component"`
+	Version   uint64 `json:"version"`
+	// Why the version could not be read, empty if it was
+	Error string `json:"error,omitempty"`
+}
+
+// Compatible returns whether the component reported a version which the relayer supports
+func (cv *ComponentVersion) Compatible() bool {
+	return cv.Error == "" && cv.Version >= MinPayloadSchemaVersion && cv.Version <= PayloadSchemaVersion
+}
+
+// CompatibilityReport compares the versions of the bridge components with those the relayer
+// supports
+type CompatibilityReport struct {
+	MinSupported int                `json:"minSupported"`
+	MaxSupported int                `json:"maxSupported"`
+	Components   []ComponentVersion `json:"components"`
+}
+
+func NewCompatibilityReport(components []ComponentVersion) *CompatibilityReport {
+	return &CompatibilityReport{
+		MinSupported: MinPayloadSchemaVersion,
+		MaxSupported: PayloadSchemaVersion,
+		Components:   components,
+	}
+}
+
+// Compatible returns whether every component reported a supported version
+func (cr *CompatibilityReport) Compatible() bool {
+	for i := range cr.Components {
+		if !cr.Components[i].Compatible() {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns a permanent error describing each incompatible component, nil if all are
+// compatible
+func (cr *CompatibilityReport) Err() error {
+	var problems []string
+	for _, component := range cr.Components {
+		if component.Compatible() {
+			continue
+		}
+		subject := fmt.Sprintf("%s app %s (%s)", component.Chain, component.App, component.Component)
+		if component.Error != "" {
+			problems = append(problems, fmt.Sprintf("%s reports no version: %s", subject, component.Error))
+		} else {
+			problems = append(problems, fmt.Sprintf("%s reports version %d", subject, component.Version))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return Permanent(fmt.Errorf(
+		"incompatible bridge components, the relayer supports payload schema versions %d to %d: %s",
+		cr.MinSupported, cr.MaxSupported, strings.Join(problems, "; "),
+	))
+}
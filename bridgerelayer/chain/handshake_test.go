@@ -0,0 +1,34 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestCompatibilityReport(t *testing.T) {
+	report := chain.NewCompatibilityReport([]chain.ComponentVersion{
+		{Chain: "Ethereum", App: "eth", Component: "0x01", Version: chain.PayloadSchemaVersion},
+		{Chain: "Substrate", App: "eth", Component: "constant ETH.PayloadVersion", Version: chain.PayloadSchemaVersion},
+	})
+	assert.True(t, report.Compatible())
+	assert.NoError(t, report.Err())
+
+	report = chain.NewCompatibilityReport([]chain.ComponentVersion{
+		{Chain: "Ethereum", App: "eth", Component: "0x01", Version: chain.PayloadSchemaVersion},
+		{Chain: "Ethereum", App: "erc20", Component: "0x02", Version: chain.PayloadSchemaVersion + 1},
+		{Chain: "Ethereum", App: "dot", Component: "0x03", Error: "contract has no version getter"},
+	})
+	assert.False(t, report.Compatible())
+
+	err := report.Err()
+	assert.Equal(t, chain.ErrorFatal, chain.Classify(err))
+	assert.Contains(t, err.Error(), "Ethereum app erc20 (0x02) reports version 2")
+	assert.Contains(t, err.Error(), "Ethereum app dot (0x03) reports no version: contract has no version getter")
+	assert.NotContains(t, err.Error(), "app eth ")
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+)
+
+// MessageHashVersion is the version of the scheme by which message IDs are derived. It is
+// hashed into every ID, so that IDs of a later scheme never collide with earlier ones.
+const MessageHashVersion = 1
+
+// MessageDomain separates the hashes of messages from any other Keccak-256 hash
+var MessageDomain = crypto.Keccak256Hash([]byte("artemis-relay/message"))
+
+// MessageHash returns the canonical hash of a message from a source chain, with the
+// canonical encoding of its payload. The hash identifies the message in the message store,
+// the archive, attestations and logs, and is reproduced by contracts as
+//
+//	keccak256(abi.encodePacked(MessageDomain, uint8(version), keccak256(source), appId, keccak256(payload)))
+//
+// where source is the lower case name of the source chain.
+func MessageHash(source string, msg *Message) (common.Hash, []byte, error) {
+	payload, err := CanonicalPayload(msg.Payload)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	hash := crypto.Keccak256Hash(
+		MessageDomain[:],
+		[]byte{MessageHashVersion},
+		crypto.Keccak256([]byte(strings.ToLower(source))),
+		msg.AppID[:],
+		crypto.Keccak256(payload),
+	)
+	return hash, payload, nil
+}
+
+// CanonicalPayload encodes a payload as it is hashed. Byte payloads, which are delivered
+// to Ethereum as they are, are taken unchanged, while other payloads are SCALE-encoded as
+// they are delivered to Substrate.
+func CanonicalPayload(payload interface{}) ([]byte, error) {
+	if data, ok := payload.([]byte); ok {
+		return data, nil
+	}
+	return types.EncodeToBytes(payload)
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messageIDVector is shared with the contract tests in ethereum/test, so that contracts
+// derive the same IDs
+type messageIDVector struct {
+	Source  string         `json:"source"`
+	AppID   common.Address `json:"appId"`
+	Payload hexutil.Bytes  `json:"payload"`
+	ID      common.Hash    `json:"id"`
+}
+
+func TestMessageHash_Vectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/message_ids.json")
+	require.NoError(t, err)
+
+	var vectors []messageIDVector
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	require.NotEmpty(t, vectors)
+
+	for _, vector := range vectors {
+		hash, payload, err := MessageHash(vector.Source, &Message{AppID: vector.AppID, Payload: []byte(vector.Payload)})
+		require.NoError(t, err)
+		assert.Equal(t, vector.ID, hash, vector.Source)
+		assert.Equal(t, []byte(vector.Payload), payload)
+	}
+}
+
+func TestMessageHash(t *testing.T) {
+	msg := &Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1, 2, 3}}
+
+	// source names are hashed in lower case, as chains name themselves
+	a, _, err := MessageHash("Substrate", msg)
+	require.NoError(t, err)
+	b, _, err := MessageHash("substrate", msg)
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	c, _, err := MessageHash("ethereum", msg)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	// other payloads are SCALE-encoded
+	call := struct {
+		Data  []byte
+		Nonce uint64
+	}{Data: []byte{1}, Nonce: 7}
+	expected, err := types.EncodeToBytes(call)
+	require.NoError(t, err)
+
+	_, payload, err := MessageHash("ethereum", &Message{Payload: call})
+	require.NoError(t, err)
+	assert.Equal(t, expected, payload)
+}
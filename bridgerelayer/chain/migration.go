@@ -0,0 +1,57 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PayloadSchemaVersion is the version of the schema by which the listeners encode the payloads
+// of messages. It is raised whenever a payload encoding changes, along with a migration from
+// the previous version, so that messages recorded by an earlier relayer can be re-encoded.
+const PayloadSchemaVersion = 1
+
+// MigratePayload re-encodes the canonical payload of a message from a source chain to an app,
+// from the previous version of the schema to the version it is registered for
+type MigratePayload func(source string, appID [20]byte, payload []byte) ([]byte, error)
+
+var (
+	migrationsMutex sync.RWMutex
+	// migrations to each schema version from the previous one
+	migrations = map[int]MigratePayload{}
+)
+
+// RegisterPayloadMigration registers the migration of payloads to a schema version
+func RegisterPayloadMigration(version int, migrate MigratePayload) {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	migrations[version] = migrate
+}
+
+// MigratePayloadVersion re-encodes a payload from one schema version to a later one, applying
+// the migration to each version in between. Payloads are returned unchanged if the versions
+// are equal, and an error if a migration is missing.
+func MigratePayloadVersion(source string, appID [20]byte, payload []byte, from int, to int) ([]byte, error) {
+	if from > to {
+		return nil, fmt.Errorf("payload schema version %d is newer than version %d", from, to)
+	}
+
+	migrationsMutex.RLock()
+	defer migrationsMutex.RUnlock()
+
+	for version := from + 1; version <= to; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration of payloads to schema version %d", version)
+		}
+
+		var err error
+		payload, err = migrate(source, appID, payload)
+		if err != nil {
+			return nil, fmt.Errorf("migrating payload to schema version %d: %w", version, err)
+		}
+	}
+	return payload, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/ss58"
+)
+
+// OverrideConfig overrides the delivery of the messages to a set of recipients, such as
+// the deposit addresses of an exchange with a tighter SLA than other transfers
+type OverrideConfig struct {
+	// Name of the override in logs, metrics and the lane of its messages
+	Name string `mapstructure:"name"`
+	// Ethereum addresses, Substrate SS58 addresses or hex-encoded Substrate account IDs
+	Recipients []string `mapstructure:"recipients"`
+	// Whether the messages skip the rate limit of their lane and are submitted escalated
+	Priority bool `mapstructure:"priority"`
+	// Confirmations which the block of an Ethereum event must have in addition to the
+	// finality depth of its network before the event is relayed. Substrate events are
+	// relayed once their block is finalized.
+	Confirmations uint64 `mapstructure:"confirmations"`
+	// Bounds on the submission of the messages, which get a lane of their own if set or
+	// if a budget is set. Otherwise they share the lane of their app.
+	Throttle *ThrottleConfig `mapstructure:"throttle"`
+	// Latency budget of the messages, measured from when their event was observed
+	Budget *BudgetConfig `mapstructure:"budget"`
+}
+
+// recipientFields are the names of the event fields holding the recipient of a transfer
+var recipientFields = []string{"_recipient", "recipient"}
+
+// overrideLanePrefix precedes the name of an override in the name of its lane
+const overrideLanePrefix = "override:"
+
+// Override is the delivery of the messages to the recipients of an override
+type Override struct {
+	Name          string
+	Priority      bool
+	Confirmations uint64
+}
+
+// Overrides match the recipients of observed events against the configured overrides.
+// A nil Overrides matches nothing.
+type Overrides struct {
+	// overrides keyed by the lower case hex address or account ID of their recipients
+	recipients map[string]*Override
+}
+
+// NewOverrides returns nil if no override is configured. A recipient may only belong to
+// one override, so that the delivery of its messages is unambiguous.
+func NewOverrides(configs []OverrideConfig) (*Overrides, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	ov := &Overrides{recipients: make(map[string]*Override)}
+	names := make(map[string]bool)
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("missing name of routing override")
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate routing override %s", config.Name)
+		}
+		names[config.Name] = true
+		if len(config.Recipients) == 0 {
+			return nil, fmt.Errorf("routing override %s has no recipients", config.Name)
+		}
+
+		override := &Override{
+			Name:          config.Name,
+			Priority:      config.Priority,
+			Confirmations: config.Confirmations,
+		}
+		for _, recipient := range config.Recipients {
+			key, err := AccountKey(recipient)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient of routing override %s: %w", config.Name, err)
+			}
+			if other, ok := ov.recipients[key]; ok {
+				return nil, fmt.Errorf("recipient %s of routing override %s also belongs to %s", recipient, config.Name, other.Name)
+			}
+			ov.recipients[key] = override
+		}
+	}
+
+	return ov, nil
+}
+
+// Match returns the override of the recipient among the decoded fields of an event, nil
+// if the recipient has none
+func (ov *Overrides) Match(fields map[string]interface{}) *Override {
+	if ov == nil {
+		return nil
+	}
+
+	for _, name := range recipientFields {
+		text, ok := fields[name].(string)
+		if !ok {
+			continue
+		}
+		if override, ok := ov.recipients[strings.ToLower(text)]; ok {
+			return override
+		}
+	}
+	return nil
+}
+
+// Apply routes a message through an override, which may be nil
+func (o *Override) Apply(msg *Message) {
+	if o == nil {
+		return
+	}
+	msg.Override = o.Name
+	msg.Priority = o.Priority
+}
+
+// AccountKey returns the lower case hex of an Ethereum address, a hex-encoded Substrate
+// account ID or the account ID of an SS58 address, on any network
+func AccountKey(address string) (string, error) {
+	if strings.HasPrefix(address, "0x") {
+		data, err := hexutil.Decode(address)
+		if err != nil {
+			return "", err
+		}
+		if len(data) != 20 && len(data) != 32 {
+			return "", fmt.Errorf("%s is neither an Ethereum address nor a Substrate account ID", address)
+		}
+		return hexutil.Encode(data), nil
+	}
+
+	publicKey, _, err := ss58.Decode(address)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(publicKey), nil
+}
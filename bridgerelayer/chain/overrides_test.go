@@ -0,0 +1,71 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestOverrides_Match(t *testing.T) {
+	overrides, err := chain.NewOverrides([]chain.OverrideConfig{
+		{Name: "exchange", Recipients: []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"}, Priority: true},
+		{Name: "custody", Recipients: []string{"0x89B4AB1EF20763630DF9743ACF155865600DAFF2"}, Confirmations: 6},
+	})
+	require.NoError(t, err)
+
+	// recipients are matched by account, whichever way they are written
+	override := overrides.Match(map[string]interface{}{
+		"recipient": "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d",
+	})
+	require.NotNil(t, override)
+	assert.Equal(t, "exchange", override.Name)
+	assert.True(t, override.Priority)
+
+	override = overrides.Match(map[string]interface{}{
+		"_recipient": "0x89b4ab1ef20763630df9743acf155865600daff2",
+	})
+	require.NotNil(t, override)
+	assert.Equal(t, uint64(6), override.Confirmations)
+
+	var msg chain.Message
+	override.Apply(&msg)
+	assert.Equal(t, "custody", msg.Override)
+	assert.False(t, msg.Priority)
+
+	// only the recipient is matched, not the sender
+	assert.Nil(t, overrides.Match(map[string]interface{}{
+		"_sender": "0x89b4ab1ef20763630df9743acf155865600daff2",
+	}))
+
+	var none *chain.Overrides
+	assert.Nil(t, none.Match(map[string]interface{}{"recipient": "0x00"}))
+	none.Match(nil).Apply(&msg)
+}
+
+func TestNewOverrides(t *testing.T) {
+	overrides, err := chain.NewOverrides(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, overrides)
+
+	_, err = chain.NewOverrides([]chain.OverrideConfig{{Recipients: []string{"0x89b4ab1ef20763630df9743acf155865600daff2"}}})
+	assert.Error(t, err)
+
+	_, err = chain.NewOverrides([]chain.OverrideConfig{{Name: "exchange"}})
+	assert.Error(t, err)
+
+	_, err = chain.NewOverrides([]chain.OverrideConfig{{Name: "exchange", Recipients: []string{"0x1234"}}})
+	assert.Error(t, err)
+
+	// a recipient belongs to one override only
+	_, err = chain.NewOverrides([]chain.OverrideConfig{
+		{Name: "exchange", Recipients: []string{"0x89b4ab1ef20763630df9743acf155865600daff2"}},
+		{Name: "custody", Recipients: []string{"0x89B4AB1EF20763630DF9743ACF155865600DAFF2"}},
+	})
+	assert.Error(t, err)
+}
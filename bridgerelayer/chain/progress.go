@@ -0,0 +1,67 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// Progress tracks how far a listener has processed its chain
+type Progress struct {
+	chain     string
+	mutex     sync.Mutex
+	latest    uint64
+	processed uint64
+	updatedAt time.Time
+}
+
+// ProgressSnapshot is a point-in-time view of a listener's progress
+type ProgressSnapshot struct {
+	// Latest block known to the listener
+	Latest uint64
+	// Last block whose events were fully processed
+	Processed uint64
+	// Time of the last update, zero if the listener has not made progress yet
+	UpdatedAt time.Time
+}
+
+func NewProgress(chain string) *Progress {
+	return &Progress{chain: chain}
+}
+
+// Update records the latest known block and the last processed block, exporting both so
+// that the lag of the listener can be alerted on
+func (pr *Progress) Update(latest uint64, processed uint64) {
+	metrics.LatestBlock.WithLabelValues(pr.chain).Set(float64(latest))
+	metrics.ProcessedBlock.WithLabelValues(pr.chain).Set(float64(processed))
+
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	pr.latest = latest
+	pr.processed = processed
+	pr.updatedAt = time.Now()
+}
+
+func (pr *Progress) Snapshot() ProgressSnapshot {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	return ProgressSnapshot{
+		Latest:    pr.latest,
+		Processed: pr.processed,
+		UpdatedAt: pr.updatedAt,
+	}
+}
+
+// Lag returns the number of known blocks which have not been processed yet
+func (ps ProgressSnapshot) Lag() uint64 {
+	if ps.Processed >= ps.Latest {
+		return 0
+	}
+	return ps.Latest - ps.Processed
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+)
+
+// EventDomain separates the hashes of events from any other Keccak-256 hash
+var EventDomain = crypto.Keccak256Hash([]byte("artemis-relay/event"))
+
+// EventID returns the deterministic identifier of the event which generated a message,
+// derived from its source chain, block hash and event index, or an empty string if its
+// provenance is unknown. Unlike the message ID, which hashes the content of a message,
+// it tells apart events of identical content, and events of the same content at the same
+// height on either side of a reorg.
+func (msg *Message) EventID() string {
+	if msg.Source == "" || msg.SourceHash == "" {
+		return ""
+	}
+
+	index := make([]byte, 8)
+	binary.BigEndian.PutUint64(index, msg.EventIndex)
+	hash := crypto.Keccak256Hash(
+		EventDomain[:],
+		crypto.Keccak256([]byte(strings.ToLower(msg.Source))),
+		crypto.Keccak256([]byte(msg.SourceChainID)),
+		crypto.Keccak256([]byte(strings.ToLower(msg.SourceHash))),
+		index,
+	)
+	return hexutil.Encode(hash[:])
+}
+
+// Provenance identifies the event which generated a message, as recorded and archived
+// with the message
+type Provenance struct {
+	ChainID    string    `json:"chainId,omitempty"`
+	Block      uint64    `json:"block"`
+	BlockHash  string    `json:"blockHash"`
+	EventIndex uint64    `json:"eventIndex"`
+	EventID    string    `json:"eventId"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// Provenance returns the provenance of a message, nil if it is unknown
+func (msg *Message) Provenance() *Provenance {
+	if msg.SourceHash == "" {
+		return nil
+	}
+	return &Provenance{
+		ChainID:    msg.SourceChainID,
+		Block:      msg.SourceBlock,
+		BlockHash:  msg.SourceHash,
+		EventIndex: msg.EventIndex,
+		EventID:    msg.EventID(),
+		ObservedAt: msg.ObservedAt,
+	}
+}
+
+// LogFields returns the identity and provenance of a message as log fields, omitting those
+// which are unknown
+func (msg *Message) LogFields() logrus.Fields {
+	fields := logrus.Fields{}
+	if msg.ID != "" {
+		fields["messageID"] = msg.ID
+		fields["sequence"] = msg.Sequence
+	}
+	if msg.Source != "" {
+		fields["source"] = msg.Source
+	}
+	if msg.SourceChainID != "" {
+		fields["sourceChainID"] = msg.SourceChainID
+	}
+	if msg.SourceBlock != 0 {
+		fields["sourceBlock"] = msg.SourceBlock
+	}
+	if msg.SourceHash != "" {
+		fields["sourceHash"] = msg.SourceHash
+		fields["eventIndex"] = msg.EventIndex
+		fields["eventID"] = msg.EventID()
+	}
+	if !msg.ObservedAt.IsZero() {
+		fields["observedAt"] = msg.ObservedAt
+	}
+	return fields
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestMessage_EventID(t *testing.T) {
+	msg := chain.Message{
+		AppID:         [20]byte{1},
+		Payload:       []byte{1, 2, 3},
+		Source:        "Ethereum",
+		SourceChainID: "1",
+		SourceBlock:   1200,
+		SourceHash:    "0x9a07c4f5e0e5d3a1b6a0c1d2e3f405162738495a6b7c8d9e0f1a2b3c4d5e6f71",
+		EventIndex:    3,
+	}
+	id := msg.EventID()
+	assert.Len(t, id, 66)
+
+	// the ID depends on the provenance of the message alone
+	same := msg
+	same.Payload = []byte{4}
+	same.Source = "ethereum"
+	same.SourceHash = "0x9A07C4F5E0E5D3A1B6A0C1D2E3F405162738495A6B7C8D9E0F1A2B3C4D5E6F71"
+	assert.Equal(t, id, same.EventID())
+
+	for _, other := range []chain.Message{
+		{Source: "Ethereum", SourceChainID: "1", SourceHash: msg.SourceHash, EventIndex: 4},
+		{Source: "Ethereum", SourceChainID: "5", SourceHash: msg.SourceHash, EventIndex: 3},
+		{Source: "Substrate", SourceChainID: "1", SourceHash: msg.SourceHash, EventIndex: 3},
+		{Source: "Ethereum", SourceChainID: "1", SourceHash: "0x01", EventIndex: 3},
+	} {
+		assert.NotEqual(t, id, other.EventID(), "%+v", other)
+	}
+
+	assert.Empty(t, (&chain.Message{Source: "Ethereum", SourceBlock: 1200}).EventID())
+	assert.Nil(t, (&chain.Message{Source: "Ethereum", SourceBlock: 1200}).Provenance())
+}
+
+func TestMessage_Provenance(t *testing.T) {
+	observedAt := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	msg := chain.Message{
+		ID:            "6f1c",
+		Sequence:      7,
+		Payload:       []byte{1},
+		ObservedAt:    observedAt,
+		Source:        "Substrate",
+		SourceChainID: "0x0c",
+		SourceBlock:   1200,
+		SourceHash:    "0x01",
+		EventIndex:    2,
+	}
+
+	assert.Equal(t, &chain.Provenance{
+		ChainID:    "0x0c",
+		Block:      1200,
+		BlockHash:  "0x01",
+		EventIndex: 2,
+		EventID:    msg.EventID(),
+		ObservedAt: observedAt,
+	}, msg.Provenance())
+
+	fields := msg.LogFields()
+	assert.Equal(t, "6f1c", fields["messageID"])
+	assert.Equal(t, "Substrate", fields["source"])
+	assert.Equal(t, uint64(2), fields["eventIndex"])
+	assert.Equal(t, msg.EventID(), fields["eventID"])
+
+	// the provenance survives persistence
+	encoded, err := chain.EncodeMessage(&msg)
+	assert.NoError(t, err)
+	decoded, err := chain.DecodeMessage(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Provenance(), decoded.Provenance())
+	assert.Equal(t, "Substrate", decoded.Source)
+}
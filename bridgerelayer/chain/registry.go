@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Endpoints connect a chain to the router of the relayer
+type Endpoints struct {
+	// Messages observed by the listener of the chain
+	Observed chan Message
+	// Messages routed to the writer of the chain
+	Delivered chan Message
+}
+
+// ConfigDecoder decodes the configuration of a chain into the configuration type of its
+// factory
+type ConfigDecoder func(config interface{}) error
+
+// Factory creates a chain of a type, named as configured, from its configuration. Chains
+// which can't be created from their configuration are refused with an error.
+type Factory func(name string, decode ConfigDecoder, endpoints Endpoints, services *Services) (Chain, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = make(map[string]Factory)
+)
+
+// Register makes a chain type available to the configuration of the relayer, usually from
+// the init function of the package implementing it. Registering a type twice panics.
+func Register(chainType string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if factory == nil {
+		panic("chain: factory of chain type " + chainType + " is nil")
+	}
+	if _, ok := factories[chainType]; ok {
+		panic("chain: chain type " + chainType + " is registered twice")
+	}
+	factories[chainType] = factory
+}
+
+// Types returns the registered chain types in order
+func Types() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for chainType := range factories {
+		types = append(types, chainType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build creates a chain through the factory of its type
+func Build(chainType string, name string, decode ConfigDecoder, endpoints Endpoints, services *Services) (Chain, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[chainType]
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		registered := Types()
+		if len(registered) == 0 {
+			return nil, fmt.Errorf("chain %s has unknown type %q, no chain types are registered", name, chainType)
+		}
+		return nil, fmt.Errorf("chain %s has unknown type %q, the registered types are %s", name, chainType, strings.Join(registered, ", "))
+	}
+
+	ch, err := factory(name, decode, endpoints, services)
+	if err != nil {
+		return nil, fmt.Errorf("chain %s: %w", name, err)
+	}
+	return ch, nil
+}
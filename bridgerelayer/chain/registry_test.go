@@ -0,0 +1,72 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type registryChain struct {
+	name     string
+	endpoint string
+}
+
+func (rc *registryChain) Name() string {
+	return rc.name
+}
+
+func (rc *registryChain) Start(context.Context, *errgroup.Group) error {
+	return nil
+}
+
+func (rc *registryChain) Stop() {}
+
+func registryFactory(name string, decode chain.ConfigDecoder, _ chain.Endpoints, _ *chain.Services) (chain.Chain, error) {
+	var config struct {
+		Endpoint string
+	}
+	err := decode(&config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("no endpoint")
+	}
+	return &registryChain{name: name, endpoint: config.Endpoint}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	chain.Register("registry-test", registryFactory)
+	assert.Contains(t, chain.Types(), "registry-test")
+
+	assert.Panics(t, func() {
+		chain.Register("registry-test", registryFactory)
+	})
+
+	decode := func(config interface{}) error {
+		config.(*struct{ Endpoint string }).Endpoint = "ws://sidechain:8545"
+		return nil
+	}
+	ch, err := chain.Build("registry-test", "Sidechain", decode, chain.Endpoints{}, &chain.Services{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Sidechain", ch.Name())
+	assert.Equal(t, "ws://sidechain:8545", ch.(*registryChain).endpoint)
+
+	_, err = chain.Build("registry-test", "Sidechain", func(interface{}) error { return nil }, chain.Endpoints{}, &chain.Services{})
+	assert.EqualError(t, err, "chain Sidechain: no endpoint")
+
+	_, err = chain.Build("unknown", "Sidechain", decode, chain.Endpoints{}, &chain.Services{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown type "unknown"`)
+	assert.Contains(t, err.Error(), "registry-test")
+}
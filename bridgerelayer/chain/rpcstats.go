@@ -0,0 +1,185 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+type RPCConfig struct {
+	// Calls taking longer than this many milliseconds are logged with their parameters.
+	// Zero disables slow-call logging.
+	SlowCallThreshold uint64 `mapstructure:"slow-call-threshold"`
+	// Retries of calls which failed with a transient error
+	Backoff BackoffConfig `mapstructure:"backoff"`
+	// Switching between the endpoints of a chain which has fallback endpoints
+	Failover FailoverConfig `mapstructure:"failover"`
+	// Dialing of the connections to the endpoints
+	Dial DialConfig `mapstructure:"dial"`
+}
+
+// RPCStats records per-method latency and error statistics of the calls made to an endpoint.
+// A nil RPCStats records nothing.
+type RPCStats struct {
+	chain    string
+	endpoint string
+	slow     time.Duration
+	mutex    sync.Mutex
+	methods  map[string]*methodStats
+	// calls which failed since the last successful call, across all methods
+	consecutive int
+	log         *logrus.Entry
+}
+
+type methodStats struct {
+	calls   uint64
+	errors  uint64
+	total   time.Duration
+	max     time.Duration
+	last    time.Duration
+	lastErr string
+}
+
+// MethodStats is a point-in-time view of the calls made to an RPC method
+type MethodStats struct {
+	Method        string  `json:"method"`
+	Calls         uint64  `json:"calls"`
+	Errors        uint64  `json:"errors"`
+	ErrorRate     float64 `json:"errorRate"`
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+	MaxLatencyMs  float64 `json:"maxLatencyMs"`
+	// Latency of the latest call
+	LastLatencyMs float64 `json:"lastLatencyMs"`
+	LastError     string  `json:"lastError,omitempty"`
+}
+
+// EndpointStats is a point-in-time view of the calls made to an endpoint
+type EndpointStats struct {
+	Endpoint string        `json:"endpoint"`
+	Methods  []MethodStats `json:"methods"`
+}
+
+func NewRPCStats(chain string, endpoint string, config *RPCConfig, log *logrus.Entry) *RPCStats {
+	endpoint = RedactEndpoint(endpoint)
+	return &RPCStats{
+		chain:    chain,
+		endpoint: endpoint,
+		slow:     time.Duration(config.SlowCallThreshold) * time.Millisecond,
+		methods:  make(map[string]*methodStats),
+		log:      log.WithField("endpoint", endpoint),
+	}
+}
+
+// Start begins timing a call, returning a function which records its outcome
+func (rs *RPCStats) Start(method string, params ...interface{}) func(err error) {
+	started := time.Now()
+	return func(err error) {
+		rs.Observe(method, time.Since(started), err, params...)
+	}
+}
+
+// Observe records the outcome of a call
+func (rs *RPCStats) Observe(method string, latency time.Duration, err error, params ...interface{}) {
+	if rs == nil {
+		return
+	}
+
+	rs.mutex.Lock()
+	stats, ok := rs.methods[method]
+	if !ok {
+		stats = &methodStats{}
+		rs.methods[method] = stats
+	}
+	stats.calls++
+	stats.total += latency
+	stats.last = latency
+	if latency > stats.max {
+		stats.max = latency
+	}
+	if err != nil {
+		stats.errors++
+		stats.lastErr = err.Error()
+		rs.consecutive++
+	} else {
+		rs.consecutive = 0
+	}
+	rs.mutex.Unlock()
+
+	metrics.RPCCalls.WithLabelValues(rs.chain, rs.endpoint, method).Inc()
+	metrics.RPCLatency.WithLabelValues(rs.chain, rs.endpoint, method).Observe(latency.Seconds())
+	if err != nil {
+		metrics.RPCErrors.WithLabelValues(rs.chain, rs.endpoint, method).Inc()
+	}
+
+	if rs.slow > 0 && latency >= rs.slow {
+		rs.log.WithFields(logrus.Fields{
+			"method":   method,
+			"params":   params,
+			"duration": latency,
+			"error":    err,
+		}).Warn("Slow RPC call")
+	}
+}
+
+// ConsecutiveErrors returns the number of calls which failed since the last successful call
+func (rs *RPCStats) ConsecutiveErrors() int {
+	if rs == nil {
+		return 0
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return rs.consecutive
+}
+
+// Snapshot returns the statistics of each method, ordered by method name
+func (rs *RPCStats) Snapshot() EndpointStats {
+	if rs == nil {
+		return EndpointStats{Methods: []MethodStats{}}
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	snapshot := EndpointStats{Endpoint: rs.endpoint, Methods: []MethodStats{}}
+	for method, stats := range rs.methods {
+		snapshot.Methods = append(snapshot.Methods, MethodStats{
+			Method:        method,
+			Calls:         stats.calls,
+			Errors:        stats.errors,
+			ErrorRate:     float64(stats.errors) / float64(stats.calls),
+			MeanLatencyMs: milliseconds(stats.total) / float64(stats.calls),
+			MaxLatencyMs:  milliseconds(stats.max),
+			LastLatencyMs: milliseconds(stats.last),
+			LastError:     stats.lastErr,
+		})
+	}
+
+	sort.Slice(snapshot.Methods, func(i, j int) bool {
+		return snapshot.Methods[i].Method < snapshot.Methods[j].Method
+	})
+
+	return snapshot
+}
+
+// RedactEndpoint strips the credentials, path and query from an endpoint URL, as providers
+// commonly embed API keys in them
+func RedactEndpoint(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return "redacted"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// Services are the relayer facilities which chains report to
+type Services struct {
+	Quarantine Quarantine
+	Blocks     BlockLog
+	Receipts   ReceiptLog
+	// Optional, converts the fees recorded in receipts into the reporting currency
+	Pricer Pricer
+	// Optional, records the checkpoints verified by the listeners
+	Checkpoints CheckpointStore
+	// Optional, records the last block handled by each listener, from which it resumes
+	Cursors CursorStore
+	// Optional, notified of the bridge events observed by the listeners
+	Events EventFeed
+	// Optional, records the messages whose delivery the writers gave up
+	Skipped SkipList
+	// Optional, holds the messages whose delivery failed permanently until they are requeued.
+	// Writers record failed deliveries in the skip list instead if nil.
+	DeadLetters DeadLetterQueue
+	// Optional, closed once the consumer of the messages observed by the listeners stops
+	ConsumerStopped <-chan struct{}
+	// Optional, records the messages whose events were orphaned by a reorg
+	Invalidated Invalidator
+	// Optional, records the state of the writers captured while their queues backed up
+	Diagnostics DiagnosticLog
+	// Optional, records each transaction the writers are about to send before it is sent
+	Intents IntentLog
+}
+
+// Quarantine holds messages which were rejected before being queued for delivery,
+// so that they can be inspected instead of failing at submission time
+type Quarantine interface {
+	Quarantine(source string, msg *Message, reason string) error
+}
+
+// Invalidator records messages which were relayed for events of blocks that a reorg
+// replaced, so that they can be inspected, as the events no longer exist on the source chain
+type Invalidator interface {
+	Invalidate(source string, msg *Message, reason string) error
+}
+
+// SkipList records messages whose delivery was given up, so that they are not retried
+// and can be inspected
+type SkipList interface {
+	// Skip records a message by the ID which the message store assigned to it
+	Skip(msg *Message, reason string) error
+}
+
+// DeadLetterQueue holds messages whose delivery failed permanently, with the error of their
+// last attempt, so that they can be inspected and requeued instead of being dropped
+type DeadLetterQueue interface {
+	// DeadLetter records a message by the ID which the message store assigned to it, with
+	// the chain to which its delivery failed and the number of attempts made
+	DeadLetter(target string, msg *Message, err error, attempts int) error
+}
+
+// DeadLetter hands a message whose delivery failed to a dead-letter queue, if given. Failures
+// caused by the relayer shutting down are not recorded, as the delivery is resumed on restart.
+func DeadLetter(ctx context.Context, queue DeadLetterQueue, target string, msg *Message, err error, attempts int, log *logrus.Entry) {
+	if queue == nil || ctx.Err() != nil {
+		return
+	}
+
+	qerr := queue.DeadLetter(target, msg, err, attempts)
+	if qerr != nil {
+		log.WithError(qerr).WithField("messageID", msg.ID).Error("Failed to move message to the dead-letter queue")
+		return
+	}
+
+	log.WithFields(msg.LogFields()).WithField("attempts", attempts).Warn("Moved message to the dead-letter queue")
+}
+
+// BlockLog records which blocks of a chain have been fully processed, so that
+// blocks which were skipped can be detected and reprocessed
+type BlockLog interface {
+	MarkProcessed(source string, number uint64) error
+}
+
+// CheckpointStore persists the most recent verified checkpoint of each chain, so that it
+// survives restarts and is carried by store snapshots
+type CheckpointStore interface {
+	// LoadCheckpoint returns nil if no checkpoint was recorded
+	LoadCheckpoint(source string) (*CheckpointConfig, error)
+	SaveCheckpoint(source string, checkpoint *CheckpointConfig) error
+}
+
+// CursorStore persists the last block whose events a listener handled, so that a restarted
+// listener resumes from the block following it instead of the latest block, and messages
+// emitted while the relayer was down are not lost
+type CursorStore interface {
+	// LoadCursor returns false if no block was recorded for the chain
+	LoadCursor(source string) (uint64, bool, error)
+	SaveCursor(source string, number uint64) error
+}
+
+// SeedCursor records the block preceding a configured start block as the last handled block
+// of a chain which has no cursor yet, so that its listener starts from the start block. Zero
+// start blocks, or a nil store, leave the cursor as it is.
+func SeedCursor(cursors CursorStore, source string, start uint64) error {
+	if cursors == nil || start == 0 {
+		return nil
+	}
+
+	_, ok, err := cursors.LoadCursor(source)
+	if err != nil || ok {
+		return err
+	}
+	return cursors.SaveCursor(source, start-1)
+}
+
+// EventFeed is notified of each bridge event observed by a listener, once it was verified
+// and before it is relayed
+type EventFeed interface {
+	Observed(event *ObservedEvent)
+}
+
+// ObservedEvent is a bridge event with its fields decoded into JSON-friendly values.
+// Integers are represented as decimal strings and byte values in hex.
+type ObservedEvent struct {
+	Chain       string `json:"chain"`
+	App         string `json:"app"`
+	Name        string `json:"name"`
+	BlockNumber uint64 `json:"blockNumber"`
+	BlockHash   string `json:"blockHash"`
+	// Hash of the transaction which emitted the event, empty on Substrate
+	TxHash     string                 `json:"txHash,omitempty"`
+	Index      uint64                 `json:"index"`
+	Fields     map[string]interface{} `json:"fields"`
+	ObservedAt time.Time              `json:"observedAt"`
+	// Message generated for the event, nil if it could not be generated
+	Message *Message `json:"-"`
+}
+
+// IntentLog records the nonce of each transaction a writer is about to send from a relayer
+// account before the transaction is signed, so that transactions from the account which
+// the relayer did not originate stand out
+type IntentLog interface {
+	RecordIntent(name string, account string, nonce uint64) error
+}
+
+// ReceiptLog is notified of messages which were submitted to their target chain
+type ReceiptLog interface {
+	Submitted(msg *Message, receipt *Receipt)
+	// Confirmed is called once the submission was successfully included in a block
+	Confirmed(msg *Message, receipt *Receipt)
+}
+
+// Receipt records the delivery of a message to its target chain
+type Receipt struct {
+	Chain string `json:"chain"`
+	// Hash of the transaction, extrinsic or user operation carrying the message
+	Hash        string     `json:"hash"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	BlockNumber uint64     `json:"blockNumber,omitempty"`
+	BlockHash   string     `json:"blockHash,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+	// Fee paid for the delivery, in base units of the native asset of the chain
+	Fee string `json:"fee,omitempty"`
+	// Fee converted into the reporting currency, if pricing is enabled
+	Cost *Cost `json:"cost,omitempty"`
+	// Price per gas paid for an Ethereum delivery, and the highest price it offered, in wei
+	GasPrice     string `json:"gasPrice,omitempty"`
+	MaxFeePerGas string `json:"maxFeePerGas,omitempty"`
+}
+
+// Cost is an amount in the reporting currency
+type Cost struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// costDecimals is the precision of reported costs
+const costDecimals = 6
+
+// Pricer converts amounts of the native asset of each chain into a reporting currency
+type Pricer interface {
+	Currency() string
+	Convert(ctx context.Context, chain string, amount *big.Int) (*big.Rat, error)
+}
+
+// NativeAssets is implemented by pricers which learn the native asset of a chain from
+// the properties the chain reports
+type NativeAssets interface {
+	Discover(chain string, symbol string, decimals uint8)
+}
+
+// Charge records the fee paid for the delivery, along with its cost if a pricer is given
+func (r *Receipt) Charge(ctx context.Context, pricer Pricer, fee *big.Int) error {
+	r.Fee = fee.String()
+	if pricer == nil {
+		return nil
+	}
+
+	value, err := pricer.Convert(ctx, r.Chain, fee)
+	if err != nil {
+		return err
+	}
+
+	r.Cost = &Cost{Amount: value.FloatString(costDecimals), Currency: pricer.Currency()}
+	amount, _ := value.Float64()
+	metrics.DeliveryCost.WithLabelValues(r.Chain, pricer.Currency()).Add(amount)
+	return nil
+}
+
+// Confirm marks the receipt as confirmed by inclusion in a block
+func (r *Receipt) Confirm(blockNumber uint64, blockHash string) {
+	now := time.Now().UTC()
+	r.BlockNumber = blockNumber
+	r.BlockHash = blockHash
+	r.ConfirmedAt = &now
+}
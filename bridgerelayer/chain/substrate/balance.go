@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// setupBalance monitors the free balance of the relayer account, which must cover the fee
+// last estimated for an extrinsic of the writer, including its tip
+func (wr *Writer) setupBalance() error {
+	if wr.balance.Interval == 0 {
+		return nil
+	}
+
+	account := wr.conn.Properties().Address(wr.conn.Keypair().PublicKey)
+	monitor, err := chain.NewBalanceMonitor(Name, account, &wr.balance, wr.gate, wr.freeBalance, wr.nextFee, wr.log)
+	if err != nil {
+		return err
+	}
+	wr.balanceMonitor = monitor
+	return nil
+}
+
+// BalanceInterval returns the interval between checks of the balance, zero if it isn't
+// monitored
+func (wr *Writer) BalanceInterval() time.Duration {
+	return time.Duration(wr.balance.Interval) * time.Second
+}
+
+// CheckBalance checks the balance of the relayer account, unless the writer didn't start
+func (wr *Writer) CheckBalance(ctx context.Context) error {
+	if wr.balanceMonitor == nil {
+		return nil
+	}
+	return wr.balanceMonitor.Check(ctx)
+}
+
+// freeBalance reads the free balance of the relayer account, zero if the account has no
+// storage
+func (wr *Writer) freeBalance(ctx context.Context) (*big.Int, error) {
+	key, err := types.CreateStorageKey(wr.conn.Metadata(), "System", "Account", wr.conn.Keypair().PublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.AccountInfo
+	ok, err := wr.conn.Client().GetStorageLatest(ctx, key, &info)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Set(info.Data.Free.Int), nil
+}
+
+// recordFee records the fee estimated for an extrinsic, unless it couldn't be estimated
+func (wr *Writer) recordFee(fee *big.Int) {
+	if fee == nil {
+		return
+	}
+
+	wr.feeMutex.Lock()
+	defer wr.feeMutex.Unlock()
+	wr.lastFee = fee
+}
+
+// nextFee returns the fee last estimated for an extrinsic, nil until one is submitted
+func (wr *Writer) nextFee(ctx context.Context) (*big.Int, error) {
+	wr.feeMutex.Lock()
+	defer wr.feeMutex.Unlock()
+	return wr.lastFee, nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// call returns the call delivering a message. Messages are submitted to Bridge.submit,
+// unless their payload names a call of its own, whose index is resolved through the
+// runtime metadata and whose arguments are passed through already encoded.
+func (wr *Writer) call(msg *chain.Message) (types.Call, error) {
+	payload, ok := msg.Payload.(chain.Call)
+	if !ok {
+		return types.NewCall(wr.conn.Metadata(), "Bridge.submit", msg.AppID, msg.Payload)
+	}
+
+	index, err := wr.conn.Metadata().FindCallIndex(payload.Name)
+	if err != nil {
+		return types.Call{}, err
+	}
+
+	return types.Call{CallIndex: index, Args: payload.Args}, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestWriter_Call(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	conn := NewMockConnection(nil, MetadataExemplary, NewMockClient())
+	writer, err := NewWriter(&Config{}, conn, nil, nil, nil, logrus.NewEntry(logger))
+	require.NoError(t, err)
+
+	args := []byte{0x01, 0x02, 0x03}
+	c, err := writer.call(&chain.Message{Payload: chain.Call{Name: "Balances.transfer", Args: args}})
+	require.NoError(t, err)
+
+	index, err := MetadataExemplary.FindCallIndex("Balances.transfer")
+	require.NoError(t, err)
+	assert.Equal(t, types.Call{CallIndex: index, Args: args}, c)
+
+	// calls missing from the metadata are rejected
+	_, err = writer.call(&chain.Message{Payload: chain.Call{Name: "Unknown.call", Args: args}})
+	assert.Error(t, err)
+}
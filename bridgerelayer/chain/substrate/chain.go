@@ -5,10 +5,14 @@ package substrate
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/sr25519"
 )
@@ -17,39 +21,173 @@ type Chain struct {
 	config   *Config
 	listener *Listener
 	writer   *Writer
-	conn     *Connection
+	pause    *PauseWatcher
+	conn     Connection
+	// connection of the writer, the same as conn unless submissions have their own endpoint
+	submit Connection
+	// divergence monitor, nil if disabled, and the further endpoints it connects to
+	divergence *chain.DivergenceMonitor
+	references []Connection
+	pricer     chain.Pricer
 }
 
 const Name = "Substrate"
 
-func NewChain(config *Config, ethMessages chan chain.Message, subMessages chan chain.Message) (*Chain, error) {
+// the components of the chain implement the interfaces through which the relayer drives chains
+var (
+	_ chain.Chain    = &Chain{}
+	_ chain.Listener = &Listener{}
+	_ chain.Writer   = &Writer{}
+)
+
+func NewChain(config *Config, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
+	log := logrus.WithField("chain", Name)
+
+	// read-only chains sign nothing, so they don't need a key
+	kp := &signature.KeyringPair{}
+	if !config.ReadOnly || config.PrivateKey != "" {
+		// Generate keypair from secret
+		pair, err := sr25519.NewKeypairFromSeed(config.PrivateKey, "")
+		if err != nil {
+			return nil, err
+		}
+		kp = pair.AsKeyringPair()
+	}
+
+	dialer, err := chain.NewDialer(&config.RPC.Dial)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn Connection
+	if len(config.FallbackEndpoints) > 0 {
+		failover := NewFailoverConnection(append([]string{config.Endpoint}, config.FallbackEndpoints...), kp, &config.Properties, &config.RPC, log)
+		failover.DialThrough(dialer)
+		conn = failover
+	} else {
+		rpcConn := NewConnection(config.Endpoint, kp, &config.Properties, chain.NewRPCStats(Name, config.Endpoint, &config.RPC, log), log)
+		rpcConn.DialThrough(dialer)
+		conn = rpcConn
+	}
+
+	var submit Connection = conn
+	if !config.ReadOnly && config.SubmitEndpoint != "" && config.SubmitEndpoint != config.Endpoint {
+		submitConn := NewConnection(config.SubmitEndpoint, kp, &config.Properties, chain.NewRPCStats(Name, config.SubmitEndpoint, &config.RPC, log), log)
+		submitConn.DialThrough(dialer)
+		submit = submitConn
+	}
+
+	if config.LightClient.Endpoint != "" {
+		// the full node, if any, keeps serving submissions
+		var fullStats *chain.RPCStats
+		if config.Endpoint != "" {
+			fullStats = conn.Stats()
+		}
+		light := NewLightConnection(&config.LightClient, config.Endpoint, kp, &config.Properties,
+			chain.NewRPCStats(Name, config.LightClient.Endpoint, &config.RPC, log), fullStats, log)
+		light.DialThrough(dialer)
+		if submit == conn && (config.ReadOnly || config.Endpoint == "") {
+			submit = light
+		}
+		conn = light
+	}
+
+	return NewChainWithConnections(config, conn, submit, ethMessages, subMessages, services)
+}
+
+// NewChainWithConnection initializes a chain whose components share the given connection
+func NewChainWithConnection(config *Config, conn Connection, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
+	return NewChainWithConnections(config, conn, conn, ethMessages, subMessages, services)
+}
+
+// NewChainWithConnections initializes a chain whose writer submits extrinsics through
+// its own connection, while the other components read the chain through conn
+func NewChainWithConnections(config *Config, conn Connection, submit Connection, ethMessages chan chain.Message, subMessages chan chain.Message, services *chain.Services) (*Chain, error) {
 	log := logrus.WithField("chain", Name)
 
-	// Generate keypair from secret
-	kp, err := sr25519.NewKeypairFromSeed(config.PrivateKey, "")
+	err := chain.SeedCursor(services.Cursors, Name, config.StartBlock)
 	if err != nil {
 		return nil, err
 	}
 
-	conn := NewConnection(config.Endpoint, kp.AsKeyringPair(), log)
+	checkpointConfig, err := chain.LoadCheckpoint(&config.Checkpoint, services.Checkpoints, Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// finalized blocks are never reverted
+	checkpoint, err := chain.NewCheckpoint(checkpointConfig, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := NewEventRegistry(config.Events)
+	if err != nil {
+		return nil, err
+	}
 
 	listener := NewListener(
 		config,
 		conn,
 		subMessages,
+		services.Quarantine,
+		services.Blocks,
+		checkpoint,
+		services.Checkpoints,
+		services.Cursors,
+		services.Events,
+		services.ConsumerStopped,
 		log,
 	)
+	listener.Route(registry)
+
+	sealer, err := NewSealer(config.Encryption, config.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	listener.Encrypt(sealer)
+
+	overrides, err := chain.NewOverrides(config.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	listener.Override(overrides)
 
-	writer, err := NewWriter(conn, ethMessages, log)
+	writer, err := NewWriter(config, submit, ethMessages, services.Receipts, services.Pricer, log)
 	if err != nil {
 		return nil, err
 	}
+	writer.DivertFailures(services.DeadLetters)
+	writer.ReportBackpressure(services.Diagnostics)
+	writer.RecordIntents(services.Intents)
+
+	var pause *PauseWatcher
+	if config.Pause.Interval > 0 && !config.ReadOnly {
+		pause = NewPauseWatcher(&config.Pause, conn, writer.Gate(), log)
+	}
+
+	var divergence *chain.DivergenceMonitor
+	var references []Connection
+	if config.Divergence.Interval > 0 {
+		dialer, err := chain.NewDialer(&config.RPC.Dial)
+		if err != nil {
+			return nil, err
+		}
+		var sources []chain.HeadSource
+		sources, references = headSources(config, conn, submit, dialer, log)
+		divergence = chain.NewDivergenceMonitor(Name, &config.Divergence, sources, log)
+	}
 
 	return &Chain{
-		config:   config,
-		conn:     conn,
-		listener: listener,
-		writer:   writer,
+		config:     config,
+		divergence: divergence,
+		references: references,
+		conn:       conn,
+		submit:     submit,
+		listener:   listener,
+		writer:     writer,
+		pause:      pause,
+		pricer:     services.Pricer,
 	}, nil
 }
 
@@ -59,9 +197,47 @@ func (ch *Chain) Start(ctx context.Context, eg *errgroup.Group) error {
 		return err
 	}
 
-	err = ch.listener.Start(ctx, eg)
-	if err != nil {
-		return err
+	if ch.submit != ch.conn {
+		err = ch.submit.Connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// fees are priced in the native token of the chain unless another asset is configured
+	if assets, ok := ch.pricer.(chain.NativeAssets); ok {
+		props := ch.conn.Properties()
+		assets.Discover(Name, props.TokenSymbol, props.TokenDecimals)
+	}
+
+	// nothing is relayed to pallets expecting payloads of another schema
+	if ch.config.Handshake.Enabled {
+		_, err = ch.Handshake(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.config.WriteOnly {
+		logrus.WithField("chain", Name).Info("Listener is disabled in write-only mode")
+	} else {
+		err = ch.listener.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check the pause state before the writer submits anything
+	if ch.pause != nil {
+		err = ch.pause.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.config.ReadOnly {
+		logrus.WithField("chain", Name).Info("Writer is disabled in read-only mode")
+		return nil
 	}
 
 	err = ch.writer.Start(ctx, eg)
@@ -76,8 +252,130 @@ func (ch *Chain) Stop() {
 	if ch.conn != nil {
 		ch.conn.Close()
 	}
+	if ch.submit != nil && ch.submit != ch.conn {
+		ch.submit.Close()
+	}
+	for _, reference := range ch.references {
+		reference.Close()
+	}
 }
 
 func (ch *Chain) Name() string {
 	return Name
 }
+
+// Divergence returns the monitor comparing the heads served by the endpoints of the chain,
+// nil if disabled
+func (ch *Chain) Divergence() *chain.DivergenceMonitor {
+	return ch.divergence
+}
+
+// WriterGate returns the gate through which submissions to this chain can be halted
+func (ch *Chain) WriterGate() *chain.Gate {
+	return ch.writer.Gate()
+}
+
+// AccountNonces returns the nonce of each account from which the writer sends transactions,
+// none if the chain is read-only
+func (ch *Chain) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	if ch.config.ReadOnly {
+		return nil, nil
+	}
+	return ch.writer.AccountNonces(ctx)
+}
+
+// LastSubmission returns when the writer last completed a submission, zero if it never did
+func (ch *Chain) LastSubmission() time.Time {
+	return ch.writer.LastSubmission()
+}
+
+// BalanceInterval returns the interval between checks of the balance of the relayer
+// account, zero if it isn't monitored or the chain is read-only
+func (ch *Chain) BalanceInterval() time.Duration {
+	if ch.config.ReadOnly {
+		return 0
+	}
+	return ch.writer.BalanceInterval()
+}
+
+// CheckBalances checks the balance of the relayer account
+func (ch *Chain) CheckBalances(ctx context.Context) error {
+	return ch.writer.CheckBalance(ctx)
+}
+
+// RPCStats returns the call statistics of each endpoint used by this chain
+func (ch *Chain) RPCStats() []*chain.RPCStats {
+	stats := []*chain.RPCStats{ch.conn.Stats()}
+	if ch.submit != ch.conn {
+		stats = append(stats, ch.submit.Stats())
+	}
+	return stats
+}
+
+// WriterQueues returns the messages pending submission to each app on this chain
+func (ch *Chain) WriterQueues() []chain.QueueStats {
+	return ch.writer.Queues()
+}
+
+// Boost escalates the submission of a message queued in the writer
+func (ch *Chain) Boost(id string) (*chain.Boost, error) {
+	return ch.writer.Boost(id)
+}
+
+// Progress returns the block processing progress of this chain's listener
+func (ch *Chain) Progress() *chain.Progress {
+	return ch.listener.Progress()
+}
+
+// Remark records data on the chain in a System.remark extrinsic of the relayer account
+func (ch *Chain) Remark(ctx context.Context, data []byte) error {
+	return ch.writer.Remark(ctx, data)
+}
+
+// Repair reprocesses a range of blocks which were skipped by the listener
+func (ch *Chain) Repair(ctx context.Context, from uint64, to uint64) error {
+	return ch.listener.Repair(ctx, from, to)
+}
+
+// Replay re-emits the messages of the events of a range of blocks, which defaults to the
+// configured range, without moving the cursor of the listener. Returns the replayed range.
+func (ch *Chain) Replay(ctx context.Context, from uint64, to uint64) (uint64, uint64, error) {
+	if from == 0 {
+		from = ch.config.StartBlock
+	}
+	if to == 0 {
+		to = ch.config.EndBlock
+	}
+	if from == 0 {
+		return 0, 0, fmt.Errorf("no start block given for the replay of %s", Name)
+	}
+
+	last, err := ch.listener.Replay(ctx, from, to)
+	return from, last, err
+}
+
+// CanonicalBlock returns the number of the block with a hash, verifying that it is on the
+// canonical chain
+func (ch *Chain) CanonicalBlock(ctx context.Context, hash string) (uint64, error) {
+	blockHash, err := types.NewHashFromHexString(hash)
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := ch.conn.Client().GetHeader(ctx, blockHash)
+	if err != nil {
+		return 0, fmt.Errorf("block %s: %w", hash, err)
+	}
+
+	number := uint64(header.Number)
+	canonical, err := ch.conn.Client().GetBlockHash(ctx, number)
+	if err != nil {
+		return 0, err
+	}
+	return number, chain.VerifyCanonical(hash, number, canonical.Hex())
+}
+
+// EventMessage rebuilds the message which the listener generates for an event of a block
+func (ch *Chain) EventMessage(ctx context.Context, number uint64, index uint64) (*chain.Message, error) {
+	return ch.listener.EventMessage(ctx, number, index)
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestNewChainWithConnections(t *testing.T) {
+	read := NewMockConnection(nil, MetadataExemplary, NewMockClient())
+	submit := NewMockConnection(nil, MetadataExemplary, NewMockClient())
+
+	ch, err := NewChainWithConnections(&Config{}, read, submit, make(chan chain.Message), make(chan chain.Message), &chain.Services{})
+	require.NoError(t, err)
+
+	// only the writer submits through the submission connection
+	assert.Equal(t, Connection(read), ch.listener.conn)
+	assert.Equal(t, Connection(submit), ch.writer.conn)
+	assert.Len(t, ch.RPCStats(), 2)
+}
+
+func TestNewChain_ReadOnly(t *testing.T) {
+	config := &Config{
+		Endpoint:       "ws://localhost:9944",
+		SubmitEndpoint: "ws://localhost:9945",
+		Pause:          PauseConfig{Interval: 10},
+		ReadOnly:       true,
+	}
+
+	// read-only chains need no key, and don't connect to the submission endpoint
+	ch, err := NewChain(config, make(chan chain.Message), make(chan chain.Message), &chain.Services{})
+	require.NoError(t, err)
+	assert.Equal(t, ch.conn, ch.submit)
+	assert.Nil(t, ch.pause)
+}
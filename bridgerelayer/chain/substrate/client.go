@@ -0,0 +1,363 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	gethrpc "github.com/snowfork/go-substrate-rpc-client/gethrpc"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// Client is the part of the Substrate RPC API used by the relayer. Calls are abandoned
+// once their context is done.
+type Client interface {
+	GetBlockHash(ctx context.Context, number uint64) (types.Hash, error)
+	GetFinalizedHead(ctx context.Context) (types.Hash, error)
+	GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error)
+	GetHeaderLatest(ctx context.Context) (*types.Header, error)
+	// GetBlock returns a block with its extrinsics
+	GetBlock(ctx context.Context, hash types.Hash) (*types.SignedBlock, error)
+	GetStorage(ctx context.Context, key types.StorageKey, target interface{}, hash types.Hash) (bool, error)
+	GetStorageLatest(ctx context.Context, key types.StorageKey, target interface{}) (bool, error)
+	GetStorageRawLatest(ctx context.Context, key types.StorageKey) (*types.StorageDataRaw, error)
+	GetRuntimeVersionLatest(ctx context.Context) (*types.RuntimeVersion, error)
+	// GetRuntimeVersion returns the version of the runtime in the state of a block
+	GetRuntimeVersion(ctx context.Context, hash types.Hash) (*types.RuntimeVersion, error)
+	// GetMetadata returns the metadata of the runtime in the state of a block
+	GetMetadata(ctx context.Context, hash types.Hash) (*types.Metadata, error)
+	SubmitExtrinsic(ctx context.Context, ext types.Extrinsic) (types.Hash, error)
+	// SubmitAndWatchExtrinsic only uses the context to set up the subscription, which
+	// lasts until it is unsubscribed
+	SubmitAndWatchExtrinsic(ctx context.Context, ext types.Extrinsic) (ExtrinsicSubscription, error)
+	// SubscribeFinalizedHeads only uses the context to set up the subscription, which
+	// lasts until it is unsubscribed or dropped by the node
+	SubscribeFinalizedHeads(ctx context.Context) (HeaderSubscription, error)
+	// Call makes a raw call to methods without a typed wrapper
+	Call(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// ExtrinsicSubscription reports the status of a submitted extrinsic
+type ExtrinsicSubscription interface {
+	Chan() <-chan types.ExtrinsicStatus
+	Err() <-chan error
+	Unsubscribe()
+}
+
+// HeaderSubscription reports the headers of newly finalized blocks
+type HeaderSubscription interface {
+	Chan() <-chan types.Header
+	Err() <-chan error
+	Unsubscribe()
+}
+
+const (
+	dialTimeout      = 10 * time.Second
+	subscribeTimeout = 5 * time.Second
+)
+
+// rpcClient implements Client over the GSRPC transport. The typed calls of GSRPC don't
+// take a context, so they are made here with the same encoding, and the statistics of
+// all calls are recorded in stats, which may be nil.
+type rpcClient struct {
+	rpc *gethrpc.Client
+	// websocket connection streamed to rpc, nil unless dialed through a dialer
+	stream io.Closer
+	stats  *chain.RPCStats
+	// routing of the calls to a full node if the client is a light client, otherwise nil
+	light *lightRoutes
+}
+
+func dialClient(ctx context.Context, url string, stats *chain.RPCStats, dialer *chain.Dialer) (*rpcClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	cl, stream, err := dialRPC(ctx, url, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcClient{rpc: cl, stream: stream, stats: stats}, nil
+}
+
+// close closes the client, with the full node to which it falls back
+func (rc *rpcClient) close() {
+	// the client waits for the stream to end as it closes
+	if rc.stream != nil {
+		rc.stream.Close()
+	}
+	rc.rpc.Close()
+	if rc.light != nil && rc.light.full != nil {
+		rc.light.full.close()
+	}
+}
+
+func (rc *rpcClient) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	target := rc.route(method)
+	err := target.call(ctx, result, method, args...)
+	if err != nil && target == rc && rc.light != nil && rc.light.retries(ctx, method) {
+		rc.light.log.WithError(err).WithField("method", method).Debug("Light client failed to serve call, falling back to full node")
+		metrics.LightClientFallbacks.WithLabelValues(method, "failed").Inc()
+		return rc.light.full.call(ctx, result, method, args...)
+	}
+	return err
+}
+
+func (rc *rpcClient) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	done := rc.stats.Start(method, args...)
+	err := rc.rpc.CallContext(ctx, result, method, args...)
+	done(err)
+	return err
+}
+
+// callWithBlockHash appends the hash of the queried block to the arguments of a call,
+// or queries the latest block if hash is nil
+func (rc *rpcClient) callWithBlockHash(ctx context.Context, result interface{}, method string, hash *types.Hash, args ...interface{}) error {
+	if hash != nil {
+		hexHash, err := types.Hex(*hash)
+		if err != nil {
+			return err
+		}
+		args = append(args, hexHash)
+	}
+	return rc.Call(ctx, result, method, args...)
+}
+
+func (rc *rpcClient) callForHash(ctx context.Context, method string, args ...interface{}) (types.Hash, error) {
+	var res string
+	err := rc.Call(ctx, &res, method, args...)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return types.NewHashFromHexString(res)
+}
+
+func (rc *rpcClient) GetBlockHash(ctx context.Context, number uint64) (types.Hash, error) {
+	return rc.callForHash(ctx, "chain_getBlockHash", number)
+}
+
+func (rc *rpcClient) GetFinalizedHead(ctx context.Context) (types.Hash, error) {
+	return rc.callForHash(ctx, "chain_getFinalizedHead")
+}
+
+func (rc *rpcClient) GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error) {
+	return rc.getHeader(ctx, &hash)
+}
+
+func (rc *rpcClient) GetHeaderLatest(ctx context.Context) (*types.Header, error) {
+	return rc.getHeader(ctx, nil)
+}
+
+func (rc *rpcClient) getHeader(ctx context.Context, hash *types.Hash) (*types.Header, error) {
+	var header types.Header
+	err := rc.callWithBlockHash(ctx, &header, "chain_getHeader", hash)
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+func (rc *rpcClient) GetBlock(ctx context.Context, hash types.Hash) (*types.SignedBlock, error) {
+	var block types.SignedBlock
+	err := rc.callWithBlockHash(ctx, &block, "chain_getBlock", &hash)
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (rc *rpcClient) GetStorage(ctx context.Context, key types.StorageKey, target interface{}, hash types.Hash) (bool, error) {
+	return rc.getStorage(ctx, key, target, &hash)
+}
+
+func (rc *rpcClient) GetStorageLatest(ctx context.Context, key types.StorageKey, target interface{}) (bool, error) {
+	return rc.getStorage(ctx, key, target, nil)
+}
+
+// getStorage decodes a storage item into target, returning false if it is empty
+func (rc *rpcClient) getStorage(ctx context.Context, key types.StorageKey, target interface{}, hash *types.Hash) (bool, error) {
+	raw, err := rc.getStorageRaw(ctx, key, hash)
+	if err != nil {
+		return false, err
+	}
+	if len(*raw) == 0 {
+		return false, nil
+	}
+	return true, types.DecodeFromBytes(*raw, target)
+}
+
+func (rc *rpcClient) GetStorageRawLatest(ctx context.Context, key types.StorageKey) (*types.StorageDataRaw, error) {
+	return rc.getStorageRaw(ctx, key, nil)
+}
+
+func (rc *rpcClient) getStorageRaw(ctx context.Context, key types.StorageKey, hash *types.Hash) (*types.StorageDataRaw, error) {
+	var res string
+	err := rc.callWithBlockHash(ctx, &res, "state_getStorage", hash, key.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := types.HexDecodeString(res)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := types.NewStorageDataRaw(data)
+	return &raw, nil
+}
+
+func (rc *rpcClient) GetRuntimeVersionLatest(ctx context.Context) (*types.RuntimeVersion, error) {
+	return rc.getRuntimeVersion(ctx, nil)
+}
+
+func (rc *rpcClient) GetRuntimeVersion(ctx context.Context, hash types.Hash) (*types.RuntimeVersion, error) {
+	return rc.getRuntimeVersion(ctx, &hash)
+}
+
+func (rc *rpcClient) getRuntimeVersion(ctx context.Context, hash *types.Hash) (*types.RuntimeVersion, error) {
+	var version types.RuntimeVersion
+	err := rc.callWithBlockHash(ctx, &version, "state_getRuntimeVersion", hash)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (rc *rpcClient) GetMetadata(ctx context.Context, hash types.Hash) (*types.Metadata, error) {
+	return rc.getMetadata(ctx, &hash)
+}
+
+// getMetadataLatest fetches the runtime metadata of the latest block
+func (rc *rpcClient) getMetadataLatest(ctx context.Context) (*types.Metadata, error) {
+	return rc.getMetadata(ctx, nil)
+}
+
+func (rc *rpcClient) getMetadata(ctx context.Context, hash *types.Hash) (*types.Metadata, error) {
+	var res string
+	err := rc.callWithBlockHash(ctx, &res, "state_getMetadata", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata types.Metadata
+	err = types.DecodeFromHexString(res, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// getProperties fetches the properties of the chain spec. They are decoded here from JSON,
+// as GSRPC decodes them from SCALE.
+func (rc *rpcClient) getProperties(ctx context.Context) (Properties, error) {
+	var raw json.RawMessage
+	err := rc.Call(ctx, &raw, "system_properties")
+	if err != nil {
+		return Properties{}, err
+	}
+	return parseProperties(raw)
+}
+
+func (rc *rpcClient) SubmitExtrinsic(ctx context.Context, ext types.Extrinsic) (types.Hash, error) {
+	enc, err := types.EncodeToHexString(ext)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return rc.callForHash(ctx, "author_submitExtrinsic", enc)
+}
+
+func (rc *rpcClient) SubmitAndWatchExtrinsic(ctx context.Context, ext types.Extrinsic) (ExtrinsicSubscription, error) {
+	enc, err := types.EncodeToHexString(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	if target := rc.route("author_submitAndWatchExtrinsic"); target != rc {
+		return target.SubmitAndWatchExtrinsic(ctx, ext)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+
+	channel := make(chan types.ExtrinsicStatus)
+
+	done := rc.stats.Start("author_submitAndWatchExtrinsic", enc)
+	sub, err := rc.rpc.Subscribe(ctx, "author", "submitAndWatchExtrinsic", "unwatchExtrinsic", "extrinsicUpdate", channel, enc)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &extrinsicSubscription{sub: sub, channel: channel}, nil
+}
+
+// extrinsicSubscription is the subscription to the status of an extrinsic, as in GSRPC
+type extrinsicSubscription struct {
+	sub      *gethrpc.ClientSubscription
+	channel  chan types.ExtrinsicStatus
+	quitOnce sync.Once
+}
+
+func (es *extrinsicSubscription) Chan() <-chan types.ExtrinsicStatus {
+	return es.channel
+}
+
+func (es *extrinsicSubscription) Err() <-chan error {
+	return es.sub.Err()
+}
+
+func (es *extrinsicSubscription) Unsubscribe() {
+	es.sub.Unsubscribe()
+	es.quitOnce.Do(func() {
+		close(es.channel)
+	})
+}
+
+func (rc *rpcClient) SubscribeFinalizedHeads(ctx context.Context) (HeaderSubscription, error) {
+	if target := rc.route("chain_subscribeFinalizedHeads"); target != rc {
+		return target.SubscribeFinalizedHeads(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+
+	channel := make(chan types.Header)
+
+	done := rc.stats.Start("chain_subscribeFinalizedHeads")
+	sub, err := rc.rpc.Subscribe(ctx, "chain", "subscribeFinalizedHeads", "unsubscribeFinalizedHeads", "finalizedHead", channel)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &headerSubscription{sub: sub, channel: channel}, nil
+}
+
+// headerSubscription is the subscription to finalized heads, as in GSRPC
+type headerSubscription struct {
+	sub      *gethrpc.ClientSubscription
+	channel  chan types.Header
+	quitOnce sync.Once
+}
+
+func (hs *headerSubscription) Chan() <-chan types.Header {
+	return hs.channel
+}
+
+func (hs *headerSubscription) Err() <-chan error {
+	return hs.sub.Err()
+}
+
+func (hs *headerSubscription) Unsubscribe() {
+	hs.sub.Unsubscribe()
+	hs.quitOnce.Do(func() {
+		close(hs.channel)
+	})
+}
@@ -1,7 +1,108 @@
 package substrate
 
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
 type Config struct {
 	Endpoint   string `mapstructure:"endpoint"`
 	PrivateKey string `mapstructure:"private-key"`
 	Targets    map[string][20]byte
+	// Ethereum network of each target app, keyed by app name, absent for the apps of the
+	// network of the ethereum section
+	Networks map[string]string
+	// Endpoints serving the same chain as Endpoint, in order of preference, to which the
+	// relayer fails over when Endpoint fails, and from which it fails back once Endpoint
+	// recovers. Submissions fail over too unless SubmitEndpoint is set. A light client
+	// only falls back to Endpoint.
+	FallbackEndpoints []string `mapstructure:"fallback-endpoints"`
+	// Endpoint through which extrinsics are submitted, while all other calls go to
+	// Endpoint. Endpoint is used for submissions too if empty.
+	SubmitEndpoint string `mapstructure:"submit-endpoint"`
+	// Light client from which the chain is read instead of Endpoint, which then only serves
+	// the calls that the light client can't, and submissions unless SubmitEndpoint is set
+	LightClient LightClientConfig `mapstructure:"light-client"`
+	// Comparison of the heads served by the endpoints of the chain
+	Divergence chain.DivergenceConfig `mapstructure:"divergence"`
+	// Events of pallets relayed to each Ethereum app, keyed by app name, in addition to
+	// the transfers of the eth and erc20 apps
+	Events map[string][]EventRoute `mapstructure:"events"`
+	// Limits of each target app on Ethereum, keyed by app name
+	Limits map[string]Limits `mapstructure:"limits"`
+	// Encryption of the payloads relayed to each Ethereum app, keyed by app name. The
+	// payloads of other apps are relayed in the clear.
+	Encryption map[string]EncryptionConfig `mapstructure:"encryption"`
+	// Secret from which the ephemeral keys of encrypted payloads are derived, read from
+	// ARTEMIS_PAYLOAD_KEY
+	EncryptionKey string
+	// Routing overrides of the messages to their recipients, copied from the overrides
+	// section of the configuration
+	Overrides []chain.OverrideConfig
+	// Bounds on the submission of messages from each Ethereum app, keyed by app name.
+	// Unthrottled apps share a single lane.
+	Throttle map[string]chain.ThrottleConfig `mapstructure:"throttle"`
+	// Latency budget of the messages for each Ethereum app, keyed by app name
+	Budget map[string]chain.BudgetConfig `mapstructure:"budget"`
+	// Cap on the estimated cost of the extrinsic of each message
+	CostCap CostCapConfig `mapstructure:"cost-cap"`
+	// Monitoring of the balance of the relayer account
+	Balance chain.BalanceConfig `mapstructure:"balance"`
+	// Tip in base units paid by extrinsics escalated to meet the latency budget of their message
+	EscalationTip uint64            `mapstructure:"escalation-tip"`
+	Clock         chain.ClockConfig `mapstructure:"clock"`
+	Pause         PauseConfig       `mapstructure:"pause"`
+	RPC           chain.RPCConfig   `mapstructure:"rpc"`
+	// Trusted block from which finalized blocks must descend. Disabled if unset.
+	Checkpoint chain.CheckpointConfig `mapstructure:"checkpoint"`
+	Properties PropertiesConfig       `mapstructure:"properties"`
+	// Tuning of the extrinsics pending inclusion and their rate to the capacity of the chain
+	AutoTune chain.AutoTuneConfig `mapstructure:"auto-tune"`
+	// Capture of the state of the writer once its queues back up
+	Diagnostics chain.DiagnosticsConfig `mapstructure:"diagnostics"`
+	// Negotiation of the payload schema version with the app pallets at startup
+	Handshake HandshakeConfig `mapstructure:"handshake"`
+	// Whether the listener prefetches the next block from the best chain while waiting for
+	// it to finalize
+	Prefetch bool `mapstructure:"prefetch"`
+	// Block from which the listener starts if it has no recorded cursor, and the first block
+	// replayed by default. The listener starts from the latest block if zero.
+	StartBlock uint64 `mapstructure:"start-block"`
+	// Last block replayed by default, the latest block if zero
+	EndBlock uint64 `mapstructure:"end-block"`
+	// Whether the writer is disabled, so that nothing is submitted and no key is needed.
+	// Set in explorer mode.
+	ReadOnly bool
+	// Whether the listener is disabled, so that no events of the chain are relayed. Set when
+	// only the direction to the chain is relayed.
+	WriteOnly bool
+}
+
+// PauseConfig enables halting the writer while a boolean pause flag is set in pallet storage
+type PauseConfig struct {
+	// Interval in seconds between checks. Zero disables the watcher.
+	Interval uint64 `mapstructure:"interval"`
+	// Pallet and storage item of the flag, for example Bridge and Paused
+	Module  string `mapstructure:"module"`
+	Storage string `mapstructure:"storage"`
+}
+
+// CostCapConfig caps the estimated cost of each message, above which its extrinsic isn't
+// submitted
+type CostCapConfig struct {
+	// Weight estimated with payment_queryInfo. Zero disables the cap.
+	MaxWeight uint64 `mapstructure:"max-weight"`
+	// Fee in base units estimated with payment_queryInfo, including any tip. Disabled if
+	// empty.
+	MaxFee string `mapstructure:"max-fee"`
+	// The same fee in the reporting currency of the pricing section, as a decimal. Disabled
+	// if empty.
+	MaxCost string `mapstructure:"max-cost"`
+}
+
+// Limits bound the events which are accepted for relaying to an Ethereum app, so that
+// messages exceeding the limits of Ethereum are rejected before being queued.
+// Zero values disable a limit.
+type Limits struct {
+	// Maximum size in bytes of the message payload
+	MaxPayloadSize int `mapstructure:"max-payload-size"`
 }
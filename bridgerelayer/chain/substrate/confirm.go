@@ -0,0 +1,252 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/go-substrate-rpc-client/blake2b"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// time after which the writer stops waiting for an extrinsic to be finalized
+const confirmTimeout = 30 * time.Minute
+
+// deliveryAttempts bounds the deliveries of a message whose extrinsics fail on-chain or
+// are not included, after which it is moved to the dead-letter queue
+const deliveryAttempts = 3
+
+// confirm follows the status of a submitted extrinsic, tuning throughput by the delay until
+// it is included in a block. Once it is finalized, the successful delivery is reported to
+// the receipt log, charged with the fee if known. Extrinsics which fail on-chain or are not
+// included are delivered again, until the attempts run out.
+func (wr *Writer) confirm(ctx context.Context, sub ExtrinsicSubscription, msg chain.Message, receipt chain.Receipt, hash types.Hash, fee *big.Int, delivery int) {
+	defer sub.Unsubscribe()
+
+	timeout := time.NewTimer(confirmTimeout)
+	defer timeout.Stop()
+
+	log := wr.log.WithFields(logrus.Fields{
+		"hash":     receipt.Hash,
+		"sequence": msg.Sequence,
+	})
+
+	// whether the extrinsic was reported to the throughput tuner as included
+	included := false
+	include := func() {
+		if !included {
+			included = true
+			metrics.TransactionsConfirmed.WithLabelValues(Name, wr.app(&msg)).Inc()
+			wr.throughput.Included(time.Since(receipt.SubmittedAt))
+		}
+	}
+	forget := func() {
+		if !included {
+			wr.throughput.Forget()
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			forget()
+			return
+		case <-timeout.C:
+			log.Warn("Gave up waiting for delivery to be confirmed")
+			if !included {
+				wr.throughput.Dropped()
+			}
+			return
+		case err := <-sub.Err():
+			log.WithError(err).Error("Lost track of submitted extrinsic")
+			forget()
+			return
+		case status := <-sub.Chan():
+			switch {
+			case status.IsInBlock:
+				include()
+				if wr.receipts == nil {
+					return
+				}
+			case status.IsFinalized:
+				include()
+				if wr.receipts == nil {
+					return
+				}
+
+				var header *types.Header
+				var failure error
+				err := wr.backoff.Retry(ctx, log, "look up finalized extrinsic", func() error {
+					var err error
+					header, err = wr.conn.Client().GetHeader(ctx, status.AsFinalized)
+					if err != nil {
+						return err
+					}
+					failure, err = wr.dispatchFailure(ctx, status.AsFinalized, hash)
+					return err
+				})
+				if err != nil {
+					// the message stays pending, and is delivered again once the relay restarts
+					log.WithError(err).Error("Failed to look up finalized extrinsic")
+					return
+				}
+
+				if failure != nil {
+					metrics.TransactionsFailed.WithLabelValues(Name, wr.app(&msg)).Inc()
+					log.WithError(failure).WithField("blockNumber", header.Number).Error("Delivery failed on-chain")
+					wr.redeliver(ctx, msg, delivery, failure)
+					return
+				}
+
+				receipt.Confirm(uint64(header.Number), status.AsFinalized.Hex())
+				if fee != nil {
+					err = receipt.Charge(ctx, wr.pricer, fee)
+					if err != nil {
+						log.WithError(err).Warn("Failed to convert delivery fee")
+					}
+				}
+				log.WithFields(logrus.Fields{
+					"blockNumber": receipt.BlockNumber,
+					"blockHash":   receipt.BlockHash,
+				}).Info("Delivery confirmed")
+
+				wr.receipts.Confirmed(&msg, &receipt)
+				return
+			case status.IsDropped, status.IsInvalid, status.IsUsurped, status.IsFinalityTimeout:
+				log.WithField("status", status).Error("Extrinsic was not included")
+				if !included {
+					metrics.TransactionsFailed.WithLabelValues(Name, wr.app(&msg)).Inc()
+					wr.throughput.Dropped()
+				}
+				// the nonce of the extrinsic is left unused, and is reused by the next one
+				wr.resetNonce()
+				wr.redeliver(ctx, msg, delivery, fmt.Errorf("extrinsic was not included: %s", extrinsicStatus(status)))
+				return
+			}
+		}
+	}
+}
+
+// redeliver submits a message again after its delivery failed, or moves it to the
+// dead-letter queue once its deliveries are exhausted
+func (wr *Writer) redeliver(ctx context.Context, msg chain.Message, delivery int, cause error) {
+	if delivery >= deliveryAttempts {
+		wr.log.WithFields(msg.LogFields()).WithError(cause).WithField("deliveries", delivery).Error("Giving up delivery of message")
+		chain.DeadLetter(ctx, wr.deadLetters, Name, &msg, cause, delivery, wr.log)
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(wr.backoff.Delay(delivery)):
+	}
+
+	wr.log.WithFields(msg.LogFields()).WithField("delivery", delivery+1).Info("Delivering message again")
+	wr.deliver(ctx, &msg, false, delivery+1)
+}
+
+// dispatchFailure returns the error with which the dispatch of an extrinsic included in a
+// block failed, or nil if it succeeded
+func (wr *Writer) dispatchFailure(ctx context.Context, blockHash types.Hash, hash types.Hash) (error, error) {
+	block, err := wr.conn.Client().GetBlock(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, ext := range block.Block.Extrinsics {
+		extHash, err := extrinsicHash(ext)
+		if err != nil {
+			return nil, chain.Permanent(err)
+		}
+		if extHash == hash {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("extrinsic %s not found in block %s", hash.Hex(), blockHash.Hex())
+	}
+
+	key, err := types.CreateStorageKey(wr.conn.Metadata(), "System", "Events", nil, nil)
+	if err != nil {
+		return nil, chain.Permanent(err)
+	}
+
+	var records types.EventRecordsRaw
+	_, err = wr.conn.Client().GetStorage(ctx, key, &records, blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := NewEventDecoder(wr.conn.Metadata()).Decode(records)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if !event.Phase.IsApplyExtrinsic || event.Phase.AsApplyExtrinsic != uint32(index) {
+			continue
+		}
+		if failed, ok := event.Fields.(SystemExtrinsicFailed); ok {
+			return dispatchError(failed.DispatchError), nil
+		}
+	}
+	return nil, nil
+}
+
+// dispatchError describes the error of a failed dispatch
+func dispatchError(de types.DispatchError) error {
+	if de.HasModule {
+		return fmt.Errorf("dispatch failed with error %d of module %d", de.Error, de.Module)
+	}
+	return fmt.Errorf("dispatch failed with error %d", de.Error)
+}
+
+// extrinsicStatus names the status of an extrinsic which was not included
+func extrinsicStatus(status types.ExtrinsicStatus) string {
+	switch {
+	case status.IsDropped:
+		return "dropped"
+	case status.IsInvalid:
+		return "invalid"
+	case status.IsUsurped:
+		return "usurped"
+	default:
+		return "finality timeout"
+	}
+}
+
+// extrinsicHash returns the hash by which the chain identifies an extrinsic
+func extrinsicHash(ext types.Extrinsic) (types.Hash, error) {
+	return blake2Hash(ext)
+}
+
+// headerHash returns the hash of a block header
+func headerHash(header *types.Header) (types.Hash, error) {
+	return blake2Hash(header)
+}
+
+func blake2Hash(value interface{}) (types.Hash, error) {
+	encoded, err := types.EncodeToBytes(value)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	hasher, err := blake2b.New256(nil)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	hasher.Write(encoded)
+
+	return types.NewHash(hasher.Sum(nil)), nil
+}
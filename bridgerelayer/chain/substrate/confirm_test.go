@@ -0,0 +1,133 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/scale"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestHeaderHash(t *testing.T) {
+	// genesis header of Polkadot
+	header := types.Header{
+		StateRoot:      types.NewHash(types.MustHexDecodeString("0x29d0d972cd27cbc511e9589fcb7a4506d5eb6a9e8df205f00472e5ab354a4e17")),
+		ExtrinsicsRoot: types.NewHash(types.MustHexDecodeString("0x03170a2e7597b7b7e3d84c05391d139a62b157e78786d8c082f29dcf4c111314")),
+	}
+
+	hash, err := headerHash(&header)
+	require.NoError(t, err)
+	assert.Equal(t, "0x91b171bb158e2d3848fa23a9f1c25182fb8e20313b2c1eb49219da7a70ce90c3", hash.Hex())
+}
+
+// deliveries records the messages whose delivery was confirmed or dead-lettered
+type deliveries struct {
+	confirmed    []string
+	deadLettered []string
+}
+
+func (d *deliveries) Submitted(*chain.Message, *chain.Receipt) {}
+
+func (d *deliveries) Confirmed(msg *chain.Message, _ *chain.Receipt) {
+	d.confirmed = append(d.confirmed, msg.ID)
+}
+
+func (d *deliveries) DeadLetter(_ string, msg *chain.Message, _ error, _ int) error {
+	d.deadLettered = append(d.deadLettered, msg.ID)
+	return nil
+}
+
+// dispatchRecords encodes the event records of a block whose first extrinsic was
+// dispatched with the given event of the System module
+func dispatchRecords(t *testing.T, name string, fields interface{}) types.EventRecordsRaw {
+	var buf bytes.Buffer
+	encoder := scale.NewEncoder(&buf)
+	require.NoError(t, encoder.EncodeUintCompact(*big.NewInt(1)))
+	require.NoError(t, encoder.Encode(types.Phase{IsApplyExtrinsic: true}))
+	require.NoError(t, encoder.Encode(eventID(t, "System", name)))
+	require.NoError(t, encoder.Encode(fields))
+	require.NoError(t, encoder.Encode([]types.Hash{}))
+	return types.EventRecordsRaw(buf.Bytes())
+}
+
+func newConfirmWriter(t *testing.T) (*Writer, *MockClient, *deliveries) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, MetadataExemplary, client)
+	recorded := &deliveries{}
+	wr, err := NewWriter(&Config{}, conn, nil, recorded, nil, logrus.NewEntry(logger))
+	require.NoError(t, err)
+	wr.DivertFailures(recorded)
+	return wr, client, recorded
+}
+
+// submitWithEvents includes an extrinsic in a new block whose events are the given records
+func submitWithEvents(t *testing.T, client *MockClient, records types.EventRecordsRaw) (ExtrinsicSubscription, types.Hash) {
+	block := client.AddBlock()
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Events", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.SetBlockStorage(block, key, records))
+
+	c, err := types.NewCall(MetadataExemplary, "System.remark", []byte{1})
+	require.NoError(t, err)
+	ext := types.NewExtrinsic(c)
+	hash, err := extrinsicHash(ext)
+	require.NoError(t, err)
+
+	sub, err := client.SubmitAndWatchExtrinsic(context.Background(), ext)
+	require.NoError(t, err)
+	return sub, hash
+}
+
+func TestConfirm_Succeeded(t *testing.T) {
+	wr, client, recorded := newConfirmWriter(t)
+	sub, hash := submitWithEvents(t, client, dispatchRecords(t, "ExtrinsicSuccess", SystemExtrinsicSuccess{}))
+
+	msg := chain.Message{ID: "a"}
+	wr.confirm(context.Background(), sub, msg, chain.Receipt{Chain: Name, Hash: hash.Hex()}, hash, nil, 1)
+	assert.Equal(t, []string{"a"}, recorded.confirmed)
+	assert.Empty(t, recorded.deadLettered)
+}
+
+func TestConfirm_ExtrinsicFailed(t *testing.T) {
+	wr, client, recorded := newConfirmWriter(t)
+	sub, hash := submitWithEvents(t, client, dispatchRecords(t, "ExtrinsicFailed", SystemExtrinsicFailed{
+		DispatchError: types.DispatchError{HasModule: true, Module: 5, Error: 2},
+	}))
+
+	// a finalized extrinsic whose dispatch failed isn't delivered, and is dead-lettered once
+	// the deliveries of its message run out
+	msg := chain.Message{ID: "a"}
+	wr.confirm(context.Background(), sub, msg, chain.Receipt{Chain: Name, Hash: hash.Hex()}, hash, nil, deliveryAttempts)
+	assert.Empty(t, recorded.confirmed)
+	assert.Equal(t, []string{"a"}, recorded.deadLettered)
+}
+
+func TestConfirm_Dropped(t *testing.T) {
+	wr, _, recorded := newConfirmWriter(t)
+	wr.nextNonce(7)
+
+	sub := &mockSubscription{statuses: make(chan types.ExtrinsicStatus, 1), errs: make(chan error)}
+	sub.statuses <- types.ExtrinsicStatus{IsDropped: true}
+
+	// the nonce left unused by the dropped extrinsic is reused
+	msg := chain.Message{ID: "a"}
+	wr.confirm(context.Background(), sub, msg, chain.Receipt{Chain: Name}, types.Hash{}, nil, deliveryAttempts)
+	assert.Empty(t, recorded.confirmed)
+	assert.Equal(t, []string{"a"}, recorded.deadLettered)
+	assert.Equal(t, uint32(7), wr.nextNonce(7))
+}
@@ -8,58 +8,164 @@ import (
 
 	"github.com/sirupsen/logrus"
 
-	gsrpc "github.com/snowfork/go-substrate-rpc-client"
 	"github.com/snowfork/go-substrate-rpc-client/signature"
 	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 )
 
-type Connection struct {
+// Connection is the access of the relayer to a Substrate node, with the keypair of its account
+type Connection interface {
+	chain.Connection
+	// Client is only available once connected
+	Client() Client
+	Keypair() *signature.KeyringPair
+	// Metadata is populated once connected, and replaced once the runtime is upgraded
+	Metadata() *types.Metadata
+	// RefreshMetadata replaces the metadata if the runtime in the state of a block is newer,
+	// returning whether it was replaced
+	RefreshMetadata(ctx context.Context, hash types.Hash) (bool, error)
+	// Properties are discovered once connected
+	Properties() *ChainProperties
+}
+
+// RPCConnection connects to a node over websocket RPC
+type RPCConnection struct {
 	endpoint    string
 	kp          *signature.KeyringPair
-	api         *gsrpc.SubstrateAPI
-	metadata    types.Metadata
+	client      *rpcClient
+	runtime     runtimeMetadata
 	genesisHash types.Hash
-	log         *logrus.Entry
+	overrides   *PropertiesConfig
+	properties  ChainProperties
+	stats       *chain.RPCStats
+	// light client configuration if the endpoint is a light client, with the full node to
+	// which it falls back
+	light         *LightClientConfig
+	fallback      string
+	fallbackStats *chain.RPCStats
+	// nil if the endpoints are dialed by default
+	dialer *chain.Dialer
+	log    *logrus.Entry
 }
 
-func NewConnection(endpoint string, kp *signature.KeyringPair, log *logrus.Entry) *Connection {
-	return &Connection{
-		endpoint: endpoint,
-		kp:       kp,
-		log:      log,
+// NewConnection creates a connection whose calls are recorded in stats, which may be nil.
+// The properties reported by the chain are replaced by those configured in overrides.
+func NewConnection(endpoint string, kp *signature.KeyringPair, overrides *PropertiesConfig, stats *chain.RPCStats, log *logrus.Entry) *RPCConnection {
+	return &RPCConnection{
+		endpoint:  endpoint,
+		kp:        kp,
+		overrides: overrides,
+		stats:     stats,
+		log:       log,
 	}
 }
 
-func (co *Connection) Connect(_ context.Context) error {
-	// Initialize API
-	api, err := gsrpc.NewSubstrateAPI(co.endpoint)
+// DialThrough dials the endpoints of the connection through a dialer, by default if nil. It
+// must be set before connecting.
+func (co *RPCConnection) DialThrough(dialer *chain.Dialer) {
+	co.dialer = dialer
+}
+
+func (co *RPCConnection) Connect(ctx context.Context) error {
+	client, err := dialClient(ctx, co.endpoint, co.stats, co.dialer)
 	if err != nil {
 		return err
 	}
-	co.api = api
+	co.client = client
+
+	if co.light != nil {
+		err = co.routeLightClient(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return co.load(ctx)
+}
 
-	// Fetch metadata
-	meta, err := api.RPC.State.GetMetadataLatest()
+// load fetches the metadata, genesis hash and properties of the chain through the client
+func (co *RPCConnection) load(ctx context.Context) error {
+	client := co.client
+
+	// Fetch metadata, with the version of the runtime it describes
+	meta, err := client.getMetadataLatest(ctx)
 	if err != nil {
 		return err
 	}
-	co.metadata = *meta
+	version, err := client.GetRuntimeVersionLatest(ctx)
+	if err != nil {
+		return err
+	}
+	co.runtime.set(meta, version.SpecVersion)
 
 	// Fetch genesis hash
-	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	genesisHash, err := client.GetBlockHash(ctx, 0)
 	if err != nil {
 		return err
 	}
 	co.genesisHash = genesisHash
 
-	co.log.WithFields(logrus.Fields{
+	// Fetch chain properties
+	reported, err := client.getProperties(ctx)
+	if err != nil {
+		return err
+	}
+	co.properties, err = ResolveProperties(co.overrides, reported)
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{
 		"endpoint":    co.endpoint,
 		"metaVersion": meta.Version,
-	}).Info("Connected to chain")
+		"specVersion": version.SpecVersion,
+		"ss58Prefix":  co.properties.SS58Prefix,
+		"token":       co.properties.TokenSymbol,
+		"lightClient": co.light != nil,
+	}
+	// read-only relays have no account
+	if len(co.kp.PublicKey) > 0 {
+		fields["address"] = co.properties.Address(co.kp.PublicKey)
+	}
+	co.log.WithFields(fields).Info("Connected to chain")
 
 	return nil
 }
 
-func (co *Connection) Close() {
-	// TODO: Fix design issue in GSRPC preventing on-demand closing of connections
+func (co *RPCConnection) Close() {
+	if co.client != nil {
+		co.client.close()
+	}
+}
+
+// Client returns the client of the connection
+func (co *RPCConnection) Client() Client {
+	return co.client
+}
+
+// Keypair returns the keypair of the relayer account
+func (co *RPCConnection) Keypair() *signature.KeyringPair {
+	return co.kp
+}
+
+// Metadata returns the metadata of the latest runtime, fetched when connecting
+func (co *RPCConnection) Metadata() *types.Metadata {
+	return co.runtime.get()
+}
+
+// RefreshMetadata fetches the metadata of the runtime in the state of a block if the chain
+// upgraded its runtime
+func (co *RPCConnection) RefreshMetadata(ctx context.Context, hash types.Hash) (bool, error) {
+	return co.runtime.refresh(ctx, co.client, hash)
+}
+
+// Properties returns the chain properties discovered when connecting
+func (co *RPCConnection) Properties() *ChainProperties {
+	return &co.properties
+}
+
+// Stats returns the statistics of the calls made over the connection
+func (co *RPCConnection) Stats() *chain.RPCStats {
+	return co.stats
 }
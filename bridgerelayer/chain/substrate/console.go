@@ -0,0 +1,270 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ConsoleCommands returns the developer console commands of this chain, which query the
+// chain through its connection. Extrinsics are built and signed, but never sent.
+func ConsoleCommands(config *Config, conn Connection, writer *Writer) []chain.ConsoleCommand {
+	co := &console{config: config, conn: conn, writer: writer}
+
+	return []chain.ConsoleCommand{
+		{
+			Name:  "head",
+			Usage: "head",
+			Help:  "Show the latest finalized block header",
+			Run:   co.head,
+		},
+		{
+			Name:  "storage",
+			Usage: "storage <module> <item> [key] [key2]",
+			Help:  "Fetch the raw value of a storage item at the latest block, with hex-encoded map keys",
+			Args:  2,
+			Run:   co.storage,
+		},
+		{
+			Name:  "events",
+			Usage: "events [block]",
+			Help:  "Decode the events of a block, by default the latest finalized block",
+			Run:   co.events,
+		},
+		{
+			Name:  "balance",
+			Usage: "balance [address]",
+			Help:  "Show the balance of an SS58 address of this chain, by default the relayer account",
+			Run:   co.balance,
+		},
+		{
+			Name:  "fee",
+			Usage: "fee <app> <payload>",
+			Help:  "Estimate the fee of submitting a hex-encoded payload from an Ethereum app",
+			Args:  2,
+			Run:   co.fee,
+		},
+		{
+			Name:  "build",
+			Usage: "build <app> <payload>",
+			Help:  "Build and sign the extrinsic submitting a hex-encoded payload from an Ethereum app, without sending it",
+			Args:  2,
+			Run:   co.build,
+		},
+	}
+}
+
+type console struct {
+	config *Config
+	conn   Connection
+	writer *Writer
+}
+
+func (co *console) head(ctx context.Context, _ []string) (interface{}, error) {
+	hash, err := co.conn.Client().GetFinalizedHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := co.conn.Client().GetHeader(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"number":         uint64(header.Number),
+		"hash":           hash.Hex(),
+		"parentHash":     header.ParentHash.Hex(),
+		"stateRoot":      header.StateRoot.Hex(),
+		"extrinsicsRoot": header.ExtrinsicsRoot.Hex(),
+	}, nil
+}
+
+func (co *console) storage(ctx context.Context, args []string) (interface{}, error) {
+	keys := [][]byte{}
+	for _, arg := range args[2:] {
+		key, err := chain.ParseHexArg("key", arg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > 2 {
+		return nil, fmt.Errorf("storage items take at most two keys")
+	}
+	for len(keys) < 2 {
+		keys = append(keys, nil)
+	}
+
+	key, err := types.CreateStorageKey(co.conn.Metadata(), args[0], args[1], keys[0], keys[1])
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := co.conn.Client().GetStorageRawLatest(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"key":   key.Hex(),
+		"value": types.HexEncodeToString(*value),
+	}, nil
+}
+
+func (co *console) events(ctx context.Context, args []string) (interface{}, error) {
+	var hash types.Hash
+	var err error
+	if len(args) > 0 {
+		number, err := chain.ParseBlockArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		hash, err = co.conn.Client().GetBlockHash(ctx, number.Uint64())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		hash, err = co.conn.Client().GetFinalizedHead(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := types.CreateStorageKey(co.conn.Metadata(), "System", "Events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var records types.EventRecordsRaw
+	_, err = co.conn.Client().GetStorage(ctx, key, &records, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := NewEventDecoder(co.conn.Metadata()).Decode(records)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []interface{}{}
+	for _, event := range events {
+		result = append(result, map[string]interface{}{
+			"name":   event.Name[0] + "." + event.Name[1],
+			"phase":  event.Phase,
+			"fields": event.Fields,
+		})
+	}
+	return result, nil
+}
+
+func (co *console) balance(ctx context.Context, args []string) (interface{}, error) {
+	props := co.conn.Properties()
+
+	account := types.NewAccountID(co.conn.Keypair().PublicKey)
+	if len(args) > 0 {
+		var err error
+		account, err = props.ParseAddress(args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := types.CreateStorageKey(co.conn.Metadata(), "System", "Account", account[:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.AccountInfo
+	ok, err := co.conn.Client().GetStorageLatest(ctx, key, &info)
+	if err != nil {
+		return nil, err
+	}
+	// accounts without storage hold nothing
+	if !ok {
+		info.Data.Free = types.NewU128(*big.NewInt(0))
+		info.Data.Reserved = types.NewU128(*big.NewInt(0))
+	}
+
+	return map[string]interface{}{
+		"address":  props.Address(account[:]),
+		"nonce":    uint32(info.Nonce),
+		"free":     props.FormatAmount(info.Data.Free.Int),
+		"reserved": props.FormatAmount(info.Data.Reserved.Int),
+	}, nil
+}
+
+func (co *console) fee(ctx context.Context, args []string) (interface{}, error) {
+	ext, err := co.extrinsic(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := types.EncodeToHexString(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var info json.RawMessage
+	err = co.conn.Client().Call(ctx, &info, "payment_queryInfo", encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (co *console) build(ctx context.Context, args []string) (interface{}, error) {
+	ext, err := co.extrinsic(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := types.EncodeToHexString(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := extrinsicHash(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"hash":   hash.Hex(),
+		"signer": co.conn.Properties().Address(co.conn.Keypair().PublicKey),
+		"nonce":  (*big.Int)(&ext.Signature.Nonce).Uint64(),
+		"raw":    encoded,
+	}, nil
+}
+
+// extrinsic signs the extrinsic for the app and hex-encoded payload in args
+func (co *console) extrinsic(ctx context.Context, args []string) (types.Extrinsic, error) {
+	var appID [20]byte
+	found := false
+	for name, address := range co.config.Targets {
+		if strings.EqualFold(name, args[0]) {
+			appID = address
+			found = true
+		}
+	}
+	if !found {
+		return types.Extrinsic{}, fmt.Errorf("unknown app: %s", args[0])
+	}
+
+	payload, err := chain.ParseHexArg("payload", args[1])
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+
+	return co.writer.Build(ctx, &chain.Message{AppID: appID, Payload: payload})
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// inspect captures the queues, nonce, extrinsics in the pool of the node, RPC statistics and
+// tips of the writer into diagnostics
+func (wr *Writer) inspect(ctx context.Context, diagnostics *chain.WriterDiagnostics) {
+	diagnostics.Queues = wr.dispatcher.Queues()
+	if submittedAt := wr.dispatcher.LastSubmission(); !submittedAt.IsZero() {
+		diagnostics.LastSubmission = &submittedAt
+	}
+	diagnostics.RPC = append(diagnostics.RPC, wr.conn.Stats().Snapshot())
+
+	account := types.NewAccountID(wr.conn.Keypair().PublicKey)
+	nonce := chain.NonceState{Account: wr.conn.Properties().Address(account[:])}
+	wr.nonceMutex.Lock()
+	if wr.nonce != nil {
+		nonce.Next = uint64(*wr.nonce)
+	}
+	wr.nonceMutex.Unlock()
+	onchain, err := wr.accountNonce(ctx)
+	if err != nil {
+		diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("account nonce: %s", err))
+	} else {
+		nonce.OnChain = uint64(onchain)
+	}
+	diagnostics.Nonces = append(diagnostics.Nonces, nonce)
+
+	// the extrinsics of the relayer account which the node holds in its pool
+	var pool []types.Extrinsic
+	err = wr.conn.Client().Call(ctx, &pool, "author_pendingExtrinsics")
+	if err != nil {
+		diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("pending extrinsics: %s", err))
+	}
+	for _, ext := range pool {
+		signer := ext.Signature.Signer
+		if !ext.IsSigned() || !signer.IsAccountID || signer.AsAccountID != account {
+			continue
+		}
+		hash, err := extrinsicHash(ext)
+		if err != nil {
+			diagnostics.Problems = append(diagnostics.Problems, fmt.Sprintf("pending extrinsics: %s", err))
+			continue
+		}
+		extNonce := big.Int(ext.Signature.Nonce)
+		diagnostics.Pending = append(diagnostics.Pending, chain.PendingSubmission{
+			Hash:  hash.Hex(),
+			Nonce: extNonce.Uint64(),
+		})
+	}
+
+	diagnostics.Fees = map[string]string{
+		"tip":           "0",
+		"escalationTip": strconv.FormatUint(wr.tip, 10),
+	}
+}
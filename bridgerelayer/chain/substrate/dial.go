@@ -0,0 +1,190 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	gethrpc "github.com/snowfork/go-substrate-rpc-client/gethrpc"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// dialRPC connects the RPC client of GSRPC to an endpoint through a dialer, or by default
+// if the dialer is nil. GSRPC dials websockets only by default, so websocket connections
+// are dialed here and streamed to the client, which must then be closed with the returned
+// closer, nil for other endpoints.
+func dialRPC(ctx context.Context, endpoint string, dialer *chain.Dialer) (*gethrpc.Client, io.Closer, error) {
+	if dialer == nil {
+		cl, err := gethrpc.DialContext(ctx, endpoint)
+		return cl, nil, err
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		cl, err := gethrpc.DialHTTPWithClient(endpoint, dialer.HTTPClient())
+		return cl, nil, err
+	case "ws", "wss":
+		return dialWebsocket(ctx, u, dialer)
+	default:
+		// IPC endpoints are local
+		cl, err := gethrpc.DialContext(ctx, endpoint)
+		return cl, nil, err
+	}
+}
+
+// dialWebsocket dials a websocket endpoint, sending its credentials as basic authentication
+// as GSRPC does
+func dialWebsocket(ctx context.Context, u *url.URL, dialer *chain.Dialer) (*gethrpc.Client, io.Closer, error) {
+	header := make(http.Header)
+	if u.User != nil {
+		header.Add("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.String())))
+		u.User = nil
+	}
+
+	endpoint := u.String()
+	stream := &wsStream{
+		dial: func(ctx context.Context) (*websocket.Conn, error) {
+			conn, _, err := dialer.WebsocketDialer().DialContext(ctx, endpoint, header)
+			return conn, err
+		},
+	}
+
+	// the endpoint is dialed first here, so that unreachable endpoints fail to connect
+	_, err := stream.current(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cl, err := gethrpc.DialIO(ctx, stream, stream)
+	if err != nil {
+		stream.Close()
+		return nil, nil, err
+	}
+	return cl, stream, nil
+}
+
+var errStreamClosed = errors.New("websocket stream closed")
+
+// wsStream reads the messages of a websocket connection as a stream of JSON values, and
+// writes each JSON value as a message. Once the connection fails, the endpoint is dialed
+// again on the next read or write, as the client reconnects.
+type wsStream struct {
+	dial  func(ctx context.Context) (*websocket.Conn, error)
+	mutex sync.Mutex
+	// nil once the connection failed, until the endpoint is dialed again
+	conn   *websocket.Conn
+	closed bool
+	// reader of the message being read from readConn, nil between messages
+	reader   io.Reader
+	readConn *websocket.Conn
+}
+
+// current returns the connection, dialing the endpoint if the last connection failed
+func (ws *wsStream) current(ctx context.Context) (*websocket.Conn, error) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.closed {
+		return nil, errStreamClosed
+	}
+	if ws.conn == nil {
+		ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+		defer cancel()
+
+		conn, err := ws.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ws.conn = conn
+	}
+	return ws.conn, nil
+}
+
+// fail closes a connection which failed, unless it was replaced already
+func (ws *wsStream) fail(conn *websocket.Conn) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	if ws.conn == conn {
+		ws.conn.Close()
+		ws.conn = nil
+	}
+}
+
+func (ws *wsStream) Read(p []byte) (int, error) {
+	conn, err := ws.current(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if conn != ws.readConn {
+		ws.reader = nil
+		ws.readConn = conn
+	}
+
+	for {
+		if ws.reader == nil {
+			_, reader, err := conn.NextReader()
+			if err != nil {
+				ws.fail(conn)
+				return 0, err
+			}
+			ws.reader = reader
+		}
+
+		n, err := ws.reader.Read(p)
+		if err == io.EOF {
+			ws.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		if err != nil {
+			ws.fail(conn)
+		}
+		return n, err
+	}
+}
+
+// Write sends p as a message, as the client encodes each JSON value in a single write
+func (ws *wsStream) Write(p []byte) (int, error) {
+	conn, err := ws.current(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	err = conn.WriteMessage(websocket.TextMessage, p)
+	if err != nil {
+		ws.fail(conn)
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection, after which the stream fails
+func (ws *wsStream) Close() error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	ws.closed = true
+	if ws.conn == nil {
+		return nil
+	}
+	err := ws.conn.Close()
+	ws.conn = nil
+	return err
+}
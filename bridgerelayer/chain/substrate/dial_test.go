@@ -0,0 +1,109 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gethrpc "github.com/snowfork/go-substrate-rpc-client/gethrpc"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type echoService struct{}
+
+func (echoService) Echo(value string) string {
+	return value
+}
+
+func TestDialRPC_Websocket(t *testing.T) {
+	server := gethrpc.NewServer()
+	err := server.RegisterName("test", echoService{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Stop()
+
+	wsServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer wsServer.Close()
+
+	dialer, _ := chain.NewDialer(&chain.DialConfig{Prefer: "ipv4-only"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	endpoint := strings.Replace(wsServer.URL, "http://127.0.0.1", "ws://relayer:secret@localhost", 1)
+	client, err := dialClient(ctx, endpoint, nil, dialer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NotNil(t, client.stream) {
+		return
+	}
+
+	// responses span several reads of the stream, and are read in turn
+	for _, value := range []string{"relayed", strings.Repeat("0x00", 4096)} {
+		var result string
+		err = client.Call(ctx, &result, "test_echo", value)
+		assert.NoError(t, err)
+		assert.Equal(t, value, result)
+	}
+
+	// the endpoint is dialed again once the connection fails
+	stream := client.stream.(*wsStream)
+	stream.mutex.Lock()
+	stream.conn.Close()
+	stream.mutex.Unlock()
+
+	var result string
+	for i := 0; i < 10; i++ {
+		err = client.Call(ctx, &result, "test_echo", "reconnected")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, "reconnected", result)
+
+	client.close()
+	err = client.Call(ctx, &result, "test_echo", "closed")
+	assert.Error(t, err)
+}
+
+func TestDialRPC_Default(t *testing.T) {
+	server := gethrpc.NewServer()
+	err := server.RegisterName("test", echoService{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Stop()
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	dialer, _ := chain.NewDialer(&chain.DialConfig{Interface: "127.0.0.1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, d := range []*chain.Dialer{nil, dialer} {
+		client, err := dialClient(ctx, httpServer.URL, nil, d)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Nil(t, client.stream)
+
+		var result string
+		err = client.Call(ctx, &result, "test_echo", "relayed")
+		assert.NoError(t, err)
+		assert.Equal(t, "relayed", result)
+		client.close()
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// endpointHeads reports the heads served through a connection
+type endpointHeads struct {
+	endpoint string
+	conn     Connection
+	// whether the connection is made on first use, rather than by the chain
+	connected bool
+}
+
+// headSources returns the sources of the heads compared by the divergence monitor: the
+// connections of the chain, followed by further endpoints which are connected on first use
+// through dialer
+func headSources(config *Config, conn Connection, submit Connection, dialer *chain.Dialer, log *logrus.Entry) ([]chain.HeadSource, []Connection) {
+	sources := []chain.HeadSource{&endpointHeads{endpoint: config.Endpoint, conn: conn, connected: true}}
+	if submit != conn {
+		sources = append(sources, &endpointHeads{endpoint: config.SubmitEndpoint, conn: submit, connected: true})
+	}
+
+	var references []Connection
+	for _, endpoint := range config.Divergence.Endpoints {
+		reference := NewConnection(endpoint, conn.Keypair(), &config.Properties, nil, log)
+		reference.DialThrough(dialer)
+		sources = append(sources, &endpointHeads{endpoint: endpoint, conn: reference})
+		references = append(references, reference)
+	}
+
+	return sources, references
+}
+
+func (eh *endpointHeads) Endpoint() string {
+	return chain.RedactEndpoint(eh.endpoint)
+}
+
+func (eh *endpointHeads) client(ctx context.Context) (Client, error) {
+	if !eh.connected {
+		err := eh.conn.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		eh.connected = true
+	}
+	return eh.conn.Client(), nil
+}
+
+func (eh *endpointHeads) Heads(ctx context.Context) (chain.Head, chain.Head, error) {
+	client, err := eh.client(ctx)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+
+	header, err := client.GetHeaderLatest(ctx)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+	number := uint64(header.Number)
+	hash, err := client.GetBlockHash(ctx, number)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+	latest := chain.Head{Number: number, Hash: hash.Hex()}
+
+	finalizedHash, err := client.GetFinalizedHead(ctx)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+	finalizedHeader, err := client.GetHeader(ctx, finalizedHash)
+	if err != nil {
+		return chain.Head{}, chain.Head{}, err
+	}
+
+	return latest, chain.Head{Number: uint64(finalizedHeader.Number), Hash: finalizedHash.Hex()}, nil
+}
+
+func (eh *endpointHeads) BlockHash(ctx context.Context, number uint64) (string, error) {
+	client, err := eh.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := client.GetBlockHash(ctx, number)
+	if err != nil {
+		return "", err
+	}
+	return hash.Hex(), nil
+}
@@ -0,0 +1,378 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/snowfork/go-substrate-rpc-client/scale"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+)
+
+// EventArgs are the fields of an event without a static type, decoded through the types
+// which the runtime metadata gives for its arguments
+type EventArgs []EventArg
+
+// EventArg is an argument of an event decoded through the runtime metadata. Values of known
+// types are decoded into the types of the RPC client, sequences and tuples into slices,
+// and options into nil or their value.
+type EventArg struct {
+	// Type of the argument as named by the metadata, for example T::AccountId
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// SCALE encoding of the argument
+	Raw []byte `json:"-"`
+}
+
+// argDecoder decodes a value of a type of the metadata
+type argDecoder func(decoder *scale.Decoder) (interface{}, error)
+
+// argTypes are the types of the metadata which are decoded into a type of the RPC client
+var argTypes = map[string]reflect.Type{
+	"bool":          reflect.TypeOf(types.Bool(false)),
+	"u8":            reflect.TypeOf(types.U8(0)),
+	"u16":           reflect.TypeOf(types.U16(0)),
+	"u32":           reflect.TypeOf(types.U32(0)),
+	"u64":           reflect.TypeOf(types.U64(0)),
+	"u128":          reflect.TypeOf(types.U128{}),
+	"U256":          reflect.TypeOf(types.U256{}),
+	"i8":            reflect.TypeOf(types.I8(0)),
+	"i16":           reflect.TypeOf(types.I16(0)),
+	"i32":           reflect.TypeOf(types.I32(0)),
+	"i64":           reflect.TypeOf(types.I64(0)),
+	"H160":          reflect.TypeOf(types.H160{}),
+	"H256":          reflect.TypeOf(types.H256{}),
+	"Hash":          reflect.TypeOf(types.Hash{}),
+	"AccountId":     reflect.TypeOf(types.AccountID{}),
+	"AuthorityId":   reflect.TypeOf(types.AuthorityID{}),
+	"Bytes":         reflect.TypeOf(types.Bytes{}),
+	"Vec<u8>":       reflect.TypeOf(types.Bytes{}),
+	"DispatchInfo":  reflect.TypeOf(types.DispatchInfo{}),
+	"DispatchError": reflect.TypeOf(types.DispatchError{}),
+}
+
+// argAliases are the types of the metadata which are encoded as another type
+var argAliases = map[string]string{
+	"Balance":                  "u128",
+	"BlockNumber":              "u32",
+	"Index":                    "u32",
+	"AccountIndex":             "u32",
+	"Weight":                   "u64",
+	"Moment":                   "u64",
+	"TokenId":                  "H160",
+	"AssetId":                  "H160",
+	"AuthorityWeight":          "u64",
+	"AuthorityList":            "Vec<(AuthorityId, AuthorityWeight)>",
+	"Status":                   "u8",
+	"BalanceStatus":            "u8",
+	"TaskAddress<BlockNumber>": "(BlockNumber, u32)",
+	"DispatchResult":           "Result<(), DispatchError>",
+}
+
+// decodeArgs decodes the arguments of an event of the given types, keeping the encoding of
+// each argument, which is read from records at the position of the decoder
+func decodeArgs(decoder *scale.Decoder, reader *positionReader, argTypes []types.Type) (EventArgs, error) {
+	args := make(EventArgs, 0, len(argTypes))
+	for _, argType := range argTypes {
+		decode, err := parseArgType(string(argType))
+		if err != nil {
+			return nil, err
+		}
+
+		start := reader.position
+		value, err := decode(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", argType, err)
+		}
+		args = append(args, EventArg{Type: string(argType), Value: value, Raw: reader.since(start)})
+	}
+	return args, nil
+}
+
+// parseArgType returns the decoder of a type of the metadata
+func parseArgType(name string) (argDecoder, error) {
+	name = normalizeArgType(name)
+
+	if alias, ok := argAliases[name]; ok {
+		return parseArgType(alias)
+	}
+
+	if typ, ok := argTypes[name]; ok {
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			value := reflect.New(typ)
+			err := decoder.Decode(value.Interface())
+			if err != nil {
+				return nil, err
+			}
+			return value.Elem().Interface(), nil
+		}, nil
+	}
+
+	switch {
+	case name == "()":
+		return func(*scale.Decoder) (interface{}, error) {
+			return nil, nil
+		}, nil
+	case strings.HasPrefix(name, "(") && strings.HasSuffix(name, ")"):
+		return parseTuple(name[1 : len(name)-1])
+	case strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]"):
+		return parseFixedArray(name[1 : len(name)-1])
+	}
+
+	outer, inner, ok := splitGeneric(name)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not decodable", name)
+	}
+
+	switch outer {
+	case "Vec":
+		decode, err := parseArgType(inner)
+		if err != nil {
+			return nil, err
+		}
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			length, err := decoder.DecodeUintCompact()
+			if err != nil {
+				return nil, err
+			}
+			return decodeSequence(decoder, decode, length.Uint64())
+		}, nil
+	case "Option":
+		decode, err := parseArgType(inner)
+		if err != nil {
+			return nil, err
+		}
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			flag, err := decoder.ReadOneByte()
+			if err != nil || flag == 0 {
+				return nil, err
+			}
+			return decode(decoder)
+		}, nil
+	case "Compact":
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			value, err := decoder.DecodeUintCompact()
+			if err != nil {
+				return nil, err
+			}
+			return types.UCompact(*value), nil
+		}, nil
+	case "Result":
+		parts := splitTopLevel(inner)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("type %s is not decodable", name)
+		}
+		decodeOk, err := parseArgType(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		decodeErr, err := parseArgType(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		// results are decoded into a single-entry map keyed Ok or Err
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			flag, err := decoder.ReadOneByte()
+			if err != nil {
+				return nil, err
+			}
+			if flag == 0 {
+				value, err := decodeOk(decoder)
+				return map[string]interface{}{"Ok": value}, err
+			}
+			value, err := decodeErr(decoder)
+			return map[string]interface{}{"Err": value}, err
+		}, nil
+	}
+
+	return nil, fmt.Errorf("type %s is not decodable", name)
+}
+
+func parseTuple(inner string) (argDecoder, error) {
+	var decoders []argDecoder
+	for _, part := range splitTopLevel(inner) {
+		decode, err := parseArgType(part)
+		if err != nil {
+			return nil, err
+		}
+		decoders = append(decoders, decode)
+	}
+
+	return func(decoder *scale.Decoder) (interface{}, error) {
+		values := make([]interface{}, 0, len(decoders))
+		for _, decode := range decoders {
+			value, err := decode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}, nil
+}
+
+// parseFixedArray parses an array type such as [u8; 32], whose bytes are decoded as a slice
+func parseFixedArray(inner string) (argDecoder, error) {
+	parts := strings.Split(inner, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("type [%s] is not decodable", inner)
+	}
+	length, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("type [%s] is not decodable", inner)
+	}
+
+	element := strings.TrimSpace(parts[0])
+	if element == "u8" {
+		return func(decoder *scale.Decoder) (interface{}, error) {
+			value := make([]byte, length)
+			err := decoder.Read(value)
+			if err != nil {
+				return nil, err
+			}
+			return value, nil
+		}, nil
+	}
+
+	decode, err := parseArgType(element)
+	if err != nil {
+		return nil, err
+	}
+	return func(decoder *scale.Decoder) (interface{}, error) {
+		return decodeSequence(decoder, decode, length)
+	}, nil
+}
+
+func decodeSequence(decoder *scale.Decoder, decode argDecoder, length uint64) (interface{}, error) {
+	values := make([]interface{}, 0, length)
+	for i := uint64(0); i < length; i++ {
+		value, err := decode(decoder)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// normalizeArgType strips the whitespace and the paths of the associated types of the
+// runtime, such as T:: or <T as Trait>::, from a type name
+func normalizeArgType(name string) string {
+	for {
+		start := strings.Index(name, "<T as ")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(name[start:], ">::")
+		if end < 0 {
+			break
+		}
+		name = name[:start] + name[start+end+3:]
+	}
+	name = strings.Join(strings.Fields(name), "")
+	name = strings.Replace(name, "T::", "", -1)
+	// tuples and arrays are separated by their commas again
+	name = strings.Replace(name, ",", ", ", -1)
+	return strings.Replace(name, ";", "; ", -1)
+}
+
+// splitGeneric splits a type such as Vec<u8> into Vec and u8
+func splitGeneric(name string) (string, string, bool) {
+	open := strings.Index(name, "<")
+	if open <= 0 || !strings.HasSuffix(name, ">") {
+		return "", "", false
+	}
+	return name[:open], name[open+1 : len(name)-1], true
+}
+
+// splitTopLevel splits the comma-separated types of a tuple or generic, ignoring the commas
+// of nested types
+func splitTopLevel(inner string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range inner {
+		switch c {
+		case '<', '(', '[':
+			depth++
+		case '>', ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(inner[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// eventArgTypes returns the types of the arguments of an event, as given by the metadata
+func eventArgTypes(meta *types.Metadata, id types.EventID) ([]types.Type, error) {
+	var modules [][]types.EventMetadataV4
+	switch {
+	case meta.IsMetadataV8:
+		modules = eventsV8(meta.AsMetadataV8.Modules)
+	case meta.IsMetadataV9:
+		modules = eventsV8(meta.AsMetadataV9.Modules)
+	case meta.IsMetadataV10:
+		modules = eventsV10(meta.AsMetadataV10.Modules)
+	case meta.IsMetadataV11:
+		modules = eventsV10(meta.AsMetadataV11.Modules)
+	default:
+		return nil, fmt.Errorf("unsupported metadata version %d", meta.Version)
+	}
+
+	if int(id[0]) >= len(modules) || int(id[1]) >= len(modules[id[0]]) {
+		return nil, fmt.Errorf("event %v not found in metadata", id)
+	}
+	return modules[id[0]][id[1]].Args, nil
+}
+
+// eventsV8 returns the events of the modules which have events, in the order of their index
+func eventsV8(modules []types.ModuleMetadataV8) [][]types.EventMetadataV4 {
+	var events [][]types.EventMetadataV4
+	for _, module := range modules {
+		if module.HasEvents {
+			events = append(events, module.Events)
+		}
+	}
+	return events
+}
+
+func eventsV10(modules []types.ModuleMetadataV10) [][]types.EventMetadataV4 {
+	var events [][]types.EventMetadataV4
+	for _, module := range modules {
+		if module.HasEvents {
+			events = append(events, module.Events)
+		}
+	}
+	return events
+}
+
+// positionReader reads event records, tracking how many bytes were read
+type positionReader struct {
+	records  []byte
+	position int
+}
+
+func (pr *positionReader) Read(p []byte) (int, error) {
+	if pr.position >= len(pr.records) {
+		return 0, io.EOF
+	}
+	n := copy(p, pr.records[pr.position:])
+	pr.position += n
+	return n, nil
+}
+
+// since returns the bytes read from a position
+func (pr *positionReader) since(start int) []byte {
+	return append([]byte{}, pr.records[start:pr.position]...)
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// EncryptionConfig enables the encryption of the payloads relayed to an Ethereum app,
+// whose contract decrypts them with the key of the recipient
+type EncryptionConfig struct {
+	// X25519 public key of the recipient, 32 bytes hex encoded
+	RecipientKey string `mapstructure:"recipient-key"`
+}
+
+// sealedVersion prefixes the envelope of an encrypted payload: the version, the X25519
+// public key of the ephemeral key of the payload, and the payload sealed with
+// ChaCha20-Poly1305 under the key derived from their shared secret, with the app ID as
+// additional data
+const sealedVersion = 1
+
+var (
+	ephemeralDomain = []byte("artemis-relay/payload-ephemeral")
+	payloadInfo     = []byte("artemis-relay/payload")
+)
+
+// Sealer encrypts the payloads of the apps configured for encryption. Ephemeral keys are
+// derived from the secret of the relayer and the provenance of each message, so that the
+// payload of an event, and thus the ID of its message, is the same each time the event
+// is processed, while only the recipient can decrypt it.
+type Sealer struct {
+	secret     []byte
+	recipients map[string][32]byte
+}
+
+// NewSealer returns nil if no app is encrypted. The secret is a hex key of 32 bytes.
+func NewSealer(configs map[string]EncryptionConfig, secret string) (*Sealer, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	key, err := hexutil.Decode(secret)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("payload encryption key is not 32 bytes hex encoded")
+	}
+
+	recipients := make(map[string][32]byte)
+	for app, config := range configs {
+		public, err := hexutil.Decode(config.RecipientKey)
+		if err != nil || len(public) != 32 {
+			return nil, fmt.Errorf("recipient key of app %s is not 32 bytes hex encoded", app)
+		}
+		var recipient [32]byte
+		copy(recipient[:], public)
+		recipients[app] = recipient
+	}
+
+	return &Sealer{secret: key, recipients: recipients}, nil
+}
+
+// Seal encrypts the payload of a message for the recipient of its app, once its
+// provenance is attributed. Messages of other apps are left unchanged.
+func (se *Sealer) Seal(app string, msg *chain.Message) error {
+	if se == nil {
+		return nil
+	}
+	recipient, ok := se.recipients[app]
+	if !ok {
+		return nil
+	}
+
+	payload, ok := msg.Payload.([]byte)
+	if !ok {
+		return fmt.Errorf("payload of app %s is not bytes", app)
+	}
+
+	mac := hmac.New(sha256.New, se.secret)
+	mac.Write(ephemeralDomain)
+	mac.Write(msg.AppID[:])
+	mac.Write([]byte(msg.SourceChainID))
+	mac.Write([]byte(msg.SourceHash))
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], msg.EventIndex)
+	mac.Write(index[:])
+	mac.Write(payload)
+	ephemeral := mac.Sum(nil)
+
+	public, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	shared, err := curve25519.X25519(ephemeral, recipient[:])
+	if err != nil {
+		return err
+	}
+
+	aead, err := payloadCipher(shared, public, recipient[:])
+	if err != nil {
+		return err
+	}
+
+	sealed := make([]byte, 0, 1+len(public)+len(payload)+aead.Overhead())
+	sealed = append(sealed, sealedVersion)
+	sealed = append(sealed, public...)
+	// each ephemeral key seals a single payload, so the nonce is never reused with a key
+	nonce := make([]byte, aead.NonceSize())
+	msg.Payload = aead.Seal(sealed, nonce, payload, msg.AppID[:])
+	return nil
+}
+
+// OpenPayload decrypts a payload sealed for the recipient of an app with its X25519
+// private key, as the target of the app does
+func OpenPayload(private []byte, appID [20]byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < 1+32 || sealed[0] != sealedVersion {
+		return nil, errors.New("payload is not sealed")
+	}
+
+	ephemeral := sealed[1:33]
+	recipient, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(private, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := payloadCipher(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Open(nil, nonce, sealed[33:], appID[:])
+}
+
+// payloadCipher derives the cipher of a payload from the secret shared by its ephemeral
+// key and the recipient
+func payloadCipher(shared []byte, ephemeral []byte, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, payloadInfo), key)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestSealer_Seal(t *testing.T) {
+	private := make([]byte, 32)
+	private[0] = 7
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	require.NoError(t, err)
+
+	sealer, err := NewSealer(map[string]EncryptionConfig{
+		"private": {RecipientKey: hexutil.Encode(public)},
+	}, hexutil.Encode(make([]byte, 32)))
+	require.NoError(t, err)
+
+	newMessage := func() *chain.Message {
+		return &chain.Message{AppID: [20]byte{1}, Payload: []byte{1, 2, 3}, SourceHash: "0x01", EventIndex: 2}
+	}
+
+	msg := newMessage()
+	require.NoError(t, sealer.Seal("private", msg))
+	sealed := msg.Payload.([]byte)
+	assert.Len(t, sealed, 1+32+3+16)
+
+	// the payload of an event is sealed the same each time, so that its message ID is stable
+	again := newMessage()
+	require.NoError(t, sealer.Seal("private", again))
+	assert.Equal(t, sealed, again.Payload)
+
+	other := newMessage()
+	other.EventIndex = 3
+	require.NoError(t, sealer.Seal("private", other))
+	assert.NotEqual(t, sealed[1:33], other.Payload.([]byte)[1:33])
+
+	payload, err := OpenPayload(private, [20]byte{1}, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, payload)
+
+	// payloads are bound to their app
+	_, err = OpenPayload(private, [20]byte{2}, sealed)
+	assert.Error(t, err)
+
+	plain := newMessage()
+	require.NoError(t, sealer.Seal("public", plain))
+	assert.Equal(t, []byte{1, 2, 3}, plain.Payload)
+}
+
+func TestNewSealer(t *testing.T) {
+	sealer, err := NewSealer(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, sealer)
+	assert.NoError(t, sealer.Seal("private", &chain.Message{Payload: []byte{1}}))
+
+	_, err = NewSealer(map[string]EncryptionConfig{"private": {RecipientKey: "0x01"}}, hexutil.Encode(make([]byte, 32)))
+	assert.Error(t, err)
+
+	_, err = NewSealer(map[string]EncryptionConfig{"private": {RecipientKey: hexutil.Encode(make([]byte, 32))}}, "")
+	assert.Error(t, err)
+}
@@ -1,7 +1,6 @@
 package substrate
 
 import (
-	"bytes"
 	"fmt"
 	"reflect"
 
@@ -172,7 +171,8 @@ func NewEventDecoder(meta *types.Metadata) *EventDecoder {
 
 func (ed *EventDecoder) Decode(records []byte) ([]Event, error) {
 
-	decoder := scale.NewDecoder(bytes.NewReader(records))
+	reader := &positionReader{records: records}
+	decoder := scale.NewDecoder(reader)
 
 	// determine number of events
 	length, err := decoder.DecodeUintCompact()
@@ -206,24 +206,9 @@ func (ed *EventDecoder) Decode(records []byte) ([]Event, error) {
 			return nil, fmt.Errorf("unable to find event with EventID %v in metadata for event #%v: %s", id, i, err)
 		}
 
-		key := [2]string{string(moduleName), string(eventName)}
-		holderType, ok := ed.Types[key]
-		if !ok {
-			return nil, fmt.Errorf("event #%v (%s.%s) is not decodable", i, moduleName, eventName)
-		}
-
-		holder := reflect.New(holderType)
-		numFields := holder.Elem().NumField()
-
-		// Decode event fields
-		for j := 0; j < numFields; j++ {
-			err = decoder.Decode(holder.Elem().FieldByIndex([]int{j}).Addr().Interface())
-			if err != nil {
-				return nil, fmt.Errorf(
-					"unable to decode field %v of event #%v with EventID %v, field %v_%v: %v", j, i, id, moduleName,
-					eventName, err,
-				)
-			}
+		fields, err := ed.decodeFields(decoder, reader, id, [2]string{string(moduleName), string(eventName)})
+		if err != nil {
+			return nil, fmt.Errorf("event #%v (%s.%s) is not decodable: %w", i, moduleName, eventName, err)
 		}
 
 		// Decode topics
@@ -238,7 +223,7 @@ func (ed *EventDecoder) Decode(records []byte) ([]Event, error) {
 			Name:   [2]string{string(moduleName), string(eventName)},
 			Phase:  phase,
 			Topics: topics,
-			Fields: holder.Elem().Interface(),
+			Fields: fields,
 		}
 
 		events = append(events, event)
@@ -247,3 +232,28 @@ func (ed *EventDecoder) Decode(records []byte) ([]Event, error) {
 
 	return events, nil
 }
+
+// decodeFields decodes the fields of an event into its type in the type map. Events
+// without a type are decoded into EventArgs through the types given by the metadata.
+func (ed *EventDecoder) decodeFields(decoder *scale.Decoder, reader *positionReader, id types.EventID, key [2]string) (interface{}, error) {
+	holderType, ok := ed.Types[key]
+	if !ok {
+		argTypes, err := eventArgTypes(ed.meta, id)
+		if err != nil {
+			return nil, err
+		}
+		return decodeArgs(decoder, reader, argTypes)
+	}
+
+	holder := reflect.New(holderType)
+	numFields := holder.Elem().NumField()
+
+	// Decode event fields
+	for j := 0; j < numFields; j++ {
+		err := decoder.Decode(holder.Elem().FieldByIndex([]int{j}).Addr().Interface())
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode field %v: %v", j, err)
+		}
+	}
+	return holder.Elem().Interface(), nil
+}
@@ -1,11 +1,14 @@
 package substrate
 
 import (
+	"bytes"
 	"math/big"
 	"testing"
 
+	"github.com/snowfork/go-substrate-rpc-client/scale"
 	"github.com/snowfork/go-substrate-rpc-client/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDecodeEvents(t *testing.T) {
@@ -68,3 +71,77 @@ func TestDecodeEvents(t *testing.T) {
 		},
 	)
 }
+
+// schedulerDispatchedRecords encodes the event records of a block with a single
+// Scheduler.Dispatched event, which has no type in the type map
+func schedulerDispatchedRecords(t *testing.T) []byte {
+	var buf bytes.Buffer
+	encoder := scale.NewEncoder(&buf)
+	require.NoError(t, encoder.EncodeUintCompact(*big.NewInt(1)))
+	require.NoError(t, encoder.Encode(types.Phase{IsFinalization: true}))
+	require.NoError(t, encoder.Encode(types.EventID{3, 2}))
+	// task address
+	require.NoError(t, encoder.Encode(types.U32(5)))
+	require.NoError(t, encoder.Encode(types.U32(1)))
+	// task id
+	require.NoError(t, encoder.Encode(types.NewOptionBytes(types.Bytes{0xab, 0xcd})))
+	// dispatch result
+	require.NoError(t, encoder.Encode(types.U8(0)))
+	require.NoError(t, encoder.Encode([]types.Hash{}))
+	return buf.Bytes()
+}
+
+func TestDecodeEvents_Metadata(t *testing.T) {
+	decoder := NewEventDecoder(MetadataExemplary)
+
+	events, err := decoder.Decode(schedulerDispatchedRecords(t))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	assert.Equal(t, [2]string{"Scheduler", "Dispatched"}, events[0].Name)
+	assert.Equal(t, []types.Hash{}, events[0].Topics)
+	assert.Equal(t, EventArgs{
+		{
+			Type:  "TaskAddress<BlockNumber>",
+			Value: []interface{}{types.U32(5), types.U32(1)},
+			Raw:   []byte{5, 0, 0, 0, 1, 0, 0, 0},
+		},
+		{
+			Type:  "Option<Vec<u8>>",
+			Value: types.Bytes{0xab, 0xcd},
+			Raw:   []byte{1, 8, 0xab, 0xcd},
+		},
+		{
+			Type:  "DispatchResult",
+			Value: map[string]interface{}{"Ok": nil},
+			Raw:   []byte{0},
+		},
+	}, events[0].Fields)
+}
+
+func TestParseArgType(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding []byte
+		value    interface{}
+	}{
+		{"T::BlockNumber", []byte{7, 0, 0, 0}, types.U32(7)},
+		{"Vec<(T::AccountId, u8)>", append(append([]byte{4}, make([]byte, 32)...), 9), []interface{}{[]interface{}{types.AccountID{}, types.U8(9)}}},
+		{"Option<u16>", []byte{0}, nil},
+		{"Compact<Balance>", []byte{0x28}, types.UCompact(*big.NewInt(10))},
+		{"[u8; 4]", []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+		{"<T as Trait>::Balance", append([]byte{1}, make([]byte, 15)...), types.NewU128(*big.NewInt(1))},
+	}
+
+	for _, tt := range tests {
+		decode, err := parseArgType(tt.name)
+		require.NoError(t, err, tt.name)
+
+		value, err := decode(scale.NewDecoder(bytes.NewReader(tt.encoding)))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.value, value, tt.name)
+	}
+
+	_, err := parseArgType("Vec<RewardPoint>")
+	assert.Error(t, err)
+}
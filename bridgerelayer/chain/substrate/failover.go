@@ -0,0 +1,302 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// errEndpointSwitched ends the subscriptions to an endpoint which is no longer active, so
+// that they are made again to the active endpoint
+var errEndpointSwitched = errors.New("active endpoint changed")
+
+// FailoverConnection connects to several endpoints serving the same chain, making its calls
+// to the one which a chain.EndpointPool selects. The metadata and properties of the chain
+// are fetched from the first endpoint connected to. Subscriptions to finalized heads end
+// when they are dropped or the connection fails over, so that they are made again to the
+// active endpoint.
+type FailoverConnection struct {
+	*RPCConnection
+	endpoints []string
+	pool      *chain.EndpointPool
+	mutex     sync.RWMutex
+	// clients of the endpoints which were connected to, nil for the others
+	clients []*rpcClient
+	stats   []*chain.RPCStats
+	// genesis of the first endpoint connected to, which the others must share
+	genesisHash *types.Hash
+	client      *failoverClient
+	closeOnce   sync.Once
+}
+
+// NewFailoverConnection creates a connection to endpoints in order of preference, whose
+// calls are recorded in the statistics of each endpoint. The properties reported by the
+// chain are replaced by those configured in overrides.
+func NewFailoverConnection(endpoints []string, kp *signature.KeyringPair, overrides *PropertiesConfig, config *chain.RPCConfig, log *logrus.Entry) *FailoverConnection {
+	co := &FailoverConnection{
+		RPCConnection: NewConnection(endpoints[0], kp, overrides, nil, log),
+		endpoints:     endpoints,
+		clients:       make([]*rpcClient, len(endpoints)),
+	}
+	for _, endpoint := range endpoints {
+		co.stats = append(co.stats, chain.NewRPCStats(Name, endpoint, config, log))
+	}
+	co.pool = chain.NewEndpointPool(Name, endpoints, &config.Failover, co.connect, log)
+	return co
+}
+
+func (co *FailoverConnection) Connect(ctx context.Context) error {
+	err := co.pool.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, index := co.active()
+	co.RPCConnection.endpoint = co.endpoints[index]
+	co.RPCConnection.client = client
+	co.RPCConnection.stats = co.stats[index]
+	err = co.RPCConnection.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	co.client = &failoverClient{co: co}
+	return nil
+}
+
+// connect dials the endpoint at an index unless it was connected to before, and checks
+// that it serves the chain of the others
+func (co *FailoverConnection) connect(ctx context.Context, index int) error {
+	co.mutex.RLock()
+	client := co.clients[index]
+	expected := co.genesisHash
+	co.mutex.RUnlock()
+
+	dialed := client == nil
+	if dialed {
+		var err error
+		client, err = dialClient(ctx, co.endpoints[index], co.stats[index], co.dialer)
+		if err != nil {
+			return err
+		}
+	}
+
+	genesisHash, err := client.GetBlockHash(ctx, 0)
+	if err == nil && expected != nil && genesisHash != *expected {
+		err = chain.Permanent(fmt.Errorf("endpoint serves the chain with genesis %s instead of %s", genesisHash.Hex(), expected.Hex()))
+	}
+	if err != nil {
+		if dialed {
+			client.close()
+		}
+		return err
+	}
+
+	co.mutex.Lock()
+	defer co.mutex.Unlock()
+
+	if co.genesisHash == nil {
+		co.genesisHash = &genesisHash
+	}
+	if dialed {
+		co.clients[index] = client
+		co.log.WithField("endpoint", chain.RedactEndpoint(co.endpoints[index])).Debug("Connected to endpoint")
+	}
+	return nil
+}
+
+// active returns the client of the active endpoint, with its index
+func (co *FailoverConnection) active() (*rpcClient, int) {
+	index := co.pool.Active()
+
+	co.mutex.RLock()
+	defer co.mutex.RUnlock()
+	return co.clients[index], index
+}
+
+func (co *FailoverConnection) Close() {
+	co.closeOnce.Do(func() {
+		co.mutex.Lock()
+		defer co.mutex.Unlock()
+		for _, client := range co.clients {
+			if client != nil {
+				client.close()
+			}
+		}
+	})
+}
+
+// Client returns the client of the connection, which makes each call to the active endpoint
+func (co *FailoverConnection) Client() Client {
+	return co.client
+}
+
+// RefreshMetadata fetches the metadata of the runtime in the state of a block from the
+// active endpoint if the chain upgraded its runtime
+func (co *FailoverConnection) RefreshMetadata(ctx context.Context, hash types.Hash) (bool, error) {
+	return co.runtime.refresh(ctx, co.client, hash)
+}
+
+// Stats returns the statistics of the calls made to the active endpoint
+func (co *FailoverConnection) Stats() *chain.RPCStats {
+	return co.stats[co.pool.Active()]
+}
+
+// Pool returns the pool selecting the active endpoint
+func (co *FailoverConnection) Pool() *chain.EndpointPool {
+	return co.pool
+}
+
+// failoverClient makes each call to the active endpoint of a connection, recording its
+// outcome in the health of the endpoint
+type failoverClient struct {
+	co *FailoverConnection
+}
+
+func (fc *failoverClient) GetBlockHash(ctx context.Context, number uint64) (types.Hash, error) {
+	client, index := fc.co.active()
+	hash, err := client.GetBlockHash(ctx, number)
+	fc.co.pool.Observe(ctx, index, err)
+	return hash, err
+}
+
+func (fc *failoverClient) GetFinalizedHead(ctx context.Context) (types.Hash, error) {
+	client, index := fc.co.active()
+	hash, err := client.GetFinalizedHead(ctx)
+	fc.co.pool.Observe(ctx, index, err)
+	return hash, err
+}
+
+func (fc *failoverClient) GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error) {
+	client, index := fc.co.active()
+	header, err := client.GetHeader(ctx, hash)
+	fc.co.pool.Observe(ctx, index, err)
+	return header, err
+}
+
+func (fc *failoverClient) GetHeaderLatest(ctx context.Context) (*types.Header, error) {
+	client, index := fc.co.active()
+	header, err := client.GetHeaderLatest(ctx)
+	fc.co.pool.Observe(ctx, index, err)
+	return header, err
+}
+
+func (fc *failoverClient) GetBlock(ctx context.Context, hash types.Hash) (*types.SignedBlock, error) {
+	client, index := fc.co.active()
+	block, err := client.GetBlock(ctx, hash)
+	fc.co.pool.Observe(ctx, index, err)
+	return block, err
+}
+
+func (fc *failoverClient) GetStorage(ctx context.Context, key types.StorageKey, target interface{}, hash types.Hash) (bool, error) {
+	client, index := fc.co.active()
+	ok, err := client.GetStorage(ctx, key, target, hash)
+	fc.co.pool.Observe(ctx, index, err)
+	return ok, err
+}
+
+func (fc *failoverClient) GetStorageLatest(ctx context.Context, key types.StorageKey, target interface{}) (bool, error) {
+	client, index := fc.co.active()
+	ok, err := client.GetStorageLatest(ctx, key, target)
+	fc.co.pool.Observe(ctx, index, err)
+	return ok, err
+}
+
+func (fc *failoverClient) GetStorageRawLatest(ctx context.Context, key types.StorageKey) (*types.StorageDataRaw, error) {
+	client, index := fc.co.active()
+	data, err := client.GetStorageRawLatest(ctx, key)
+	fc.co.pool.Observe(ctx, index, err)
+	return data, err
+}
+
+func (fc *failoverClient) GetRuntimeVersionLatest(ctx context.Context) (*types.RuntimeVersion, error) {
+	client, index := fc.co.active()
+	version, err := client.GetRuntimeVersionLatest(ctx)
+	fc.co.pool.Observe(ctx, index, err)
+	return version, err
+}
+
+func (fc *failoverClient) GetRuntimeVersion(ctx context.Context, hash types.Hash) (*types.RuntimeVersion, error) {
+	client, index := fc.co.active()
+	version, err := client.GetRuntimeVersion(ctx, hash)
+	fc.co.pool.Observe(ctx, index, err)
+	return version, err
+}
+
+func (fc *failoverClient) GetMetadata(ctx context.Context, hash types.Hash) (*types.Metadata, error) {
+	client, index := fc.co.active()
+	metadata, err := client.GetMetadata(ctx, hash)
+	fc.co.pool.Observe(ctx, index, err)
+	return metadata, err
+}
+
+func (fc *failoverClient) SubmitExtrinsic(ctx context.Context, ext types.Extrinsic) (types.Hash, error) {
+	client, index := fc.co.active()
+	hash, err := client.SubmitExtrinsic(ctx, ext)
+	fc.co.pool.Observe(ctx, index, err)
+	return hash, err
+}
+
+func (fc *failoverClient) SubmitAndWatchExtrinsic(ctx context.Context, ext types.Extrinsic) (ExtrinsicSubscription, error) {
+	client, index := fc.co.active()
+	sub, err := client.SubmitAndWatchExtrinsic(ctx, ext)
+	fc.co.pool.Observe(ctx, index, err)
+	return sub, err
+}
+
+func (fc *failoverClient) SubscribeFinalizedHeads(ctx context.Context) (HeaderSubscription, error) {
+	changed := fc.co.pool.Changed()
+	client, index := fc.co.active()
+	sub, err := client.SubscribeFinalizedHeads(ctx)
+	fc.co.pool.Observe(ctx, index, err)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &failoverHeaderSubscription{HeaderSubscription: sub, err: make(chan error, 1)}
+	go fs.watch(fc.co.pool, index, changed)
+	return fs, nil
+}
+
+func (fc *failoverClient) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	client, index := fc.co.active()
+	err := client.Call(ctx, result, method, args...)
+	fc.co.pool.Observe(ctx, index, err)
+	return err
+}
+
+// failoverHeaderSubscription reports the drop of a subscription to the pool of its
+// endpoint, and fails once its endpoint is no longer active
+type failoverHeaderSubscription struct {
+	HeaderSubscription
+	err chan error
+}
+
+func (fs *failoverHeaderSubscription) watch(pool *chain.EndpointPool, index int, changed <-chan struct{}) {
+	select {
+	case err, ok := <-fs.HeaderSubscription.Err():
+		if !ok {
+			close(fs.err)
+			return
+		}
+		pool.Drop(index, err)
+		fs.err <- err
+	case <-changed:
+		fs.err <- errEndpointSwitched
+	}
+}
+
+func (fs *failoverHeaderSubscription) Err() <-chan error {
+	return fs.err
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/snowfork/go-substrate-rpc-client/scale"
+)
+
+// EventRoute relays the events of a pallet to an Ethereum app
+type EventRoute struct {
+	// Pallet and name of the event, for example ETH.Transfer
+	Event string `mapstructure:"event"`
+	// Handler encoding the payload of the messages for the event, one of eth-transfer,
+	// erc20-transfer or args. Defaults to args.
+	Handler string `mapstructure:"handler"`
+}
+
+// EventHandler encodes the payload of the message for an event, returning its decoded
+// fields. Events which the handler can't encode are refused with an error.
+type EventHandler func(encoder *scale.Encoder, props *ChainProperties, blockNumber uint64, index int, event *Event) (map[string]interface{}, error)
+
+const defaultEventHandler = "args"
+
+// eventHandlers are the handlers which routes can name
+var eventHandlers = map[string]EventHandler{
+	"eth-transfer":   encodeETHTransfer,
+	"erc20-transfer": encodeERC20Transfer,
+	"args":           encodeArgs,
+}
+
+// defaultRoutes are the routes of the apps which are relayed without configuration
+var defaultRoutes = map[string][]EventRoute{
+	"eth":   {{Event: "ETH.Transfer", Handler: "eth-transfer"}},
+	"erc20": {{Event: "ERC20.Transfer", Handler: "erc20-transfer"}},
+}
+
+// EventRegistry maps the events of pallets to the app to which they are relayed and the
+// handler encoding their messages
+type EventRegistry struct {
+	routes map[[2]string]appHandler
+}
+
+type appHandler struct {
+	app     string
+	handler EventHandler
+}
+
+// NewEventRegistry creates a registry of the default routes and those configured for each
+// app, keyed by app name. Configured routes replace the default route of their event.
+func NewEventRegistry(routes map[string][]EventRoute) (*EventRegistry, error) {
+	er := &EventRegistry{routes: make(map[[2]string]appHandler)}
+	for _, configured := range []map[string][]EventRoute{defaultRoutes, routes} {
+		for app, appRoutes := range configured {
+			for _, route := range appRoutes {
+				err := er.add(app, route)
+				if err != nil {
+					return nil, fmt.Errorf("events of app %s: %w", app, err)
+				}
+			}
+		}
+	}
+	return er, nil
+}
+
+// defaultEventRegistry creates a registry of the default routes, which are always valid
+func defaultEventRegistry() *EventRegistry {
+	er, _ := NewEventRegistry(nil)
+	return er
+}
+
+func (er *EventRegistry) add(app string, route EventRoute) error {
+	parts := strings.Split(route.Event, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("event %q is not of the form Pallet.Event", route.Event)
+	}
+
+	name := route.Handler
+	if name == "" {
+		name = defaultEventHandler
+	}
+	handler, ok := eventHandlers[name]
+	if !ok {
+		return fmt.Errorf("unknown handler %q of event %s", name, route.Event)
+	}
+
+	er.routes[[2]string{parts[0], parts[1]}] = appHandler{app: app, handler: handler}
+	return nil
+}
+
+// Encode encodes the payload of the message for an event, returning the app of the event
+// with its decoded fields, or an empty app for events which aren't relayed
+func (er *EventRegistry) Encode(encoder *scale.Encoder, props *ChainProperties, blockNumber uint64, index int, event *Event) (string, map[string]interface{}, error) {
+	route, ok := er.routes[event.Name]
+	if !ok {
+		return "", nil, nil
+	}
+
+	fields, err := route.handler(encoder, props, blockNumber, index, event)
+	if err != nil {
+		return "", nil, err
+	}
+	return route.app, fields, nil
+}
+
+// encodeETHTransfer encodes a transfer of ETH. The sending account is also given by its
+// address on the chain.
+func encodeETHTransfer(encoder *scale.Encoder, props *ChainProperties, blockNumber uint64, index int, event *Event) (map[string]interface{}, error) {
+	fields, ok := event.Fields.(ETHTransfer)
+	if !ok {
+		return nil, fmt.Errorf("event %s.%s is not an ETH transfer", event.Name[0], event.Name[1])
+	}
+
+	encoder.Encode(fields.AccountID)
+	encoder.Encode(fields.Recipient)
+	encoder.Encode(fields.Amount)
+	encoder.Encode(uint64(blockNumber))
+	encoder.Encode(uint64(index))
+
+	return map[string]interface{}{
+		"accountId": hexutil.Encode(fields.AccountID[:]),
+		"account":   props.Address(fields.AccountID[:]),
+		"recipient": hexutil.Encode(fields.Recipient[:]),
+		"amount":    fields.Amount.String(),
+	}, nil
+}
+
+// encodeERC20Transfer encodes a transfer of an ERC20 token. The sending account is also
+// given by its address on the chain.
+func encodeERC20Transfer(encoder *scale.Encoder, props *ChainProperties, blockNumber uint64, index int, event *Event) (map[string]interface{}, error) {
+	fields, ok := event.Fields.(ERC20Transfer)
+	if !ok {
+		return nil, fmt.Errorf("event %s.%s is not an ERC20 transfer", event.Name[0], event.Name[1])
+	}
+
+	encoder.Encode(fields.AccountID)
+	encoder.Encode(fields.Recipient)
+	encoder.Encode(fields.TokenID)
+	encoder.Encode(fields.Amount)
+	encoder.Encode(uint64(blockNumber))
+	encoder.Encode(uint64(index))
+
+	return map[string]interface{}{
+		"tokenId":   hexutil.Encode(fields.TokenID[:]),
+		"accountId": hexutil.Encode(fields.AccountID[:]),
+		"account":   props.Address(fields.AccountID[:]),
+		"recipient": hexutil.Encode(fields.Recipient[:]),
+		"amount":    fields.Amount.String(),
+	}, nil
+}
+
+// encodeArgs encodes the arguments of any event in their order, followed by the block
+// number and index of the event. The fields are the hex-encoded arguments keyed by their
+// position.
+func encodeArgs(encoder *scale.Encoder, _ *ChainProperties, blockNumber uint64, index int, event *Event) (map[string]interface{}, error) {
+	args, err := eventArgs(event)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(args))
+	for i, arg := range args {
+		err := encoder.Write(arg.Raw)
+		if err != nil {
+			return nil, err
+		}
+		fields[strconv.Itoa(i)] = hexutil.Encode(arg.Raw)
+	}
+	encoder.Encode(uint64(blockNumber))
+	encoder.Encode(uint64(index))
+
+	return fields, nil
+}
+
+// eventArgs returns the arguments of an event, encoding the fields of events decoded into
+// their type
+func eventArgs(event *Event) (EventArgs, error) {
+	if args, ok := event.Fields.(EventArgs); ok {
+		return args, nil
+	}
+
+	holder := reflect.ValueOf(event.Fields)
+	if holder.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fields of event %s.%s are not decoded", event.Name[0], event.Name[1])
+	}
+
+	args := make(EventArgs, 0, holder.NumField())
+	for i := 0; i < holder.NumField(); i++ {
+		var buf bytes.Buffer
+		err := scale.NewEncoder(&buf).Encode(holder.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, EventArg{
+			Type:  holder.Type().Field(i).Type.Name(),
+			Value: holder.Field(i).Interface(),
+			Raw:   buf.Bytes(),
+		})
+	}
+	return args, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/snowfork/go-substrate-rpc-client/scale"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestEventRegistry_Defaults(t *testing.T) {
+	registry := defaultEventRegistry()
+	event := transferEvents(2)[1]
+
+	var buf bytes.Buffer
+	app, fields, err := registry.Encode(scale.NewEncoder(&buf), &ChainProperties{}, 7, 3, &event)
+	require.NoError(t, err)
+	assert.Equal(t, "eth", app)
+	assert.Equal(t, "1", fields["amount"])
+
+	var expected bytes.Buffer
+	encoder := scale.NewEncoder(&expected)
+	transfer := event.Fields.(ETHTransfer)
+	require.NoError(t, encoder.Encode(transfer.AccountID))
+	require.NoError(t, encoder.Encode(transfer.Recipient))
+	require.NoError(t, encoder.Encode(transfer.Amount))
+	require.NoError(t, encoder.Encode(uint64(7)))
+	require.NoError(t, encoder.Encode(uint64(3)))
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+
+	buf.Reset()
+	app, _, err = registry.Encode(scale.NewEncoder(&buf), &ChainProperties{}, 7, 3, &Event{Name: [2]string{"Balances", "Transfer"}})
+	require.NoError(t, err)
+	assert.Equal(t, "", app)
+	assert.Zero(t, buf.Len())
+}
+
+func TestEventRegistry_Args(t *testing.T) {
+	registry, err := NewEventRegistry(map[string][]EventRoute{
+		"scheduler": {{Event: "Scheduler.Dispatched"}},
+		// replaces the default route of ETH transfers
+		"eth2": {{Event: "ETH.Transfer", Handler: "args"}},
+	})
+	require.NoError(t, err)
+
+	events, err := NewEventDecoder(MetadataExemplary).Decode(schedulerDispatchedRecords(t))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	app, fields, err := registry.Encode(scale.NewEncoder(&buf), &ChainProperties{}, 7, 0, &events[0])
+	require.NoError(t, err)
+	assert.Equal(t, "scheduler", app)
+	assert.Equal(t, map[string]interface{}{"0": "0x0500000001000000", "1": "0x0108abcd", "2": "0x00"}, fields)
+	assert.Equal(t, types.MustHexDecodeString("0x05000000010000000108abcd0007000000000000000000000000000000"), buf.Bytes())
+
+	event := transferEvents(1)[0]
+	buf.Reset()
+	app, fields, err = registry.Encode(scale.NewEncoder(&buf), &ChainProperties{}, 7, 0, &event)
+	require.NoError(t, err)
+	assert.Equal(t, "eth2", app)
+	assert.Len(t, fields, 3)
+}
+
+func TestEventRegistry_Invalid(t *testing.T) {
+	_, err := NewEventRegistry(map[string][]EventRoute{"app": {{Event: "Dispatched"}}})
+	assert.Error(t, err)
+
+	_, err = NewEventRegistry(map[string][]EventRoute{"app": {{Event: "Scheduler.Dispatched", Handler: "unknown"}}})
+	assert.Error(t, err)
+
+	// the typed handlers refuse events of other types
+	registry, err := NewEventRegistry(map[string][]EventRoute{"app": {{Event: "Balances.Transfer", Handler: "eth-transfer"}}})
+	require.NoError(t, err)
+	messages := make(chan chain.Message, 1)
+	li := newTestListener(messages)
+	li.Route(registry)
+	events := []Event{{Name: [2]string{"Balances", "Transfer"}, Fields: BalancesTransfer{}}}
+	require.NoError(t, li.handleEvents(context.Background(), 7, types.Hash{7}, events, false))
+	assert.Len(t, messages, 0)
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// HandshakeConfig negotiates the payload schema version with the pallet of each app
+type HandshakeConfig struct {
+	chain.HandshakeConfig `mapstructure:",squash"`
+	// Where the pallet of each app, keyed by app name, reports its version. Defaults to the
+	// PayloadVersion constant of the pallet named after the app in upper case, for example
+	// ETH for the eth app.
+	Versions map[string]VersionSource `mapstructure:"versions"`
+}
+
+// VersionSource is the constant or storage item of a pallet holding its payload schema
+// version, an unsigned integer
+type VersionSource struct {
+	Module string `mapstructure:"module"`
+	// Name of the constant, ignored if Storage is set
+	Constant string `mapstructure:"constant"`
+	// Name of the storage item
+	Storage string `mapstructure:"storage"`
+}
+
+const defaultVersionConstant = "PayloadVersion"
+
+// Handshake reads the payload schema version of the pallet of each app, and refuses to
+// relay unless the relayer supports all of them
+func (ch *Chain) Handshake(ctx context.Context) (*chain.CompatibilityReport, error) {
+	report := chain.NewCompatibilityReport(PalletVersions(ctx, ch.conn, ch.config))
+	for _, component := range report.Components {
+		logrus.WithFields(logrus.Fields{
+			"chain":     Name,
+			"app":       component.App,
+			"component": component.Component,
+			"version":   component.Version,
+		}).Info("Negotiated payload schema version")
+	}
+	return report, report.Err()
+}
+
+// PalletVersions reads the payload schema version of the pallet of each app, in the order
+// of their names
+func PalletVersions(ctx context.Context, conn Connection, config *Config) []chain.ComponentVersion {
+	names := make([]string, 0, len(config.Targets))
+	for name := range config.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make([]chain.ComponentVersion, 0, len(names))
+	for _, name := range names {
+		source, ok := config.Handshake.Versions[name]
+		if !ok {
+			source = VersionSource{Module: strings.ToUpper(name), Constant: defaultVersionConstant}
+		}
+
+		component := chain.ComponentVersion{Chain: Name, App: name, Component: source.String()}
+		version, err := palletVersion(ctx, conn, &source)
+		if err != nil {
+			component.Error = err.Error()
+		} else {
+			component.Version = version
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+func (vs *VersionSource) String() string {
+	if vs.Storage != "" {
+		return fmt.Sprintf("storage %s.%s", vs.Module, vs.Storage)
+	}
+	return fmt.Sprintf("constant %s.%s", vs.Module, vs.Constant)
+}
+
+func palletVersion(ctx context.Context, conn Connection, source *VersionSource) (uint64, error) {
+	if source.Storage == "" {
+		value, err := findConstant(conn.Metadata(), source.Module, source.Constant)
+		if err != nil {
+			return 0, err
+		}
+		return decodeVersion(value)
+	}
+
+	key, err := types.CreateStorageKey(conn.Metadata(), source.Module, source.Storage, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	value, err := conn.Client().GetStorageRawLatest(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if value == nil || len(*value) == 0 {
+		return 0, fmt.Errorf("storage item is empty")
+	}
+	return decodeVersion(*value)
+}
+
+// findConstant returns the SCALE encoding of a constant of a module in the metadata
+func findConstant(meta *types.Metadata, module string, name string) ([]byte, error) {
+	var constants []types.ModuleConstantMetadataV6
+	found := false
+	switch {
+	case meta.IsMetadataV8:
+		constants, found = constantsV8(meta.AsMetadataV8.Modules, module)
+	case meta.IsMetadataV9:
+		constants, found = constantsV8(meta.AsMetadataV9.Modules, module)
+	case meta.IsMetadataV10:
+		constants, found = constantsV10(meta.AsMetadataV10.Modules, module)
+	case meta.IsMetadataV11:
+		constants, found = constantsV10(meta.AsMetadataV11.Modules, module)
+	default:
+		return nil, fmt.Errorf("unsupported metadata version %d", meta.Version)
+	}
+	if !found {
+		return nil, fmt.Errorf("module %s not found in metadata", module)
+	}
+
+	for _, constant := range constants {
+		if string(constant.Name) == name {
+			return constant.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("constant %s.%s not found in metadata", module, name)
+}
+
+func constantsV8(modules []types.ModuleMetadataV8, name string) ([]types.ModuleConstantMetadataV6, bool) {
+	for _, module := range modules {
+		if string(module.Name) == name {
+			return module.Constants, true
+		}
+	}
+	return nil, false
+}
+
+func constantsV10(modules []types.ModuleMetadataV10, name string) ([]types.ModuleConstantMetadataV6, bool) {
+	for _, module := range modules {
+		if string(module.Name) == name {
+			return module.Constants, true
+		}
+	}
+	return nil, false
+}
+
+// decodeVersion decodes a version encoded as an unsigned integer of any width
+func decodeVersion(value []byte) (uint64, error) {
+	switch len(value) {
+	case 1:
+		return uint64(value[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(value)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(value)), nil
+	case 8:
+		return binary.LittleEndian.Uint64(value), nil
+	}
+	return 0, fmt.Errorf("version of %d bytes is not an unsigned integer", len(value))
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestPalletVersions(t *testing.T) {
+	client := NewMockClient()
+	conn := NewMockConnection(nil, MetadataExemplary, client)
+
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Number", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.SetStorage(key, types.U32(chain.PayloadSchemaVersion)))
+
+	config := &Config{
+		Targets: map[string][20]byte{"eth": {1}, "erc20": {2}, "dot": {3}},
+		Handshake: HandshakeConfig{
+			Versions: map[string]VersionSource{
+				"eth": {Module: "System", Storage: "Number"},
+				// a u32 constant of 2400
+				"dot": {Module: "System", Constant: "BlockHashCount"},
+			},
+		},
+	}
+
+	components := PalletVersions(context.Background(), conn, config)
+	require.Len(t, components, 3)
+
+	assert.Equal(t, chain.ComponentVersion{Chain: Name, App: "dot", Component: "constant System.BlockHashCount", Version: 2400}, components[0])
+	assert.Equal(t, "constant ERC20.PayloadVersion", components[1].Component)
+	assert.Equal(t, "constant ERC20.PayloadVersion not found in metadata", components[1].Error)
+	assert.Equal(t, chain.ComponentVersion{Chain: Name, App: "eth", Component: "storage System.Number", Version: chain.PayloadSchemaVersion}, components[2])
+
+	err = chain.NewCompatibilityReport(components).Err()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Substrate app dot (constant System.BlockHashCount) reports version 2400")
+}
+
+func TestDecodeVersion(t *testing.T) {
+	version, err := decodeVersion([]byte{2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), version)
+
+	version, err = decodeVersion([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(257), version)
+
+	_, err = decodeVersion(make([]byte, 16))
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+)
+
+// headFollower reports the finalized heads of the chain as they finalize. Heads are received
+// through a subscription, and polled while the node doesn't serve one, such as after the
+// subscription dropped. The subscription is renewed at each poll.
+type headFollower struct {
+	conn     Connection
+	sub      HeaderSubscription
+	interval time.Duration
+	// when the finalized head was last polled
+	polled time.Time
+	// whether the previous attempt to subscribe failed, so that failures are reported once
+	polling bool
+	log     *logrus.Entry
+}
+
+func newHeadFollower(conn Connection, interval time.Duration, log *logrus.Entry) *headFollower {
+	return &headFollower{
+		conn:     conn,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// next waits for the next finalized head, returning its header and hash
+func (hf *headFollower) next(ctx context.Context) (*types.Header, types.Hash, error) {
+	if hf.sub == nil {
+		// while the node doesn't serve a subscription, the head is polled once per interval
+		if !hf.subscribe(ctx) && !hf.polled.IsZero() {
+			sleep(ctx, hf.interval-time.Since(hf.polled))
+		}
+		// heads finalized before subscribing are not reported, so the latest one is polled
+		return hf.poll(ctx)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, types.Hash{}, ctx.Err()
+	case header, ok := <-hf.sub.Chan():
+		if ok {
+			hash, err := headerHash(&header)
+			if err != nil {
+				return nil, types.Hash{}, err
+			}
+			return &header, hash, nil
+		}
+		hf.log.Warn("Finalized head subscription closed, polling instead")
+	case err := <-hf.sub.Err():
+		hf.log.WithError(err).Warn("Finalized head subscription dropped, polling instead")
+	}
+
+	hf.sub.Unsubscribe()
+	hf.sub = nil
+	hf.polling = true
+	return hf.poll(ctx)
+}
+
+// subscribe attempts to subscribe to finalized heads, returning whether it succeeded
+func (hf *headFollower) subscribe(ctx context.Context) bool {
+	sub, err := hf.conn.Client().SubscribeFinalizedHeads(ctx)
+	if err != nil {
+		if hf.polling {
+			hf.log.WithError(err).Debug("Failed to renew finalized head subscription")
+		} else {
+			hf.log.WithError(err).Warn("Failed to subscribe to finalized heads, polling instead")
+		}
+		hf.polling = true
+		return false
+	}
+
+	if hf.polling {
+		hf.log.Info("Renewed finalized head subscription")
+	} else {
+		hf.log.Debug("Subscribed to finalized heads")
+	}
+	hf.sub = sub
+	hf.polling = false
+	return true
+}
+
+func (hf *headFollower) poll(ctx context.Context) (*types.Header, types.Hash, error) {
+	hf.polled = time.Now()
+
+	hash, err := hf.conn.Client().GetFinalizedHead(ctx)
+	if err != nil {
+		return nil, types.Hash{}, err
+	}
+
+	header, err := hf.conn.Client().GetHeader(ctx, hash)
+	if err != nil {
+		return nil, types.Hash{}, err
+	}
+
+	return header, hash, nil
+}
+
+// close ends the subscription, if any
+func (hf *headFollower) close() {
+	if hf.sub != nil {
+		hf.sub.Unsubscribe()
+		hf.sub = nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// LightClientConfig backs the reads of the relayer with a light client instead of a trusted
+// full node
+type LightClientConfig struct {
+	// Websocket endpoint of the JSON-RPC server of a light client following the chain, such
+	// as smoldot run next to the relayer. Disabled if empty.
+	Endpoint string `mapstructure:"endpoint"`
+	// Whether calls which the light client can't serve fail instead of falling back to the
+	// full node, so that nothing is read from a trusted node
+	Strict bool `mapstructure:"strict"`
+}
+
+// clientMethods are the methods called by Client, which are checked against those served by
+// a light client when connecting
+var clientMethods = []string{
+	"author_submitAndWatchExtrinsic",
+	"author_submitExtrinsic",
+	"chain_getBlockHash",
+	"chain_getFinalizedHead",
+	"chain_getHeader",
+	"chain_subscribeFinalizedHeads",
+	"state_getMetadata",
+	"state_getRuntimeVersion",
+	"state_getStorage",
+	"system_properties",
+}
+
+// lightRoutes routes the calls of a client backed by a light client. Methods which the light
+// client doesn't list in rpc_methods are sent to the full node, as are reads of blocks and
+// storage which the light client fails to serve, typically because it only keeps recent
+// blocks. Nothing is sent to the full node if it is nil.
+type lightRoutes struct {
+	// methods served by the light client, nil if it doesn't list them
+	methods map[string]bool
+	full    *rpcClient
+	log     *logrus.Entry
+}
+
+func (lr *lightRoutes) serves(method string) bool {
+	return lr.methods == nil || lr.methods[method]
+}
+
+// unsupported returns the methods of Client which the light client doesn't serve
+func (lr *lightRoutes) unsupported() []string {
+	methods := []string{}
+	for _, method := range clientMethods {
+		if !lr.serves(method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// retries returns whether a call which failed on the light client is made again on the
+// full node
+func (lr *lightRoutes) retries(ctx context.Context, method string) bool {
+	if lr.full == nil || ctx.Err() != nil {
+		return false
+	}
+	return strings.HasPrefix(method, "chain_") || strings.HasPrefix(method, "state_")
+}
+
+// route returns the client to which a call is sent first, counting the calls which are sent
+// to the full node as they aren't served by the light client
+func (rc *rpcClient) route(method string) *rpcClient {
+	if rc.light == nil || rc.light.full == nil || rc.light.serves(method) {
+		return rc
+	}
+	metrics.LightClientFallbacks.WithLabelValues(method, "unsupported").Inc()
+	return rc.light.full
+}
+
+// NewLightConnection creates a connection backed by a light client, whose calls fall back to
+// the full node at fallback where the light client can't serve them, unless fallback is
+// empty or the light client is strict. Calls are recorded in stats, or in fallbackStats once
+// they fell back; both may be nil.
+func NewLightConnection(light *LightClientConfig, fallback string, kp *signature.KeyringPair, overrides *PropertiesConfig, stats *chain.RPCStats, fallbackStats *chain.RPCStats, log *logrus.Entry) *RPCConnection {
+	co := NewConnection(light.Endpoint, kp, overrides, stats, log)
+	co.light = light
+	co.fallback = fallback
+	co.fallbackStats = fallbackStats
+	return co
+}
+
+// routeLightClient detects the methods served by the light client, and connects to the full
+// node to which the others are sent
+func (co *RPCConnection) routeLightClient(ctx context.Context) error {
+	routes := &lightRoutes{log: co.log.WithField("endpoint", chain.RedactEndpoint(co.endpoint))}
+
+	var res struct {
+		Methods []string `json:"methods"`
+	}
+	err := co.client.call(ctx, &res, "rpc_methods")
+	if err != nil {
+		routes.log.WithError(err).Warn("Light client doesn't list its methods, sending all calls to it")
+	} else {
+		routes.methods = make(map[string]bool, len(res.Methods))
+		for _, method := range res.Methods {
+			routes.methods[method] = true
+		}
+	}
+
+	if !co.light.Strict && co.fallback != "" {
+		full, err := dialClient(ctx, co.fallback, co.fallbackStats, co.dialer)
+		if err != nil {
+			return fmt.Errorf("connecting to full node: %w", err)
+		}
+		routes.full = full
+	}
+
+	co.client.light = routes
+
+	unsupported := routes.unsupported()
+	log := routes.log.WithFields(logrus.Fields{
+		"unsupported": strings.Join(unsupported, ","),
+		"fallback":    routes.full != nil,
+	})
+	if len(unsupported) > 0 && routes.full == nil {
+		log.Warn("Light client can't serve all calls of the relayer, and has no full node to fall back to")
+	} else {
+		log.Info("Detected methods of light client")
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	gethrpc "github.com/snowfork/go-substrate-rpc-client/gethrpc"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// lightChainAPI serves the hashes of the blocks it keeps, and fails for the others
+type lightChainAPI struct {
+	oldest uint64
+	calls  int
+}
+
+func (api *lightChainAPI) GetBlockHash(number uint64) (string, error) {
+	api.calls++
+	if number < api.oldest {
+		return "", fmt.Errorf("block %d is pruned", number)
+	}
+	hash := types.NewHash([]byte{1})
+	return types.HexEncodeToString(hash[:]), nil
+}
+
+type lightRPCAPI struct{}
+
+func (lightRPCAPI) Methods() map[string][]string {
+	return map[string][]string{"methods": {"chain_getBlockHash", "rpc_methods"}}
+}
+
+type fullChainAPI struct{}
+
+func (fullChainAPI) GetBlockHash(number uint64) (string, error) {
+	hash := types.NewHash([]byte{2})
+	return types.HexEncodeToString(hash[:]), nil
+}
+
+type fullStateAPI struct{}
+
+func (fullStateAPI) GetRuntimeVersion() types.RuntimeVersion {
+	return types.RuntimeVersion{SpecName: "full", SpecVersion: 7}
+}
+
+func newLightTestConnection(t *testing.T, strict bool) (*RPCConnection, *lightChainAPI) {
+	chainAPI := &lightChainAPI{oldest: 10}
+	light := gethrpc.NewServer()
+	assert.NoError(t, light.RegisterName("chain", chainAPI))
+	assert.NoError(t, light.RegisterName("rpc", lightRPCAPI{}))
+
+	full := gethrpc.NewServer()
+	assert.NoError(t, full.RegisterName("chain", fullChainAPI{}))
+	assert.NoError(t, full.RegisterName("state", fullStateAPI{}))
+
+	conn := NewLightConnection(&LightClientConfig{Strict: strict}, "", nil, &PropertiesConfig{}, nil, nil, logrus.NewEntry(logrus.New()))
+	conn.client = &rpcClient{rpc: gethrpc.DialInProc(light)}
+	assert.NoError(t, conn.routeLightClient(context.Background()))
+	if !strict {
+		conn.client.light.full = &rpcClient{rpc: gethrpc.DialInProc(full)}
+	}
+
+	return conn, chainAPI
+}
+
+func TestLightClient_ServesSupportedCalls(t *testing.T) {
+	conn, chainAPI := newLightTestConnection(t, false)
+	defer conn.Close()
+
+	hash, err := conn.Client().GetBlockHash(context.Background(), 12)
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewHash([]byte{1}), hash)
+	assert.Equal(t, 1, chainAPI.calls)
+}
+
+func TestLightClient_FallsBackForUnsupportedMethods(t *testing.T) {
+	conn, _ := newLightTestConnection(t, false)
+	defer conn.Close()
+
+	assert.Equal(t, []string{
+		"author_submitAndWatchExtrinsic",
+		"author_submitExtrinsic",
+		"chain_getFinalizedHead",
+		"chain_getHeader",
+		"chain_subscribeFinalizedHeads",
+		"state_getMetadata",
+		"state_getRuntimeVersion",
+		"state_getStorage",
+		"system_properties",
+	}, conn.client.light.unsupported())
+
+	version, err := conn.Client().GetRuntimeVersionLatest(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "full", string(version.SpecName))
+}
+
+func TestLightClient_FallsBackForFailedReads(t *testing.T) {
+	conn, chainAPI := newLightTestConnection(t, false)
+	defer conn.Close()
+
+	hash, err := conn.Client().GetBlockHash(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewHash([]byte{2}), hash)
+	assert.Equal(t, 1, chainAPI.calls)
+}
+
+func TestLightClient_Strict(t *testing.T) {
+	conn, _ := newLightTestConnection(t, true)
+	defer conn.Close()
+
+	_, err := conn.Client().GetBlockHash(context.Background(), 3)
+	assert.Error(t, err)
+
+	_, err = conn.Client().GetRuntimeVersionLatest(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"fmt"
+)
+
+// Check verifies that a message payload fits the limits
+func (l *Limits) Check(payload []byte) error {
+	if l.MaxPayloadSize > 0 && len(payload) > l.MaxPayloadSize {
+		return fmt.Errorf("payload is %d bytes, exceeding the limit of %d", len(payload), l.MaxPayloadSize)
+	}
+	return nil
+}
@@ -7,7 +7,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -16,117 +19,656 @@ import (
 	"github.com/snowfork/go-substrate-rpc-client/scale"
 	types "github.com/snowfork/go-substrate-rpc-client/types"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 )
 
 type Listener struct {
+	// decodes events with the latest metadata, replaced once the metadata is refreshed
+	decoderMutex sync.Mutex
 	eventDecoder *EventDecoder
 	config       *Config
-	conn         *Connection
-	messages     chan<- chain.Message
+	conn         Connection
+	// genesis hash recorded in the provenance of messages, a string once fetched
+	genesis    atomic.Value
+	messages   chan<- chain.Message
+	quarantine chain.Quarantine
+	blocks     chain.BlockLog
+	progress   *chain.Progress
+	clock      *chain.ClockMonitor
+	checkpoint *chain.Checkpoint
+	// records the advancing checkpoint, may be nil
+	checkpoints chain.CheckpointStore
+	// records the last handled block, from which the listener resumes, may be nil
+	cursors chain.CursorStore
+	// notified of observed events, may be nil
+	events chain.EventFeed
+	// closed once the consumer of messages stops, may be nil
+	stopped <-chan struct{}
+	// events already enqueued, which are seen again when repaired
+	seen *chain.Deduplicator
+	// retries calls which failed with a transient error, may be nil
+	backoff *chain.Backoff
+	// routes the events to their app and encodes their messages
+	registry *EventRegistry
+	// encrypts the payloads of the apps configured for encryption, nil if none is
+	sealer *Sealer
+	// routes the messages to the recipients of routing overrides, nil if none is configured
+	overrides *chain.Overrides
+	// interval between polls of the finalized head
+	pollInterval time.Duration
 	log          *logrus.Entry
 }
 
-func NewListener(config *Config, conn *Connection, messages chan<- chain.Message, log *logrus.Entry) *Listener {
+const defaultPollInterval = 10 * time.Second
+
+func NewListener(config *Config, conn Connection, messages chan<- chain.Message, quarantine chain.Quarantine, blocks chain.BlockLog, checkpoint *chain.Checkpoint, checkpoints chain.CheckpointStore, cursors chain.CursorStore, events chain.EventFeed, stopped <-chan struct{}, log *logrus.Entry) *Listener {
 	return &Listener{
-		eventDecoder: NewEventDecoder(&conn.metadata),
 		config:       config,
 		conn:         conn,
 		messages:     messages,
+		quarantine:   quarantine,
+		blocks:       blocks,
+		progress:     chain.NewProgress(Name),
+		clock:        chain.NewClockMonitor(&config.Clock, log),
+		checkpoint:   checkpoint,
+		checkpoints:  checkpoints,
+		cursors:      cursors,
+		events:       events,
+		stopped:      stopped,
+		seen:         chain.NewDeduplicator(Name),
+		backoff:      chain.NewBackoff(Name, &config.RPC.Backoff),
+		registry:     defaultEventRegistry(),
+		pollInterval: defaultPollInterval,
 		log:          log,
 	}
 }
 
+// Encrypt sets the sealer which encrypts the payloads of the apps configured for encryption.
+// It must be set before starting.
+func (li *Listener) Encrypt(sealer *Sealer) {
+	li.sealer = sealer
+}
+
+// Override sets the routing overrides matched against the recipients of the events. It must
+// be set before starting.
+func (li *Listener) Override(overrides *chain.Overrides) {
+	li.overrides = overrides
+}
+
+// Route sets the registry which routes the events to their app. Only the default routes
+// are relayed if none is set. It must be set before starting.
+func (li *Listener) Route(registry *EventRegistry) {
+	li.registry = registry
+}
+
 func (li *Listener) Start(ctx context.Context, eg *errgroup.Group) error {
 	eg.Go(func() error {
-		return li.pollBlocks(ctx)
+		return li.followBlocks(ctx)
 	})
 
 	return nil
 }
 
-func (li *Listener) pollBlocks(ctx context.Context) error {
-	storageKey, err := types.CreateStorageKey(&li.conn.metadata, "System", "Events", nil, nil)
+// followBlocks processes every block as it finalizes, following the finalized heads from a
+// subscription, or by polling while the node doesn't serve one
+func (li *Listener) followBlocks(ctx context.Context) error {
+	if li.checkpoint != nil {
+		err := li.pinCheckpoint(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := li.backoff.Retry(ctx, li.log, "fetch genesis hash", func() error {
+		genesis, err := li.conn.Client().GetBlockHash(ctx, 0)
+		if err == nil {
+			li.genesis.Store(genesis.Hex())
+		}
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	// Get current block
-	block, err := li.conn.api.RPC.Chain.GetHeaderLatest()
+	var block *types.Header
+	err = li.backoff.Retry(ctx, li.log, "fetch latest block", func() error {
+		var err error
+		block, err = li.conn.Client().GetHeaderLatest(ctx)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	currentBlock := uint64(block.Number)
+	currentBlock := li.resumeBlock(uint64(block.Number))
+
+	heads := newHeadFollower(li.conn, li.pollInterval, li.log)
+	defer heads.close()
+
+	var prefetch *prefetcher
+	if li.config.Prefetch {
+		prefetch = newPrefetcher(li.prefetchBlock, li.log)
+		defer prefetch.stop()
+	}
+
+	var finalized uint64
+	var finalizedHeader *types.Header
+	var finalizedHash types.Hash
+	// Timestamps are only checked once for each new finalized head
+	var checkedHash types.Hash
 
-	retryInterval := time.Duration(10) * time.Second
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			return ctx.Err()
-		default:
+		}
 
-			li.log.WithField("block", currentBlock).Debug("Processing block")
+		// Wait for a new finalized head once all finalized blocks are processed
+		if currentBlock > finalized {
+			if prefetch != nil {
+				prefetch.start(ctx, currentBlock)
+			}
 
-			// Get block hash
-			finalizedHash, err := li.conn.api.RPC.Chain.GetFinalizedHead()
+			err := li.backoff.Retry(ctx, li.log, "fetch finalized head", func() error {
+				var err error
+				finalizedHeader, finalizedHash, err = heads.next(ctx)
+				return err
+			})
 			if err != nil {
 				li.log.WithError(err).Error("Failed to fetch finalized head")
-				sleep(ctx, retryInterval)
-				continue
+				return err
 			}
 
-			// Get block header
-			finalizedHeader, err := li.conn.api.RPC.Chain.GetHeader(finalizedHash)
-			if err != nil {
-				li.log.WithError(err).Error("Failed to fetch header for finalized head")
-				sleep(ctx, retryInterval)
-				continue
+			if li.clock.Enabled() && finalizedHash != checkedHash {
+				li.checkTimestamp(ctx, uint64(finalizedHeader.Number), finalizedHash)
+				checkedHash = finalizedHash
 			}
 
-			// Sleep if the block we want comes after the most recently finalized block
-			if currentBlock > uint64(finalizedHeader.Number) {
+			finalized = uint64(finalizedHeader.Number)
+			if currentBlock > finalized {
 				li.log.WithFields(logrus.Fields{
 					"block":  currentBlock,
-					"latest": finalizedHeader.Number,
+					"latest": finalized,
 				}).Trace("Block not yet finalized")
-				sleep(ctx, retryInterval)
-				continue
 			}
+			li.progress.Update(finalized, currentBlock-1)
+			continue
+		}
 
-			// Get hash for latest block, sleep and retry if not ready
-			hash, err := li.conn.api.RPC.Chain.GetBlockHash(currentBlock)
-			if err != nil {
-				li.log.WithFields(logrus.Fields{
-					"error": err,
-					"block": currentBlock,
-				}).Error("Failed to fetch block hash")
-				sleep(ctx, retryInterval)
-				continue
-			}
+		li.log.WithField("block", currentBlock).Debug("Processing block")
 
-			var records types.EventRecordsRaw
-			_, err = li.conn.api.RPC.State.GetStorage(storageKey, &records, hash)
-			if err != nil {
-				li.log.WithError(err).Error("Failed to fetch events for block")
-				sleep(ctx, retryInterval)
-				continue
-			}
+		traced, fetch := tracing.Start(ctx, "fetch block")
+		fetch.SetAttribute("chain", Name)
+		fetch.SetAttribute("block", currentBlock)
 
-			li.log.WithField("record", hex.EncodeToString(records)).Trace("Fetched event record")
+		var hash types.Hash
+		var events []Event
+		prefetched := false
+		if prefetch != nil {
+			hash, events, prefetched = prefetch.take(ctx, currentBlock, finalizedHeader, finalizedHash, li.conn.Client().GetBlockHash)
+			// blocks failing the verification are fetched again, which verifies them with retries
+			prefetched = prefetched && li.verifyAncestry(ctx, hash) == nil
+		}
+		fetch.SetAttribute("prefetched", prefetched)
 
-			events, err := li.eventDecoder.Decode(records)
+		if !prefetched {
+			err := li.backoff.Retry(ctx, li.log.WithField("block", currentBlock), "fetch block events", func() error {
+				var err error
+				hash, events, err = li.blockEvents(ctx, currentBlock)
+				return err
+			})
 			if err != nil {
-				li.log.WithFields(logrus.Fields{
-					"error": err,
-					"block": currentBlock,
-				}).Error("Failed to decode events for block")
+				fetch.End(err)
+				li.log.WithError(err).WithField("block", currentBlock).Error("Failed to fetch events for block")
 				return err
 			}
+		}
+		fetch.End(nil)
+
+		// the block is processed again after a restart if its messages weren't all sent
+		err := li.handleEvents(traced, currentBlock, hash, events, false)
+		if err != nil {
+			return err
+		}
+		li.markProcessed(currentBlock)
+		li.saveCursor(currentBlock)
+		li.saveCheckpoint()
+		li.progress.Update(finalized, currentBlock)
+
+		currentBlock++
+	}
+}
+
+// send queues a message for the target app, or quarantines it if it exceeds the app's limits.
+// An error is returned if the message was persisted instead of being queued, because the
+// listener is shutting down or the consumer stopped.
+func (li *Listener) send(ctx context.Context, blockNumber uint64, app string, msg chain.Message) error {
+	// the payloads generated by the listener are always encoded bytes
+	payload, _ := msg.Payload.([]byte)
+
+	limits := li.config.Limits[app]
+	err := limits.Check(payload)
+	if err == nil {
+		_, enqueue := tracing.Start(ctx, "enqueue message")
+		// the spans of the delivery descend from the enqueue of the message
+		msg.Trace = enqueue.Context()
+		err = chain.Handoff(ctx, li.messages, li.stopped, li.quarantine, Name, msg)
+		enqueue.End(err)
+		if err != nil {
+			li.log.WithError(err).WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"app":         app,
+			}).Warn("Persisted message which could not be queued")
+			return err
+		}
+		metrics.MessagesEnqueued.WithLabelValues(Name, app).Inc()
+		return nil
+	}
+
+	log := li.log.WithFields(logrus.Fields{
+		"blockNumber": blockNumber,
+		"app":         app,
+		"reason":      err,
+	})
+	log.Warn("Rejected message exceeding payload limits")
+
+	err = li.quarantine.Quarantine(Name, &msg, err.Error())
+	if err != nil {
+		log.WithError(err).Error("Failed to quarantine message")
+	}
+	return nil
+}
+
+// Progress returns the block processing progress of the listener
+func (li *Listener) Progress() *chain.Progress {
+	return li.progress
+}
+
+// Repair reprocesses the events of a range of blocks which were skipped
+func (li *Listener) Repair(ctx context.Context, from uint64, to uint64) error {
+	for number := from; number <= to; number++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		traced, hash, events, err := li.fetchBlock(ctx, number)
+		if err != nil {
+			return err
+		}
+
+		err = li.handleEvents(traced, number, hash, events, false)
+		if err != nil {
+			return err
+		}
+		li.markProcessed(number)
+	}
+
+	li.log.WithFields(logrus.Fields{
+		"from": from,
+		"to":   to,
+	}).Info("Repaired blocks")
+
+	return nil
+}
+
+// Replay re-emits the messages of the events of a range of blocks, up to the latest finalized
+// block if to is zero, including events which were already enqueued. Neither the cursor nor
+// the processed blocks are updated. Returns the last replayed block.
+func (li *Listener) Replay(ctx context.Context, from uint64, to uint64) (uint64, error) {
+	if to == 0 {
+		hash, err := li.conn.Client().GetFinalizedHead(ctx)
+		if err != nil {
+			return 0, err
+		}
+		header, err := li.conn.Client().GetHeader(ctx, hash)
+		if err != nil {
+			return 0, err
+		}
+		to = uint64(header.Number)
+	}
+	if from > to {
+		return 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+
+	for number := from; number <= to; number++ {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		traced, hash, events, err := li.fetchBlock(ctx, number)
+		if err != nil {
+			return 0, err
+		}
+
+		err = li.handleEvents(traced, number, hash, events, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	li.log.WithFields(logrus.Fields{
+		"from": from,
+		"to":   to,
+	}).Info("Replayed blocks")
+
+	return to, nil
+}
+
+// EventMessage rebuilds the message which the listener generates for the event at an
+// index of a block, without queueing it. Messages exceeding the limits of their app are
+// refused, as the listener would quarantine them.
+func (li *Listener) EventMessage(ctx context.Context, number uint64, index uint64) (*chain.Message, error) {
+	hash, events, err := li.blockEvents(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if index >= uint64(len(events)) {
+		return nil, chain.ErrEventNotFound
+	}
+
+	var buf bytes.Buffer
+	app, fields, err := li.registry.Encode(scale.NewEncoder(&buf), li.conn.Properties(), number, int(index), &events[index])
+	if err != nil {
+		return nil, err
+	}
+	if app == "" {
+		return nil, chain.ErrEventNotFound
+	}
+
+	msg := &chain.Message{AppID: li.config.Targets[app], Payload: buf.Bytes(), SourceBlock: number, Network: li.config.Networks[app]}
+	li.attribute(msg, hash, index)
+	li.overrides.Match(fields).Apply(msg)
+	err = li.sealer.Seal(app, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := li.config.Limits[app]
+	err = limits.Check(msg.Payload.([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// attribute records the provenance of a message generated for an event of a block
+func (li *Listener) attribute(msg *chain.Message, hash types.Hash, index uint64) {
+	msg.Source = Name
+	msg.SourceChainID, _ = li.genesis.Load().(string)
+	msg.SourceHash = hash.Hex()
+	msg.EventIndex = index
+}
+
+// fetchBlock fetches and decodes the events of a block, with the context of the span of
+// its fetch from which the spans of its messages descend
+func (li *Listener) fetchBlock(ctx context.Context, number uint64) (context.Context, types.Hash, []Event, error) {
+	ctx, span := tracing.Start(ctx, "fetch block")
+	span.SetAttribute("chain", Name)
+	span.SetAttribute("block", number)
+
+	hash, events, err := li.blockEvents(ctx, number)
+	span.End(err)
+	return ctx, hash, events, err
+}
+
+// blockEvents fetches and decodes the events of a block, once its ancestry is verified
+func (li *Listener) blockEvents(ctx context.Context, number uint64) (types.Hash, []Event, error) {
+	hash, err := li.conn.Client().GetBlockHash(ctx, number)
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+
+	err = li.verifyAncestry(ctx, hash)
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+
+	events, err := li.eventsAt(ctx, hash)
+	if err != nil {
+		return types.Hash{}, nil, err
+	}
+	return hash, events, nil
+}
+
+// eventsAt fetches and decodes the events of the block with the given hash
+func (li *Listener) eventsAt(ctx context.Context, hash types.Hash) ([]Event, error) {
+	storageKey, err := types.CreateStorageKey(li.conn.Metadata(), "System", "Events", nil, nil)
+	if err != nil {
+		return nil, chain.Permanent(err)
+	}
 
-			li.handleEvents(currentBlock, events)
+	var records types.EventRecordsRaw
+	_, err = li.conn.Client().GetStorage(ctx, storageKey, &records, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	li.log.WithField("record", hex.EncodeToString(records)).Trace("Fetched event record")
+
+	events, err := li.decoder().Decode(records)
+	if err != nil {
+		events, err = li.decodeUpgraded(ctx, hash, records)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, event := range events {
+		if event.Name == [2]string{"System", "CodeUpdated"} {
+			li.refreshMetadata(ctx, hash)
+			break
+		}
+	}
+	return events, nil
+}
+
+// decoder returns the decoder of events for the latest metadata of the connection
+func (li *Listener) decoder() *EventDecoder {
+	li.decoderMutex.Lock()
+	defer li.decoderMutex.Unlock()
+
+	meta := li.conn.Metadata()
+	if li.eventDecoder == nil || li.eventDecoder.meta != meta {
+		li.eventDecoder = NewEventDecoder(meta)
+	}
+	return li.eventDecoder
+}
+
+// decodeUpgraded decodes the events of a block which the latest metadata can't decode. These
+// are emitted by a runtime which the relayer has not seen yet, whose metadata is fetched, or
+// by an older runtime when a block is repaired after an upgrade. Events which can't be
+// decoded with the metadata of their own runtime are not retried.
+func (li *Listener) decodeUpgraded(ctx context.Context, hash types.Hash, records []byte) ([]Event, error) {
+	header, err := li.conn.Client().GetHeader(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
 
-			currentBlock++
+	// events are emitted by the runtime in the state of the parent block
+	parent := header.ParentHash
+	refreshed, err := li.conn.RefreshMetadata(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	if refreshed {
+		li.log.WithField("blockNumber", header.Number).Info("Runtime was upgraded, refreshed metadata")
+		events, err := li.decoder().Decode(records)
+		if err == nil {
+			return events, nil
 		}
 	}
+
+	meta, err := li.conn.Client().GetMetadata(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	events, err := NewEventDecoder(meta).Decode(records)
+	if err != nil {
+		return nil, chain.Permanent(err)
+	}
+	return events, nil
+}
+
+// refreshMetadata fetches the metadata of the runtime which a block upgraded to, so that
+// the events of the blocks after it are decoded with it
+func (li *Listener) refreshMetadata(ctx context.Context, hash types.Hash) {
+	refreshed, err := li.conn.RefreshMetadata(ctx, hash)
+	if err != nil {
+		// the metadata is fetched once the events of a later block can't be decoded
+		li.log.WithError(err).Warn("Failed to refresh metadata after runtime upgrade")
+		return
+	}
+	if refreshed {
+		li.log.WithField("blockHash", hash.Hex()).Info("Runtime was upgraded, refreshed metadata")
+	}
+}
+
+// pinCheckpoint verifies the block which the node serves at the height of the checkpoint
+func (li *Listener) pinCheckpoint(ctx context.Context) error {
+	hash, err := li.conn.Client().GetBlockHash(ctx, li.checkpoint.Number())
+	if err != nil {
+		return err
+	}
+
+	header, err := li.fetchHeader(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	err = li.checkpoint.Pin(header)
+	if err != nil {
+		li.log.WithError(err).Error("Refusing to relay from a chain which does not match the checkpoint")
+		return err
+	}
+
+	li.log.WithField("blockNumber", header.Number).Info("Verified trusted checkpoint")
+	return nil
+}
+
+// verifyAncestry checks that a block descends from the checkpoint, if one is pinned
+func (li *Listener) verifyAncestry(ctx context.Context, hash types.Hash) error {
+	if li.checkpoint == nil {
+		return nil
+	}
+
+	fetch := func(hash [32]byte) (*chain.Header, error) {
+		return li.fetchHeader(ctx, hash)
+	}
+
+	header, err := fetch(hash)
+	if err != nil {
+		return err
+	}
+
+	err = li.checkpoint.Connect(header, fetch)
+	if errors.Is(err, chain.ErrUntrustedHistory) {
+		li.log.WithError(err).Error("Refusing to relay from a chain history which does not descend from the checkpoint")
+	}
+	return err
+}
+
+func (li *Listener) fetchHeader(ctx context.Context, hash [32]byte) (*chain.Header, error) {
+	header, err := li.conn.Client().GetHeader(ctx, types.Hash(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	// the hash is recomputed as the node could serve any header for it
+	computed, err := headerHash(header)
+	if err != nil {
+		return nil, err
+	}
+	if computed != types.Hash(hash) {
+		return nil, fmt.Errorf("%w: node served a header not matching hash %s", chain.ErrUntrustedHistory, types.Hash(hash).Hex())
+	}
+
+	return &chain.Header{
+		Number:     uint64(header.Number),
+		Hash:       hash,
+		ParentHash: header.ParentHash,
+		StateRoot:  header.StateRoot,
+	}, nil
+}
+
+// checkTimestamp validates the timestamp of a block against local time
+func (li *Listener) checkTimestamp(ctx context.Context, number uint64, hash types.Hash) {
+	key, err := types.CreateStorageKey(li.conn.Metadata(), "Timestamp", "Now", nil, nil)
+	if err != nil {
+		li.log.WithError(err).Error("Failed to create storage key for block timestamp")
+		return
+	}
+
+	var moment types.U64
+	_, err = li.conn.Client().GetStorage(ctx, key, &moment, hash)
+	if err != nil {
+		li.log.WithError(err).WithField("blockNumber", number).Warn("Failed to fetch block timestamp")
+		return
+	}
+
+	// Timestamps are in milliseconds
+	li.clock.Observe(number, time.Unix(0, int64(moment)*int64(time.Millisecond)))
+}
+
+// saveCheckpoint records the checkpoint once it has advanced, so that it survives restarts
+func (li *Listener) saveCheckpoint() {
+	if li.checkpoint == nil || li.checkpoints == nil {
+		return
+	}
+
+	err := li.checkpoint.Save(li.checkpoints, Name)
+	if err != nil {
+		li.log.WithError(err).Warn("Failed to record checkpoint")
+	}
+}
+
+// resumeBlock returns the block following the last handled one, so that the events emitted
+// while the relayer was down are processed. Without a recorded cursor, or if the cursor is
+// ahead of the chain, which was reset, processing starts from the latest block.
+func (li *Listener) resumeBlock(latest uint64) uint64 {
+	if li.cursors == nil {
+		return latest
+	}
+
+	cursor, ok, err := li.cursors.LoadCursor(Name)
+	if err != nil {
+		li.log.WithError(err).Warn("Failed to load last handled block, starting from the latest block")
+		return latest
+	}
+	if !ok {
+		return latest
+	}
+
+	log := li.log.WithFields(logrus.Fields{
+		"cursor": cursor,
+		"latest": latest,
+	})
+	if cursor > latest {
+		log.Warn("Last handled block is ahead of the chain, starting from the latest block")
+		return latest
+	}
+
+	log.Info("Resuming from the last handled block")
+	return cursor + 1
+}
+
+// saveCursor records the last handled block, so that the listener resumes from it after a restart
+func (li *Listener) saveCursor(number uint64) {
+	if li.cursors == nil {
+		return
+	}
+
+	err := li.cursors.SaveCursor(Name, number)
+	if err != nil {
+		li.log.WithError(err).WithField("blockNumber", number).Warn("Failed to record last handled block")
+	}
+}
+
+func (li *Listener) markProcessed(number uint64) {
+	metrics.BlocksProcessed.WithLabelValues(Name).Inc()
+	err := li.blocks.MarkProcessed(Name, number)
+	if err != nil {
+		li.log.WithError(err).WithField("blockNumber", number).Error("Failed to record processed block")
+	}
 }
 
 func sleep(ctx context.Context, delay time.Duration) {
@@ -136,42 +678,87 @@ func sleep(ctx context.Context, delay time.Duration) {
 	}
 }
 
-// Process transfer events in the block
-func (li *Listener) handleEvents(blockNumber uint64, events []Event) {
+// Process transfer events in the block, until a message can't be queued. Replayed events are
+// queued even if they were already enqueued.
+func (li *Listener) handleEvents(ctx context.Context, blockNumber uint64, hash types.Hash, events []Event, replay bool) error {
+	// a single pooled buffer and encoder are reused for all payloads of the block
+	buf := chain.GetBuffer()
+	defer chain.PutBuffer(buf)
+	encoder := scale.NewEncoder(buf)
+	debug := li.log.Logger.IsLevelEnabled(logrus.DebugLevel)
 
 	for i, event := range events {
 
-		li.log.WithFields(logrus.Fields{
-			"blockNumber": blockNumber,
-			"name":        fmt.Sprintf("%s.%s", event.Name[0], event.Name[1]),
-		}).Debug("Witnessed event")
+		if debug {
+			li.log.WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"name":        fmt.Sprintf("%s.%s", event.Name[0], event.Name[1]),
+			}).Debug("Witnessed event")
+		}
 
-		switch fields := event.Fields.(type) {
-		case ETHTransfer:
-			buf := bytes.NewBuffer(nil)
-			encoder := scale.NewEncoder(buf)
-			encoder.Encode(fields.AccountID)
-			encoder.Encode(fields.Recipient)
-			encoder.Encode(fields.Amount)
-			encoder.Encode(uint64(blockNumber))
-			encoder.Encode(uint64(i))
+		buf.Reset()
+		traced, decode := tracing.Start(ctx, "decode event")
+		decode.SetAttribute("chain", Name)
+		decode.SetAttribute("block", blockNumber)
+		decode.SetAttribute("index", i)
+		app, fields, err := li.registry.Encode(encoder, li.conn.Properties(), blockNumber, i, &event)
+		decode.End(err)
+		if err != nil {
+			li.log.WithError(err).WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"index":       i,
+			}).Error("Skipped event which could not be encoded")
+			continue
+		}
+		if app == "" {
+			continue
+		}
+		metrics.EventsDecoded.WithLabelValues(Name, app).Inc()
 
-			targetAppID := li.config.Targets["eth"]
+		if !li.seen.Observe(chain.EventKey{BlockHash: hash, Index: uint64(i)}) && !replay {
+			li.log.WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"index":       i,
+			}).Debug("Skipped event which was already enqueued")
+			continue
+		}
 
-			li.messages <- chain.Message{AppID: targetAppID, Payload: buf.Bytes()}
-		case ERC20Transfer:
-			buf := bytes.NewBuffer(nil)
-			encoder := scale.NewEncoder(buf)
-			encoder.Encode(fields.AccountID)
-			encoder.Encode(fields.Recipient)
-			encoder.Encode(fields.TokenID)
-			encoder.Encode(fields.Amount)
-			encoder.Encode(uint64(blockNumber))
-			encoder.Encode(uint64(i))
+		msg := chain.Message{AppID: li.config.Targets[app], Payload: chain.CopyBytes(buf), ObservedAt: time.Now(), SourceBlock: blockNumber, Replayed: replay, Network: li.config.Networks[app]}
+		li.attribute(&msg, hash, uint64(i))
+		li.overrides.Match(fields).Apply(&msg)
+		err = li.sealer.Seal(app, &msg)
+		if err != nil {
+			li.log.WithError(err).WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"index":       i,
+			}).Error("Skipped event whose payload could not be encrypted")
+			continue
+		}
+		li.observe(blockNumber, hash, i, &event, app, fields, &msg)
+		err = li.send(traced, blockNumber, app, msg)
+		if err != nil {
+			return err
+		}
+	}
 
-			targetAppID := li.config.Targets["erc20"]
+	return nil
+}
 
-			li.messages <- chain.Message{AppID: targetAppID, Payload: buf.Bytes()}
-		}
+// observe notifies the event feed of a decoded app event and the message generated for it
+func (li *Listener) observe(blockNumber uint64, hash types.Hash, index int, event *Event, app string, fields map[string]interface{}, msg *chain.Message) {
+	if li.events == nil {
+		return
 	}
+
+	li.events.Observed(&chain.ObservedEvent{
+		Chain:       Name,
+		App:         app,
+		Name:        fmt.Sprintf("%s.%s", event.Name[0], event.Name[1]),
+		BlockNumber: blockNumber,
+		BlockHash:   hash.Hex(),
+		Index:       uint64(index),
+		Fields:      fields,
+		ObservedAt:  time.Now(),
+		Message:     msg,
+	})
 }
@@ -0,0 +1,394 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/scale"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func newTestListener(messages chan chain.Message) *Listener {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return &Listener{
+		config:   &Config{},
+		conn:     NewMockConnection(nil, MetadataExemplary, NewMockClient()),
+		messages: messages,
+		seen:     chain.NewDeduplicator(Name),
+		registry: defaultEventRegistry(),
+		log:      logrus.NewEntry(logger),
+	}
+}
+
+func transferEvents(n int) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = Event{
+			Name: [2]string{"ETH", "Transfer"},
+			Fields: ETHTransfer{
+				AccountID: types.AccountID{byte(i)},
+				Recipient: types.H160{byte(i)},
+				Amount:    types.NewU256(*big.NewInt(int64(i))),
+			},
+		}
+	}
+	return events
+}
+
+func TestHandleEvents(t *testing.T) {
+	events := transferEvents(3)
+	messages := make(chan chain.Message, len(events))
+	li := newTestListener(messages)
+
+	require.NoError(t, li.handleEvents(context.Background(), 7, types.Hash{7}, events, false))
+	require.Len(t, messages, len(events))
+
+	// each payload is encoded on its own, although the encoding buffer is reused
+	for i, event := range events {
+		var expected bytes.Buffer
+		encoder := scale.NewEncoder(&expected)
+		fields := event.Fields.(ETHTransfer)
+		require.NoError(t, encoder.Encode(fields.AccountID))
+		require.NoError(t, encoder.Encode(fields.Recipient))
+		require.NoError(t, encoder.Encode(fields.Amount))
+		require.NoError(t, encoder.Encode(uint64(7)))
+		require.NoError(t, encoder.Encode(uint64(i)))
+
+		msg := <-messages
+		assert.Equal(t, expected.Bytes(), msg.Payload)
+	}
+}
+
+func TestHandleEvents_Duplicates(t *testing.T) {
+	events := transferEvents(2)
+	messages := make(chan chain.Message, 2*len(events))
+	li := newTestListener(messages)
+
+	// the same block observed twice, such as by a repair, is only enqueued once
+	require.NoError(t, li.handleEvents(context.Background(), 7, types.Hash{7}, events, false))
+	require.NoError(t, li.handleEvents(context.Background(), 7, types.Hash{7}, events, false))
+	assert.Len(t, messages, len(events))
+
+	// a block with a different hash at the same height is not a duplicate
+	require.NoError(t, li.handleEvents(context.Background(), 7, types.Hash{8}, events, false))
+	assert.Len(t, messages, 2*len(events))
+}
+
+type unsentMessages struct {
+	messages []chain.Message
+}
+
+func (um *unsentMessages) Quarantine(_ string, msg *chain.Message, _ string) error {
+	um.messages = append(um.messages, *msg)
+	return nil
+}
+
+func TestHandleEvents_ConsumerStopped(t *testing.T) {
+	events := transferEvents(2)
+	messages := make(chan chain.Message)
+	stopped := make(chan struct{})
+	unsent := &unsentMessages{}
+	li := newTestListener(messages)
+	li.quarantine = unsent
+	li.stopped = stopped
+
+	// the listener gives up on a consumer which stopped, rather than blocking forever
+	close(stopped)
+	err := li.handleEvents(context.Background(), 7, types.Hash{7}, events, false)
+	assert.Equal(t, chain.ErrConsumerStopped, err)
+	require.Len(t, unsent.messages, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	li.stopped = nil
+	err = li.handleEvents(ctx, 7, types.Hash{8}, events, false)
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, unsent.messages, 2)
+}
+
+func BenchmarkHandleEvents(b *testing.B) {
+	events := transferEvents(1000)
+	messages := make(chan chain.Message, len(events))
+	li := newTestListener(messages)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		err := li.handleEvents(context.Background(), 1, types.Hash{byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, events, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for range events {
+			<-messages
+		}
+	}
+}
+
+// eventID returns the ID of an event in the exemplary metadata
+func eventID(t *testing.T, module string, name string) types.EventID {
+	for i := 0; i < 32; i++ {
+		for j := 0; j < 8; j++ {
+			candidate := types.EventID{byte(i), byte(j)}
+			moduleName, eventName, err := MetadataExemplary.FindEventNamesForEventID(candidate)
+			if err == nil && string(moduleName) == module && string(eventName) == name {
+				return candidate
+			}
+		}
+	}
+	require.Fail(t, "event not found", "%s.%s", module, name)
+	return types.EventID{}
+}
+
+// ethTransferRecords encodes the event records of a block with a single ETH transfer
+func ethTransferRecords(t *testing.T, transfer ETHTransfer) types.EventRecordsRaw {
+	id := eventID(t, "ETH", "Transfer")
+
+	var buf bytes.Buffer
+	encoder := scale.NewEncoder(&buf)
+	require.NoError(t, encoder.EncodeUintCompact(*big.NewInt(1)))
+	require.NoError(t, encoder.Encode(types.Phase{IsApplyExtrinsic: true}))
+	require.NoError(t, encoder.Encode(id))
+	require.NoError(t, encoder.Encode(transfer))
+	require.NoError(t, encoder.Encode([]types.Hash{}))
+	return types.EventRecordsRaw(buf.Bytes())
+}
+
+func TestListener_MockConnection(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, MetadataExemplary, client)
+
+	transfer := ETHTransfer{
+		AccountID: types.AccountID{1},
+		Recipient: types.H160{2},
+		Amount:    types.NewU256(*big.NewInt(3)),
+	}
+	hash := client.AddBlock()
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Events", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.SetBlockStorage(hash, key, ethTransferRecords(t, transfer)))
+
+	app := [20]byte{9}
+	config := &Config{Targets: map[string][20]byte{"eth": app}}
+	messages := make(chan chain.Message, 1)
+	blocks := &processedBlocks{}
+	listener := NewListener(config, conn, messages, nil, blocks, nil, nil, nil, nil, nil, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	var msg chain.Message
+	select {
+	case msg = <-messages:
+		assert.Equal(t, app, msg.AppID)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// repairing the block again does not enqueue the transfer a second time
+	require.NoError(t, listener.Repair(ctx, 1, 1))
+	assert.Len(t, messages, 0)
+	assert.Contains(t, blocks.processed(), uint64(1))
+
+	// the message can be rebuilt for users who relay the event themselves
+	rebuilt, err := listener.EventMessage(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, msg.AppID, rebuilt.AppID)
+	assert.Equal(t, msg.Payload, rebuilt.Payload)
+	_, err = listener.EventMessage(ctx, 1, 1)
+	assert.Equal(t, chain.ErrEventNotFound, err)
+
+	// replaying the block enqueues the transfer again, without marking the block processed
+	processed := blocks.processed()
+	last, err := listener.Replay(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), last)
+	require.Len(t, messages, 1)
+	replayed := <-messages
+	assert.True(t, replayed.Replayed)
+	assert.Equal(t, msg.Payload, replayed.Payload)
+	assert.Equal(t, processed, blocks.processed())
+
+	_, err = listener.Replay(ctx, 2, 1)
+	assert.Error(t, err)
+}
+
+// metadataWithoutETH describes a runtime from before the ETH module was added
+func metadataWithoutETH() *types.Metadata {
+	metadata := *MetadataExemplary
+	var modules []types.ModuleMetadataV10
+	for _, module := range metadata.AsMetadataV11.Modules {
+		if module.Name != "ETH" {
+			modules = append(modules, module)
+		}
+	}
+	metadata.AsMetadataV11.Modules = modules
+	return &metadata
+}
+
+func TestListener_RuntimeUpgrade(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, metadataWithoutETH(), client)
+
+	// the chain upgrades to a runtime with the ETH module, whose events the relayer can't
+	// decode until it fetches the new metadata
+	client.Upgrade(MetadataExemplary)
+	client.AddBlock()
+	hash := client.AddBlock()
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Events", nil, nil)
+	require.NoError(t, err)
+	transfer := ETHTransfer{
+		AccountID: types.AccountID{1},
+		Recipient: types.H160{2},
+		Amount:    types.NewU256(*big.NewInt(3)),
+	}
+	require.NoError(t, client.SetBlockStorage(hash, key, ethTransferRecords(t, transfer)))
+
+	app := [20]byte{9}
+	config := &Config{Targets: map[string][20]byte{"eth": app}}
+	messages := make(chan chain.Message, 1)
+	listener := NewListener(config, conn, messages, nil, &processedBlocks{}, nil, nil, nil, nil, nil, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, app, msg.AppID)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	assert.Equal(t, MetadataExemplary, conn.Metadata())
+}
+
+func TestListener_Resume(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, MetadataExemplary, client)
+
+	// the transfer was emitted while the relayer was down, after it handled block 1
+	client.AddBlock()
+	hash := client.AddBlock()
+	client.AddBlock()
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Events", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.SetBlockStorage(hash, key, ethTransferRecords(t, ETHTransfer{Amount: types.NewU256(*big.NewInt(1))})))
+
+	cursors := store.NewCursors(store.NewMemoryDB())
+	require.NoError(t, cursors.SaveCursor(Name, 1))
+
+	config := &Config{Targets: map[string][20]byte{"eth": {9}}}
+	messages := make(chan chain.Message, 1)
+	blocks := &processedBlocks{}
+	listener := NewListener(config, conn, messages, nil, blocks, nil, nil, cursors, nil, nil, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	select {
+	case <-messages:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// the cursor follows the handled blocks
+	assert.Eventually(t, func() bool {
+		number, _, err := cursors.LoadCursor(Name)
+		return err == nil && number == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []uint64{2, 3}, blocks.processed())
+}
+
+func TestListener_FinalizedHeads(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	log := logrus.NewEntry(logger)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, MetadataExemplary, client)
+
+	blocks := &processedBlocks{}
+	listener := NewListener(&Config{}, conn, make(chan chain.Message), nil, blocks, nil, nil, nil, nil, nil, log)
+	// blocks can only be processed in time if they are received from the subscription
+	listener.pollInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, listener.Start(ctx, eg))
+
+	subscribed := func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return len(client.headSubs) > 0
+	}
+	processed := func(number uint64) func() bool {
+		return func() bool {
+			numbers := blocks.processed()
+			return len(numbers) > 0 && numbers[len(numbers)-1] == number
+		}
+	}
+
+	require.Eventually(t, subscribed, time.Second, time.Millisecond)
+	client.AddBlock()
+	require.Eventually(t, processed(1), time.Second, time.Millisecond)
+
+	// the subscription is renewed after it dropped
+	client.DropHeadSubscriptions()
+	require.Eventually(t, subscribed, time.Second, time.Millisecond)
+	client.AddBlock()
+	require.Eventually(t, processed(2), time.Second, time.Millisecond)
+	assert.Equal(t, uint64(2), listener.Progress().Snapshot().Latest)
+}
+
+type processedBlocks struct {
+	mutex   sync.Mutex
+	numbers []uint64
+}
+
+func (pb *processedBlocks) MarkProcessed(_ string, number uint64) error {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	pb.numbers = append(pb.numbers, number)
+	return nil
+}
+
+func (pb *processedBlocks) processed() []uint64 {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+	return append([]uint64{}, pb.numbers...)
+}
@@ -0,0 +1,462 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// MockConnection is a Connection to an in-memory MockClient, so that components can be
+// tested without a node
+type MockConnection struct {
+	kp         *signature.KeyringPair
+	runtime    runtimeMetadata
+	client     *MockClient
+	properties ChainProperties
+}
+
+// NewMockConnection creates a connection to a chain with the default properties, whose
+// latest runtime is described by metadata
+func NewMockConnection(kp *signature.KeyringPair, metadata *types.Metadata, client *MockClient) *MockConnection {
+	mc := &MockConnection{kp: kp, client: client, properties: DefaultProperties}
+	mc.runtime.set(metadata, client.runtimeVersion.SpecVersion)
+	return mc
+}
+
+func (mc *MockConnection) Connect(_ context.Context) error {
+	return nil
+}
+
+func (mc *MockConnection) Close() {}
+
+func (mc *MockConnection) Client() Client {
+	return mc.client
+}
+
+func (mc *MockConnection) Keypair() *signature.KeyringPair {
+	return mc.kp
+}
+
+func (mc *MockConnection) Metadata() *types.Metadata {
+	return mc.runtime.get()
+}
+
+func (mc *MockConnection) RefreshMetadata(ctx context.Context, hash types.Hash) (bool, error) {
+	return mc.runtime.refresh(ctx, mc.client, hash)
+}
+
+func (mc *MockConnection) Properties() *ChainProperties {
+	return &mc.properties
+}
+
+// SetProperties replaces the properties of the chain
+func (mc *MockConnection) SetProperties(properties ChainProperties) {
+	mc.properties = properties
+}
+
+func (mc *MockConnection) Stats() *chain.RPCStats {
+	return nil
+}
+
+// MockClient is an in-memory chain whose blocks are finalized as soon as they are added.
+// Storage can be set for the latest state or for a single block, and submitted extrinsics
+// are recorded and reported as finalized in the latest block, whose extrinsics they join. Subscribers to finalized heads
+// are notified of each added block. Calls fail once their context is done, like calls to a node.
+type MockClient struct {
+	mutex          sync.Mutex
+	hashes         []types.Hash
+	headers        map[types.Hash]*types.Header
+	extrinsics     map[types.Hash][]types.Extrinsic
+	storage        map[string][]byte
+	blockStorage   map[types.Hash]map[string][]byte
+	calls          map[string]json.RawMessage
+	runtimeVersion types.RuntimeVersion
+	// metadata of the latest runtime, nil until it is upgraded, and the runtime in the state
+	// of each block
+	metadata  *types.Metadata
+	runtimes  map[types.Hash]mockRuntime
+	submitted []types.Extrinsic
+	headSubs  []*mockHeaderSubscription
+}
+
+type mockRuntime struct {
+	version  types.RuntimeVersion
+	metadata *types.Metadata
+}
+
+// NewMockClient creates a client whose chain starts with an empty genesis block
+func NewMockClient() *MockClient {
+	mc := &MockClient{
+		headers:        make(map[types.Hash]*types.Header),
+		extrinsics:     make(map[types.Hash][]types.Extrinsic),
+		storage:        make(map[string][]byte),
+		blockStorage:   make(map[types.Hash]map[string][]byte),
+		calls:          make(map[string]json.RawMessage),
+		runtimeVersion: types.RuntimeVersion{SpecVersion: 1},
+		runtimes:       make(map[types.Hash]mockRuntime),
+	}
+	mc.AddBlock()
+	return mc
+}
+
+// AddBlock appends a block to the chain and returns its hash
+func (mc *MockClient) AddBlock() types.Hash {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	header := &types.Header{Number: types.BlockNumber(len(mc.hashes))}
+	if len(mc.hashes) > 0 {
+		header.ParentHash = mc.hashes[len(mc.hashes)-1]
+	}
+
+	hash, err := headerHash(header)
+	if err != nil {
+		panic(err)
+	}
+
+	mc.hashes = append(mc.hashes, hash)
+	mc.headers[hash] = header
+	mc.runtimes[hash] = mockRuntime{version: mc.runtimeVersion, metadata: mc.metadata}
+
+	// like a node, heads are dropped for subscribers which fall behind
+	for _, sub := range mc.headSubs {
+		select {
+		case sub.headers <- *header:
+		default:
+		}
+	}
+	return hash
+}
+
+// Upgrade replaces the runtime of the blocks added from now on with a runtime of the next
+// spec version, described by metadata
+func (mc *MockClient) Upgrade(metadata *types.Metadata) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.runtimeVersion.SpecVersion++
+	mc.metadata = metadata
+}
+
+// DropHeadSubscriptions ends the subscriptions to finalized heads with an error, as if the
+// connection to the node was lost
+func (mc *MockClient) DropHeadSubscriptions() {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	for _, sub := range mc.headSubs {
+		sub.errs <- fmt.Errorf("subscription dropped")
+	}
+	mc.headSubs = nil
+}
+
+// SetStorage sets a storage item in the latest state, SCALE-encoding the value
+func (mc *MockClient) SetStorage(key types.StorageKey, value interface{}) error {
+	encoded, err := types.EncodeToBytes(value)
+	if err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.storage[key.Hex()] = encoded
+	return nil
+}
+
+// SetBlockStorage sets a storage item in the state of a single block, such as its events.
+// Other items of the block fall back to the latest state.
+func (mc *MockClient) SetBlockStorage(hash types.Hash, key types.StorageKey, value interface{}) error {
+	encoded, err := types.EncodeToBytes(value)
+	if err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if mc.blockStorage[hash] == nil {
+		mc.blockStorage[hash] = make(map[string][]byte)
+	}
+	mc.blockStorage[hash][key.Hex()] = encoded
+	return nil
+}
+
+// SetCallResult sets the JSON result of raw calls to a method
+func (mc *MockClient) SetCallResult(method string, result interface{}) error {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.calls[method] = encoded
+	return nil
+}
+
+// Submitted returns the extrinsics which were submitted, in order
+func (mc *MockClient) Submitted() []types.Extrinsic {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return append([]types.Extrinsic{}, mc.submitted...)
+}
+
+func (mc *MockClient) GetBlockHash(ctx context.Context, number uint64) (types.Hash, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Hash{}, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	if number >= uint64(len(mc.hashes)) {
+		return types.Hash{}, fmt.Errorf("block %d not found", number)
+	}
+	return mc.hashes[number], nil
+}
+
+func (mc *MockClient) GetFinalizedHead(ctx context.Context) (types.Hash, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Hash{}, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.hashes[len(mc.hashes)-1], nil
+}
+
+func (mc *MockClient) GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	header, ok := mc.headers[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
+	return header, nil
+}
+
+func (mc *MockClient) GetHeaderLatest(ctx context.Context) (*types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.headers[mc.hashes[len(mc.hashes)-1]], nil
+}
+
+func (mc *MockClient) GetBlock(ctx context.Context, hash types.Hash) (*types.SignedBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	header, ok := mc.headers[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
+	return &types.SignedBlock{Block: types.Block{
+		Header:     *header,
+		Extrinsics: append([]types.Extrinsic{}, mc.extrinsics[hash]...),
+	}}, nil
+}
+
+func (mc *MockClient) GetStorage(ctx context.Context, key types.StorageKey, target interface{}, hash types.Hash) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	value, ok := mc.blockStorage[hash][key.Hex()]
+	if !ok {
+		value, ok = mc.storage[key.Hex()]
+	}
+	if !ok {
+		return false, nil
+	}
+	return true, types.DecodeFromBytes(value, target)
+}
+
+func (mc *MockClient) GetStorageLatest(ctx context.Context, key types.StorageKey, target interface{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	value, ok := mc.storage[key.Hex()]
+	if !ok {
+		return false, nil
+	}
+	return true, types.DecodeFromBytes(value, target)
+}
+
+func (mc *MockClient) GetStorageRawLatest(ctx context.Context, key types.StorageKey) (*types.StorageDataRaw, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	value := types.StorageDataRaw(mc.storage[key.Hex()])
+	return &value, nil
+}
+
+func (mc *MockClient) GetRuntimeVersionLatest(ctx context.Context) (*types.RuntimeVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	version := mc.runtimeVersion
+	return &version, nil
+}
+
+func (mc *MockClient) GetRuntimeVersion(ctx context.Context, hash types.Hash) (*types.RuntimeVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	runtime, ok := mc.runtimes[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
+	version := runtime.version
+	return &version, nil
+}
+
+func (mc *MockClient) GetMetadata(ctx context.Context, hash types.Hash) (*types.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	runtime, ok := mc.runtimes[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
+	if runtime.metadata == nil {
+		return nil, fmt.Errorf("no metadata for the runtime of block %s", hash.Hex())
+	}
+	return runtime.metadata, nil
+}
+
+func (mc *MockClient) SubmitExtrinsic(ctx context.Context, ext types.Extrinsic) (types.Hash, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Hash{}, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.submitted = append(mc.submitted, ext)
+	return extrinsicHash(ext)
+}
+
+func (mc *MockClient) SubmitAndWatchExtrinsic(ctx context.Context, ext types.Extrinsic) (ExtrinsicSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.submitted = append(mc.submitted, ext)
+	latest := mc.hashes[len(mc.hashes)-1]
+	mc.extrinsics[latest] = append(mc.extrinsics[latest], ext)
+
+	sub := &mockSubscription{
+		statuses: make(chan types.ExtrinsicStatus, 1),
+		errs:     make(chan error),
+	}
+	sub.statuses <- types.ExtrinsicStatus{IsFinalized: true, AsFinalized: latest}
+	return sub, nil
+}
+
+func (mc *MockClient) SubscribeFinalizedHeads(ctx context.Context) (HeaderSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	sub := &mockHeaderSubscription{
+		headers: make(chan types.Header, 16),
+		errs:    make(chan error, 1),
+	}
+	mc.headSubs = append(mc.headSubs, sub)
+	return sub, nil
+}
+
+func (mc *MockClient) Call(ctx context.Context, result interface{}, method string, _ ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	encoded, ok := mc.calls[method]
+	if !ok {
+		return fmt.Errorf("method %s not found", method)
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+type mockSubscription struct {
+	statuses chan types.ExtrinsicStatus
+	errs     chan error
+}
+
+func (ms *mockSubscription) Chan() <-chan types.ExtrinsicStatus {
+	return ms.statuses
+}
+
+func (ms *mockSubscription) Err() <-chan error {
+	return ms.errs
+}
+
+func (ms *mockSubscription) Unsubscribe() {}
+
+type mockHeaderSubscription struct {
+	headers chan types.Header
+	errs    chan error
+}
+
+func (ms *mockHeaderSubscription) Chan() <-chan types.Header {
+	return ms.headers
+}
+
+func (ms *mockHeaderSubscription) Err() <-chan error {
+	return ms.errs
+}
+
+func (ms *mockHeaderSubscription) Unsubscribe() {}
@@ -0,0 +1,56 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+)
+
+// StorageParameter reads an on-chain parameter from a storage item, given as Module.Item for
+// a value or Module.Item(account) for a map keyed by the relayer account. The item holds an
+// unsigned integer of up to 128 bits or a boolean.
+func (ch *Chain) StorageParameter(ctx context.Context, storage string) (*big.Int, error) {
+	item := storage
+	var arg []byte
+	if strings.HasSuffix(item, "(account)") {
+		item = strings.TrimSuffix(item, "(account)")
+		arg = ch.conn.Keypair().PublicKey
+	}
+
+	parts := strings.Split(item, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("storage %q is not of the form Module.Item or Module.Item(account)", storage)
+	}
+
+	key, err := types.CreateStorageKey(ch.conn.Metadata(), parts[0], parts[1], arg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ch.conn.Client().GetStorageRawLatest(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParameter(*value)
+}
+
+// decodeParameter decodes the SCALE encoding of an unsigned integer or boolean. Unset items
+// are zero, like the default value of most parameters.
+func decodeParameter(value []byte) (*big.Int, error) {
+	if len(value) > 16 {
+		return nil, fmt.Errorf("storage value of %d bytes is not an unsigned integer", len(value))
+	}
+
+	// SCALE integers are little endian
+	reversed := make([]byte, len(value))
+	for i, b := range value {
+		reversed[len(value)-1-i] = b
+	}
+	return new(big.Int).SetBytes(reversed), nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeParameter(t *testing.T) {
+	for _, test := range []struct {
+		value    []byte
+		expected int64
+	}{
+		// unset
+		{nil, 0},
+		// bool
+		{[]byte{1}, 1},
+		// u32
+		{[]byte{0x2c, 0x01, 0x00, 0x00}, 300},
+		// u128
+		{append([]byte{0x00, 0x10}, make([]byte, 14)...), 4096},
+	} {
+		value, err := decodeParameter(test.value)
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(test.expected), value)
+	}
+
+	_, err := decodeParameter(make([]byte, 17))
+	assert.Error(t, err)
+}
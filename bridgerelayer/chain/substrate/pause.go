@@ -0,0 +1,97 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// PauseWatcher halts the writer while the pause flag of the bridge pallet is set
+type PauseWatcher struct {
+	config   *PauseConfig
+	conn     Connection
+	gate     *chain.Gate
+	interval time.Duration
+	paused   bool
+	log      *logrus.Entry
+}
+
+func NewPauseWatcher(config *PauseConfig, conn Connection, gate *chain.Gate, log *logrus.Entry) *PauseWatcher {
+	return &PauseWatcher{
+		config:   config,
+		conn:     conn,
+		gate:     gate,
+		interval: time.Duration(config.Interval) * time.Second,
+		log:      log,
+	}
+}
+
+func (pw *PauseWatcher) Start(ctx context.Context, eg *errgroup.Group) error {
+	if pw.config.Module == "" || pw.config.Storage == "" {
+		return fmt.Errorf("pause watcher requires a module and storage item")
+	}
+
+	_, err := pw.storageKey()
+	if err != nil {
+		return err
+	}
+
+	pw.check(ctx)
+
+	eg.Go(func() error {
+		ticker := time.NewTicker(pw.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				pw.check(ctx)
+			}
+		}
+	})
+
+	return nil
+}
+
+// storageKey returns the key of the pause flag, which is created with the latest metadata as
+// a runtime upgrade may move it
+func (pw *PauseWatcher) storageKey() (types.StorageKey, error) {
+	return types.CreateStorageKey(pw.conn.Metadata(), pw.config.Module, pw.config.Storage, nil, nil)
+}
+
+func (pw *PauseWatcher) check(ctx context.Context) {
+	key, err := pw.storageKey()
+	if err != nil {
+		pw.log.WithError(err).Warn("Failed to query pause state")
+		return
+	}
+
+	var flag types.Bool
+	_, err = pw.conn.Client().GetStorageLatest(ctx, key, &flag)
+	if err != nil {
+		// Keep the current state if the pause state is unknown
+		pw.log.WithError(err).Warn("Failed to query pause state")
+		return
+	}
+	paused := bool(flag)
+
+	if paused && !pw.paused {
+		pw.log.Warn("Bridge is paused on-chain, halting writer")
+		pw.gate.Halt(chain.ReasonPausedOnChain)
+	} else if !paused && pw.paused {
+		pw.log.Info("Bridge is no longer paused, resuming writer")
+		pw.gate.Release(chain.ReasonPausedOnChain)
+	}
+	pw.paused = paused
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// interval between checks of the best block while waiting for a prefetched block to be built
+const prefetchPollInterval = time.Second
+
+// prefetchedBlock is the hash and events of a block on the best chain, which may not be the
+// block which finalizes at its height
+type prefetchedBlock struct {
+	hash   types.Hash
+	events []Event
+	err    error
+}
+
+// prefetcher speculatively fetches the events of the next block from the best chain while
+// the listener waits for it to finalize, so that only the check of its hash remains once it
+// does. A prefetched block is only used if its hash is the one finalized at its height, and
+// discarded otherwise. Outcomes are counted by metrics.
+type prefetcher struct {
+	fetch func(ctx context.Context, number uint64) prefetchedBlock
+	// block being prefetched, and the channel receiving it once it was
+	number uint64
+	result chan prefetchedBlock
+	cancel context.CancelFunc
+	log    *logrus.Entry
+}
+
+func newPrefetcher(fetch func(ctx context.Context, number uint64) prefetchedBlock, log *logrus.Entry) *prefetcher {
+	return &prefetcher{fetch: fetch, log: log}
+}
+
+// start prefetches a block, unless it is already being prefetched
+func (pf *prefetcher) start(ctx context.Context, number uint64) {
+	if pf.cancel != nil && pf.number == number {
+		return
+	}
+	pf.stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	result := make(chan prefetchedBlock, 1)
+	pf.number = number
+	pf.result = result
+	pf.cancel = cancel
+
+	go func() {
+		result <- pf.fetch(ctx, number)
+	}()
+}
+
+// take returns the prefetched hash and events of a block which finalized, if it was
+// prefetched and its hash matches the finalized one. The finalized hash at the height of the
+// block is taken from the finalized head or its parent where possible, and fetched through
+// hashAt otherwise.
+func (pf *prefetcher) take(ctx context.Context, number uint64, head *types.Header, headHash types.Hash, hashAt func(ctx context.Context, number uint64) (types.Hash, error)) (types.Hash, []Event, bool) {
+	if pf.cancel == nil || pf.number != number {
+		return types.Hash{}, nil, false
+	}
+	result := pf.result
+	pf.stop()
+
+	var block prefetchedBlock
+	select {
+	case block = <-result:
+	default:
+		metrics.PrefetchedBlocks.WithLabelValues(Name, "missed").Inc()
+		return types.Hash{}, nil, false
+	}
+	if block.err != nil {
+		pf.log.WithError(block.err).WithField("block", number).Debug("Failed to prefetch block")
+		metrics.PrefetchedBlocks.WithLabelValues(Name, "missed").Inc()
+		return types.Hash{}, nil, false
+	}
+
+	var finalized types.Hash
+	switch uint64(head.Number) {
+	case number:
+		finalized = headHash
+	case number + 1:
+		finalized = head.ParentHash
+	default:
+		var err error
+		finalized, err = hashAt(ctx, number)
+		if err != nil {
+			metrics.PrefetchedBlocks.WithLabelValues(Name, "missed").Inc()
+			return types.Hash{}, nil, false
+		}
+	}
+
+	if finalized != block.hash {
+		pf.log.WithFields(logrus.Fields{
+			"block":      number,
+			"prefetched": block.hash.Hex(),
+			"finalized":  finalized.Hex(),
+		}).Debug("Discarded prefetched block which didn't finalize")
+		metrics.PrefetchedBlocks.WithLabelValues(Name, "discarded").Inc()
+		return types.Hash{}, nil, false
+	}
+
+	metrics.PrefetchedBlocks.WithLabelValues(Name, "used").Inc()
+	return block.hash, block.events, true
+}
+
+// stop abandons the prefetch in progress, if any
+func (pf *prefetcher) stop() {
+	if pf.cancel == nil {
+		return
+	}
+	pf.cancel()
+	pf.cancel = nil
+	pf.result = nil
+}
+
+// prefetchBlock waits for a block to be built on the best chain, and fetches its hash and
+// events
+func (li *Listener) prefetchBlock(ctx context.Context, number uint64) prefetchedBlock {
+	for {
+		header, err := li.conn.Client().GetHeaderLatest(ctx)
+		if err != nil {
+			return prefetchedBlock{err: err}
+		}
+		if uint64(header.Number) >= number {
+			break
+		}
+
+		sleep(ctx, prefetchPollInterval)
+		if ctx.Err() != nil {
+			return prefetchedBlock{err: ctx.Err()}
+		}
+	}
+
+	hash, err := li.conn.Client().GetBlockHash(ctx, number)
+	if err != nil {
+		return prefetchedBlock{err: err}
+	}
+
+	events, err := li.eventsAt(ctx, hash)
+	if err != nil {
+		return prefetchedBlock{err: err}
+	}
+	return prefetchedBlock{hash: hash, events: events}
+}
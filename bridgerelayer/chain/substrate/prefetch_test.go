@@ -0,0 +1,103 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefetchNow prefetches a block and waits for the prefetch to complete
+func prefetchNow(pf *prefetcher, number uint64) {
+	pf.start(context.Background(), number)
+	block := <-pf.result
+	pf.result <- block
+}
+
+func TestPrefetcher_Take(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	ctx := context.Background()
+
+	prefetched := types.Hash{5}
+	pf := newPrefetcher(func(ctx context.Context, number uint64) prefetchedBlock {
+		return prefetchedBlock{hash: prefetched, events: transferEvents(2)}
+	}, logrus.NewEntry(logger))
+	noLookup := func(ctx context.Context, number uint64) (types.Hash, error) {
+		return types.Hash{}, fmt.Errorf("unexpected lookup of block %d", number)
+	}
+
+	// blocks which weren't prefetched are fetched as usual
+	_, _, ok := pf.take(ctx, 5, &types.Header{Number: 5}, prefetched, noLookup)
+	assert.False(t, ok)
+
+	// the prefetched block is used if it is the finalized head
+	prefetchNow(pf, 5)
+	hash, events, ok := pf.take(ctx, 5, &types.Header{Number: 5}, prefetched, noLookup)
+	assert.True(t, ok)
+	assert.Equal(t, prefetched, hash)
+	assert.Len(t, events, 2)
+
+	// or its parent
+	prefetchNow(pf, 5)
+	_, _, ok = pf.take(ctx, 5, &types.Header{Number: 6, ParentHash: prefetched}, types.Hash{6}, noLookup)
+	assert.True(t, ok)
+
+	// and discarded if another block finalized at its height
+	prefetchNow(pf, 5)
+	_, _, ok = pf.take(ctx, 5, &types.Header{Number: 5}, types.Hash{7}, noLookup)
+	assert.False(t, ok)
+
+	// the finalized hash is fetched for heads further ahead
+	prefetchNow(pf, 5)
+	_, _, ok = pf.take(ctx, 5, &types.Header{Number: 9}, types.Hash{9}, func(ctx context.Context, number uint64) (types.Hash, error) {
+		return prefetched, nil
+	})
+	assert.True(t, ok)
+
+	// a prefetched block is only taken once
+	_, _, ok = pf.take(ctx, 5, &types.Header{Number: 5}, prefetched, noLookup)
+	assert.False(t, ok)
+}
+
+func TestListener_PrefetchBlock(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	client := NewMockClient()
+	conn := NewMockConnection(&signature.TestKeyringPairAlice, MetadataExemplary, client)
+	listener := NewListener(&Config{}, conn, nil, nil, nil, nil, nil, nil, nil, nil, logrus.NewEntry(logger))
+
+	key, err := types.CreateStorageKey(MetadataExemplary, "System", "Events", nil, nil)
+	require.NoError(t, err)
+
+	// the prefetch waits for the block to be built
+	pf := newPrefetcher(listener.prefetchBlock, listener.log)
+	defer pf.stop()
+	pf.start(context.Background(), 1)
+	time.Sleep(10 * time.Millisecond)
+	hash := client.AddBlock()
+	require.NoError(t, client.SetBlockStorage(hash, key, ethTransferRecords(t, ETHTransfer{Amount: types.NewU256(*big.NewInt(1))})))
+
+	var block prefetchedBlock
+	select {
+	case block = <-pf.result:
+	case <-time.After(3 * prefetchPollInterval):
+		t.Fatal("timed out waiting for prefetch")
+	}
+	require.NoError(t, block.err)
+	assert.Equal(t, hash, block.hash)
+	require.Len(t, block.events, 1)
+	assert.Equal(t, [2]string{"ETH", "Transfer"}, block.events[0].Name)
+}
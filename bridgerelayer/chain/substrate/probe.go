@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ChainInfo describes a Substrate chain discovered through its RPC endpoint
+type ChainInfo struct {
+	Chain       string
+	GenesisHash types.Hash
+	SpecName    string
+	SpecVersion uint32
+	Properties  Properties
+}
+
+// Properties are the token properties defined in the chain spec. Chains with several
+// tokens list the decimals and symbol of each.
+type Properties struct {
+	SS58Format    *uint16
+	TokenDecimals []uint32
+	TokenSymbols  []string
+}
+
+type rawProperties struct {
+	SS58Format    *uint16         `json:"ss58Format"`
+	TokenDecimals json.RawMessage `json:"tokenDecimals"`
+	TokenSymbol   json.RawMessage `json:"tokenSymbol"`
+}
+
+// Probe discovers the properties of the chain behind an endpoint, dialed through dialer, by
+// default if nil
+func Probe(ctx context.Context, endpoint string, dialer *chain.Dialer) (*ChainInfo, error) {
+	client, err := dialClient(ctx, endpoint, nil, dialer)
+	if err != nil {
+		return nil, err
+	}
+	defer client.close()
+
+	var info ChainInfo
+
+	err = client.Call(ctx, &info.Chain, "system_chain")
+	if err != nil {
+		return nil, err
+	}
+
+	info.GenesisHash, err = client.GetBlockHash(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rv, err := client.GetRuntimeVersionLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.SpecName = string(rv.SpecName)
+	info.SpecVersion = uint32(rv.SpecVersion)
+
+	info.Properties, err = client.getProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func parseProperties(data []byte) (Properties, error) {
+	var raw rawProperties
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return Properties{}, err
+	}
+
+	props := Properties{SS58Format: raw.SS58Format}
+
+	err = unmarshalOneOrMany(raw.TokenDecimals, &props.TokenDecimals)
+	if err != nil {
+		return Properties{}, err
+	}
+
+	err = unmarshalOneOrMany(raw.TokenSymbol, &props.TokenSymbols)
+	if err != nil {
+		return Properties{}, err
+	}
+
+	return props, nil
+}
+
+// unmarshalOneOrMany decodes either a single JSON value or an array of values into a slice
+func unmarshalOneOrMany(data json.RawMessage, target interface{}) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if data[0] != '[' {
+		data = append(append(json.RawMessage{'['}, data...), ']')
+	}
+	return json.Unmarshal(data, target)
+}
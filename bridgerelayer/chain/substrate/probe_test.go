@@ -0,0 +1,30 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProperties(t *testing.T) {
+	props, err := parseProperties([]byte(`{"ss58Format":42,"tokenDecimals":12,"tokenSymbol":"DOT"}`))
+	require.NoError(t, err)
+	require.NotNil(t, props.SS58Format)
+	assert.Equal(t, uint16(42), *props.SS58Format)
+	assert.Equal(t, []uint32{12}, props.TokenDecimals)
+	assert.Equal(t, []string{"DOT"}, props.TokenSymbols)
+
+	props, err = parseProperties([]byte(`{"tokenDecimals":[12,18],"tokenSymbol":["ACA","AUSD"]}`))
+	require.NoError(t, err)
+	assert.Nil(t, props.SS58Format)
+	assert.Equal(t, []uint32{12, 18}, props.TokenDecimals)
+	assert.Equal(t, []string{"ACA", "AUSD"}, props.TokenSymbols)
+
+	props, err = parseProperties([]byte(`{}`))
+	require.NoError(t, err)
+	assert.Empty(t, props.TokenDecimals)
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/ss58"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/units"
+)
+
+// PropertiesConfig overrides the properties reported by the chain in system_properties,
+// for chains which report none or whose chain spec is wrong. Unset values are reported.
+type PropertiesConfig struct {
+	SS58Prefix    *uint16 `mapstructure:"ss58-prefix"`
+	TokenDecimals *uint8  `mapstructure:"token-decimals"`
+	TokenSymbol   string  `mapstructure:"token-symbol"`
+}
+
+// ChainProperties are the properties by which addresses and amounts of the chain are
+// formatted and parsed. The native token is the first token listed by the chain.
+type ChainProperties struct {
+	SS58Prefix    uint16
+	TokenDecimals uint8
+	TokenSymbol   string
+}
+
+// DefaultProperties are those of development chains, assumed for properties which are
+// neither reported nor configured
+var DefaultProperties = ChainProperties{
+	SS58Prefix:    ss58.DefaultPrefix,
+	TokenDecimals: 12,
+	TokenSymbol:   "UNIT",
+}
+
+// ResolveProperties returns the properties reported by a chain with the overrides of config
+func ResolveProperties(config *PropertiesConfig, reported Properties) (ChainProperties, error) {
+	props := DefaultProperties
+
+	if reported.SS58Format != nil {
+		props.SS58Prefix = *reported.SS58Format
+	}
+	if len(reported.TokenDecimals) > 0 {
+		if reported.TokenDecimals[0] > units.MaxDecimals {
+			return ChainProperties{}, fmt.Errorf("chain reports %d token decimals, more than %d", reported.TokenDecimals[0], units.MaxDecimals)
+		}
+		props.TokenDecimals = uint8(reported.TokenDecimals[0])
+	}
+	if len(reported.TokenSymbols) > 0 && reported.TokenSymbols[0] != "" {
+		props.TokenSymbol = reported.TokenSymbols[0]
+	}
+
+	if config.SS58Prefix != nil {
+		props.SS58Prefix = *config.SS58Prefix
+	}
+	if config.TokenDecimals != nil {
+		props.TokenDecimals = *config.TokenDecimals
+	}
+	if config.TokenSymbol != "" {
+		props.TokenSymbol = config.TokenSymbol
+	}
+
+	if props.SS58Prefix > ss58.MaxPrefix {
+		return ChainProperties{}, fmt.Errorf("SS58 prefix %d exceeds %d", props.SS58Prefix, ss58.MaxPrefix)
+	}
+	if props.TokenDecimals > units.MaxDecimals {
+		return ChainProperties{}, fmt.Errorf("token decimals %d exceed %d", props.TokenDecimals, units.MaxDecimals)
+	}
+
+	return props, nil
+}
+
+// Address returns the SS58 address of an account on the chain, or the hex-encoded
+// public key if it is not a valid account key
+func (cp *ChainProperties) Address(publicKey []byte) string {
+	address, err := ss58.Encode(publicKey, cp.SS58Prefix)
+	if err != nil {
+		return types.HexEncodeToString(publicKey)
+	}
+	return address
+}
+
+// ParseAddress returns the account with an SS58 address, which must be of this chain
+func (cp *ChainProperties) ParseAddress(address string) (types.AccountID, error) {
+	publicKey, err := ss58.DecodeWithPrefix(address, cp.SS58Prefix)
+	if err != nil {
+		return types.AccountID{}, err
+	}
+	return types.NewAccountID(publicKey), nil
+}
+
+// FormatAmount writes an amount in base units of the native token with its symbol
+func (cp *ChainProperties) FormatAmount(amount *big.Int) string {
+	return units.Format(amount, cp.TokenDecimals) + " " + cp.TokenSymbol
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveProperties(t *testing.T) {
+	props, err := ResolveProperties(&PropertiesConfig{}, Properties{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProperties, props)
+
+	prefix := uint16(0)
+	reported := Properties{SS58Format: &prefix, TokenDecimals: []uint32{10, 18}, TokenSymbols: []string{"DOT", "AUSD"}}
+	props, err = ResolveProperties(&PropertiesConfig{}, reported)
+	require.NoError(t, err)
+	assert.Equal(t, ChainProperties{SS58Prefix: 0, TokenDecimals: 10, TokenSymbol: "DOT"}, props)
+
+	// configured properties replace those reported
+	override := uint16(2)
+	decimals := uint8(12)
+	props, err = ResolveProperties(&PropertiesConfig{SS58Prefix: &override, TokenDecimals: &decimals, TokenSymbol: "KSM"}, reported)
+	require.NoError(t, err)
+	assert.Equal(t, ChainProperties{SS58Prefix: 2, TokenDecimals: 12, TokenSymbol: "KSM"}, props)
+
+	_, err = ResolveProperties(&PropertiesConfig{}, Properties{TokenDecimals: []uint32{78}})
+	assert.Error(t, err)
+
+	override = 16384
+	_, err = ResolveProperties(&PropertiesConfig{SS58Prefix: &override}, reported)
+	assert.Error(t, err)
+}
+
+func TestChainProperties_Address(t *testing.T) {
+	alice := signature.TestKeyringPairAlice
+
+	props := DefaultProperties
+	assert.Equal(t, alice.Address, props.Address(alice.PublicKey))
+
+	account, err := props.ParseAddress(alice.Address)
+	require.NoError(t, err)
+	assert.Equal(t, types.NewAccountID(alice.PublicKey), account)
+
+	// addresses of other networks are rejected
+	polkadot := ChainProperties{SS58Prefix: 0, TokenDecimals: 10, TokenSymbol: "DOT"}
+	_, err = polkadot.ParseAddress(alice.Address)
+	assert.Error(t, err)
+
+	account, err = polkadot.ParseAddress(polkadot.Address(alice.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, types.NewAccountID(alice.PublicKey), account)
+
+	assert.Equal(t, "1.25 DOT", polkadot.FormatAmount(big.NewInt(12500000000)))
+}
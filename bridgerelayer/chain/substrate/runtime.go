@@ -0,0 +1,75 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"sync"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// runtimeMetadata is the metadata of the latest runtime of the chain known to the relayer,
+// which is replaced once the chain upgrades its runtime. The metadata returned before is
+// left unchanged, so that it can be used while it is replaced.
+type runtimeMetadata struct {
+	mutex       sync.RWMutex
+	metadata    *types.Metadata
+	specVersion types.U32
+}
+
+// get returns the latest metadata, which is empty until it was fetched
+func (rm *runtimeMetadata) get() *types.Metadata {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	if rm.metadata == nil {
+		return &types.Metadata{}
+	}
+	return rm.metadata
+}
+
+func (rm *runtimeMetadata) set(metadata *types.Metadata, specVersion types.U32) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	rm.metadata = metadata
+	rm.specVersion = specVersion
+	metrics.RuntimeSpecVersion.WithLabelValues(Name).Set(float64(specVersion))
+}
+
+// refresh fetches the metadata of the runtime in the state of a block if it is newer than
+// the latest metadata, returning whether the metadata was replaced. The metadata of older
+// runtimes never replaces that of newer ones.
+func (rm *runtimeMetadata) refresh(ctx context.Context, client Client, hash types.Hash) (bool, error) {
+	version, err := client.GetRuntimeVersion(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+
+	rm.mutex.RLock()
+	current := rm.specVersion
+	rm.mutex.RUnlock()
+	if version.SpecVersion <= current {
+		return false, nil
+	}
+
+	metadata, err := client.GetMetadata(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	if version.SpecVersion <= rm.specVersion {
+		return false, nil
+	}
+	rm.metadata = metadata
+	rm.specVersion = version.SpecVersion
+	metrics.RuntimeSpecVersion.WithLabelValues(Name).Set(float64(version.SpecVersion))
+	return true, nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/snowfork/go-substrate-rpc-client/types"
+)
+
+// MintedSupply returns the total issuance of a bridged asset in the asset pallet
+func (ch *Chain) MintedSupply(ctx context.Context, asset [20]byte) (*big.Int, error) {
+	key, err := types.CreateStorageKey(ch.conn.Metadata(), "Asset", "TotalIssuance", asset[:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var issuance types.U256
+	ok, err := ch.conn.Client().GetStorageLatest(ctx, key, &issuance)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return big.NewInt(0), nil
+	}
+
+	return issuance.Int, nil
+}
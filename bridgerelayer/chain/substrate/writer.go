@@ -6,7 +6,12 @@ package substrate
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -14,23 +19,114 @@ import (
 
 	"github.com/snowfork/go-substrate-rpc-client/types"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 )
 
 type Writer struct {
-	conn     *Connection
+	conn     Connection
+	tip      uint64
 	messages <-chan chain.Message
-	log      *logrus.Entry
+	receipts chain.ReceiptLog
+	pricer   chain.Pricer
+	// nil unless failed deliveries are moved to a dead-letter queue
+	deadLetters chain.DeadLetterQueue
+	gate        *chain.Gate
+	dispatcher  *chain.Dispatcher
+	// names of the apps by their ID, to label metrics
+	apps       map[[20]byte]string
+	throughput *chain.ThroughputTuner
+	// retries submissions which failed with a transient error
+	backoff *chain.Backoff
+	// captures the state of the writer once its queues back up
+	monitor *chain.BackpressureMonitor
+	// nil unless extrinsics are recorded before they are signed
+	intents chain.IntentLog
+	// weight which extrinsics may fill in a block, 0 if unknown
+	blockWeight uint64
+	// monitoring of the balance of the account, and the fee last estimated for an
+	// extrinsic, which it must cover, nil until one is estimated
+	balance  chain.BalanceConfig
+	lastFee  *big.Int
+	feeMutex sync.Mutex
+	// nil until the writer starts, or if the balance isn't monitored
+	balanceMonitor *chain.BalanceMonitor
+	// refuses extrinsics whose estimated weight or fee exceeds the cap
+	costCap *chain.CostCap
+	// next account nonce, tracked locally so that concurrently submitted
+	// extrinsics do not reuse a nonce. Unset after a failed submission.
+	nonce      *uint32
+	nonceMutex sync.Mutex
+	log        *logrus.Entry
 }
 
-func NewWriter(conn *Connection, messages <-chan chain.Message, log *logrus.Entry) (*Writer, error) {
-	return &Writer{
-		conn:     conn,
-		messages: messages,
-		log:      log,
-	}, nil
+func NewWriter(config *Config, conn Connection, messages <-chan chain.Message, receipts chain.ReceiptLog, pricer chain.Pricer, log *logrus.Entry) (*Writer, error) {
+	costCap, err := chain.NewCostCap(Name, "weight", config.CostCap.MaxWeight, config.CostCap.MaxFee, config.CostCap.MaxCost, pricer)
+	if err != nil {
+		return nil, err
+	}
+
+	wr := &Writer{
+		conn:       conn,
+		tip:        config.EscalationTip,
+		balance:    config.Balance,
+		costCap:    costCap,
+		messages:   messages,
+		receipts:   receipts,
+		pricer:     pricer,
+		gate:       chain.NewGate(),
+		throughput: chain.NewThroughputTuner(Name, &config.AutoTune, log),
+		backoff:    chain.NewBackoff(Name, &config.RPC.Backoff),
+		log:        log,
+	}
+
+	wr.dispatcher = chain.NewDispatcher(Name, wr.gate, wr.handle, log)
+	wr.dispatcher.Tune(wr.throughput)
+	wr.monitor = chain.NewBackpressureMonitor(Name, &config.Diagnostics, wr.inspect, log)
+	wr.dispatcher.Monitor(wr.monitor)
+	wr.apps = make(map[[20]byte]string, len(config.Targets))
+	for name := range config.Throttle {
+		if _, ok := config.Targets[name]; !ok {
+			return nil, fmt.Errorf("throttle configured for unknown app %s", name)
+		}
+	}
+	for name := range config.Budget {
+		if _, ok := config.Targets[name]; !ok {
+			return nil, fmt.Errorf("budget configured for unknown app %s", name)
+		}
+	}
+
+	for name, appID := range config.Targets {
+		wr.apps[appID] = name
+		var throttle *chain.ThrottleConfig
+		if value, ok := config.Throttle[name]; ok {
+			throttle = &value
+		}
+		var budget *chain.BudgetConfig
+		if value, ok := config.Budget[name]; ok {
+			budget = &value
+		}
+		if throttle != nil || budget != nil {
+			wr.dispatcher.AddLane(name, appID, throttle, budget)
+		}
+	}
+	for i := range config.Overrides {
+		wr.dispatcher.AddOverride(&config.Overrides[i])
+	}
+
+	return wr, nil
 }
 
 func (wr *Writer) Start(ctx context.Context, eg *errgroup.Group) error {
+	if wr.throughput.Enabled() {
+		wr.blockWeight = maximumBlockWeight(wr.conn.Metadata())
+	}
+
+	err := wr.setupBalance()
+	if err != nil {
+		return err
+	}
+
 	eg.Go(func() error {
 		return wr.writeLoop(ctx)
 	})
@@ -38,59 +134,336 @@ func (wr *Writer) Start(ctx context.Context, eg *errgroup.Group) error {
 }
 
 func (wr *Writer) writeLoop(ctx context.Context) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case msg := <-wr.messages:
-			err := wr.Write(ctx, &msg)
-			if err != nil {
-				wr.log.WithFields(logrus.Fields{
-					"appid": hex.EncodeToString(msg.AppID[:]),
-					"error": err,
-				}).Error("Failure submitting message to substrate")
-			}
-		}
+	return wr.dispatcher.Run(ctx, wr.messages)
+}
+
+func (wr *Writer) handle(ctx context.Context, msg *chain.Message, escalate bool) {
+	wr.deliver(ctx, msg, escalate, 1)
+}
+
+// deliver submits a message, retrying failed submissions, as the given delivery of the
+// message. Deliveries after the first follow earlier ones which failed on-chain or were
+// dropped from the pool.
+func (wr *Writer) deliver(ctx context.Context, msg *chain.Message, escalate bool, delivery int) {
+	attempts := delivery - 1
+	err := wr.backoff.Retry(ctx, wr.log, "submit message", func() error {
+		attempts++
+		return wr.write(ctx, msg, escalate, delivery)
+	})
+	if err != nil {
+		metrics.TransactionsFailed.WithLabelValues(Name, wr.app(msg)).Inc()
+		wr.log.WithFields(msg.LogFields()).WithFields(logrus.Fields{
+			"appid": hex.EncodeToString(msg.AppID[:]),
+			"error": err,
+		}).Error("Failure submitting message to substrate")
+		chain.DeadLetter(ctx, wr.deadLetters, Name, msg, err, attempts, wr.log)
 	}
 }
 
+// DivertFailures moves the messages whose submission failed permanently to a dead-letter queue
+func (wr *Writer) DivertFailures(queue chain.DeadLetterQueue) {
+	wr.deadLetters = queue
+}
+
+// ReportBackpressure records the state of the writer captured once its queues back up in a
+// diagnostic log
+func (wr *Writer) ReportBackpressure(diagnostics chain.DiagnosticLog) {
+	wr.monitor.Record(diagnostics)
+}
+
+// RecordIntents records the nonce of each extrinsic in an intent log before it is signed
+func (wr *Writer) RecordIntents(intents chain.IntentLog) {
+	wr.intents = intents
+}
+
+// AccountNonces returns the nonce of the relayer account in the latest block, by address
+func (wr *Writer) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	nonce, err := wr.accountNonce(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]uint64{wr.conn.Keypair().Address: uint64(nonce)}, nil
+}
+
+// app returns the name of the app whose message is delivered, for labelling metrics
+func (wr *Writer) app(msg *chain.Message) string {
+	if name, ok := wr.apps[msg.AppID]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Queues returns the messages pending submission from each app
+func (wr *Writer) Queues() []chain.QueueStats {
+	return wr.dispatcher.Queues()
+}
+
+// Boost escalates the submission of a queued message
+func (wr *Writer) Boost(id string) (*chain.Boost, error) {
+	return wr.dispatcher.Boost(id)
+}
+
+// LastSubmission returns when a submission last completed, zero if none did
+func (wr *Writer) LastSubmission() time.Time {
+	return wr.dispatcher.LastSubmission()
+}
+
+// Gate returns the gate through which submissions can be halted
+func (wr *Writer) Gate() *chain.Gate {
+	return wr.gate
+}
+
 // Write submits a transaction to the chain
-func (wr *Writer) Write(_ context.Context, msg *chain.Message) error {
+func (wr *Writer) Write(ctx context.Context, msg *chain.Message) error {
+	return wr.write(ctx, msg, false, 1)
+}
+
+// write submits a message, paying the configured tip for escalated extrinsics
+func (wr *Writer) write(ctx context.Context, msg *chain.Message, escalate bool, delivery int) error {
+	ctx, span := tracing.Start(tracing.ContextWith(ctx, msg.Trace), "submit message")
+	span.SetAttribute("chain", Name)
+	span.SetAttribute("app", wr.app(msg))
+	span.SetAttribute("messageID", msg.ID)
+	err := wr.submit(ctx, msg, escalate, delivery)
+	span.End(err)
+	return err
+}
+
+func (wr *Writer) submit(ctx context.Context, msg *chain.Message, escalate bool, delivery int) error {
+	var tip uint64
+	if escalate {
+		tip = wr.tip
+	}
 
-	c, err := types.NewCall(&wr.conn.metadata, "Bridge.submit", msg.AppID, msg.Payload)
+	_, build := tracing.Start(ctx, "build extrinsic")
+	extI, err := wr.build(ctx, msg, tip)
+	build.End(err)
 	if err != nil {
 		return err
 	}
 
-	ext := types.NewExtrinsic(c)
+	// the fee is estimated before submission, as it is not reported by the subscription, and
+	// for the balance monitor and the cost cap
+	watched := wr.receipts != nil || wr.throughput.Enabled()
+	var fee *big.Int
+	if watched || wr.balance.Interval > 0 || wr.costCap.Enabled() {
+		var weight uint64
+		fee, weight, err = wr.queryInfo(ctx, extI, tip)
+		if err != nil {
+			wr.log.WithError(err).Debug("Failed to estimate fee of extrinsic")
+			if wr.costCap.Enabled() {
+				wr.resetNonce()
+				return fmt.Errorf("estimate fee of extrinsic: %w", err)
+			}
+		}
+		wr.recordFee(fee)
+		wr.throughput.SetBlockCapacity(wr.blockWeight, weight)
 
-	era := types.ExtrinsicEra{IsMortalEra: false}
+		err = wr.costCap.Check(ctx, wr.app(msg), weight, fee)
+		if err != nil {
+			wr.resetNonce()
+			wr.log.WithFields(msg.LogFields()).WithError(err).Warn("Refused extrinsic exceeding the cost cap")
+			return err
+		}
+	}
+
+	// extrinsics are followed for the receipt log and to tune throughput
+	if !watched {
+		_, span := tracing.Start(ctx, "send extrinsic")
+		_, err = wr.conn.Client().SubmitExtrinsic(ctx, extI)
+		span.End(err)
+		if err != nil {
+			wr.resetNonce()
+			return err
+		}
+		wr.submitted(ctx, msg)
+	} else {
+		hash, err := extrinsicHash(extI)
+		if err != nil {
+			wr.resetNonce()
+			return err
+		}
+
+		_, span := tracing.Start(ctx, "send extrinsic")
+		span.SetAttribute("hash", hash.Hex())
+		sub, err := wr.conn.Client().SubmitAndWatchExtrinsic(ctx, extI)
+		span.End(err)
+		if err != nil {
+			wr.resetNonce()
+			wr.throughput.Rejected()
+			return err
+		}
+
+		wr.submitted(ctx, msg)
+
+		receipt := chain.Receipt{
+			Chain:       Name,
+			Hash:        hash.Hex(),
+			SubmittedAt: time.Now().UTC(),
+		}
+		if wr.receipts != nil {
+			wr.receipts.Submitted(msg, &receipt)
+		}
+		wr.throughput.Submitted()
+		go wr.confirm(ctx, sub, *msg, receipt, hash, fee, delivery)
+	}
+
+	wr.log.WithFields(msg.LogFields()).WithField("appid", hex.EncodeToString(msg.AppID[:])).Info("Submitted message to Substrate")
+
+	return nil
+}
+
+// build signs the extrinsic of a message with the next nonce of the account
+func (wr *Writer) build(ctx context.Context, msg *chain.Message, tip uint64) (types.Extrinsic, error) {
+	onchain, err := wr.accountNonce(ctx)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+
+	extI, err := wr.sign(ctx, msg, wr.nextNonce(onchain), tip)
+	if err != nil {
+		wr.resetNonce()
+		return types.Extrinsic{}, err
+	}
+	return extI, nil
+}
+
+// submitted counts a submitted extrinsic, observing the delay since its message was
+// observed. The confirmation of the delivery descends from the span of its submission.
+func (wr *Writer) submitted(ctx context.Context, msg *chain.Message) {
+	if sc := tracing.FromContext(ctx); sc.Valid() {
+		msg.Trace = sc
+	}
+	metrics.TransactionsSubmitted.WithLabelValues(Name, wr.app(msg)).Inc()
+	if !msg.ObservedAt.IsZero() {
+		metrics.SubmissionDelay.WithLabelValues(Name).Observe(time.Since(msg.ObservedAt).Seconds())
+	}
+}
+
+// queryInfo returns the fee charged for an extrinsic, including its tip, and its weight
+func (wr *Writer) queryInfo(ctx context.Context, ext types.Extrinsic, tip uint64) (*big.Int, uint64, error) {
+	encoded, err := types.EncodeToHexString(ext)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var info struct {
+		Weight     uint64          `json:"weight"`
+		PartialFee json.RawMessage `json:"partialFee"`
+	}
+	err = wr.conn.Client().Call(ctx, &info, "payment_queryInfo", encoded)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// balances are serialized as numbers or strings depending on the node version
+	value := strings.Trim(string(info.PartialFee), `"`)
+	fee, ok := new(big.Int).SetString(value, 0)
+	if !ok {
+		return nil, info.Weight, fmt.Errorf("invalid partial fee %s", info.PartialFee)
+	}
+	return fee.Add(fee, new(big.Int).SetUint64(tip)), info.Weight, nil
+}
+
+// maximumBlockWeight returns the MaximumBlockWeight constant of the System module, or 0 if
+// the runtime does not declare it
+func maximumBlockWeight(meta *types.Metadata) uint64 {
+	var modules []types.ModuleMetadataV10
+	switch {
+	case meta.IsMetadataV11:
+		modules = meta.AsMetadataV11.Modules
+	case meta.IsMetadataV10:
+		modules = meta.AsMetadataV10.Modules
+	}
+
+	for _, module := range modules {
+		if module.Name != "System" {
+			continue
+		}
+		for _, constant := range module.Constants {
+			if constant.Name != "MaximumBlockWeight" {
+				continue
+			}
+			var weight types.U64
+			err := types.DecodeFromBytes(constant.Value, &weight)
+			if err != nil {
+				return 0
+			}
+			return uint64(weight)
+		}
+	}
+	return 0
+}
+
+// Build signs the extrinsic submitting a message without sending it, using the
+// account nonce of the latest block
+func (wr *Writer) Build(ctx context.Context, msg *chain.Message) (types.Extrinsic, error) {
+	nonce, err := wr.accountNonce(ctx)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+	return wr.sign(ctx, msg, nonce, 0)
+}
 
-	genesisHash, err := wr.conn.api.RPC.Chain.GetBlockHash(0)
+// Remark submits a System.remark extrinsic carrying data, without waiting for its inclusion
+func (wr *Writer) Remark(ctx context.Context, data []byte) error {
+	c, err := types.NewCall(wr.conn.Metadata(), "System.remark", data)
 	if err != nil {
 		return err
 	}
 
-	rv, err := wr.conn.api.RPC.State.GetRuntimeVersionLatest()
+	onchain, err := wr.accountNonce(ctx)
 	if err != nil {
 		return err
 	}
 
-	key, err := types.CreateStorageKey(&wr.conn.metadata, "System", "Account", wr.conn.kp.PublicKey, nil)
+	ext, err := wr.signCall(ctx, c, wr.nextNonce(onchain), 0)
 	if err != nil {
+		wr.resetNonce()
 		return err
 	}
 
-	var accountInfo types.AccountInfo
-	ok, err := wr.conn.api.RPC.State.GetStorageLatest(key, &accountInfo)
+	_, err = wr.conn.Client().SubmitExtrinsic(ctx, ext)
 	if err != nil {
+		wr.resetNonce()
 		return err
 	}
-	if !ok {
-		return fmt.Errorf("no account info found for %s", wr.conn.kp.URI)
+
+	return nil
+}
+
+// sign builds and signs the extrinsic submitting a message
+func (wr *Writer) sign(ctx context.Context, msg *chain.Message, nonce uint32, tip uint64) (types.Extrinsic, error) {
+	c, err := wr.call(msg)
+	if err != nil {
+		return types.Extrinsic{}, chain.Permanent(err)
 	}
 
-	nonce := uint32(accountInfo.Nonce)
+	return wr.signCall(ctx, c, nonce, tip)
+}
+
+// signCall signs the extrinsic of a call with the relayer account
+func (wr *Writer) signCall(ctx context.Context, c types.Call, nonce uint32, tip uint64) (types.Extrinsic, error) {
+	if wr.intents != nil {
+		err := wr.intents.RecordIntent(Name, wr.conn.Keypair().Address, uint64(nonce))
+		if err != nil {
+			return types.Extrinsic{}, fmt.Errorf("record intent: %w", err)
+		}
+	}
+
+	ext := types.NewExtrinsic(c)
+
+	era := types.ExtrinsicEra{IsMortalEra: false}
+
+	genesisHash, err := wr.conn.Client().GetBlockHash(ctx, 0)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
+
+	rv, err := wr.conn.Client().GetRuntimeVersionLatest(ctx)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
 
 	o := types.SignatureOptions{
 		BlockHash:   genesisHash,
@@ -99,24 +472,55 @@ func (wr *Writer) Write(_ context.Context, msg *chain.Message) error {
 		Nonce:       types.NewUCompactFromUInt(uint64(nonce)),
 		SpecVersion: rv.SpecVersion,
 		TxVersion:   1,
-		Tip:         types.NewUCompactFromUInt(0),
+		Tip:         types.NewUCompactFromUInt(tip),
 	}
 
-	extI := ext
+	err = ext.Sign(*wr.conn.Keypair(), o)
+	if err != nil {
+		return types.Extrinsic{}, err
+	}
 
-	err = extI.Sign(*wr.conn.kp, o)
+	return ext, nil
+}
+
+// accountNonce returns the nonce of the relayer account in the latest block
+func (wr *Writer) accountNonce(ctx context.Context) (uint32, error) {
+	key, err := types.CreateStorageKey(wr.conn.Metadata(), "System", "Account", wr.conn.Keypair().PublicKey, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = wr.conn.api.RPC.Author.SubmitExtrinsic(extI)
+	var accountInfo types.AccountInfo
+	ok, err := wr.conn.Client().GetStorageLatest(ctx, key, &accountInfo)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no account info found for %s", wr.conn.Keypair().URI)
 	}
 
-	wr.log.WithFields(logrus.Fields{
-		"appid": hex.EncodeToString(msg.AppID[:]),
-	}).Info("Submitted message to Substrate")
+	return uint32(accountInfo.Nonce), nil
+}
 
-	return nil
+// nextNonce reserves the next account nonce. The local counter is only used while it
+// is ahead of the nonce in account storage, which excludes extrinsics still in the pool.
+func (wr *Writer) nextNonce(onchain uint32) uint32 {
+	wr.nonceMutex.Lock()
+	defer wr.nonceMutex.Unlock()
+
+	nonce := onchain
+	if wr.nonce != nil && *wr.nonce > nonce {
+		nonce = *wr.nonce
+	}
+	next := nonce + 1
+	wr.nonce = &next
+
+	return nonce
+}
+
+// resetNonce discards the locally tracked nonce after a failed submission
+func (wr *Writer) resetNonce() {
+	wr.nonceMutex.Lock()
+	defer wr.nonceMutex.Unlock()
+	wr.nonce = nil
 }
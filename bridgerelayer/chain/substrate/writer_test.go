@@ -5,11 +5,14 @@ package substrate_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"golang.org/x/sync/errgroup"
 
@@ -24,14 +27,14 @@ func TestWrite(t *testing.T) {
 	logger, hook := test.NewNullLogger()
 	log := logger.WithField("chain", "Substrate")
 
-	conn := substrate.NewConnection("ws://127.0.0.1:9944/", sr25519.Alice().AsKeyringPair(), log)
+	conn := substrate.NewConnection("ws://127.0.0.1:9944/", sr25519.Alice().AsKeyringPair(), &substrate.PropertiesConfig{}, nil, log)
 
 	messages := make(chan chain.Message, 1)
 	ctx, cancel := context.WithCancel(context.Background())
 	eg, ctx := errgroup.WithContext(ctx)
 	defer cancel()
 
-	writer, err := substrate.NewWriter(conn, messages, log)
+	writer, err := substrate.NewWriter(&substrate.Config{}, conn, messages, nil, nil, log)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,3 +61,22 @@ func TestWrite(t *testing.T) {
 	assert.Equal(t, "Submitted message to Substrate", hook.LastEntry().Message)
 
 }
+
+func TestWrite_CanceledContext(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	log := logger.WithField("chain", "Substrate")
+
+	client := substrate.NewMockClient()
+	conn := substrate.NewMockConnection(&signature.TestKeyringPairAlice, substrate.MetadataExemplary, client)
+
+	writer, err := substrate.NewWriter(&substrate.Config{}, conn, nil, nil, nil, log)
+	require.NoError(t, err)
+
+	// calls are abandoned rather than waited out once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = writer.Write(ctx, &chain.Message{AppID: AppID, Payload: []byte{0, 1, 2}})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, client.Submitted())
+}
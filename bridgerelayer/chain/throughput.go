@@ -0,0 +1,341 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+)
+
+// AutoTuneConfig bounds the tuning of the submissions of a writer to the capacity of its
+// target chain. Zero values take the defaults.
+type AutoTuneConfig struct {
+	// Target seconds from the submission of a delivery to its inclusion in a block. Zero
+	// disables tuning.
+	TargetLatency uint64 `mapstructure:"target-latency"`
+	// Bounds of the number of deliveries pending inclusion at once, across all apps
+	MinPending int `mapstructure:"min-pending"`
+	MaxPending int `mapstructure:"max-pending"`
+	// Bounds of the submissions per minute. The rate is not limited if MaxRate is zero.
+	MinRate float64 `mapstructure:"min-rate"`
+	MaxRate float64 `mapstructure:"max-rate"`
+	// Share of the latest deliveries which may fail before submissions are backed off
+	MaxFailureRate float64 `mapstructure:"max-failure-rate"`
+	// Number of latest deliveries over which the failure rate is measured
+	Window int `mapstructure:"window"`
+	// Share of the block gas or weight limit which pending deliveries may fill
+	BlockShare float64 `mapstructure:"block-share"`
+}
+
+const (
+	defaultMaxPending     = 16
+	defaultMinRate        = 1
+	defaultMaxFailureRate = 0.1
+	defaultTuneWindow     = 20
+	defaultBlockShare     = 0.5
+	// rateSteps is the number of successful deliveries over which the rate climbs from
+	// its lower to its upper bound
+	rateSteps = 10
+)
+
+// ThroughputTuner paces the submissions of a writer to what its target chain includes, so
+// that the relayer neither floods the mempool nor fills blocks beyond their limits. The
+// number of deliveries pending inclusion and the submission rate are raised step by step
+// after each delivery included within the target latency, and halved after each delivery
+// which was late, dropped or rejected, or while too many of the latest deliveries failed.
+// Pending deliveries are also capped by the share of a block they may fill.
+type ThroughputTuner struct {
+	chain          string
+	target         time.Duration
+	minPending     int
+	maxPending     int
+	minRate        float64
+	maxRate        float64
+	maxFailureRate float64
+	blockShare     float64
+	limiter        *rate.Limiter
+	mutex          sync.Mutex
+	// deliveries reserved by the dispatcher and pending inclusion
+	reserved int
+	pending  int
+	// tuned bounds, and the deliveries fitting in the block share, 0 if unknown
+	limit    int
+	rate     float64
+	capacity int
+	// failures among the latest deliveries, in a ring
+	outcomes []bool
+	next     int
+	// closed and replaced whenever a slot is freed
+	freed chan struct{}
+	log   *logrus.Entry
+}
+
+func NewThroughputTuner(chain string, config *AutoTuneConfig, log *logrus.Entry) *ThroughputTuner {
+	tt := &ThroughputTuner{
+		chain:          chain,
+		target:         time.Duration(config.TargetLatency) * time.Second,
+		minPending:     config.MinPending,
+		maxPending:     config.MaxPending,
+		minRate:        config.MinRate,
+		maxRate:        config.MaxRate,
+		maxFailureRate: config.MaxFailureRate,
+		blockShare:     config.BlockShare,
+		freed:          make(chan struct{}),
+		log:            log,
+	}
+	if tt.minPending <= 0 {
+		tt.minPending = 1
+	}
+	if tt.maxPending <= 0 {
+		tt.maxPending = defaultMaxPending
+	}
+	if tt.maxPending < tt.minPending {
+		tt.maxPending = tt.minPending
+	}
+	if tt.minRate <= 0 {
+		tt.minRate = defaultMinRate
+	}
+	if tt.maxRate > 0 && tt.maxRate < tt.minRate {
+		tt.minRate = tt.maxRate
+	}
+	if tt.maxFailureRate <= 0 {
+		tt.maxFailureRate = defaultMaxFailureRate
+	}
+	if tt.blockShare <= 0 || tt.blockShare > 1 {
+		tt.blockShare = defaultBlockShare
+	}
+
+	window := config.Window
+	if window <= 0 {
+		window = defaultTuneWindow
+	}
+	tt.outcomes = make([]bool, 0, window)
+
+	// submissions start slowly, and speed up as the chain keeps up
+	tt.limit = tt.minPending
+	if tt.maxRate > 0 {
+		tt.rate = tt.minRate
+		tt.limiter = rate.NewLimiter(rate.Limit(tt.rate/60), 1)
+	}
+	return tt
+}
+
+// Enabled returns whether submissions are tuned
+func (tt *ThroughputTuner) Enabled() bool {
+	return tt != nil && tt.target > 0
+}
+
+// Wait blocks until a delivery may be submitted within the tuned bounds, reserving a
+// slot for it which is held until Done is called
+func (tt *ThroughputTuner) Wait(ctx context.Context) error {
+	for {
+		tt.mutex.Lock()
+		if tt.reserved+tt.pending < tt.effectiveLimit() {
+			tt.reserved++
+			tt.mutex.Unlock()
+			break
+		}
+		freed := tt.freed
+		tt.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-freed:
+		}
+	}
+
+	if tt.limiter != nil {
+		err := tt.limiter.Wait(ctx)
+		if err != nil {
+			tt.Done()
+			return err
+		}
+	}
+	return nil
+}
+
+// Done releases the slot reserved by Wait once the delivery was handed to the writer,
+// which reports its submission and inclusion as they happen
+func (tt *ThroughputTuner) Done() {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tt.reserved--
+	tt.freeLocked()
+}
+
+// Submitted counts a delivery sent to the chain as pending until it is included, dropped
+// or forgotten. Writers report deliveries whether or not submissions are tuned.
+func (tt *ThroughputTuner) Submitted() {
+	if !tt.Enabled() {
+		return
+	}
+
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tt.pending++
+}
+
+// Included tunes the submissions after a pending delivery was included with the given
+// latency, successful or not
+func (tt *ThroughputTuner) Included(latency time.Duration) {
+	tt.resolve(true, latency > tt.target, false, latency)
+}
+
+// Dropped tunes the submissions after a pending delivery was never included
+func (tt *ThroughputTuner) Dropped() {
+	tt.resolve(true, false, true, 0)
+}
+
+// Rejected tunes the submissions after the chain refused to accept a delivery
+func (tt *ThroughputTuner) Rejected() {
+	tt.resolve(false, false, true, 0)
+}
+
+// Forget stops counting a pending delivery whose fate is unknown, without tuning
+func (tt *ThroughputTuner) Forget() {
+	if !tt.Enabled() {
+		return
+	}
+
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tt.pending--
+	tt.freeLocked()
+}
+
+// SetBlockCapacity caps the pending deliveries at those fitting in the block share, given
+// the gas or weight limit of a block and the gas or weight of a delivery. Unknown limits,
+// given as zero, leave the capacity as it is.
+func (tt *ThroughputTuner) SetBlockCapacity(blockLimit uint64, cost uint64) {
+	if !tt.Enabled() || blockLimit == 0 || cost == 0 {
+		return
+	}
+	capacity := int(float64(blockLimit) * tt.blockShare / float64(cost))
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	if capacity == tt.capacity {
+		return
+	}
+	tt.capacity = capacity
+	tt.freeLocked()
+	tt.log.WithFields(logrus.Fields{
+		"blockLimit": blockLimit,
+		"cost":       cost,
+		"capacity":   capacity,
+	}).Debug("Updated block capacity for submissions")
+}
+
+// Limits returns the tuned number of pending deliveries and submissions per minute, which
+// is zero if the rate is not limited
+func (tt *ThroughputTuner) Limits() (int, float64) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	return tt.effectiveLimit(), tt.rate
+}
+
+// resolve tunes the submissions after the outcome of a delivery. Late deliveries slow
+// down submissions without counting as failures.
+func (tt *ThroughputTuner) resolve(pending bool, late bool, failed bool, latency time.Duration) {
+	if !tt.Enabled() {
+		return
+	}
+
+	tt.mutex.Lock()
+	if pending {
+		tt.pending--
+	}
+
+	tt.record(failed)
+	previousLimit, previousRate := tt.effectiveLimit(), tt.rate
+	backOff := late || failed || tt.failureRate() > tt.maxFailureRate
+	if backOff {
+		tt.limit /= 2
+		if tt.limit < tt.minPending {
+			tt.limit = tt.minPending
+		}
+	} else if tt.limit < tt.maxPending {
+		tt.limit++
+	}
+	if tt.limiter != nil {
+		if backOff {
+			tt.rate /= 2
+			if tt.rate < tt.minRate {
+				tt.rate = tt.minRate
+			}
+		} else {
+			tt.rate += (tt.maxRate - tt.minRate) / rateSteps
+			if tt.rate > tt.maxRate {
+				tt.rate = tt.maxRate
+			}
+		}
+		tt.limiter.SetLimit(rate.Limit(tt.rate / 60))
+	}
+	limit, submissionRate := tt.effectiveLimit(), tt.rate
+	tt.freeLocked()
+	tt.mutex.Unlock()
+
+	metrics.TunedPending.WithLabelValues(tt.chain).Set(float64(limit))
+	metrics.TunedRate.WithLabelValues(tt.chain).Set(submissionRate)
+	if pending && !failed {
+		metrics.InclusionLatency.WithLabelValues(tt.chain).Observe(latency.Seconds())
+	}
+	if limit != previousLimit || submissionRate != previousRate {
+		tt.log.WithFields(logrus.Fields{
+			"pending": limit,
+			"rate":    submissionRate,
+			"latency": latency.String(),
+			"failed":  failed,
+		}).Debug("Tuned submission throughput")
+	}
+}
+
+// record adds the outcome of a delivery to the window of latest deliveries
+func (tt *ThroughputTuner) record(failed bool) {
+	if len(tt.outcomes) < cap(tt.outcomes) {
+		tt.outcomes = append(tt.outcomes, failed)
+		return
+	}
+	tt.outcomes[tt.next] = failed
+	tt.next = (tt.next + 1) % len(tt.outcomes)
+}
+
+func (tt *ThroughputTuner) failureRate() float64 {
+	if len(tt.outcomes) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range tt.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(tt.outcomes))
+}
+
+func (tt *ThroughputTuner) effectiveLimit() int {
+	if tt.capacity > 0 && tt.capacity < tt.limit {
+		return tt.capacity
+	}
+	return tt.limit
+}
+
+func (tt *ThroughputTuner) freeLocked() {
+	close(tt.freed)
+	tt.freed = make(chan struct{})
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func newTestTuner(config *chain.AutoTuneConfig) *chain.ThroughputTuner {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return chain.NewThroughputTuner("Ethereum", config, logrus.NewEntry(logger))
+}
+
+func assertLimits(t *testing.T, tuner *chain.ThroughputTuner, pending int, rate float64) {
+	actualPending, actualRate := tuner.Limits()
+	assert.Equal(t, pending, actualPending)
+	assert.InDelta(t, rate, actualRate, 0.001)
+}
+
+func TestThroughputTuner(t *testing.T) {
+	tuner := newTestTuner(&chain.AutoTuneConfig{
+		TargetLatency: 60,
+		MaxPending:    3,
+		MinRate:       6,
+		MaxRate:       60,
+		Window:        2,
+	})
+	assert.True(t, tuner.Enabled())
+	assertLimits(t, tuner, 1, 6)
+
+	// deliveries included in time raise the limits step by step
+	for _, expected := range []struct {
+		pending int
+		rate    float64
+	}{{2, 11.4}, {3, 16.8}, {3, 22.2}} {
+		tuner.Submitted()
+		tuner.Included(10 * time.Second)
+		assertLimits(t, tuner, expected.pending, expected.rate)
+	}
+
+	// late deliveries halve them
+	tuner.Submitted()
+	tuner.Included(2 * time.Minute)
+	assertLimits(t, tuner, 1, 11.1)
+
+	// as do failures, down to the lower bounds
+	tuner.Rejected()
+	assertLimits(t, tuner, 1, 6)
+
+	// the limits are held while too many of the latest deliveries failed
+	tuner.Submitted()
+	tuner.Included(10 * time.Second)
+	assertLimits(t, tuner, 1, 6)
+	tuner.Submitted()
+	tuner.Included(10 * time.Second)
+	assertLimits(t, tuner, 2, 11.4)
+
+	// pending deliveries are capped by the share of a block they may fill
+	tuner.SetBlockCapacity(10000000, 2000000)
+	assertLimits(t, tuner, 2, 11.4)
+	tuner.SetBlockCapacity(2000000, 2000000)
+	assertLimits(t, tuner, 1, 11.4)
+
+	disabled := newTestTuner(&chain.AutoTuneConfig{})
+	disabled.Rejected()
+	assert.False(t, disabled.Enabled())
+	assertLimits(t, disabled, 1, 0)
+}
+
+func TestThroughputTuner_Wait(t *testing.T) {
+	tuner := newTestTuner(&chain.AutoTuneConfig{TargetLatency: 60})
+
+	require.NoError(t, tuner.Wait(context.Background()))
+	tuner.Submitted()
+	tuner.Done()
+
+	// the slot is held until the pending delivery is resolved
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, tuner.Wait(ctx))
+
+	go tuner.Included(time.Second)
+	require.NoError(t, tuner.Wait(context.Background()))
+}
+
+func TestDispatcher_Tune(t *testing.T) {
+	tuner := newTestTuner(&chain.AutoTuneConfig{TargetLatency: 60})
+
+	submitted := make(chan chain.Message, 10)
+	submit := func(_ context.Context, msg *chain.Message, _ bool) {
+		tuner.Submitted()
+		submitted <- *msg
+	}
+
+	dispatcher := chain.NewDispatcher("Ethereum", chain.NewGate(), submit, logrus.NewEntry(logrus.New()))
+	dispatcher.Tune(tuner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan chain.Message, 2)
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx, messages)
+	}()
+	messages <- chain.Message{Payload: 1}
+	messages <- chain.Message{Payload: 2}
+
+	// the second message waits until the first is included
+	<-submitted
+	select {
+	case <-submitted:
+		t.Fatal("submitted beyond the tuned limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tuner.Included(time.Second)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for submission")
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
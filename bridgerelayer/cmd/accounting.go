@@ -0,0 +1,83 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+func accountingCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "accounting",
+		Short:   "Export the daily flow of each token through the bridge recorded by a running relay, for reconciliation",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay accounting --since 30d --format csv --output accounting.csv",
+		RunE:    accountingFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("token", "", "Token to export, such as eth or the address of an ERC20 token, all tokens if empty")
+	cmd.Flags().String("since", "30d", "Period to export, in days such as 30d or as a duration such as 12h")
+	cmd.Flags().String("format", "csv", "Format of the export, csv or json")
+	cmd.Flags().String("output", "", "File to which the export is written, standard output if empty")
+	return cmd
+}
+
+func accountingFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	token, err := cmd.Flags().GetString("token")
+	if err != nil {
+		return err
+	}
+
+	since, err := cmd.Flags().GetString("since")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unknown format %q, expected csv or json", format)
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	flows, err := client.Accounting(token, since)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(flows)
+	}
+	return api.WriteAccountingCSV(w, flows)
+}
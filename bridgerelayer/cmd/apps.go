@@ -0,0 +1,110 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+func appsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apps",
+		Short: "Inspect and toggle the directions in which a running relay relays each app",
+	}
+	cmd.PersistentFlags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List the enabled directions of each app",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay apps list",
+		RunE:    listAppsFn,
+	}
+
+	enable := &cobra.Command{
+		Use:     "enable <app>",
+		Short:   "Relay the messages of an app in a direction",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay apps enable erc20 --direction ethereum-to-substrate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDirectionFn(cmd, args[0], true)
+		},
+	}
+
+	disable := &cobra.Command{
+		Use:     "disable <app>",
+		Short:   "Stop relaying the messages of an app in a direction",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay apps disable erc20 --direction ethereum-to-substrate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDirectionFn(cmd, args[0], false)
+		},
+	}
+
+	for _, c := range []*cobra.Command{enable, disable} {
+		c.Flags().String("direction", "", "ethereum-to-substrate or substrate-to-ethereum")
+		_ = c.MarkFlagRequired("direction")
+	}
+
+	cmd.AddCommand(list, enable, disable)
+	return cmd
+}
+
+func listAppsFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	apps, err := client.Apps()
+	if err != nil {
+		return err
+	}
+
+	printApps(apps)
+	return nil
+}
+
+func setDirectionFn(cmd *cobra.Command, app string, enabled bool) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	direction, err := cmd.Flags().GetString("direction")
+	if err != nil {
+		return err
+	}
+
+	apps, err := client.SetDirection(app, direction, enabled)
+	if err != nil {
+		return err
+	}
+
+	printApps(apps)
+	return nil
+}
+
+func printApps(apps []api.AppRollout) {
+	for _, app := range apps {
+		directions := make([]string, 0, len(app.Directions))
+		for direction := range app.Directions {
+			directions = append(directions, direction)
+		}
+		sort.Strings(directions)
+
+		for _, direction := range directions {
+			state := "disabled"
+			if app.Directions[direction] {
+				state = "enabled"
+			}
+			fmt.Printf("%-10s %-22s %s\n", app.App, direction, state)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func auditLogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "audit-log <file>",
+		Short:   "Decrypt an audit log with the key in ARTEMIS_AUDIT_KEY, printing each record as a line of JSON",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay audit-log /var/lib/artemis-relay/audit.log",
+		RunE:    AuditLogFn,
+	}
+	return cmd
+}
+
+func AuditLogFn(_ *cobra.Command, args []string) error {
+	key, ok := os.LookupEnv("ARTEMIS_AUDIT_KEY")
+	if !ok {
+		return fmt.Errorf("environment variable not set: ARTEMIS_AUDIT_KEY")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(os.Stdout)
+	return core.ReadAuditLog(file, key, func(record *core.AuditRecord) error {
+		return encoder.Encode(record)
+	})
+}
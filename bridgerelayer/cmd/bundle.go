@@ -0,0 +1,82 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func supportBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "support-bundle",
+		Short:   "Collect the version, redacted config, recent logs and status of a running relay into an archive for bug reports",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay support-bundle --output bundle.tar.gz",
+		RunE:    supportBundleFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("status", "", "Status feed endpoint of the relay, found from the config if empty")
+	cmd.Flags().String("output", "", "Path of the archive, artemis-relay-support-<time>.tar.gz if empty")
+	cmd.Flags().Int("logs", 0, "Number of recent log entries to collect, all entries kept by the relay if 0")
+	return cmd
+}
+
+func supportBundleFn(cmd *cobra.Command, _ []string) error {
+	adminEndpoint, err := cmd.Flags().GetString("api")
+	if err != nil {
+		return err
+	}
+
+	statusEndpoint, err := cmd.Flags().GetString("status")
+	if err != nil {
+		return err
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	logs, err := cmd.Flags().GetInt("logs")
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("artemis-relay-support-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	bundle := core.NewSupportBundle(adminEndpoint, statusEndpoint, logs)
+
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(output), ".gz"), ".tar")
+	err = bundle.WriteTo(file, name)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range bundle.Problems() {
+		fmt.Fprintf(os.Stderr, "Not collected: %s\n", problem)
+	}
+	fmt.Printf("Wrote support bundle to %s\n", output)
+	fmt.Println("Review its contents before sharing it, as logs and settings may still reveal addresses and other details.")
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func checkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "check",
+		Short:   "Validate the configuration and endpoints of the relay, printing the findings as JSON and failing on any error",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay check --config /etc/artemis-relay/config.toml",
+		RunE:    CheckFn,
+	}
+	cmd.Flags().String("config", "", "Configuration file, looked up in the default locations if empty")
+	cmd.Flags().Bool("explorer", false, "Check the configuration of an explorer, which has no keys")
+	cmd.Flags().Duration("timeout", 30*time.Second, "Time given to the checks of the endpoints")
+	return cmd
+}
+
+func CheckFn(cmd *cobra.Command, _ []string) error {
+	// the report is the output, only warnings of the services checked are logged
+	logrus.SetLevel(logrus.WarnLevel)
+
+	configFile, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return err
+	}
+
+	explorer, err := cmd.Flags().GetBool("explorer")
+	if err != nil {
+		return err
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+
+	core.SetConfigFile(configFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	report := core.Check(ctx, explorer)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	if !report.Passed {
+		errors := 0
+		for _, finding := range report.Findings {
+			if finding.Severity == core.SeverityError {
+				errors++
+			}
+		}
+		return fmt.Errorf("check failed with %d errors", errors)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func consoleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "console",
+		Short:   "Query the chains interactively through the relay's connections",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay console --exec \"sub events 1200\"",
+		RunE:    ConsoleFn,
+	}
+	cmd.Flags().StringArray("exec", nil, "Execute a command and exit, can be repeated")
+	return cmd
+}
+
+func ConsoleFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+	// keep the console readable, connections log at info level
+	logrus.SetLevel(logrus.WarnLevel)
+
+	commands, err := cmd.Flags().GetStringArray("exec")
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	console, err := core.OpenConsole(ctx)
+	if err != nil {
+		return err
+	}
+	defer console.Close()
+
+	if len(commands) == 0 {
+		return console.Run(ctx, os.Stdin, os.Stdout, true)
+	}
+
+	for _, command := range commands {
+		err := console.Exec(ctx, command, os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
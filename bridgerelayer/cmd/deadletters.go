@@ -0,0 +1,92 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func deadLettersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dead-letters",
+		Short: "Inspect and requeue the messages whose delivery failed permanently",
+	}
+	cmd.PersistentFlags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List dead-lettered messages in the order in which their delivery failed",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay dead-letters list",
+		RunE:    listDeadLettersFn,
+	}
+
+	show := &cobra.Command{
+		Use:     "show <message-id>",
+		Short:   "Show a dead-lettered message with its payload and last error",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay dead-letters show 6f1c...",
+		RunE:    showDeadLetterFn,
+	}
+
+	requeue := &cobra.Command{
+		Use:     "requeue <message-id>",
+		Short:   "Route a dead-lettered message again",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay dead-letters requeue 6f1c...",
+		RunE:    requeueFn,
+	}
+
+	cmd.AddCommand(list, show, requeue)
+	return cmd
+}
+
+func listDeadLettersFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	letters, err := client.DeadLetters()
+	if err != nil {
+		return err
+	}
+
+	for _, letter := range letters {
+		fmt.Printf("%s %s %-10s block %-10d %d attempts  %s\n", letter.FailedAt.Format("2006-01-02T15:04:05Z"), letter.ID, letter.Target, letter.SourceBlock, letter.Attempts, letter.Error)
+	}
+
+	return nil
+}
+
+func showDeadLetterFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	letter, err := client.GetDeadLetter(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printJSON(letter)
+}
+
+func requeueFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	letter, err := client.Requeue(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Requeued message %s for delivery to %s\n", letter.ID, letter.Target)
+	return nil
+}
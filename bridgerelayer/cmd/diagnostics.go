@@ -0,0 +1,69 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func diagnosticsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diagnostics",
+		Short: "Inspect the state of the writers captured while their queues backed up",
+	}
+	cmd.PersistentFlags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List the captured diagnostics in the order in which they were captured",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay diagnostics list",
+		RunE:    listDiagnosticsFn,
+	}
+
+	show := &cobra.Command{
+		Use:     "show <id>",
+		Short:   "Show the nonces, pending submissions, RPC latencies and fees of a capture",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay diagnostics show 1601553600000000000-ethereum",
+		RunE:    showDiagnosticsFn,
+	}
+
+	cmd.AddCommand(list, show)
+	return cmd
+}
+
+func listDiagnosticsFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	list, err := client.Diagnostics()
+	if err != nil {
+		return err
+	}
+
+	for _, diagnostics := range list {
+		fmt.Printf("%s %s %-10s %d queued  %d pending  %d problems\n", diagnostics.CapturedAt.Format("2006-01-02T15:04:05Z"), diagnostics.ID, diagnostics.Chain, diagnostics.Queued, len(diagnostics.Pending), len(diagnostics.Problems))
+	}
+
+	return nil
+}
+
+func showDiagnosticsFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	diagnostics, err := client.GetDiagnostics(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printJSON(diagnostics)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func drillCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "drill",
+		Short:   "Rehearse failure scenarios against test networks",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay drill --scenario stall-writer --grace 1m",
+		RunE:    DrillFn,
+	}
+	cmd.Flags().StringSlice("scenario", core.DrillScenarios, "Drill scenarios to run")
+	cmd.Flags().Duration("grace", 30*time.Second, "Time given to the relay to recover from each failure")
+	cmd.Flags().String("report", "", "Write a JSON report to this file")
+	return cmd
+}
+
+func DrillFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+
+	scenarios, err := cmd.Flags().GetStringSlice("scenario")
+	if err != nil {
+		return err
+	}
+
+	grace, err := cmd.Flags().GetDuration("grace")
+	if err != nil {
+		return err
+	}
+
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+
+	results := []*core.DrillResult{}
+	failures := 0
+	for _, scenario := range scenarios {
+		result, err := core.RunDrill(scenario, grace)
+		if err != nil {
+			return err
+		}
+		if !result.Passed {
+			failures++
+		}
+		results = append(results, result)
+	}
+
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("%s %s (%s, %d errors logged)\n", status, result.Scenario, result.Duration.Round(time.Millisecond), result.Errors)
+		fmt.Printf("  expected: %s\n", result.Expected)
+		fmt.Printf("  observed: %s\n", result.Observed)
+	}
+
+	if reportPath != "" {
+		report, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(reportPath, report, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d drills failed", failures, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+func emergencyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "emergency",
+		Short: "Halt and resume all submissions of a running relay to a chain",
+	}
+	cmd.PersistentFlags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List whether the submissions to each chain are halted, and why",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay emergency list",
+		RunE:    listHaltsFn,
+	}
+
+	stop := &cobra.Command{
+		Use:     "stop <chain>",
+		Short:   "Halt all submissions to a chain until it is resumed",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay emergency stop ethereum --reason \"suspected exploit\"",
+		RunE:    stopFn,
+	}
+	stop.Flags().String("reason", "", "Reason of the stop, reported by the relay")
+
+	resume := &cobra.Command{
+		Use:     "resume <chain>",
+		Short:   "Withdraw the emergency stop of a chain",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay emergency resume ethereum",
+		RunE:    resumeFn,
+	}
+
+	cmd.AddCommand(list, stop, resume)
+	return cmd
+}
+
+func listHaltsFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	halts, err := client.Halts()
+	if err != nil {
+		return err
+	}
+
+	printHalts(halts)
+	return nil
+}
+
+func stopFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	reason, err := cmd.Flags().GetString("reason")
+	if err != nil {
+		return err
+	}
+
+	halts, err := client.Stop(args[0], reason)
+	if err != nil {
+		return err
+	}
+
+	printHalts(halts)
+	return nil
+}
+
+func resumeFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	halts, err := client.Resume(args[0])
+	if err != nil {
+		return err
+	}
+
+	printHalts(halts)
+	return nil
+}
+
+func printHalts(halts []api.ChainHalt) {
+	for _, halt := range halts {
+		state := "running"
+		if halt.Halted {
+			state = "halted: " + halt.Reasons
+		}
+		fmt.Printf("%-10s %s\n", halt.Chain, state)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func fastSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "fast-sync",
+		Short:   "Initialize the store from the signed snapshot published by another relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay fast-sync --from s3://bucket/relay --signer 0x89b4AB1eF20763630df9743ACF155865600daFF2",
+		RunE:    FastSyncFn,
+	}
+	cmd.Flags().String("from", "", "Object storage URL where the snapshot is published")
+	cmd.Flags().String("signer", "", "Ethereum address of the relay trusted to sign the snapshot")
+	cmd.Flags().Bool("force", false, "Overwrite records in a non-empty local store")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("signer")
+	return cmd
+}
+
+func FastSyncFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+
+	signer, err := cmd.Flags().GetString("signer")
+	if err != nil {
+		return err
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	return core.FastSync(from, signer, force)
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func feeReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "fee-replay",
+		Short:   "Project the profit or loss of the relayer had its recorded deliveries been relayed under given channel fees",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay fee-replay --since 90d --fee ethereum-to-substrate=2000000000 --reward-share 0.8",
+		RunE:    feeReplayFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("since", "30d", "Period to replay, in days such as 30d or as a duration such as 12h")
+	cmd.Flags().StringSlice("fee", nil, "Fee per message of a channel in base units of its source chain's asset, as channel=amount, replacing the configured fee")
+	cmd.Flags().Float64("reward-share", 0, "Share of the fees rewarded to the relayer, replacing the configured share of all channels if set")
+	cmd.Flags().Bool("json", false, "Print the projection of each channel as JSON")
+	return cmd
+}
+
+func feeReplayFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	since, err := cmd.Flags().GetString("since")
+	if err != nil {
+		return err
+	}
+
+	fees, err := cmd.Flags().GetStringSlice("fee")
+	if err != nil {
+		return err
+	}
+
+	share, err := cmd.Flags().GetFloat64("reward-share")
+	if err != nil {
+		return err
+	}
+
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]core.IncentiveConfig)
+	for _, fee := range fees {
+		parts := strings.SplitN(fee, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("fee %q is not of the form channel=amount", fee)
+		}
+		overrides[parts[0]] = core.IncentiveConfig{Fee: parts[1]}
+	}
+
+	replay, err := core.NewFeeReplay(overrides, share)
+	if err != nil {
+		return err
+	}
+
+	days, err := client.Stats("", since)
+	if err != nil {
+		return err
+	}
+
+	projections, err := replay.Project(context.Background(), days)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(projections)
+	}
+
+	for _, projection := range projections {
+		fmt.Printf("%-21s %6d messages over %d days  cost %s  revenue %s\n",
+			projection.Channel, projection.Messages, projection.Days, projection.Cost, projection.Revenue)
+		if projection.Currency != "" {
+			fmt.Printf("%-21s cost %s %s  revenue %s %s  profit %s %s  break-even fee %s %s\n", "",
+				projection.CostValue, projection.Currency, projection.RevenueValue, projection.Currency,
+				projection.Profit, projection.Currency, projection.BreakEvenFee, projection.Currency)
+		}
+	}
+	if len(projections) == 0 {
+		fmt.Println("no channel has fee parameters, configure them in the incentives section or with --fee")
+	}
+
+	return nil
+}
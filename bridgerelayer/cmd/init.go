@@ -0,0 +1,215 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+// apps prompted for when none are given as flags
+var defaultApps = []string{"eth", "erc20"}
+
+func initCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "init",
+		Short:   "Probe the chains and generate a config file for a new relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay init --ethereum ws://localhost:7545/ --substrate ws://127.0.0.1:9944/ --app eth=0x0d27...",
+		RunE:    InitFn,
+	}
+	cmd.Flags().String("ethereum", "", "Ethereum RPC endpoint")
+	cmd.Flags().String("substrate", "", "Substrate RPC endpoint")
+	cmd.Flags().StringArray("app", nil, "App contract as name=address, can be repeated")
+	cmd.Flags().String("abi-dir", "~/.config/artemis-relay/ethereum", "Directory holding the <name>.json ABI of each app")
+	cmd.Flags().String("output", "~/.config/artemis-relay/config.toml", "Path of the generated config file")
+	cmd.Flags().Bool("force", false, "Overwrite an existing config file")
+	cmd.Flags().Bool("non-interactive", false, "Fail instead of prompting for missing settings")
+	return cmd
+}
+
+func InitFn(cmd *cobra.Command, _ []string) error {
+	flags := cmd.Flags()
+
+	interactive, err := flags.GetBool("non-interactive")
+	if err != nil {
+		return err
+	}
+	interactive = !interactive
+	prompt := newPrompter(interactive)
+
+	var bs core.Bootstrap
+
+	bs.EthEndpoint, err = flags.GetString("ethereum")
+	if err != nil {
+		return err
+	}
+	bs.EthEndpoint, err = prompt.Ask("Ethereum RPC endpoint", bs.EthEndpoint, "ws://localhost:7545/")
+	if err != nil {
+		return err
+	}
+
+	bs.SubEndpoint, err = flags.GetString("substrate")
+	if err != nil {
+		return err
+	}
+	bs.SubEndpoint, err = prompt.Ask("Substrate RPC endpoint", bs.SubEndpoint, "ws://127.0.0.1:9944/")
+	if err != nil {
+		return err
+	}
+
+	abiDir, err := flags.GetString("abi-dir")
+	if err != nil {
+		return err
+	}
+
+	appFlags, err := flags.GetStringArray("app")
+	if err != nil {
+		return err
+	}
+
+	addresses := make(map[string]string)
+	for _, value := range appFlags {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid app %q, expected name=address", value)
+		}
+		addresses[parts[0]] = parts[1]
+	}
+	if len(addresses) == 0 && interactive {
+		for _, name := range defaultApps {
+			address, err := prompt.Ask(fmt.Sprintf("Contract address of the %s app (empty to skip)", name), "", "")
+			if err != nil {
+				return err
+			}
+			if address != "" {
+				addresses[name] = address
+			}
+		}
+	}
+
+	bs.Apps = make(map[string]ethereum.Application)
+	for name, address := range addresses {
+		bs.Apps[name] = ethereum.Application{
+			Address: address,
+			AbiPath: path.Join(abiDir, name+".json"),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = bs.Probe(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Ethereum:  chain ID %s, at block %d\n", bs.Eth.ChainID, bs.Eth.BlockNumber)
+	fmt.Printf("Substrate: %s (%s v%d)\n", bs.Sub.Chain, bs.Sub.SpecName, bs.Sub.SpecVersion)
+	if bs.Sub.Properties.SS58Format != nil {
+		fmt.Printf("           SS58 prefix %d\n", *bs.Sub.Properties.SS58Format)
+	}
+	for i, decimals := range bs.Sub.Properties.TokenDecimals {
+		symbol := "?"
+		if i < len(bs.Sub.Properties.TokenSymbols) {
+			symbol = bs.Sub.Properties.TokenSymbols[i]
+		}
+		fmt.Printf("           token %s with %d decimals\n", symbol, decimals)
+	}
+	for _, name := range bs.AppNames() {
+		fmt.Printf("Verified %s app at %s\n", name, bs.Apps[name].Address)
+	}
+
+	data, err := bs.Render()
+	if err != nil {
+		return err
+	}
+
+	return writeConfig(cmd, data)
+}
+
+func writeConfig(cmd *cobra.Command, data []byte) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	output, err = homedir.Expand(output)
+	if err != nil {
+		return err
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(output, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s\n", output)
+	return nil
+}
+
+// prompter asks for settings which were not given as flags
+type prompter struct {
+	interactive bool
+	reader      *bufio.Reader
+}
+
+func newPrompter(interactive bool) *prompter {
+	return &prompter{interactive: interactive, reader: bufio.NewReader(os.Stdin)}
+}
+
+// Ask returns value if set, and otherwise prompts for it, falling back to a default
+func (pr *prompter) Ask(question string, value string, fallback string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if !pr.interactive {
+		if fallback == "" {
+			return "", fmt.Errorf("missing setting: %s", question)
+		}
+		return fallback, nil
+	}
+
+	if fallback != "" {
+		fmt.Printf("%s [%s]: ", question, fallback)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	line, err := pr.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return fallback, nil
+	}
+	return line, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func journalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "journal",
+		Short: "Inspect the journal of a stopped relay, and rebuild the state derived from it",
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "Print the events of the journal as lines of JSON, in order",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay journal list --from 1200",
+		RunE:    listJournalFn,
+	}
+	list.Flags().Uint64("from", 0, "Sequence number of the first event printed")
+
+	rebuild := &cobra.Command{
+		Use:     "rebuild",
+		Short:   "Regenerate state from the journal, replacing the state in the store",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay journal rebuild --projection messages,stats",
+		RunE:    rebuildJournalFn,
+	}
+	rebuild.Flags().StringSlice("projection", core.Projections, "Projections to rebuild")
+
+	cmd.AddCommand(list, rebuild)
+	return cmd
+}
+
+func listJournalFn(cmd *cobra.Command, _ []string) error {
+	from, err := cmd.Flags().GetUint64("from")
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	return core.ReadJournal(from, func(event *store.StateEvent) error {
+		return encoder.Encode(event)
+	})
+}
+
+func rebuildJournalFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+
+	projections, err := cmd.Flags().GetStringSlice("projection")
+	if err != nil {
+		return err
+	}
+
+	replayed, err := core.RebuildProjections(projections)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rebuilt %d projections from %d events\n", len(projections), replayed)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func keysCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Create encrypted keystores for the writer keys",
+	}
+	cmd.PersistentFlags().String("output", "", "Path of the keystore file")
+	cmd.PersistentFlags().String("passphrase-file", "", "File holding the passphrase, read if ARTEMIS_KEYSTORE_PASSPHRASE is not set")
+	cmd.PersistentFlags().Bool("force", false, "Overwrite an existing keystore file")
+	_ = cmd.MarkPersistentFlagRequired("output")
+
+	generate := &cobra.Command{
+		Use:     "generate <chain>",
+		Short:   "Generate a new key for the writer of ethereum or substrate into a keystore",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay keys generate ethereum --output ~/.config/artemis-relay/ethereum.json",
+		RunE:    generateKeyFn,
+	}
+
+	importKey := &cobra.Command{
+		Use:     "import <chain>",
+		Short:   "Import the key in ARTEMIS_ETHEREUM_KEY or ARTEMIS_SUBSTRATE_KEY, or prompted for, into a keystore",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay keys import substrate --output ~/.config/artemis-relay/substrate.json",
+		RunE:    importKeyFn,
+	}
+
+	cmd.AddCommand(generate, importKey)
+	return cmd
+}
+
+func generateKeyFn(cmd *cobra.Command, args []string) error {
+	return writeKeystore(cmd, args[0], "")
+}
+
+func importKeyFn(cmd *cobra.Command, args []string) error {
+	variable := "ARTEMIS_" + strings.ToUpper(args[0]) + "_KEY"
+	key, ok := os.LookupEnv(variable)
+	if !ok {
+		var err error
+		key, err = core.ReadSecret(fmt.Sprintf("Key of %s: ", args[0]))
+		if err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("no key to import")
+	}
+	return writeKeystore(cmd, args[0], key)
+}
+
+// writeKeystore encrypts a key into the output file, generating one if key is empty
+func writeKeystore(cmd *cobra.Command, name string, key string) error {
+	flags := cmd.Flags()
+
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	output, err = homedir.Expand(output)
+	if err != nil {
+		return err
+	}
+
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	passphraseFile, err := flags.GetString("passphrase-file")
+	if err != nil {
+		return err
+	}
+	passphrase, err := newPassphrase(passphraseFile)
+	if err != nil {
+		return err
+	}
+
+	data, address, err := core.NewKeystore(name, key, passphrase)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0700)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(output, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote keystore of %s to %s\n", address, output)
+	return nil
+}
+
+// newPassphrase returns the passphrase of a new keystore, prompting for it twice if
+// it isn't set
+func newPassphrase(file string) (string, error) {
+	if _, ok := os.LookupEnv(core.PassphraseVariable); ok || file != "" {
+		passphrase, _, err := core.KeystorePassphrase(os.LookupEnv, file, false, "")
+		return passphrase, err
+	}
+
+	passphrase, err := core.ReadSecret("Passphrase of the keystore: ")
+	if err != nil {
+		return "", err
+	}
+	repeated, err := core.ReadSecret("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func messagesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "messages",
+		Short: "Inspect and annotate messages known to a running relay",
+	}
+	cmd.PersistentFlags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List messages",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay messages list --label investigating",
+		RunE:    listMessagesFn,
+	}
+	list.Flags().String("label", "", "Only list messages carrying this annotation label")
+	list.Flags().String("tag", "", "Only list messages carrying this tag, such as watch:treasury")
+
+	show := &cobra.Command{
+		Use:     "show <message-id>",
+		Short:   "Show a message and its annotations",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay messages show 6f1c...",
+		RunE:    showMessageFn,
+	}
+
+	annotate := &cobra.Command{
+		Use:     "annotate <message-id>",
+		Short:   "Attach a note and status label to a message",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay messages annotate 6f1c... --label investigating --note \"ticket #123\"",
+		RunE:    annotateMessageFn,
+	}
+	annotate.Flags().String("label", "", "One of investigating, refunded, known-issue, resolved")
+	annotate.Flags().String("note", "", "Free-form note")
+	annotate.Flags().String("author", "", "Author of the annotation (defaults to the current user)")
+	_ = annotate.MarkFlagRequired("label")
+
+	boost := &cobra.Command{
+		Use:     "boost <message-id>",
+		Short:   "Escalate the submission of a queued message, and of those ahead of it in ordered apps",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay messages boost 6f1c...",
+		RunE:    boostMessageFn,
+	}
+
+	cmd.AddCommand(list, show, annotate, boost)
+	return cmd
+}
+
+func apiClient(cmd *cobra.Command) (*api.Client, error) {
+	endpoint, err := cmd.Flags().GetString("api")
+	if err != nil {
+		return nil, err
+	}
+	return api.NewClient(endpoint), nil
+}
+
+func listMessagesFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	label, err := cmd.Flags().GetString("label")
+	if err != nil {
+		return err
+	}
+
+	tag, err := cmd.Flags().GetString("tag")
+	if err != nil {
+		return err
+	}
+
+	records, err := client.ListMessages(label, tag)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		fmt.Printf("%8d %s %-10s %-9s %d annotations\n", record.Sequence, record.ID, record.Source, record.Status, len(record.Annotations))
+	}
+
+	return nil
+}
+
+func showMessageFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	record, err := client.GetMessage(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printJSON(record)
+}
+
+func annotateMessageFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	var annotation store.Annotation
+	annotation.Label, err = cmd.Flags().GetString("label")
+	if err != nil {
+		return err
+	}
+	annotation.Note, err = cmd.Flags().GetString("note")
+	if err != nil {
+		return err
+	}
+	annotation.Author, err = cmd.Flags().GetString("author")
+	if err != nil {
+		return err
+	}
+	if annotation.Author == "" {
+		if current, err := user.Current(); err == nil {
+			annotation.Author = current.Username
+		}
+	}
+
+	record, err := client.Annotate(args[0], annotation)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(record)
+}
+
+func boostMessageFn(cmd *cobra.Command, args []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	boost, err := client.Boost(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printJSON(boost)
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func migrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "migrate-payloads",
+		Short:   "Re-encode the payloads of pending messages for an upgraded relay, which must be stopped",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay migrate-payloads --dry-run",
+		RunE:    MigrateFn,
+	}
+	cmd.Flags().Bool("dry-run", false, "Only report the changes")
+	return cmd
+}
+
+func MigrateFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	changes, err := core.MigrateStore(dryRun)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, change := range changes {
+		fmt.Printf("- %s %8d %-9s %-11s schema %d payload %s\n", change.ID, change.Sequence, change.Source, change.Status, change.From, change.Payload)
+		if change.Error != "" {
+			fmt.Printf("! %s\n", change.Error)
+			failed++
+			continue
+		}
+		fmt.Printf("+ %s %8d %-9s %-11s schema %d payload %s\n", change.NewID, change.Sequence, change.Source, change.Status, change.To, change.NewPayload)
+		if change.Queue != "" {
+			fmt.Printf("  outbox entry of %s moved to the new ID\n", change.Queue)
+		}
+	}
+
+	migrated := len(changes) - failed
+	if dryRun {
+		fmt.Printf("%d messages to migrate, %d failing\n", migrated, failed)
+	} else {
+		fmt.Printf("%d messages migrated, %d failed\n", migrated, failed)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d messages could not be migrated", failed)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
+)
+
+func promoteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "promote",
+		Short:   "Restore the replicated store of the primary so that this standby can take over",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay promote",
+		RunE:    PromoteFn,
+	}
+	cmd.Flags().Bool("force", false, "Overwrite records in a non-empty local store")
+	return cmd
+}
+
+func PromoteFn(cmd *cobra.Command, _ []string) error {
+	setupLogging()
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	return core.Promote(force)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func proofCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "proof",
+		Short:   "Generate the message which a running relay submits for an event",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay proof --chain ethereum --block 1200 --index 3",
+		RunE:    proofFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("chain", "", "Chain which emitted the event (ethereum or substrate)")
+	cmd.Flags().Uint64("block", 0, "Number of the block which contains the event")
+	cmd.Flags().Uint64("index", 0, "Index of the event in the block, the log index on Ethereum")
+	return cmd
+}
+
+func proofFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	chain, err := cmd.Flags().GetString("chain")
+	if err != nil {
+		return err
+	}
+	if chain == "" {
+		return fmt.Errorf("--chain is required")
+	}
+
+	block, err := cmd.Flags().GetUint64("block")
+	if err != nil {
+		return err
+	}
+
+	index, err := cmd.Flags().GetUint64("index")
+	if err != nil {
+		return err
+	}
+
+	proof, err := client.Proof(chain, block, index)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(proof)
+}
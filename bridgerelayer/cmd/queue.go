@@ -0,0 +1,58 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func queueCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect and escalate the messages pending submission in a running relay",
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Short:   "List the messages queued and in flight to each app of each chain",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay queue list",
+		RunE:    listQueuesFn,
+	}
+	list.Flags().String("status-api", "http://127.0.0.1:8082", "Status feed endpoint of the relay")
+
+	boost := &cobra.Command{
+		Use:     "boost <message-id>",
+		Short:   "Escalate the submission of a queued message, and of those ahead of it in ordered apps",
+		Args:    cobra.ExactArgs(1),
+		Example: "artemis-relay queue boost 6f1c...",
+		RunE:    boostMessageFn,
+	}
+	boost.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+
+	cmd.AddCommand(list, boost)
+	return cmd
+}
+
+func listQueuesFn(cmd *cobra.Command, _ []string) error {
+	client, err := statusClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range status.Chains {
+		for _, queue := range ch.Queues {
+			fmt.Printf("%-10s %-20s %6d queued  %6d in flight\n", ch.Name, queue.App, queue.Queued, queue.InFlight)
+		}
+	}
+
+	return nil
+}
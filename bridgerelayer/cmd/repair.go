@@ -0,0 +1,82 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func repairCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "repair",
+		Short:   "Reprocess blocks which were skipped by a running relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay repair --chain substrate",
+		RunE:    RepairFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("chain", "", "Chain whose skipped blocks are reprocessed (ethereum or substrate)")
+	cmd.Flags().Bool("dry-run", false, "Only list the skipped blocks")
+	return cmd
+}
+
+func RepairFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	chain, err := cmd.Flags().GetString("chain")
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		holes, err := client.Holes()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(holes))
+		for name := range holes {
+			if chain == "" || strings.EqualFold(name, chain) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, hole := range holes[name] {
+				fmt.Printf("%s %d-%d (%d blocks)\n", name, hole.Start, hole.End, hole.Len())
+			}
+		}
+		return nil
+	}
+
+	if chain == "" {
+		return fmt.Errorf("--chain is required")
+	}
+
+	repaired, err := client.Repair(chain)
+	if err != nil {
+		return err
+	}
+
+	for _, hole := range repaired {
+		fmt.Printf("repaired %d-%d (%d blocks)\n", hole.Start, hole.End, hole.Len())
+	}
+	if len(repaired) == 0 {
+		fmt.Println("no skipped blocks")
+	}
+
+	return nil
+}
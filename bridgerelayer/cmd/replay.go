@@ -0,0 +1,60 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func replayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "replay",
+		Short:   "Re-relay the events of a range of blocks through a running relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay replay --chain substrate --from 1200 --to 0x9a07...",
+		RunE:    ReplayFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("chain", "", "Chain whose blocks are replayed (ethereum or substrate)")
+	cmd.Flags().String("from", "0", "Number or hash of the first block to replay (defaults to the configured start-block)")
+	cmd.Flags().String("to", "0", "Number or hash of the last block to replay (defaults to the configured end-block, or the latest block)")
+	_ = cmd.MarkFlagRequired("chain")
+	return cmd
+}
+
+func ReplayFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	name, err := cmd.Flags().GetString("chain")
+	if err != nil {
+		return err
+	}
+
+	var bounds [2]chain.BlockRef
+	for i, flag := range []string{"from", "to"} {
+		value, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return err
+		}
+		bounds[i], err = chain.ParseBlockRef(value)
+		if err != nil {
+			return fmt.Errorf("--%s: %w", flag, err)
+		}
+	}
+
+	replayed, err := client.Replay(name, bounds[0], bounds[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("replayed %d-%d (%d blocks)\n", replayed.Start, replayed.End, replayed.Len())
+	return nil
+}
@@ -17,6 +17,30 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(runCmd())
+	rootCmd.AddCommand(drillCmd())
+	rootCmd.AddCommand(messagesCmd())
+	rootCmd.AddCommand(deadLettersCmd())
+	rootCmd.AddCommand(diagnosticsCmd())
+	rootCmd.AddCommand(statsCmd())
+	rootCmd.AddCommand(feeReplayCmd())
+	rootCmd.AddCommand(accountingCmd())
+	rootCmd.AddCommand(repairCmd())
+	rootCmd.AddCommand(replayCmd())
+	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(queueCmd())
+	rootCmd.AddCommand(proofCmd())
+	rootCmd.AddCommand(appsCmd())
+	rootCmd.AddCommand(emergencyCmd())
+	rootCmd.AddCommand(promoteCmd())
+	rootCmd.AddCommand(initCmd())
+	rootCmd.AddCommand(fastSyncCmd())
+	rootCmd.AddCommand(migrateCmd())
+	rootCmd.AddCommand(consoleCmd())
+	rootCmd.AddCommand(supportBundleCmd())
+	rootCmd.AddCommand(auditLogCmd())
+	rootCmd.AddCommand(keysCmd())
+	rootCmd.AddCommand(checkCmd())
+	rootCmd.AddCommand(journalCmd())
 }
 
 // Execute adds all child commands to the root command
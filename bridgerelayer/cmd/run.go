@@ -4,11 +4,15 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/core"
 )
 
@@ -17,16 +21,41 @@ func runCmd() *cobra.Command {
 		Use:     "run",
 		Short:   "Start the relay service",
 		Args:    cobra.ExactArgs(0),
-		Example: "artemis-relay run",
+		Example: "artemis-relay run --chains substrate,ethereum",
 		RunE:    RunFn,
 	}
+	cmd.Flags().Bool("explorer", false, "Only run the listeners, message store and APIs, without submitting anything")
+	cmd.Flags().StringSlice("chains", nil, "Only relay the direction from the first chain to the second, such as substrate,ethereum")
 	return cmd
 }
 
-func RunFn(_ *cobra.Command, _ []string) error {
+func RunFn(cmd *cobra.Command, _ []string) error {
 	setupLogging()
 
-	relay, err := core.NewRelay()
+	explorer, err := cmd.Flags().GetBool("explorer")
+	if err != nil {
+		return err
+	}
+
+	chains, err := cmd.Flags().GetStringSlice("chains")
+	if err != nil {
+		return err
+	}
+	var direction string
+	if len(chains) > 0 {
+		direction, err = parseDirection(chains)
+		if err != nil {
+			return err
+		}
+	}
+
+	config, err := core.LoadConfig("", explorer)
+	if err != nil {
+		logrus.WithField("error", err).Error("Failed to initialize relayer")
+		return err
+	}
+
+	relay, err := core.NewRelayWith(config, &core.Options{Explorer: explorer, Direction: direction})
 	if err != nil {
 		logrus.WithField("error", err).Error("Failed to initialize relayer")
 		return err
@@ -37,6 +66,24 @@ func RunFn(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// parseDirection returns the direction relayed from the first of two chains to the second
+func parseDirection(chains []string) (string, error) {
+	if len(chains) != 2 {
+		return "", fmt.Errorf("expected a source and a target chain, got %s", strings.Join(chains, ","))
+	}
+
+	source := strings.TrimSpace(chains[0])
+	target := strings.TrimSpace(chains[1])
+	switch {
+	case strings.EqualFold(source, substrate.Name) && strings.EqualFold(target, ethereum.Name):
+		return core.DirectionToEthereum, nil
+	case strings.EqualFold(source, ethereum.Name) && strings.EqualFold(target, substrate.Name):
+		return core.DirectionToSubstrate, nil
+	default:
+		return "", fmt.Errorf("no direction is relayed from %s to %s", chains[0], chains[1])
+	}
+}
+
 func setupLogging() {
 	logrus.SetLevel(logrus.DebugLevel)
 	// Some of our dependencies such as GSRPC use the stdlib logger. So we need to
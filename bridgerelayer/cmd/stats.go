@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func statsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "stats",
+		Short:   "Report the daily statistics of each channel recorded by a running relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay stats --channel ethereum-to-substrate --since 30d",
+		RunE:    statsFn,
+	}
+	cmd.Flags().String("api", "http://127.0.0.1:8081", "Admin API endpoint of the relay")
+	cmd.Flags().String("channel", "", "Channel to report (ethereum-to-substrate or substrate-to-ethereum), all channels if empty")
+	cmd.Flags().String("since", "30d", "Period to report, in days such as 30d or as a duration such as 12h")
+	cmd.Flags().Bool("json", false, "Print the statistics of each day as JSON")
+	return cmd
+}
+
+func statsFn(cmd *cobra.Command, _ []string) error {
+	client, err := apiClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	channel, err := cmd.Flags().GetString("channel")
+	if err != nil {
+		return err
+	}
+
+	since, err := cmd.Flags().GetString("since")
+	if err != nil {
+		return err
+	}
+
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	days, err := client.Stats(channel, since)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(days)
+	}
+
+	totals := make(map[string]*store.ChannelStats)
+	var channels []string
+	for _, day := range days {
+		printStats(day.Day, day)
+
+		total, ok := totals[day.Channel]
+		if !ok {
+			total = &store.ChannelStats{Channel: day.Channel}
+			totals[day.Channel] = total
+			channels = append(channels, day.Channel)
+		}
+		total.Add(day)
+	}
+
+	sort.Strings(channels)
+	for _, name := range channels {
+		printStats("total", totals[name])
+	}
+	if len(days) == 0 {
+		fmt.Println("no statistics")
+	}
+
+	return nil
+}
+
+func printStats(period string, stats *store.ChannelStats) {
+	tokens := make([]string, 0, len(stats.Volume))
+	for token := range stats.Volume {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	volume := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		volume = append(volume, fmt.Sprintf("%s=%s", token, stats.Volume[token]))
+	}
+
+	fees := stats.Fees
+	if fees == "" {
+		fees = "0"
+	}
+
+	fmt.Printf("%-10s %-21s %6d messages  fees %s  latency %.1fs  volume %s\n",
+		period, stats.Channel, stats.Messages, fees, stats.AverageLatency, strings.Join(volume, " "))
+}
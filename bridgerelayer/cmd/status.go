@@ -0,0 +1,82 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+func statusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Show the health, progress and queues of each chain of a running relay",
+		Args:    cobra.ExactArgs(0),
+		Example: "artemis-relay status --status-api http://relayer:8082",
+		RunE:    statusFn,
+	}
+	cmd.Flags().String("status-api", "http://127.0.0.1:8082", "Status feed endpoint of the relay")
+	cmd.Flags().Bool("json", false, "Print the status feed as JSON")
+	return cmd
+}
+
+// statusClient returns a client of the status feed, which unlike the admin API needs no
+// access to the relay's internals
+func statusClient(cmd *cobra.Command) (*api.Client, error) {
+	endpoint, err := cmd.Flags().GetString("status-api")
+	if err != nil {
+		return nil, err
+	}
+	return api.NewClient(endpoint), nil
+}
+
+func statusFn(cmd *cobra.Command, _ []string) error {
+	client, err := statusClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(status)
+	}
+
+	if status.Relayer != nil {
+		fmt.Printf("relayer %s\n", status.Relayer.ID)
+	}
+	fmt.Printf("%s  sequence %d  updated %s\n", health(status.Healthy, status.Paused), status.Sequence, status.UpdatedAt.Format("2006-01-02T15:04:05Z"))
+	for _, ch := range status.Chains {
+		queued := 0
+		for _, queue := range ch.Queues {
+			queued += queue.Queued + queue.InFlight
+		}
+		fmt.Printf("%-10s %-9s block %-10d processed %-10d lag %-6d %d queued  recorded %d  delivered %d\n",
+			ch.Name, health(ch.Healthy, ch.Paused), ch.LatestBlock, ch.ProcessedBlock, ch.Lag, queued, ch.RecordedSequence, ch.DeliveredSequence)
+	}
+
+	return nil
+}
+
+func health(healthy bool, paused bool) string {
+	switch {
+	case paused:
+		return "paused"
+	case healthy:
+		return "healthy"
+	default:
+		return "unhealthy"
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type AccountingConfig struct {
+	// Whether the daily flow of each token is recorded for the accounting export
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// AccountingRecorder projects the events of the journal into the daily flow of each token.
+// Transfers are taken from the events observed on their source chain, on the day they
+// were appended like the statistics of the channels. The fee of a delivery is attributed to
+// the token of its message on the day the delivery is confirmed, so that fees are only
+// accounted for transfers observed while the journal recorded their message IDs.
+type AccountingRecorder struct {
+	accounting *store.Accounting
+}
+
+func NewAccountingRecorder(accounting *store.Accounting) *AccountingRecorder {
+	return &AccountingRecorder{accounting: accounting}
+}
+
+func (ar *AccountingRecorder) Name() string {
+	return "accounting"
+}
+
+func (ar *AccountingRecorder) Reset() error {
+	return ar.accounting.Reset()
+}
+
+func (ar *AccountingRecorder) Apply(event *store.StateEvent) error {
+	switch {
+	case event.Kind == store.EventConfirmed && event.Receipt != nil:
+		return ar.confirmed(event)
+	case event.Kind == store.EventObserved && event.Event != nil:
+		return ar.observed(event)
+	}
+	return nil
+}
+
+func (ar *AccountingRecorder) observed(event *store.StateEvent) error {
+	observed := event.Event
+	if observed.Name != "Transfer" && !strings.HasSuffix(observed.Name, ".Transfer") {
+		return nil
+	}
+
+	value, ok := field(observed, amountFields).(string)
+	if !ok {
+		return nil
+	}
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil
+	}
+
+	// the transfers of ETH don't name a token
+	token, ok := field(observed, tokenFields).(string)
+	if !ok {
+		token = observed.App
+	}
+
+	var transfer func(flow *store.TokenFlow)
+	switch observed.Chain {
+	case ethereum.Name:
+		transfer = func(flow *store.TokenFlow) { flow.BridgeIn(amount) }
+	case substrate.Name:
+		transfer = func(flow *store.TokenFlow) { flow.BridgeOut(amount) }
+	default:
+		return nil
+	}
+
+	err := ar.accounting.Update(token, event.Time, transfer)
+	if err != nil {
+		return err
+	}
+
+	if event.MessageID == "" {
+		return nil
+	}
+	return ar.accounting.Attribute(event.MessageID, token)
+}
+
+func (ar *AccountingRecorder) confirmed(event *store.StateEvent) error {
+	receipt := event.Receipt
+	if receipt.Fee == "" || event.MessageID == "" {
+		return nil
+	}
+	fee, ok := new(big.Int).SetString(receipt.Fee, 10)
+	if !ok {
+		return nil
+	}
+
+	token, err := ar.accounting.Attributed(event.MessageID)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return ar.accounting.Update(token, event.Time, func(flow *store.TokenFlow) {
+		switch receipt.Chain {
+		case ethereum.Name:
+			flow.PayEthereumFee(fee)
+		case substrate.Name:
+			flow.PaySubstrateFee(fee)
+		}
+	})
+}
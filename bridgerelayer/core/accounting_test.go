@@ -0,0 +1,78 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestAccountingRecorder(t *testing.T) {
+	db := store.NewMemoryDB()
+	accounting := store.NewAccounting(db)
+	recorder := NewAccountingRecorder(accounting)
+	journal := store.NewMessages(db).Journal()
+	journal.Project(recorder)
+	journaled := NewJournalRecorder(journal)
+
+	deposit := &chain.Message{AppID: [20]byte{1}, Payload: []byte{1, 2}}
+	journaled.Observed(&chain.ObservedEvent{
+		Chain:   "Ethereum",
+		App:     "eth",
+		Name:    "Transfer",
+		Fields:  map[string]interface{}{"_amount": "100"},
+		Message: deposit,
+	})
+	journaled.Observed(&chain.ObservedEvent{
+		Chain:  "Substrate",
+		App:    "erc20",
+		Name:   "ERC20.Transfer",
+		Fields: map[string]interface{}{"tokenId": "0x02", "amount": "5"},
+	})
+	journaled.Observed(&chain.ObservedEvent{
+		Chain:  "Substrate",
+		App:    "eth",
+		Name:   "ETH.Transfer",
+		Fields: map[string]interface{}{"amount": "30"},
+	})
+
+	// the fee of the deposit is attributed to its token through the ID of its message
+	id, _, err := store.MessageID("Ethereum", deposit)
+	require.NoError(t, err)
+	receipt := chain.Receipt{Chain: "Substrate", Fee: "12"}
+	receipt.Confirm(7, "0x01")
+	journaled.Confirmed(&chain.Message{ID: id}, &receipt)
+	// deliveries of unknown messages are not accounted for
+	journaled.Confirmed(&chain.Message{ID: "ff"}, &receipt)
+
+	check := func() {
+		flows, err := accounting.List("", time.Now())
+		require.NoError(t, err)
+		require.Len(t, flows, 2)
+
+		assert.Equal(t, "0x02", flows[0].Token)
+		assert.Equal(t, "5", flows[0].BridgedOut)
+		assert.Equal(t, "-5", flows[0].NetFlow)
+
+		assert.Equal(t, "eth", flows[1].Token)
+		assert.Equal(t, "100", flows[1].BridgedIn)
+		assert.Equal(t, "30", flows[1].BridgedOut)
+		assert.Equal(t, "70", flows[1].NetFlow)
+		assert.Equal(t, "12", flows[1].FeesSubstrate)
+		assert.Equal(t, "0", flows[1].FeesEthereum)
+	}
+	check()
+
+	// the flows are regenerated from the journal
+	replayed, err := journal.Rebuild(recorder)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), replayed)
+	check()
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ArchiveConfig struct {
+	// Archiving is disabled if the bucket URL is empty
+	objectstore.Config `mapstructure:",squash"`
+}
+
+const (
+	// archiveQueueSize bounds the memory used by uploads which are still pending
+	archiveQueueSize = 1024
+	archiveAttempts  = 3
+)
+
+// Archiver uploads the raw payload and proof of each relayed message, and the receipts
+// of its delivery, to object storage. Objects are keyed by message ID:
+//
+//	messages/<id[:2]>/<id>/payload.json
+//	messages/<id[:2]>/<id>/receipts/<chain>-<hash>.json
+type Archiver struct {
+	bucket objectstore.Bucket
+	queue  chan archiveObject
+}
+
+type archiveObject struct {
+	key   string
+	value interface{}
+}
+
+// ArchivedMessage is the archived form of a relayed message
+type ArchivedMessage struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	AppID  string `json:"appId"`
+	// Canonical encoding of the payload, from which the ID is hashed
+	Payload string `json:"payload"`
+	// Decoded payload, including the proof data of messages from Ethereum
+	Message interface{} `json:"message"`
+	// Event which generated the message, nil if unknown
+	Provenance *chain.Provenance `json:"provenance,omitempty"`
+	ArchivedAt time.Time         `json:"archivedAt"`
+}
+
+func NewArchiver(config *ArchiveConfig) (*Archiver, error) {
+	bucket, err := objectstore.Open(&config.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{
+		bucket: bucket,
+		queue:  make(chan archiveObject, archiveQueueSize),
+	}, nil
+}
+
+func (ar *Archiver) Start(ctx context.Context, eg *errgroup.Group) {
+	eg.Go(func() error {
+		for {
+			select {
+			case <-ctx.Done():
+				ar.drain()
+				return ctx.Err()
+			case object := <-ar.queue:
+				ar.upload(ctx, object)
+			}
+		}
+	})
+}
+
+// ArchiveMessage queues the payload of a message for archival
+func (ar *Archiver) ArchiveMessage(source string, msg *chain.Message) {
+	if msg.ID == "" {
+		return
+	}
+
+	payload, err := chain.CanonicalPayload(msg.Payload)
+	if err != nil {
+		log.WithError(err).WithField("messageID", msg.ID).Error("Failed to encode message for archival")
+		return
+	}
+
+	ar.enqueue(archiveKey(msg.ID, "payload.json"), &ArchivedMessage{
+		ID:         msg.ID,
+		Source:     source,
+		AppID:      hex.EncodeToString(msg.AppID[:]),
+		Payload:    hex.EncodeToString(payload),
+		Message:    msg.Payload,
+		Provenance: msg.Provenance(),
+		ArchivedAt: time.Now().UTC(),
+	})
+}
+
+// Submitted queues the receipt of a delivered message for archival
+func (ar *Archiver) Submitted(msg *chain.Message, receipt *chain.Receipt) {
+	if msg.ID == "" {
+		return
+	}
+
+	ar.enqueue(archiveKey(msg.ID, fmt.Sprintf("receipts/%s-%s.json", receipt.Chain, receipt.Hash)), receipt)
+}
+
+// Confirmed replaces the archived receipt with its confirmed form
+func (ar *Archiver) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	ar.Submitted(msg, receipt)
+}
+
+func (ar *Archiver) enqueue(key string, value interface{}) {
+	select {
+	case ar.queue <- archiveObject{key: key, value: value}:
+	default:
+		log.WithField("key", key).Error("Archive queue is full, dropping object")
+	}
+}
+
+func (ar *Archiver) upload(ctx context.Context, object archiveObject) {
+	data, err := json.Marshal(object.value)
+	if err != nil {
+		log.WithError(err).WithField("key", object.key).Error("Failed to encode archived object")
+		return
+	}
+
+	for attempt := 1; attempt <= archiveAttempts; attempt++ {
+		err = ar.bucket.Put(ctx, object.key, data)
+		if err == nil {
+			log.WithField("key", object.key).Debug("Archived object")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			attempt = archiveAttempts
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+
+	log.WithError(err).WithField("key", object.key).Error("Failed to archive object")
+}
+
+// drain uploads the objects which are still queued when shutting down
+func (ar *Archiver) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for {
+		select {
+		case object := <-ar.queue:
+			ar.upload(ctx, object)
+		default:
+			return
+		}
+	}
+}
+
+func archiveKey(id string, name string) string {
+	return fmt.Sprintf("messages/%s/%s/%s", id[:2], id, name)
+}
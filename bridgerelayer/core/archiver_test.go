@@ -0,0 +1,54 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+)
+
+func TestArchiver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artemis-relay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := ArchiveConfig{Config: objectstore.Config{URL: "file://" + dir}}
+	archiver, err := NewArchiver(&config)
+	require.NoError(t, err)
+
+	msg := chain.Message{ID: "abcdef", Payload: []byte{1, 2, 3}}
+	archiver.ArchiveMessage("Substrate", &msg)
+	archiver.Submitted(&msg, &chain.Receipt{Chain: "Ethereum", Hash: "0x01"})
+	// messages which were not recorded cannot be archived
+	archiver.ArchiveMessage("Substrate", &chain.Message{Payload: []byte{1}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eg, ctx := errgroup.WithContext(ctx)
+	archiver.Start(ctx, eg)
+	cancel()
+	_ = eg.Wait()
+
+	bucket := objectstore.NewFileBucket(dir)
+
+	data, err := bucket.Get(context.Background(), "messages/ab/abcdef/payload.json")
+	require.NoError(t, err)
+
+	var archived ArchivedMessage
+	require.NoError(t, json.Unmarshal(data, &archived))
+	assert.Equal(t, "Substrate", archived.Source)
+	assert.Equal(t, "010203", archived.Payload)
+
+	_, err = bucket.Get(context.Background(), "messages/ab/abcdef/receipts/Ethereum-0x01.json")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type AttestationConfig struct {
+	// URL to which signed delivery receipts are posted. Attestation is disabled if empty.
+	Endpoint string `mapstructure:"endpoint"`
+	// Timeout in seconds of each request to the attestation service
+	Timeout uint64 `mapstructure:"timeout"`
+}
+
+const (
+	defaultAttestationTimeout = 10
+	// attestationQueueSize bounds the memory used by receipts which are still pending
+	attestationQueueSize = 1024
+	attestationAttempts  = 5
+	// SignatureHeader carries the signature of the request body
+	SignatureHeader = "X-Artemis-Signature"
+)
+
+// Attestation is a receipt of a confirmed delivery, as posted to the attestation service
+type Attestation struct {
+	MessageID string `json:"messageId"`
+	// Version of the scheme by which the message ID was hashed
+	HashVersion int           `json:"hashVersion"`
+	Signer      string        `json:"signer"`
+	Receipt     chain.Receipt `json:"receipt"`
+}
+
+// Attestor posts signed receipts of confirmed deliveries to an off-chain attestation
+// service. The JSON body of each request is signed as an EIP-191 personal message with
+// the relayer's Ethereum key, and the hex-encoded signature sent in SignatureHeader.
+type Attestor struct {
+	endpoint string
+	kp       *secp256k1.Keypair
+	client   *http.Client
+	queue    chan *Attestation
+}
+
+func NewAttestor(config *AttestationConfig, kp *secp256k1.Keypair) *Attestor {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultAttestationTimeout
+	}
+
+	return &Attestor{
+		endpoint: config.Endpoint,
+		kp:       kp,
+		client:   &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		queue:    make(chan *Attestation, attestationQueueSize),
+	}
+}
+
+func (at *Attestor) Start(ctx context.Context, eg *errgroup.Group) {
+	eg.Go(func() error {
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case attestation := <-at.queue:
+				at.post(ctx, attestation)
+			}
+		}
+	})
+}
+
+// Submitted is a no-op, only confirmed deliveries are attested
+func (at *Attestor) Submitted(*chain.Message, *chain.Receipt) {}
+
+// Confirmed queues an attestation of a confirmed delivery
+func (at *Attestor) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	if msg.ID == "" {
+		return
+	}
+
+	attestation := &Attestation{
+		MessageID:   msg.ID,
+		HashVersion: chain.MessageHashVersion,
+		Signer:      at.kp.CommonAddress().Hex(),
+		Receipt:     *receipt,
+	}
+
+	select {
+	case at.queue <- attestation:
+	default:
+		log.WithField("messageID", msg.ID).Error("Attestation queue is full, dropping receipt")
+	}
+}
+
+func (at *Attestor) post(ctx context.Context, attestation *Attestation) {
+	body, err := json.Marshal(attestation)
+	if err != nil {
+		log.WithError(err).WithField("messageID", attestation.MessageID).Error("Failed to encode attestation")
+		return
+	}
+
+	signature, err := Sign(at.kp, body)
+	if err != nil {
+		log.WithError(err).WithField("messageID", attestation.MessageID).Error("Failed to sign attestation")
+		return
+	}
+
+	for attempt := 1; attempt <= attestationAttempts; attempt++ {
+		err = at.send(ctx, body, signature)
+		if err == nil {
+			log.WithField("messageID", attestation.MessageID).Debug("Posted delivery attestation")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(1<<uint(attempt)) * time.Second):
+		}
+	}
+
+	log.WithError(err).WithField("messageID", attestation.MessageID).Error("Failed to post delivery attestation")
+}
+
+func (at *Attestor) send(ctx context.Context, body []byte, signature []byte) error {
+	req, err := http.NewRequest(http.MethodPost, at.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, hexutil.Encode(signature))
+
+	resp, err := at.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("attestation service responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign signs data as an EIP-191 personal message
+func Sign(kp *secp256k1.Keypair, data []byte) ([]byte, error) {
+	signature, err := crypto.Sign(accounts.TextHash(data), kp.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	// use the recovery id format of eth_sign
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// Recover returns the address which signed data with Sign
+func Recover(data []byte, signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(signature))
+	}
+
+	sig := append([]byte{}, signature...)
+	sig[crypto.RecoveryIDOffset] -= 27
+
+	pub, err := crypto.SigToPub(accounts.TextHash(data), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+func TestAttestor(t *testing.T) {
+	kp := secp256k1.Alice()
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	attestor := NewAttestor(&AttestationConfig{Endpoint: server.URL}, kp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	attestor.Start(ctx, eg)
+
+	msg := chain.Message{ID: "abcdef"}
+	receipt := chain.Receipt{Chain: "Ethereum", Hash: "0x01"}
+	receipt.Confirm(42, "0x02")
+
+	// only confirmed deliveries are attested
+	attestor.Submitted(&msg, &receipt)
+	attestor.Confirmed(&msg, &receipt)
+
+	req := <-received
+	body := <-bodies
+
+	var attestation Attestation
+	require.NoError(t, json.Unmarshal(body, &attestation))
+	assert.Equal(t, "abcdef", attestation.MessageID)
+	assert.Equal(t, uint64(42), attestation.Receipt.BlockNumber)
+	assert.Equal(t, kp.CommonAddress().Hex(), attestation.Signer)
+
+	signature, err := hexutil.Decode(req.Header.Get(SignatureHeader))
+	require.NoError(t, err)
+	signature[crypto.RecoveryIDOffset] -= 27
+
+	pub, err := crypto.SigToPub(accounts.TextHash(body), signature)
+	require.NoError(t, err)
+	assert.Equal(t, kp.CommonAddress(), crypto.PubkeyToAddress(*pub))
+}
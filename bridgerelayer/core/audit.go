@@ -0,0 +1,169 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditRecord is an entry of the audit log, either a log entry or an observed event
+type AuditRecord struct {
+	Time    time.Time            `json:"time"`
+	Level   string               `json:"level,omitempty"`
+	Message string               `json:"message,omitempty"`
+	Fields  map[string]string    `json:"fields,omitempty"`
+	Event   *chain.ObservedEvent `json:"event,omitempty"`
+}
+
+// AuditLog keeps the full detail of log entries and observed events, which the privacy
+// settings mask everywhere else, in an append-only file. Each record is a line holding the
+// base64 of its JSON sealed with AES-256-GCM, prefixed by its random nonce, so that the file
+// is only readable with the key. It is both a log hook and an event feed.
+type AuditLog struct {
+	mutex sync.Mutex
+	file  *os.File
+	aead  cipher.AEAD
+}
+
+func NewAuditLog(path string, key string) (*AuditLog, error) {
+	aead, err := auditCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditLog{file: file, aead: aead}, nil
+}
+
+// auditCipher returns the cipher of a hex-encoded AES-256 key
+func auditCipher(key string) (cipher.AEAD, error) {
+	data, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit log key: %w", err)
+	}
+	if len(data) != 32 {
+		return nil, fmt.Errorf("invalid audit log key: expected 32 bytes, got %d", len(data))
+	}
+
+	block, err := aes.NewCipher(data)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (al *AuditLog) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (al *AuditLog) Fire(entry *log.Entry) error {
+	record := AuditRecord{
+		Time:    entry.Time.UTC(),
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+	}
+	if len(entry.Data) > 0 {
+		record.Fields = make(map[string]string, len(entry.Data))
+		for name, value := range entry.Data {
+			record.Fields[name] = fmt.Sprint(value)
+		}
+	}
+	return al.write(&record)
+}
+
+func (al *AuditLog) Observed(event *chain.ObservedEvent) {
+	err := al.write(&AuditRecord{Time: event.ObservedAt.UTC(), Event: event})
+	if err != nil {
+		// logged without the event, whose detail must not reach the regular logs
+		log.WithError(err).Error("Failed to write observed event to audit log")
+	}
+}
+
+func (al *AuditLog) write(record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, al.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+	sealed := al.aead.Seal(nonce, nonce, data, nil)
+
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	_, err = al.file.Write(line)
+	return err
+}
+
+func (al *AuditLog) Close() error {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	return al.file.Close()
+}
+
+// ReadAuditLog decrypts the records of an audit log, calling fn with each in order
+func ReadAuditLog(r io.Reader, key string, fn func(record *AuditRecord) error) error {
+	aead, err := auditCipher(key)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		sealed, err := base64.StdEncoding.DecodeString(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(sealed) < aead.NonceSize() {
+			return fmt.Errorf("line %d: truncated record", line)
+		}
+
+		nonce := sealed[:aead.NonceSize()]
+		data, err := aead.Open(nil, nonce, sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var record AuditRecord
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		err = fn(&record)
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
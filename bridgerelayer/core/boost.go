@@ -0,0 +1,34 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// Boostable is implemented by chains whose writer can escalate the submission of a queued
+// message
+type Boostable interface {
+	Boost(id string) (*chain.Boost, error)
+}
+
+// Boost escalates the submission of a message queued in the writer of its target chain,
+// such as a stuck high-profile transfer. Returns chain.ErrNotQueued if no writer has the
+// message queued.
+func (re *Relay) Boost(id string) (*chain.Boost, error) {
+	for _, ch := range re.chains {
+		boostable, ok := ch.(Boostable)
+		if !ok {
+			continue
+		}
+
+		boost, err := boostable.Boost(id)
+		if err == chain.ErrNotQueued {
+			continue
+		}
+		return boost, err
+	}
+
+	return nil, chain.ErrNotQueued
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+)
+
+// Bootstrap holds what is needed to generate the config of a new relay
+type Bootstrap struct {
+	EthEndpoint string
+	SubEndpoint string
+	Apps        map[string]ethereum.Application
+	// Properties discovered by Probe
+	Eth *ethereum.ChainInfo
+	Sub *substrate.ChainInfo
+}
+
+var appNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Probe connects to both chains to discover their properties and verify the app contracts
+func (bs *Bootstrap) Probe(ctx context.Context) error {
+	for name := range bs.Apps {
+		if !appNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid app name: %s", name)
+		}
+	}
+
+	eth, err := ethereum.Probe(ctx, bs.EthEndpoint, nil, bs.Apps)
+	if err != nil {
+		return fmt.Errorf("ethereum: %w", err)
+	}
+	bs.Eth = eth
+
+	sub, err := substrate.Probe(ctx, bs.SubEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("substrate: %w", err)
+	}
+	bs.Sub = sub
+
+	return nil
+}
+
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  joinValues,
+}).Parse(`# Generated by artemis-relay init
+#
+{{- with .Eth}}
+# Ethereum: chain ID {{.ChainID}}, network ID {{.NetworkID}}, at block {{.BlockNumber}}
+{{- end}}
+{{- with .Sub}}
+# Substrate: {{.Chain}} ({{.SpecName}} v{{.SpecVersion}}), genesis {{.GenesisHash.Hex}}
+{{- with .Properties}}
+#   SS58 prefix {{if .SS58Format}}{{.SS58Format}}{{else}}unset{{end}}, token decimals {{join .TokenDecimals}}, token symbols {{join .TokenSymbols}}
+{{- end}}
+{{- end}}
+#
+# Keys are read from the ARTEMIS_ETHEREUM_KEY and ARTEMIS_SUBSTRATE_KEY environment variables,
+# or from the keystores of a [keystore] section, created with artemis-relay keys.
+
+[ethereum]
+endpoint = {{quote .EthEndpoint}}
+{{range $name := .AppNames}}
+[ethereum.apps.{{$name}}]
+{{- with index $.Apps $name}}
+address = {{quote .Address}}
+abi = {{quote .AbiPath}}
+{{- end}}
+{{end}}
+[substrate]
+endpoint = {{quote .SubEndpoint}}
+`))
+
+// AppNames returns the names of the apps in a stable order
+func (bs *Bootstrap) AppNames() []string {
+	names := make([]string, 0, len(bs.Apps))
+	for name := range bs.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render generates the config file and verifies that it parses back to the same settings
+func (bs *Bootstrap) Render() ([]byte, error) {
+	var buf bytes.Buffer
+	err := configTemplate.Execute(&buf, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated config is invalid: %w", err)
+	}
+
+	if config.Eth.Endpoint != bs.EthEndpoint || config.Sub.Endpoint != bs.SubEndpoint || len(config.Eth.Apps) != len(bs.Apps) {
+		return nil, fmt.Errorf("generated config does not match the provided settings")
+	}
+	for name, app := range bs.Apps {
+		parsed := config.Eth.Apps[strings.ToLower(name)]
+		if parsed.Address != app.Address || parsed.AbiPath != app.AbiPath {
+			return nil, fmt.Errorf("generated config does not match the settings of app %s", name)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ParseConfig parses a TOML config without reading secrets from the environment
+func ParseConfig(data []byte) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("toml")
+
+	err := v.ReadConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = v.Unmarshal(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func joinValues(values interface{}) string {
+	text := strings.Trim(fmt.Sprint(values), "[]")
+	if text == "" {
+		return "unset"
+	}
+	return strings.Replace(text, " ", ", ", -1)
+}
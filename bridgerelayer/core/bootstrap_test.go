@@ -0,0 +1,42 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+)
+
+func TestBootstrap_Render(t *testing.T) {
+	prefix := uint16(42)
+	bs := Bootstrap{
+		EthEndpoint: "ws://localhost:7545/",
+		SubEndpoint: "ws://127.0.0.1:9944/",
+		Apps: map[string]ethereum.Application{
+			"eth":   {Address: "0x0d27b0069241c03575669fed1badcbccdc0dd4d1", AbiPath: "~/.config/artemis-relay/ethereum/ETHApp.json"},
+			"erc20": {Address: "0x3f839e70117c64744930de8567ae7a5363487ca3", AbiPath: "~/.config/artemis-relay/ethereum/ERC20App.json"},
+		},
+		Eth: &ethereum.ChainInfo{ChainID: big.NewInt(344), NetworkID: big.NewInt(344), BlockNumber: 10},
+		Sub: &substrate.ChainInfo{
+			Chain:      "Development",
+			Properties: substrate.Properties{SS58Format: &prefix, TokenDecimals: []uint32{12}},
+		},
+	}
+
+	data, err := bs.Render()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# Ethereum: chain ID 344")
+	assert.Contains(t, string(data), "SS58 prefix 42, token decimals 12, token symbols unset")
+
+	config, err := ParseConfig(data)
+	require.NoError(t, err)
+	assert.Equal(t, bs.SubEndpoint, config.Sub.Endpoint)
+	assert.Equal(t, bs.Apps["erc20"], config.Eth.Apps["erc20"])
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+)
+
+// BundleInfo identifies the build and host which created a support bundle
+type BundleInfo struct {
+	Version   string    `json:"version"`
+	GoVersion string    `json:"goVersion"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SupportBundle collects what is needed to investigate a bug report into a gzipped tar
+// archive: the version, the configuration file with secrets and endpoint credentials
+// redacted, and from the running relay its recent logs, status, queues, halted chains,
+// app rollout, skipped blocks and writer diagnostics. Parts which can't be collected, for
+// example because the relay isn't running, are listed in problems.txt instead of failing
+// the bundle.
+type SupportBundle struct {
+	admin *api.Client
+	// nil if the status feed is neither given nor configured
+	status *api.Client
+	// number of recent log entries to collect, all kept entries if zero
+	logLimit int
+	files    []bundleFile
+	problems []string
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// NewSupportBundle collects a bundle from the relay whose admin API and status feed are
+// served at the given endpoints. The status feed is found from the configuration if its
+// endpoint is empty.
+func NewSupportBundle(adminEndpoint string, statusEndpoint string, logLimit int) *SupportBundle {
+	sb := &SupportBundle{
+		admin:    api.NewClient(adminEndpoint),
+		logLimit: logLimit,
+	}
+
+	sb.add("version.json", &BundleInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CreatedAt: time.Now().UTC(),
+	}, nil)
+
+	config, err := sb.collectConfig()
+	if err != nil {
+		sb.fail("config.json", err)
+	} else if statusEndpoint == "" && config.Status.Address != "" {
+		statusEndpoint = localEndpoint(config.Status.Address)
+	}
+	if statusEndpoint != "" {
+		sb.status = api.NewClient(statusEndpoint)
+	}
+
+	sb.collectRelay()
+	return sb
+}
+
+// Problems returns the parts of the bundle which could not be collected
+func (sb *SupportBundle) Problems() []string {
+	return sb.problems
+}
+
+// collectConfig adds the configuration file as it was read, before secrets are loaded
+// from the environment, and returns the configuration
+func (sb *SupportBundle) collectConfig() (*Config, error) {
+	err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = viper.Unmarshal(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	sb.add("config.json", redactSettings(viper.AllSettings()), nil)
+	return &config, nil
+}
+
+func (sb *SupportBundle) collectRelay() {
+	logs, err := sb.admin.Logs(sb.logLimit)
+	sb.add("logs.json", logs, err)
+
+	halts, err := sb.admin.Halts()
+	sb.add("chains.json", halts, err)
+
+	apps, err := sb.admin.Apps()
+	sb.add("apps.json", apps, err)
+
+	holes, err := sb.admin.Holes()
+	sb.add("holes.json", holes, err)
+
+	diagnostics, err := sb.admin.Diagnostics()
+	sb.add("diagnostics.json", diagnostics, err)
+
+	if sb.status == nil {
+		sb.problems = append(sb.problems, "status.json: the status feed is not configured")
+		return
+	}
+
+	status, err := sb.status.Status()
+	sb.add("status.json", status, err)
+	if err == nil {
+		queues := make(map[string]interface{}, len(status.Chains))
+		for _, chain := range status.Chains {
+			queues[chain.Name] = chain.Queues
+		}
+		sb.add("queues.json", queues, nil)
+	}
+}
+
+// add adds a part of the bundle, or records the error by which it could not be collected
+func (sb *SupportBundle) add(name string, value interface{}, err error) {
+	if err != nil {
+		sb.fail(name, err)
+		return
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		sb.fail(name, err)
+		return
+	}
+	sb.files = append(sb.files, bundleFile{name: name, data: data})
+}
+
+// fail records a part which could not be collected. Errors may quote endpoints, so their
+// URLs are redacted.
+func (sb *SupportBundle) fail(name string, err error) {
+	sb.problems = append(sb.problems, fmt.Sprintf("%s: %s", name, redactText(err.Error())))
+}
+
+// WriteTo writes the bundle as a gzipped tar archive, whose files are within a directory
+// named after the bundle
+func (sb *SupportBundle) WriteTo(w io.Writer, name string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	files := sb.files
+	if len(sb.problems) > 0 {
+		files = append(files, bundleFile{name: "problems.txt", data: []byte(strings.Join(sb.problems, "\n") + "\n")})
+	}
+
+	for _, file := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name + "/" + file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(file.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// localEndpoint returns the endpoint at which a server listening on an address is reached
+// from the same host
+func localEndpoint(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
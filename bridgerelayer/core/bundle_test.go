@@ -0,0 +1,126 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSettings(t *testing.T) {
+	settings := map[string]interface{}{
+		"ethereum": map[string]interface{}{
+			"endpoint":    "wss://mainnet.infura.io/ws/v3/0123456789abcdef",
+			"private-key": "0xdeadbeef",
+			"sponsor-key": "",
+		},
+		"webhooks": []interface{}{
+			map[string]interface{}{"url": "https://hooks.example.com/T000/B000?token=abc", "secret": "hunter2"},
+		},
+		"store": map[string]interface{}{"path": "/var/lib/artemis-relay/db"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"ethereum": map[string]interface{}{
+			"endpoint":    "wss://mainnet.infura.io",
+			"private-key": redacted,
+			"sponsor-key": "",
+		},
+		"webhooks": []interface{}{
+			map[string]interface{}{"url": "https://hooks.example.com", "secret": redacted},
+		},
+		"store": map[string]interface{}{"path": "/var/lib/artemis-relay/db"},
+	}, redactSettings(settings))
+}
+
+func TestLogBuffer(t *testing.T) {
+	logs := NewLogBuffer(&SupportConfig{RecentLogs: 3}, nil)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(logs)
+
+	logger.Info("first")
+	logger.WithError(errors.New("dial wss://node.example.com/key/0123: refused")).Warn("Failed to connect")
+	logger.WithField("private-key", "0xdeadbeef").Info("third")
+	logger.Info("fourth")
+
+	entries := logs.RecentLogs(0)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "Failed to connect", entries[0].Message)
+	assert.Equal(t, "warning", entries[0].Level)
+	assert.Equal(t, "dial wss://node.example.com: refused", entries[0].Fields["error"])
+	assert.Equal(t, redacted, entries[1].Fields["private-key"])
+	assert.Equal(t, "fourth", entries[2].Message)
+
+	entries = logs.RecentLogs(1)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "fourth", entries[0].Message)
+}
+
+func TestSupportBundle(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		_, _ = w.Write([]byte(`[{"time":"2020-10-01T00:00:00Z","level":"info","message":"Started chain"}]`))
+	})
+	mux.HandleFunc("/chains", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"chain":"Ethereum","halted":false}]`))
+	})
+	mux.HandleFunc("/apps", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("/blocks/holes", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"store unavailable"}`, http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":"1601510400000000000-ethereum","chain":"Ethereum","queued":48}]`))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"healthy":true,"chains":[{"name":"Ethereum","queues":[{"app":"eth","queued":2}]}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	bundle := NewSupportBundle(server.URL, server.URL, 10)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.WriteTo(&buf, "bundle"))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+		assert.WithinDuration(t, time.Now(), header.ModTime, time.Minute)
+	}
+
+	for _, name := range []string{"version.json", "logs.json", "chains.json", "apps.json", "diagnostics.json", "status.json", "queues.json", "problems.txt"} {
+		assert.Contains(t, files, "bundle/"+name)
+	}
+	assert.NotContains(t, files, "bundle/holes.json")
+	assert.Contains(t, files["bundle/logs.json"], "Started chain")
+	assert.Contains(t, files["bundle/queues.json"], `"queued": 2`)
+	assert.Contains(t, files["bundle/diagnostics.json"], `"queued": 48`)
+	assert.Contains(t, files["bundle/problems.txt"], "holes.json: ")
+}
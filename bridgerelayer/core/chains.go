@@ -0,0 +1,95 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+)
+
+// ChainConfig configures a further chain, such as a second parachain or an EVM sidechain,
+// of a type registered with the chain package. The messages observed on the chain are
+// routed to its target.
+type ChainConfig struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// Chain to which the messages observed on the chain are routed, ethereum or substrate
+	Target string `mapstructure:"target"`
+	// Settings of the chain, decoded by the factory of its type
+	Settings map[string]interface{} `mapstructure:"config"`
+}
+
+// routedChain is a further chain with the route of the messages it observes
+type routedChain struct {
+	chain     chain.Chain
+	direction string
+	observed  chan chain.Message
+}
+
+// buildChains creates the further chains through the factories of their types. Their
+// messages are routed in the direction of their target.
+func buildChains(configs []ChainConfig, services *chain.Services) ([]routedChain, error) {
+	names := map[string]bool{
+		strings.ToLower(ethereum.Name):  true,
+		strings.ToLower(substrate.Name): true,
+	}
+
+	var chains []routedChain
+	for _, config := range configs {
+		if config.Name == "" || config.Type == "" {
+			return nil, fmt.Errorf("chains need a name and a type")
+		}
+		if names[strings.ToLower(config.Name)] {
+			return nil, fmt.Errorf("chain %s is configured twice", config.Name)
+		}
+		names[strings.ToLower(config.Name)] = true
+
+		var direction string
+		switch strings.ToLower(config.Target) {
+		case "ethereum":
+			direction = DirectionToEthereum
+		case "substrate":
+			direction = DirectionToSubstrate
+		default:
+			return nil, fmt.Errorf("chain %s has unknown target %q, expected ethereum or substrate", config.Name, config.Target)
+		}
+
+		// messages are not routed to further chains yet, so their writers stay idle
+		endpoints := chain.Endpoints{
+			Observed:  make(chan chain.Message, 1),
+			Delivered: make(chan chain.Message, 1),
+		}
+
+		ch, err := chain.Build(config.Type, config.Name, settingsDecoder(config.Settings), endpoints, services)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, routedChain{chain: ch, direction: direction, observed: endpoints.Observed})
+	}
+	return chains, nil
+}
+
+// settingsDecoder decodes the settings of a chain as viper decodes the configuration
+func settingsDecoder(settings map[string]interface{}) chain.ConfigDecoder {
+	return func(config interface{}) error {
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+			),
+			WeaklyTypedInput: true,
+			Result:           config,
+		})
+		if err != nil {
+			return err
+		}
+		return decoder.Decode(settings)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+type sidechainConfig struct {
+	Endpoint     string        `mapstructure:"endpoint"`
+	PollInterval time.Duration `mapstructure:"poll-interval"`
+}
+
+type sidechain struct {
+	name      string
+	config    sidechainConfig
+	endpoints chain.Endpoints
+}
+
+func (sc *sidechain) Name() string                                        { return sc.name }
+func (sc *sidechain) Start(ctx context.Context, eg *errgroup.Group) error { return nil }
+func (sc *sidechain) Stop()                                               {}
+
+func init() {
+	chain.Register("sidechain-test", func(name string, decode chain.ConfigDecoder, endpoints chain.Endpoints, _ *chain.Services) (chain.Chain, error) {
+		sc := &sidechain{name: name, endpoints: endpoints}
+		err := decode(&sc.config)
+		if err != nil {
+			return nil, err
+		}
+		return sc, nil
+	})
+}
+
+func TestBuildChains(t *testing.T) {
+	chains, err := buildChains([]ChainConfig{{
+		Name:   "Sidechain",
+		Type:   "sidechain-test",
+		Target: "substrate",
+		Settings: map[string]interface{}{
+			"endpoint":      "ws://sidechain:8545",
+			"poll-interval": "3s",
+		},
+	}}, &chain.Services{})
+	if !assert.NoError(t, err) || !assert.Len(t, chains, 1) {
+		return
+	}
+
+	assert.Equal(t, "Sidechain", chains[0].chain.Name())
+	assert.Equal(t, DirectionToSubstrate, chains[0].direction)
+
+	sc := chains[0].chain.(*sidechain)
+	assert.Equal(t, sidechainConfig{Endpoint: "ws://sidechain:8545", PollInterval: 3 * time.Second}, sc.config)
+
+	// the listener of the chain sends to the channel which is routed
+	sc.endpoints.Observed <- chain.Message{Sequence: 1}
+	assert.Equal(t, uint64(1), (<-chains[0].observed).Sequence)
+}
+
+func TestBuildChains_Invalid(t *testing.T) {
+	tests := map[string]ChainConfig{
+		"collides":       {Name: "ethereum", Type: "sidechain-test", Target: "substrate"},
+		"unknown type":   {Name: "Sidechain", Type: "unknown", Target: "substrate"},
+		"unknown target": {Name: "Sidechain", Type: "sidechain-test", Target: "Sidechain"},
+		"unnamed":        {Type: "sidechain-test", Target: "ethereum"},
+	}
+	for name, config := range tests {
+		_, err := buildChains([]ChainConfig{config}, &chain.Services{})
+		assert.Error(t, err, name)
+	}
+
+	_, err := buildChains([]ChainConfig{
+		{Name: "Sidechain", Type: "sidechain-test", Target: "ethereum"},
+		{Name: "sidechain", Type: "sidechain-test", Target: "substrate"},
+	}, &chain.Services{})
+	assert.EqualError(t, err, "chain sidechain is configured twice")
+}
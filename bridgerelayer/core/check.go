@@ -0,0 +1,203 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// CheckFinding is a problem found by a check of the relay's deployment
+type CheckFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// CheckReport records the checks run against a deployment and their findings. The
+// deployment passes unless a finding is an error.
+type CheckReport struct {
+	Passed   bool           `json:"passed"`
+	Checks   []string       `json:"checks"`
+	Findings []CheckFinding `json:"findings"`
+}
+
+func (cr *CheckReport) add(check string, severity string, format string, args ...interface{}) {
+	cr.Findings = append(cr.Findings, CheckFinding{
+		Check:    check,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+	if severity == SeverityError {
+		cr.Passed = false
+	}
+}
+
+// Check validates the configuration of a relay and the endpoints it relays between without
+// starting it, nor touching its store or audit log. The checks following one which finds an
+// error are skipped if they depend on it.
+func Check(ctx context.Context, explorer bool) *CheckReport {
+	report := &CheckReport{Passed: true, Checks: []string{}, Findings: []CheckFinding{}}
+
+	config := checkConfig(report, explorer)
+	if config == nil {
+		return report
+	}
+
+	checkRelay(report, config, explorer)
+	checkEthereum(ctx, report, config, explorer)
+	checkSubstrate(ctx, report, config)
+
+	return report
+}
+
+// checkConfig reads the configuration and the keys of the relayer, returning nil if they
+// are invalid. Settings which the relay doesn't know, which are usually misspelt, are
+// reported as warnings.
+func checkConfig(report *CheckReport, explorer bool) *Config {
+	report.Checks = append(report.Checks, "config")
+
+	err := readConfigFile()
+	if err != nil {
+		report.add("config", SeverityError, "read config: %s", err)
+		return nil
+	}
+
+	var metadata mapstructure.Metadata
+	err = viper.Unmarshal(&Config{}, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &metadata
+	})
+	if err != nil {
+		report.add("config", SeverityError, "%s", err)
+		return nil
+	}
+	sort.Strings(metadata.Unused)
+	for _, key := range metadata.Unused {
+		report.add("config", SeverityWarning, "unknown setting %s", key)
+	}
+
+	report.Checks = append(report.Checks, "secrets")
+	config, err := readConfig(explorer)
+	if err != nil {
+		report.add("secrets", SeverityError, "%s", err)
+		return nil
+	}
+	return config
+}
+
+// checkRelay creates the services of the relay, which validates the settings of each, with
+// the store in memory and without the audit log, so that a running relay isn't disturbed
+func checkRelay(report *CheckReport, config *Config, explorer bool) {
+	report.Checks = append(report.Checks, "relay")
+
+	if config.Privacy.AuditLog != "" {
+		_, err := auditCipher(config.Privacy.AuditKey)
+		if err != nil {
+			report.add("relay", SeverityError, "%s", err)
+		}
+	}
+
+	offline := *config
+	offline.Store.Path = ""
+	offline.Store.CursorFile = ""
+	offline.Privacy.AuditLog = ""
+
+	relay, err := buildRelay(&offline, &Options{Explorer: explorer})
+	if err != nil {
+		report.add("relay", SeverityError, "%s", err)
+		return
+	}
+	relay.db.Close()
+	relay.logger.restore()
+}
+
+// checkEthereum probes the Ethereum endpoint, its fallbacks and the contracts of the apps,
+// and verifies that the relayer's account can pay for the transactions of the writer
+func checkEthereum(ctx context.Context, report *CheckReport, config *Config, explorer bool) {
+	report.Checks = append(report.Checks, "ethereum")
+
+	dialer, err := chain.NewDialer(&config.Eth.RPC.Dial)
+	if err != nil {
+		report.add("ethereum", SeverityError, "dial settings: %s", err)
+		return
+	}
+
+	// the relay runs while fallbacks are down, as long as the endpoint is up
+	for _, endpoint := range config.Eth.FallbackEndpoints {
+		_, err := ethereum.Probe(ctx, endpoint, dialer, config.Eth.Apps)
+		if err != nil {
+			report.add("ethereum", SeverityWarning, "probe fallback %s: %s", endpoint, err)
+		}
+	}
+
+	_, err = ethereum.Probe(ctx, config.Eth.Endpoint, dialer, config.Eth.Apps)
+	if err != nil {
+		report.add("ethereum", SeverityError, "probe %s: %s", config.Eth.Endpoint, err)
+		return
+	}
+
+	if explorer {
+		return
+	}
+
+	key, err := ethereum.LoadKeypair(&config.Eth)
+	if err != nil {
+		report.add("ethereum", SeverityError, "relayer key: %s", err)
+		return
+	}
+
+	rpcClient, err := chain.DialRPC(ctx, config.Eth.Endpoint, dialer)
+	if err != nil {
+		report.add("ethereum", SeverityError, "dial %s: %s", config.Eth.Endpoint, err)
+		return
+	}
+	client := ethclient.NewClient(rpcClient)
+	defer client.Close()
+
+	balance, err := client.BalanceAt(ctx, key.CommonAddress(), nil)
+	if err != nil {
+		report.add("ethereum", SeverityError, "balance of relayer account %s: %s", key.Address(), err)
+		return
+	}
+	if balance.Sign() == 0 {
+		report.add("ethereum", SeverityError, "relayer account %s has no funds to pay for transactions", key.Address())
+	}
+}
+
+// checkSubstrate probes the Substrate endpoint and its fallbacks
+func checkSubstrate(ctx context.Context, report *CheckReport, config *Config) {
+	report.Checks = append(report.Checks, "substrate")
+
+	dialer, err := chain.NewDialer(&config.Sub.RPC.Dial)
+	if err != nil {
+		report.add("substrate", SeverityError, "dial settings: %s", err)
+		return
+	}
+
+	for _, endpoint := range config.Sub.FallbackEndpoints {
+		_, err := substrate.Probe(ctx, endpoint, dialer)
+		if err != nil {
+			report.add("substrate", SeverityWarning, "probe fallback %s: %s", endpoint, err)
+		}
+	}
+
+	_, err = substrate.Probe(ctx, config.Sub.Endpoint, dialer)
+	if err != nil {
+		report.add("substrate", SeverityError, "probe %s: %s", config.Sub.Endpoint, err)
+	}
+}
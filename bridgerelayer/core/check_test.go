@@ -0,0 +1,61 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck_Config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "relay.toml")
+	err = ioutil.WriteFile(file, []byte(`
+[ethereum]
+endpint = "ws://localhost:8545"
+
+[privacy]
+mode = "scramble"
+`), 0644)
+	require.NoError(t, err)
+
+	SetConfigFile(path.Join(dir, "missing.toml"))
+	defer SetConfigFile("")
+
+	report := Check(context.Background(), true)
+	assert.False(t, report.Passed)
+	assert.Equal(t, []string{"config"}, report.Checks)
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, SeverityError, report.Findings[0].Severity)
+
+	// the keys of the relayer are required, unless it is an explorer
+	SetConfigFile(file)
+	os.Unsetenv("ARTEMIS_ETHEREUM_KEY")
+	report = Check(context.Background(), false)
+	assert.False(t, report.Passed)
+	assert.Equal(t, []string{"config", "secrets"}, report.Checks)
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, CheckFinding{Check: "config", Severity: SeverityWarning, Message: "unknown setting ethereum.endpint"}, report.Findings[0])
+	assert.Equal(t, CheckFinding{Check: "secrets", Severity: SeverityError, Message: "environment variable not set: ARTEMIS_ETHEREUM_KEY"}, report.Findings[1])
+
+	// invalid settings are found by creating the relay, and the endpoints are probed anyway
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report = Check(ctx, true)
+	assert.False(t, report.Passed)
+	assert.Equal(t, []string{"config", "secrets", "relay", "ethereum", "substrate"}, report.Checks)
+	require.Len(t, report.Findings, 4)
+	assert.Equal(t, CheckFinding{Check: "relay", Severity: SeverityError, Message: `unknown privacy mode "scramble", expected redact or hash`}, report.Findings[1])
+	assert.Equal(t, "ethereum", report.Findings[2].Check)
+	assert.Equal(t, "substrate", report.Findings[3].Check)
+}
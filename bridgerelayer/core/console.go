@@ -0,0 +1,221 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/sr25519"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// consoleAliases are the short names of chains accepted by the console
+var consoleAliases = map[string]string{
+	"eth": "ethereum",
+	"sub": "substrate",
+}
+
+// Console is an interactive shell for querying the chains through the connections and
+// decoders of the relayer, for debugging against test networks. Commands are entered as
+// "<chain> <command> [args...]" and their results printed as JSON.
+type Console struct {
+	// commands of each chain, keyed by lowercase chain name
+	commands map[string][]chain.ConsoleCommand
+	close    func()
+}
+
+func newConsole(commands map[string][]chain.ConsoleCommand) *Console {
+	return &Console{commands: commands, close: func() {}}
+}
+
+// OpenConsole connects to both chains with the relayer's configuration and keys
+func OpenConsole(ctx context.Context) (*Console, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	contracts, err := ethereum.LoadContracts(&config.Eth)
+	if err != nil {
+		return nil, err
+	}
+
+	ethKey, err := ethereum.LoadKeypair(&config.Eth)
+	if err != nil {
+		return nil, err
+	}
+
+	ethDialer, err := chain.NewDialer(&config.Eth.RPC.Dial)
+	if err != nil {
+		return nil, err
+	}
+
+	ethLog := log.WithField("chain", ethereum.Name)
+	ethConn := ethereum.NewConnection(config.Eth.Endpoint, ethKey, nil, ethLog)
+	ethConn.DialThrough(ethDialer)
+	err = ethConn.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ethWriter, err := ethereum.NewWriter(&config.Eth, ethConn, nil, nil, nil, nil, ethLog)
+	if err != nil {
+		ethConn.Close()
+		return nil, err
+	}
+
+	subKey, err := sr25519.NewKeypairFromSeed(config.Sub.PrivateKey, "")
+	if err != nil {
+		ethConn.Close()
+		return nil, err
+	}
+
+	subDialer, err := chain.NewDialer(&config.Sub.RPC.Dial)
+	if err != nil {
+		ethConn.Close()
+		return nil, err
+	}
+
+	subLog := log.WithField("chain", substrate.Name)
+	subConn := substrate.NewConnection(config.Sub.Endpoint, subKey.AsKeyringPair(), &config.Sub.Properties, nil, subLog)
+	subConn.DialThrough(subDialer)
+	err = subConn.Connect(ctx)
+	if err != nil {
+		ethConn.Close()
+		return nil, err
+	}
+
+	subWriter, err := substrate.NewWriter(&config.Sub, subConn, nil, nil, nil, subLog)
+	if err != nil {
+		ethConn.Close()
+		subConn.Close()
+		return nil, err
+	}
+
+	co := newConsole(map[string][]chain.ConsoleCommand{
+		strings.ToLower(ethereum.Name):  ethereum.ConsoleCommands(ethConn, contracts, ethWriter),
+		strings.ToLower(substrate.Name): substrate.ConsoleCommands(&config.Sub, subConn, subWriter),
+	})
+	co.close = func() {
+		ethConn.Close()
+		subConn.Close()
+	}
+	return co, nil
+}
+
+// Close closes the connections of the console
+func (co *Console) Close() {
+	co.close()
+}
+
+// Run executes the commands read from in until it is exhausted or exit is entered,
+// printing a prompt before each command if interactive
+func (co *Console) Run(ctx context.Context, in io.Reader, out io.Writer, interactive bool) error {
+	if interactive {
+		fmt.Fprintln(out, "Enter \"help\" to list commands, \"exit\" to quit")
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(out, "> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+
+		err := co.Exec(ctx, line, out)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Exec executes a single command
+func (co *Console) Exec(ctx context.Context, line string, out io.Writer) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if fields[0] == "help" {
+		co.help(out)
+		return nil
+	}
+
+	name := strings.ToLower(fields[0])
+	if alias, ok := consoleAliases[name]; ok {
+		name = alias
+	}
+
+	commands, ok := co.commands[name]
+	if !ok {
+		return fmt.Errorf("unknown chain %q, enter \"help\" to list commands", fields[0])
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("missing command for chain %s", name)
+	}
+
+	for _, command := range commands {
+		if command.Name != fields[1] {
+			continue
+		}
+
+		args := fields[2:]
+		if len(args) < command.Args {
+			return fmt.Errorf("usage: %s %s", name, command.Usage)
+		}
+
+		result, err := command.Run(ctx, args)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q for chain %s", fields[1], name)
+}
+
+func (co *Console) help(out io.Writer) {
+	names := make([]string, 0, len(co.commands))
+	for name := range co.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(out, "%s:\n", name)
+		for _, command := range co.commands[name] {
+			fmt.Fprintf(out, "  %-45s %s\n", name+" "+command.Usage, command.Help)
+		}
+	}
+}
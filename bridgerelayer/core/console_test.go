@@ -0,0 +1,61 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestConsole(t *testing.T) {
+	var received []string
+	console := newConsole(map[string][]chain.ConsoleCommand{
+		"ethereum": {
+			{
+				Name:  "head",
+				Usage: "head",
+				Help:  "Show the latest block header",
+				Run: func(_ context.Context, _ []string) (interface{}, error) {
+					return map[string]uint64{"number": 42}, nil
+				},
+			},
+			{
+				Name:  "events",
+				Usage: "events <app> <from> [to]",
+				Args:  2,
+				Run: func(_ context.Context, args []string) (interface{}, error) {
+					received = args
+					return nil, fmt.Errorf("no events")
+				},
+			},
+		},
+	})
+
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	assert.NoError(t, console.Exec(ctx, "eth head", &out))
+	assert.JSONEq(t, `{"number": 42}`, out.String())
+
+	assert.EqualError(t, console.Exec(ctx, "ethereum events eth", &out), "usage: ethereum events <app> <from> [to]")
+	assert.EqualError(t, console.Exec(ctx, "Ethereum events eth 10 20", &out), "no events")
+	assert.Equal(t, []string{"eth", "10", "20"}, received)
+
+	assert.Error(t, console.Exec(ctx, "sub head", &out))
+	assert.Error(t, console.Exec(ctx, "eth send", &out))
+
+	out.Reset()
+	input := strings.NewReader("help\n\n# comment\neth send\nexit\neth head\n")
+	assert.NoError(t, console.Run(ctx, input, &out, false))
+	assert.Contains(t, out.String(), "ethereum head")
+	assert.Contains(t, out.String(), `error: unknown command "send" for chain ethereum`)
+	assert.NotContains(t, out.String(), "42")
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DeadLetterQueue holds the messages whose delivery the writers gave up after it failed
+// permanently, such as deliveries which keep reverting, so that they are neither retried
+// forever nor dropped. Operators inspect them with the error of their last attempt, and
+// requeue them once the cause is fixed.
+type DeadLetterQueue struct {
+	deadLetters *store.DeadLetters
+	messages    *store.Messages
+	// nil if messages aren't persisted until their delivery is confirmed
+	outbox *Outbox
+	// channels of the source chains through which requeued messages are routed again, by
+	// the chain to which the messages are delivered
+	sources map[string]chan<- chain.Message
+	stopped <-chan struct{}
+}
+
+func NewDeadLetterQueue(db store.DB, messages *store.Messages, outbox *Outbox, sources map[string]chan<- chain.Message, stopped <-chan struct{}) (*DeadLetterQueue, error) {
+	dq := &DeadLetterQueue{
+		deadLetters: store.NewDeadLetters(db),
+		messages:    messages,
+		outbox:      outbox,
+		sources:     sources,
+		stopped:     stopped,
+	}
+
+	err := dq.count()
+	if err != nil {
+		return nil, err
+	}
+	return dq, nil
+}
+
+// DeadLetter moves a message whose delivery failed to the queue. The message is
+// acknowledged in the outbox, so that it isn't redelivered on restart.
+func (dq *DeadLetterQueue) DeadLetter(target string, msg *chain.Message, failure error, attempts int) error {
+	_, err := dq.deadLetters.Add(target, msg, failure.Error(), attempts)
+	if err != nil {
+		return err
+	}
+
+	if dq.outbox != nil {
+		dq.outbox.ack(msg)
+	}
+
+	err = dq.messages.SetStatus(msg.ID, store.StatusDeadLettered, fmt.Sprintf("delivery to %s failed after %d attempts", target, attempts))
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	return dq.count()
+}
+
+// List returns the queued messages in the order in which their delivery failed
+func (dq *DeadLetterQueue) List() ([]*store.DeadLetter, error) {
+	return dq.deadLetters.List()
+}
+
+// Get returns a queued message
+func (dq *DeadLetterQueue) Get(id string) (*store.DeadLetter, error) {
+	return dq.deadLetters.Get(id)
+}
+
+// Requeue routes a queued message again, as a replayed message so that it isn't suppressed
+// as a duplicate, and removes it from the queue
+func (dq *DeadLetterQueue) Requeue(ctx context.Context, id string) (*store.DeadLetter, error) {
+	letter, err := dq.deadLetters.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	source, ok := dq.sources[letter.Target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target chain %s", letter.Target)
+	}
+
+	msg, err := letter.Decode()
+	if err != nil {
+		return nil, err
+	}
+	msg.Replayed = true
+	msg.ObservedAt = time.Now()
+
+	select {
+	case source <- *msg:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-dq.stopped:
+		return nil, chain.ErrConsumerStopped
+	}
+
+	err = dq.deadLetters.Remove(id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"messageID": id,
+		"target":    letter.Target,
+	}).Info("Requeued message from the dead-letter queue")
+
+	return letter, dq.count()
+}
+
+// count exports the number of queued messages of each target chain
+func (dq *DeadLetterQueue) count() error {
+	letters, err := dq.deadLetters.List()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for target := range dq.sources {
+		counts[target] = 0
+	}
+	for _, letter := range letters {
+		counts[letter.Target]++
+	}
+	for target, count := range counts {
+		metrics.DeadLetters.WithLabelValues(target).Set(float64(count))
+	}
+	return nil
+}
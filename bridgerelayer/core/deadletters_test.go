@@ -0,0 +1,76 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	outbox := NewOutbox(&store.OutboxConfig{}, db, messages)
+	fromSubstrate := make(chan chain.Message, 1)
+	sources := map[string]chan<- chain.Message{ethereum.Name: fromSubstrate}
+
+	queue, err := NewDeadLetterQueue(db, messages, outbox, sources, nil)
+	require.NoError(t, err)
+
+	unlock := chain.Message{AppID: [20]byte{1}, Payload: []byte{1, 2, 3}, SourceBlock: 1200}
+	record, err := messages.Record(substrate.Name, &unlock, store.StatusRouted)
+	require.NoError(t, err)
+	unlock.ID = record.ID
+	unlock.Sequence = record.Sequence
+	require.NoError(t, outbox.Add(DirectionToEthereum, &unlock))
+
+	// messages which were not recorded can't be queued
+	assert.Error(t, queue.DeadLetter(ethereum.Name, &chain.Message{Payload: []byte{4}}, fmt.Errorf("reverted"), 1))
+
+	require.NoError(t, queue.DeadLetter(ethereum.Name, &unlock, fmt.Errorf("reverted: invalid proof"), 3))
+
+	letters, err := queue.List()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, unlock.ID, letters[0].ID)
+	assert.Equal(t, ethereum.Name, letters[0].Target)
+	assert.Equal(t, "010203", letters[0].Payload)
+	assert.Equal(t, uint64(1200), letters[0].SourceBlock)
+	assert.Equal(t, "reverted: invalid proof", letters[0].Error)
+	assert.Equal(t, 3, letters[0].Attempts)
+
+	// dead-lettered messages are neither pending delivery nor redelivered on restart
+	record, err = messages.Get(unlock.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusDeadLettered, record.Status)
+	redelivered, err := outbox.Redeliver(DirectionToEthereum)
+	require.NoError(t, err)
+	assert.Empty(t, redelivered)
+
+	// requeued messages are routed again as replayed messages
+	letter, err := queue.Requeue(context.Background(), unlock.ID)
+	require.NoError(t, err)
+	assert.Equal(t, unlock.ID, letter.ID)
+
+	requeued := <-fromSubstrate
+	assert.True(t, requeued.Replayed)
+	assert.Equal(t, unlock.ID, requeued.ID)
+	assert.Equal(t, unlock.Payload, requeued.Payload)
+	assert.Equal(t, unlock.SourceBlock, requeued.SourceBlock)
+
+	_, err = queue.Get(unlock.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	_, err = queue.Requeue(context.Background(), unlock.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+}
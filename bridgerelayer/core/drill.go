@@ -0,0 +1,187 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DrillScenarios lists the supported failover drills
+var DrillScenarios = []string{"drop-rpc", "stall-writer", "bad-payload"}
+
+// DrillResult records the outcome of a single failover drill
+type DrillResult struct {
+	Scenario string        `json:"scenario"`
+	Expected string        `json:"expected"`
+	Observed string        `json:"observed"`
+	Passed   bool          `json:"passed"`
+	Errors   int           `json:"errors"`
+	Duration time.Duration `json:"duration"`
+}
+
+type gated interface {
+	WriterGate() *chain.Gate
+}
+
+// RunDrill starts a relay against the configured (test) networks, deliberately
+// injects a failure and observes how the relay recovers within the grace period
+func RunDrill(scenario string, grace time.Duration) (*DrillResult, error) {
+	relay, err := NewRelay()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &errorCounter{}
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	log.AddHook(hook)
+	defer log.StandardLogger().ReplaceHooks(hooks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+
+	err = relay.start(ctx, eg)
+	if err != nil {
+		relay.stop()
+		return nil, err
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		failed <- eg.Wait()
+	}()
+
+	log.WithField("scenario", scenario).Info("Starting drill")
+
+	result := DrillResult{Scenario: scenario}
+	start := time.Now()
+
+	switch scenario {
+	case "drop-rpc":
+		relay.drillDropRPC(&result, failed, grace)
+	case "stall-writer":
+		relay.drillStallWriter(&result, failed, grace)
+	case "bad-payload":
+		relay.drillBadPayload(&result, failed, grace)
+	default:
+		return nil, fmt.Errorf("unknown drill scenario: %s", scenario)
+	}
+
+	result.Duration = time.Since(start)
+	result.Errors = hook.count()
+
+	cancel()
+	<-failed
+	relay.stop()
+
+	return &result, nil
+}
+
+// drillDropRPC closes the RPC connections of all chains. Since the relay cannot
+// reconnect by itself, it is expected to fail fast so that a supervisor restarts it.
+func (re *Relay) drillDropRPC(result *DrillResult, failed <-chan error, grace time.Duration) {
+	result.Expected = "relay exits with an unrecoverable error so that it can be restarted"
+
+	for _, chain := range re.chains {
+		chain.Stop()
+	}
+
+	select {
+	case err := <-failed:
+		result.Passed = err != nil && !errors.Is(err, context.Canceled)
+		result.Observed = fmt.Sprintf("relay exited: %v", err)
+	case <-time.After(grace):
+		result.Observed = "relay kept running without a connection"
+	}
+}
+
+// drillStallWriter halts all writers for half of the grace period and then resumes them
+func (re *Relay) drillStallWriter(result *DrillResult, failed <-chan error, grace time.Duration) {
+	result.Expected = "relay stays up while writers are stalled and resumes delivery"
+
+	for _, ch := range re.chains {
+		if g, ok := ch.(gated); ok {
+			g.WriterGate().Halt("drill")
+		}
+	}
+
+	select {
+	case err := <-failed:
+		result.Observed = fmt.Sprintf("relay exited while writers were stalled: %v", err)
+		return
+	case <-time.After(grace / 2):
+	}
+
+	queued := len(re.toEthereum) + len(re.toSubstrate)
+
+	for _, ch := range re.chains {
+		if g, ok := ch.(gated); ok {
+			g.WriterGate().Release("drill")
+		}
+	}
+
+	select {
+	case err := <-failed:
+		result.Observed = fmt.Sprintf("relay exited after writers were resumed: %v", err)
+	case <-time.After(grace / 2):
+		result.Passed = true
+		result.Observed = fmt.Sprintf("relay stayed up, %d messages were queued during the stall", queued)
+	}
+}
+
+// drillBadPayload hands a malformed message to the writer of each chain
+func (re *Relay) drillBadPayload(result *DrillResult, failed <-chan error, grace time.Duration) {
+	result.Expected = "writers reject malformed messages and keep running"
+
+	bad := chain.Message{Payload: []byte{0xff, 0xff, 0xff}}
+	for _, messages := range []chan chain.Message{re.toEthereum, re.toSubstrate} {
+		select {
+		case messages <- bad:
+		case err := <-failed:
+			result.Observed = fmt.Sprintf("relay exited before the payload was injected: %v", err)
+			return
+		}
+	}
+
+	select {
+	case err := <-failed:
+		result.Observed = fmt.Sprintf("relay exited: %v", err)
+	case <-time.After(grace):
+		result.Passed = true
+		result.Observed = "relay stayed up after malformed messages were submitted"
+	}
+}
+
+// errorCounter is a logrus hook counting the errors logged during a drill
+type errorCounter struct {
+	mutex  sync.Mutex
+	errors int
+}
+
+func (ec *errorCounter) Levels() []log.Level {
+	return []log.Level{log.ErrorLevel, log.FatalLevel, log.PanicLevel}
+}
+
+func (ec *errorCounter) Fire(_ *log.Entry) error {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	ec.errors++
+	return nil
+}
+
+func (ec *errorCounter) count() int {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	return ec.errors
+}
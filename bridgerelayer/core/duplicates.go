@@ -0,0 +1,167 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type DuplicateConfig struct {
+	// Seconds for which a message of an app without nonces is suppressed if it is seen
+	// again with the same hash. Zero disables suppression for these apps.
+	Window uint64 `mapstructure:"window"`
+	// Days for which the IDs of routed events are kept, so that an event observed again
+	// isn't routed twice. Zero keeps them without limit.
+	EventRetention uint64 `mapstructure:"event-retention"`
+}
+
+const (
+	// RetentionPermanent suppresses every message already routed, for apps with nonces
+	RetentionPermanent = "permanent"
+	// RetentionWindow suppresses messages routed within the duplicate window
+	RetentionWindow = "window"
+	// RetentionEvent suppresses messages of events already routed, identified by their block
+	// hash and event index
+	RetentionEvent = "event"
+)
+
+// eventPruneInterval is the interval at which event IDs past their retention are forgotten
+const eventPruneInterval = time.Hour
+
+// DuplicateFilter suppresses messages which duplicate a message already routed, by their
+// message ID. The messages of apps with nonces are unique, so duplicates are found in the
+// message store however long ago the original was routed. Identical messages of other
+// apps may be legitimate, so they are only suppressed within a sliding window. Messages
+// whose event was already routed are suppressed for any app, since an event is only
+// delivered once however often a restarted or misbehaving listener observes it.
+type DuplicateFilter struct {
+	messages *store.Messages
+	// nil if events are not remembered
+	events         *store.Events
+	eventRetention time.Duration
+	nonced         map[[20]byte]bool
+	window         time.Duration
+	mutex          sync.Mutex
+	// times at which messages were routed within the window, with their IDs in order
+	routed map[string]time.Time
+	order  []string
+	now    func() time.Time
+}
+
+func NewDuplicateFilter(config *DuplicateConfig, apps map[string]ethereum.Application, messages *store.Messages, events *store.Events) *DuplicateFilter {
+	df := &DuplicateFilter{
+		messages:       messages,
+		events:         events,
+		eventRetention: time.Duration(config.EventRetention) * 24 * time.Hour,
+		nonced:         make(map[[20]byte]bool),
+		window:         time.Duration(config.Window) * time.Second,
+		routed:         make(map[string]time.Time),
+		now:            time.Now,
+	}
+
+	for _, app := range apps {
+		if app.Nonced {
+			df.nonced[common.HexToAddress(app.Address)] = true
+		}
+	}
+
+	return df
+}
+
+// Duplicate returns whether a message observed on a source chain duplicates a message
+// already routed, recording it as routed otherwise
+func (df *DuplicateFilter) Duplicate(source string, msg *chain.Message) (bool, error) {
+	if eventID := msg.EventID(); eventID != "" && df.events != nil {
+		routed, err := df.events.Route(eventID, df.now())
+		if err != nil {
+			return false, err
+		}
+		if !routed {
+			metrics.SuppressedDuplicates.WithLabelValues(source, RetentionEvent).Inc()
+			return true, nil
+		}
+	}
+
+	if df.nonced[msg.AppID] {
+		record, err := df.messages.Lookup(source, msg)
+		if err == store.ErrNotFound {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		// quarantined messages are routed once they are observed again, while skipped
+		// messages would only revert again
+		if record.Status != store.StatusRouted && record.Status != store.StatusSkipped {
+			return false, nil
+		}
+		metrics.SuppressedDuplicates.WithLabelValues(source, RetentionPermanent).Inc()
+		return true, nil
+	}
+
+	if df.window == 0 {
+		return false, nil
+	}
+
+	id, _, err := store.MessageID(source, msg)
+	if err != nil {
+		return false, err
+	}
+
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	now := df.now()
+	df.expire(now)
+
+	if _, ok := df.routed[id]; ok {
+		metrics.SuppressedDuplicates.WithLabelValues(source, RetentionWindow).Inc()
+		return true, nil
+	}
+
+	df.routed[id] = now
+	df.order = append(df.order, id)
+	return false, nil
+}
+
+// Nonced returns whether the messages of an app are unique, so that duplicates are
+// suppressed however long ago the original was routed
+func (df *DuplicateFilter) Nonced(app [20]byte) bool {
+	return df.nonced[app]
+}
+
+// Task returns the task which forgets the events routed before their retention
+func (df *DuplicateFilter) Task() Task {
+	return Task{Name: "events", Interval: eventPruneInterval, Immediate: true, Run: df.prune}
+}
+
+func (df *DuplicateFilter) prune(context.Context) error {
+	_, err := df.events.Prune(df.now().Add(-df.eventRetention))
+	if err != nil {
+		return fmt.Errorf("prune routed events: %w", err)
+	}
+	return nil
+}
+
+// expire forgets the messages routed before the window
+func (df *DuplicateFilter) expire(now time.Time) {
+	expired := 0
+	for _, id := range df.order {
+		if now.Sub(df.routed[id]) < df.window {
+			break
+		}
+		delete(df.routed, id)
+		expired++
+	}
+	df.order = df.order[expired:]
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestDuplicateFilter_Permanent(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+	filter := NewDuplicateFilter(&DuplicateConfig{}, map[string]ethereum.Application{
+		"eth": {Address: "0x01", Nonced: true},
+	}, messages, nil)
+
+	msg := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1}}
+
+	duplicate, err := filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	// quarantined messages are not duplicates
+	require.NoError(t, messages.Quarantine("Ethereum", &msg, "payload too large"))
+	duplicate, err = filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	_, err = messages.Record("Ethereum", &msg, store.StatusRouted)
+	require.NoError(t, err)
+	duplicate, err = filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+
+	// the same message from another chain is a different message
+	duplicate, err = filter.Duplicate("Substrate", &msg)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+}
+
+func TestDuplicateFilter_Window(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	filter := NewDuplicateFilter(&DuplicateConfig{Window: 60}, nil, store.NewMessages(store.NewMemoryDB()), nil)
+	filter.now = func() time.Time { return now }
+
+	a := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1}}
+	b := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{2}}
+
+	duplicate, err := filter.Duplicate("Ethereum", &a)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	now = now.Add(30 * time.Second)
+	duplicate, err = filter.Duplicate("Ethereum", &b)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	duplicate, err = filter.Duplicate("Ethereum", &a)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+
+	// messages are forgotten once they leave the window
+	now = now.Add(40 * time.Second)
+	duplicate, err = filter.Duplicate("Ethereum", &a)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	duplicate, err = filter.Duplicate("Ethereum", &b)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+}
+
+func TestDuplicateFilter_Disabled(t *testing.T) {
+	filter := NewDuplicateFilter(&DuplicateConfig{}, nil, store.NewMessages(store.NewMemoryDB()), nil)
+	msg := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1}}
+
+	for i := 0; i < 2; i++ {
+		duplicate, err := filter.Duplicate("Ethereum", &msg)
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+	}
+}
+
+func TestDuplicateFilter_Events(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	filter := NewDuplicateFilter(&DuplicateConfig{EventRetention: 1}, nil, messages, store.NewEvents(db))
+	filter.now = func() time.Time { return now }
+
+	msg := chain.Message{
+		AppID:      common.HexToAddress("0x01"),
+		Payload:    []byte{1},
+		Source:     "Ethereum",
+		SourceHash: "0x0000000000000000000000000000000000000000000000000000000000000001",
+		EventIndex: 2,
+	}
+
+	duplicate, err := filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	// the event is remembered across restarts, without a window or nonces
+	filter = NewDuplicateFilter(&DuplicateConfig{EventRetention: 1}, nil, messages, store.NewEvents(db))
+	filter.now = func() time.Time { return now }
+	duplicate, err = filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+
+	// another event of the same block is not a duplicate
+	other := msg
+	other.EventIndex = 3
+	duplicate, err = filter.Duplicate("Ethereum", &other)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	// events are forgotten after their retention
+	now = now.Add(25 * time.Hour)
+	require.NoError(t, filter.Task().Run(context.Background()))
+	duplicate, err = filter.Duplicate("Ethereum", &msg)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+}
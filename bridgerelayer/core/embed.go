@@ -0,0 +1,49 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// Options customize a relay embedded in another service
+type Options struct {
+	// Whether the relay runs in explorer mode, without writers
+	Explorer bool
+	// Direction relayed alone, DirectionToEthereum or DirectionToSubstrate, for which the
+	// writer of the source chain and the listener of the target chain are disabled. Both
+	// directions are relayed if empty.
+	Direction string
+	// Database holding the state of the relay instead of the one of the store section,
+	// which the relay closes once it stops, but not if it can't be built
+	DB store.DB
+	// Feeds to which the events observed by the listeners are reported, after the feeds
+	// of the relay
+	Events []chain.EventFeed
+	// Logs to which the deliveries of messages are reported as they are submitted and
+	// confirmed, after the receipt logs of the relay
+	Receipts []chain.ReceiptLog
+	// Consumers of subscriptions to the observed events by name, each reading them from the
+	// journal at its own pace from a cursor recorded under its name
+	Subscribers map[string]EventConsumer
+}
+
+// LoadConfig reads the configuration of a relay from a file, or from the default locations
+// if path is empty, and its keys from the secret provider. Explorers need no keys.
+func LoadConfig(path string, explorer bool) (*Config, error) {
+	if path != "" {
+		SetConfigFile(path)
+	}
+	return readConfig(explorer)
+}
+
+// NewRelayWith creates a relay from a configuration read with LoadConfig, without starting
+// it. The relay installs its log hooks and tracer globally, like the relay of the binary.
+func NewRelayWith(config *Config, options *Options) (*Relay, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	return buildRelay(config, options)
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+type closedDB struct {
+	*store.MemoryDB
+	closed bool
+}
+
+func (cd *closedDB) Close() error {
+	cd.closed = true
+	return nil
+}
+
+func TestNewRelayWith_Failure(t *testing.T) {
+	previous, err := tracing.NewTracer(&tracing.Config{Endpoint: "http://previous:4318"}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	tracing.Install(previous)
+	defer tracing.Install(nil)
+
+	// the archive is built after the tracer is installed
+	config := &Config{
+		Tracing: tracing.Config{Endpoint: "http://collector:4318"},
+		Archive: ArchiveConfig{Config: objectstore.Config{URL: "ftp://archive"}},
+	}
+	db := &closedDB{MemoryDB: store.NewMemoryDB()}
+
+	_, err = NewRelayWith(config, &Options{Explorer: true, DB: db})
+	require.EqualError(t, err, "unsupported object storage url: ftp://archive")
+
+	// the caller's store is left open, and the tracer installed before is restored
+	assert.False(t, db.closed)
+	assert.Equal(t, previous, tracing.Install(nil))
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SnapshotConfig struct {
+	objectstore.Config `mapstructure:",squash"`
+	// Interval in seconds between published snapshots. Zero disables publishing.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+const (
+	// Objects of the published snapshot. The manifest is written last and carries the
+	// digest of the store, so readers can detect a store uploaded by a later publish.
+	snapshotManifestKey = "snapshots/signed/manifest.json"
+	snapshotStoreKey    = "snapshots/signed/store.gz"
+	snapshotVersion     = 1
+)
+
+// SnapshotManifest describes a published snapshot of the store
+type SnapshotManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Signer    string    `json:"signer"`
+	// Hex-encoded SHA-256 digest of the store snapshot
+	Digest string `json:"digest"`
+	Size   int    `json:"size"`
+	// Highest processed block of each chain
+	Heights map[string]uint64 `json:"heights"`
+	// Verified checkpoints of each chain which pins one, as recorded in the store
+	Checkpoints map[string]*chain.CheckpointConfig `json:"checkpoints"`
+}
+
+// SignedManifest is the published form of a manifest, signed as an EIP-191 personal
+// message with the Ethereum key of the publishing relayer
+type SignedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"`
+}
+
+// Publisher periodically publishes a signed snapshot of the store, from which new
+// relayer instances can start without scanning the chains for already relayed messages.
+// Unlike replication, the snapshot is meant to be consumed by instances of other operators.
+type Publisher struct {
+	interval time.Duration
+	db       store.DB
+	bucket   objectstore.Bucket
+	kp       *secp256k1.Keypair
+	chains   []string
+}
+
+func NewPublisher(config *SnapshotConfig, db store.DB, kp *secp256k1.Keypair, chains []string) (*Publisher, error) {
+	bucket, err := objectstore.Open(&config.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{
+		interval: time.Duration(config.Interval) * time.Second,
+		db:       db,
+		bucket:   bucket,
+		kp:       kp,
+		chains:   chains,
+	}, nil
+}
+
+func (pb *Publisher) Task() Task {
+	return Task{Name: "snapshot", Interval: pb.interval, Run: pb.publish}
+}
+
+func (pb *Publisher) publish(ctx context.Context) error {
+	manifest, err := publishSnapshot(ctx, pb.bucket, pb.db, pb.kp, pb.chains)
+	if err != nil {
+		return fmt.Errorf("publish store snapshot: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"size":    manifest.Size,
+		"heights": manifest.Heights,
+	}).Debug("Published store snapshot")
+	return nil
+}
+
+// publishSnapshot uploads a snapshot of the store followed by its signed manifest
+func publishSnapshot(ctx context.Context, bucket objectstore.Bucket, db store.DB, kp *secp256k1.Keypair, chains []string) (*SnapshotManifest, error) {
+	var buffer bytes.Buffer
+	err := store.WriteSnapshot(db, &buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(buffer.Bytes())
+	manifest := SnapshotManifest{
+		Version:     snapshotVersion,
+		CreatedAt:   time.Now().UTC(),
+		Signer:      kp.CommonAddress().Hex(),
+		Digest:      hex.EncodeToString(digest[:]),
+		Size:        buffer.Len(),
+		Heights:     make(map[string]uint64),
+		Checkpoints: make(map[string]*chain.CheckpointConfig),
+	}
+
+	blocks := store.NewBlocks(db)
+	checkpoints := store.NewCheckpoints(db)
+	for _, name := range chains {
+		processed, err := blocks.Processed(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(processed) > 0 {
+			manifest.Heights[name] = processed[len(processed)-1].End
+		}
+
+		checkpoint, err := checkpoints.LoadCheckpoint(name)
+		if err != nil {
+			return nil, err
+		}
+		if checkpoint != nil {
+			manifest.Checkpoints[name] = checkpoint
+		}
+	}
+
+	data, err := json.Marshal(&manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := Sign(kp, data)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := json.Marshal(&SignedManifest{
+		Manifest:  data,
+		Signature: hexutil.Encode(signature),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = bucket.Put(ctx, snapshotStoreKey, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	err = bucket.Put(ctx, snapshotManifestKey, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// FastSync initializes the local store from the snapshot published by another instance
+// at the given object storage URL, after verifying that it was signed by the given
+// Ethereum address. The local store must be empty unless forced.
+func FastSync(from string, signer string, force bool) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if !common.IsHexAddress(signer) {
+		return fmt.Errorf("invalid signer address: %s", signer)
+	}
+
+	bucket, err := objectstore.Open(&objectstore.Config{
+		URL:      from,
+		Region:   config.Snapshot.Region,
+		Endpoint: config.Snapshot.Endpoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(&config.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	manifest, err := fastSync(ctx, bucket, common.HexToAddress(signer), db, force)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"signer":    manifest.Signer,
+		"createdAt": manifest.CreatedAt,
+		"heights":   manifest.Heights,
+	}).Info("Restored store from published snapshot")
+
+	for name, checkpoint := range manifest.Checkpoints {
+		log.WithFields(log.Fields{
+			"chain":  name,
+			"number": checkpoint.Number,
+			"hash":   checkpoint.Hash,
+		}).Info("Restored trusted checkpoint")
+	}
+
+	return nil
+}
+
+func fastSync(ctx context.Context, bucket objectstore.Bucket, signer common.Address, db store.DB, force bool) (*SnapshotManifest, error) {
+	empty, err := store.IsEmpty(db)
+	if err != nil {
+		return nil, err
+	}
+	if !empty && !force {
+		return nil, fmt.Errorf("local store is not empty, refusing to overwrite it")
+	}
+
+	data, err := bucket.Get(ctx, snapshotManifestKey)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+
+	manifest, err := verifyManifest(data, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := bucket.Get(ctx, snapshotStoreKey)
+	if err != nil {
+		return nil, fmt.Errorf("fetch snapshot: %w", err)
+	}
+
+	digest := sha256.Sum256(snapshot)
+	if hex.EncodeToString(digest[:]) != manifest.Digest {
+		return nil, fmt.Errorf("snapshot does not match its manifest, it may have been replaced by a later publish")
+	}
+
+	err = store.RestoreSnapshot(db, bytes.NewReader(snapshot))
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// verifyManifest decodes a signed manifest, checking that it was signed by the signer
+func verifyManifest(data []byte, signer common.Address) (*SnapshotManifest, error) {
+	var signed SignedManifest
+	err := json.Unmarshal(data, &signed)
+	if err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+
+	signature, err := hexutil.Decode(signed.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("decode manifest signature: %w", err)
+	}
+
+	address, err := Recover(signed.Manifest, signature)
+	if err != nil {
+		return nil, err
+	}
+	if address != signer {
+		return nil, fmt.Errorf("manifest is signed by %s, not the trusted signer %s", address.Hex(), signer.Hex())
+	}
+
+	var manifest SnapshotManifest
+	err = json.Unmarshal(signed.Manifest, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if manifest.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+
+	return &manifest, nil
+}
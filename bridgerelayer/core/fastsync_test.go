@@ -0,0 +1,63 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestFastSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artemis-relay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bucket := objectstore.NewFileBucket(dir)
+	ctx := context.Background()
+	kp := secp256k1.Alice()
+
+	source := store.NewMemoryDB()
+	_, err = store.NewMessages(source).Record("Ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	require.NoError(t, store.NewBlocks(source).MarkProcessed("Ethereum", 42))
+	checkpoint := &chain.CheckpointConfig{Number: 40, Hash: "0x01", StateRoot: "0x02"}
+	require.NoError(t, store.NewCheckpoints(source).SaveCheckpoint("Ethereum", checkpoint))
+
+	published, err := publishSnapshot(ctx, bucket, source, kp, []string{"Ethereum", "Substrate"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"Ethereum": 42}, published.Heights)
+
+	// snapshots signed by another key are refused
+	_, err = fastSync(ctx, bucket, secp256k1.Bob().CommonAddress(), store.NewMemoryDB(), false)
+	assert.Error(t, err)
+
+	db := store.NewMemoryDB()
+	manifest, err := fastSync(ctx, bucket, kp.CommonAddress(), db, false)
+	require.NoError(t, err)
+	assert.Equal(t, published.Digest, manifest.Digest)
+	assert.Equal(t, checkpoint, manifest.Checkpoints["Ethereum"])
+
+	restored, err := store.NewCheckpoints(db).LoadCheckpoint("Ethereum")
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, restored)
+
+	// the local store is not overwritten unless forced
+	_, err = fastSync(ctx, bucket, kp.CommonAddress(), db, false)
+	assert.Error(t, err)
+
+	// a store which doesn't match the manifest is refused
+	require.NoError(t, bucket.Put(ctx, snapshotStoreKey, []byte("tampered")))
+	_, err = fastSync(ctx, bucket, kp.CommonAddress(), store.NewMemoryDB(), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type HoleConfig struct {
+	// Interval in seconds between checks for skipped blocks. Zero disables the detector.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+// Repairable is implemented by chains which can reprocess skipped blocks
+type Repairable interface {
+	Repair(ctx context.Context, from uint64, to uint64) error
+}
+
+// Replayable is implemented by chains which can re-emit the messages of a range of blocks
+type Replayable interface {
+	Replay(ctx context.Context, from uint64, to uint64) (uint64, uint64, error)
+}
+
+// Pinnable is implemented by chains which can resolve blocks by hash, so that replays are
+// pinned to exactly the intended history
+type Pinnable interface {
+	// CanonicalBlock returns the number of the block with a hash, failing unless it is on
+	// the canonical chain
+	CanonicalBlock(ctx context.Context, hash string) (uint64, error)
+}
+
+// HoleDetector periodically checks for blocks which were skipped by the listeners,
+// for example because the relayer crashed, and exports their number as metrics
+type HoleDetector struct {
+	interval time.Duration
+	blocks   *store.Blocks
+	chains   []string
+}
+
+func NewHoleDetector(config *HoleConfig, blocks *store.Blocks, chains []string) *HoleDetector {
+	return &HoleDetector{
+		interval: time.Duration(config.Interval) * time.Second,
+		blocks:   blocks,
+		chains:   chains,
+	}
+}
+
+func (hd *HoleDetector) Task() Task {
+	return Task{
+		Name:     "holes",
+		Interval: hd.interval,
+		Run: func(context.Context) error {
+			return hd.Check()
+		},
+	}
+}
+
+// Check updates the hole metrics of all chains
+func (hd *HoleDetector) Check() error {
+	for _, name := range hd.chains {
+		holes, err := hd.blocks.Holes(name)
+		if err != nil {
+			return fmt.Errorf("fetch skipped blocks of %s: %w", name, err)
+		}
+
+		var missing uint64
+		for _, hole := range holes {
+			missing += hole.Len()
+		}
+
+		metrics.BlockHoles.WithLabelValues(name).Set(float64(len(holes)))
+		metrics.MissingBlocks.WithLabelValues(name).Set(float64(missing))
+
+		if len(holes) > 0 {
+			log.WithFields(log.Fields{
+				"chain":   name,
+				"holes":   len(holes),
+				"missing": missing,
+			}).Warn("Detected skipped blocks, run the repair command to reprocess them")
+		}
+	}
+	return nil
+}
+
+// Holes returns the skipped blocks of each chain
+func (re *Relay) Holes() (map[string][]store.Interval, error) {
+	result := make(map[string][]store.Interval)
+	for _, ch := range re.chains {
+		holes, err := re.blocks.Holes(ch.Name())
+		if err != nil {
+			return nil, err
+		}
+		result[ch.Name()] = holes
+	}
+	return result, nil
+}
+
+// RepairHoles reprocesses the skipped blocks of a chain, returning the repaired ranges
+func (re *Relay) RepairHoles(ctx context.Context, name string) ([]store.Interval, error) {
+	for _, ch := range re.chains {
+		if !strings.EqualFold(ch.Name(), name) {
+			continue
+		}
+
+		repairable, ok := ch.(Repairable)
+		if !ok {
+			return nil, fmt.Errorf("chain %s does not support repairs", ch.Name())
+		}
+
+		holes, err := re.blocks.Holes(ch.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		repaired := []store.Interval{}
+		for _, hole := range holes {
+			log.WithFields(log.Fields{
+				"chain": ch.Name(),
+				"from":  hole.Start,
+				"to":    hole.End,
+			}).Info("Repairing skipped blocks")
+
+			err := repairable.Repair(ctx, hole.Start, hole.End)
+			if err != nil {
+				return repaired, err
+			}
+			repaired = append(repaired, hole)
+		}
+
+		return repaired, nil
+	}
+
+	return nil, fmt.Errorf("unknown chain: %s", name)
+}
+
+// ReplayBlocks re-emits the messages of the events of a range of blocks of a chain, even if
+// they were already relayed, without moving the cursor of its listener. Unset bounds default
+// to the configured range, and to the latest block. Bounds pinned by hash must be on the
+// canonical chain, before and after the replay. Returns the replayed range.
+func (re *Relay) ReplayBlocks(ctx context.Context, name string, from chain.BlockRef, to chain.BlockRef) (store.Interval, error) {
+	for _, ch := range re.chains {
+		if !strings.EqualFold(ch.Name(), name) {
+			continue
+		}
+
+		replayable, ok := ch.(Replayable)
+		if !ok {
+			return store.Interval{}, fmt.Errorf("chain %s does not support replays", ch.Name())
+		}
+
+		first, err := resolveBlock(ctx, ch, from)
+		if err != nil {
+			return store.Interval{}, err
+		}
+		last, err := resolveBlock(ctx, ch, to)
+		if err != nil {
+			return store.Interval{}, err
+		}
+		if from.Pinned() && to.Pinned() && last < first {
+			return store.Interval{}, fmt.Errorf("block %s at %d precedes block %s at %d", to, last, from, first)
+		}
+
+		log.WithFields(log.Fields{
+			"chain": ch.Name(),
+			"from":  from.String(),
+			"to":    to.String(),
+		}).Info("Replaying blocks")
+
+		start, end, err := replayable.Replay(ctx, first, last)
+		if err != nil {
+			return store.Interval{}, err
+		}
+
+		// a reorg during the replay may have replaced the pinned history
+		for _, ref := range []chain.BlockRef{from, to} {
+			_, err = resolveBlock(ctx, ch, ref)
+			if err != nil {
+				return store.Interval{Start: start, End: end}, fmt.Errorf("replayed %d-%d, but: %w", start, end, err)
+			}
+		}
+		return store.Interval{Start: start, End: end}, nil
+	}
+
+	return store.Interval{}, fmt.Errorf("unknown chain: %s", name)
+}
+
+// resolveBlock returns the number of a block of a chain, verifying that it is on the
+// canonical chain if it is pinned by hash
+func resolveBlock(ctx context.Context, ch chain.Chain, ref chain.BlockRef) (uint64, error) {
+	if !ref.Pinned() {
+		return ref.Number, nil
+	}
+
+	pinnable, ok := ch.(Pinnable)
+	if !ok {
+		return 0, fmt.Errorf("chain %s does not resolve blocks by hash", ch.Name())
+	}
+	return pinnable.CanonicalBlock(ctx, ref.Hash)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// pinnedChain replays blocks whose hashes are canonical until the replay reorganizes them
+type pinnedChain struct {
+	canonical map[string]uint64
+	// hash replaced by a reorg during the replay, empty if none is
+	reorged  string
+	replayed [2]uint64
+}
+
+func (pc *pinnedChain) Name() string                                        { return "Ethereum" }
+func (pc *pinnedChain) Start(ctx context.Context, eg *errgroup.Group) error { return nil }
+func (pc *pinnedChain) Stop()                                               {}
+
+func (pc *pinnedChain) Replay(ctx context.Context, from uint64, to uint64) (uint64, uint64, error) {
+	pc.replayed = [2]uint64{from, to}
+	delete(pc.canonical, pc.reorged)
+	return from, to, nil
+}
+
+func (pc *pinnedChain) CanonicalBlock(ctx context.Context, hash string) (uint64, error) {
+	number, ok := pc.canonical[hash]
+	if !ok {
+		return 0, fmt.Errorf("block %s is not on the canonical chain", hash)
+	}
+	return number, nil
+}
+
+func TestReplayBlocks_Pinned(t *testing.T) {
+	ctx := context.Background()
+	pc := &pinnedChain{canonical: map[string]uint64{"0x01": 1200, "0x02": 1300}}
+	re := &Relay{chains: []chain.Chain{pc}}
+
+	replayed, err := re.ReplayBlocks(ctx, "ethereum", chain.BlockRef{Hash: "0x01"}, chain.BlockRef{Hash: "0x02"})
+	assert.NoError(t, err)
+	assert.Equal(t, store.Interval{Start: 1200, End: 1300}, replayed)
+
+	// numbers and hashes mix
+	_, err = re.ReplayBlocks(ctx, "ethereum", chain.BlockRef{Number: 1100}, chain.BlockRef{Hash: "0x01"})
+	assert.NoError(t, err)
+	assert.Equal(t, [2]uint64{1100, 1200}, pc.replayed)
+
+	pc.replayed = [2]uint64{}
+	_, err = re.ReplayBlocks(ctx, "ethereum", chain.BlockRef{Hash: "0x03"}, chain.BlockRef{})
+	assert.EqualError(t, err, "block 0x03 is not on the canonical chain")
+	assert.Equal(t, [2]uint64{}, pc.replayed, "nothing is replayed from blocks off the canonical chain")
+
+	_, err = re.ReplayBlocks(ctx, "ethereum", chain.BlockRef{Hash: "0x02"}, chain.BlockRef{Hash: "0x01"})
+	assert.EqualError(t, err, "block 0x01 at 1200 precedes block 0x02 at 1300")
+
+	// pinned blocks which were reorganized during the replay fail it
+	pc.reorged = "0x02"
+	replayed, err = re.ReplayBlocks(ctx, "ethereum", chain.BlockRef{Hash: "0x01"}, chain.BlockRef{Hash: "0x02"})
+	assert.EqualError(t, err, "replayed 1200-1300, but: block 0x02 is not on the canonical chain")
+	assert.Equal(t, store.Interval{Start: 1200, End: 1300}, replayed)
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Version of the relayer, set at build time with
+// -ldflags "-X github.com/snowfork/polkadot-ethereum/bridgerelayer/core.Version=..."
+var Version = "dev"
+
+type IdentityConfig struct {
+	// Human readable name of the relayer instance
+	Label string `mapstructure:"label"`
+	// Environment in which the relayer runs, for example staging or production
+	Environment string `mapstructure:"environment"`
+	// Interval in seconds between heartbeats recorded on the Substrate chain. Zero disables heartbeats.
+	Heartbeat uint64 `mapstructure:"heartbeat"`
+}
+
+// Remarker records data on a chain
+type Remarker interface {
+	Remark(ctx context.Context, data []byte) error
+}
+
+// Heartbeat is recorded on-chain by each relayer to announce its identity
+type Heartbeat struct {
+	Relayer api.Identity `json:"relayer"`
+	SentAt  time.Time    `json:"sentAt"`
+}
+
+// SignedHeartbeat is the content of a heartbeat remark. The signature over the heartbeat
+// is made with Sign, so that the remark binds its Substrate sender to the ID.
+type SignedHeartbeat struct {
+	Heartbeat json.RawMessage `json:"heartbeat"`
+	Signature string          `json:"signature"`
+}
+
+// NewIdentity identifies the relayer by the address of its Ethereum key, and exports
+// the identity as the labels of the relayer_info metric
+func NewIdentity(config *IdentityConfig, kp *secp256k1.Keypair, relayConfig *Config) (*api.Identity, error) {
+	hash, err := configHash(relayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &api.Identity{
+		ID:          kp.CommonAddress().Hex(),
+		Label:       config.Label,
+		Environment: config.Environment,
+		Version:     Version,
+		ConfigHash:  hash,
+		StartedAt:   time.Now().UTC(),
+	}
+
+	metrics.RelayerInfo.WithLabelValues(identity.ID, identity.Label, identity.Environment, identity.Version, identity.ConfigHash).Set(1)
+
+	return identity, nil
+}
+
+// configHash hashes the configuration redacted as in support bundles, so that relayers can
+// be compared without revealing their keys or the credentials embedded in their endpoints
+func configHash(config *Config) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	var settings interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&settings)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = json.Marshal(redactSettings(settings))
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	return hexutil.Encode(hash[:]), nil
+}
+
+// Heartbeater periodically records a signed heartbeat of the relayer on-chain
+type Heartbeater struct {
+	interval time.Duration
+	identity *api.Identity
+	kp       *secp256k1.Keypair
+	remarker Remarker
+}
+
+func NewHeartbeater(config *IdentityConfig, identity *api.Identity, kp *secp256k1.Keypair, remarker Remarker) *Heartbeater {
+	return &Heartbeater{
+		interval: time.Duration(config.Heartbeat) * time.Second,
+		identity: identity,
+		kp:       kp,
+		remarker: remarker,
+	}
+}
+
+// Task beats as soon as the relayer starts, and then once per interval
+func (hb *Heartbeater) Task() Task {
+	return Task{Name: "heartbeat", Interval: hb.interval, Immediate: true, Run: hb.beat}
+}
+
+func (hb *Heartbeater) beat(ctx context.Context) error {
+	data, err := hb.remark(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("build heartbeat: %w", err)
+	}
+
+	err = hb.remarker.Remark(ctx, data)
+	if err != nil {
+		return fmt.Errorf("submit heartbeat: %w", err)
+	}
+
+	log.WithField("id", hb.identity.ID).Debug("Submitted heartbeat")
+	return nil
+}
+
+// remark returns the content of the heartbeat remark sent at a time
+func (hb *Heartbeater) remark(sentAt time.Time) ([]byte, error) {
+	heartbeat, err := json.Marshal(&Heartbeat{Relayer: *hb.identity, SentAt: sentAt})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := Sign(hb.kp, heartbeat)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&SignedHeartbeat{
+		Heartbeat: heartbeat,
+		Signature: hexutil.Encode(signature),
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+)
+
+type remarks struct {
+	data [][]byte
+}
+
+func (r *remarks) Remark(_ context.Context, data []byte) error {
+	r.data = append(r.data, data)
+	return nil
+}
+
+func TestNewIdentity(t *testing.T) {
+	kp := secp256k1.Alice()
+
+	var config Config
+	config.Eth.PrivateKey = "key-1"
+	config.Eth.Endpoint = "ws://localhost:8545"
+
+	identity, err := NewIdentity(&IdentityConfig{Label: "relayer-1", Environment: "staging"}, kp, &config)
+	require.NoError(t, err)
+	assert.Equal(t, kp.CommonAddress().Hex(), identity.ID)
+	assert.Equal(t, "relayer-1", identity.Label)
+	assert.Equal(t, Version, identity.Version)
+
+	// the hash ignores secret keys but not the rest of the configuration
+	config.Eth.PrivateKey = "key-2"
+	hash, err := configHash(&config)
+	require.NoError(t, err)
+	assert.Equal(t, identity.ConfigHash, hash)
+	assert.Equal(t, "key-2", config.Eth.PrivateKey)
+
+	// settings are redacted as in support bundles, including credentials in endpoints
+	config.Sub.Endpoint = "wss://node.example.com/api-key-1"
+	hash, err = configHash(&config)
+	require.NoError(t, err)
+	config.Sub.Endpoint = "wss://node.example.com/api-key-2"
+	redacted, err := configHash(&config)
+	require.NoError(t, err)
+	assert.Equal(t, hash, redacted)
+	config.Sub.Endpoint = ""
+
+	config.Eth.Endpoint = "ws://localhost:8546"
+	hash, err = configHash(&config)
+	require.NoError(t, err)
+	assert.NotEqual(t, identity.ConfigHash, hash)
+}
+
+func TestHeartbeater(t *testing.T) {
+	kp := secp256k1.Alice()
+	identity, err := NewIdentity(&IdentityConfig{Label: "relayer-1"}, kp, &Config{})
+	require.NoError(t, err)
+
+	sent := &remarks{}
+	heartbeater := NewHeartbeater(&IdentityConfig{Heartbeat: 60}, identity, kp, sent)
+	require.NoError(t, heartbeater.beat(context.Background()))
+	require.Len(t, sent.data, 1)
+
+	var signed SignedHeartbeat
+	require.NoError(t, json.Unmarshal(sent.data[0], &signed))
+
+	var heartbeat Heartbeat
+	require.NoError(t, json.Unmarshal(signed.Heartbeat, &heartbeat))
+	assert.Equal(t, "relayer-1", heartbeat.Relayer.Label)
+	assert.WithinDuration(t, time.Now(), heartbeat.SentAt, time.Minute)
+
+	// the heartbeat is signed by the identity key
+	signature, err := hexutil.Decode(signed.Signature)
+	require.NoError(t, err)
+	signer, err := Recover(signed.Heartbeat, signature)
+	require.NoError(t, err)
+	assert.Equal(t, identity.ID, signer.Hex())
+}
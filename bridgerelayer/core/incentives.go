@@ -0,0 +1,227 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/spf13/viper"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/pricing"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// IncentiveConfig are the fee parameters of an incentivized channel
+type IncentiveConfig struct {
+	// Fee charged to users per message, in base units of the native asset of the source chain
+	Fee string `mapstructure:"fee"`
+	// Share of the fee rewarded to the relayer which delivers the message. Defaults to 1.
+	RewardShare float64 `mapstructure:"reward-share"`
+}
+
+// ChannelProjection is the projected profit or loss of the relayer on a channel, had its
+// messages been relayed under a set of fee parameters
+type ChannelProjection struct {
+	Channel  string `json:"channel"`
+	Days     int    `json:"days"`
+	Messages uint64 `json:"messages"`
+	// Fees paid for deliveries, in base units of the native asset of the target chain
+	Cost string `json:"cost"`
+	// Rewards of the relayer, in base units of the native asset of the source chain
+	Revenue string `json:"revenue"`
+	// Reporting currency of the values below, which are omitted if pricing is disabled
+	Currency     string `json:"currency,omitempty"`
+	CostValue    string `json:"costValue,omitempty"`
+	RevenueValue string `json:"revenueValue,omitempty"`
+	Profit       string `json:"profit,omitempty"`
+	// Fee per message at which the relayer breaks even, in the reporting currency
+	BreakEvenFee string `json:"breakEvenFee,omitempty"`
+}
+
+// FeeReplay replays the delivery costs recorded in the channel statistics against fee
+// parameters, to project what the relayer would have earned. Costs and rewards are valued
+// at current prices, as historical prices aren't recorded.
+type FeeReplay struct {
+	incentives map[string]IncentiveConfig
+	// nil if pricing is disabled
+	converter *pricing.Converter
+}
+
+// NewFeeReplay replays the incentive parameters of the configuration file, whose fees are
+// replaced per channel by the given overrides. A reward share other than zero replaces the
+// share of all channels.
+func NewFeeReplay(overrides map[string]IncentiveConfig, rewardShare float64) (*FeeReplay, error) {
+	err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = viper.Unmarshal(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	incentives := make(map[string]IncentiveConfig)
+	for channel, incentive := range config.Incentives {
+		incentives[channel] = incentive
+	}
+	for channel, override := range overrides {
+		incentive := incentives[channel]
+		incentive.Fee = override.Fee
+		incentives[channel] = incentive
+	}
+	if rewardShare != 0 {
+		for channel, incentive := range incentives {
+			incentive.RewardShare = rewardShare
+			incentives[channel] = incentive
+		}
+	}
+
+	converter, err := pricing.NewConverter(&config.Pricing, log.WithField("service", "pricing"))
+	if err != nil {
+		return nil, err
+	}
+
+	return newFeeReplay(incentives, converter)
+}
+
+func newFeeReplay(incentives map[string]IncentiveConfig, converter *pricing.Converter) (*FeeReplay, error) {
+	for channel, incentive := range incentives {
+		if channelSource(channel) == "" {
+			return nil, fmt.Errorf("unknown channel %s", channel)
+		}
+		if _, ok := new(big.Int).SetString(incentive.Fee, 10); !ok {
+			return nil, fmt.Errorf("invalid fee of channel %s: %q", channel, incentive.Fee)
+		}
+		if incentive.RewardShare < 0 || incentive.RewardShare > 1 {
+			return nil, fmt.Errorf("reward share of channel %s is not between 0 and 1", channel)
+		}
+	}
+
+	return &FeeReplay{incentives: incentives, converter: converter}, nil
+}
+
+// Project sums the statistics of each channel with incentive parameters over the given days,
+// in order of channel
+func (fr *FeeReplay) Project(ctx context.Context, days []*store.ChannelStats) ([]*ChannelProjection, error) {
+	totals := make(map[string]*store.ChannelStats)
+	counts := make(map[string]int)
+	for _, day := range days {
+		if _, ok := fr.incentives[day.Channel]; !ok {
+			continue
+		}
+
+		total, ok := totals[day.Channel]
+		if !ok {
+			total = &store.ChannelStats{Channel: day.Channel}
+			totals[day.Channel] = total
+		}
+		total.Add(day)
+		counts[day.Channel]++
+	}
+
+	channels := make([]string, 0, len(fr.incentives))
+	for channel := range fr.incentives {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	projections := []*ChannelProjection{}
+	for _, channel := range channels {
+		total, ok := totals[channel]
+		if !ok {
+			total = &store.ChannelStats{Channel: channel}
+		}
+
+		projection, err := fr.project(ctx, total, fr.incentives[channel])
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", channel, err)
+		}
+		projection.Days = counts[channel]
+		projections = append(projections, projection)
+	}
+
+	return projections, nil
+}
+
+func (fr *FeeReplay) project(ctx context.Context, total *store.ChannelStats, incentive IncentiveConfig) (*ChannelProjection, error) {
+	cost, ok := new(big.Int).SetString(total.Fees, 10)
+	if !ok {
+		cost = new(big.Int)
+	}
+
+	share := incentive.RewardShare
+	if share == 0 {
+		share = 1
+	}
+
+	fee, _ := new(big.Int).SetString(incentive.Fee, 10)
+	revenue := new(big.Rat).SetInt(new(big.Int).Mul(fee, new(big.Int).SetUint64(total.Messages)))
+	revenue.Mul(revenue, new(big.Rat).SetFloat64(share))
+	rewards := new(big.Int).Quo(revenue.Num(), revenue.Denom())
+
+	projection := &ChannelProjection{
+		Channel:  total.Channel,
+		Messages: total.Messages,
+		Cost:     cost.String(),
+		Revenue:  rewards.String(),
+	}
+
+	if fr.converter == nil {
+		return projection, nil
+	}
+
+	costValue, err := fr.converter.Convert(ctx, channelTarget(total.Channel), cost)
+	if err != nil {
+		return nil, err
+	}
+	revenueValue, err := fr.converter.Convert(ctx, channelSource(total.Channel), rewards)
+	if err != nil {
+		return nil, err
+	}
+
+	projection.Currency = fr.converter.Currency()
+	projection.CostValue = costValue.FloatString(2)
+	projection.RevenueValue = revenueValue.FloatString(2)
+	projection.Profit = new(big.Rat).Sub(revenueValue, costValue).FloatString(2)
+
+	if total.Messages > 0 {
+		// the fee charged per message whose reward share covers the average cost
+		breakEven := new(big.Rat).Quo(costValue, new(big.Rat).SetInt64(int64(total.Messages)))
+		breakEven.Quo(breakEven, new(big.Rat).SetFloat64(share))
+		projection.BreakEvenFee = breakEven.FloatString(4)
+	}
+
+	return projection, nil
+}
+
+// channelSource returns the chain on which the messages of a channel are observed
+func channelSource(channel string) string {
+	switch channel {
+	case DirectionToSubstrate:
+		return ethereum.Name
+	case DirectionToEthereum:
+		return substrate.Name
+	}
+	return ""
+}
+
+// channelTarget returns the chain to which the messages of a channel are delivered
+func channelTarget(channel string) string {
+	switch channel {
+	case DirectionToSubstrate:
+		return substrate.Name
+	case DirectionToEthereum:
+		return ethereum.Name
+	}
+	return ""
+}
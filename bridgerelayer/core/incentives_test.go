@@ -0,0 +1,76 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/pricing"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestFeeReplay_Project(t *testing.T) {
+	days := []*store.ChannelStats{
+		{Channel: DirectionToSubstrate, Day: "2020-10-01", Messages: 3, Fees: "30000000000"},
+		{Channel: DirectionToSubstrate, Day: "2020-10-02", Messages: 1, Fees: "10000000000"},
+		{Channel: DirectionToEthereum, Day: "2020-10-01", Messages: 2, Fees: "4000000000000000"},
+	}
+	incentives := map[string]IncentiveConfig{
+		// 0.0002 ETH per message, of which the relayer gets half
+		DirectionToSubstrate: {Fee: "200000000000000", RewardShare: 0.5},
+	}
+
+	_, err := newFeeReplay(map[string]IncentiveConfig{"sideways": {Fee: "1"}}, nil)
+	assert.Error(t, err)
+	_, err = newFeeReplay(map[string]IncentiveConfig{DirectionToSubstrate: {Fee: "0.1"}}, nil)
+	assert.Error(t, err)
+	_, err = newFeeReplay(map[string]IncentiveConfig{DirectionToSubstrate: {Fee: "1", RewardShare: 2}}, nil)
+	assert.Error(t, err)
+
+	// without pricing, costs and revenues are given in the assets of each chain
+	replay, err := newFeeReplay(incentives, nil)
+	require.NoError(t, err)
+
+	projections, err := replay.Project(context.Background(), days)
+	require.NoError(t, err)
+	require.Len(t, projections, 1)
+	assert.Equal(t, &ChannelProjection{
+		Channel:  DirectionToSubstrate,
+		Days:     2,
+		Messages: 4,
+		Cost:     "40000000000",
+		Revenue:  "400000000000000",
+	}, projections[0])
+
+	// with pricing, they are compared in the reporting currency
+	converter, err := pricing.NewConverter(&pricing.Config{
+		Currency: "USD",
+		Prices:   map[string]string{"ETH": "400", "DOT": "5"},
+		Assets: map[string]pricing.Asset{
+			"ethereum":  {Symbol: "ETH", Decimals: 18},
+			"substrate": {Symbol: "DOT", Decimals: 10},
+		},
+	}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	replay, err = newFeeReplay(incentives, converter)
+	require.NoError(t, err)
+
+	projections, err = replay.Project(context.Background(), days)
+	require.NoError(t, err)
+	require.Len(t, projections, 1)
+
+	projection := projections[0]
+	assert.Equal(t, "USD", projection.Currency)
+	assert.Equal(t, "20.00", projection.CostValue)
+	assert.Equal(t, "0.16", projection.RevenueValue)
+	assert.Equal(t, "-19.84", projection.Profit)
+	// 5 USD per message, doubled by the reward share
+	assert.Equal(t, "10.0000", projection.BreakEvenFee)
+}
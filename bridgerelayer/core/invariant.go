@@ -0,0 +1,213 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/units"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type InvariantConfig struct {
+	// Interval in seconds between checks. Zero disables the checker.
+	Interval uint64           `mapstructure:"interval"`
+	Assets   []InvariantAsset `mapstructure:"assets"`
+}
+
+type InvariantAsset struct {
+	// Token address on Ethereum, or the zero address for ETH
+	Token string `mapstructure:"token"`
+	// Maximum amount (in Ethereum base units) which may be locked but not yet minted, to
+	// account for transfers which are still in flight
+	Tolerance string `mapstructure:"tolerance"`
+	// Decimals of the asset on each chain. The locked supply is rescaled to Substrate
+	// decimals before it is compared, so these default to being equal.
+	EthereumDecimals  uint8 `mapstructure:"ethereum-decimals"`
+	SubstrateDecimals uint8 `mapstructure:"substrate-decimals"`
+	// Fee in basis points retained on the Ethereum side of each transfer, which is not minted
+	FeeBps uint32 `mapstructure:"fee-bps"`
+	// Rounding policy of the rescaling: down (default), up, half-up or half-even
+	Rounding string `mapstructure:"rounding"`
+	// Rounding policy of the fee, which defaults to up so that the net amount is never overstated
+	FeeRounding string `mapstructure:"fee-rounding"`
+}
+
+// LockedSupply is implemented by chains which lock assets on the source side of the bridge
+type LockedSupply interface {
+	LockedSupply(ctx context.Context, asset [20]byte) (*big.Int, error)
+}
+
+// MintedSupply is implemented by chains which mint the bridged representation of assets
+type MintedSupply interface {
+	MintedSupply(ctx context.Context, asset [20]byte) (*big.Int, error)
+}
+
+// InvariantChecker periodically verifies that the total supply minted for each
+// bridged asset is backed by the balance locked in the bank contracts
+type InvariantChecker struct {
+	interval time.Duration
+	assets   []checkedAsset
+	locked   LockedSupply
+	minted   MintedSupply
+}
+
+type checkedAsset struct {
+	id [20]byte
+	// in Substrate base units
+	tolerance   *big.Int
+	ethDecimals uint8
+	subDecimals uint8
+	feeBps      uint32
+	rounding    units.Rounding
+	feeRounding units.Rounding
+}
+
+func NewInvariantChecker(config *InvariantConfig, locked LockedSupply, minted MintedSupply) (*InvariantChecker, error) {
+	assets := []checkedAsset{}
+	for _, asset := range config.Assets {
+		if !common.IsHexAddress(asset.Token) {
+			return nil, fmt.Errorf("invalid asset token address: %s", asset.Token)
+		}
+
+		tolerance := big.NewInt(0)
+		if asset.Tolerance != "" {
+			var ok bool
+			tolerance, ok = new(big.Int).SetString(asset.Tolerance, 10)
+			if !ok || tolerance.Sign() < 0 {
+				return nil, fmt.Errorf("invalid tolerance for asset %s: %s", asset.Token, asset.Tolerance)
+			}
+		}
+
+		rounding, err := units.ParseRounding(asset.Rounding)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rounding for asset %s: %w", asset.Token, err)
+		}
+
+		feeRounding := units.RoundUp
+		if asset.FeeRounding != "" {
+			feeRounding, err = units.ParseRounding(asset.FeeRounding)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fee rounding for asset %s: %w", asset.Token, err)
+			}
+		}
+
+		checked := checkedAsset{
+			id:          common.HexToAddress(asset.Token),
+			ethDecimals: asset.EthereumDecimals,
+			subDecimals: asset.SubstrateDecimals,
+			feeBps:      asset.FeeBps,
+			rounding:    rounding,
+			feeRounding: feeRounding,
+		}
+
+		// the tolerance is rounded up, so that it is never narrowed by rescaling
+		checked.tolerance, _, err = units.Rescale(tolerance, checked.ethDecimals, checked.subDecimals, units.RoundUp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimals for asset %s: %w", asset.Token, err)
+		}
+
+		if asset.FeeBps > units.FeeDenominator {
+			return nil, fmt.Errorf("invalid fee for asset %s: %d basis points", asset.Token, asset.FeeBps)
+		}
+
+		assets = append(assets, checked)
+	}
+
+	return &InvariantChecker{
+		interval: time.Duration(config.Interval) * time.Second,
+		assets:   assets,
+		locked:   locked,
+		minted:   minted,
+	}, nil
+}
+
+func (ic *InvariantChecker) Task() Task {
+	return Task{Name: "invariants", Interval: ic.interval, Run: ic.Check}
+}
+
+// Check compares locked and minted supplies for all configured assets. Violations raise
+// alerts of their own, so an error is only returned for assets which could not be checked.
+func (ic *InvariantChecker) Check(ctx context.Context) error {
+	unchecked := 0
+	for _, asset := range ic.assets {
+		fields := log.Fields{"asset": common.Address(asset.id).Hex()}
+
+		locked, err := ic.locked.LockedSupply(ctx, asset.id)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Warn("Failed to fetch locked supply")
+			unchecked++
+			continue
+		}
+
+		minted, err := ic.minted.MintedSupply(ctx, asset.id)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Warn("Failed to fetch minted supply")
+			unchecked++
+			continue
+		}
+
+		fields["locked"] = locked.String()
+		fields["minted"] = minted.String()
+
+		backed, err := asset.backing(locked)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Warn("Failed to convert locked supply")
+			unchecked++
+			continue
+		}
+		fields["backed"] = backed.String()
+
+		err = checkSupply(backed, minted, asset.tolerance)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Error("ALERT: bridge invariant violated")
+			continue
+		}
+
+		log.WithFields(fields).Debug("Bridge invariant holds")
+	}
+
+	if unchecked > 0 {
+		return fmt.Errorf("%d of %d assets could not be checked", unchecked, len(ic.assets))
+	}
+	return nil
+}
+
+// backing converts the locked supply of an asset into the Substrate units it backs, net of fees.
+// Dust rounded off individual transfers remains locked, so it is covered by the tolerance.
+func (ca *checkedAsset) backing(locked *big.Int) (*big.Int, error) {
+	rescaled, _, err := units.Rescale(locked, ca.ethDecimals, ca.subDecimals, ca.rounding)
+	if err != nil {
+		return nil, err
+	}
+
+	net, _, err := units.DeductFee(rescaled, ca.feeBps, ca.feeRounding)
+	if err != nil {
+		return nil, err
+	}
+
+	return net, nil
+}
+
+// checkSupply verifies the invariant for a single asset. Every minted unit must be backed
+// by a locked unit, while locked units may exceed minted units by at most the in-flight tolerance.
+// Supplies are compared in Substrate units, with backed being the converted locked supply.
+func checkSupply(backed, minted, tolerance *big.Int) error {
+	if minted.Cmp(backed) > 0 {
+		return fmt.Errorf("minted supply exceeds locked supply by %s", new(big.Int).Sub(minted, backed))
+	}
+
+	pending := new(big.Int).Sub(backed, minted)
+	if pending.Cmp(tolerance) > 0 {
+		return fmt.Errorf("locked supply exceeds minted supply by %s, beyond in-flight tolerance of %s", pending, tolerance)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSupply(t *testing.T) {
+	tolerance := big.NewInt(100)
+
+	assert.NoError(t, checkSupply(big.NewInt(1000), big.NewInt(1000), tolerance))
+	// transfers in flight
+	assert.NoError(t, checkSupply(big.NewInt(1100), big.NewInt(1000), tolerance))
+	// stuck beyond tolerance
+	assert.Error(t, checkSupply(big.NewInt(1101), big.NewInt(1000), tolerance))
+	// unbacked mint
+	assert.Error(t, checkSupply(big.NewInt(1000), big.NewInt(1001), tolerance))
+}
+
+func TestNewInvariantChecker_InvalidTolerance(t *testing.T) {
+	_, err := NewInvariantChecker(&InvariantConfig{
+		Interval: 60,
+		Assets:   []InvariantAsset{{Token: "0x0000000000000000000000000000000000000000", Tolerance: "-1"}},
+	}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestCheckedAsset_Backing(t *testing.T) {
+	checker, err := NewInvariantChecker(&InvariantConfig{
+		Interval: 60,
+		Assets: []InvariantAsset{
+			{Token: "0x0000000000000000000000000000000000000000", Tolerance: "100"},
+			{
+				Token:             "0x0000000000000000000000000000000000000001",
+				Tolerance:         "1500000000001",
+				EthereumDecimals:  18,
+				SubstrateDecimals: 12,
+				FeeBps:            30,
+			},
+			{Token: "0x0000000000000000000000000000000000000002", FeeBps: 30},
+			{Token: "0x0000000000000000000000000000000000000003", FeeBps: 30, FeeRounding: "down"},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	// equal decimals and no fee leave the locked supply unchanged
+	plain := checker.assets[0]
+	backed, err := plain.backing(big.NewInt(1100))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1100), backed)
+	assert.Equal(t, big.NewInt(100), plain.tolerance)
+
+	// 1 ETH and 999 wei truncated to 12 decimals, less the 0.3% fee
+	scaled := checker.assets[1]
+	locked, _ := new(big.Int).SetString("1000000000000000999", 10)
+	backed, err = scaled.backing(locked)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(997000000000), backed)
+	// tolerance is rounded up into Substrate units
+	assert.Equal(t, big.NewInt(1500001), scaled.tolerance)
+
+	assert.NoError(t, checkSupply(backed, big.NewInt(997000000000), scaled.tolerance))
+	assert.Error(t, checkSupply(backed, big.NewInt(997000000001), scaled.tolerance))
+
+	// the 3.003 fee on 1001 is rounded up unless the asset rounds fees otherwise
+	backed, err = checker.assets[2].backing(big.NewInt(1001))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(997), backed)
+	backed, err = checker.assets[3].backing(big.NewInt(1001))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(998), backed)
+}
+
+func TestNewInvariantChecker_InvalidConversion(t *testing.T) {
+	token := "0x0000000000000000000000000000000000000000"
+
+	for _, asset := range []InvariantAsset{
+		{Token: token, Rounding: "nearest"},
+		{Token: token, FeeRounding: "nearest"},
+		{Token: token, FeeBps: 10001},
+		{Token: token, EthereumDecimals: 78},
+	} {
+		_, err := NewInvariantChecker(&InvariantConfig{Interval: 60, Assets: []InvariantAsset{asset}}, nil, nil)
+		assert.Error(t, err)
+	}
+}
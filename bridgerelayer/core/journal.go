@@ -0,0 +1,137 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Projections lists the state which can be rebuilt from the journal
+var Projections = []string{"messages", "stats", "cursors", "accounting"}
+
+// JournalRecorder appends the submissions and confirmations reported by the writers, and the
+// events observed by the listeners, to the journal
+type JournalRecorder struct {
+	journal *store.Journal
+}
+
+func NewJournalRecorder(journal *store.Journal) *JournalRecorder {
+	return &JournalRecorder{journal: journal}
+}
+
+func (jr *JournalRecorder) Submitted(msg *chain.Message, receipt *chain.Receipt) {
+	jr.append(store.Receipt(store.EventSubmitted, msg, receipt))
+}
+
+func (jr *JournalRecorder) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	jr.append(store.Receipt(store.EventConfirmed, msg, receipt))
+}
+
+// Observed appends an observed event with the ID of its message, which is recorded under
+// the same ID, so that the outcome of its delivery can be related back to the event
+func (jr *JournalRecorder) Observed(event *chain.ObservedEvent) {
+	stateEvent := &store.StateEvent{Kind: store.EventObserved, Event: event}
+	if event.Message != nil {
+		id, _, err := store.MessageID(event.Chain, event.Message)
+		if err == nil {
+			stateEvent.MessageID = id
+		}
+	}
+	jr.append(stateEvent)
+}
+
+func (jr *JournalRecorder) append(event *store.StateEvent) {
+	err := jr.journal.Append(event)
+	if err != nil {
+		log.WithError(err).WithField("kind", event.Kind).Error("Failed to journal event, rebuild the projections of a stopped relay if it persists")
+	}
+}
+
+// ReadJournal calls fn with the events in the journal of a stopped relay from a sequence
+// number, in order
+func ReadJournal(from uint64, fn func(event *store.StateEvent) error) error {
+	config, err := readConfig(true)
+	if err != nil {
+		return err
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("the store keeps no journal, as it has no path")
+	}
+
+	db, err := store.Open(&config.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var fnErr error
+	err = store.NewMessages(db).Journal().Iterate(from, func(event *store.StateEvent) bool {
+		fnErr = fn(event)
+		return fnErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// RebuildProjections regenerates state of a stopped relay from its journal, returning the
+// number of events replayed
+func RebuildProjections(names []string) (uint64, error) {
+	config, err := readConfig(true)
+	if err != nil {
+		return 0, err
+	}
+	if config.Store.Path == "" {
+		return 0, fmt.Errorf("the store keeps no journal, as it has no path")
+	}
+
+	db, err := store.Open(&config.Store)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	cursors, err := store.OpenCursors(&config.Store, db)
+	if err != nil {
+		return 0, err
+	}
+
+	return rebuildProjections(db, cursors, config.Stats.Retention, names)
+}
+
+// rebuildProjections replays the journal into projections, once the records of a store
+// which predates the journal were appended to it. Statistics beyond the retention are
+// deleted as their days are replayed.
+func rebuildProjections(db store.DB, cursors chain.CursorStore, retention int, names []string) (uint64, error) {
+	messages := store.NewMessages(db)
+	_, err := messages.SeedJournal()
+	if err != nil {
+		return 0, err
+	}
+
+	var projections []store.Projection
+	for _, name := range names {
+		switch name {
+		case "messages":
+			projections = append(projections, messages.Projection())
+		case "stats":
+			projections = append(projections, NewStatsRecorder(store.NewStats(db, retention)))
+		case "cursors":
+			projections = append(projections, store.NewCursorProjection(cursors))
+		case "accounting":
+			projections = append(projections, NewAccountingRecorder(store.NewAccounting(db)))
+		default:
+			return 0, fmt.Errorf("unknown projection %q, expected one of %s", name, strings.Join(Projections, ", "))
+		}
+	}
+
+	return messages.Journal().Rebuild(projections...)
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/sr25519"
+)
+
+type KeystoreConfig struct {
+	// Ethereum V3 JSON keystore holding the key of the Ethereum writer, read instead of
+	// ARTEMIS_ETHEREUM_KEY
+	Ethereum string `mapstructure:"ethereum"`
+	// Keyring JSON holding the secret URI of the Substrate writer, read instead of
+	// ARTEMIS_SUBSTRATE_KEY
+	Substrate string `mapstructure:"substrate"`
+	// File holding the passphrase of the keystores, read if ARTEMIS_KEYSTORE_PASSPHRASE is
+	// not set. The passphrase is prompted for if neither is set.
+	PassphraseFile string `mapstructure:"passphrase-file"`
+}
+
+// PassphraseVariable is the environment variable holding the passphrase of the keystores
+const PassphraseVariable = "ARTEMIS_KEYSTORE_PASSPHRASE"
+
+// unlockKeystores decrypts the keys of the configured keystores into the chain configs.
+// Read-only relays don't need keys, so they only unlock the keystores if the passphrase is
+// at hand without prompting for it.
+func unlockKeystores(config *Config, readOnly bool) error {
+	ks := &config.Keystore
+	if ks.Ethereum == "" && ks.Substrate == "" {
+		return nil
+	}
+
+	passphrase, ok, err := KeystorePassphrase(config.secretSet.Lookup, ks.PassphraseFile, !readOnly, "Passphrase of the keystores: ")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	if ks.Ethereum != "" {
+		data, err := readKeystore(ks.Ethereum)
+		if err != nil {
+			return err
+		}
+		kp, err := secp256k1.DecryptKeystore(data, passphrase)
+		if err != nil {
+			return fmt.Errorf("ethereum keystore %s: %w", ks.Ethereum, err)
+		}
+		config.Eth.PrivateKey = hex.EncodeToString(kp.Encode())
+	}
+
+	if ks.Substrate != "" {
+		data, err := readKeystore(ks.Substrate)
+		if err != nil {
+			return err
+		}
+		uri, _, err := sr25519.DecryptKeyring(data, passphrase)
+		if err != nil {
+			return fmt.Errorf("substrate keyring %s: %w", ks.Substrate, err)
+		}
+		config.Sub.PrivateKey = uri
+	}
+
+	return nil
+}
+
+func readKeystore(file string) ([]byte, error) {
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(file)
+}
+
+// KeystorePassphrase returns the passphrase of the keystores from ARTEMIS_KEYSTORE_PASSPHRASE,
+// as looked up in the secrets, then from a file, and then from a prompt on the terminal if
+// allowed. It returns false if the passphrase could only be prompted for.
+func KeystorePassphrase(lookup func(name string) (string, bool), file string, prompt bool, question string) (string, bool, error) {
+	value, ok := lookup(PassphraseVariable)
+	if ok {
+		return value, true, nil
+	}
+
+	if file != "" {
+		file, err := homedir.Expand(file)
+		if err != nil {
+			return "", false, err
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	if !prompt {
+		return "", false, nil
+	}
+	value, err := ReadSecret(question)
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// ReadSecret prompts for a secret on the terminal without echoing it
+func ReadSecret(question string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("cannot prompt for %q without a terminal, set %s or a passphrase file", strings.TrimSuffix(question, ": "), PassphraseVariable)
+	}
+
+	fmt.Fprint(os.Stderr, question)
+	value, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// NewKeystore encrypts the key of the writer of a chain with a passphrase, returning the
+// keystore and the address of the key. A new key is generated if key is empty. Ethereum
+// keys are hex private keys, and Substrate keys are secret URIs.
+func NewKeystore(name string, key string, passphrase string) ([]byte, string, error) {
+	switch strings.ToLower(name) {
+	case "ethereum":
+		var kp *secp256k1.Keypair
+		var err error
+		if key == "" {
+			kp, err = secp256k1.GenerateKeypair()
+		} else {
+			kp, err = secp256k1.NewKeypairFromString(strings.TrimPrefix(key, "0x"))
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := secp256k1.EncryptKeystore(kp, passphrase)
+		return data, kp.Address(), err
+	case "substrate":
+		uri := key
+		if uri == "" {
+			var err error
+			uri, err = sr25519.NewSecretURI()
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		kp, err := sr25519.NewKeypairFromSeed(uri, "")
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := sr25519.EncryptKeyring(uri, kp.Address(), passphrase)
+		return data, kp.Address(), err
+	default:
+		return nil, "", fmt.Errorf("unknown chain %q, expected ethereum or substrate", name)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/sr25519"
+)
+
+func TestUnlockKeystores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kp, err := secp256k1.GenerateKeypair()
+	require.NoError(t, err)
+	ethJSON, err := secp256k1.EncryptKeystore(kp, "passphrase")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ethereum.json"), ethJSON, 0600))
+
+	subJSON, err := sr25519.EncryptKeyring("//Relayer", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", "passphrase")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "substrate.json"), subJSON, 0600))
+
+	// the passphrase file may end with a newline
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "passphrase"), []byte("passphrase\n"), 0600))
+
+	config := Config{Keystore: KeystoreConfig{
+		Ethereum:       filepath.Join(dir, "ethereum.json"),
+		Substrate:      filepath.Join(dir, "substrate.json"),
+		PassphraseFile: filepath.Join(dir, "passphrase"),
+	}}
+	err = unlockKeystores(&config, false)
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(kp.Encode()), config.Eth.PrivateKey)
+	assert.Equal(t, "//Relayer", config.Sub.PrivateKey)
+
+	// the environment variable takes precedence over the file
+	os.Setenv(PassphraseVariable, "wrong")
+	defer os.Unsetenv(PassphraseVariable)
+	err = unlockKeystores(&config, false)
+	assert.Error(t, err)
+}
+
+func TestUnlockKeystores_ReadOnly(t *testing.T) {
+	// read-only relays run without keys rather than prompting for the passphrase
+	config := Config{Keystore: KeystoreConfig{Ethereum: "/nonexistent/ethereum.json"}}
+	err := unlockKeystores(&config, true)
+	require.NoError(t, err)
+	assert.Equal(t, "", config.Eth.PrivateKey)
+}
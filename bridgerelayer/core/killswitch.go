@@ -0,0 +1,244 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type KillSwitchConfig struct {
+	// File whose presence halts the submissions to the chains it lists, one per line, or
+	// to all chains if it lists none. Disabled if empty.
+	File string `mapstructure:"file"`
+	// Seconds between checks of the file. Defaults to 1.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+const (
+	defaultKillSwitchInterval = 1
+	// gate reasons of the kill switch file and of emergency stops through the admin API, which
+	// are released independently
+	reasonKillSwitch    = "kill switch"
+	reasonEmergencyStop = "emergency stop"
+)
+
+// KillSwitch halts all submissions to a chain in an emergency, such as a suspected exploit,
+// while the kill switch file exists or once stopped through the admin API. Halted writers keep
+// their queued messages, and listeners keep recording messages and advancing checkpoints, so
+// relaying continues where it stopped once resumed.
+type KillSwitch struct {
+	file     string
+	interval time.Duration
+	// writer gates by chain name
+	gates map[string]*chain.Gate
+	mutex sync.Mutex
+	// chains halted by the file
+	engaged map[string]bool
+	// reasons of the emergency stops of each chain, as released on resumption
+	stops map[string]string
+}
+
+func NewKillSwitch(config *KillSwitchConfig, gates map[string]*chain.Gate) *KillSwitch {
+	interval := config.Interval
+	if interval == 0 {
+		interval = defaultKillSwitchInterval
+	}
+
+	return &KillSwitch{
+		file:     config.File,
+		interval: time.Duration(interval) * time.Second,
+		gates:    gates,
+		engaged:  make(map[string]bool),
+		stops:    make(map[string]string),
+	}
+}
+
+// Enabled returns whether a kill switch file is configured
+func (ks *KillSwitch) Enabled() bool {
+	return ks.file != ""
+}
+
+func (ks *KillSwitch) Start(ctx context.Context, eg *errgroup.Group) {
+	eg.Go(func() error {
+		ticker := time.NewTicker(ks.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				ks.Check()
+			}
+		}
+	})
+}
+
+// Check halts the chains listed by the kill switch file, and resumes those it no longer lists.
+// The state is left unchanged if the file can't be read.
+func (ks *KillSwitch) Check() {
+	if !ks.Enabled() {
+		return
+	}
+
+	halted, err := ks.read()
+	if err != nil {
+		log.WithError(err).WithField("file", ks.file).Error("Failed to read kill switch file")
+		return
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	for name, gate := range ks.gates {
+		fields := log.Fields{
+			"chain": name,
+			"file":  ks.file,
+		}
+		switch {
+		case halted[name] && !ks.engaged[name]:
+			gate.Halt(reasonKillSwitch)
+			log.WithFields(fields).Error("ALERT: kill switch engaged, halted submissions")
+		case !halted[name] && ks.engaged[name]:
+			gate.Release(reasonKillSwitch)
+			log.WithFields(fields).Warn("Kill switch released")
+		}
+		ks.engaged[name] = halted[name]
+	}
+}
+
+// read returns the chains halted by the kill switch file. A file which names an unknown chain
+// halts all chains, so that a mistyped name doesn't leave the bridge running.
+func (ks *KillSwitch) read() (map[string]bool, error) {
+	data, err := ioutil.ReadFile(ks.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+
+	halted := make(map[string]bool)
+	for _, line := range names {
+		name, ok := ks.chain(line)
+		if !ok {
+			log.WithFields(log.Fields{
+				"chain": line,
+				"file":  ks.file,
+			}).Warn("Kill switch file names an unknown chain, halting all chains")
+			names = nil
+			break
+		}
+		halted[name] = true
+	}
+
+	if len(names) == 0 {
+		for name := range ks.gates {
+			halted[name] = true
+		}
+	}
+	return halted, nil
+}
+
+// chain returns the name of a chain, matched regardless of case
+func (ks *KillSwitch) chain(name string) (string, bool) {
+	for known := range ks.gates {
+		if strings.EqualFold(known, name) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// Halts returns whether the submissions to each chain are halted, and why
+func (ks *KillSwitch) Halts() []api.ChainHalt {
+	halts := make([]api.ChainHalt, 0, len(ks.gates))
+	for name, gate := range ks.gates {
+		halted, reasons := gate.Halted()
+		halts = append(halts, api.ChainHalt{Chain: name, Halted: halted, Reasons: reasons})
+	}
+
+	sort.Slice(halts, func(i, j int) bool {
+		return halts[i].Chain < halts[j].Chain
+	})
+	return halts
+}
+
+// Stop halts all submissions to a chain until it is resumed through the admin API
+func (ks *KillSwitch) Stop(chainName string, reason string) error {
+	name, ok := ks.chain(chainName)
+	if !ok {
+		return fmt.Errorf("unknown chain %s", chainName)
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	stop := reasonEmergencyStop
+	if reason != "" {
+		stop += ": " + reason
+	}
+
+	// a stop replaces the reason of an earlier one
+	gate := ks.gates[name]
+	gate.Halt(stop)
+	if previous, ok := ks.stops[name]; ok && previous != stop {
+		gate.Release(previous)
+	}
+	ks.stops[name] = stop
+
+	log.WithFields(log.Fields{
+		"chain":  name,
+		"reason": reason,
+	}).Error("ALERT: emergency stop, halted submissions")
+	return nil
+}
+
+// Resume withdraws the emergency stop of a chain. Submissions remain halted while the kill
+// switch file or other reasons, such as an on-chain pause, still apply.
+func (ks *KillSwitch) Resume(chainName string) error {
+	name, ok := ks.chain(chainName)
+	if !ok {
+		return fmt.Errorf("unknown chain %s", chainName)
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	stop, ok := ks.stops[name]
+	if !ok {
+		return nil
+	}
+	ks.gates[name].Release(stop)
+	delete(ks.stops, name)
+
+	log.WithField("chain", name).Warn("Withdrew emergency stop")
+	return nil
+}
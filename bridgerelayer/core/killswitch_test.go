@@ -0,0 +1,104 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestKillSwitch_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "killswitch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "STOP")
+
+	eth, sub := chain.NewGate(), chain.NewGate()
+	kill := NewKillSwitch(&KillSwitchConfig{File: file}, map[string]*chain.Gate{"Ethereum": eth, "Substrate": sub})
+
+	halted := func() (bool, bool) {
+		ethHalted, _ := eth.Halted()
+		subHalted, _ := sub.Halted()
+		return ethHalted, subHalted
+	}
+
+	kill.Check()
+	ethHalted, subHalted := halted()
+	assert.False(t, ethHalted)
+	assert.False(t, subHalted)
+
+	// an empty file halts all chains
+	require.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	kill.Check()
+	ethHalted, subHalted = halted()
+	assert.True(t, ethHalted)
+	assert.True(t, subHalted)
+
+	// a file listing chains halts only those
+	require.NoError(t, ioutil.WriteFile(file, []byte("# suspected exploit\nethereum\n"), 0600))
+	kill.Check()
+	ethHalted, subHalted = halted()
+	assert.True(t, ethHalted)
+	assert.False(t, subHalted)
+
+	// a file naming an unknown chain halts all chains
+	require.NoError(t, ioutil.WriteFile(file, []byte("etherum\n"), 0600))
+	kill.Check()
+	ethHalted, subHalted = halted()
+	assert.True(t, ethHalted)
+	assert.True(t, subHalted)
+
+	require.NoError(t, os.Remove(file))
+	kill.Check()
+	ethHalted, subHalted = halted()
+	assert.False(t, ethHalted)
+	assert.False(t, subHalted)
+}
+
+func TestKillSwitch_Stop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "killswitch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "STOP")
+
+	eth, sub := chain.NewGate(), chain.NewGate()
+	kill := NewKillSwitch(&KillSwitchConfig{File: file}, map[string]*chain.Gate{"Ethereum": eth, "Substrate": sub})
+
+	assert.Error(t, kill.Stop("Kusama", ""))
+
+	require.NoError(t, kill.Stop("substrate", "suspected exploit"))
+	halts := kill.Halts()
+	require.Len(t, halts, 2)
+	assert.Equal(t, "Ethereum", halts[0].Chain)
+	assert.False(t, halts[0].Halted)
+	assert.True(t, halts[1].Halted)
+	assert.Equal(t, "emergency stop: suspected exploit", halts[1].Reasons)
+
+	// a later stop replaces the reason
+	require.NoError(t, kill.Stop("Substrate", ""))
+	assert.Equal(t, "emergency stop", kill.Halts()[1].Reasons)
+
+	// resuming doesn't release the kill switch file, nor the file an emergency stop
+	require.NoError(t, ioutil.WriteFile(file, nil, 0600))
+	kill.Check()
+	require.NoError(t, kill.Resume("Substrate"))
+	assert.True(t, kill.Halts()[1].Halted)
+
+	require.NoError(t, kill.Stop("Ethereum", ""))
+	require.NoError(t, os.Remove(file))
+	kill.Check()
+	halts = kill.Halts()
+	assert.True(t, halts[0].Halted)
+	assert.False(t, halts[1].Halted)
+
+	require.NoError(t, kill.Resume("Ethereum"))
+	assert.False(t, kill.Halts()[0].Halted)
+}
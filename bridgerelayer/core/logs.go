@@ -0,0 +1,120 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SupportConfig struct {
+	// Number of recent log entries kept in memory for support bundles. Defaults to 2000.
+	RecentLogs int `mapstructure:"recent-logs"`
+}
+
+const defaultRecentLogs = 2000
+
+// LogBuffer is a log hook which keeps the most recent entries in memory, so that they can
+// be collected into support bundles from a running relay. Entries are redacted as they are
+// logged, so that the admin API never serves secrets, and masked if privacy is enabled.
+type LogBuffer struct {
+	mutex sync.Mutex
+	// nil if disabled
+	privacy *Privacy
+	entries []api.LogEntry
+	// index at which the next entry is kept
+	next int
+	full bool
+}
+
+func NewLogBuffer(config *SupportConfig, privacy *Privacy) *LogBuffer {
+	size := config.RecentLogs
+	if size <= 0 {
+		size = defaultRecentLogs
+	}
+	return &LogBuffer{privacy: privacy, entries: make([]api.LogEntry, size)}
+}
+
+func (lb *LogBuffer) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (lb *LogBuffer) Fire(entry *log.Entry) error {
+	kept := api.LogEntry{
+		Time:    entry.Time.UTC(),
+		Level:   entry.Level.String(),
+		Message: redactText(entry.Message),
+	}
+	if lb.privacy != nil {
+		kept.Message = lb.privacy.Text(kept.Message)
+	}
+	if len(entry.Data) > 0 {
+		kept.Fields = make(map[string]string, len(entry.Data))
+		for name, value := range entry.Data {
+			if isSecret(name) {
+				kept.Fields[name] = redacted
+				continue
+			}
+			if lb.privacy != nil {
+				value = lb.privacy.Field(name, value)
+			}
+			kept.Fields[name] = redactText(fmt.Sprint(value))
+		}
+	}
+
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	lb.entries[lb.next] = kept
+	lb.next++
+	if lb.next == len(lb.entries) {
+		lb.next = 0
+		lb.full = true
+	}
+	return nil
+}
+
+// RecentLogs returns up to limit of the most recent entries, oldest first, or all kept
+// entries if the limit is zero
+func (lb *LogBuffer) RecentLogs(limit int) []api.LogEntry {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	var entries []api.LogEntry
+	if lb.full {
+		entries = append(entries, lb.entries[lb.next:]...)
+	}
+	entries = append(entries, lb.entries[:lb.next]...)
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries
+}
+
+// loggerState is the formatter and hooks of the standard logger before a relay installed its
+// own, which are restored once the relay stops, so that hosts embedding the relay don't keep
+// firing the hooks of a stopped relay
+type loggerState struct {
+	formatter log.Formatter
+	hooks     log.LevelHooks
+}
+
+func saveLogger() *loggerState {
+	logger := log.StandardLogger()
+	hooks := make(log.LevelHooks, len(logger.Hooks))
+	for level, levelHooks := range logger.Hooks {
+		hooks[level] = append([]log.Hook{}, levelHooks...)
+	}
+	return &loggerState{formatter: logger.Formatter, hooks: hooks}
+}
+
+func (ls *loggerState) restore() {
+	log.SetFormatter(ls.formatter)
+	log.StandardLogger().ReplaceHooks(ls.hooks)
+}
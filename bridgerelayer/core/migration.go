@@ -0,0 +1,208 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PayloadChange is the migration of the payload of a pending message to a later schema
+// version, which changes its ID along with its payload
+type PayloadChange struct {
+	ID         string `json:"id"`
+	NewID      string `json:"newId"`
+	Sequence   uint64 `json:"sequence"`
+	Source     string `json:"source"`
+	AppID      string `json:"appId"`
+	Status     string `json:"status"`
+	From       int    `json:"from"`
+	To         int    `json:"to"`
+	Payload    string `json:"payload"`
+	NewPayload string `json:"newPayload"`
+	// Queue of the outbox entry of the message, which is migrated along with its record,
+	// empty if the message has none
+	Queue string `json:"queue,omitempty"`
+	// Reason the message could not be migrated, which leaves its record unchanged
+	Error string `json:"error,omitempty"`
+}
+
+// MigrateStore migrates the pending messages in the store of a stopped relay to the current
+// payload schema version. In a dry run, the changes are only reported.
+func MigrateStore(dryRun bool) ([]PayloadChange, error) {
+	config, err := readConfig(true)
+	if err != nil {
+		return nil, err
+	}
+	if config.Store.Path == "" {
+		return nil, fmt.Errorf("the store keeps no records to migrate, as it has no path")
+	}
+
+	db, err := store.Open(&config.Store)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return MigratePayloads(store.NewMessages(db), store.NewOutbox(db), chain.PayloadSchemaVersion, dryRun)
+}
+
+// MigratePayloads re-encodes the payloads of the pending messages which were recorded with an
+// earlier schema version, so that messages which were queued but not delivered before an
+// upgrade are recognised by the upgraded relayer when their events are observed again. Their
+// entries in the outbox are moved to the new IDs with the re-encoded payloads, so that they
+// are redelivered and acknowledged as the migrated messages. In a dry run, the changes are
+// only reported. Messages which can't be migrated are reported with the reason, and left
+// unchanged.
+func MigratePayloads(messages *store.Messages, outbox *store.Outbox, to int, dryRun bool) ([]PayloadChange, error) {
+	records, err := messages.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []PayloadChange{}
+	for _, record := range records {
+		if record.SchemaVersion() >= to {
+			continue
+		}
+
+		change, migrated := migrateRecord(record, to)
+		if migrated != nil {
+			if existing, err := messages.Get(migrated.ID); err == nil {
+				change.Error = fmt.Sprintf("already recorded as %s message", existing.Status)
+				migrated = nil
+			} else if err != store.ErrNotFound {
+				return changes, err
+			}
+		}
+
+		var entry *chain.Message
+		if migrated != nil {
+			entry, err = migrateEntry(outbox, &change, migrated)
+			if err != nil {
+				return changes, err
+			}
+			if change.Error != "" {
+				migrated = nil
+			}
+		}
+
+		if migrated != nil && !dryRun {
+			err = messages.Replace(record.ID, migrated)
+			if err != nil {
+				return changes, err
+			}
+			if entry != nil {
+				err = outbox.Replace(record.ID, entry)
+				if err != nil {
+					return changes, err
+				}
+			}
+
+			log.WithFields(log.Fields{
+				"messageID": record.ID,
+				"newID":     migrated.ID,
+				"sequence":  record.Sequence,
+				"from":      change.From,
+				"to":        to,
+			}).Info("Migrated payload of pending message")
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// migrateRecord returns the change of a record and the migrated record, which is nil if the
+// payload could not be migrated
+func migrateRecord(record *store.MessageRecord, to int) (PayloadChange, *store.MessageRecord) {
+	change := PayloadChange{
+		ID:       record.ID,
+		Sequence: record.Sequence,
+		Source:   record.Source,
+		AppID:    record.AppID,
+		Status:   string(record.Status),
+		From:     record.SchemaVersion(),
+		To:       to,
+		Payload:  record.Payload,
+	}
+
+	appID, payload, err := decodeRecord(record)
+	if err != nil {
+		change.Error = err.Error()
+		return change, nil
+	}
+
+	payload, err = chain.MigratePayloadVersion(record.Source, appID, payload, change.From, to)
+	if err != nil {
+		change.Error = err.Error()
+		return change, nil
+	}
+
+	// byte payloads are hashed as they are, so the ID follows from the re-encoded payload
+	id, _, err := store.MessageID(record.Source, &chain.Message{AppID: appID, Payload: payload})
+	if err != nil {
+		change.Error = err.Error()
+		return change, nil
+	}
+	change.NewID = id
+	change.NewPayload = hex.EncodeToString(payload)
+
+	migrated := *record
+	migrated.ID = id
+	migrated.Payload = change.NewPayload
+	migrated.Schema = to
+	migrated.MigratedFrom = record.ID
+	migrated.UpdatedAt = time.Now().UTC()
+	return change, &migrated
+}
+
+// migrateEntry returns the message of the outbox entry of a migrated record, with its new
+// ID and payload, which is nil if the message has no entry. Entries whose payload can't be
+// migrated are reported as the error of the change.
+func migrateEntry(outbox *store.Outbox, change *PayloadChange, migrated *store.MessageRecord) (*chain.Message, error) {
+	entry, err := outbox.Get(change.ID)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	change.Queue = entry.Queue
+
+	payload, err := hex.DecodeString(migrated.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := *entry.Message
+	msg.ID = migrated.ID
+	msg.Payload, err = chain.DecodePayloadAs(entry.Message.Payload, payload)
+	if err != nil {
+		change.Error = fmt.Sprintf("outbox entry: %v", err)
+		return nil, nil
+	}
+	return &msg, nil
+}
+
+func decodeRecord(record *store.MessageRecord) ([20]byte, []byte, error) {
+	var appID [20]byte
+	decoded, err := hex.DecodeString(record.AppID)
+	if err != nil || len(decoded) != len(appID) {
+		return appID, nil, fmt.Errorf("invalid app ID: %s", record.AppID)
+	}
+	copy(appID[:], decoded)
+
+	payload, err := hex.DecodeString(record.Payload)
+	if err != nil {
+		return appID, nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	return appID, payload, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestMigratePayloads(t *testing.T) {
+	broken := common.HexToAddress("0x02")
+	chain.RegisterPayloadMigration(2, func(_ string, appID [20]byte, payload []byte) ([]byte, error) {
+		if appID == broken {
+			return nil, errors.New("unknown layout")
+		}
+		return append(payload, 0xff), nil
+	})
+
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	outbox := store.NewOutbox(db)
+	routed := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1}}
+	quarantined := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{2}}
+	observed := chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{3}}
+	failing := chain.Message{AppID: broken, Payload: []byte{4}}
+
+	routedRecord, err := messages.Record("Substrate", &routed, store.StatusRouted)
+	require.NoError(t, err)
+	require.NoError(t, messages.Quarantine("Ethereum", &quarantined, "unsent: context canceled"))
+	_, err = messages.Record("Substrate", &observed, store.StatusObserved)
+	require.NoError(t, err)
+	_, err = messages.Record("Substrate", &failing, store.StatusRouted)
+	require.NoError(t, err)
+	sent := routed
+	sent.ID = routedRecord.ID
+	sent.Sequence = routedRecord.Sequence
+	require.NoError(t, outbox.Add("substrate-to-ethereum", &sent))
+
+	// a dry run reports the changes of pending messages without applying them
+	changes, err := MigratePayloads(messages, outbox, 2, true)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, routedRecord.ID, changes[0].ID)
+	assert.Equal(t, "01", changes[0].Payload)
+	assert.Equal(t, "01ff", changes[0].NewPayload)
+	assert.Equal(t, 1, changes[0].From)
+	assert.Equal(t, 2, changes[0].To)
+	assert.Equal(t, "substrate-to-ethereum", changes[0].Queue)
+	assert.Empty(t, changes[1].Queue)
+	assert.Equal(t, "02ff", changes[1].NewPayload)
+	assert.Contains(t, changes[2].Error, "unknown layout")
+
+	_, err = messages.Get(routedRecord.ID)
+	require.NoError(t, err)
+	_, err = outbox.Get(routedRecord.ID)
+	require.NoError(t, err)
+
+	changes, err = MigratePayloads(messages, outbox, 2, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	// migrated records are found as the upgraded relayer encodes their messages
+	_, err = messages.Get(routedRecord.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+	migrated, err := messages.Lookup("Substrate", &chain.Message{AppID: routed.AppID, Payload: []byte{1, 0xff}})
+	require.NoError(t, err)
+	assert.Equal(t, changes[0].NewID, migrated.ID)
+	assert.Equal(t, routedRecord.ID, migrated.MigratedFrom)
+	assert.Equal(t, routedRecord.Sequence, migrated.Sequence)
+	assert.Equal(t, store.StatusRouted, migrated.Status)
+	assert.Equal(t, 2, migrated.SchemaVersion())
+
+	// outbox entries are redelivered as the migrated messages
+	_, err = outbox.Get(routedRecord.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+	pending, err := outbox.Pending("substrate-to-ethereum")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, migrated.ID, pending[0].Message.ID)
+	assert.Equal(t, routedRecord.Sequence, pending[0].Message.Sequence)
+	assert.Equal(t, []byte{1, 0xff}, pending[0].Message.Payload)
+
+	// messages which failed or are never delivered are left unchanged
+	record, err := messages.Lookup("Substrate", &failing)
+	require.NoError(t, err)
+	assert.Equal(t, 1, record.SchemaVersion())
+	record, err = messages.Lookup("Substrate", &observed)
+	require.NoError(t, err)
+	assert.Equal(t, 1, record.SchemaVersion())
+
+	// migrated messages are not migrated again, and missing migrations are reported
+	changes, err = MigratePayloads(messages, outbox, 2, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	changes, err = MigratePayloads(messages, outbox, 3, true)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Contains(t, changes[0].Error, "no migration of payloads to schema version 3")
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// BalanceMonitored is implemented by chains whose writers monitor the balances of the
+// accounts paying for their submissions
+type BalanceMonitored interface {
+	// BalanceInterval returns the interval between checks of the balances, zero if they
+	// aren't monitored
+	BalanceInterval() time.Duration
+	CheckBalances(ctx context.Context) error
+}
+
+// balanceTask checks the balances of a chain as soon as the scheduler starts, after the
+// chain, and then at each interval
+func balanceTask(name string, ch BalanceMonitored) Task {
+	return Task{
+		Name:      chainTask(name, "balance"),
+		Interval:  ch.BalanceInterval(),
+		Immediate: true,
+		Run:       ch.CheckBalances,
+	}
+}
+
+// DivergenceMonitored is implemented by chains which compare the heads served by their
+// endpoints
+type DivergenceMonitored interface {
+	// Divergence returns the monitor of the heads, nil if disabled
+	Divergence() *chain.DivergenceMonitor
+}
+
+// divergenceTask compares the heads of the endpoints of a chain at each interval. Lagging
+// and forked endpoints are alerted on by the monitor, rather than failing the task.
+func divergenceTask(name string, monitor *chain.DivergenceMonitor) Task {
+	return Task{
+		Name:     chainTask(name, "divergence"),
+		Interval: monitor.Interval(),
+		Run: func(ctx context.Context) error {
+			monitor.Check(ctx)
+			return nil
+		},
+	}
+}
+
+// chainTask names the task of a chain, such as ethereum-balance
+func chainTask(chain string, task string) string {
+	return strings.ToLower(chain) + "-" + task
+}
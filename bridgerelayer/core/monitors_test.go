@@ -0,0 +1,45 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// unreachableHeads is an endpoint which fails to report its heads
+type unreachableHeads struct{}
+
+func (unreachableHeads) Endpoint() string {
+	return "wss://unreachable"
+}
+
+func (unreachableHeads) Heads(ctx context.Context) (chain.Head, chain.Head, error) {
+	return chain.Head{}, chain.Head{}, context.DeadlineExceeded
+}
+
+func (unreachableHeads) BlockHash(ctx context.Context, number uint64) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func TestDivergenceTask(t *testing.T) {
+	monitor := chain.NewDivergenceMonitor("Ethereum", &chain.DivergenceConfig{Interval: 30}, []chain.HeadSource{unreachableHeads{}}, logrus.NewEntry(logrus.New()))
+
+	task := divergenceTask("Ethereum", monitor)
+	assert.Equal(t, "ethereum-divergence", task.Name)
+	assert.Equal(t, 30*time.Second, task.Interval)
+
+	// endpoints which fail to answer are reported by the monitor, without failing the task
+	require.NoError(t, task.Run(context.Background()))
+	heads := monitor.Heads()
+	require.Len(t, heads, 1)
+	assert.NotEmpty(t, heads[0].Error)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+)
+
+// NetworkConfig configures a further Ethereum network to which messages are delivered,
+// such as a testnet relayed alongside mainnet. Its apps receive the messages of the
+// Substrate apps of the same name, so app names must be unique across networks.
+type NetworkConfig struct {
+	// Identifier by which messages are routed to the network
+	Name            string `mapstructure:"name"`
+	ethereum.Config `mapstructure:",squash"`
+}
+
+// routedNetwork is a further network with the channel from which its writer reads
+type routedNetwork struct {
+	network *ethereum.Network
+	in      chan chain.Message
+}
+
+var networkNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// networkKeyVariable is the environment variable holding the key of a network, such as
+// ARTEMIS_ETHEREUM_KEY_SEPOLIA for sepolia
+func networkKeyVariable(name string) string {
+	return "ARTEMIS_ETHEREUM_KEY_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadNetworks reads the keys of the further networks, and adds their apps to the targets
+// of Substrate with the network whose writer delivers their messages
+func loadNetworks(config *Config, readOnly bool) error {
+	config.Sub.Networks = make(map[string]string)
+	names := make(map[string]bool)
+	networks := make(map[string]string)
+	for name := range config.Eth.Apps {
+		networks[name] = ""
+	}
+
+	for i := range config.Networks {
+		network := &config.Networks[i]
+		if !networkNamePattern.MatchString(network.Name) {
+			return fmt.Errorf("network name %q is not lower case letters, digits and dashes", network.Name)
+		}
+		if names[network.Name] {
+			return fmt.Errorf("network %s is configured twice", network.Name)
+		}
+		names[network.Name] = true
+
+		for name, app := range network.Apps {
+			if other, ok := networks[name]; ok {
+				if other == "" {
+					other = "ethereum"
+				}
+				return fmt.Errorf("app %s of network %s is also an app of %s", name, network.Name, other)
+			}
+			networks[name] = network.Name
+			config.Sub.Targets[name] = common.HexToAddress(app.Address)
+			config.Sub.Networks[name] = network.Name
+		}
+
+		if network.RemoteSigner == nil {
+			value, ok := config.secretSet.Lookup(networkKeyVariable(network.Name))
+			if !ok && !readOnly {
+				return config.secretSet.Missing(networkKeyVariable(network.Name))
+			}
+			network.PrivateKey = value
+		}
+		network.ReadOnly = readOnly
+		network.Overrides = config.Overrides
+	}
+	return nil
+}
+
+// buildNetworks creates the writers of the further networks
+func buildNetworks(configs []NetworkConfig, services *chain.Services) ([]routedNetwork, error) {
+	var networks []routedNetwork
+	for i := range configs {
+		in := make(chan chain.Message, 1)
+		network, err := ethereum.NewNetwork(configs[i].Name, &configs[i].Config, in, services)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", configs[i].Name, err)
+		}
+		networks = append(networks, routedNetwork{network: network, in: in})
+	}
+	return networks, nil
+}
+
+// networkApps returns the apps of the ethereum section and of all further networks
+func networkApps(config *Config) map[string]ethereum.Application {
+	apps := make(map[string]ethereum.Application, len(config.Eth.Apps))
+	for name, app := range config.Eth.Apps {
+		apps[name] = app
+	}
+	for _, network := range config.Networks {
+		for name, app := range network.Apps {
+			apps[name] = app
+		}
+	}
+	return apps
+}
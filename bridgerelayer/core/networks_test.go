@@ -0,0 +1,115 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestLoadNetworks(t *testing.T) {
+	os.Setenv("ARTEMIS_ETHEREUM_KEY_SEPOLIA_2", "0x935b65c833ced92c43ef9de6bff30703d941bd92a2637cb00cfad389f5862109")
+	defer os.Unsetenv("ARTEMIS_ETHEREUM_KEY_SEPOLIA_2")
+
+	config := Config{
+		Eth: ethereum.Config{Apps: map[string]ethereum.Application{
+			"eth": {Address: "0x0000000000000000000000000000000000000001"},
+		}},
+		Networks: []NetworkConfig{{
+			Name: "sepolia-2",
+			Config: ethereum.Config{Apps: map[string]ethereum.Application{
+				"eth-sepolia": {Address: "0x0000000000000000000000000000000000000002"},
+			}},
+		}},
+	}
+	config.Sub.Targets = map[string][20]byte{"eth": common.HexToAddress("0x0000000000000000000000000000000000000001")}
+
+	err := loadNetworks(&config, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "0x935b65c833ced92c43ef9de6bff30703d941bd92a2637cb00cfad389f5862109", config.Networks[0].PrivateKey)
+	assert.Equal(t, common.HexToAddress("0x0000000000000000000000000000000000000002"), common.Address(config.Sub.Targets["eth-sepolia"]))
+	assert.Equal(t, map[string]string{"eth-sepolia": "sepolia-2"}, config.Sub.Networks)
+	assert.Len(t, networkApps(&config), 2)
+}
+
+func TestLoadNetworks_Invalid(t *testing.T) {
+	os.Setenv("ARTEMIS_ETHEREUM_KEY_SEPOLIA", "0x935b65c833ced92c43ef9de6bff30703d941bd92a2637cb00cfad389f5862109")
+	defer os.Unsetenv("ARTEMIS_ETHEREUM_KEY_SEPOLIA")
+
+	apps := map[string]ethereum.Application{"eth": {Address: "0x0000000000000000000000000000000000000002"}}
+	tests := map[string][]NetworkConfig{
+		"unnamed":      {{}},
+		"upper case":   {{Name: "Sepolia"}},
+		"twice":        {{Name: "sepolia"}, {Name: "sepolia"}},
+		"app collides": {{Name: "sepolia", Config: ethereum.Config{Apps: apps}}},
+		"no key":       {{Name: "goerli"}},
+	}
+	for name, networks := range tests {
+		config := Config{Eth: ethereum.Config{Apps: apps}, Networks: networks}
+		config.Sub.Targets = make(map[string][20]byte)
+		err := loadNetworks(&config, false)
+		assert.Error(t, err, name)
+	}
+
+	// read-only relays need no keys
+	config := Config{Networks: []NetworkConfig{{Name: "goerli"}}}
+	config.Sub.Targets = make(map[string][20]byte)
+	err := loadNetworks(&config, true)
+	assert.NoError(t, err)
+	assert.True(t, config.Networks[0].ReadOnly)
+}
+
+func TestRouter_Networks(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+	rollout, err := NewRollout(nil)
+	require.NoError(t, err)
+
+	router := NewRouter(messages, nil, rollout, nil, NewSequenceTracker(messages))
+	in := make(chan chain.Message, 3)
+	toEthereum := make(chan chain.Message, 3)
+	toSepolia := make(chan chain.Message, 3)
+	router.AddRoute("Substrate", DirectionToEthereum, in, toEthereum)
+	router.AddNetwork("sepolia", toSepolia)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	router.Start(ctx, eg)
+
+	unknown := chain.Message{AppID: [20]byte{3}, Payload: []byte{3}, Network: "goerli"}
+	in <- chain.Message{AppID: [20]byte{1}, Payload: []byte{1}}
+	in <- unknown
+	in <- chain.Message{AppID: [20]byte{2}, Payload: []byte{2}, Network: "sepolia"}
+
+	select {
+	case msg := <-toEthereum:
+		assert.Equal(t, []byte{1}, msg.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("message was not routed to ethereum")
+	}
+	select {
+	case msg := <-toSepolia:
+		assert.Equal(t, []byte{2}, msg.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("message was not routed to sepolia")
+	}
+
+	// messages for networks which are not configured are quarantined
+	record, err := messages.Lookup("Substrate", &unknown)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusQuarantined, record.Status)
+	assert.Equal(t, "network goerli is not configured", record.Reason)
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type OutboundConfig struct {
+	// Interval in seconds between checks of the transactions sent from the relayer accounts.
+	// Zero disables the watcher.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+// AccountNonces is implemented by chains which report how many transactions were sent from
+// the accounts of their writers
+type AccountNonces interface {
+	// AccountNonces returns the nonce of the next transaction of each account, by account
+	AccountNonces(ctx context.Context) (map[string]uint64, error)
+}
+
+// OutboundWatcher periodically compares the nonces of the relayer accounts against the
+// intents which the writers record before signing each transaction. A nonce used without
+// an intent was used by a transaction which the relayer did not originate, which raises a
+// critical alert, as it is an early sign that a key was stolen.
+type OutboundWatcher struct {
+	interval time.Duration
+	intents  *store.Intents
+	chains   map[string]AccountNonces
+}
+
+func NewOutboundWatcher(config *OutboundConfig, intents *store.Intents, chains []chain.Chain) *OutboundWatcher {
+	watched := make(map[string]AccountNonces)
+	for _, ch := range chains {
+		if accounts, ok := ch.(AccountNonces); ok {
+			watched[ch.Name()] = accounts
+		}
+	}
+
+	return &OutboundWatcher{
+		interval: time.Duration(config.Interval) * time.Second,
+		intents:  intents,
+		chains:   watched,
+	}
+}
+
+func (ow *OutboundWatcher) Task() Task {
+	return Task{Name: "outbound", Interval: ow.interval, Immediate: true, Run: ow.Check}
+}
+
+// Check compares the nonces of the accounts of all chains against their intents.
+// Unoriginated transactions raise alerts of their own, so an error is only returned for
+// chains which could not be checked.
+func (ow *OutboundWatcher) Check(ctx context.Context) error {
+	unchecked := 0
+	for name, accounts := range ow.chains {
+		_, err := ow.check(ctx, name, accounts)
+		if err != nil {
+			log.WithField("chain", name).WithError(err).Warn("Failed to check the transactions of the relayer accounts")
+			unchecked++
+		}
+	}
+
+	if unchecked > 0 {
+		return fmt.Errorf("%d of %d chains could not be checked", unchecked, len(ow.chains))
+	}
+	return nil
+}
+
+// check compares the nonces of the accounts of a chain against their intents, returning
+// the number of unoriginated transactions
+func (ow *OutboundWatcher) check(ctx context.Context, name string, accounts AccountNonces) (int, error) {
+	nonces, err := accounts.AccountNonces(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	unoriginated := 0
+	for account, next := range nonces {
+		fields := log.Fields{"chain": name, "account": account}
+
+		checked, ok, err := ow.intents.Checked(name, account)
+		if err != nil {
+			return unoriginated, err
+		}
+
+		// transactions sent before the account was first checked have no intents
+		if !ok || next < checked {
+			log.WithFields(fields).WithField("nonce", next).Debug("Started checking the transactions of the relayer account")
+			err = ow.intents.Advance(name, account, next)
+			if err != nil {
+				return unoriginated, err
+			}
+			continue
+		}
+
+		for nonce := checked; nonce < next; nonce++ {
+			intended, err := ow.intents.Intended(name, account, nonce)
+			if err != nil {
+				return unoriginated, err
+			}
+			if !intended {
+				unoriginated++
+				metrics.UnoriginatedTransactions.WithLabelValues(name).Inc()
+				log.WithFields(fields).WithField("nonce", nonce).Error("ALERT: transaction sent from the relayer account was not originated by the relayer, its key may be compromised")
+			}
+		}
+
+		err = ow.intents.Advance(name, account, next)
+		if err != nil {
+			return unoriginated, err
+		}
+	}
+	return unoriginated, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// accountChain reports the nonces of its relayer accounts
+type accountChain struct {
+	nonces map[string]uint64
+}
+
+func (ac *accountChain) Name() string                                        { return "Substrate" }
+func (ac *accountChain) Start(ctx context.Context, eg *errgroup.Group) error { return nil }
+func (ac *accountChain) Stop()                                               {}
+
+func (ac *accountChain) AccountNonces(ctx context.Context) (map[string]uint64, error) {
+	return ac.nonces, nil
+}
+
+func TestOutboundWatcher(t *testing.T) {
+	ctx := context.Background()
+	intents := store.NewIntents(store.NewMemoryDB())
+	ac := &accountChain{nonces: map[string]uint64{"alice": 5}}
+	watcher := NewOutboundWatcher(&OutboundConfig{Interval: 60}, intents, []chain.Chain{ac})
+
+	// transactions sent before the first check are not judged
+	unoriginated, err := watcher.check(ctx, "Substrate", ac)
+	require.NoError(t, err)
+	assert.Equal(t, 0, unoriginated)
+
+	require.NoError(t, intents.RecordIntent("Substrate", "alice", 5))
+	require.NoError(t, intents.RecordIntent("Substrate", "alice", 6))
+	ac.nonces["alice"] = 7
+	unoriginated, err = watcher.check(ctx, "Substrate", ac)
+	require.NoError(t, err)
+	assert.Equal(t, 0, unoriginated)
+
+	// nonce 7 was used without an intent
+	require.NoError(t, intents.RecordIntent("Substrate", "alice", 8))
+	ac.nonces["alice"] = 9
+	unoriginated, err = watcher.check(ctx, "Substrate", ac)
+	require.NoError(t, err)
+	assert.Equal(t, 1, unoriginated)
+
+	// checked intents are forgotten
+	intended, err := intents.Intended("Substrate", "alice", 8)
+	require.NoError(t, err)
+	assert.False(t, intended)
+	checked, ok, err := intents.Checked("Substrate", "alice")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(9), checked)
+}
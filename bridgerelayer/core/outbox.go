@@ -0,0 +1,108 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultMaxRedeliveries = 5
+
+// Outbox keeps the messages routed to the writers until their delivery is confirmed on the
+// target chain or given up, so that messages which were queued or in flight when the relayer
+// stopped are delivered again once it restarts. Delivery is at least once: a message whose
+// confirmation was not observed before a restart is submitted again, so apps must tolerate
+// receiving a message twice. Messages which are still not confirmed after several restarts
+// are given up and marked as skipped in the message store.
+type Outbox struct {
+	outbox          *store.Outbox
+	messages        *store.Messages
+	maxRedeliveries int
+}
+
+func NewOutbox(config *store.OutboxConfig, db store.DB, messages *store.Messages) *Outbox {
+	maxRedeliveries := config.MaxRedeliveries
+	if maxRedeliveries <= 0 {
+		maxRedeliveries = defaultMaxRedeliveries
+	}
+
+	return &Outbox{
+		outbox:          store.NewOutbox(db),
+		messages:        messages,
+		maxRedeliveries: maxRedeliveries,
+	}
+}
+
+// Add persists a message routed in a direction until its delivery is acknowledged
+func (ob *Outbox) Add(direction string, msg *chain.Message) error {
+	return ob.outbox.Add(direction, msg)
+}
+
+// Redeliver returns the unacknowledged messages routed in a direction, in the order in which
+// they were recorded, counting their redelivery. Messages which exhausted their redeliveries
+// are skipped instead.
+func (ob *Outbox) Redeliver(direction string) ([]chain.Message, error) {
+	entries, err := ob.outbox.Pending(direction)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []chain.Message{}
+	for _, entry := range entries {
+		msg := entry.Message
+		fields := log.Fields{
+			"direction":    direction,
+			"messageID":    msg.ID,
+			"redeliveries": entry.Redeliveries,
+		}
+
+		if entry.Redeliveries >= ob.maxRedeliveries {
+			log.WithFields(fields).Error("Gave up message whose delivery was never confirmed")
+			err = ob.Skip(msg, fmt.Sprintf("not confirmed after %d redeliveries", entry.Redeliveries))
+			if err != nil {
+				log.WithError(err).WithFields(fields).Error("Failed to record skipped message")
+			}
+			continue
+		}
+
+		_, err = ob.outbox.Redelivered(msg.ID)
+		if err != nil {
+			return nil, err
+		}
+		log.WithFields(fields).Info("Redelivering message whose delivery was not confirmed")
+		messages = append(messages, *msg)
+	}
+
+	return messages, nil
+}
+
+func (ob *Outbox) Submitted(msg *chain.Message, receipt *chain.Receipt) {}
+
+// Confirmed acknowledges a message once its delivery was included in a block
+func (ob *Outbox) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	ob.ack(msg)
+}
+
+// Skip records a message whose delivery was given up in the message store, and acknowledges it
+func (ob *Outbox) Skip(msg *chain.Message, reason string) error {
+	err := ob.messages.Skip(msg, reason)
+	ob.ack(msg)
+	return err
+}
+
+func (ob *Outbox) ack(msg *chain.Message) {
+	if msg.ID == "" {
+		return
+	}
+
+	err := ob.outbox.Ack(msg.ID)
+	if err != nil {
+		log.WithError(err).WithField("messageID", msg.ID).Error("Failed to acknowledge message in outbox")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestOutbox_Redeliver(t *testing.T) {
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	outbox := NewOutbox(&store.OutboxConfig{MaxRedeliveries: 2}, db, messages)
+
+	lock := chain.Message{
+		AppID: [20]byte{1},
+		Payload: ethereum.Message{
+			Data: []byte{1, 2, 3},
+			VerificationInput: ethereum.VerificationInput{
+				IsBasic: true,
+				AsBasic: ethereum.VerificationBasic{BlockNumber: 938, EventIndex: 4},
+			},
+		},
+	}
+	record, err := messages.Record("Ethereum", &lock, store.StatusRouted)
+	require.NoError(t, err)
+	lock.ID = record.ID
+	lock.Sequence = record.Sequence
+
+	deposit := chain.Message{AppID: [20]byte{1}, Payload: ethereum.Message{Data: []byte{4}, VerificationInput: ethereum.VerificationInput{IsNone: true}}}
+	record, err = messages.Record("Ethereum", &deposit, store.StatusRouted)
+	require.NoError(t, err)
+	deposit.ID = record.ID
+	deposit.Sequence = record.Sequence
+
+	require.NoError(t, outbox.Add(DirectionToSubstrate, &lock))
+	require.NoError(t, outbox.Add(DirectionToSubstrate, &deposit))
+
+	// confirmed messages are not delivered again
+	outbox.Confirmed(&deposit, &chain.Receipt{Chain: "Substrate"})
+
+	for i := 0; i < 2; i++ {
+		pending, err := outbox.Redeliver(DirectionToSubstrate)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, lock, pending[0])
+	}
+
+	// until they exhaust their redeliveries, and are skipped
+	pending, err := outbox.Redeliver(DirectionToSubstrate)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	record, err = messages.Get(lock.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusSkipped, record.Status)
+	assert.Equal(t, "not confirmed after 2 redeliveries", record.Reason)
+
+	pending, err = outbox.Redeliver(DirectionToSubstrate)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
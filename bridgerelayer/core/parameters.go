@@ -0,0 +1,274 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ParameterConfig struct {
+	// Interval in seconds between checks of the parameters. Zero disables the guard.
+	Interval uint64             `mapstructure:"interval"`
+	Watch    []WatchedParameter `mapstructure:"watch"`
+}
+
+// WatchedParameter is an on-chain parameter which the relayer depends on, read either
+// through the getter of an Ethereum contract or from a Substrate storage item
+type WatchedParameter struct {
+	// Name of the parameter in logs and metrics
+	Name string `mapstructure:"name"`
+	// App name or address of the contract, and its getter, such as fee() or isRelayer(address)
+	Contract string `mapstructure:"contract"`
+	Method   string `mapstructure:"method"`
+	// Storage item, as Module.Item or Module.Item(account), instead of a contract getter
+	Storage string `mapstructure:"storage"`
+	// Setting which follows the parameter: confirmations or membership. Parameters without a
+	// kind, or of kind fee, are only watched.
+	Kind string `mapstructure:"kind"`
+	// Change in percent of the previous value which is only logged as a warning, while larger
+	// changes raise an alert. Any change raises an alert if zero.
+	MaxChange float64 `mapstructure:"max-change"`
+}
+
+const (
+	// ParameterFee is a fee of a channel, which is only watched
+	ParameterFee = "fee"
+	// ParameterConfirmations are the confirmations which the receiving chain requires of
+	// Ethereum events, which the Ethereum listener waits for if they exceed its depth
+	ParameterConfirmations = "confirmations"
+	// ParameterMembership is the membership of the relayer in the relayer set, whose writer
+	// on the chain holding the parameter is halted while the parameter is zero
+	ParameterMembership = "membership"
+	// gate reason of the writers of relayers removed from a relayer set, followed by the
+	// name of the parameter
+	reasonRelayerSet = "removed from relayer set"
+)
+
+// ContractParameters is implemented by chains whose contracts hold parameters
+type ContractParameters interface {
+	ContractParameter(ctx context.Context, contract string, method string) (*big.Int, error)
+}
+
+// StorageParameters is implemented by chains whose storage holds parameters
+type StorageParameters interface {
+	StorageParameter(ctx context.Context, storage string) (*big.Int, error)
+}
+
+// ConfirmationRequirer is implemented by chains whose events can be held for the
+// confirmations required by the receiving chain
+type ConfirmationRequirer interface {
+	RequireConfirmations(confirmations uint64)
+}
+
+// ParameterGuard keeps a long-running relayer consistent with the on-chain parameters it
+// depends on, which governance may change. Each change is logged, and raises an alert
+// unless it is within the tolerated rate of change. The settings which follow a parameter
+// are reloaded where this is safe: Ethereum events wait for the confirmations required of
+// them, never fewer than the finality depth, and the writer of a relayer removed from the
+// relayer set halts until it is added again.
+type ParameterGuard struct {
+	interval   time.Duration
+	parameters []*guardedParameter
+	contracts  ContractParameters
+	storage    StorageParameters
+	required   ConfirmationRequirer
+	// writer gates by chain name
+	gates map[string]*chain.Gate
+}
+
+type guardedParameter struct {
+	WatchedParameter
+	// chain holding the parameter
+	chain string
+	// last value read, nil until the parameter was read
+	value *big.Int
+	// whether the parameter halted the writer of its chain
+	halted bool
+}
+
+func NewParameterGuard(config *ParameterConfig, contracts ContractParameters, storage StorageParameters, required ConfirmationRequirer, gates map[string]*chain.Gate) (*ParameterGuard, error) {
+	names := make(map[string]bool)
+	parameters := make([]*guardedParameter, 0, len(config.Watch))
+	for _, watched := range config.Watch {
+		if watched.Name == "" {
+			return nil, fmt.Errorf("missing name of watched parameter")
+		}
+		if names[watched.Name] {
+			return nil, fmt.Errorf("duplicate watched parameter %s", watched.Name)
+		}
+		names[watched.Name] = true
+
+		parameter := &guardedParameter{WatchedParameter: watched}
+		switch {
+		case watched.Storage != "" && watched.Method == "" && watched.Contract == "":
+			parameter.chain = substrate.Name
+		case watched.Storage == "" && watched.Method != "" && watched.Contract != "":
+			parameter.chain = ethereum.Name
+		default:
+			return nil, fmt.Errorf("watched parameter %s must have either a contract and method, or a storage item", watched.Name)
+		}
+
+		switch watched.Kind {
+		case "", ParameterFee, ParameterConfirmations, ParameterMembership:
+		default:
+			return nil, fmt.Errorf("unknown kind of watched parameter %s: %s", watched.Name, watched.Kind)
+		}
+		if watched.MaxChange < 0 {
+			return nil, fmt.Errorf("invalid max change of watched parameter %s: %v", watched.Name, watched.MaxChange)
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	return &ParameterGuard{
+		interval:   time.Duration(config.Interval) * time.Second,
+		parameters: parameters,
+		contracts:  contracts,
+		storage:    storage,
+		required:   required,
+		gates:      gates,
+	}, nil
+}
+
+// Task checks the parameters as soon as the scheduler starts, so that the settings following
+// them are reloaded shortly after the chains start
+func (pg *ParameterGuard) Task() Task {
+	return Task{Name: "parameters", Interval: pg.interval, Immediate: true, Run: pg.Check}
+}
+
+// Check reads the parameters, alerting of their changes and reloading the settings which
+// follow them. Settings keep following the last values read of parameters which can't be
+// read, for which an error is returned.
+func (pg *ParameterGuard) Check(ctx context.Context) error {
+	unread := 0
+	for _, parameter := range pg.parameters {
+		fields := log.Fields{
+			"parameter": parameter.Name,
+			"chain":     parameter.chain,
+		}
+
+		value, err := pg.read(ctx, parameter)
+		if err != nil {
+			log.WithFields(fields).WithError(err).Warn("Failed to read on-chain parameter")
+			unread++
+			continue
+		}
+		pg.observe(parameter, value, fields)
+	}
+
+	pg.reload()
+
+	if unread > 0 {
+		return fmt.Errorf("%d of %d parameters could not be read", unread, len(pg.parameters))
+	}
+	return nil
+}
+
+func (pg *ParameterGuard) read(ctx context.Context, parameter *guardedParameter) (*big.Int, error) {
+	if parameter.chain == substrate.Name {
+		return pg.storage.StorageParameter(ctx, parameter.Storage)
+	}
+	return pg.contracts.ContractParameter(ctx, parameter.Contract, parameter.Method)
+}
+
+// observe records the value read of a parameter, logging it if it changed
+func (pg *ParameterGuard) observe(parameter *guardedParameter, value *big.Int, fields log.Fields) {
+	previous := parameter.value
+	parameter.value = value
+	value64, _ := new(big.Float).SetInt(value).Float64()
+	metrics.OnChainParameter.WithLabelValues(parameter.Name).Set(value64)
+
+	if previous == nil {
+		log.WithFields(fields).WithField("value", value.String()).Info("Read on-chain parameter")
+		return
+	}
+	if previous.Cmp(value) == 0 {
+		return
+	}
+
+	metrics.ParameterChanges.WithLabelValues(parameter.Name).Inc()
+	fields["previous"] = previous.String()
+	fields["value"] = value.String()
+	if exceedsChange(previous, value, parameter.MaxChange) {
+		log.WithFields(fields).Error("ALERT: on-chain parameter changed")
+	} else {
+		log.WithFields(fields).Warn("On-chain parameter changed within its tolerated rate of change")
+	}
+}
+
+// exceedsChange returns whether a value differs from the previous one by more than a
+// percentage of it. Changes from zero exceed any percentage.
+func exceedsChange(previous *big.Int, value *big.Int, maxChange float64) bool {
+	if maxChange == 0 || previous.Sign() == 0 {
+		return true
+	}
+
+	change := new(big.Rat).SetInt(new(big.Int).Sub(value, previous))
+	change.Quo(change, new(big.Rat).SetInt(previous))
+	change.Abs(change)
+	limit := new(big.Rat).SetFloat64(maxChange / 100)
+	return change.Cmp(limit) > 0
+}
+
+// reload applies the last values read of the parameters to the settings following them
+func (pg *ParameterGuard) reload() {
+	var confirmations *big.Int
+	for _, parameter := range pg.parameters {
+		if parameter.value == nil {
+			continue
+		}
+
+		switch parameter.Kind {
+		case ParameterConfirmations:
+			// the events wait for the most confirmations required of them
+			if confirmations == nil || parameter.value.Cmp(confirmations) > 0 {
+				confirmations = parameter.value
+			}
+		case ParameterMembership:
+			pg.gate(parameter)
+		}
+	}
+
+	if confirmations != nil && pg.required != nil {
+		if !confirmations.IsUint64() {
+			log.WithField("confirmations", confirmations.String()).Error("ALERT: required confirmations are out of range, keeping the previous requirement")
+			return
+		}
+		pg.required.RequireConfirmations(confirmations.Uint64())
+	}
+}
+
+// gate halts the writer of the chain holding a membership parameter while it is zero
+func (pg *ParameterGuard) gate(parameter *guardedParameter) {
+	gate, ok := pg.gates[parameter.chain]
+	if !ok {
+		return
+	}
+
+	fields := log.Fields{
+		"parameter": parameter.Name,
+		"chain":     parameter.chain,
+	}
+	reason := reasonRelayerSet + ": " + parameter.Name
+	member := parameter.value.Sign() != 0
+	switch {
+	case !member && !parameter.halted:
+		gate.Halt(reason)
+		log.WithFields(fields).Error("ALERT: relayer is not a member of the relayer set, halted submissions")
+	case member && parameter.halted:
+		gate.Release(reason)
+		log.WithFields(fields).Warn("Relayer is a member of the relayer set again, resumed submissions")
+	}
+	parameter.halted = !member
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+)
+
+type mockParameters struct {
+	values        map[string]*big.Int
+	confirmations uint64
+}
+
+func (mp *mockParameters) ContractParameter(ctx context.Context, contract string, method string) (*big.Int, error) {
+	return mp.value(contract + "." + method)
+}
+
+func (mp *mockParameters) StorageParameter(ctx context.Context, storage string) (*big.Int, error) {
+	return mp.value(storage)
+}
+
+func (mp *mockParameters) value(key string) (*big.Int, error) {
+	value, ok := mp.values[key]
+	if !ok {
+		return nil, fmt.Errorf("no value of %s", key)
+	}
+	return value, nil
+}
+
+func (mp *mockParameters) RequireConfirmations(confirmations uint64) {
+	mp.confirmations = confirmations
+}
+
+func TestParameterGuard(t *testing.T) {
+	params := &mockParameters{values: map[string]*big.Int{
+		"eth.fee()":                      big.NewInt(100),
+		"eth.isRelayer(address)":         big.NewInt(1),
+		"Verifier.RequiredConfirmations": big.NewInt(20),
+		"eth.requiredConfirmations()":    big.NewInt(10),
+	}}
+	gates := map[string]*chain.Gate{
+		ethereum.Name:  chain.NewGate(),
+		substrate.Name: chain.NewGate(),
+	}
+
+	guard, err := NewParameterGuard(&ParameterConfig{Interval: 60, Watch: []WatchedParameter{
+		{Name: "fee", Contract: "eth", Method: "fee()", Kind: ParameterFee, MaxChange: 10},
+		{Name: "relayers", Contract: "eth", Method: "isRelayer(address)", Kind: ParameterMembership},
+		{Name: "verifier", Storage: "Verifier.RequiredConfirmations", Kind: ParameterConfirmations},
+		{Name: "confirmations", Contract: "eth", Method: "requiredConfirmations()", Kind: ParameterConfirmations},
+	}}, params, params, params, gates)
+	require.NoError(t, err)
+
+	// the events wait for the most confirmations required of them
+	require.NoError(t, guard.Check(context.Background()))
+	assert.Equal(t, uint64(20), params.confirmations)
+	halted, _ := gates[ethereum.Name].Halted()
+	assert.False(t, halted)
+
+	// removal from the relayer set halts the writer of the chain holding it
+	params.values["eth.isRelayer(address)"] = big.NewInt(0)
+	params.values["Verifier.RequiredConfirmations"] = big.NewInt(5)
+	require.NoError(t, guard.Check(context.Background()))
+	assert.Equal(t, uint64(10), params.confirmations)
+	halted, reasons := gates[ethereum.Name].Halted()
+	assert.True(t, halted)
+	assert.Contains(t, reasons, "relayers")
+	halted, _ = gates[substrate.Name].Halted()
+	assert.False(t, halted)
+
+	// parameters which can't be read keep their settings
+	delete(params.values, "eth.isRelayer(address)")
+	assert.Error(t, guard.Check(context.Background()))
+	halted, _ = gates[ethereum.Name].Halted()
+	assert.True(t, halted)
+
+	params.values["eth.isRelayer(address)"] = big.NewInt(1)
+	require.NoError(t, guard.Check(context.Background()))
+	halted, _ = gates[ethereum.Name].Halted()
+	assert.False(t, halted)
+}
+
+func TestNewParameterGuard_Invalid(t *testing.T) {
+	for name, watched := range map[string]WatchedParameter{
+		"no name":       {Contract: "eth", Method: "fee()"},
+		"no source":     {Name: "fee"},
+		"both sources":  {Name: "fee", Contract: "eth", Method: "fee()", Storage: "Fees.Fee"},
+		"unknown kind":  {Name: "fee", Contract: "eth", Method: "fee()", Kind: "price"},
+		"negative rate": {Name: "fee", Contract: "eth", Method: "fee()", MaxChange: -1},
+	} {
+		_, err := NewParameterGuard(&ParameterConfig{Watch: []WatchedParameter{watched}}, nil, nil, nil, nil)
+		assert.Error(t, err, name)
+	}
+
+	_, err := NewParameterGuard(&ParameterConfig{Watch: []WatchedParameter{
+		{Name: "fee", Contract: "eth", Method: "fee()"},
+		{Name: "fee", Storage: "Fees.Fee"},
+	}}, nil, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestExceedsChange(t *testing.T) {
+	assert.False(t, exceedsChange(big.NewInt(100), big.NewInt(110), 10))
+	assert.False(t, exceedsChange(big.NewInt(100), big.NewInt(90), 10))
+	assert.True(t, exceedsChange(big.NewInt(100), big.NewInt(111), 10))
+	// any change exceeds a rate of zero, and any change from zero exceeds a rate
+	assert.True(t, exceedsChange(big.NewInt(100), big.NewInt(101), 0))
+	assert.True(t, exceedsChange(big.NewInt(0), big.NewInt(1), 50))
+}
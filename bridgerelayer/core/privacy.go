@@ -0,0 +1,169 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/ss58"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type PrivacyConfig struct {
+	// How account addresses and amounts are masked in logs, support bundles and webhook
+	// payloads: redact to remove them, or hash to replace them with keyed hashes, so that the
+	// entries of an account can still be correlated. Kept in full if empty.
+	Mode string `mapstructure:"mode"`
+	// Secret mixed into the hashes, so that the hashes of known addresses can't be computed
+	// by whoever reads the logs
+	Salt string `mapstructure:"salt"`
+	// File to which the full detail of each log entry and observed event is appended,
+	// encrypted with the key in ARTEMIS_AUDIT_KEY. Disabled if empty.
+	AuditLog string `mapstructure:"audit-log"`
+	// Hex-encoded AES-256 key of the audit log
+	AuditKey string `mapstructure:"audit-key"`
+}
+
+const (
+	privacyRedact = "redact"
+	privacyHash   = "hash"
+	// hex characters of the hash kept in place of a masked value
+	privacyHashLength = 16
+)
+
+// privateNames are the parts of the names of log and event fields holding the accounts and
+// amounts of users
+var privateNames = []string{"sender", "recipient", "beneficiary", "account", "amount"}
+
+var (
+	// addressPattern matches Ethereum addresses within text
+	addressPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+	// ss58Pattern matches text which may be an SS58 address, checked by decoding it
+	ss58Pattern = regexp.MustCompile(`\b[1-9A-HJ-NP-Za-km-z]{46,48}\b`)
+)
+
+// Privacy masks the accounts and amounts of users wherever the relay exposes them outside
+// of the encrypted audit log. Fields are masked by name, and Ethereum and SS58 addresses
+// are masked wherever they appear in text, including those of contracts.
+type Privacy struct {
+	mode string
+	salt []byte
+}
+
+// NewPrivacy returns the masking of a privacy configuration, nil if disabled
+func NewPrivacy(config *PrivacyConfig) (*Privacy, error) {
+	switch config.Mode {
+	case "":
+		return nil, nil
+	case privacyRedact, privacyHash:
+		return &Privacy{mode: config.Mode, salt: []byte(config.Salt)}, nil
+	default:
+		return nil, fmt.Errorf("unknown privacy mode %q, expected %s or %s", config.Mode, privacyRedact, privacyHash)
+	}
+}
+
+// isPrivate returns whether a log or event field of the name may hold the account or the
+// amount of a user
+func isPrivate(name string) bool {
+	name = strings.ToLower(name)
+	for _, private := range privateNames {
+		if strings.Contains(name, private) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask replaces a value, hashing it case-insensitively so that the checksummed and lower
+// case forms of an address hash alike
+func (pr *Privacy) mask(value string) string {
+	if pr.mode == privacyRedact {
+		return redacted
+	}
+	mac := hmac.New(sha256.New, pr.salt)
+	mac.Write([]byte(strings.ToLower(value)))
+	return "hash:" + hex.EncodeToString(mac.Sum(nil))[:privacyHashLength]
+}
+
+// Text masks the addresses within text
+func (pr *Privacy) Text(text string) string {
+	text = addressPattern.ReplaceAllStringFunc(text, pr.mask)
+	return ss58Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if _, _, err := ss58.Decode(match); err != nil {
+			return match
+		}
+		return pr.mask(match)
+	})
+}
+
+// Field masks the value of a log or event field, entirely if its name is private, and
+// otherwise the addresses within its text and those of any nested fields
+func (pr *Privacy) Field(name string, value interface{}) interface{} {
+	if isPrivate(name) && value != nil && value != "" {
+		return pr.mask(fmt.Sprint(value))
+	}
+
+	switch value := value.(type) {
+	case string:
+		return pr.Text(value)
+	case error:
+		text := value.Error()
+		if masked := pr.Text(text); masked != text {
+			return masked
+		}
+		return value
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			masked[name] = pr.Field(name, item)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(value))
+		for i, item := range value {
+			masked[i] = pr.Field(name, item)
+		}
+		return masked
+	default:
+		return value
+	}
+}
+
+// Event returns a copy of an observed event with its fields masked
+func (pr *Privacy) Event(event *chain.ObservedEvent) *chain.ObservedEvent {
+	masked := *event
+	masked.Fields = make(map[string]interface{}, len(event.Fields))
+	for name, value := range event.Fields {
+		masked.Fields[name] = pr.Field(name, value)
+	}
+	return &masked
+}
+
+// Formatter wraps a log formatter so that entries are masked as they are written. Hooks,
+// such as the audit log, still receive the entries in full.
+func (pr *Privacy) Formatter(formatter log.Formatter) log.Formatter {
+	return &privacyFormatter{formatter: formatter, privacy: pr}
+}
+
+type privacyFormatter struct {
+	formatter log.Formatter
+	privacy   *Privacy
+}
+
+func (pf *privacyFormatter) Format(entry *log.Entry) ([]byte, error) {
+	masked := *entry
+	masked.Message = pf.privacy.Text(entry.Message)
+	masked.Data = make(log.Fields, len(entry.Data))
+	for name, value := range entry.Data {
+		masked.Data[name] = pf.privacy.Field(name, value)
+	}
+	return pf.formatter.Format(&masked)
+}
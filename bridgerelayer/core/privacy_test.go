@@ -0,0 +1,140 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+const (
+	testAddress  = "0x89b4AB1eF20763630df9743ACF155865600daFF2"
+	testSS58     = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	testAuditKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+)
+
+func TestPrivacy(t *testing.T) {
+	privacy, err := NewPrivacy(&PrivacyConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, privacy)
+
+	_, err = NewPrivacy(&PrivacyConfig{Mode: "scramble"})
+	assert.Error(t, err)
+
+	privacy, err = NewPrivacy(&PrivacyConfig{Mode: "redact"})
+	require.NoError(t, err)
+	assert.Equal(t, "sent by [redacted] to [redacted].", privacy.Text("sent by "+testAddress+" to "+testSS58+"."))
+	assert.Equal(t, redacted, privacy.Field("_amount", "1000"))
+	assert.Equal(t, "", privacy.Field("recipient", ""))
+
+	// hashes of an address are keyed by the salt and ignore its case
+	privacy, err = NewPrivacy(&PrivacyConfig{Mode: "hash", Salt: "pepper"})
+	require.NoError(t, err)
+	hashed := privacy.Text(testAddress)
+	assert.True(t, strings.HasPrefix(hashed, "hash:"))
+	assert.Equal(t, hashed, privacy.Text(strings.ToLower(testAddress)))
+	salted, err := NewPrivacy(&PrivacyConfig{Mode: "hash", Salt: "salt"})
+	require.NoError(t, err)
+	assert.NotEqual(t, hashed, salted.Text(testAddress))
+
+	// text which merely looks like an SS58 address is kept
+	assert.Equal(t, "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQz", privacy.Text("5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQz"))
+
+	event := &chain.ObservedEvent{
+		Chain: "Ethereum",
+		Fields: map[string]interface{}{
+			"_sender": testAddress,
+			"_amount": "1000",
+			"_token":  testAddress,
+			"nonce":   uint64(7),
+		},
+	}
+	masked := privacy.Event(event)
+	assert.Equal(t, hashed, masked.Fields["_sender"])
+	assert.Equal(t, hashed, masked.Fields["_token"])
+	assert.Equal(t, uint64(7), masked.Fields["nonce"])
+	assert.NotEqual(t, "1000", masked.Fields["_amount"])
+	assert.Equal(t, testAddress, event.Fields["_sender"])
+
+	err = errors.New("transfer from " + testAddress + " failed")
+	assert.Equal(t, "transfer from "+hashed+" failed", privacy.Field("error", err))
+	plain := errors.New("refused")
+	assert.Equal(t, plain, privacy.Field("error", plain))
+}
+
+func TestPrivacy_Logs(t *testing.T) {
+	privacy, err := NewPrivacy(&PrivacyConfig{Mode: "redact"})
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "privacy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	audit, err := NewAuditLog(path.Join(dir, "audit.log"), testAuditKey)
+	require.NoError(t, err)
+	logs := NewLogBuffer(&SupportConfig{}, privacy)
+
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&output)
+	logger.SetFormatter(privacy.Formatter(&logrus.JSONFormatter{}))
+	logger.AddHook(audit)
+	logger.AddHook(logs)
+
+	logger.WithFields(logrus.Fields{
+		"recipient": testSS58,
+		"amount":    "1000",
+		"app":       "ETH",
+	}).Info("Delivered transfer from " + testAddress)
+	audit.Observed(&chain.ObservedEvent{Chain: "Ethereum", Fields: map[string]interface{}{"_sender": testAddress}})
+	require.NoError(t, audit.Close())
+
+	// the written logs and the support bundles are masked
+	assert.NotContains(t, output.String(), testAddress)
+	assert.NotContains(t, output.String(), testSS58)
+	assert.NotContains(t, output.String(), "1000")
+	assert.Contains(t, output.String(), "ETH")
+	entries := logs.RecentLogs(0)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Delivered transfer from "+redacted, entries[0].Message)
+	assert.Equal(t, redacted, entries[0].Fields["recipient"])
+
+	// while the audit log keeps them in full, encrypted
+	data, err := ioutil.ReadFile(path.Join(dir, "audit.log"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), testAddress)
+
+	var records []*AuditRecord
+	err = ReadAuditLog(bytes.NewReader(data), testAuditKey, func(record *AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "Delivered transfer from "+testAddress, records[0].Message)
+	assert.Equal(t, testSS58, records[0].Fields["recipient"])
+	assert.Equal(t, "1000", records[0].Fields["amount"])
+	require.NotNil(t, records[1].Event)
+	assert.Equal(t, testAddress, records[1].Event.Fields["_sender"])
+
+	// and can't be read with another key
+	err = ReadAuditLog(bytes.NewReader(data), strings.Repeat("ff", 32), func(record *AuditRecord) error {
+		return nil
+	})
+	assert.Error(t, err)
+
+	_, err = NewAuditLog(path.Join(dir, "other.log"), "0xdeadbeef")
+	assert.Error(t, err)
+}
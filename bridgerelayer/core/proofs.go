@@ -0,0 +1,57 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// Provable is implemented by chains which can rebuild the message of an event
+type Provable interface {
+	EventMessage(ctx context.Context, number uint64, index uint64) (*chain.Message, error)
+}
+
+// Proof rebuilds the message which the relayer submits for the event at an index of a
+// block of a chain. The bridge verifies messages by the block and index of their event,
+// which the payload carries, so the message is all that users need to relay it.
+func (re *Relay) Proof(ctx context.Context, name string, block uint64, index uint64) (*api.Proof, error) {
+	for _, ch := range re.chains {
+		if !strings.EqualFold(ch.Name(), name) {
+			continue
+		}
+
+		provable, ok := ch.(Provable)
+		if !ok {
+			return nil, fmt.Errorf("chain %s does not support proofs", ch.Name())
+		}
+
+		msg, err := provable.EventMessage(ctx, block, index)
+		if err != nil {
+			return nil, err
+		}
+
+		id, payload, err := store.MessageID(ch.Name(), msg)
+		if err != nil {
+			return nil, err
+		}
+
+		return &api.Proof{
+			ID:          id,
+			Source:      ch.Name(),
+			BlockNumber: block,
+			Index:       index,
+			AppID:       hex.EncodeToString(msg.AppID[:]),
+			Payload:     hex.EncodeToString(payload),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown chain: %s", name)
+}
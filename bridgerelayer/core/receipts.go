@@ -0,0 +1,32 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+// ReceiptLogs fans out delivery receipts to several receipt logs
+type ReceiptLogs []chain.ReceiptLog
+
+func (rl ReceiptLogs) Submitted(msg *chain.Message, receipt *chain.Receipt) {
+	for _, log := range rl {
+		log.Submitted(msg, receipt)
+	}
+}
+
+func (rl ReceiptLogs) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	for _, log := range rl {
+		log.Confirmed(msg, receipt)
+	}
+}
+
+// EventFeeds fans out observed events to several event feeds
+type EventFeeds []chain.EventFeed
+
+func (ef EventFeeds) Observed(event *chain.ObservedEvent) {
+	for _, feed := range ef {
+		feed.Observed(event)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+const redacted = "[redacted]"
+
+// secretNames are the parts of the names of settings and log fields holding secrets
+var secretNames = []string{"key", "secret", "password", "token", "mnemonic", "seed", "phrase", "salt", "headers"}
+
+// urlPattern matches URLs within text, whose paths and queries may embed API keys
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// isSecret returns whether a setting or log field of the name may hold a secret
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText reduces the URLs within text to their scheme and host, keeping any punctuation
+// which follows them
+func redactText(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, func(match string) string {
+		trimmed := strings.TrimRight(match, ".,;:!?)]}")
+		return chain.RedactEndpoint(trimmed) + match[len(trimmed):]
+	})
+}
+
+// redactSettings returns a copy of decoded settings, with the values of secret settings
+// replaced and URLs reduced to their scheme and host
+func redactSettings(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			if isSecret(name) && item != nil && item != "" {
+				copied[name] = redacted
+				continue
+			}
+			copied[name] = redactSettings(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = redactSettings(item)
+		}
+		return copied
+	case []map[string]interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = redactSettings(item)
+		}
+		return copied
+	case string:
+		return redactText(value)
+	default:
+		return value
+	}
+}
@@ -13,9 +13,15 @@ import (
 	"syscall"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
 	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/secp256k1"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/pricing"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/secrets"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
 	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 
@@ -25,93 +31,788 @@ import (
 )
 
 type Relay struct {
-	chains []chain.Chain
+	chains    []chain.Chain
+	startup   *Startup
+	scheduler *Scheduler
+	kill      *KillSwitch
+	archiver  *Archiver
+	attestor  *Attestor
+	notifier  *Notifier
+	watcher   *Watcher
+	tracer    *tracing.Tracer
+	previous  *tracing.Tracer
+	rotation  *SecretRotation
+	audit     *AuditLog
+	logger    *loggerState
+	router    *Router
+	api       *api.Server
+	status    *api.StatusServer
+	health    *api.HealthServer
+	db        store.DB
+	blocks    *store.Blocks
+	// channels from which the writers of each chain read messages
+	toEthereum  chan chain.Message
+	toSubstrate chan chain.Message
+	// nil without subscriptions to the observed events
+	subscriptions *Subscriptions
 }
 
 type Config struct {
-	Eth ethereum.Config  `mapstructure:"ethereum"`
-	Sub substrate.Config `mapstructure:"substrate"`
+	Eth         ethereum.Config   `mapstructure:"ethereum"`
+	Sub         substrate.Config  `mapstructure:"substrate"`
+	Invariant   InvariantConfig   `mapstructure:"invariant"`
+	Parameters  ParameterConfig   `mapstructure:"parameters"`
+	Holes       HoleConfig        `mapstructure:"holes"`
+	Outbound    OutboundConfig    `mapstructure:"outbound"`
+	Replication ReplicationConfig `mapstructure:"replication"`
+	Archive     ArchiveConfig     `mapstructure:"archive"`
+	Attestation AttestationConfig `mapstructure:"attestation"`
+	Snapshot    SnapshotConfig    `mapstructure:"snapshot"`
+	Store       store.Config      `mapstructure:"store"`
+	API         api.Config        `mapstructure:"api"`
+	Status      api.StatusConfig  `mapstructure:"status"`
+	Health      api.HealthConfig  `mapstructure:"health"`
+	Tracing     tracing.Config    `mapstructure:"tracing"`
+	Identity    IdentityConfig    `mapstructure:"identity"`
+	Keystore    KeystoreConfig    `mapstructure:"keystore"`
+	Secrets     secrets.Config    `mapstructure:"secrets"`
+	Pricing     pricing.Config    `mapstructure:"pricing"`
+	Webhooks    []WebhookConfig   `mapstructure:"webhooks"`
+	Watch       []WatchConfig     `mapstructure:"watch"`
+	Duplicates  DuplicateConfig   `mapstructure:"duplicates"`
+	Stats       StatsConfig       `mapstructure:"stats"`
+	Accounting  AccountingConfig  `mapstructure:"accounting"`
+	SelfRelay   SelfRelayConfig   `mapstructure:"self-relay"`
+	Scheduler   SchedulerConfig   `mapstructure:"scheduler"`
+	Startup     StartupConfig     `mapstructure:"startup"`
+	KillSwitch  KillSwitchConfig  `mapstructure:"kill-switch"`
+	Support     SupportConfig     `mapstructure:"support"`
+	Privacy     PrivacyConfig     `mapstructure:"privacy"`
+	// Subscriptions of webhooks and embedding services to the observed events
+	Subscriptions SubscriptionConfig `mapstructure:"subscriptions"`
+	// Delivery of the messages to particular recipients, overriding that of their app
+	Overrides []chain.OverrideConfig `mapstructure:"overrides"`
+	// Further chains, of the types registered with the chain package
+	Chains []ChainConfig `mapstructure:"chains"`
+	// Further Ethereum networks to which the messages of their apps are delivered
+	Networks []NetworkConfig `mapstructure:"ethereum-networks"`
+	// Fee parameters of the incentivized channels, by channel, replayed by the fee-replay command
+	Incentives map[string]IncentiveConfig `mapstructure:"incentives"`
+	// Provider and secrets from which the configuration was completed, nil if it was built
+	// otherwise
+	secretProvider secrets.Provider
+	secretSet      *secrets.Set
 }
 
 func NewRelay() (*Relay, error) {
+	return newRelay(false)
+}
+
+// NewExplorer creates a relay in explorer mode, which runs the listeners, the message
+// store and the APIs without any writers. Messages are recorded as observed instead of
+// being delivered, so that the bridge can be monitored without submission keys.
+func NewExplorer() (*Relay, error) {
+	return newRelay(true)
+}
+
+func newRelay(explorer bool) (*Relay, error) {
+	config, err := readConfig(explorer)
+	if err != nil {
+		return nil, err
+	}
+	return buildRelay(config, &Options{Explorer: explorer})
+}
+
+// buildRelay creates the services of a relay from its configuration, without starting them
+func buildRelay(config *Config, options *Options) (_ *Relay, err error) {
+	explorer := options.Explorer
+
+	// the formatter and hooks of the standard logger are restored once the relay stops, or
+	// if it can't be built, as are the tracer and the store opened for the relay
+	logger := saveLogger()
+	var audit *AuditLog
+	var db store.DB
+	var opened bool
+	var previous *tracing.Tracer
+	var installed bool
+	defer func() {
+		if err == nil {
+			return
+		}
+		if installed {
+			tracing.Install(previous)
+		}
+		if opened {
+			db.Close()
+		}
+		logger.restore()
+		if audit != nil {
+			audit.Close()
+		}
+	}()
+
+	// a single direction is relayed by the listener of its source chain and the writer of its
+	// target chain alone
+	switch options.Direction {
+	case "":
+	case DirectionToEthereum:
+		config.Sub.ReadOnly = true
+		config.Eth.WriteOnly = true
+	case DirectionToSubstrate:
+		config.Eth.ReadOnly = true
+		config.Sub.WriteOnly = true
+	default:
+		return nil, fmt.Errorf("unknown direction %s", options.Direction)
+	}
+
+	// channels for messages observed by the listeners of each chain
+	fromEthereum := make(chan chain.Message, 1)
+	fromSubstrate := make(chan chain.Message, 1)
+
+	// channels for messages routed to the writers of each chain
+	toEthereum := make(chan chain.Message, 1)
+	toSubstrate := make(chan chain.Message, 1)
+
+	// users' accounts and amounts are masked from the logs as they are written, while the
+	// audit log hooks into them in full
+	privacy, err := NewPrivacy(&config.Privacy)
+	if err != nil {
+		return nil, err
+	}
+	if privacy != nil {
+		log.SetFormatter(privacy.Formatter(log.StandardLogger().Formatter))
+	}
+
+	if config.Privacy.AuditLog != "" {
+		audit, err = NewAuditLog(config.Privacy.AuditLog, config.Privacy.AuditKey)
+		if err != nil {
+			return nil, err
+		}
+		log.AddHook(audit)
+	}
+
+	// the relayer's Ethereum key identifies it and signs attestations, snapshots and heartbeats.
+	// Explorers without a key and relayers whose key is held by a remote signer use a
+	// throwaway one, so their ID changes with each start.
+	var ethKey *secp256k1.Keypair
+	if config.Eth.PrivateKey == "" && (explorer || config.Eth.RemoteSigner != nil) {
+		ethKey, err = secp256k1.GenerateKeypair()
+	} else {
+		ethKey, err = secp256k1.NewKeypairFromString(config.Eth.PrivateKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := NewIdentity(&config.Identity, ethKey, config)
+	if err != nil {
+		return nil, err
+	}
 
-	// channel for messages from ethereum
-	ethMessages := make(chan chain.Message, 1)
+	// a store passed by the caller is theirs to close if the relay can't be built
+	db = options.DB
+	if db == nil {
+		db, err = store.Open(&config.Store)
+		if err != nil {
+			return nil, err
+		}
+		opened = true
+	}
+	messages := store.NewMessages(db)
+	blocks := store.NewBlocks(db)
 
-	// channel for messages from substrate
-	subMessages := make(chan chain.Message, 1)
+	seeded, err := messages.SeedJournal()
+	if err != nil {
+		return nil, err
+	}
+	if seeded > 0 {
+		log.WithField("records", seeded).Info("Appended the records of the store to the journal")
+	}
 
-	config, err := loadConfig()
+	cursors, err := store.OpenCursors(&config.Store, db)
 	if err != nil {
 		return nil, err
 	}
 
-	ethChain, err := ethereum.NewChain(&config.Eth, ethMessages, subMessages)
+	services := &chain.Services{
+		Quarantine:  messages,
+		Blocks:      blocks,
+		Checkpoints: store.NewCheckpoints(db),
+		Cursors:     cursors,
+		Skipped:     messages,
+		Invalidated: messages,
+	}
+
+	diagnostics := store.NewDiagnostics(db)
+	services.Diagnostics = diagnostics
+
+	// writers record their transactions before signing them, if transactions from the relayer
+	// accounts are watched
+	var intents *store.Intents
+	if config.Outbound.Interval > 0 && !explorer {
+		intents = store.NewIntents(db)
+		services.Intents = intents
+	}
+
+	converter, err := pricing.NewConverter(&config.Pricing, log.WithField("service", "pricing"))
 	if err != nil {
 		return nil, err
 	}
+	if converter != nil {
+		services.Pricer = converter
+	}
+
+	var receipts ReceiptLogs
 
-	subChain, err := substrate.NewChain(&config.Sub, ethMessages, subMessages)
+	// spans are started by the listeners and writers through the installed tracer
+	tracer, err := tracing.NewTracer(&config.Tracing, log.WithField("service", "tracing"))
 	if err != nil {
 		return nil, err
 	}
+	previous, installed = tracing.Install(tracer), true
+	if tracer != nil {
+		receipts = append(receipts, NewTraceRecorder())
+	}
+
+	var archiver *Archiver
+	if config.Archive.URL != "" {
+		archiver, err = NewArchiver(&config.Archive)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, archiver)
+	}
+
+	var attestor *Attestor
+	if config.Attestation.Endpoint != "" && !explorer {
+		attestor = NewAttestor(&config.Attestation, ethKey)
+		receipts = append(receipts, attestor)
+	}
+
+	var feeds EventFeeds
+	if audit != nil {
+		feeds = append(feeds, audit)
+	}
+
+	// the journal records every change of the relay's state, of which the statistics are a
+	// projection
+	journal := NewJournalRecorder(messages.Journal())
+	receipts = append(receipts, journal)
+	feeds = append(feeds, journal)
+
+	// the hooks of a relay embedded in another service observe the same events and deliveries
+	receipts = append(receipts, options.Receipts...)
+	feeds = append(feeds, options.Events...)
+
+	stats := store.NewStats(db, config.Stats.Retention)
+	if config.Stats.Retention > 0 {
+		messages.Journal().Project(NewStatsRecorder(stats))
+	}
+
+	accounting := store.NewAccounting(db)
+	if config.Accounting.Enabled {
+		messages.Journal().Project(NewAccountingRecorder(accounting))
+	}
+
+	// delivered sequence numbers are followed when deliveries are confirmed anyway, or for
+	// the status feed
+	sequences := NewSequenceTracker(messages)
+	if len(receipts) > 0 || config.Status.Address != "" {
+		receipts = append(receipts, sequences)
+	}
+
+	// messages are persisted until their delivery is confirmed if the store outlives restarts
+	var outbox *Outbox
+	if config.Store.Path != "" && !explorer {
+		outbox = NewOutbox(&config.Store.Outbox, db, messages)
+		receipts = append(receipts, outbox)
+		services.Skipped = outbox
+	}
+
+	if len(receipts) > 0 {
+		services.Receipts = receipts
+	}
 
-	return &Relay{
-		chains: []chain.Chain{ethChain, subChain},
-	}, nil
+	// consumers of subscriptions read the observed events back from the journal, at their
+	// own pace
+	consumers := make(map[string]EventConsumer)
+	for name, consumer := range options.Subscribers {
+		consumers[name] = consumer
+	}
+
+	var notifier *Notifier
+	if len(config.Webhooks) > 0 {
+		webhooks, err := NewNotifier(config.Webhooks, privacy)
+		if err != nil {
+			return nil, err
+		}
+		if config.Subscriptions.Enabled {
+			for name, consumer := range webhooks.Consumers() {
+				if _, ok := consumers[name]; ok {
+					return nil, fmt.Errorf("subscription %s is already registered", name)
+				}
+				consumers[name] = consumer
+			}
+		} else {
+			notifier = webhooks
+			feeds = append(feeds, notifier)
+		}
+	}
+
+	var subscriptions *Subscriptions
+	if len(consumers) > 0 {
+		subscriptions, err = NewSubscriptions(&config.Subscriptions, messages.Journal(), store.NewCursors(db), consumers)
+		if err != nil {
+			return nil, err
+		}
+		messages.Journal().Project(subscriptions)
+	}
+
+	var watcher *Watcher
+	if len(config.Watch) > 0 {
+		watcher, err = NewWatcher(config.Watch, messages, privacy)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, watcher)
+	}
+
+	if len(feeds) > 0 {
+		services.Events = feeds
+	}
+
+	rollout, err := NewRollout(networkApps(config))
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := NewDuplicateFilter(&config.Duplicates, networkApps(config), messages, store.NewEvents(db))
+
+	router := NewRouter(messages, archiver, rollout, duplicates, sequences)
+	services.ConsumerStopped = router.Stopped()
+	if outbox != nil {
+		router.Persist(outbox)
+	}
+
+	// requeued messages are routed again through the channel of their source chain
+	var deadLetters *DeadLetterQueue
+	if !explorer {
+		sources := map[string]chan<- chain.Message{
+			ethereum.Name:  fromSubstrate,
+			substrate.Name: fromEthereum,
+		}
+		// messages can't be requeued to the writer of a disabled direction
+		switch options.Direction {
+		case DirectionToEthereum:
+			delete(sources, substrate.Name)
+		case DirectionToSubstrate:
+			delete(sources, ethereum.Name)
+		}
+		deadLetters, err = NewDeadLetterQueue(db, messages, outbox, sources, router.Stopped())
+		if err != nil {
+			return nil, err
+		}
+		services.DeadLetters = deadLetters
+	}
+
+	ethChain, err := ethereum.NewChain(&config.Eth, fromEthereum, toEthereum, services)
+	if err != nil {
+		return nil, err
+	}
+
+	subChain, err := substrate.NewChain(&config.Sub, toSubstrate, fromSubstrate, services)
+	if err != nil {
+		return nil, err
+	}
+
+	// further chains aren't started when a single direction is relayed
+	var further []routedChain
+	if options.Direction == "" {
+		further, err = buildChains(config.Chains, services)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// explorers have no writers to deliver to further networks
+	var networks []routedNetwork
+	if !explorer && options.Direction != DirectionToSubstrate {
+		networks, err = buildNetworks(config.Networks, services)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chains := []chain.Chain{ethChain, subChain}
+	names := []string{ethChain.Name(), subChain.Name()}
+	for _, rc := range further {
+		chains = append(chains, rc.chain)
+		names = append(names, rc.chain.Name())
+	}
+	for i, rn := range networks {
+		chains = append(chains, rn.network)
+		router.AddNetwork(config.Networks[i].Name, rn.in)
+	}
+
+	scheduler := NewScheduler(&config.Scheduler)
+
+	if config.Invariant.Interval > 0 {
+		invariants, err := NewInvariantChecker(&config.Invariant, ethChain, subChain)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.Add(invariants.Task())
+	}
+
+	if explorer {
+		router.AddRoute(ethChain.Name(), DirectionToSubstrate, fromEthereum, nil)
+		router.AddRoute(subChain.Name(), DirectionToEthereum, fromSubstrate, nil)
+	} else {
+		router.AddRoute(ethChain.Name(), DirectionToSubstrate, fromEthereum, toSubstrate)
+		router.AddRoute(subChain.Name(), DirectionToEthereum, fromSubstrate, toEthereum)
+	}
+	for _, rc := range further {
+		var out chan<- chain.Message
+		switch {
+		case explorer:
+			// explorers only record the messages as observed
+		case rc.direction == DirectionToEthereum:
+			out = toEthereum
+		default:
+			out = toSubstrate
+		}
+		router.AddRoute(rc.chain.Name(), rc.direction, rc.observed, out)
+	}
+
+	if config.Duplicates.EventRetention > 0 {
+		scheduler.Add(duplicates.Task())
+	}
+
+	if intents != nil {
+		outbound := NewOutboundWatcher(&config.Outbound, intents, chains)
+		scheduler.Add(outbound.Task())
+	}
+
+	if config.Holes.Interval > 0 {
+		holes := NewHoleDetector(&config.Holes, blocks, names)
+		scheduler.Add(holes.Task())
+	}
+
+	if config.Replication.Interval > 0 {
+		replicator, err := NewReplicator(&config.Replication, db)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.Add(replicator.Task())
+	}
+
+	if config.Snapshot.Interval > 0 {
+		publisher, err := NewPublisher(&config.Snapshot, db, ethKey, names)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.Add(publisher.Task())
+	}
+
+	if config.Identity.Heartbeat > 0 && !explorer && !config.Sub.ReadOnly {
+		heartbeat := NewHeartbeater(&config.Identity, identity, ethKey, subChain)
+		scheduler.Add(heartbeat.Task())
+	}
+
+	// further chains are halted by the kill switch, probed, and have their balances and the
+	// heads of their endpoints checked if they support it
+	gates := make(map[string]*chain.Gate)
+	var healthSources []api.HealthSource
+	var statusSources []api.StatusSource
+	for _, ch := range chains {
+		if g, ok := ch.(gated); ok {
+			gates[ch.Name()] = g.WriterGate()
+		}
+		if source, ok := ch.(api.HealthSource); ok {
+			healthSources = append(healthSources, source)
+		}
+		if source, ok := ch.(api.StatusSource); ok {
+			statusSources = append(statusSources, source)
+		}
+		if monitored, ok := ch.(BalanceMonitored); ok && monitored.BalanceInterval() > 0 {
+			scheduler.Add(balanceTask(ch.Name(), monitored))
+		}
+		if monitored, ok := ch.(DivergenceMonitored); ok && monitored.Divergence() != nil {
+			scheduler.Add(divergenceTask(ch.Name(), monitored.Divergence()))
+		}
+	}
+	kill := NewKillSwitch(&config.KillSwitch, gates)
+
+	if config.Parameters.Interval > 0 {
+		guard, err := NewParameterGuard(&config.Parameters, ethChain, subChain, ethChain, gates)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.Add(guard.Task())
+	}
+
+	var rotation *SecretRotation
+	if config.Secrets.RotationInterval > 0 && config.secretSet != nil {
+		rotation = NewSecretRotation(&config.Secrets, config.secretProvider, config.secretSet)
+	}
+
+	relay := &Relay{
+		chains:      chains,
+		startup:     NewStartup(&config.Startup),
+		scheduler:   scheduler,
+		kill:        kill,
+		archiver:    archiver,
+		attestor:    attestor,
+		notifier:    notifier,
+		watcher:     watcher,
+		tracer:      tracer,
+		previous:    previous,
+		rotation:    rotation,
+		audit:       audit,
+		logger:      logger,
+		router:      router,
+		db:          db,
+		blocks:      blocks,
+		toEthereum:  toEthereum,
+		toSubstrate: toSubstrate,
+	}
+	relay.subscriptions = subscriptions
+
+	if config.API.Address != "" {
+		// recent logs are kept for support bundles, which collect them through the admin API
+		logs := NewLogBuffer(&config.Support, privacy)
+		log.AddHook(logs)
+		var queue api.DeadLetters
+		if deadLetters != nil {
+			queue = deadLetters
+		}
+		relay.api = api.NewServer(&config.API, messages, stats, accounting, relay, relay, rollout, kill, logs, queue, relay, diagnostics, log.WithField("service", "api"))
+	}
+
+	if config.Health.Address != "" {
+		relay.health = api.NewHealthServer(&config.Health, healthSources, log.WithField("service", "health"))
+	}
+
+	if config.Status.Address != "" {
+		// explorers have no writers to deliver self-relayed messages, and relays of a single
+		// direction no listener to observe them on the other chain
+		var relayer api.SelfRelayer
+		if config.SelfRelay.Enabled && !explorer && options.Direction == "" {
+			sources := map[string]chan<- chain.Message{
+				ethChain.Name(): fromEthereum,
+				subChain.Name(): fromSubstrate,
+			}
+			for _, rc := range further {
+				sources[rc.chain.Name()] = rc.observed
+			}
+			relayer, err = NewSelfRelay(&config.SelfRelay, relay.chains, sources, router.Stopped(), messages, duplicates, store.NewPayments(db), ethChain)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var tasks api.Tasks
+		if !scheduler.Empty() {
+			tasks = scheduler
+		}
+
+		relay.status = api.NewStatusServer(&config.Status, identity, statusSources, sequences, tasks, relayer, log.WithField("service", "status"))
+	}
+
+	return relay, nil
 }
 
+// Start runs the relay until a fatal error is raised or SIGINT or SIGTERM is received
 func (re *Relay) Start() {
-
 	ctx, cancel := context.WithCancel(context.Background())
-	eg, ctx := errgroup.WithContext(ctx)
+	defer cancel()
 
 	// Ensure clean termination upon SIGINT, SIGTERM
-	eg.Go(func() error {
-		notify := make(chan os.Signal, 1)
-		signal.Notify(notify, syscall.SIGINT, syscall.SIGTERM)
-
+	notify := make(chan os.Signal, 1)
+	signal.Notify(notify, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(notify)
+	go func() {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
 		case sig := <-notify:
 			log.WithField("signal", sig.String()).Info("Received signal")
 			cancel()
-
 		}
+	}()
 
+	err := re.Run(ctx)
+	if errors.Is(err, ErrSecretsRotated) {
+		log.Info("Stopped for a restart with the rotated secrets")
+	} else if err != nil {
+		log.WithField("error", err).Error("Encountered an unrecoverable failure")
+	}
+}
+
+// Run starts the relay and blocks until ctx is done or a service fails, then stops the
+// chains and closes the store. It returns nil once ctx is done, and the failure otherwise.
+func (re *Relay) Run(ctx context.Context) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+
+	err := re.start(ctx, eg)
+	if err != nil {
+		cancel()
+	}
+
+	// Wait until a fatal error is raised or ctx is done
+	waitErr := eg.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	re.stop()
+	if parent.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
 		return nil
-	})
+	}
+	return err
+}
 
+// stop shuts down chains, closes the store and restores the standard logger and tracer
+func (re *Relay) stop() {
 	for _, chain := range re.chains {
-		err := chain.Start(ctx, eg)
+		chain.Stop()
+	}
+
+	err := re.db.Close()
+	if err != nil {
+		log.WithError(err).Error("Failed to close store")
+	}
+
+	// the tracer installed before the relay's is restored
+	tracing.Install(re.previous)
+
+	// the audit log is closed once its hook is removed
+	if re.logger != nil {
+		re.logger.restore()
+	}
+	if re.audit != nil {
+		err = re.audit.Close()
+		if err != nil {
+			log.WithError(err).Error("Failed to close audit log")
+		}
+	}
+}
+
+// start launches all chains and background services into the errgroup
+func (re *Relay) start(ctx context.Context, eg *errgroup.Group) error {
+	// probes answer while the chains connect, reporting them as not ready
+	if re.health != nil {
+		err := re.health.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the kill switch file applies before the writers submit anything
+	if re.kill.Enabled() {
+		re.kill.Check()
+		re.kill.Start(ctx, eg)
+	}
+
+	if re.tracer != nil {
+		re.tracer.Start(ctx, eg)
+	}
+
+	// relayers restarted together wait for their own time to connect to shared providers
+	err := re.startup.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, chain := range re.chains {
+		if i > 0 {
+			err = re.startup.Stagger(ctx, chain.Name())
+			if err != nil {
+				return err
+			}
+		}
+
+		err = chain.Start(ctx, eg)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"chain": chain.Name(),
 				"error": err,
 			}).Error("Failed to start chain")
-			return
+			return err
 		}
 		log.WithField("name", chain.Name()).Info("Started chain")
 	}
 
-	// Wait until a fatal error or signal is raised
-	if err := eg.Wait(); err != nil {
-		if !errors.Is(err, context.Canceled) {
-			log.WithField("error", err).Error("Encountered an unrecoverable failure")
+	re.router.Start(ctx, eg)
+
+	re.scheduler.Start(ctx, eg)
+
+	if re.archiver != nil {
+		re.archiver.Start(ctx, eg)
+	}
+
+	if re.attestor != nil {
+		re.attestor.Start(ctx, eg)
+	}
+
+	if re.notifier != nil {
+		re.notifier.Start(ctx, eg)
+	}
+
+	if re.watcher != nil {
+		re.watcher.Start(ctx, eg)
+	}
+
+	if re.subscriptions != nil {
+		err := re.subscriptions.Start(ctx, eg)
+		if err != nil {
+			return err
 		}
 	}
 
-	// Shutdown chains
-	for _, chain := range re.chains {
-		chain.Stop()
+	if re.rotation != nil {
+		re.rotation.Start(ctx, eg)
 	}
+
+	if re.api != nil {
+		err := re.api.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if re.status != nil {
+		err := re.status.Start(ctx, eg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func loadConfig() (*Config, error) {
+// configFile is the configuration file set with SetConfigFile, which is otherwise looked
+// up in the default locations
+var configFile string
+
+// SetConfigFile sets the file from which the configuration is read
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// readConfigFile reads the configuration file from the user's config directory or the
+// working directory, unless another file was set
+func readConfigFile() error {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		viper.SetConfigType("toml")
+		return viper.ReadInConfig()
+	}
+
 	home, err := homedir.Dir()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	viper.AddConfigPath(path.Join(home, ".config", "artemis-relay"))
@@ -120,7 +821,17 @@ func loadConfig() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("toml")
 
-	err = viper.ReadInConfig()
+	return viper.ReadInConfig()
+}
+
+func loadConfig() (*Config, error) {
+	return readConfig(false)
+}
+
+// readConfig loads the configuration and the keys of the relayer, which are optional
+// for read-only relays, whose chains are then configured without writers
+func readConfig(readOnly bool) (*Config, error) {
+	err := readConfigFile()
 	if err != nil {
 		return nil, err
 	}
@@ -131,21 +842,82 @@ func loadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	// Load secrets from environment variables
+	// Load secrets from the secret provider, the environment by default, unless the keys
+	// are kept in keystores
+	provider, err := secrets.NewProvider(&config.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+	set, err := secrets.Read(ctx, provider)
+	if err != nil {
+		return nil, fmt.Errorf("read secrets: %w", err)
+	}
+	config.secretProvider = provider
+	config.secretSet = set
+
 	var value string
 	var ok bool
 
-	value, ok = os.LookupEnv("ARTEMIS_ETHEREUM_KEY")
-	if !ok {
-		return nil, fmt.Errorf("environment variable not set: ARTEMIS_ETHEREUM_KEY")
+	if config.Eth.RemoteSigner != nil && config.Keystore.Ethereum != "" {
+		return nil, fmt.Errorf("the Ethereum key cannot be read from a keystore when it is held by a remote signer")
+	}
+
+	if config.Keystore.Ethereum == "" && config.Eth.RemoteSigner == nil {
+		value, ok = set.Lookup("ARTEMIS_ETHEREUM_KEY")
+		if !ok && !readOnly {
+			return nil, set.Missing("ARTEMIS_ETHEREUM_KEY")
+		}
+		config.Eth.PrivateKey = value
+	}
+
+	if config.Keystore.Substrate == "" {
+		value, ok = set.Lookup("ARTEMIS_SUBSTRATE_KEY")
+		if !ok && !readOnly {
+			return nil, set.Missing("ARTEMIS_SUBSTRATE_KEY")
+		}
+		config.Sub.PrivateKey = value
+	}
+
+	err = unlockKeystores(&config, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Privacy.AuditLog != "" {
+		value, ok = set.Lookup("ARTEMIS_AUDIT_KEY")
+		if !ok {
+			return nil, set.Missing("ARTEMIS_AUDIT_KEY")
+		}
+		config.Privacy.AuditKey = value
 	}
-	config.Eth.PrivateKey = value
 
-	value, ok = os.LookupEnv("ARTEMIS_SUBSTRATE_KEY")
-	if !ok {
-		return nil, fmt.Errorf("environment variable not set: ARTEMIS_SUBSTRATE_KEY")
+	// messages are rebuilt with the same encrypted payloads in read-only mode too
+	if len(config.Sub.Encryption) > 0 {
+		value, ok = set.Lookup("ARTEMIS_PAYLOAD_KEY")
+		if !ok {
+			return nil, set.Missing("ARTEMIS_PAYLOAD_KEY")
+		}
+		config.Sub.EncryptionKey = value
+	}
+
+	err = expandEndpoints(&config)
+	if err != nil {
+		return nil, err
 	}
-	config.Sub.PrivateKey = value
+
+	config.Eth.ReadOnly = readOnly
+	config.Sub.ReadOnly = readOnly
+
+	// Optional key for the account sponsoring meta-transactions
+	value, ok = set.Lookup("ARTEMIS_ETHEREUM_SPONSOR_KEY")
+	if ok {
+		config.Eth.SponsorKey = value
+	}
+
+	config.Eth.Overrides = config.Overrides
+	config.Sub.Overrides = config.Overrides
 
 	// Copy over Ethereum application addresses to the Substrate config
 	config.Sub.Targets = make(map[string][20]byte)
@@ -153,5 +925,10 @@ func loadConfig() (*Config, error) {
 		config.Sub.Targets[k] = common.HexToAddress(v.Address)
 	}
 
+	err = loadNetworks(&config, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,116 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ReplicationConfig struct {
+	objectstore.Config `mapstructure:",squash"`
+	// Interval in seconds between snapshots. Zero disables replication.
+	Interval uint64 `mapstructure:"interval"`
+}
+
+// snapshotKey is the object under which the latest snapshot of the store is replicated
+const snapshotKey = "snapshots/latest.gz"
+
+// Replicator periodically uploads a snapshot of the store to object storage, from
+// where a standby instance in another region can be promoted without a cold resync
+type Replicator struct {
+	interval time.Duration
+	db       store.DB
+	bucket   objectstore.Bucket
+}
+
+func NewReplicator(config *ReplicationConfig, db store.DB) (*Replicator, error) {
+	bucket, err := objectstore.Open(&config.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replicator{
+		interval: time.Duration(config.Interval) * time.Second,
+		db:       db,
+		bucket:   bucket,
+	}, nil
+}
+
+// Task replicates the store periodically, and replicates its final state on shutdown
+func (rp *Replicator) Task() Task {
+	return Task{Name: "replication", Interval: rp.interval, Final: true, Run: rp.replicate}
+}
+
+func (rp *Replicator) replicate(ctx context.Context) error {
+	var buffer bytes.Buffer
+	err := store.WriteSnapshot(rp.db, &buffer)
+	if err != nil {
+		return fmt.Errorf("snapshot store: %w", err)
+	}
+
+	err = rp.bucket.Put(ctx, snapshotKey, buffer.Bytes())
+	if err != nil {
+		return fmt.Errorf("replicate store snapshot: %w", err)
+	}
+
+	log.WithField("size", buffer.Len()).Debug("Replicated store snapshot")
+	return nil
+}
+
+// Promote restores the latest replicated snapshot into the local store, so that
+// this instance can take over relaying. The local store must be empty unless forced.
+func Promote(force bool) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.Replication.URL == "" {
+		return fmt.Errorf("replication is not configured")
+	}
+
+	bucket, err := objectstore.Open(&config.Replication.Config)
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(&config.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	empty, err := store.IsEmpty(db)
+	if err != nil {
+		return err
+	}
+	if !empty && !force {
+		return fmt.Errorf("local store is not empty, refusing to overwrite it")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	snapshot, err := bucket.Get(ctx, snapshotKey)
+	if err != nil {
+		return fmt.Errorf("fetch snapshot: %w", err)
+	}
+
+	err = store.RestoreSnapshot(db, bytes.NewReader(snapshot))
+	if err != nil {
+		return err
+	}
+
+	log.WithField("size", len(snapshot)).Info("Restored store from replicated snapshot")
+
+	return nil
+}
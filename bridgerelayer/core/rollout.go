@@ -0,0 +1,117 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+)
+
+const (
+	// DirectionToSubstrate relays events of Ethereum apps, such as locks, to Substrate
+	DirectionToSubstrate = "ethereum-to-substrate"
+	// DirectionToEthereum relays events of Substrate, such as burns, to Ethereum apps
+	DirectionToEthereum = "substrate-to-ethereum"
+)
+
+// Rollout tracks in which directions the messages of each app are relayed, so that new
+// apps can be rolled out one direction at a time. Directions can be toggled at runtime,
+// starting from those disabled in the configuration of each app.
+type Rollout struct {
+	mutex sync.RWMutex
+	names map[[20]byte]string
+	// disabled apps by direction
+	disabled map[string]map[string]bool
+}
+
+func NewRollout(apps map[string]ethereum.Application) (*Rollout, error) {
+	rl := &Rollout{
+		names: make(map[[20]byte]string),
+		disabled: map[string]map[string]bool{
+			DirectionToSubstrate: make(map[string]bool),
+			DirectionToEthereum:  make(map[string]bool),
+		},
+	}
+
+	for name, app := range apps {
+		rl.names[common.HexToAddress(app.Address)] = name
+		for _, direction := range app.DisabledDirections {
+			disabled, ok := rl.disabled[direction]
+			if !ok {
+				return nil, fmt.Errorf("unknown direction %s disabled for app %s", direction, name)
+			}
+			disabled[name] = true
+		}
+	}
+
+	return rl, nil
+}
+
+// Enabled returns the name of the app with the given ID, and whether its messages are
+// relayed in a direction. Messages of unknown apps are always relayed.
+func (rl *Rollout) Enabled(direction string, appID [20]byte) (string, bool) {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+
+	name, ok := rl.names[appID]
+	if !ok {
+		return "", true
+	}
+	return name, !rl.disabled[direction][name]
+}
+
+// SetEnabled enables or disables relaying the messages of an app in a direction
+func (rl *Rollout) SetEnabled(app string, direction string, enabled bool) error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	disabled, ok := rl.disabled[direction]
+	if !ok {
+		return fmt.Errorf("unknown direction %s", direction)
+	}
+	if !rl.known(app) {
+		return fmt.Errorf("unknown app %s", app)
+	}
+
+	if enabled {
+		delete(disabled, app)
+	} else {
+		disabled[app] = true
+	}
+	return nil
+}
+
+// Apps returns the enabled directions of each app, sorted by name
+func (rl *Rollout) Apps() []api.AppRollout {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+
+	result := []api.AppRollout{}
+	for _, name := range rl.names {
+		directions := make(map[string]bool)
+		for direction, disabled := range rl.disabled {
+			directions[direction] = !disabled[name]
+		}
+		result = append(result, api.AppRollout{App: name, Directions: directions})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].App < result[j].App
+	})
+	return result
+}
+
+func (rl *Rollout) known(app string) bool {
+	for _, name := range rl.names {
+		if name == app {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+)
+
+func TestRollout(t *testing.T) {
+	eth := common.HexToAddress("0x01")
+	erc20 := common.HexToAddress("0x02")
+
+	rollout, err := NewRollout(map[string]ethereum.Application{
+		"eth":   {Address: eth.Hex()},
+		"erc20": {Address: erc20.Hex(), DisabledDirections: []string{DirectionToSubstrate}},
+	})
+	require.NoError(t, err)
+
+	name, ok := rollout.Enabled(DirectionToSubstrate, erc20)
+	assert.Equal(t, "erc20", name)
+	assert.False(t, ok)
+
+	_, ok = rollout.Enabled(DirectionToEthereum, erc20)
+	assert.True(t, ok)
+	_, ok = rollout.Enabled(DirectionToSubstrate, eth)
+	assert.True(t, ok)
+
+	// messages of unknown apps are relayed
+	_, ok = rollout.Enabled(DirectionToSubstrate, common.HexToAddress("0x03"))
+	assert.True(t, ok)
+
+	apps := rollout.Apps()
+	require.Len(t, apps, 2)
+	assert.Equal(t, "erc20", apps[0].App)
+	assert.Equal(t, map[string]bool{DirectionToSubstrate: false, DirectionToEthereum: true}, apps[0].Directions)
+
+	// directions are toggled at runtime
+	require.NoError(t, rollout.SetEnabled("erc20", DirectionToSubstrate, true))
+	require.NoError(t, rollout.SetEnabled("eth", DirectionToEthereum, false))
+	_, ok = rollout.Enabled(DirectionToSubstrate, erc20)
+	assert.True(t, ok)
+	_, ok = rollout.Enabled(DirectionToEthereum, eth)
+	assert.False(t, ok)
+
+	assert.Error(t, rollout.SetEnabled("unknown", DirectionToEthereum, true))
+	assert.Error(t, rollout.SetEnabled("eth", "sideways", true))
+}
+
+func TestNewRollout_UnknownDirection(t *testing.T) {
+	_, err := NewRollout(map[string]ethereum.Application{
+		"eth": {Address: "0x01", DisabledDirections: []string{"sideways"}},
+	})
+	assert.Error(t, err)
+}
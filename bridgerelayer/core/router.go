@@ -0,0 +1,261 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Router forwards messages from the listener of one chain to the writer of another,
+// recording each message in the message store on the way. Messages of apps which are
+// disabled in the direction of their route are quarantined instead. With an outbox, routed
+// messages are also persisted until their delivery is confirmed, and those which were not
+// confirmed before a restart are forwarded again first. Messages routed to Ethereum for a
+// further network are forwarded to the writer of that network instead.
+type Router struct {
+	routes []route
+	// channels of the writers of further Ethereum networks, by network
+	networks map[string]chan<- chain.Message
+	messages *store.Messages
+	archiver *Archiver
+	rollout  *Rollout
+	// nil if duplicates are not suppressed
+	duplicates *DuplicateFilter
+	sequences  *SequenceTracker
+	// nil if routed messages are not persisted
+	outbox *Outbox
+	// closed once any route stops forwarding, so that listeners stop waiting on it
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+type route struct {
+	source    string
+	direction string
+	in        <-chan chain.Message
+	out       chan<- chain.Message
+}
+
+// NewRouter creates a router which records messages in the store, and archives
+// them if an archiver is given. Duplicate messages are dropped if a filter is given.
+// The sequence numbers of recorded messages are reported to the tracker.
+func NewRouter(messages *store.Messages, archiver *Archiver, rollout *Rollout, duplicates *DuplicateFilter, sequences *SequenceTracker) *Router {
+	return &Router{
+		messages:   messages,
+		archiver:   archiver,
+		rollout:    rollout,
+		duplicates: duplicates,
+		sequences:  sequences,
+		networks:   make(map[string]chan<- chain.Message),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Stopped is closed once the router stops consuming the messages of the listeners
+func (ro *Router) Stopped() <-chan struct{} {
+	return ro.stopped
+}
+
+// AddRoute forwards messages observed on the source chain in a direction. If out is nil,
+// messages are only recorded as observed, as in explorer mode.
+func (ro *Router) AddRoute(source string, direction string, in <-chan chain.Message, out chan<- chain.Message) {
+	ro.routes = append(ro.routes, route{source: source, direction: direction, in: in, out: out})
+}
+
+// AddNetwork forwards the messages routed to Ethereum for a further network to its writer.
+// It must be added before starting.
+func (ro *Router) AddNetwork(network string, out chan<- chain.Message) {
+	ro.networks[network] = out
+}
+
+// Persist keeps routed messages in an outbox until their delivery is acknowledged. It must
+// be set before starting.
+func (ro *Router) Persist(outbox *Outbox) {
+	ro.outbox = outbox
+}
+
+func (ro *Router) Start(ctx context.Context, eg *errgroup.Group) {
+	for _, r := range ro.routes {
+		r := r
+		eg.Go(func() error {
+			defer ro.stopOnce.Do(func() {
+				close(ro.stopped)
+			})
+			return ro.forward(ctx, r)
+		})
+	}
+}
+
+func (ro *Router) forward(ctx context.Context, r route) error {
+	err := ro.redeliver(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-r.in:
+			if app, ok := ro.rollout.Enabled(r.direction, msg.AppID); !ok {
+				ro.quarantine(r, &msg, app)
+				continue
+			}
+
+			out, ok := ro.target(r, &msg)
+			if !ok {
+				ro.quarantineNetwork(r, &msg)
+				continue
+			}
+
+			if !msg.Replayed && ro.duplicate(r, &msg) {
+				continue
+			}
+
+			status := store.StatusRouted
+			if out == nil {
+				status = store.StatusObserved
+			}
+
+			record, err := ro.messages.Record(r.source, &msg, status)
+			if err != nil {
+				log.WithError(err).WithField("source", r.source).Warn("Failed to record message")
+			} else {
+				msg.ID = record.ID
+				msg.Sequence = record.Sequence
+				ro.sequences.Record(r.source, &msg)
+				log.WithFields(msg.LogFields()).WithFields(log.Fields{
+					"source":   r.source,
+					"replayed": msg.Replayed,
+				}).Debug("Routing message")
+			}
+
+			if ro.archiver != nil {
+				ro.archiver.ArchiveMessage(r.source, &msg)
+			}
+
+			if out == nil {
+				continue
+			}
+
+			if ro.outbox != nil && msg.ID != "" {
+				err = ro.outbox.Add(r.direction, &msg)
+				if err != nil {
+					log.WithError(err).WithField("messageID", msg.ID).Error("Failed to persist message in outbox")
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case out <- msg:
+			}
+		}
+	}
+}
+
+// target returns the channel to which a message of a route is forwarded, nil if it is only
+// recorded, and false if it is for a network which is not configured
+func (ro *Router) target(r route, msg *chain.Message) (chan<- chain.Message, bool) {
+	if r.out == nil || msg.Network == "" || r.direction != DirectionToEthereum {
+		return r.out, true
+	}
+	out, ok := ro.networks[msg.Network]
+	return out, ok
+}
+
+// redeliver forwards the messages of a route which were not confirmed before the relayer
+// stopped. Messages are forwarded as they were routed, without being recorded again.
+func (ro *Router) redeliver(ctx context.Context, r route) error {
+	if ro.outbox == nil || r.out == nil {
+		return nil
+	}
+
+	messages, err := ro.outbox.Redeliver(r.direction)
+	if err != nil {
+		log.WithError(err).WithField("direction", r.direction).Error("Failed to read outbox")
+		return nil
+	}
+
+	for _, msg := range messages {
+		// messages of a network which is no longer configured stay in the outbox until
+		// their redeliveries are exhausted
+		out, ok := ro.target(r, &msg)
+		if !ok {
+			log.WithFields(log.Fields{
+				"messageID": msg.ID,
+				"network":   msg.Network,
+			}).Warn("Kept message of unknown network in outbox")
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- msg:
+		}
+	}
+	return nil
+}
+
+// duplicate returns whether a message duplicates one already routed. Messages which
+// can't be checked are routed.
+func (ro *Router) duplicate(r route, msg *chain.Message) bool {
+	if ro.duplicates == nil {
+		return false
+	}
+
+	duplicate, err := ro.duplicates.Duplicate(r.source, msg)
+	if err != nil {
+		log.WithError(err).WithField("source", r.source).Warn("Failed to check for duplicate message")
+		return false
+	}
+
+	if duplicate {
+		log.WithField("source", r.source).Debug("Suppressed duplicate message")
+	}
+	return duplicate
+}
+
+// quarantine records a message of an app which is disabled in the direction of a route
+func (ro *Router) quarantine(r route, msg *chain.Message, app string) {
+	fields := log.Fields{
+		"source":    r.source,
+		"app":       app,
+		"direction": r.direction,
+	}
+
+	err := ro.messages.Quarantine(r.source, msg, fmt.Sprintf("app %s is disabled for %s", app, r.direction))
+	if err != nil {
+		log.WithError(err).WithFields(fields).Error("Failed to quarantine message of disabled app")
+		return
+	}
+
+	log.WithFields(fields).Warn("Quarantined message of app disabled for direction")
+}
+
+// quarantineNetwork records a message for an Ethereum network which is not configured
+func (ro *Router) quarantineNetwork(r route, msg *chain.Message) {
+	fields := log.Fields{
+		"source":  r.source,
+		"network": msg.Network,
+	}
+
+	err := ro.messages.Quarantine(r.source, msg, fmt.Sprintf("network %s is not configured", msg.Network))
+	if err != nil {
+		log.WithError(err).WithFields(fields).Error("Failed to quarantine message of unknown network")
+		return
+	}
+
+	log.WithFields(fields).Warn("Quarantined message of unknown network")
+}
@@ -0,0 +1,254 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SchedulerConfig struct {
+	// Share of its interval by which each run of a task is randomly delayed, so that the
+	// relayers of a fleet don't run their tasks in lockstep. Zero disables jitter.
+	Jitter float64 `mapstructure:"jitter"`
+	// Consecutive failures of a task after which an alert is raised. Defaults to 3.
+	AlertAfter int `mapstructure:"alert-after"`
+	// Settings of each task, by task name
+	Tasks map[string]TaskConfig `mapstructure:"tasks"`
+}
+
+type TaskConfig struct {
+	// Disables the task, even though the service it belongs to is configured
+	Disabled bool `mapstructure:"disabled"`
+}
+
+const (
+	defaultAlertAfter = 3
+	// time given to the final runs of tasks when the relayer shuts down
+	finalRunTimeout = 30 * time.Second
+)
+
+// Task is a periodic maintenance job hosted by the scheduler
+type Task struct {
+	Name     string
+	Interval time.Duration
+	// Whether the task first runs when the scheduler starts, rather than after an interval
+	Immediate bool
+	// Whether the task runs once more when the scheduler stops, to flush its state
+	Final bool
+	Run   func(ctx context.Context) error
+}
+
+type scheduledTask struct {
+	Task
+	mutex  sync.Mutex
+	status api.TaskStatus
+}
+
+// Scheduler runs the periodic maintenance tasks of the relayer. Runs are delayed by a random
+// jitter, and a run is skipped if the previous run of the task is still in progress. Tasks
+// which keep failing raise an alert.
+type Scheduler struct {
+	jitter     float64
+	alertAfter int
+	config     map[string]TaskConfig
+	tasks      []*scheduledTask
+}
+
+func NewScheduler(config *SchedulerConfig) *Scheduler {
+	alertAfter := config.AlertAfter
+	if alertAfter <= 0 {
+		alertAfter = defaultAlertAfter
+	}
+
+	return &Scheduler{
+		jitter:     config.Jitter,
+		alertAfter: alertAfter,
+		config:     config.Tasks,
+	}
+}
+
+// Add schedules a task, unless it is disabled in the configuration
+func (sc *Scheduler) Add(task Task) {
+	if sc.config[task.Name].Disabled {
+		log.WithField("task", task.Name).Info("Scheduled task is disabled")
+		return
+	}
+
+	sc.tasks = append(sc.tasks, &scheduledTask{
+		Task:   task,
+		status: api.TaskStatus{Name: task.Name},
+	})
+}
+
+// Empty returns whether no tasks are scheduled
+func (sc *Scheduler) Empty() bool {
+	return len(sc.tasks) == 0
+}
+
+func (sc *Scheduler) Start(ctx context.Context, eg *errgroup.Group) {
+	for _, task := range sc.tasks {
+		task := task
+		eg.Go(func() error {
+			return sc.loop(ctx, task)
+		})
+	}
+}
+
+// Tasks returns the status of all scheduled tasks, ordered by name
+func (sc *Scheduler) Tasks() []api.TaskStatus {
+	statuses := make([]api.TaskStatus, 0, len(sc.tasks))
+	for _, task := range sc.tasks {
+		task.mutex.Lock()
+		statuses = append(statuses, task.status)
+		task.mutex.Unlock()
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+func (sc *Scheduler) loop(ctx context.Context, task *scheduledTask) error {
+	var wait time.Duration
+	if !task.Immediate {
+		wait = sc.delay(task.Interval)
+	}
+	task.scheduled(time.Now().Add(wait))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	// runs happen outside the loop, which keeps its schedule while a run is in progress
+	var runs sync.WaitGroup
+
+	for {
+		select {
+		case <-ctx.Done():
+			runs.Wait()
+			if task.Final {
+				finalCtx, cancel := context.WithTimeout(context.Background(), finalRunTimeout)
+				sc.run(finalCtx, task)
+				cancel()
+			}
+			return ctx.Err()
+		case <-timer.C:
+			if task.begin() {
+				runs.Add(1)
+				go func() {
+					defer runs.Done()
+					sc.run(ctx, task)
+				}()
+			} else {
+				metrics.TaskRuns.WithLabelValues(task.Name, "skipped").Inc()
+				log.WithField("task", task.Name).Warn("Skipped scheduled task, as its previous run is still in progress")
+			}
+
+			wait = sc.delay(task.Interval)
+			task.scheduled(time.Now().Add(wait))
+			timer.Reset(wait)
+		}
+	}
+}
+
+// run executes a task which was marked as running
+func (sc *Scheduler) run(ctx context.Context, task *scheduledTask) {
+	started := time.Now()
+	err := task.Run(ctx)
+	duration := time.Since(started)
+
+	// runs which are cut short by a shutdown are not failures of the task
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		task.finish(started, duration, nil, false)
+		log.WithField("task", task.Name).Debug("Scheduled task was interrupted")
+		return
+	}
+
+	failures := task.finish(started, duration, err, true)
+
+	fields := log.Fields{
+		"task":     task.Name,
+		"duration": duration,
+	}
+
+	if err != nil {
+		metrics.TaskRuns.WithLabelValues(task.Name, "failed").Inc()
+		fields["failures"] = failures
+		if failures == sc.alertAfter {
+			log.WithFields(fields).WithError(err).Error("ALERT: scheduled task keeps failing")
+		} else {
+			log.WithFields(fields).WithError(err).Error("Scheduled task failed")
+		}
+		return
+	}
+
+	metrics.TaskRuns.WithLabelValues(task.Name, "succeeded").Inc()
+	metrics.TaskLastSuccess.WithLabelValues(task.Name).Set(float64(started.Add(duration).Unix()))
+	log.WithFields(fields).Debug("Scheduled task succeeded")
+}
+
+// delay returns the time until the next run of a task, with jitter applied
+func (sc *Scheduler) delay(interval time.Duration) time.Duration {
+	spread := int64(sc.jitter * float64(interval))
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(spread))
+}
+
+// begin marks the task as running, returning false if it already is
+func (st *scheduledTask) begin() bool {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if st.status.Running {
+		st.status.Skipped++
+		return false
+	}
+	st.status.Running = true
+	return true
+}
+
+// finish records the outcome of a run, returning the number of consecutive failures.
+// Interrupted runs are not counted.
+func (st *scheduledTask) finish(started time.Time, duration time.Duration, err error, counted bool) int {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.status.Running = false
+	if !counted {
+		return st.status.ConsecutiveFailures
+	}
+
+	st.status.Runs++
+	st.status.LastRun = started
+	st.status.DurationMs = float64(duration) / float64(time.Millisecond)
+	if err != nil {
+		st.status.Failures++
+		st.status.ConsecutiveFailures++
+	} else {
+		st.status.LastSuccess = started.Add(duration)
+		st.status.ConsecutiveFailures = 0
+	}
+	return st.status.ConsecutiveFailures
+}
+
+func (st *scheduledTask) scheduled(next time.Time) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.status.NextRun = next
+}
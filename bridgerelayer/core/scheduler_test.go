@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestScheduler(t *testing.T) {
+	scheduler := NewScheduler(&SchedulerConfig{
+		Jitter: 0.5,
+		Tasks:  map[string]TaskConfig{"disabled": {Disabled: true}},
+	})
+
+	var failing, slow, final int32
+	release := make(chan struct{})
+	scheduler.Add(Task{
+		Name:      "failing",
+		Interval:  10 * time.Millisecond,
+		Immediate: true,
+		Run: func(context.Context) error {
+			atomic.AddInt32(&failing, 1)
+			return errors.New("unreachable")
+		},
+	})
+	scheduler.Add(Task{
+		Name:     "slow",
+		Interval: 10 * time.Millisecond,
+		Run: func(context.Context) error {
+			atomic.AddInt32(&slow, 1)
+			<-release
+			return nil
+		},
+	})
+	scheduler.Add(Task{
+		Name:     "final",
+		Interval: time.Hour,
+		Final:    true,
+		Run: func(context.Context) error {
+			atomic.AddInt32(&final, 1)
+			return nil
+		},
+	})
+	scheduler.Add(Task{
+		Name:     "disabled",
+		Interval: 10 * time.Millisecond,
+		Run: func(context.Context) error {
+			t.Error("disabled task ran")
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eg, ctx := errgroup.WithContext(ctx)
+	scheduler.Start(ctx, eg)
+
+	require.Eventually(t, func() bool {
+		statuses := scheduler.Tasks()
+		return statuses[0].ConsecutiveFailures >= 3 && statuses[2].Skipped >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	// the slow task is not run again while a run is in progress
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slow))
+	close(release)
+
+	cancel()
+	assert.True(t, errors.Is(eg.Wait(), context.Canceled))
+
+	statuses := scheduler.Tasks()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, []string{"failing", "final", "slow"}, []string{statuses[0].Name, statuses[1].Name, statuses[2].Name})
+
+	assert.Equal(t, uint64(atomic.LoadInt32(&failing)), statuses[0].Failures)
+	assert.True(t, statuses[0].LastSuccess.IsZero())
+	assert.False(t, statuses[0].LastRun.IsZero())
+
+	// tasks with a final run run once more as the scheduler stops
+	assert.Equal(t, int32(1), atomic.LoadInt32(&final))
+	assert.Equal(t, uint64(1), statuses[1].Runs)
+	assert.False(t, statuses[1].LastSuccess.IsZero())
+
+	assert.False(t, statuses[2].Running)
+	assert.Equal(t, uint64(1), statuses[2].Runs)
+	assert.Equal(t, 0, statuses[2].ConsecutiveFailures)
+}
+
+func TestScheduler_Delay(t *testing.T) {
+	scheduler := NewScheduler(&SchedulerConfig{})
+	assert.Equal(t, time.Minute, scheduler.delay(time.Minute))
+
+	scheduler = NewScheduler(&SchedulerConfig{Jitter: 0.1})
+	for i := 0; i < 100; i++ {
+		delay := scheduler.delay(time.Minute)
+		assert.True(t, delay >= time.Minute && delay < time.Minute+6*time.Second, delay)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/secrets"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// secretsTimeout bounds each read of the secrets from their provider
+const secretsTimeout = 30 * time.Second
+
+// ErrSecretsRotated stops the relay once a secret it uses was rotated, so that it is
+// restarted with the rotated secrets
+var ErrSecretsRotated = errors.New("secrets were rotated")
+
+// expandEndpoints replaces the references to secrets in the RPC endpoints, such as
+// wss://mainnet.infura.io/ws/v3/${INFURA_PROJECT_ID}, by their values
+func expandEndpoints(config *Config) error {
+	set := config.secretSet
+
+	err := expandEthereumEndpoints(set, &config.Eth)
+	if err != nil {
+		return err
+	}
+	for i := range config.Networks {
+		err = expandEthereumEndpoints(set, &config.Networks[i].Config)
+		if err != nil {
+			return err
+		}
+	}
+
+	sub := &config.Sub
+	return expandAll(set, &sub.Endpoint, &sub.SubmitEndpoint, &sub.LightClient.Endpoint, sub.FallbackEndpoints)
+}
+
+func expandEthereumEndpoints(set *secrets.Set, config *ethereum.Config) error {
+	err := expandAll(set, &config.Endpoint, &config.SubmitEndpoint, nil, config.FallbackEndpoints)
+	if err != nil {
+		return err
+	}
+	if config.Bundler != nil {
+		err = expandAll(set, &config.Bundler.Endpoint, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+	if config.RemoteSigner != nil {
+		err = expandAll(set, &config.RemoteSigner.Endpoint, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		// headers may authenticate with the signer
+		for name, value := range config.RemoteSigner.Headers {
+			expanded, err := set.Expand(value)
+			if err != nil {
+				return err
+			}
+			config.RemoteSigner.Headers[name] = expanded
+		}
+	}
+	return nil
+}
+
+func expandAll(set *secrets.Set, endpoint *string, submit *string, light *string, fallbacks []string) error {
+	for _, setting := range []*string{endpoint, submit, light} {
+		if setting == nil {
+			continue
+		}
+		expanded, err := set.Expand(*setting)
+		if err != nil {
+			return err
+		}
+		*setting = expanded
+	}
+	for i := range fallbacks {
+		expanded, err := set.Expand(fallbacks[i])
+		if err != nil {
+			return err
+		}
+		fallbacks[i] = expanded
+	}
+	return nil
+}
+
+// SecretRotation periodically reads the secrets again, and stops the relay once a secret
+// which it uses was rotated, so that its supervisor restarts it with the rotated secrets.
+// Keys and credentials are only read at startup, as the writers, keystores and
+// connections are built from them.
+type SecretRotation struct {
+	provider secrets.Provider
+	secrets  *secrets.Set
+	interval time.Duration
+}
+
+func NewSecretRotation(config *secrets.Config, provider secrets.Provider, set *secrets.Set) *SecretRotation {
+	return &SecretRotation{
+		provider: provider,
+		secrets:  set,
+		interval: time.Duration(config.RotationInterval) * time.Second,
+	}
+}
+
+func (sr *SecretRotation) Start(ctx context.Context, eg *errgroup.Group) {
+	eg.Go(func() error {
+		ticker := time.NewTicker(sr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				rotated, err := sr.Check(ctx)
+				if err != nil {
+					log.WithError(err).Warn("Failed to read secrets to check for their rotation")
+					continue
+				}
+				if len(rotated) > 0 {
+					log.WithField("secrets", rotated).Warn("Stopping to restart with the rotated secrets")
+					return ErrSecretsRotated
+				}
+			}
+		}
+	})
+}
+
+// Check returns the names of the secrets used by the relay which were rotated since it started
+func (sr *SecretRotation) Check(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretsTimeout)
+	defer cancel()
+
+	later, err := secrets.Read(ctx, sr.provider)
+	if err != nil {
+		return nil, err
+	}
+	return sr.secrets.Rotated(later), nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/secrets"
+)
+
+func TestExpandEndpoints(t *testing.T) {
+	os.Setenv("ARTEMIS_TEST_RPC_TOKEN", "abc")
+	defer os.Unsetenv("ARTEMIS_TEST_RPC_TOKEN")
+
+	set, err := secrets.Read(context.Background(), secrets.Env{})
+	require.NoError(t, err)
+
+	config := Config{
+		Eth: ethereum.Config{
+			Endpoint:          "wss://eth.example/${ARTEMIS_TEST_RPC_TOKEN}",
+			FallbackEndpoints: []string{"wss://fallback.example/${ARTEMIS_TEST_RPC_TOKEN}"},
+		},
+		secretSet: set,
+	}
+	config.Sub.Endpoint = "ws://127.0.0.1:9944/"
+	require.NoError(t, expandEndpoints(&config))
+	assert.Equal(t, "wss://eth.example/abc", config.Eth.Endpoint)
+	assert.Equal(t, []string{"wss://fallback.example/abc"}, config.Eth.FallbackEndpoints)
+	assert.Equal(t, "ws://127.0.0.1:9944/", config.Sub.Endpoint)
+
+	// the secret is watched for its rotation
+	rotation := NewSecretRotation(&secrets.Config{RotationInterval: 1}, secrets.Env{}, set)
+	rotated, err := rotation.Check(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, rotated)
+
+	os.Setenv("ARTEMIS_TEST_RPC_TOKEN", "def")
+	rotated, err = rotation.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ARTEMIS_TEST_RPC_TOKEN"}, rotated)
+
+	config.Sub.Endpoint = "ws://${ARTEMIS_TEST_MISSING}/"
+	assert.EqualError(t, expandEndpoints(&config), "environment variable not set: ARTEMIS_TEST_MISSING")
+}
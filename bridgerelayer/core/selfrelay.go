@@ -0,0 +1,176 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SelfRelayConfig struct {
+	// Accept events which users submit through the status feed, to be relayed at once
+	Enabled bool `mapstructure:"enabled"`
+	// Fee in wei which users pay to the relayer's Ethereum account for each message they
+	// submit. No payment is required if empty or zero.
+	Fee string `mapstructure:"fee"`
+}
+
+// SelfRelayQueued is the status of self-relayed messages which were queued for delivery
+const SelfRelayQueued = "queued"
+
+// PaymentVerifier verifies the payments which users make to the relayer
+type PaymentVerifier interface {
+	VerifyPayment(ctx context.Context, hash [32]byte, amount *big.Int) error
+}
+
+// SelfRelay delivers the messages of events which users submit themselves, for example
+// to expedite a transfer while the listener lags behind. Messages are rebuilt from the
+// chain as the listener builds them, so that nothing but genuine events can be submitted,
+// and are then routed like any observed message. Only the messages of apps with nonces
+// are accepted, as the router suppresses them when the listener observes them again.
+type SelfRelay struct {
+	chains     []chain.Chain
+	sources    map[string]chan<- chain.Message
+	stopped    <-chan struct{}
+	messages   *store.Messages
+	duplicates *DuplicateFilter
+	payments   *store.Payments
+	verifier   PaymentVerifier
+	// nil if no payment is required
+	fee *big.Int
+}
+
+// NewSelfRelay creates a self-relay which hands messages to the router through the
+// channel of their source chain
+func NewSelfRelay(config *SelfRelayConfig, chains []chain.Chain, sources map[string]chan<- chain.Message, stopped <-chan struct{}, messages *store.Messages, duplicates *DuplicateFilter, payments *store.Payments, verifier PaymentVerifier) (*SelfRelay, error) {
+	sr := &SelfRelay{
+		chains:     chains,
+		sources:    sources,
+		stopped:    stopped,
+		messages:   messages,
+		duplicates: duplicates,
+		payments:   payments,
+		verifier:   verifier,
+	}
+
+	if config.Fee != "" {
+		fee, ok := new(big.Int).SetString(config.Fee, 10)
+		if !ok || fee.Sign() < 0 {
+			return nil, fmt.Errorf("invalid self-relay fee: %s", config.Fee)
+		}
+		if fee.Sign() > 0 {
+			sr.fee = fee
+		}
+	}
+
+	return sr, nil
+}
+
+// SelfRelay verifies the event of a request against its chain and queues its message for
+// delivery, unless the message was already routed
+func (sr *SelfRelay) SelfRelay(ctx context.Context, request *api.SelfRelayRequest) (*api.SelfRelayResult, error) {
+	var provable Provable
+	var source string
+	for _, ch := range sr.chains {
+		if strings.EqualFold(ch.Name(), request.Chain) {
+			provable, _ = ch.(Provable)
+			source = ch.Name()
+		}
+	}
+	if provable == nil {
+		return nil, fmt.Errorf("unknown chain: %s", request.Chain)
+	}
+
+	msg, err := provable.EventMessage(ctx, request.Block, request.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sr.duplicates.Nonced(msg.AppID) {
+		return nil, fmt.Errorf("app %s does not accept self-relayed messages", hexutil.Encode(msg.AppID[:]))
+	}
+
+	id, _, err := store.MessageID(source, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// quarantined messages are retried, like when they are observed again
+	record, err := sr.messages.Lookup(source, msg)
+	if err == nil && record.Status != store.StatusQuarantined {
+		return &api.SelfRelayResult{ID: record.ID, Status: string(record.Status)}, nil
+	} else if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	err = sr.pay(ctx, request.Payment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.ObservedAt = time.Now()
+	err = chain.Handoff(ctx, sr.sources[source], sr.stopped, sr.messages, source, *msg)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source":    source,
+		"block":     request.Block,
+		"index":     request.Index,
+		"messageID": id,
+	}).Info("Queued self-relayed message")
+
+	return &api.SelfRelayResult{ID: id, Status: SelfRelayQueued}, nil
+}
+
+// pay verifies and claims the payment of the fee for a message. A payment which was
+// claimed for the same message is accepted again, so that users can retry requests.
+func (sr *SelfRelay) pay(ctx context.Context, payment string, id string) error {
+	if sr.fee == nil {
+		return nil
+	}
+	if payment == "" {
+		return fmt.Errorf("%w: a fee of %s wei is charged for each message", api.ErrPaymentRequired, sr.fee)
+	}
+
+	decoded, err := hexutil.Decode(payment)
+	if err != nil || len(decoded) != common.HashLength {
+		return fmt.Errorf("%w: invalid payment hash %s", api.ErrPaymentRequired, payment)
+	}
+	hash := common.BytesToHash(decoded)
+
+	claimant, err := sr.payments.Claimant(hash.Hex())
+	if err == nil {
+		if claimant == id {
+			return nil
+		}
+		return fmt.Errorf("%w: %v", api.ErrPaymentRequired, store.ErrPaymentClaimed)
+	} else if err != store.ErrNotFound {
+		return err
+	}
+
+	err = sr.verifier.VerifyPayment(ctx, hash, sr.fee)
+	if err != nil {
+		return fmt.Errorf("%w: %v", api.ErrPaymentRequired, err)
+	}
+
+	err = sr.payments.Claim(hash.Hex(), id)
+	if err == store.ErrPaymentClaimed {
+		return fmt.Errorf("%w: %v", api.ErrPaymentRequired, err)
+	}
+	return err
+}
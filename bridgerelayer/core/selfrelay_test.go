@@ -0,0 +1,128 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/api"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// eventChain serves the messages of events from a map keyed by block number
+type eventChain struct {
+	events map[uint64]*chain.Message
+}
+
+func (ec *eventChain) Name() string                                     { return ethereum.Name }
+func (ec *eventChain) Start(_ context.Context, _ *errgroup.Group) error { return nil }
+func (ec *eventChain) Stop()                                            {}
+
+func (ec *eventChain) EventMessage(_ context.Context, number uint64, _ uint64) (*chain.Message, error) {
+	msg, ok := ec.events[number]
+	if !ok {
+		return nil, chain.ErrEventNotFound
+	}
+	copied := *msg
+	return &copied, nil
+}
+
+// paymentLog accepts payments of at least the fee, by the first byte of their hash
+type paymentLog struct {
+	paid map[byte]*big.Int
+}
+
+func (pl *paymentLog) VerifyPayment(_ context.Context, hash [32]byte, amount *big.Int) error {
+	paid, ok := pl.paid[hash[0]]
+	if !ok || paid.Cmp(amount) < 0 {
+		return errors.New("insufficient payment")
+	}
+	return nil
+}
+
+func newTestSelfRelay(t *testing.T, fee string) (*SelfRelay, chan chain.Message, *store.Messages) {
+	nonced := common.HexToAddress("0x01")
+	ch := &eventChain{events: map[uint64]*chain.Message{
+		1: {AppID: nonced, Payload: []byte{1}},
+		2: {AppID: nonced, Payload: []byte{2}},
+		3: {AppID: common.HexToAddress("0x02"), Payload: []byte{3}},
+	}}
+
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	duplicates := NewDuplicateFilter(&DuplicateConfig{}, map[string]ethereum.Application{
+		"eth": {Address: "0x01", Nonced: true},
+	}, messages, nil)
+	verifier := &paymentLog{paid: map[byte]*big.Int{1: big.NewInt(100), 2: big.NewInt(10)}}
+
+	source := make(chan chain.Message, 4)
+	sr, err := NewSelfRelay(&SelfRelayConfig{Enabled: true, Fee: fee}, []chain.Chain{ch}, map[string]chan<- chain.Message{ethereum.Name: source}, nil, messages, duplicates, store.NewPayments(db), verifier)
+	require.NoError(t, err)
+	return sr, source, messages
+}
+
+func TestSelfRelay(t *testing.T) {
+	sr, source, messages := newTestSelfRelay(t, "")
+	ctx := context.Background()
+
+	result, err := sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1})
+	require.NoError(t, err)
+	assert.Equal(t, SelfRelayQueued, result.Status)
+	msg := <-source
+	assert.Equal(t, []byte{1}, msg.Payload)
+	assert.False(t, msg.ObservedAt.IsZero())
+
+	// messages which were already routed are not queued again
+	_, err = messages.Record(ethereum.Name, &msg, store.StatusRouted)
+	require.NoError(t, err)
+	again, err := sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1})
+	require.NoError(t, err)
+	assert.Equal(t, result.ID, again.ID)
+	assert.Equal(t, string(store.StatusRouted), again.Status)
+	assert.Len(t, source, 0)
+
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 9})
+	assert.Equal(t, chain.ErrEventNotFound, err)
+
+	// apps without nonces could have the message delivered twice
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 3})
+	assert.Error(t, err)
+
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "polkadot", Block: 1})
+	assert.Error(t, err)
+}
+
+func TestSelfRelay_Fee(t *testing.T) {
+	sr, source, _ := newTestSelfRelay(t, "50")
+	ctx := context.Background()
+	paid := common.Hash{1}.Hex()
+
+	_, err := sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1})
+	assert.True(t, errors.Is(err, api.ErrPaymentRequired))
+
+	// payments below the fee are refused
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1, Payment: common.Hash{2}.Hex()})
+	assert.True(t, errors.Is(err, api.ErrPaymentRequired))
+
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1, Payment: paid})
+	require.NoError(t, err)
+	assert.Len(t, source, 1)
+
+	// a payment covers retries of its message, but no other message
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 1, Payment: paid})
+	require.NoError(t, err)
+	_, err = sr.SelfRelay(ctx, &api.SelfRelayRequest{Chain: "ethereum", Block: 2, Payment: paid})
+	assert.True(t, errors.Is(err, api.ErrPaymentRequired))
+	assert.Len(t, source, 2)
+}
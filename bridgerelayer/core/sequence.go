@@ -0,0 +1,92 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"sync"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SequenceTracker follows the bridge-wide sequence numbers which the message store assigns
+// to messages, from the messages recorded by the router and the deliveries confirmed by the
+// writers, for the status feed and metrics. Sequence numbers follow the order in which
+// messages were recorded, so they order messages across both directions.
+type SequenceTracker struct {
+	messages *store.Messages
+	mutex    sync.Mutex
+	// latest sequence number recorded from each source chain
+	recorded map[string]uint64
+	// highest sequence number confirmed as delivered to each target chain
+	delivered map[string]uint64
+}
+
+func NewSequenceTracker(messages *store.Messages) *SequenceTracker {
+	return &SequenceTracker{
+		messages:  messages,
+		recorded:  make(map[string]uint64),
+		delivered: make(map[string]uint64),
+	}
+}
+
+// Record notes the sequence number of a message recorded from a source chain
+func (st *SequenceTracker) Record(source string, msg *chain.Message) {
+	if msg.Sequence == 0 {
+		return
+	}
+
+	st.mutex.Lock()
+	if msg.Sequence > st.recorded[source] {
+		st.recorded[source] = msg.Sequence
+	}
+	st.mutex.Unlock()
+
+	metrics.RecordedSequence.WithLabelValues(source).Set(float64(msg.Sequence))
+}
+
+func (st *SequenceTracker) Submitted(_ *chain.Message, _ *chain.Receipt) {}
+
+// Confirmed notes the sequence number of a message delivered to its target chain.
+// Deliveries can be confirmed out of order, so the highest number is kept.
+func (st *SequenceTracker) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	if msg.Sequence == 0 {
+		return
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if msg.Sequence > st.delivered[receipt.Chain] {
+		st.delivered[receipt.Chain] = msg.Sequence
+		metrics.DeliveredSequence.WithLabelValues(receipt.Chain).Set(float64(msg.Sequence))
+	}
+}
+
+// Latest returns the latest sequence number assigned by the message store, which persists
+// across restarts
+func (st *SequenceTracker) Latest() uint64 {
+	sequence, err := st.messages.Sequence()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read latest message sequence number")
+	}
+	return sequence
+}
+
+// Recorded returns the latest sequence number recorded from a chain since the relay started
+func (st *SequenceTracker) Recorded(chain string) uint64 {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.recorded[chain]
+}
+
+// Delivered returns the highest sequence number delivered to a chain since the relay started
+func (st *SequenceTracker) Delivered(chain string) uint64 {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.delivered[chain]
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+	tracker := NewSequenceTracker(messages)
+
+	var recorded []*chain.Message
+	for i, source := range []string{"Ethereum", "Substrate", "Ethereum"} {
+		msg := &chain.Message{Payload: []byte{byte(i)}}
+		record, err := messages.Record(source, msg, store.StatusRouted)
+		require.NoError(t, err)
+		msg.Sequence = record.Sequence
+		tracker.Record(source, msg)
+		recorded = append(recorded, msg)
+	}
+
+	assert.Equal(t, uint64(3), tracker.Latest())
+	assert.Equal(t, uint64(3), tracker.Recorded("Ethereum"))
+	assert.Equal(t, uint64(2), tracker.Recorded("Substrate"))
+
+	// deliveries confirmed out of order leave the highest sequence number
+	tracker.Confirmed(recorded[2], &chain.Receipt{Chain: "Substrate"})
+	tracker.Confirmed(recorded[0], &chain.Receipt{Chain: "Substrate"})
+	assert.Equal(t, uint64(3), tracker.Delivered("Substrate"))
+	assert.Equal(t, uint64(0), tracker.Delivered("Ethereum"))
+}
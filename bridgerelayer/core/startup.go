@@ -0,0 +1,82 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type StartupConfig struct {
+	// Seconds for which the relay waits before it connects to any chain
+	Delay uint64 `mapstructure:"delay"`
+	// Seconds up to which the startup delay is randomly extended, so that the relayers of a
+	// fleet restarted together don't connect to shared RPC providers at once. Zero disables
+	// jitter.
+	Jitter uint64 `mapstructure:"jitter"`
+	// Seconds up to which the relay randomly pauses before starting each further chain, so
+	// that the catch-up of the listeners and the nonce reconciliation of the writers are
+	// staggered. Zero starts the chains one after another without pausing.
+	Stagger uint64 `mapstructure:"stagger"`
+}
+
+// Startup paces the start of a relay
+type Startup struct {
+	delay   time.Duration
+	jitter  time.Duration
+	stagger time.Duration
+	random  func(n int64) int64
+}
+
+func NewStartup(config *StartupConfig) *Startup {
+	return &Startup{
+		delay:   time.Duration(config.Delay) * time.Second,
+		jitter:  time.Duration(config.Jitter) * time.Second,
+		stagger: time.Duration(config.Stagger) * time.Second,
+		random:  rand.Int63n,
+	}
+}
+
+// Wait waits for the startup delay and its jitter, returning early if the context is done
+func (su *Startup) Wait(ctx context.Context) error {
+	delay := su.delay + su.spread(su.jitter)
+	if delay == 0 {
+		return nil
+	}
+	log.WithField("delay", delay.String()).Info("Delaying startup")
+	return pause(ctx, delay)
+}
+
+// Stagger pauses before a further chain is started, returning early if the context is done
+func (su *Startup) Stagger(ctx context.Context, name string) error {
+	delay := su.spread(su.stagger)
+	if delay == 0 {
+		return nil
+	}
+	log.WithFields(log.Fields{"chain": name, "delay": delay.String()}).Debug("Staggering start of chain")
+	return pause(ctx, delay)
+}
+
+// spread returns a random duration up to a bound
+func (su *Startup) spread(bound time.Duration) time.Duration {
+	if bound <= 0 {
+		return 0
+	}
+	return time.Duration(su.random(int64(bound) + 1))
+}
+
+func pause(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartup_Spread(t *testing.T) {
+	startup := NewStartup(&StartupConfig{Jitter: 10})
+	var bound int64
+	startup.random = func(n int64) int64 {
+		bound = n
+		return n - 1
+	}
+
+	assert.Equal(t, 10*time.Second, startup.spread(startup.jitter))
+	assert.Equal(t, int64(10*time.Second)+1, bound)
+
+	// no jitter without a bound
+	assert.Equal(t, time.Duration(0), startup.spread(startup.stagger))
+}
+
+func TestStartup_Wait(t *testing.T) {
+	assert.NoError(t, NewStartup(&StartupConfig{}).Wait(context.Background()))
+
+	// a relay stopped while delayed starts nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewStartup(&StartupConfig{Delay: 600}).Wait(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	err = NewStartup(&StartupConfig{Stagger: 600}).Stagger(ctx, "Substrate")
+	assert.Equal(t, context.Canceled, err)
+}
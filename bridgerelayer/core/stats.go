@@ -0,0 +1,143 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/ethereum"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain/substrate"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+type StatsConfig struct {
+	// Days for which the statistics of each channel are kept. Statistics are not
+	// recorded if zero.
+	Retention int `mapstructure:"retention"`
+}
+
+// Fields of transfer events carrying the amount and token, on Ethereum and Substrate
+var (
+	amountFields = []string{"_amount", "amount"}
+	tokenFields  = []string{"_token", "tokenId"}
+)
+
+// StatsRecorder projects the events of the journal into the daily statistics of each
+// channel, the direction in which messages are relayed. Deliveries are counted from the
+// confirmations of their target chain, while volumes are taken from the transfer events
+// observed on the source chain. Events are counted on the day they were appended, so that
+// the statistics rebuilt from the journal match those recorded as it grew.
+type StatsRecorder struct {
+	stats *store.Stats
+}
+
+func NewStatsRecorder(stats *store.Stats) *StatsRecorder {
+	return &StatsRecorder{stats: stats}
+}
+
+func (sr *StatsRecorder) Name() string {
+	return "stats"
+}
+
+func (sr *StatsRecorder) Reset() error {
+	return sr.stats.Reset()
+}
+
+func (sr *StatsRecorder) Apply(event *store.StateEvent) error {
+	switch {
+	case event.Kind == store.EventConfirmed && event.Receipt != nil:
+		return sr.confirmed(event)
+	case event.Kind == store.EventObserved && event.Event != nil:
+		return sr.observed(event)
+	}
+	return nil
+}
+
+func (sr *StatsRecorder) confirmed(event *store.StateEvent) error {
+	receipt := event.Receipt
+	channel := channelTo(receipt.Chain)
+	if channel == "" {
+		return nil
+	}
+
+	var fee *big.Int
+	if receipt.Fee != "" {
+		fee, _ = new(big.Int).SetString(receipt.Fee, 10)
+	}
+
+	var latency time.Duration
+	if event.ObservedAt != nil && receipt.ConfirmedAt != nil {
+		latency = receipt.ConfirmedAt.Sub(*event.ObservedAt)
+	}
+
+	return sr.stats.Update(channel, event.Time, func(stats *store.ChannelStats) {
+		stats.Deliver(fee, latency)
+	})
+}
+
+func (sr *StatsRecorder) observed(event *store.StateEvent) error {
+	observed := event.Event
+	if observed.Name != "Transfer" && !strings.HasSuffix(observed.Name, ".Transfer") {
+		return nil
+	}
+
+	channel := channelFrom(observed.Chain)
+	if channel == "" {
+		return nil
+	}
+
+	value, ok := field(observed, amountFields).(string)
+	if !ok {
+		return nil
+	}
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil
+	}
+
+	// the transfers of ETH don't name a token
+	token, ok := field(observed, tokenFields).(string)
+	if !ok {
+		token = observed.App
+	}
+
+	return sr.stats.Update(channel, event.Time, func(stats *store.ChannelStats) {
+		stats.Transfer(token, amount)
+	})
+}
+
+// field returns the first of several fields which an event carries
+func field(event *chain.ObservedEvent, names []string) interface{} {
+	for _, name := range names {
+		if value, ok := event.Fields[name]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// channelFrom returns the channel of messages observed on a source chain
+func channelFrom(source string) string {
+	switch source {
+	case ethereum.Name:
+		return DirectionToSubstrate
+	case substrate.Name:
+		return DirectionToEthereum
+	}
+	return ""
+}
+
+// channelTo returns the channel of messages delivered to a target chain
+func channelTo(target string) string {
+	switch target {
+	case substrate.Name:
+		return DirectionToSubstrate
+	case ethereum.Name:
+		return DirectionToEthereum
+	}
+	return ""
+}
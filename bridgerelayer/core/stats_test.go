@@ -0,0 +1,75 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestStatsRecorder(t *testing.T) {
+	db := store.NewMemoryDB()
+	stats := store.NewStats(db, 30)
+	recorder := NewStatsRecorder(stats)
+	journal := store.NewMessages(db).Journal()
+	journal.Project(recorder)
+
+	observed := time.Now().Add(-time.Minute)
+	receipt := chain.Receipt{Chain: "Substrate", Fee: "120"}
+	receipt.Confirm(7, "0x01")
+	require.NoError(t, journal.Append(store.Receipt(store.EventConfirmed, &chain.Message{ObservedAt: observed}, &receipt)))
+
+	observe := func(event *chain.ObservedEvent) {
+		require.NoError(t, journal.Append(&store.StateEvent{Kind: store.EventObserved, Event: event}))
+	}
+	observe(&chain.ObservedEvent{
+		Chain:  "Ethereum",
+		App:    "eth",
+		Name:   "Transfer",
+		Fields: map[string]interface{}{"_amount": "100"},
+	})
+	observe(&chain.ObservedEvent{
+		Chain:  "Substrate",
+		App:    "erc20",
+		Name:   "ERC20.Transfer",
+		Fields: map[string]interface{}{"tokenId": "0x02", "amount": "5"},
+	})
+	// other events carry no volume
+	observe(&chain.ObservedEvent{
+		Chain:  "Ethereum",
+		App:    "eth",
+		Name:   "Unlock",
+		Fields: map[string]interface{}{"_amount": "100"},
+	})
+
+	check := func() {
+		days, err := stats.List(DirectionToSubstrate, time.Now())
+		require.NoError(t, err)
+		require.Len(t, days, 1)
+		assert.Equal(t, uint64(1), days[0].Messages)
+		assert.Equal(t, "120", days[0].Fees)
+		assert.InDelta(t, 60, days[0].AverageLatency, 5)
+		assert.Equal(t, map[string]string{"eth": "100"}, days[0].Volume)
+
+		days, err = stats.List(DirectionToEthereum, time.Now())
+		require.NoError(t, err)
+		require.Len(t, days, 1)
+		assert.Equal(t, uint64(0), days[0].Messages)
+		assert.Equal(t, map[string]string{"0x02": "5"}, days[0].Volume)
+	}
+	check()
+
+	// corrupted statistics are regenerated from the journal
+	require.NoError(t, db.Put([]byte("stats/ethereum-to-substrate/"+time.Now().UTC().Format(store.DayLayout)), []byte("{")))
+	replayed, err := journal.Rebuild(recorder)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), replayed)
+	check()
+}
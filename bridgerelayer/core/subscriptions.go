@@ -0,0 +1,218 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SubscriptionConfig struct {
+	// Whether each webhook reads the observed events from the journal through a subscription
+	// of its own, instead of a queue fed by the listeners
+	Enabled bool `mapstructure:"enabled"`
+	// Events by which a subscription may fall behind the journal before an alert is logged.
+	// Zero disables the alert.
+	MaxLag uint64 `mapstructure:"max-lag"`
+}
+
+// subscriptionRetry is the delay before an event which a consumer failed to handle is
+// handed to it again
+const subscriptionRetry = 5 * time.Second
+
+// EventConsumer handles the observed events of a subscription. The subscription waits while
+// an event is consumed, and hands it again to the consumer if it fails.
+type EventConsumer interface {
+	Consume(ctx context.Context, event *chain.ObservedEvent) error
+}
+
+// Subscriptions fan the events observed by the listeners out to consumers which read them
+// from the journal at their own pace, instead of being called by the listeners. Each
+// subscription has a cursor of its own, persisted with the state of the relay, so that a
+// slow consumer falls behind without holding back the listeners, the writers or the other
+// consumers, and resumes from its cursor after a restart. New subscriptions start from the
+// latest event. Events are read back from the journal, without the messages generated for
+// them.
+type Subscriptions struct {
+	config        *SubscriptionConfig
+	journal       *store.Journal
+	cursors       chain.CursorStore
+	subscriptions []*subscription
+}
+
+type subscription struct {
+	name     string
+	consumer EventConsumer
+	// signalled as events are appended to the journal
+	wake chan struct{}
+	// sequence number of the last event handed to the consumer
+	cursor uint64
+	// whether the alert of the lag of the subscription was raised
+	lagging bool
+}
+
+// NewSubscriptions creates a subscription for each consumer, whose cursor is recorded under
+// its name
+func NewSubscriptions(config *SubscriptionConfig, journal *store.Journal, cursors chain.CursorStore, consumers map[string]EventConsumer) (*Subscriptions, error) {
+	ss := &Subscriptions{config: config, journal: journal, cursors: cursors}
+	for name, consumer := range consumers {
+		if name == "" {
+			return nil, fmt.Errorf("missing name of subscription")
+		}
+		ss.subscriptions = append(ss.subscriptions, &subscription{
+			name:     name,
+			consumer: consumer,
+			wake:     make(chan struct{}, 1),
+		})
+	}
+	return ss, nil
+}
+
+// Empty returns whether there are no subscriptions
+func (ss *Subscriptions) Empty() bool {
+	return len(ss.subscriptions) == 0
+}
+
+func (ss *Subscriptions) Start(ctx context.Context, eg *errgroup.Group) error {
+	for _, sub := range ss.subscriptions {
+		cursor, ok, err := ss.cursors.LoadCursor(subscriptionCursor(sub.name))
+		if err != nil {
+			return fmt.Errorf("load cursor of subscription %s: %w", sub.name, err)
+		}
+		if !ok {
+			cursor, err = ss.journal.Sequence()
+			if err != nil {
+				return err
+			}
+			err = ss.cursors.SaveCursor(subscriptionCursor(sub.name), cursor)
+			if err != nil {
+				return fmt.Errorf("save cursor of subscription %s: %w", sub.name, err)
+			}
+		}
+		sub.cursor = cursor
+
+		sub := sub
+		eg.Go(func() error {
+			return ss.run(ctx, sub)
+		})
+	}
+	return nil
+}
+
+// Name, Reset and Apply project the journal onto the subscriptions, waking them up as
+// events are appended
+func (ss *Subscriptions) Name() string {
+	return "subscriptions"
+}
+
+func (ss *Subscriptions) Reset() error {
+	return nil
+}
+
+func (ss *Subscriptions) Apply(event *store.StateEvent) error {
+	if event.Kind != store.EventObserved {
+		return nil
+	}
+	for _, sub := range ss.subscriptions {
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (ss *Subscriptions) run(ctx context.Context, sub *subscription) error {
+	for {
+		wait := sub.wake
+		var retry <-chan time.Time
+		err := ss.drain(ctx, sub)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.WithError(err).WithField("subscription", sub.name).Warn("Failed to consume observed event, retrying")
+			wait = nil
+			retry = time.After(subscriptionRetry)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		case <-retry:
+		}
+	}
+}
+
+// drain hands the observed events appended to the journal since the cursor of a
+// subscription to its consumer, moving the cursor past each event it handled
+func (ss *Subscriptions) drain(ctx context.Context, sub *subscription) error {
+	latest, err := ss.journal.Sequence()
+	if err != nil {
+		return err
+	}
+
+	for sub.cursor < latest {
+		ss.observeLag(sub, latest)
+
+		event, err := ss.journal.Event(sub.cursor + 1)
+		if err == store.ErrNotFound {
+			// the event is still being appended, and wakes the subscription once it is
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if event.Kind == store.EventObserved && event.Event != nil {
+			err = sub.consumer.Consume(ctx, event.Event)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = ss.cursors.SaveCursor(subscriptionCursor(sub.name), event.Sequence)
+		if err != nil {
+			return err
+		}
+		sub.cursor = event.Sequence
+	}
+
+	ss.observeLag(sub, latest)
+	return nil
+}
+
+func (ss *Subscriptions) observeLag(sub *subscription, latest uint64) {
+	lag := latest - sub.cursor
+	metrics.SubscriptionLag.WithLabelValues(sub.name).Set(float64(lag))
+
+	if ss.config.MaxLag == 0 {
+		return
+	}
+	if lag > ss.config.MaxLag && !sub.lagging {
+		log.WithFields(log.Fields{
+			"subscription": sub.name,
+			"lag":          lag,
+		}).Error("ALERT: Subscription is falling behind the journal")
+		sub.lagging = true
+	} else if lag <= ss.config.MaxLag && sub.lagging {
+		log.WithField("subscription", sub.name).Info("Subscription caught up with the journal")
+		sub.lagging = false
+	}
+}
+
+// subscriptionCursor names the cursor of a subscription apart from those of the listeners
+func subscriptionCursor(name string) string {
+	return "subscription/" + name
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+// channelConsumer hands the names of the consumed events to a channel, waiting while it is
+// full
+type channelConsumer chan string
+
+func (cc channelConsumer) Consume(ctx context.Context, event *chain.ObservedEvent) error {
+	select {
+	case cc <- event.Name:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	db := store.NewMemoryDB()
+	journal := store.NewMessages(db).Journal()
+	recorder := NewJournalRecorder(journal)
+	cursors := store.NewCursors(db)
+
+	// events of the journal preceding a new subscription aren't handed to it
+	recorder.Observed(&chain.ObservedEvent{Chain: "Ethereum", Name: "Old"})
+
+	fast := make(channelConsumer, 10)
+	slow := make(channelConsumer)
+	subscriptions, err := NewSubscriptions(&SubscriptionConfig{MaxLag: 1}, journal, cursors, map[string]EventConsumer{
+		"fast": fast,
+		"slow": slow,
+	})
+	require.NoError(t, err)
+	journal.Project(subscriptions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eg, ctx := errgroup.WithContext(ctx)
+	require.NoError(t, subscriptions.Start(ctx, eg))
+
+	// the slow consumer holds back neither the journal nor the fast consumer
+	for _, name := range []string{"Lock", "Burn", "Unlock"} {
+		recorder.Observed(&chain.ObservedEvent{Chain: "Ethereum", Name: name})
+	}
+	recorder.Submitted(&chain.Message{ID: "a"}, &chain.Receipt{})
+	for _, name := range []string{"Lock", "Burn", "Unlock"} {
+		assert.Equal(t, name, receive(t, fast))
+	}
+	assert.Equal(t, "Lock", receive(t, slow))
+
+	cancel()
+	_ = eg.Wait()
+
+	latest, err := journal.Sequence()
+	require.NoError(t, err)
+	cursor, ok, err := cursors.LoadCursor(subscriptionCursor("fast"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, latest, cursor)
+
+	// a restarted subscription resumes from the events it didn't consume
+	resumed, err := NewSubscriptions(&SubscriptionConfig{}, journal, cursors, map[string]EventConsumer{"slow": slow})
+	require.NoError(t, err)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx = errgroup.WithContext(ctx)
+	require.NoError(t, resumed.Start(ctx, eg))
+	assert.Equal(t, "Burn", receive(t, slow))
+	assert.Equal(t, "Unlock", receive(t, slow))
+}
+
+func TestNewSubscriptions_Invalid(t *testing.T) {
+	_, err := NewSubscriptions(&SubscriptionConfig{}, nil, nil, map[string]EventConsumer{"": make(channelConsumer)})
+	assert.Error(t, err)
+}
+
+func receive(t *testing.T, consumer channelConsumer) string {
+	select {
+	case name := <-consumer:
+		return name
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event was consumed")
+		return ""
+	}
+}
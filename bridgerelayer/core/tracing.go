@@ -0,0 +1,31 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+// TraceRecorder records the wait for the confirmation of each delivery as the last span of
+// the trace of its message
+type TraceRecorder struct{}
+
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
+
+// Submitted records nothing, the writers trace the submission of a message themselves
+func (tr *TraceRecorder) Submitted(msg *chain.Message, receipt *chain.Receipt) {}
+
+func (tr *TraceRecorder) Confirmed(msg *chain.Message, receipt *chain.Receipt) {
+	if !msg.Trace.Valid() || receipt.ConfirmedAt == nil {
+		return
+	}
+	tracing.Record("confirm delivery", msg.Trace, receipt.SubmittedAt, *receipt.ConfirmedAt, map[string]interface{}{
+		"chain": receipt.Chain,
+		"hash":  receipt.Hash,
+		"block": receipt.BlockNumber,
+	})
+}
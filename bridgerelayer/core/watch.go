@@ -0,0 +1,176 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/metrics"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type WatchConfig struct {
+	// Name of the account in notifications and message tags, for example treasury
+	Name string `mapstructure:"name"`
+	// Ethereum address, Substrate SS58 address or hex-encoded Substrate account ID
+	Address string `mapstructure:"address"`
+	// URL to which the events involving the account are posted. Disabled if empty.
+	Webhook string `mapstructure:"webhook"`
+	// Secret with which the requests to the webhook are signed, unsigned if empty
+	Secret string `mapstructure:"secret"`
+	// Slack incoming webhook URL to which a summary of each event is posted. Disabled if empty.
+	Slack string `mapstructure:"slack"`
+}
+
+// WatchTagPrefix precedes the name of a watched account in the tags of its messages
+const WatchTagPrefix = "watch:"
+
+// WatchNotification is posted to the webhook of a watched account for each event involving it
+type WatchNotification struct {
+	Account string               `json:"account"`
+	Event   *chain.ObservedEvent `json:"event"`
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Watcher follows the bridge events involving watched accounts, such as those of a treasury
+// or an exchange. Their messages are tagged in the message store, and each event is posted
+// to the webhook and Slack channel of the account, masked if privacy is enabled.
+type Watcher struct {
+	// watched accounts keyed by their lower case hex address or account ID
+	accounts map[string][]*watchedAccount
+	messages *store.Messages
+	notifier *Notifier
+}
+
+type watchedAccount struct {
+	name string
+	// nil if disabled
+	webhook *webhook
+	slack   *webhook
+}
+
+func NewWatcher(configs []WatchConfig, messages *store.Messages, privacy *Privacy) (*Watcher, error) {
+	wa := &Watcher{
+		accounts: make(map[string][]*watchedAccount),
+		messages: messages,
+		notifier: &Notifier{client: newWebhookClient(), privacy: privacy},
+	}
+
+	names := make(map[string]bool)
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("missing name of watched account %s", config.Address)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate watched account %s", config.Name)
+		}
+		names[config.Name] = true
+
+		key, err := chain.AccountKey(config.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address of watched account %s: %w", config.Name, err)
+		}
+
+		account := &watchedAccount{name: config.Name}
+		if config.Webhook != "" {
+			account.webhook = wa.notifier.add(WebhookConfig{URL: config.Webhook, Secret: config.Secret})
+		}
+		if config.Slack != "" {
+			account.slack = wa.notifier.add(WebhookConfig{URL: config.Slack})
+		}
+		wa.accounts[key] = append(wa.accounts[key], account)
+	}
+
+	return wa, nil
+}
+
+func (wa *Watcher) Start(ctx context.Context, eg *errgroup.Group) {
+	wa.notifier.Start(ctx, eg)
+}
+
+// Observed tags the message of an event and notifies each watched account it involves
+func (wa *Watcher) Observed(event *chain.ObservedEvent) {
+	for _, account := range wa.involved(event) {
+		metrics.WatchedTransfers.WithLabelValues(event.Chain, account.name).Inc()
+
+		fields := log.Fields{
+			"account":     account.name,
+			"chain":       event.Chain,
+			"event":       event.Name,
+			"blockNumber": event.BlockNumber,
+		}
+		log.WithFields(fields).Info("Observed bridge event of watched account")
+
+		if event.Message != nil {
+			err := wa.messages.Tag(event.Chain, event.Message, WatchTagPrefix+account.name)
+			if err != nil {
+				log.WithFields(fields).WithError(err).Warn("Failed to tag message of watched account")
+			}
+		}
+
+		// watched accounts are matched before their fields are masked
+		payload := event
+		if wa.notifier.privacy != nil {
+			payload = wa.notifier.privacy.Event(event)
+		}
+		if account.webhook != nil {
+			wa.notify(account.webhook, event, WatchNotification{Account: account.name, Event: payload})
+		}
+		if account.slack != nil {
+			wa.notify(account.slack, event, slackMessage{Text: watchSummary(account.name, payload)})
+		}
+	}
+}
+
+// involved returns the watched accounts which appear in the fields of an event
+func (wa *Watcher) involved(event *chain.ObservedEvent) []*watchedAccount {
+	var accounts []*watchedAccount
+	seen := make(map[*watchedAccount]bool)
+	for _, value := range event.Fields {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for _, account := range wa.accounts[strings.ToLower(text)] {
+			if !seen[account] {
+				seen[account] = true
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	return accounts
+}
+
+func (wa *Watcher) notify(wh *webhook, event *chain.ObservedEvent, notification interface{}) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.WithError(err).WithField("event", event.Name).Error("Failed to encode notification")
+		return
+	}
+	wh.enqueue(body, event)
+}
+
+// watchSummary describes an event involving a watched account for a chat message
+func watchSummary(account string, event *chain.ObservedEvent) string {
+	text := fmt.Sprintf("Watched account *%s* in %s event of the %s app on %s, block %d",
+		account, event.Name, event.App, event.Chain, event.BlockNumber)
+	if event.TxHash != "" {
+		text += ", transaction " + event.TxHash
+	}
+	if amount, ok := field(event, amountFields).(string); ok {
+		text += ", amount " + amount
+	}
+	return text
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestWatcher(t *testing.T) {
+	requests := make(chan string, 4)
+	bodies := make(chan []byte, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r.URL.Path
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	messages := store.NewMessages(store.NewMemoryDB())
+	watcher, err := NewWatcher([]WatchConfig{
+		{Name: "treasury", Address: "0x89b4AB1eF20763630df9743ACF155865600daFF2", Webhook: server.URL + "/hook"},
+		{Name: "exchange", Address: signature.TestKeyringPairAlice.Address, Slack: server.URL + "/slack"},
+	}, messages, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	watcher.Start(ctx, eg)
+
+	msg := &chain.Message{AppID: common.HexToAddress("0x01"), Payload: []byte{1}}
+	watcher.Observed(&chain.ObservedEvent{
+		Chain:       "Ethereum",
+		App:         "eth",
+		Name:        "Transfer",
+		BlockNumber: 7,
+		Fields: map[string]interface{}{
+			"_sender":    "0x89b4AB1eF20763630df9743ACF155865600daFF2",
+			"_recipient": "0x" + common.Bytes2Hex(signature.TestKeyringPairAlice.PublicKey),
+			"_amount":    "100",
+		},
+		Message: msg,
+	})
+	// events of other accounts are ignored
+	watcher.Observed(&chain.ObservedEvent{
+		Chain:  "Substrate",
+		Name:   "ETH.Transfer",
+		Fields: map[string]interface{}{"accountId": "0x02"},
+	})
+
+	received := make(map[string][]byte)
+	for i := 0; i < 2; i++ {
+		select {
+		case path := <-requests:
+			received[path] = <-bodies
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for notification")
+		}
+	}
+
+	var notification WatchNotification
+	require.NoError(t, json.Unmarshal(received["/hook"], &notification))
+	assert.Equal(t, "treasury", notification.Account)
+	assert.Equal(t, uint64(7), notification.Event.BlockNumber)
+
+	var slack slackMessage
+	require.NoError(t, json.Unmarshal(received["/slack"], &slack))
+	assert.Contains(t, slack.Text, "*exchange*")
+	assert.Contains(t, slack.Text, "amount 100")
+
+	tagged, err := messages.List("", WatchTagPrefix+"treasury")
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.ElementsMatch(t, []string{"watch:treasury", "watch:exchange"}, tagged[0].Tags)
+}
+
+func TestNewWatcher_Invalid(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+
+	_, err := NewWatcher([]WatchConfig{{Address: "0x89b4AB1eF20763630df9743ACF155865600daFF2"}}, messages, nil)
+	assert.Error(t, err, "missing name")
+
+	_, err = NewWatcher([]WatchConfig{{Name: "treasury", Address: "0x1234"}}, messages, nil)
+	assert.Error(t, err, "short address")
+
+	_, err = NewWatcher([]WatchConfig{{Name: "treasury", Address: "5Grwva"}}, messages, nil)
+	assert.Error(t, err, "invalid SS58 address")
+
+	_, err = NewWatcher([]WatchConfig{
+		{Name: "treasury", Address: "0x89b4AB1eF20763630df9743ACF155865600daFF2"},
+		{Name: "treasury", Address: signature.TestKeyringPairAlice.Address},
+	}, messages, nil)
+	assert.Error(t, err, "duplicate name")
+}
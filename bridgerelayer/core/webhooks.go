@@ -0,0 +1,254 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type WebhookConfig struct {
+	// URL to which observed events are posted
+	URL string `mapstructure:"url"`
+	// Secret with which the body of each request is signed. Requests are unsigned if empty.
+	Secret string `mapstructure:"secret"`
+	// Chains whose events are posted, all chains if empty
+	Chains []string `mapstructure:"chains"`
+	// Apps whose events are posted, all apps if empty
+	Apps []string `mapstructure:"apps"`
+}
+
+const (
+	webhookTimeout = 10
+	// webhookQueueSize bounds the memory used by events which are still pending for each webhook
+	webhookQueueSize = 1024
+	webhookAttempts  = 5
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the request body, as sha256=<hex>
+	WebhookSignatureHeader = "X-Artemis-Webhook-Signature"
+)
+
+// Notifier posts the bridge events observed by the listeners to the webhooks registered by
+// integrators. Each webhook has its own queue, so that a slow endpoint doesn't hold back
+// the others, and events are posted to it in the order they were observed. The fields of
+// events are masked if privacy is enabled.
+type Notifier struct {
+	webhooks []*webhook
+	client   *http.Client
+	// nil if disabled
+	privacy *Privacy
+}
+
+type webhook struct {
+	url    string
+	secret []byte
+	chains map[string]bool
+	apps   map[string]bool
+	queue  chan []byte
+}
+
+func NewNotifier(configs []WebhookConfig, privacy *Privacy) (*Notifier, error) {
+	no := &Notifier{client: newWebhookClient(), privacy: privacy}
+
+	for _, config := range configs {
+		if config.URL == "" {
+			return nil, fmt.Errorf("missing URL of webhook")
+		}
+		no.add(config)
+	}
+
+	return no, nil
+}
+
+func newWebhookClient() *http.Client {
+	return &http.Client{Timeout: webhookTimeout * time.Second}
+}
+
+// add registers a webhook, returning it
+func (no *Notifier) add(config WebhookConfig) *webhook {
+	wh := &webhook{
+		url:    config.URL,
+		secret: []byte(config.Secret),
+		chains: lowerSet(config.Chains),
+		apps:   lowerSet(config.Apps),
+		queue:  make(chan []byte, webhookQueueSize),
+	}
+	no.webhooks = append(no.webhooks, wh)
+	return wh
+}
+
+func (no *Notifier) Start(ctx context.Context, eg *errgroup.Group) {
+	for _, wh := range no.webhooks {
+		wh := wh
+		eg.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case body := <-wh.queue:
+					err := no.post(ctx, wh, body)
+					if err != nil && ctx.Err() == nil {
+						log.WithError(err).WithField("url", wh.url).Error("Failed to post event to webhook")
+					}
+				}
+			}
+		})
+	}
+}
+
+// Observed queues an event for each webhook which it matches
+func (no *Notifier) Observed(event *chain.ObservedEvent) {
+	body, err := no.body(event)
+	if err != nil {
+		log.WithError(err).WithField("event", event.Name).Error("Failed to encode observed event")
+		return
+	}
+
+	for _, wh := range no.webhooks {
+		if wh.matches(event) {
+			wh.enqueue(body, event)
+		}
+	}
+}
+
+// body encodes an event as the body of a request, with its fields masked if privacy is
+// enabled
+func (no *Notifier) body(event *chain.ObservedEvent) ([]byte, error) {
+	payload := event
+	if no.privacy != nil {
+		payload = no.privacy.Event(event)
+	}
+	return json.Marshal(payload)
+}
+
+// Consumers returns a consumer for a subscription of each webhook to the observed events,
+// named after a digest of its URL, which may hold credentials. Each posts the events which
+// match its webhook in order, without a queue, and holds back its subscription while its
+// webhook fails.
+func (no *Notifier) Consumers() map[string]EventConsumer {
+	consumers := make(map[string]EventConsumer, len(no.webhooks))
+	for _, wh := range no.webhooks {
+		digest := sha256.Sum256([]byte(wh.url))
+		base := "webhook/" + hex.EncodeToString(digest[:4])
+		// webhooks of the same URL with other filters are told apart by their position
+		name := base
+		for i := 2; consumers[name] != nil; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		consumers[name] = &webhookConsumer{notifier: no, webhook: wh}
+	}
+	return consumers
+}
+
+type webhookConsumer struct {
+	notifier *Notifier
+	webhook  *webhook
+}
+
+func (wc *webhookConsumer) Consume(ctx context.Context, event *chain.ObservedEvent) error {
+	if !wc.webhook.matches(event) {
+		return nil
+	}
+
+	body, err := wc.notifier.body(event)
+	if err != nil {
+		log.WithError(err).WithField("event", event.Name).Error("Failed to encode observed event")
+		return nil
+	}
+	return wc.notifier.post(ctx, wc.webhook, body)
+}
+
+// enqueue queues the body of a request about an event, dropping it if the queue is full
+func (wh *webhook) enqueue(body []byte, event *chain.ObservedEvent) {
+	select {
+	case wh.queue <- body:
+	default:
+		log.WithFields(log.Fields{
+			"url":   wh.url,
+			"event": event.Name,
+		}).Error("Webhook queue is full, dropping event")
+	}
+}
+
+func (wh *webhook) matches(event *chain.ObservedEvent) bool {
+	if len(wh.chains) > 0 && !wh.chains[strings.ToLower(event.Chain)] {
+		return false
+	}
+	if len(wh.apps) > 0 && !wh.apps[strings.ToLower(event.App)] {
+		return false
+	}
+	return true
+}
+
+// post posts the body of a request to a webhook, retrying with a growing delay
+func (no *Notifier) post(ctx context.Context, wh *webhook, body []byte) error {
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = no.send(ctx, wh, body)
+		if err == nil {
+			log.WithField("url", wh.url).Debug("Posted event to webhook")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(1<<uint(attempt)) * time.Second):
+		}
+	}
+	return err
+}
+
+func (no *Notifier) send(ctx context.Context, wh *webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if len(wh.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(wh.secret, body))
+	}
+
+	resp, err := no.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// WebhookSignature returns the hex-encoded HMAC-SHA256 of a request body, with which
+// integrators verify that events were posted by the relayer
+func WebhookSignature(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+	return set
+}
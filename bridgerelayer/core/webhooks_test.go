@@ -0,0 +1,74 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+func TestNotifier(t *testing.T) {
+	signatures := make(chan string, 2)
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signatures <- r.Header.Get(WebhookSignatureHeader)
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier, err := NewNotifier([]WebhookConfig{
+		{URL: server.URL, Secret: "secret", Chains: []string{"ethereum"}, Apps: []string{"ETH"}},
+	}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+	notifier.Start(ctx, eg)
+
+	// only events matching the filters of the webhook are posted
+	notifier.Observed(&chain.ObservedEvent{Chain: "Substrate", App: "eth", Name: "ETH.Transfer"})
+	notifier.Observed(&chain.ObservedEvent{Chain: "Ethereum", App: "erc20", Name: "Transfer"})
+	notifier.Observed(&chain.ObservedEvent{
+		Chain:       "Ethereum",
+		App:         "eth",
+		Name:        "Transfer",
+		BlockNumber: 7,
+		Fields:      map[string]interface{}{"_amount": "100"},
+	})
+
+	signature := <-signatures
+	body := <-bodies
+	assert.Equal(t, "sha256="+WebhookSignature([]byte("secret"), body), signature)
+	assert.Empty(t, bodies)
+
+	var event chain.ObservedEvent
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "Transfer", event.Name)
+	assert.Equal(t, uint64(7), event.BlockNumber)
+	assert.Equal(t, "100", event.Fields["_amount"])
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// test vector from RFC 4231
+	assert.Equal(t,
+		"5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		WebhookSignature([]byte("Jefe"), []byte("what do ya want for nothing?")),
+	)
+
+	_, err := NewNotifier([]WebhookConfig{{Secret: "secret"}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package secp256k1
+
+import (
+	"crypto/rand"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// EncryptKeystore encrypts the private key of a keypair as an Ethereum V3 JSON keystore,
+// which geth, clef and most wallets import
+func EncryptKeystore(kp *Keypair, passphrase string) ([]byte, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	// random UUID, version 4
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	key := &keystore.Key{
+		Id:         id,
+		Address:    kp.CommonAddress(),
+		PrivateKey: kp.private,
+	}
+	return keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// DecryptKeystore decrypts the keypair of an Ethereum V3 JSON keystore
+func DecryptKeystore(keyJSON []byte, passphrase string) (*Keypair, error) {
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeypair(*key.PrivateKey), nil
+}
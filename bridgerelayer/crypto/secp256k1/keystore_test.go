@@ -0,0 +1,33 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package secp256k1
+
+import (
+	"testing"
+)
+
+func TestEncryptAndDecryptKeystore(t *testing.T) {
+	kp, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyJSON, err := EncryptKeystore(kp, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := DecryptKeystore(keyJSON, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address() != kp.Address() {
+		t.Fatalf("Fail: got %s expected %s", res.Address(), kp.Address())
+	}
+
+	_, err = DecryptKeystore(keyJSON, "wrong")
+	if err == nil {
+		t.Fatal("Fail: decrypted keystore with the wrong passphrase")
+	}
+}
@@ -20,6 +20,8 @@ const PrivateKeyLength = 32
 type Keypair struct {
 	public  *ecdsa.PublicKey
 	private *ecdsa.PrivateKey
+	// address of keypairs without keys, nil otherwise
+	address *common.Address
 }
 
 func NewKeypairFromPrivateKey(priv []byte) (*Keypair, error) {
@@ -57,6 +59,12 @@ func NewKeypair(pk ecdsa.PrivateKey) *Keypair {
 	}
 }
 
+// NewAddressKeypair returns the keypair of an account whose key is held elsewhere, such as
+// by a remote signer. It has neither a public nor a private key, and can't sign.
+func NewAddressKeypair(address common.Address) *Keypair {
+	return &Keypair{address: &address}
+}
+
 func GenerateKeypair() (*Keypair, error) {
 	priv, err := secp256k1.GenerateKey()
 	if err != nil {
@@ -80,22 +88,29 @@ func (kp *Keypair) Decode(in []byte) error {
 
 	kp.public = key.Public().(*ecdsa.PublicKey)
 	kp.private = key
+	kp.address = nil
 
 	return nil
 }
 
 // Address returns the Ethereum address format
 func (kp *Keypair) Address() string {
-	return secp256k1.PubkeyToAddress(*kp.public).String()
+	return kp.CommonAddress().String()
 }
 
 // CommonAddress returns the Ethereum address in the common.Address Format
 func (kp *Keypair) CommonAddress() common.Address {
+	if kp.address != nil {
+		return *kp.address
+	}
 	return secp256k1.PubkeyToAddress(*kp.public)
 }
 
-// PublicKey returns the public key hex encoded
+// PublicKey returns the public key hex encoded, empty for keypairs without keys
 func (kp *Keypair) PublicKey() string {
+	if kp.public == nil {
+		return ""
+	}
 	return hexutil.Encode(secp256k1.CompressPubkey(kp.public))
 }
 
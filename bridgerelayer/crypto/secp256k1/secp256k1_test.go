@@ -36,3 +36,18 @@ func TestEncodeAndDecodeKeypair(t *testing.T) {
 		t.Fatalf("Fail: got %#v expected %#v", res, kp)
 	}
 }
+
+func TestNewAddressKeypair(t *testing.T) {
+	kp, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := NewAddressKeypair(kp.CommonAddress())
+	if remote.CommonAddress() != kp.CommonAddress() || remote.Address() != kp.Address() {
+		t.Fatalf("address %s doesn't match %s", remote.Address(), kp.Address())
+	}
+	if remote.PublicKey() != "" || remote.PrivateKey() != nil {
+		t.Fatalf("keypair without keys has key data: %#v", remote)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package sr25519
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Keyring JSON files wrap the secret of a keypair in the envelope of the polkadot-js
+// keyring: a base64 payload of the scrypt parameters, followed by a nonce and the secret
+// sealed with xsalsa20-poly1305. As keypairs sign through subkey, the secret is the
+// secret URI of the keypair rather than its expanded PKCS8 key, so that keyrings exported
+// from polkadot-js can't be used.
+const (
+	keyringVersion = "3"
+	keyringContent = "uri"
+	scryptSaltLen  = 32
+	scryptN        = 1 << 15
+	scryptP        = 1
+	scryptR        = 8
+	// bounds on the work of decrypting an untrusted keyring
+	maxScryptN = 1 << 20
+	maxScryptP = 16
+	maxScryptR = 16
+	nonceLen   = 24
+)
+
+var errDecrypt = errors.New("could not decrypt keyring with the passphrase")
+
+type keyringJSON struct {
+	Encoded  string          `json:"encoded"`
+	Encoding keyringEncoding `json:"encoding"`
+	Address  string          `json:"address"`
+	Meta     keyringMeta     `json:"meta"`
+}
+
+type keyringEncoding struct {
+	Content []string `json:"content"`
+	Type    []string `json:"type"`
+	Version string   `json:"version"`
+}
+
+type keyringMeta struct {
+	Name string `json:"name,omitempty"`
+}
+
+// NewSecretURI returns the secret URI of a new keypair, a random hex seed
+func NewSecretURI() (string, error) {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(seed), nil
+}
+
+// EncryptKeyring encrypts the secret URI of a keypair, with its SS58 address, as keyring JSON
+func EncryptKeyring(uri string, address string, passphrase string) ([]byte, error) {
+	salt := make([]byte, scryptSaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceLen]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keyringKey(passphrase, salt, scryptN, scryptP, scryptR)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]byte, 12)
+	binary.LittleEndian.PutUint32(params[0:], scryptN)
+	binary.LittleEndian.PutUint32(params[4:], scryptP)
+	binary.LittleEndian.PutUint32(params[8:], scryptR)
+
+	encoded := append(append(salt, params...), nonce[:]...)
+	encoded = secretbox.Seal(encoded, []byte(uri), &nonce, key)
+
+	return json.MarshalIndent(&keyringJSON{
+		Encoded: base64.StdEncoding.EncodeToString(encoded),
+		Encoding: keyringEncoding{
+			Content: []string{keyringContent, "sr25519"},
+			Type:    []string{"scrypt", "xsalsa20-poly1305"},
+			Version: keyringVersion,
+		},
+		Address: address,
+		Meta:    keyringMeta{Name: "artemis-relay"},
+	}, "", "  ")
+}
+
+// DecryptKeyring decrypts the secret URI of a keypair and its SS58 address from keyring JSON
+func DecryptKeyring(keyJSON []byte, passphrase string) (string, string, error) {
+	var keyring keyringJSON
+	err := json.Unmarshal(keyJSON, &keyring)
+	if err != nil {
+		return "", "", err
+	}
+
+	if keyring.Encoding.Version != keyringVersion {
+		return "", "", fmt.Errorf("unsupported keyring version %q", keyring.Encoding.Version)
+	}
+	if len(keyring.Encoding.Content) == 0 || keyring.Encoding.Content[0] != keyringContent {
+		return "", "", fmt.Errorf("unsupported keyring content %v, only secret URIs can be used", keyring.Encoding.Content)
+	}
+	if len(keyring.Encoding.Type) != 2 || keyring.Encoding.Type[0] != "scrypt" || keyring.Encoding.Type[1] != "xsalsa20-poly1305" {
+		return "", "", fmt.Errorf("unsupported keyring encryption %v", keyring.Encoding.Type)
+	}
+
+	encoded, err := base64.StdEncoding.DecodeString(keyring.Encoded)
+	if err != nil {
+		return "", "", err
+	}
+	if len(encoded) < scryptSaltLen+12+nonceLen+secretbox.Overhead {
+		return "", "", errors.New("keyring is truncated")
+	}
+
+	salt := encoded[:scryptSaltLen]
+	n := binary.LittleEndian.Uint32(encoded[scryptSaltLen:])
+	p := binary.LittleEndian.Uint32(encoded[scryptSaltLen+4:])
+	r := binary.LittleEndian.Uint32(encoded[scryptSaltLen+8:])
+	if n > maxScryptN || p > maxScryptP || r > maxScryptR {
+		return "", "", fmt.Errorf("scrypt parameters N=%d p=%d r=%d of keyring are too costly", n, p, r)
+	}
+
+	var nonce [nonceLen]byte
+	copy(nonce[:], encoded[scryptSaltLen+12:])
+	sealed := encoded[scryptSaltLen+12+nonceLen:]
+
+	key, err := keyringKey(passphrase, salt, int(n), int(p), int(r))
+	if err != nil {
+		return "", "", err
+	}
+
+	uri, ok := secretbox.Open(nil, sealed, &nonce, key)
+	if !ok {
+		return "", "", errDecrypt
+	}
+	return string(uri), keyring.Address, nil
+}
+
+// keyringKey derives the key sealing the secret from the passphrase
+func keyringKey(passphrase string, salt []byte, n, p, r int) (*[32]byte, error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 64)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], derived)
+	return &key, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package sr25519
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecryptKeyring(t *testing.T) {
+	uri, err := NewSecretURI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+
+	keyJSON, err := EncryptKeyring(uri, address, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resURI, resAddress, err := DecryptKeyring(keyJSON, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resURI != uri || resAddress != address {
+		t.Fatalf("Fail: got %s at %s expected %s at %s", resURI, resAddress, uri, address)
+	}
+
+	_, _, err = DecryptKeyring(keyJSON, "wrong")
+	if err == nil {
+		t.Fatal("Fail: decrypted keyring with the wrong passphrase")
+	}
+}
+
+func TestDecryptKeyring_PKCS8(t *testing.T) {
+	keyJSON, err := EncryptKeyring("//Alice", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keyrings exported from polkadot-js hold expanded keys, which can't sign through subkey
+	exported := strings.Replace(string(keyJSON), `"uri"`, `"pkcs8"`, 1)
+	_, _, err = DecryptKeyring([]byte(exported), "passphrase")
+	if err == nil {
+		t.Fatal("Fail: decrypted keyring holding a PKCS8 key")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package ss58 encodes the addresses of Substrate accounts in the SS58 format, where
+// the public key of an account is preceded by the prefix of its network and followed
+// by a checksum, all in base58.
+package ss58
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// MaxPrefix is the largest network prefix which can be encoded
+	MaxPrefix = 16383
+	// DefaultPrefix is the prefix of generic Substrate chains
+	DefaultPrefix = 42
+	// publicKeyLength is the length of account public keys
+	publicKeyLength = 32
+	// checksumLength is the length of the checksum of account addresses
+	checksumLength = 2
+)
+
+var checksumContext = []byte("SS58PRE")
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Encode returns the address of an account on the network with a prefix
+func Encode(publicKey []byte, prefix uint16) (string, error) {
+	if prefix > MaxPrefix {
+		return "", fmt.Errorf("SS58 prefix %d exceeds %d", prefix, MaxPrefix)
+	}
+	if len(publicKey) != publicKeyLength {
+		return "", fmt.Errorf("public key has %d bytes instead of %d", len(publicKey), publicKeyLength)
+	}
+
+	data := append(encodePrefix(prefix), publicKey...)
+	return encodeBase58(append(data, checksum(data)...)), nil
+}
+
+// Decode returns the public key of the account with an address and the prefix of its network
+func Decode(address string) ([]byte, uint16, error) {
+	data, err := decodeBase58(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("empty address")
+	}
+
+	prefixLength := 1
+	if data[0]&0x40 != 0 {
+		prefixLength = 2
+	}
+	if len(data) != prefixLength+publicKeyLength+checksumLength {
+		return nil, 0, fmt.Errorf("address %s is not an account address", address)
+	}
+
+	body := data[:len(data)-checksumLength]
+	if !bytes.Equal(checksum(body), data[len(body):]) {
+		return nil, 0, fmt.Errorf("invalid checksum of address %s", address)
+	}
+
+	prefix := uint16(data[0])
+	if prefixLength == 2 {
+		prefix = uint16(data[0]&0x3f)<<2 | uint16(data[1]>>6) | uint16(data[1]&0x3f)<<8
+	}
+	return append([]byte{}, body[prefixLength:]...), prefix, nil
+}
+
+// DecodeWithPrefix decodes the address of an account on the network with a prefix,
+// rejecting the addresses of other networks
+func DecodeWithPrefix(address string, prefix uint16) ([]byte, error) {
+	publicKey, actual, err := Decode(address)
+	if err != nil {
+		return nil, err
+	}
+	if actual != prefix {
+		return nil, fmt.Errorf("address %s has SS58 prefix %d instead of %d", address, actual, prefix)
+	}
+	return publicKey, nil
+}
+
+// encodePrefix encodes prefixes below 64 in one byte, and larger prefixes in two
+func encodePrefix(prefix uint16) []byte {
+	if prefix < 64 {
+		return []byte{byte(prefix)}
+	}
+	return []byte{
+		0x40 | byte((prefix&0xfc)>>2),
+		byte(prefix>>8) | byte((prefix&0x03)<<6),
+	}
+}
+
+func checksum(data []byte) []byte {
+	hash := blake2b.Sum512(append(append([]byte{}, checksumContext...), data...))
+	return hash[:checksumLength]
+}
+
+func encodeBase58(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, digit)
+		encoded = append(encoded, alphabet[digit.Int64()])
+	}
+	// leading zero bytes are kept as leading ones
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+func decodeBase58(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(int64(len(alphabet)))
+
+	zeros := 0
+	for i, c := range encoded {
+		index := bytes.IndexRune([]byte(alphabet), c)
+		if index < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		if index == 0 && i == zeros {
+			zeros++
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(index)))
+	}
+
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ss58_test
+
+import (
+	"testing"
+
+	"github.com/snowfork/go-substrate-rpc-client/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/crypto/ss58"
+)
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		prefix  uint16
+		address string
+	}{
+		{0, "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+		{2, "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F"},
+		{42, signature.TestKeyringPairAlice.Address},
+	}
+
+	for _, c := range cases {
+		address, err := ss58.Encode(signature.TestKeyringPairAlice.PublicKey, c.prefix)
+		require.NoError(t, err)
+		assert.Equal(t, c.address, address)
+
+		publicKey, prefix, err := ss58.Decode(address)
+		require.NoError(t, err)
+		assert.Equal(t, signature.TestKeyringPairAlice.PublicKey, publicKey)
+		assert.Equal(t, c.prefix, prefix)
+	}
+}
+
+func TestEncode_TwoBytePrefix(t *testing.T) {
+	for _, prefix := range []uint16{64, 255, 1284, ss58.MaxPrefix} {
+		address, err := ss58.Encode(signature.TestKeyringPairAlice.PublicKey, prefix)
+		require.NoError(t, err)
+
+		publicKey, decoded, err := ss58.Decode(address)
+		require.NoError(t, err)
+		assert.Equal(t, signature.TestKeyringPairAlice.PublicKey, publicKey)
+		assert.Equal(t, prefix, decoded)
+	}
+
+	_, err := ss58.Encode(signature.TestKeyringPairAlice.PublicKey, ss58.MaxPrefix+1)
+	assert.Error(t, err)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	alice := signature.TestKeyringPairAlice.Address
+
+	_, _, err := ss58.Decode(alice[:len(alice)-1] + "Z")
+	assert.Error(t, err, "checksum")
+
+	_, _, err = ss58.Decode("0x" + alice[2:])
+	assert.Error(t, err, "base58 alphabet")
+
+	_, _, err = ss58.Decode(alice[:20])
+	assert.Error(t, err, "length")
+
+	_, err = ss58.DecodeWithPrefix(alice, 0)
+	assert.Error(t, err, "network")
+
+	publicKey, err := ss58.DecodeWithPrefix(alice, ss58.DefaultPrefix)
+	require.NoError(t, err)
+	assert.Equal(t, signature.TestKeyringPairAlice.PublicKey, publicKey)
+}
@@ -0,0 +1,480 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package metrics defines the Prometheus metrics exported by the relayer.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "artemis_relay"
+
+var (
+	// BlockHoles is the number of ranges of skipped blocks per chain
+	BlockHoles = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "block_holes",
+		Help:      "Number of ranges of blocks which were skipped by the listener.",
+	}, []string{"chain"})
+
+	// MissingBlocks is the total number of skipped blocks per chain
+	MissingBlocks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "missing_blocks",
+		Help:      "Number of blocks which were skipped by the listener.",
+	}, []string{"chain"})
+
+	// LatestBlock is the latest block known to the listener per chain, finalized on Substrate
+	LatestBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "latest_block",
+		Help:      "Latest block known to the listener.",
+	}, []string{"chain"})
+
+	// ProcessedBlock is the last block whose events the listener processed per chain
+	ProcessedBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "processed_block",
+		Help:      "Last block whose events were fully processed by the listener.",
+	}, []string{"chain"})
+
+	// BlocksProcessed is the number of blocks processed by the listener per chain
+	BlocksProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_processed_total",
+		Help:      "Number of blocks whose events were processed by the listener, including repaired and replayed blocks.",
+	}, []string{"chain"})
+
+	// EventsDecoded is the number of bridge events decoded by the listener per chain and app
+	EventsDecoded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_decoded_total",
+		Help:      "Number of bridge events decoded by the listener.",
+	}, []string{"chain", "app"})
+
+	// EndpointHead is the latest or finalized head served by each endpoint of a chain
+	EndpointHead = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_head",
+		Help:      "Latest or finalized block served by an endpoint, as last compared.",
+	}, []string{"chain", "endpoint", "head"})
+
+	// EndpointLag is the number of blocks by which the head of an endpoint lags behind the
+	// highest head of all endpoints of its chain
+	EndpointLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_head_lag",
+		Help:      "Blocks by which the latest or finalized head of an endpoint lags behind the highest of all endpoints of the chain.",
+	}, []string{"chain", "endpoint", "head"})
+
+	// EndpointForks is the number of comparisons in which an endpoint served another
+	// finalized block than the primary endpoint of its chain
+	EndpointForks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "endpoint_forks_total",
+		Help:      "Number of comparisons in which an endpoint served another finalized block than the primary endpoint.",
+	}, []string{"chain", "endpoint"})
+
+	// FieldsTruncated is the number of events relayed with oversized arguments truncated per
+	// chain and app
+	FieldsTruncated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fields_truncated_total",
+		Help:      "Number of events whose oversized arguments were truncated before relaying.",
+	}, []string{"chain", "app"})
+
+	// MessagesEnqueued is the number of messages queued for relaying per source chain and app
+	MessagesEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_enqueued_total",
+		Help:      "Number of messages queued for relaying by the listener.",
+	}, []string{"chain", "app"})
+
+	// TransactionsSubmitted is the number of deliveries submitted per target chain and app
+	TransactionsSubmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_submitted_total",
+		Help:      "Number of transactions or extrinsics submitted to deliver messages.",
+	}, []string{"chain", "app"})
+
+	// TransactionsConfirmed is the number of deliveries confirmed per target chain and app
+	TransactionsConfirmed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_confirmed_total",
+		Help:      "Number of deliveries confirmed by their inclusion in a block. Only counted while deliveries are followed.",
+	}, []string{"chain", "app"})
+
+	// TransactionsFailed is the number of deliveries which failed per target chain and app
+	TransactionsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_failed_total",
+		Help:      "Number of deliveries which could not be submitted, or which failed or were dropped after submission.",
+	}, []string{"chain", "app"})
+
+	// BatchSize is the number of messages in each batch delivery per chain and app
+	BatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Number of messages submitted together in one batch delivery.",
+		Buckets:   []float64{2, 4, 8, 16, 32, 64},
+	}, []string{"chain", "app"})
+
+	// NonceAnomalies is the number of nonce gaps and of external transactions from the
+	// relayer account detected per chain
+	NonceAnomalies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nonce_anomalies_total",
+		Help:      "Nonce gaps left by dropped transactions, and transactions sent by others from the relayer account.",
+	}, []string{"chain", "kind"})
+
+	// UnoriginatedTransactions is the number of transactions from the relayer accounts which
+	// the relayer did not originate, detected per chain
+	UnoriginatedTransactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unoriginated_transactions_total",
+		Help:      "Transactions sent from the relayer accounts which the relayer did not originate.",
+	}, []string{"chain"})
+
+	// PendingTransactions is the number of transactions awaiting their confirmation depth per chain
+	PendingTransactions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_transactions",
+		Help:      "Number of submitted transactions awaiting their confirmation depth. Only tracked while confirmations are tracked.",
+	}, []string{"chain"})
+
+	// OldestPendingTransaction is the submission time of the oldest pending transaction per chain
+	OldestPendingTransaction = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "oldest_pending_transaction_timestamp_seconds",
+		Help:      "Unix time of the submission of the oldest transaction awaiting its confirmation depth, zero if none is.",
+	}, []string{"chain"})
+
+	// TransactionRebroadcasts is the number of stuck transactions rebroadcast with a bumped
+	// gas price per chain
+	TransactionRebroadcasts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transaction_rebroadcasts_total",
+		Help:      "Number of transactions stuck in the mempool which were rebroadcast with a bumped gas price.",
+	}, []string{"chain"})
+
+	// FeePerGas is the base fee of the next block and the priority fee suggested by the fee
+	// oracle per chain
+	FeePerGas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fee_per_gas_wei",
+		Help:      "Base fee of the next block and priority fee suggested from recent blocks, in wei per gas.",
+	}, []string{"chain", "fee"})
+
+	// PrefetchedBlocks is the number of blocks prefetched from the best chain per chain, by
+	// whether they were used, discarded as another block finalized, or missed
+	PrefetchedBlocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prefetched_blocks_total",
+		Help:      "Blocks prefetched from the best chain while waiting for finality, by whether they were used, discarded or not ready in time.",
+	}, []string{"chain", "outcome"})
+
+	// Reorgs is the number of reorgs detected by the listener per chain
+	Reorgs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Reorgs which replaced blocks the listener had seen.",
+	}, []string{"chain"})
+
+	// OrphanedMessages is the number of relayed messages per chain whose events were emitted
+	// in blocks which a reorg replaced
+	OrphanedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orphaned_messages_total",
+		Help:      "Relayed messages whose events were emitted in blocks replaced by a reorg, and which were invalidated.",
+	}, []string{"chain"})
+
+	// LightClientFallbacks is the number of Substrate calls sent to the full node by method and
+	// by whether the light client doesn't serve the method or failed to serve the call
+	LightClientFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "light_client_fallbacks_total",
+		Help:      "Number of Substrate calls which the light client couldn't serve and were sent to the full node.",
+	}, []string{"method", "reason"})
+
+	// DeadLetters is the number of messages in the dead-letter queue per target chain
+	DeadLetters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dead_letters",
+		Help:      "Number of messages whose delivery failed permanently, awaiting inspection or requeueing.",
+	}, []string{"chain"})
+
+	// SubmissionDelay is the time from the observation of messages to their submission per target chain
+	SubmissionDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "submission_delay_seconds",
+		Help:      "Time from the observation of a message by the listener to the submission of its delivery.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"chain"})
+
+	// RPCCalls is the number of RPC calls per chain, endpoint and method
+	RPCCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_calls_total",
+		Help:      "Number of RPC calls made to an endpoint.",
+	}, []string{"chain", "endpoint", "method"})
+
+	// RPCErrors is the number of failed RPC calls per chain, endpoint and method
+	RPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Number of RPC calls to an endpoint which failed.",
+	}, []string{"chain", "endpoint", "method"})
+
+	// RPCRetries is the number of retries of failed calls per chain
+	RPCRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_retries_total",
+		Help:      "Number of retries of calls which failed with a transient error.",
+	}, []string{"chain"})
+
+	// RPCLatency is the latency of RPC calls per chain, endpoint and method
+	RPCLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_latency_seconds",
+		Help:      "Latency of RPC calls made to an endpoint.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"chain", "endpoint", "method"})
+
+	// EndpointHealth is the health score of each endpoint of a chain, from 0 to 1
+	EndpointHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_health",
+		Help:      "Health score of an endpoint, decaying with its failed calls and recovering with its successful calls.",
+	}, []string{"chain", "endpoint"})
+
+	// EndpointActive is 1 for the endpoint of a chain to which calls are made, 0 for the others
+	EndpointActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_active",
+		Help:      "Whether calls to the chain are made to an endpoint.",
+	}, []string{"chain", "endpoint"})
+
+	// EndpointSwitches counts the switches to each endpoint of a chain by reason, which is
+	// failover or failback
+	EndpointSwitches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "endpoint_switches_total",
+		Help:      "Switches of the calls to the chain to an endpoint, failing over from a failed endpoint or back to a preferred one.",
+	}, []string{"chain", "endpoint", "reason"})
+
+	// RuntimeSpecVersion is the spec version of the runtime whose metadata the relayer uses
+	RuntimeSpecVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runtime_spec_version",
+		Help:      "Spec version of the runtime whose metadata encodes calls and decodes events.",
+	}, []string{"chain"})
+
+	// WriterDiagnostics is the number of times the state of a writer was captured as its
+	// queues backed up, per chain
+	WriterDiagnostics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "writer_diagnostics_total",
+		Help:      "Number of captures of the state of a writer whose queues backed up.",
+	}, []string{"chain"})
+
+	// AppQueueDepth is the number of messages queued for submission per chain and app
+	AppQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "app_queue_depth",
+		Help:      "Number of messages queued for submission to an app.",
+	}, []string{"chain", "app"})
+
+	// AppInFlight is the number of messages being submitted per chain and app
+	AppInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "app_in_flight",
+		Help:      "Number of messages being submitted to an app.",
+	}, []string{"chain", "app"})
+
+	// BudgetEscalations is the number of submissions escalated per chain and app
+	BudgetEscalations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "budget_escalations_total",
+		Help:      "Number of messages whose submission was escalated to meet their latency budget.",
+	}, []string{"chain", "app"})
+
+	// BoostedMessages is the number of queued messages boosted by operators per chain and app
+	BoostedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "boosted_messages_total",
+		Help:      "Number of queued messages whose submission was boosted by an operator.",
+	}, []string{"chain", "app"})
+
+	// BudgetAlerts is the number of messages at risk of missing their latency budget per chain and app
+	BudgetAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "budget_alerts_total",
+		Help:      "Number of messages which had consumed most of their latency budget when submitted.",
+	}, []string{"chain", "app"})
+
+	// BudgetExceeded is the number of messages which missed their latency budget per chain and app
+	BudgetExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "budget_exceeded_total",
+		Help:      "Number of messages submitted after their latency budget was depleted.",
+	}, []string{"chain", "app"})
+
+	// CostCapExceeded is the number of deliveries refused for their estimated cost per chain and app
+	CostCapExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cost_cap_exceeded_total",
+		Help:      "Number of deliveries refused as their estimated gas, weight or fee per message exceeded the cap.",
+	}, []string{"chain", "app"})
+
+	// DuplicateEvents is the number of events observed more than once per chain
+	DuplicateEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicate_events_total",
+		Help:      "Number of events observed more than once, which were not enqueued again.",
+	}, []string{"chain"})
+
+	// SuppressedDuplicates is the number of duplicate messages which were not routed per chain and retention
+	SuppressedDuplicates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suppressed_duplicates_total",
+		Help:      "Number of messages not routed because they duplicate a message already routed.",
+	}, []string{"chain", "retention"})
+
+	// DeliveryCost is the fees paid for confirmed deliveries per chain and reporting currency
+	DeliveryCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_cost_total",
+		Help:      "Fees paid for confirmed deliveries, in the reporting currency.",
+	}, []string{"chain", "currency"})
+
+	// GasOverpayment is the wei reserved by the max fee of confirmed deliveries but not paid per chain
+	GasOverpayment = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gas_overpayment_wei_total",
+		Help:      "Difference between the max fee and the effective gas price of confirmed deliveries, times their gas used, in wei.",
+	}, []string{"chain"})
+
+	// GasPremium is the percentage added to the suggested gas price by fee tuning per chain
+	GasPremium = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "gas_premium_percent",
+		Help:      "Percentage added to the suggested gas price by fee tuning.",
+	}, []string{"chain"})
+
+	// TunedPending is the number of deliveries which throughput tuning lets pend inclusion per chain
+	TunedPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tuned_pending",
+		Help:      "Number of deliveries which may pend inclusion at once, as tuned to the capacity of the chain.",
+	}, []string{"chain"})
+
+	// TunedRate is the submissions per minute allowed by throughput tuning per chain
+	TunedRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tuned_rate_per_minute",
+		Help:      "Submissions per minute, as tuned to the capacity of the chain. Zero if the rate is not limited.",
+	}, []string{"chain"})
+
+	// InclusionLatency is the time from submission to inclusion of deliveries per chain
+	InclusionLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "inclusion_latency_seconds",
+		Help:      "Time from the submission of a delivery to its inclusion in a block.",
+		Buckets:   []float64{6, 12, 30, 60, 120, 300, 600, 1800},
+	}, []string{"chain"})
+
+	// RecordedSequence is the latest sequence number of the messages recorded per source chain
+	RecordedSequence = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "recorded_sequence",
+		Help:      "Bridge-wide sequence number of the latest message recorded from a chain.",
+	}, []string{"chain"})
+
+	// DeliveredSequence is the highest sequence number of the messages confirmed per target chain
+	DeliveredSequence = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delivered_sequence",
+		Help:      "Highest bridge-wide sequence number of the messages confirmed as delivered to a chain.",
+	}, []string{"chain"})
+
+	// RevertRetries counts the checks of reverted deliveries per chain and outcome, which is
+	// retried, waiting or skipped
+	RevertRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revert_retries_total",
+		Help:      "Gas-free checks of reverted deliveries, by whether the delivery was retried, kept waiting or skipped.",
+	}, []string{"chain", "outcome"})
+
+	// WatchedTransfers counts the observed events involving each watched account
+	WatchedTransfers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watched_transfers_total",
+		Help:      "Number of observed bridge events involving a watched account.",
+	}, []string{"chain", "account"})
+
+	// SubscriptionLag is the number of journal events which each subscription to observed
+	// events has yet to read
+	SubscriptionLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subscription_lag_events",
+		Help:      "Number of journal events a subscription to observed events has yet to read.",
+	}, []string{"subscription"})
+
+	// TaskRuns counts the runs of each scheduled task by outcome, which is succeeded,
+	// failed or skipped
+	TaskRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_runs_total",
+		Help:      "Runs of scheduled maintenance tasks, by whether they succeeded, failed or were skipped while a previous run was in progress.",
+	}, []string{"task", "outcome"})
+
+	// TaskLastSuccess is the time of the last successful run of each scheduled task
+	TaskLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "task_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of a scheduled maintenance task.",
+	}, []string{"task"})
+
+	// AccountBalance is the last balance read of each account paying for the submissions of
+	// a writer, in base units of the native asset of its chain
+	AccountBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "account_balance",
+		Help:      "Balance of an account paying for submissions, in base units of the native asset of the chain.",
+	}, []string{"chain", "account"})
+
+	// OnChainParameter is the last value read of each watched on-chain parameter
+	OnChainParameter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "onchain_parameter",
+		Help:      "Last value read of a watched on-chain parameter.",
+	}, []string{"parameter"})
+
+	// ParameterChanges counts the changes of each watched on-chain parameter
+	ParameterChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "onchain_parameter_changes_total",
+		Help:      "Number of changes of a watched on-chain parameter.",
+	}, []string{"parameter"})
+
+	// RelayerInfo identifies the relayer instance through its labels, and is always 1
+	RelayerInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "relayer_info",
+		Help:      "Identity, version and configuration hash of the relayer.",
+	}, []string{"id", "label", "environment", "version", "config_hash"})
+)
+
+func init() {
+	prometheus.MustRegister(BlockHoles, MissingBlocks, RPCCalls, RPCErrors, RPCRetries, RPCLatency, AppQueueDepth, AppInFlight,
+		BudgetEscalations, BudgetAlerts, BudgetExceeded, CostCapExceeded, BoostedMessages, DuplicateEvents, SuppressedDuplicates, RelayerInfo,
+		DeliveryCost, GasOverpayment, GasPremium, RevertRetries, WatchedTransfers, SubscriptionLag,
+		TunedPending, TunedRate, InclusionLatency, RecordedSequence, DeliveredSequence,
+		TaskRuns, TaskLastSuccess, OnChainParameter, ParameterChanges, AccountBalance, LatestBlock, ProcessedBlock, BlocksProcessed, EventsDecoded, MessagesEnqueued,
+		FieldsTruncated, TransactionsSubmitted, TransactionsConfirmed, TransactionsFailed, SubmissionDelay,
+		EndpointHead, EndpointLag, EndpointForks, DeadLetters,
+		BatchSize, NonceAnomalies, UnoriginatedTransactions, PendingTransactions, OldestPendingTransaction, TransactionRebroadcasts, FeePerGas, LightClientFallbacks, PrefetchedBlocks, Reorgs, OrphanedMessages,
+		EndpointHealth, EndpointActive, EndpointSwitches, RuntimeSpecVersion,
+		WriterDiagnostics)
+}
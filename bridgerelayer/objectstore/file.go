@@ -0,0 +1,51 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package objectstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileBucket stores objects as files below a directory, for example on a
+// network file system which is replicated by other means
+type FileBucket struct {
+	dir string
+}
+
+func NewFileBucket(dir string) *FileBucket {
+	return &FileBucket{dir: dir}
+}
+
+func (fb *FileBucket) Put(_ context.Context, key string, data []byte) error {
+	path := fb.path(key)
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that readers never observe partial objects
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (fb *FileBucket) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fb.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (fb *FileBucket) path(key string) string {
+	return filepath.Join(fb.dir, filepath.FromSlash(key))
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package objectstore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/objectstore"
+)
+
+func TestFileBucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artemis-relay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bucket, err := objectstore.Open(&objectstore.Config{URL: "file://" + dir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = bucket.Get(ctx, "snapshots/latest.gz")
+	assert.Equal(t, objectstore.ErrNotFound, err)
+
+	require.NoError(t, bucket.Put(ctx, "snapshots/latest.gz", []byte("one")))
+	require.NoError(t, bucket.Put(ctx, "snapshots/latest.gz", []byte("two")))
+
+	data, err := bucket.Get(ctx, "snapshots/latest.gz")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), data)
+}
+
+func TestOpen_Unsupported(t *testing.T) {
+	_, err := objectstore.Open(&objectstore.Config{URL: "ftp://example.com/bucket"})
+	assert.Error(t, err)
+}
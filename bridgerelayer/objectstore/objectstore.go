@@ -0,0 +1,52 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package objectstore provides access to buckets on object storage services,
+// or to a local directory laid out in the same way.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Bucket stores objects under slash-separated keys
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+type Config struct {
+	// Location of the bucket, for example s3://bucket/prefix or file:///var/lib/artemis-relay
+	URL string `mapstructure:"url"`
+	// Region of the bucket on S3
+	Region string `mapstructure:"region"`
+	// Endpoint of S3-compatible services, for example https://storage.googleapis.com for GCS
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Open opens the bucket described by the config. Credentials for S3 are read
+// from the environment or the shared credentials file.
+func Open(config *Config) (Bucket, error) {
+	location, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(location.Path, "/")
+
+	switch location.Scheme {
+	case "file":
+		return NewFileBucket(location.Path), nil
+	case "s3":
+		return NewS3Bucket(location.Host, prefix, config.Region, config.Endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported object storage url: %s", config.URL)
+	}
+}
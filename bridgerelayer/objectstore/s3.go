@@ -0,0 +1,73 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Bucket stores objects in a bucket on S3 or an S3-compatible service
+type S3Bucket struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func NewS3Bucket(bucket string, prefix string, region string, endpoint string) (*S3Bucket, error) {
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Bucket{
+		client: s3.New(sess),
+		bucket: bucket,
+		prefix: prefix,
+	}, nil
+}
+
+func (sb *S3Bucket) Put(ctx context.Context, key string, data []byte) error {
+	_, err := sb.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(sb.bucket),
+		Key:    aws.String(sb.key(key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (sb *S3Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	output, err := sb.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sb.bucket),
+		Key:    aws.String(sb.key(key)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+func (sb *S3Bucket) key(key string) string {
+	return path.Join(sb.prefix, key)
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package pricing converts the fees paid on each chain into a common reporting currency,
+// so that delivery costs can be compared across chains. Prices are taken from a
+// configurable feed and cached, with fixed prices for assets the feed does not cover.
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/units"
+)
+
+type Config struct {
+	// Currency in which costs are reported, for example USD. Pricing is disabled if empty.
+	Currency string `mapstructure:"currency"`
+	// URL of the price feed, which serves a JSON object of prices in the reporting currency
+	// keyed by asset symbol. Only fixed prices are used if empty.
+	Feed string `mapstructure:"feed"`
+	// Seconds for which prices from the feed are cached
+	CacheTTL uint64 `mapstructure:"cache-ttl"`
+	// Timeout in seconds of each request to the feed
+	Timeout uint64 `mapstructure:"timeout"`
+	// Fixed prices keyed by asset symbol, used for assets missing from the feed
+	Prices map[string]string `mapstructure:"prices"`
+	// Asset in which fees are paid on each chain, keyed by chain name
+	Assets map[string]Asset `mapstructure:"assets"`
+}
+
+// Asset is the native asset of a chain
+type Asset struct {
+	Symbol   string `mapstructure:"symbol"`
+	Decimals uint8  `mapstructure:"decimals"`
+}
+
+const (
+	defaultCacheTTL = 60
+	defaultTimeout  = 10
+)
+
+// Converter converts amounts of the native asset of each chain into the reporting currency
+type Converter struct {
+	currency string
+	feed     string
+	ttl      time.Duration
+	client   *http.Client
+	fixed    map[string]*big.Rat
+	// assets keyed by lower case chain name, guarded by assetMutex
+	assets     map[string]Asset
+	assetMutex sync.Mutex
+	// guards the cached prices of the feed
+	mutex     sync.Mutex
+	cached    map[string]*big.Rat
+	fetchedAt time.Time
+	log       *logrus.Entry
+}
+
+// NewConverter returns nil if pricing is disabled
+func NewConverter(config *Config, log *logrus.Entry) (*Converter, error) {
+	if config.Currency == "" {
+		return nil, nil
+	}
+
+	ttl := config.CacheTTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	co := &Converter{
+		currency: config.Currency,
+		feed:     config.Feed,
+		ttl:      time.Duration(ttl) * time.Second,
+		client:   &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		fixed:    make(map[string]*big.Rat),
+		assets:   make(map[string]Asset),
+		log:      log,
+	}
+
+	for symbol, value := range config.Prices {
+		price, err := parsePrice(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price of %s: %w", symbol, err)
+		}
+		co.fixed[strings.ToUpper(symbol)] = price
+	}
+
+	for name, asset := range config.Assets {
+		if asset.Symbol == "" {
+			return nil, fmt.Errorf("missing symbol of the asset of chain %s", name)
+		}
+		if asset.Decimals > units.MaxDecimals {
+			return nil, fmt.Errorf("asset of chain %s has more than %d decimals", name, units.MaxDecimals)
+		}
+		co.assets[strings.ToLower(name)] = asset
+	}
+
+	return co, nil
+}
+
+// Currency returns the reporting currency
+func (co *Converter) Currency() string {
+	return co.currency
+}
+
+// Convert returns the value in the reporting currency of an amount in base units of the
+// native asset of a chain
+func (co *Converter) Convert(ctx context.Context, chain string, amount *big.Int) (*big.Rat, error) {
+	co.assetMutex.Lock()
+	asset, ok := co.assets[strings.ToLower(chain)]
+	co.assetMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no asset configured for chain %s", chain)
+	}
+
+	price, err := co.price(ctx, strings.ToUpper(asset.Symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(asset.Decimals)), nil)
+	value := new(big.Rat).SetFrac(amount, scale)
+	return value.Mul(value, price), nil
+}
+
+// Discover sets the asset of a chain to the native token it reports, unless an asset is
+// configured for the chain
+func (co *Converter) Discover(chain string, symbol string, decimals uint8) {
+	co.assetMutex.Lock()
+	defer co.assetMutex.Unlock()
+
+	if _, ok := co.assets[strings.ToLower(chain)]; ok {
+		return
+	}
+	co.assets[strings.ToLower(chain)] = Asset{Symbol: symbol, Decimals: decimals}
+}
+
+// price returns the price of an asset, preferring the feed over fixed prices. If the feed
+// cannot be reached, the last prices fetched from it are used instead.
+func (co *Converter) price(ctx context.Context, symbol string) (*big.Rat, error) {
+	if co.feed != "" {
+		prices := co.prices(ctx)
+		if price, ok := prices[symbol]; ok {
+			return price, nil
+		}
+	}
+
+	price, ok := co.fixed[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no price of %s in %s", symbol, co.currency)
+	}
+	return price, nil
+}
+
+// prices returns the cached prices of the feed, refreshing them once expired
+func (co *Converter) prices(ctx context.Context) map[string]*big.Rat {
+	co.mutex.Lock()
+	defer co.mutex.Unlock()
+
+	if !co.fetchedAt.IsZero() && time.Since(co.fetchedAt) < co.ttl {
+		return co.cached
+	}
+
+	// failed fetches are also only retried once the cache expires
+	co.fetchedAt = time.Now()
+
+	prices, err := co.fetch(ctx)
+	if err != nil {
+		co.log.WithError(err).WithField("feed", co.feed).Warn("Failed to fetch prices, using previous prices")
+		return co.cached
+	}
+
+	co.cached = prices
+	return prices
+}
+
+func (co *Converter) fetch(ctx context.Context) (map[string]*big.Rat, error) {
+	req, err := http.NewRequest(http.MethodGet, co.feed, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := co.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price feed responded with %s", resp.Status)
+	}
+
+	var values map[string]json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&values)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]*big.Rat)
+	for symbol, value := range values {
+		price, err := parsePrice(strings.Trim(string(value), `"`))
+		if err != nil {
+			return nil, fmt.Errorf("invalid price of %s: %w", symbol, err)
+		}
+		prices[strings.ToUpper(symbol)] = price
+	}
+	return prices, nil
+}
+
+// parsePrice parses a non-negative decimal price
+func parsePrice(value string) (*big.Rat, error) {
+	price, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a decimal number", value)
+	}
+	if price.Sign() < 0 {
+		return nil, fmt.Errorf("%q is negative", value)
+	}
+	return price, nil
+}
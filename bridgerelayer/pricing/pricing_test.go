@@ -0,0 +1,119 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package pricing_test
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/pricing"
+)
+
+func TestConverter_Convert(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"eth": 2000.5, "DOT": "5"}`))
+	}))
+	defer server.Close()
+
+	// keys are lower case, as read from the configuration
+	converter, err := pricing.NewConverter(&pricing.Config{
+		Currency: "USD",
+		Feed:     server.URL,
+		CacheTTL: 3600,
+		Prices:   map[string]string{"ksm": "20", "dot": "4"},
+		Assets: map[string]pricing.Asset{
+			"ethereum":  {Symbol: "ETH", Decimals: 18},
+			"substrate": {Symbol: "DOT", Decimals: 10},
+		},
+	}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	require.NotNil(t, converter)
+
+	ctx := context.Background()
+
+	// 0.002 ETH
+	value, err := converter.Convert(ctx, "Ethereum", big.NewInt(2000000000000000))
+	require.NoError(t, err)
+	assert.Equal(t, "4.001000", value.FloatString(6))
+
+	// the feed takes precedence over fixed prices
+	value, err = converter.Convert(ctx, "Substrate", big.NewInt(15000000000))
+	require.NoError(t, err)
+	assert.Equal(t, "7.500000", value.FloatString(6))
+
+	// prices are cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	_, err = converter.Convert(ctx, "Kusama", big.NewInt(1))
+	assert.Error(t, err)
+}
+
+func TestConverter_FixedPrices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// fixed prices are used while the feed is unavailable
+	converter, err := pricing.NewConverter(&pricing.Config{
+		Currency: "EUR",
+		Feed:     server.URL,
+		Prices:   map[string]string{"dot": "4.25"},
+		Assets:   map[string]pricing.Asset{"substrate": {Symbol: "DOT", Decimals: 10}},
+	}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	value, err := converter.Convert(context.Background(), "Substrate", big.NewInt(20000000000))
+	require.NoError(t, err)
+	assert.Equal(t, "8.50", value.FloatString(2))
+	assert.Equal(t, "EUR", converter.Currency())
+}
+
+func TestNewConverter(t *testing.T) {
+	converter, err := pricing.NewConverter(&pricing.Config{}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	assert.Nil(t, converter)
+
+	_, err = pricing.NewConverter(&pricing.Config{Currency: "USD", Prices: map[string]string{"dot": "-1"}}, logrus.NewEntry(logrus.New()))
+	assert.Error(t, err)
+
+	_, err = pricing.NewConverter(&pricing.Config{
+		Currency: "USD",
+		Assets:   map[string]pricing.Asset{"substrate": {Symbol: "DOT", Decimals: 100}},
+	}, logrus.NewEntry(logrus.New()))
+	assert.Error(t, err)
+}
+
+func TestConverter_Discover(t *testing.T) {
+	converter, err := pricing.NewConverter(&pricing.Config{
+		Currency: "USD",
+		Prices:   map[string]string{"ksm": "40", "dot": "4"},
+		Assets:   map[string]pricing.Asset{"ethereum": {Symbol: "ETH", Decimals: 18}},
+	}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+
+	_, err = converter.Convert(context.Background(), "Substrate", big.NewInt(1000000000000))
+	assert.Error(t, err)
+
+	// chains are priced in the native token they report
+	converter.Discover("Substrate", "KSM", 12)
+	value, err := converter.Convert(context.Background(), "Substrate", big.NewInt(1000000000000))
+	require.NoError(t, err)
+	assert.Equal(t, "40.00", value.FloatString(2))
+
+	// configured assets are kept
+	converter.Discover("Ethereum", "DOT", 10)
+	_, err = converter.Convert(context.Background(), "Ethereum", big.NewInt(1))
+	assert.EqualError(t, err, "no price of ETH in USD")
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package secrets reads the secrets of the relayer, such as its signing keys and the
+// credentials of its RPC endpoints, from the environment or from a remote secret backend,
+// so that they are never written to the configuration file.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	// Backend from which the secrets are read, env or vault. Defaults to env.
+	Provider string `mapstructure:"provider"`
+	// Seconds between reads of the secrets, after which the relay stops to be restarted if
+	// a secret which it uses was rotated. Zero disables the checks.
+	RotationInterval uint64      `mapstructure:"rotation-interval"`
+	Vault            VaultConfig `mapstructure:"vault"`
+}
+
+// Provider reads the secrets of the relayer from a backend
+type Provider interface {
+	// Secrets returns the secrets which are set, by name
+	Secrets(ctx context.Context) (map[string]string, error)
+}
+
+func NewProvider(config *Config) (Provider, error) {
+	switch config.Provider {
+	case "", "env":
+		return Env{}, nil
+	case "vault":
+		return NewVault(&config.Vault)
+	default:
+		return nil, fmt.Errorf("unknown secret provider %q, expected env or vault", config.Provider)
+	}
+}
+
+// Env reads the secrets from the environment variables of the process
+type Env struct{}
+
+func (Env) Secrets(_ context.Context) (map[string]string, error) {
+	return environment(), nil
+}
+
+func environment() map[string]string {
+	values := make(map[string]string)
+	for _, entry := range os.Environ() {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		}
+	}
+	return values
+}
+
+// Set is the secrets read from a provider at once, which records the secrets looked up in
+// it, so that their rotation can be detected
+type Set struct {
+	values map[string]string
+	// whether the secrets were read from the environment
+	env   bool
+	mutex sync.Mutex
+	used  map[string]bool
+}
+
+// Read reads the secrets from a provider
+func Read(ctx context.Context, provider Provider) (*Set, error) {
+	values, err := provider.Secrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, env := provider.(Env)
+	return &Set{values: values, env: env, used: make(map[string]bool)}, nil
+}
+
+// Lookup returns a secret and whether it is set, from the environment for a nil set
+func (s *Set) Lookup(name string) (string, bool) {
+	if s == nil {
+		return os.LookupEnv(name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.used[name] = true
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Missing returns the error reported for a required secret which is not set
+func (s *Set) Missing(name string) error {
+	if s == nil || s.env {
+		return fmt.Errorf("environment variable not set: %s", name)
+	}
+	return fmt.Errorf("secret not set: %s", name)
+}
+
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Expand replaces the references to secrets in a setting, written as ${NAME}, by their
+// values, such as the credentials in the URL of an RPC endpoint
+func (s *Set) Expand(setting string) (string, error) {
+	var missing []string
+	expanded := reference.ReplaceAllStringFunc(setting, func(ref string) string {
+		name := reference.FindStringSubmatch(ref)[1]
+		value, ok := s.Lookup(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", s.Missing(missing[0])
+	}
+	return expanded, nil
+}
+
+// Rotated returns the names of the secrets looked up in the set which changed in a later
+// read, including those which were set or unset since
+func (s *Set) Rotated(later *Set) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var rotated []string
+	for name := range s.used {
+		value, ok := s.values[name]
+		laterValue, laterOk := later.values[name]
+		if ok != laterOk || value != laterValue {
+			rotated = append(rotated, name)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated
+}
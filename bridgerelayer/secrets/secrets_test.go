@@ -0,0 +1,127 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package secrets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/secrets"
+)
+
+type staticProvider map[string]string
+
+func (sp staticProvider) Secrets(_ context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	for name, value := range sp {
+		values[name] = value
+	}
+	return values, nil
+}
+
+func TestSet_Expand(t *testing.T) {
+	set, err := secrets.Read(context.Background(), staticProvider{"INFURA_ID": "abc"})
+	require.NoError(t, err)
+
+	expanded, err := set.Expand("wss://mainnet.infura.io/ws/v3/${INFURA_ID}")
+	require.NoError(t, err)
+	assert.Equal(t, "wss://mainnet.infura.io/ws/v3/abc", expanded)
+
+	// other dollar signs are left as they are
+	expanded, err = set.Expand("ws://localhost:8545/$INFURA_ID")
+	require.NoError(t, err)
+	assert.Equal(t, "ws://localhost:8545/$INFURA_ID", expanded)
+
+	_, err = set.Expand("wss://${ALCHEMY_KEY}@eth.example")
+	assert.EqualError(t, err, "secret not set: ALCHEMY_KEY")
+}
+
+func TestSet_Rotated(t *testing.T) {
+	set, err := secrets.Read(context.Background(), staticProvider{"KEY": "a", "UNUSED": "b"})
+	require.NoError(t, err)
+	_, ok := set.Lookup("KEY")
+	assert.True(t, ok)
+	_, ok = set.Lookup("OPTIONAL")
+	assert.False(t, ok)
+
+	later, err := secrets.Read(context.Background(), staticProvider{"KEY": "a", "UNUSED": "c"})
+	require.NoError(t, err)
+	assert.Empty(t, set.Rotated(later))
+
+	later, err = secrets.Read(context.Background(), staticProvider{"KEY": "d", "OPTIONAL": "e"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"KEY", "OPTIONAL"}, set.Rotated(later))
+}
+
+func TestEnv(t *testing.T) {
+	os.Setenv("ARTEMIS_TEST_SECRET", "value")
+	defer os.Unsetenv("ARTEMIS_TEST_SECRET")
+
+	provider, err := secrets.NewProvider(&secrets.Config{})
+	require.NoError(t, err)
+	set, err := secrets.Read(context.Background(), provider)
+	require.NoError(t, err)
+
+	value, ok := set.Lookup("ARTEMIS_TEST_SECRET")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	assert.EqualError(t, set.Missing("ARTEMIS_TEST_MISSING"), "environment variable not set: ARTEMIS_TEST_MISSING")
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/data/artemis-relay/mainnet", r.URL.Path)
+		assert.Equal(t, "ops", r.Header.Get("X-Vault-Namespace"))
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"ARTEMIS_ETHEREUM_KEY": "secret"}, "metadata": {"version": 2}}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("token\n"), 0600))
+
+	os.Setenv("ARTEMIS_SUBSTRATE_KEY", "//Relay")
+	defer os.Unsetenv("ARTEMIS_SUBSTRATE_KEY")
+
+	provider, err := secrets.NewProvider(&secrets.Config{
+		Provider: "vault",
+		Vault: secrets.VaultConfig{
+			Address:   server.URL,
+			Mount:     "kv",
+			Path:      "artemis-relay/mainnet",
+			TokenFile: tokenFile,
+			Namespace: "ops",
+		},
+	})
+	require.NoError(t, err)
+
+	set, err := secrets.Read(context.Background(), provider)
+	require.NoError(t, err)
+	value, ok := set.Lookup("ARTEMIS_ETHEREUM_KEY")
+	assert.True(t, ok)
+	assert.Equal(t, "secret", value)
+
+	// secrets missing from Vault are read from the environment
+	value, ok = set.Lookup("ARTEMIS_SUBSTRATE_KEY")
+	assert.True(t, ok)
+	assert.Equal(t, "//Relay", value)
+
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("revoked"), 0600))
+	_, err = secrets.Read(context.Background(), provider)
+	assert.Error(t, err)
+}
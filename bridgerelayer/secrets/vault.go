@@ -0,0 +1,154 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// VaultConfig locates the secrets of the relayer in a KV version 2 secrets engine of
+// HashiCorp Vault, as a single secret whose keys are the names of the secrets
+type VaultConfig struct {
+	// Address of the Vault server, for example https://vault:8200. Defaults to VAULT_ADDR.
+	Address string `mapstructure:"address"`
+	// Mount path of the KV secrets engine. Defaults to secret.
+	Mount string `mapstructure:"mount"`
+	// Path of the secret within the engine, for example artemis-relay/mainnet
+	Path string `mapstructure:"path"`
+	// File holding the Vault token, read on each read of the secrets so that a token renewed
+	// by a Vault agent is picked up. VAULT_TOKEN is used if empty.
+	TokenFile string `mapstructure:"token-file"`
+	// Vault Enterprise namespace, if any
+	Namespace string `mapstructure:"namespace"`
+	// Timeout in seconds of each request to Vault
+	Timeout uint64 `mapstructure:"timeout"`
+}
+
+const (
+	defaultMount        = "secret"
+	defaultVaultTimeout = 10
+)
+
+// Vault reads the secrets from HashiCorp Vault. Secrets missing from Vault are read from
+// the environment, so that only the sensitive ones need to be moved to Vault.
+type Vault struct {
+	url       string
+	tokenFile string
+	namespace string
+	client    *http.Client
+}
+
+func NewVault(config *VaultConfig) (*Vault, error) {
+	address := config.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, errors.New("vault address is not set")
+	}
+	if config.Path == "" {
+		return nil, errors.New("vault path of the secrets is not set")
+	}
+
+	mount := config.Mount
+	if mount == "" {
+		mount = defaultMount
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultVaultTimeout
+	}
+
+	tokenFile := config.TokenFile
+	if tokenFile != "" {
+		var err error
+		tokenFile, err = homedir.Expand(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Vault{
+		url:       fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(address, "/"), strings.Trim(mount, "/"), strings.Trim(config.Path, "/")),
+		tokenFile: tokenFile,
+		namespace: config.Namespace,
+		client:    &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}, nil
+}
+
+type kvResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+}
+
+func (va *Vault) Secrets(ctx context.Context) (map[string]string, error) {
+	token, err := va.token()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, va.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("X-Vault-Token", token)
+	if va.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", va.namespace)
+	}
+
+	response, err := va.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault responded with status %d", response.StatusCode)
+	}
+
+	var kv kvResponse
+	err = json.NewDecoder(response.Body).Decode(&kv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from vault: %w", err)
+	}
+
+	values := environment()
+	for name, value := range kv.Data.Data {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("vault secret %s is not a string", name)
+		}
+		values[name] = text
+	}
+	return values, nil
+}
+
+func (va *Vault) token() (string, error) {
+	if va.tokenFile == "" {
+		token, ok := os.LookupEnv("VAULT_TOKEN")
+		if !ok {
+			return "", errors.New("environment variable not set: VAULT_TOKEN")
+		}
+		return token, nil
+	}
+
+	data, err := ioutil.ReadFile(va.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	accountingPrefix = []byte("accounting/")
+	flowPrefix       = []byte("accounting/flow/")
+	// tokens of the messages whose delivery fees are not yet accounted for
+	attributionPrefix = []byte("accounting/message/")
+)
+
+// TokenFlow is the flow of a token through the bridge over one UTC day, in base units of
+// the token. Tokens are bridged in when they are locked on Ethereum and bridged out when
+// they are unlocked, so that the net flow is the change in the supply locked there.
+type TokenFlow struct {
+	Day   string `json:"day"`
+	Token string `json:"token"`
+	// Amounts transferred from Ethereum to Substrate
+	BridgedIn   string `json:"bridgedIn"`
+	TransfersIn uint64 `json:"transfersIn"`
+	// Amounts transferred from Substrate to Ethereum
+	BridgedOut   string `json:"bridgedOut"`
+	TransfersOut uint64 `json:"transfersOut"`
+	// Bridged in less bridged out, negative if more was bridged out
+	NetFlow string `json:"netFlow"`
+	// Fees paid for the deliveries of the transfers, in base units of the native asset of
+	// each target chain
+	FeesEthereum  string `json:"feesEthereum"`
+	FeesSubstrate string `json:"feesSubstrate"`
+}
+
+// BridgeIn adds an amount transferred from Ethereum to the flow
+func (tf *TokenFlow) BridgeIn(amount *big.Int) {
+	tf.BridgedIn = addAmounts(tf.BridgedIn, amount)
+	tf.TransfersIn++
+}
+
+// BridgeOut adds an amount transferred to Ethereum to the flow
+func (tf *TokenFlow) BridgeOut(amount *big.Int) {
+	tf.BridgedOut = addAmounts(tf.BridgedOut, amount)
+	tf.TransfersOut++
+}
+
+// PayEthereumFee adds the fee of a delivery to Ethereum
+func (tf *TokenFlow) PayEthereumFee(fee *big.Int) {
+	tf.FeesEthereum = addAmounts(tf.FeesEthereum, fee)
+}
+
+// PaySubstrateFee adds the fee of a delivery to Substrate
+func (tf *TokenFlow) PaySubstrateFee(fee *big.Int) {
+	tf.FeesSubstrate = addAmounts(tf.FeesSubstrate, fee)
+}
+
+// net updates the net flow, setting all amounts so that exports have no empty columns
+func (tf *TokenFlow) net() {
+	tf.BridgedIn = addAmounts(tf.BridgedIn, nil)
+	tf.BridgedOut = addAmounts(tf.BridgedOut, nil)
+	tf.FeesEthereum = addAmounts(tf.FeesEthereum, nil)
+	tf.FeesSubstrate = addAmounts(tf.FeesSubstrate, nil)
+
+	in, _ := new(big.Int).SetString(tf.BridgedIn, 10)
+	out, _ := new(big.Int).SetString(tf.BridgedOut, 10)
+	tf.NetFlow = in.Sub(in, out).String()
+}
+
+// Accounting stores the daily flow of each token, from which back offices reconcile their
+// balances with the bridge
+type Accounting struct {
+	db DB
+	// serializes read-modify-write updates of days
+	mutex sync.Mutex
+}
+
+func NewAccounting(db DB) *Accounting {
+	return &Accounting{db: db}
+}
+
+// Update applies fn to the flow of a token for the day of a time
+func (ac *Accounting) Update(token string, at time.Time, fn func(*TokenFlow)) error {
+	day := at.UTC().Format(DayLayout)
+
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	flow := &TokenFlow{Day: day, Token: token}
+	value, err := ac.db.Get(flowKey(day, token))
+	if err == nil {
+		err = json.Unmarshal(value, flow)
+	}
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	fn(flow)
+	flow.net()
+
+	value, err = json.Marshal(flow)
+	if err != nil {
+		return err
+	}
+	return ac.db.Put(flowKey(day, token), value)
+}
+
+// Attribute records the token transferred by a message, to which the fee of its delivery is
+// attributed once it is confirmed
+func (ac *Accounting) Attribute(messageID string, token string) error {
+	return ac.db.Put(attributionKey(messageID), []byte(token))
+}
+
+// Attributed returns the token transferred by a message and forgets it, as its delivery is
+// confirmed once
+func (ac *Accounting) Attributed(messageID string) (string, error) {
+	value, err := ac.db.Get(attributionKey(messageID))
+	if err != nil {
+		return "", err
+	}
+	return string(value), ac.db.Delete(attributionKey(messageID))
+}
+
+// Reset deletes the flows of every token and the pending attributions
+func (ac *Accounting) Reset() error {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	var keys [][]byte
+	err := ac.db.Iterate(accountingPrefix, func(key []byte, _ []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = ac.db.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the flows of a token, or of all tokens if empty, for the days since a time,
+// in order of day and token
+func (ac *Accounting) List(token string, since time.Time) ([]*TokenFlow, error) {
+	first := since.UTC().Format(DayLayout)
+	flows := []*TokenFlow{}
+
+	var decodeErr error
+	err := ac.db.Iterate(flowPrefix, func(key []byte, value []byte) bool {
+		if string(key[len(flowPrefix):len(flowPrefix)+len(DayLayout)]) < first {
+			return true
+		}
+
+		var flow TokenFlow
+		decodeErr = json.Unmarshal(value, &flow)
+		if decodeErr != nil {
+			return false
+		}
+
+		if token == "" || flow.Token == token {
+			flows = append(flows, &flow)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return flows, decodeErr
+}
+
+func flowKey(day string, token string) []byte {
+	return []byte(string(flowPrefix) + day + "/" + token)
+}
+
+func attributionKey(messageID string) []byte {
+	return append(append([]byte{}, attributionPrefix...), messageID...)
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestAccounting(t *testing.T) {
+	accounting := store.NewAccounting(store.NewMemoryDB())
+	day := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	update := func(token string, at time.Time, fn func(*store.TokenFlow)) {
+		require.NoError(t, accounting.Update(token, at, fn))
+	}
+	update("eth", day, func(flow *store.TokenFlow) { flow.BridgeIn(big.NewInt(100)) })
+	update("eth", day.Add(time.Hour), func(flow *store.TokenFlow) { flow.BridgeOut(big.NewInt(150)) })
+	update("eth", day.Add(time.Hour), func(flow *store.TokenFlow) { flow.PaySubstrateFee(big.NewInt(3)) })
+	update("0x02", day, func(flow *store.TokenFlow) { flow.BridgeIn(big.NewInt(5)) })
+	update("eth", day.AddDate(0, 0, 1), func(flow *store.TokenFlow) { flow.PayEthereumFee(big.NewInt(7)) })
+
+	flows, err := accounting.List("", day)
+	require.NoError(t, err)
+	require.Len(t, flows, 3)
+
+	assert.Equal(t, &store.TokenFlow{
+		Day: "2020-09-01", Token: "0x02",
+		BridgedIn: "5", TransfersIn: 1, BridgedOut: "0", NetFlow: "5",
+		FeesEthereum: "0", FeesSubstrate: "0",
+	}, flows[0])
+	assert.Equal(t, &store.TokenFlow{
+		Day: "2020-09-01", Token: "eth",
+		BridgedIn: "100", TransfersIn: 1, BridgedOut: "150", TransfersOut: 1, NetFlow: "-50",
+		FeesEthereum: "0", FeesSubstrate: "3",
+	}, flows[1])
+	assert.Equal(t, "2020-09-02", flows[2].Day)
+	assert.Equal(t, "7", flows[2].FeesEthereum)
+	assert.Equal(t, "0", flows[2].NetFlow)
+
+	flows, err = accounting.List("eth", day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, flows, 1)
+	assert.Equal(t, "2020-09-02", flows[0].Day)
+
+	// the token of a message is attributed once
+	require.NoError(t, accounting.Attribute("01", "eth"))
+	token, err := accounting.Attributed("01")
+	require.NoError(t, err)
+	assert.Equal(t, "eth", token)
+	_, err = accounting.Attributed("01")
+	assert.Equal(t, store.ErrNotFound, err)
+
+	require.NoError(t, accounting.Attribute("02", "eth"))
+	require.NoError(t, accounting.Reset())
+	flows, err = accounting.List("", day)
+	require.NoError(t, err)
+	assert.Empty(t, flows)
+	_, err = accounting.Attributed("02")
+	assert.Equal(t, store.ErrNotFound, err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+var blocksPrefix = []byte("blocks/")
+
+// Interval is an inclusive range of block numbers
+type Interval struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// Len returns the number of blocks in the interval
+func (iv Interval) Len() uint64 {
+	return iv.End - iv.Start + 1
+}
+
+// Blocks records which blocks of each chain have been fully processed, as a set of
+// disjoint intervals. Gaps between the intervals are blocks which were skipped, for
+// example because the relayer crashed or gave up on a block.
+type Blocks struct {
+	db DB
+	// serializes read-modify-write updates of interval sets
+	mutex sync.Mutex
+}
+
+func NewBlocks(db DB) *Blocks {
+	return &Blocks{db: db}
+}
+
+// MarkProcessed records that all events of a block have been handled
+func (bl *Blocks) MarkProcessed(source string, number uint64) error {
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+
+	intervals, err := bl.Processed(source)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(addInterval(intervals, Interval{Start: number, End: number}))
+	if err != nil {
+		return err
+	}
+
+	return bl.db.Put(blocksKey(source), value)
+}
+
+// Processed returns the intervals of processed blocks in ascending order
+func (bl *Blocks) Processed(source string) ([]Interval, error) {
+	value, err := bl.db.Get(blocksKey(source))
+	if err == ErrNotFound {
+		return []Interval{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var intervals []Interval
+	err = json.Unmarshal(value, &intervals)
+	if err != nil {
+		return nil, err
+	}
+
+	return intervals, nil
+}
+
+// Holes returns the blocks which were skipped between the first and the last processed block
+func (bl *Blocks) Holes(source string) ([]Interval, error) {
+	intervals, err := bl.Processed(source)
+	if err != nil {
+		return nil, err
+	}
+	return holes(intervals), nil
+}
+
+// addInterval inserts an interval into a sorted set of disjoint intervals,
+// merging it with any intervals it overlaps or adjoins
+func addInterval(intervals []Interval, iv Interval) []Interval {
+	result := make([]Interval, 0, len(intervals)+1)
+
+	i := 0
+	for ; i < len(intervals) && intervals[i].End+1 < iv.Start; i++ {
+		result = append(result, intervals[i])
+	}
+
+	for ; i < len(intervals) && intervals[i].Start <= iv.End+1; i++ {
+		if intervals[i].Start < iv.Start {
+			iv.Start = intervals[i].Start
+		}
+		if intervals[i].End > iv.End {
+			iv.End = intervals[i].End
+		}
+	}
+	result = append(result, iv)
+
+	return append(result, intervals[i:]...)
+}
+
+func holes(intervals []Interval) []Interval {
+	result := []Interval{}
+	for i := 1; i < len(intervals); i++ {
+		result = append(result, Interval{
+			Start: intervals[i-1].End + 1,
+			End:   intervals[i].Start - 1,
+		})
+	}
+	return result
+}
+
+func blocksKey(source string) []byte {
+	return append(append([]byte{}, blocksPrefix...), source...)
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestBlocks_Holes(t *testing.T) {
+	blocks := store.NewBlocks(store.NewMemoryDB())
+
+	holes, err := blocks.Holes("substrate")
+	require.NoError(t, err)
+	assert.Empty(t, holes)
+
+	for _, number := range []uint64{10, 11, 12, 20, 21, 15, 13, 11} {
+		require.NoError(t, blocks.MarkProcessed("substrate", number))
+	}
+
+	processed, err := blocks.Processed("substrate")
+	require.NoError(t, err)
+	assert.Equal(t, []store.Interval{{10, 13}, {15, 15}, {20, 21}}, processed)
+
+	holes, err = blocks.Holes("substrate")
+	require.NoError(t, err)
+	assert.Equal(t, []store.Interval{{14, 14}, {16, 19}}, holes)
+
+	// filling a hole merges the adjoining intervals
+	require.NoError(t, blocks.MarkProcessed("substrate", 14))
+	processed, err = blocks.Processed("substrate")
+	require.NoError(t, err)
+	assert.Equal(t, []store.Interval{{10, 15}, {20, 21}}, processed)
+
+	// chains are tracked separately
+	processed, err = blocks.Processed("ethereum")
+	require.NoError(t, err)
+	assert.Empty(t, processed)
+}
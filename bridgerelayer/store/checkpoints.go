@@ -0,0 +1,52 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var checkpointPrefix = []byte("checkpoint/")
+
+// Checkpoints records the most recent verified checkpoint of each chain
+type Checkpoints struct {
+	db DB
+}
+
+func NewCheckpoints(db DB) *Checkpoints {
+	return &Checkpoints{db: db}
+}
+
+// LoadCheckpoint returns the recorded checkpoint of a chain, nil if there is none
+func (cs *Checkpoints) LoadCheckpoint(source string) (*chain.CheckpointConfig, error) {
+	value, err := cs.db.Get(checkpointKey(source))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint chain.CheckpointConfig
+	err = json.Unmarshal(value, &checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint replaces the recorded checkpoint of a chain
+func (cs *Checkpoints) SaveCheckpoint(source string, checkpoint *chain.CheckpointConfig) error {
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return cs.db.Put(checkpointKey(source), value)
+}
+
+func checkpointKey(source string) []byte {
+	return append(append([]byte{}, checkpointPrefix...), source...)
+}
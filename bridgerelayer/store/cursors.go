@@ -0,0 +1,172 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var cursorPrefix = []byte("cursor/")
+
+// Cursors records the last block handled by the listener of each chain in the database
+type Cursors struct {
+	db DB
+}
+
+func NewCursors(db DB) *Cursors {
+	return &Cursors{db: db}
+}
+
+// LoadCursor returns the last handled block of a chain, false if there is none
+func (cs *Cursors) LoadCursor(source string) (uint64, bool, error) {
+	value, err := cs.db.Get(cursorKey(source))
+	if err == ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	var number uint64
+	err = json.Unmarshal(value, &number)
+	if err != nil {
+		return 0, false, err
+	}
+	return number, true, nil
+}
+
+// SaveCursor replaces the last handled block of a chain
+func (cs *Cursors) SaveCursor(source string, number uint64) error {
+	value, err := json.Marshal(number)
+	if err != nil {
+		return err
+	}
+	return cs.db.Put(cursorKey(source), value)
+}
+
+func cursorKey(source string) []byte {
+	return append(append([]byte{}, cursorPrefix...), source...)
+}
+
+// FileCursors records the last block handled by the listener of each chain in a JSON file,
+// so that the cursors survive restarts of relayers whose state is otherwise kept in memory
+type FileCursors struct {
+	path    string
+	mutex   sync.Mutex
+	cursors map[string]uint64
+}
+
+// NewFileCursors reads the cursors recorded in a file, which is created once a cursor is saved
+func NewFileCursors(path string) (*FileCursors, error) {
+	fc := &FileCursors{path: path, cursors: make(map[string]uint64)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &fc.cursors)
+	if err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// LoadCursor returns the last handled block of a chain, false if there is none
+func (fc *FileCursors) LoadCursor(source string) (uint64, bool, error) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	number, ok := fc.cursors[source]
+	return number, ok, nil
+}
+
+// SaveCursor replaces the last handled block of a chain and rewrites the file
+func (fc *FileCursors) SaveCursor(source string, number uint64) error {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	fc.cursors[source] = number
+	data, err := json.Marshal(fc.cursors)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fc.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a crash never leaves a partial file
+	tmp := fc.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, fc.path)
+}
+
+// OpenCursors returns the cursor store described by the config, which keeps the cursors in
+// the database unless a cursor file is configured
+func OpenCursors(config *Config, db DB) (chain.CursorStore, error) {
+	if config.CursorFile == "" {
+		return NewCursors(db), nil
+	}
+
+	path, err := homedir.Expand(config.CursorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFileCursors(path)
+}
+
+// CursorProjection rebuilds the cursors of the listeners from the blocks of the events which
+// they observed. It isn't applied as events are appended, as the listeners save their cursors
+// as they go, for blocks without events too. A rebuilt cursor resumes its listener from the
+// block of the last event it observed, whose later events may not have been handled, so its
+// earlier events are handled again, as after a crash of the relayer.
+type CursorProjection struct {
+	cursors chain.CursorStore
+	// block of the last observed event of each chain
+	last map[string]uint64
+}
+
+func NewCursorProjection(cursors chain.CursorStore) *CursorProjection {
+	return &CursorProjection{cursors: cursors, last: make(map[string]uint64)}
+}
+
+func (cp *CursorProjection) Name() string {
+	return "cursors"
+}
+
+// Reset forgets the observed blocks. The cursors of chains without observed events are kept.
+func (cp *CursorProjection) Reset() error {
+	cp.last = make(map[string]uint64)
+	return nil
+}
+
+func (cp *CursorProjection) Apply(event *StateEvent) error {
+	if event.Kind != EventObserved || event.Event == nil || event.Event.BlockNumber == 0 {
+		return nil
+	}
+
+	number := event.Event.BlockNumber
+	if last, ok := cp.last[event.Event.Chain]; ok && number <= last {
+		return nil
+	}
+	cp.last[event.Event.Chain] = number
+	return cp.cursors.SaveCursor(event.Event.Chain, number-1)
+}
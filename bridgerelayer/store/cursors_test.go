@@ -0,0 +1,84 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func testCursors(t *testing.T, cursors chain.CursorStore) {
+	_, ok, err := cursors.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cursors.SaveCursor("Substrate", 10))
+	require.NoError(t, cursors.SaveCursor("Substrate", 12))
+	require.NoError(t, cursors.SaveCursor("Ethereum", 0))
+
+	number, ok, err := cursors.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(12), number)
+
+	// block zero is a cursor like any other
+	number, ok, err = cursors.LoadCursor("Ethereum")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), number)
+}
+
+func TestCursors(t *testing.T) {
+	testCursors(t, store.NewCursors(store.NewMemoryDB()))
+}
+
+func TestFileCursors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artemis-relay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state", "cursors.json")
+	cursors, err := store.NewFileCursors(path)
+	require.NoError(t, err)
+	testCursors(t, cursors)
+
+	// the cursors are read back after a restart
+	reopened, err := store.OpenCursors(&store.Config{CursorFile: path}, store.NewMemoryDB())
+	require.NoError(t, err)
+	number, ok, err := reopened.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(12), number)
+}
+
+func TestSeedCursor(t *testing.T) {
+	cursors := store.NewCursors(store.NewMemoryDB())
+
+	// listeners without a cursor start from the start block
+	require.NoError(t, chain.SeedCursor(cursors, "Substrate", 100))
+	number, ok, err := cursors.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(99), number)
+
+	// while recorded cursors are kept
+	require.NoError(t, cursors.SaveCursor("Substrate", 150))
+	require.NoError(t, chain.SeedCursor(cursors, "Substrate", 100))
+	number, _, err = cursors.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(150), number)
+
+	require.NoError(t, chain.SeedCursor(cursors, "Ethereum", 0))
+	_, ok, err = cursors.LoadCursor("Ethereum")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var deadLetterPrefix = []byte("deadletter/")
+
+// DeadLetter is a message whose delivery failed permanently
+type DeadLetter struct {
+	ID string `json:"id"`
+	// Chain to which the message was delivered
+	Target string `json:"target"`
+	AppID  string `json:"appId"`
+	// Canonical encoding of the payload, in hex
+	Payload string `json:"payload"`
+	// Block of the source chain whose event generated the message, zero if unknown
+	SourceBlock uint64 `json:"sourceBlock"`
+	// Error of the last attempt
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+	// Encoded message, from which it is requeued
+	Message json.RawMessage `json:"message"`
+}
+
+// DeadLetters stores the messages whose delivery failed permanently, by the ID which the
+// message store assigned to them, until they are requeued
+type DeadLetters struct {
+	db DB
+	// serializes updates of entries
+	mutex sync.Mutex
+}
+
+func NewDeadLetters(db DB) *DeadLetters {
+	return &DeadLetters{db: db}
+}
+
+// Add stores a message whose delivery to a chain failed with an error after some attempts,
+// replacing any earlier entry of the message
+func (dl *DeadLetters) Add(target string, msg *chain.Message, reason string, attempts int) (*DeadLetter, error) {
+	if msg.ID == "" {
+		return nil, fmt.Errorf("message was not recorded")
+	}
+
+	encoded, err := chain.EncodeMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := chain.CanonicalPayload(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	letter := &DeadLetter{
+		ID:          msg.ID,
+		Target:      target,
+		AppID:       hex.EncodeToString(msg.AppID[:]),
+		Payload:     hex.EncodeToString(payload),
+		SourceBlock: msg.SourceBlock,
+		Error:       reason,
+		Attempts:    attempts,
+		FailedAt:    time.Now().UTC(),
+		Message:     encoded,
+	}
+
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return nil, err
+	}
+
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	return letter, dl.db.Put(deadLetterKey(msg.ID), value)
+}
+
+func (dl *DeadLetters) Get(id string) (*DeadLetter, error) {
+	value, err := dl.db.Get(deadLetterKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var letter DeadLetter
+	err = json.Unmarshal(value, &letter)
+	if err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+// List returns the stored messages in the order in which their delivery failed
+func (dl *DeadLetters) List() ([]*DeadLetter, error) {
+	letters := []*DeadLetter{}
+
+	var decodeErr error
+	err := dl.db.Iterate(deadLetterPrefix, func(_ []byte, value []byte) bool {
+		var letter DeadLetter
+		decodeErr = json.Unmarshal(value, &letter)
+		if decodeErr != nil {
+			return false
+		}
+		letters = append(letters, &letter)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters, decodeErr
+}
+
+// Remove deletes a message, whether or not it is still stored
+func (dl *DeadLetters) Remove(id string) error {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	return dl.db.Delete(deadLetterKey(id))
+}
+
+// Decode decodes the message of an entry
+func (de *DeadLetter) Decode() (*chain.Message, error) {
+	return chain.DecodeMessage(de.Message)
+}
+
+func deadLetterKey(id string) []byte {
+	return append(append([]byte{}, deadLetterPrefix...), id...)
+}
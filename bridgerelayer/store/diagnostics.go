@@ -0,0 +1,103 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var diagnosticsPrefix = []byte("diagnostics/")
+
+// maxDiagnostics is the number of diagnostics kept, beyond which the oldest are removed
+const maxDiagnostics = 100
+
+// Diagnostics stores the state of the writers captured while their queues backed up, keeping
+// the latest captures. Their IDs sort in the order in which they were captured.
+type Diagnostics struct {
+	db DB
+	// serializes additions, which remove the oldest entries
+	mutex sync.Mutex
+}
+
+func NewDiagnostics(db DB) *Diagnostics {
+	return &Diagnostics{db: db}
+}
+
+// RecordDiagnostics stores the diagnostics of a writer, assigning their ID
+func (ds *Diagnostics) RecordDiagnostics(diagnostics *chain.WriterDiagnostics) error {
+	diagnostics.ID = fmt.Sprintf("%019d-%s", diagnostics.CapturedAt.UnixNano(), strings.ToLower(diagnostics.Chain))
+
+	value, err := json.Marshal(diagnostics)
+	if err != nil {
+		return err
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	err = ds.db.Put(diagnosticsKey(diagnostics.ID), value)
+	if err != nil {
+		return err
+	}
+
+	var keys [][]byte
+	err = ds.db.Iterate(diagnosticsPrefix, func(key []byte, _ []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for len(keys) > maxDiagnostics {
+		err = ds.db.Delete(keys[0])
+		if err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
+
+func (ds *Diagnostics) Get(id string) (*chain.WriterDiagnostics, error) {
+	value, err := ds.db.Get(diagnosticsKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics chain.WriterDiagnostics
+	err = json.Unmarshal(value, &diagnostics)
+	if err != nil {
+		return nil, err
+	}
+	return &diagnostics, nil
+}
+
+// List returns the stored diagnostics in the order in which they were captured
+func (ds *Diagnostics) List() ([]*chain.WriterDiagnostics, error) {
+	list := []*chain.WriterDiagnostics{}
+
+	var decodeErr error
+	err := ds.db.Iterate(diagnosticsPrefix, func(_ []byte, value []byte) bool {
+		var diagnostics chain.WriterDiagnostics
+		decodeErr = json.Unmarshal(value, &diagnostics)
+		if decodeErr != nil {
+			return false
+		}
+		list = append(list, &diagnostics)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, decodeErr
+}
+
+func diagnosticsKey(id string) []byte {
+	return append(append([]byte{}, diagnosticsPrefix...), id...)
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestDiagnostics(t *testing.T) {
+	diagnostics := store.NewDiagnostics(store.NewMemoryDB())
+
+	capturedAt := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 105; i++ {
+		require.NoError(t, diagnostics.RecordDiagnostics(&chain.WriterDiagnostics{
+			Chain:      "Ethereum",
+			CapturedAt: capturedAt.Add(time.Duration(i) * time.Minute),
+			Queued:     i,
+		}))
+	}
+
+	// the latest captures are kept, in the order in which they were captured
+	list, err := diagnostics.List()
+	require.NoError(t, err)
+	require.Len(t, list, 100)
+	assert.Equal(t, 5, list[0].Queued)
+	assert.Equal(t, 104, list[99].Queued)
+
+	recorded, err := diagnostics.Get(list[99].ID)
+	require.NoError(t, err)
+	assert.Equal(t, capturedAt.Add(104*time.Minute), recorded.CapturedAt)
+
+	_, err = diagnostics.Get(list[0].ID + "0")
+	assert.Equal(t, store.ErrNotFound, err)
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+var eventPrefix = []byte("events/")
+
+// routedEvent is the record of an event whose message was routed
+type routedEvent struct {
+	RoutedAt time.Time `json:"routedAt"`
+}
+
+// Events records the IDs of the events whose messages were routed, so that no event is
+// routed twice, even after a restart or by a listener which observes it again
+type Events struct {
+	db DB
+	// serializes the check and record of the same event
+	mutex sync.Mutex
+}
+
+func NewEvents(db DB) *Events {
+	return &Events{db: db}
+}
+
+// Route records that the message of an event was routed, returning false if it already was
+func (es *Events) Route(eventID string, at time.Time) (bool, error) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	_, err := es.db.Get(eventKey(eventID))
+	if err == nil {
+		return false, nil
+	} else if err != ErrNotFound {
+		return false, err
+	}
+
+	value, err := json.Marshal(routedEvent{RoutedAt: at.UTC()})
+	if err != nil {
+		return false, err
+	}
+	return true, es.db.Put(eventKey(eventID), value)
+}
+
+// Prune forgets the events routed before a time, returning how many were forgotten
+func (es *Events) Prune(before time.Time) (int, error) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	var expired [][]byte
+	var decodeErr error
+	err := es.db.Iterate(eventPrefix, func(key []byte, value []byte) bool {
+		var event routedEvent
+		decodeErr = json.Unmarshal(value, &event)
+		if decodeErr != nil {
+			return false
+		}
+		if event.RoutedAt.Before(before) {
+			expired = append(expired, append([]byte{}, key...))
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+
+	for i, key := range expired {
+		err = es.db.Delete(key)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+func eventKey(eventID string) []byte {
+	return append(append([]byte{}, eventPrefix...), strings.ToLower(eventID)...)
+}
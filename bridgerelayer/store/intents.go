@@ -0,0 +1,102 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+var (
+	intentPrefix  = []byte("intents/")
+	checkedPrefix = []byte("intents-checked/")
+)
+
+// Intents is the write-ahead log of the transactions which the writers are about to send
+// from the relayer accounts, by chain, account and nonce. A nonce used on chain without an
+// intent was used by a transaction which the relayer did not originate.
+type Intents struct {
+	db DB
+}
+
+func NewIntents(db DB) *Intents {
+	return &Intents{db: db}
+}
+
+// RecordIntent records that a transaction of an account is about to be sent with a nonce
+func (is *Intents) RecordIntent(name string, account string, nonce uint64) error {
+	value, err := time.Now().UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return is.db.Put(intentKey(name, account, nonce), value)
+}
+
+// Intended returns whether a transaction of an account was recorded with a nonce
+func (is *Intents) Intended(name string, account string, nonce uint64) (bool, error) {
+	_, err := is.db.Get(intentKey(name, account, nonce))
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Checked returns the nonce of an account up to which its transactions were checked
+// against the intents, false if they never were
+func (is *Intents) Checked(name string, account string) (uint64, bool, error) {
+	value, err := is.db.Get(checkedKey(name, account))
+	if err == ErrNotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	if len(value) != 8 {
+		return 0, false, fmt.Errorf("invalid checked nonce of %s on %s", account, name)
+	}
+	return binary.BigEndian.Uint64(value), true, nil
+}
+
+// Advance records that the transactions of an account were checked up to a nonce,
+// forgetting the intents of the nonces below it
+func (is *Intents) Advance(name string, account string, nonce uint64) error {
+	prefix := intentAccountPrefix(name, account)
+	var checked [][]byte
+	err := is.db.Iterate(prefix, func(key []byte, value []byte) bool {
+		var intended uint64
+		_, err := fmt.Sscanf(string(key[len(prefix):]), "%d", &intended)
+		if err == nil && intended < nonce {
+			checked = append(checked, append([]byte{}, key...))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range checked {
+		err = is.db.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, nonce)
+	return is.db.Put(checkedKey(name, account), value)
+}
+
+func intentAccountPrefix(name string, account string) []byte {
+	return []byte(fmt.Sprintf("%s%s/%s/", intentPrefix, name, account))
+}
+
+func intentKey(name string, account string, nonce uint64) []byte {
+	return append(intentAccountPrefix(name, account), fmt.Sprintf("%020d", nonce)...)
+}
+
+func checkedKey(name string, account string) []byte {
+	return []byte(fmt.Sprintf("%s%s/%s", checkedPrefix, name, account))
+}
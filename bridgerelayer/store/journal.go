@@ -0,0 +1,235 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var (
+	journalPrefix = []byte("journal/")
+	// journalSequenceKey holds the sequence number of the latest event of the journal
+	journalSequenceKey = []byte("journal-sequence")
+)
+
+type EventKind string
+
+const (
+	// EventObserved is a bridge event observed on a source chain, or a message recorded by
+	// a relay in explorer mode
+	EventObserved EventKind = "observed"
+	// EventQueued is a message handed to the writer of its target chain
+	EventQueued EventKind = "queued"
+	// EventSubmitted is the submission of a message to its target chain
+	EventSubmitted EventKind = "submitted"
+	// EventConfirmed is the inclusion of a submitted message in a block of its target chain
+	EventConfirmed EventKind = "confirmed"
+	// EventFailed is a message which was quarantined, invalidated, skipped or dead-lettered
+	EventFailed EventKind = "failed"
+)
+
+// StateEvent is an entry of the journal
+type StateEvent struct {
+	Sequence uint64    `json:"sequence"`
+	Kind     EventKind `json:"kind"`
+	Time     time.Time `json:"time"`
+	// Record of a message whose status changed, as it was after the change, without the
+	// annotations of operators
+	Message *MessageRecord `json:"message,omitempty"`
+	// ID of the record which the message record replaces, after its payload was migrated
+	PreviousID string `json:"previousId,omitempty"`
+	// Bridge event observed on a source chain
+	Event *chain.ObservedEvent `json:"event,omitempty"`
+	// ID of the message delivered by a submission, with its receipt and the time at which
+	// its event was observed, or of the message generated for an observed event
+	MessageID  string         `json:"messageId,omitempty"`
+	Receipt    *chain.Receipt `json:"receipt,omitempty"`
+	ObservedAt *time.Time     `json:"observedAt,omitempty"`
+}
+
+// Projection is state derived from the events of the journal, which can be regenerated by
+// replaying them
+type Projection interface {
+	Name() string
+	// Reset discards the state before the events are replayed
+	Reset() error
+	Apply(event *StateEvent) error
+}
+
+// Journal is the append-only log of the events by which the relayer's state changes, from
+// the observation of each message to the outcome of its delivery. The records of messages,
+// the statistics of channels and the cursors of the listeners are projections of the
+// journal, so that they can be rebuilt if they are corrupted, while the journal keeps the
+// history of everything the relayer did.
+type Journal struct {
+	db DB
+	// serializes the assignment of sequence numbers and the projection of events in order
+	mutex       sync.Mutex
+	projections []Projection
+}
+
+func NewJournal(db DB) *Journal {
+	return &Journal{db: db}
+}
+
+// Project applies the events appended from now on to a projection
+func (jn *Journal) Project(projection Projection) {
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	jn.projections = append(jn.projections, projection)
+}
+
+// Append records an event, assigning its sequence number, and applies it to the projections.
+// The event is kept even if a projection fails to apply it, and the error is returned.
+func (jn *Journal) Append(event *StateEvent) error {
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	sequence, err := jn.Sequence()
+	if err != nil {
+		return err
+	}
+	event.Sequence = sequence + 1
+
+	value, err := json.Marshal(event.Sequence)
+	if err != nil {
+		return err
+	}
+	err = jn.db.Put(journalSequenceKey, value)
+	if err != nil {
+		return err
+	}
+
+	value, err = json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	err = jn.db.Put(journalKey(event.Sequence), value)
+	if err != nil {
+		return err
+	}
+
+	var projectErr error
+	for _, projection := range jn.projections {
+		err = projection.Apply(event)
+		if err != nil && projectErr == nil {
+			projectErr = fmt.Errorf("project event %d on %s: %w", event.Sequence, projection.Name(), err)
+		}
+	}
+	return projectErr
+}
+
+// Sequence returns the sequence number of the latest event, zero if the journal is empty
+func (jn *Journal) Sequence() (uint64, error) {
+	value, err := jn.db.Get(journalSequenceKey)
+	if err == ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var sequence uint64
+	err = json.Unmarshal(value, &sequence)
+	if err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+// Event returns the event of a sequence number. As the sequence number of an event is
+// recorded before the event, ErrNotFound may be returned for the latest sequence number
+// while it is being appended.
+func (jn *Journal) Event(sequence uint64) (*StateEvent, error) {
+	value, err := jn.db.Get(journalKey(sequence))
+	if err != nil {
+		return nil, err
+	}
+
+	var event StateEvent
+	err = json.Unmarshal(value, &event)
+	if err != nil {
+		return nil, fmt.Errorf("journal entry %d: %w", sequence, err)
+	}
+	return &event, nil
+}
+
+// Iterate calls fn with the events from a sequence number in order, until fn returns false
+func (jn *Journal) Iterate(from uint64, fn func(event *StateEvent) bool) error {
+	var decodeErr error
+	err := jn.db.Iterate(journalPrefix, func(key []byte, value []byte) bool {
+		var event StateEvent
+		decodeErr = json.Unmarshal(value, &event)
+		if decodeErr != nil {
+			decodeErr = fmt.Errorf("journal entry %s: %w", key[len(journalPrefix):], decodeErr)
+			return false
+		}
+		if event.Sequence < from {
+			return true
+		}
+		return fn(&event)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// Rebuild resets projections and replays every event of the journal into them, returning
+// the number of events replayed
+func (jn *Journal) Rebuild(projections ...Projection) (uint64, error) {
+	jn.mutex.Lock()
+	defer jn.mutex.Unlock()
+
+	for _, projection := range projections {
+		err := projection.Reset()
+		if err != nil {
+			return 0, fmt.Errorf("reset %s: %w", projection.Name(), err)
+		}
+	}
+
+	var replayed uint64
+	var applyErr error
+	err := jn.Iterate(0, func(event *StateEvent) bool {
+		for _, projection := range projections {
+			applyErr = projection.Apply(event)
+			if applyErr != nil {
+				applyErr = fmt.Errorf("project event %d on %s: %w", event.Sequence, projection.Name(), applyErr)
+				return false
+			}
+		}
+		replayed++
+		return true
+	})
+	if err != nil {
+		return replayed, err
+	}
+	return replayed, applyErr
+}
+
+// Receipt returns the event of the submission or confirmation of a message, with a copy of
+// its receipt, which the writer may go on updating
+func Receipt(kind EventKind, msg *chain.Message, receipt *chain.Receipt) *StateEvent {
+	copied := *receipt
+	event := &StateEvent{Kind: kind, MessageID: msg.ID, Receipt: &copied}
+	if !msg.ObservedAt.IsZero() {
+		observedAt := msg.ObservedAt.UTC()
+		event.ObservedAt = &observedAt
+	}
+	return event
+}
+
+// journalKey orders the events of the journal by their sequence numbers
+func journalKey(sequence uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", journalPrefix, sequence))
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestJournal_Messages(t *testing.T) {
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+	journal := messages.Journal()
+
+	first, err := messages.Record("ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	second, err := messages.Record("ethereum", &chain.Message{Payload: []byte{2}}, store.StatusRouted)
+	require.NoError(t, err)
+	require.NoError(t, messages.SetStatus(second.ID, store.StatusDeadLettered, "reverted"))
+	_, err = messages.Annotate(first.ID, store.Annotation{Label: store.LabelInvestigating})
+	require.NoError(t, err)
+
+	var kinds []store.EventKind
+	err = journal.Iterate(0, func(event *store.StateEvent) bool {
+		kinds = append(kinds, event.Kind)
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []store.EventKind{store.EventQueued, store.EventQueued, store.EventFailed}, kinds)
+
+	// the journal is seeded once only
+	seeded, err := messages.SeedJournal()
+	require.NoError(t, err)
+	assert.Equal(t, 0, seeded)
+
+	// corrupted and lost records are regenerated, keeping the annotations of those found
+	require.NoError(t, db.Put([]byte("message/"+second.ID), []byte("{")))
+	require.NoError(t, db.Delete([]byte("message/"+first.ID)))
+	_, err = messages.Annotate(first.ID, store.Annotation{Label: store.LabelResolved})
+	assert.Equal(t, store.ErrNotFound, err)
+
+	replayed, err := journal.Rebuild(messages.Projection())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), replayed)
+
+	record, err := messages.Get(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusDeadLettered, record.Status)
+	assert.Equal(t, "reverted", record.Reason)
+	assert.Equal(t, second.Sequence, record.Sequence)
+	record, err = messages.Get(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusRouted, record.Status)
+	assert.Len(t, record.Annotations, 0)
+
+	// replaced records are replayed under their new ID, with their annotations
+	_, err = messages.Annotate(first.ID, store.Annotation{Label: store.LabelInvestigating})
+	require.NoError(t, err)
+	migrated := *record
+	migrated.ID = "migrated"
+	require.NoError(t, messages.Replace(first.ID, &migrated))
+	_, err = journal.Rebuild(messages.Projection())
+	require.NoError(t, err)
+	_, err = messages.Get(first.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+	record, err = messages.Get("migrated")
+	require.NoError(t, err)
+	assert.Len(t, record.Annotations, 1)
+}
+
+func TestJournal_Seed(t *testing.T) {
+	db := store.NewMemoryDB()
+	record := store.MessageRecord{ID: "legacy", Sequence: 4, Source: "substrate", Status: store.StatusSkipped, Annotations: []store.Annotation{}}
+	value, err := json.Marshal(record)
+	require.NoError(t, err)
+	require.NoError(t, db.Put([]byte("message/legacy"), value))
+
+	// the records of a store which predates the journal survive a rebuild
+	messages := store.NewMessages(db)
+	seeded, err := messages.SeedJournal()
+	require.NoError(t, err)
+	assert.Equal(t, 1, seeded)
+
+	_, err = messages.Journal().Rebuild(messages.Projection())
+	require.NoError(t, err)
+	stored, err := messages.Get("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusSkipped, stored.Status)
+}
+
+func TestCursorProjection(t *testing.T) {
+	db := store.NewMemoryDB()
+	journal := store.NewJournal(db)
+	cursors := store.NewCursors(db)
+	require.NoError(t, cursors.SaveCursor("Ethereum", 90))
+	require.NoError(t, cursors.SaveCursor("Substrate", 12))
+
+	for _, number := range []uint64{40, 41, 41, 39} {
+		err := journal.Append(&store.StateEvent{
+			Kind:  store.EventObserved,
+			Event: &chain.ObservedEvent{Chain: "Ethereum", BlockNumber: number},
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := journal.Rebuild(store.NewCursorProjection(cursors))
+	require.NoError(t, err)
+
+	// listeners resume from the block of their last observed event
+	number, ok, err := cursors.LoadCursor("Ethereum")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(40), number)
+	number, _, err = cursors.LoadCursor("Substrate")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), number)
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDB is a DB backed by an embedded LevelDB database
+type LevelDB struct {
+	db *leveldb.DB
+}
+
+func NewLevelDB(path string) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDB{db: db}, nil
+}
+
+func (ld *LevelDB) Get(key []byte) ([]byte, error) {
+	value, err := ld.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (ld *LevelDB) Put(key []byte, value []byte) error {
+	return ld.db.Put(key, value, nil)
+}
+
+func (ld *LevelDB) Delete(key []byte) error {
+	return ld.db.Delete(key, nil)
+}
+
+func (ld *LevelDB) Iterate(prefix []byte, fn func(key []byte, value []byte) bool) error {
+	iter := ld.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func (ld *LevelDB) Close() error {
+	return ld.db.Close()
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// MemoryDB is a DB which keeps all records in memory
+type MemoryDB struct {
+	mutex   sync.RWMutex
+	records map[string][]byte
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{records: make(map[string][]byte)}
+}
+
+func (md *MemoryDB) Get(key []byte) ([]byte, error) {
+	md.mutex.RLock()
+	defer md.mutex.RUnlock()
+
+	value, ok := md.records[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (md *MemoryDB) Put(key []byte, value []byte) error {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+
+	md.records[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (md *MemoryDB) Delete(key []byte) error {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+
+	delete(md.records, string(key))
+	return nil
+}
+
+func (md *MemoryDB) Iterate(prefix []byte, fn func(key []byte, value []byte) bool) error {
+	md.mutex.RLock()
+	keys := []string{}
+	for key := range md.records {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = md.records[key]
+	}
+	md.mutex.RUnlock()
+
+	for i, key := range keys {
+		if !fn([]byte(key), values[i]) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (md *MemoryDB) Close() error {
+	return nil
+}
@@ -0,0 +1,599 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/snowfork/go-substrate-rpc-client/types"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var (
+	messagePrefix = []byte("message/")
+	// sequenceKey holds the latest sequence number assigned to a message
+	sequenceKey = []byte("sequence")
+)
+
+type MessageStatus string
+
+const (
+	// StatusRouted means the message was handed to the writer of its target chain
+	StatusRouted MessageStatus = "routed"
+	// StatusQuarantined means the message was rejected before being queued for delivery
+	StatusQuarantined MessageStatus = "quarantined"
+	// StatusObserved means the message was recorded by a relay in explorer mode, which
+	// doesn't deliver messages
+	StatusObserved MessageStatus = "observed"
+	// StatusSkipped means the delivery of the message reverted for a reason which retrying
+	// would not clear, so it was given up
+	StatusSkipped MessageStatus = "skipped"
+	// StatusDeadLettered means the delivery of the message failed permanently, so it was
+	// moved to the dead-letter queue until an operator requeues it
+	StatusDeadLettered MessageStatus = "dead-lettered"
+	// StatusInvalidated means the event of the message was emitted in a block which a reorg
+	// replaced, so the message must not be delivered
+	StatusInvalidated MessageStatus = "invalidated"
+)
+
+// Annotation labels which operators can attach to messages
+const (
+	LabelInvestigating = "investigating"
+	LabelRefunded      = "refunded"
+	LabelKnownIssue    = "known-issue"
+	LabelResolved      = "resolved"
+)
+
+var labels = map[string]bool{
+	LabelInvestigating: true,
+	LabelRefunded:      true,
+	LabelKnownIssue:    true,
+	LabelResolved:      true,
+}
+
+// MessageRecord is the stored state of a relayed message
+type MessageRecord struct {
+	ID string `json:"id"`
+	// Bridge-wide position of the message in the order in which messages were recorded,
+	// across both directions. Zero for records stored before sequence numbers were assigned.
+	Sequence    uint64        `json:"sequence"`
+	Source      string        `json:"source"`
+	AppID       string        `json:"appId"`
+	Payload     string        `json:"payload"`
+	Status      MessageStatus `json:"status"`
+	Reason      string        `json:"reason,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	Annotations []Annotation  `json:"annotations"`
+	// Tags attached by the relayer, such as those of watched accounts
+	Tags []string `json:"tags,omitempty"`
+	// Version of the schema by which the payload is encoded. Zero for records stored before
+	// schemas were versioned, which are encoded by the first version.
+	Schema int `json:"schema,omitempty"`
+	// ID of the record before its payload was migrated to a later schema version
+	MigratedFrom string `json:"migratedFrom,omitempty"`
+	// Event which generated the message, when it was first recorded. Nil if unknown, such
+	// as for records stored before provenance was recorded.
+	Provenance *chain.Provenance `json:"provenance,omitempty"`
+}
+
+// Annotation is a note attached to a message by an operator, for example while
+// working a support ticket for a stuck transfer
+type Annotation struct {
+	Label     string    `json:"label"`
+	Note      string    `json:"note"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MessageID returns the identifier of a message, its canonical hash, with the canonical
+// encoding of its payload
+func MessageID(source string, msg *chain.Message) (string, []byte, error) {
+	hash, payload, err := chain.MessageHash(source, msg)
+	if err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(hash[:]), payload, nil
+}
+
+// legacyMessageID returns the identifier under which a message was stored before message
+// hashes were versioned
+func legacyMessageID(source string, msg *chain.Message) (string, error) {
+	payload, err := types.EncodeToBytes(msg.Payload)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.Keccak256([]byte(source), msg.AppID[:], payload)), nil
+}
+
+// Messages stores records of relayed messages. Each change of the status of a message is
+// appended to the journal, of which the records are a projection, while the annotations of
+// operators are kept on the records alone.
+type Messages struct {
+	db         DB
+	journal    *Journal
+	projection *messageProjection
+	// serializes read-modify-write updates of records
+	mutex sync.Mutex
+}
+
+func NewMessages(db DB) *Messages {
+	ms := &Messages{db: db, journal: NewJournal(db)}
+	ms.projection = &messageProjection{messages: ms}
+	ms.journal.Project(ms.projection)
+	return ms
+}
+
+// Journal returns the journal to which the changes of the records are appended
+func (ms *Messages) Journal() *Journal {
+	return ms.journal
+}
+
+// Projection returns the projection of the journal into the records
+func (ms *Messages) Projection() Projection {
+	return ms.projection
+}
+
+// Record stores a message which was observed on the source chain, returning its record.
+// Existing records keep their annotations.
+func (ms *Messages) Record(source string, msg *chain.Message, status MessageStatus) (*MessageRecord, error) {
+	return ms.record(source, msg, status, "")
+}
+
+// Quarantine stores a message which was rejected before being queued for delivery
+func (ms *Messages) Quarantine(source string, msg *chain.Message, reason string) error {
+	_, err := ms.record(source, msg, StatusQuarantined, reason)
+	return err
+}
+
+// Invalidate stores a message whose event was orphaned by a reorg
+func (ms *Messages) Invalidate(source string, msg *chain.Message, reason string) error {
+	_, err := ms.record(source, msg, StatusInvalidated, reason)
+	return err
+}
+
+// Skip marks a routed message, by its ID, as skipped after its delivery kept reverting
+func (ms *Messages) Skip(msg *chain.Message, reason string) error {
+	return ms.SetStatus(msg.ID, StatusSkipped, reason)
+}
+
+// SetStatus updates the status of a stored message, with the reason of the update
+func (ms *Messages) SetStatus(id string, status MessageStatus, reason string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	record, err := ms.Get(id)
+	if err != nil {
+		return err
+	}
+
+	record.Status = status
+	record.Reason = reason
+	record.UpdatedAt = time.Now().UTC()
+
+	return ms.change(record, "")
+}
+
+// Tag attaches a tag to a message, unless it carries it already. Messages which weren't
+// recorded yet are recorded as observed, and keep their tags once they are routed.
+func (ms *Messages) Tag(source string, msg *chain.Message, tag string) error {
+	id, payload, err := MessageID(source, msg)
+	if err != nil {
+		return err
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	record, err := ms.Get(id)
+	if err == ErrNotFound {
+		sequence, err := ms.nextSequence()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		record = &MessageRecord{
+			ID:          id,
+			Sequence:    sequence,
+			Source:      source,
+			AppID:       hex.EncodeToString(msg.AppID[:]),
+			Payload:     hex.EncodeToString(payload),
+			Status:      StatusObserved,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Annotations: []Annotation{},
+			Tags:        []string{tag},
+			Schema:      chain.PayloadSchemaVersion,
+		}
+		return ms.change(record, "")
+	} else if err != nil {
+		return err
+	}
+
+	// the tags of recorded messages are kept on their records, like annotations
+	if record.HasTag(tag) {
+		return nil
+	}
+	record.Tags = append(record.Tags, tag)
+
+	return ms.put(record)
+}
+
+func (ms *Messages) record(source string, msg *chain.Message, status MessageStatus, reason string) (*MessageRecord, error) {
+	id, payload, err := MessageID(source, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	record, err := ms.Get(id)
+	if err == ErrNotFound {
+		sequence, err := ms.nextSequence()
+		if err != nil {
+			return nil, err
+		}
+
+		record = &MessageRecord{
+			ID:          id,
+			Sequence:    sequence,
+			Source:      source,
+			AppID:       hex.EncodeToString(msg.AppID[:]),
+			Payload:     hex.EncodeToString(payload),
+			CreatedAt:   now,
+			Annotations: []Annotation{},
+			Schema:      chain.PayloadSchemaVersion,
+			Provenance:  msg.Provenance(),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	record.Status = status
+	record.Reason = reason
+	record.UpdatedAt = now
+
+	return record, ms.change(record, "")
+}
+
+// Annotate attaches an annotation to a stored message
+func (ms *Messages) Annotate(id string, annotation Annotation) (*MessageRecord, error) {
+	if !labels[annotation.Label] {
+		return nil, fmt.Errorf("unknown annotation label: %s", annotation.Label)
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	record, err := ms.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if annotation.CreatedAt.IsZero() {
+		annotation.CreatedAt = time.Now().UTC()
+	}
+	record.Annotations = append(record.Annotations, annotation)
+
+	return record, ms.put(record)
+}
+
+// Lookup returns the record of a message, falling back to the identifier under which it
+// was stored before message hashes were versioned
+func (ms *Messages) Lookup(source string, msg *chain.Message) (*MessageRecord, error) {
+	id, _, err := MessageID(source, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := ms.Get(id)
+	if err != ErrNotFound {
+		return record, err
+	}
+
+	legacyID, err := legacyMessageID(source, msg)
+	if err != nil {
+		return nil, err
+	}
+	return ms.Get(legacyID)
+}
+
+func (ms *Messages) Get(id string) (*MessageRecord, error) {
+	value, err := ms.db.Get(messageKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var record MessageRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// List returns stored messages in the order of their sequence numbers, optionally
+// restricted to those carrying a label and a tag
+func (ms *Messages) List(label string, tag string) ([]*MessageRecord, error) {
+	records := []*MessageRecord{}
+
+	var decodeErr error
+	err := ms.db.Iterate(messagePrefix, func(_ []byte, value []byte) bool {
+		var record MessageRecord
+		decodeErr = json.Unmarshal(value, &record)
+		if decodeErr != nil {
+			return false
+		}
+
+		if (label == "" || record.HasLabel(label)) && (tag == "" || record.HasTag(tag)) {
+			records = append(records, &record)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Sequence < records[j].Sequence
+	})
+	return records, decodeErr
+}
+
+// Pending returns the routed and quarantined messages in the order of their sequence numbers,
+// which may not have been delivered yet. Observed and skipped messages are never delivered.
+func (ms *Messages) Pending() ([]*MessageRecord, error) {
+	records, err := ms.List("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []*MessageRecord{}
+	for _, record := range records {
+		if record.Status == StatusRouted || record.Status == StatusQuarantined {
+			pending = append(pending, record)
+		}
+	}
+	return pending, nil
+}
+
+// Replace stores a record in place of the record with a previous ID, for example after its
+// payload was migrated and its ID changed with it
+func (ms *Messages) Replace(previousID string, record *MessageRecord) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	return ms.change(record, previousID)
+}
+
+// Sequence returns the latest sequence number assigned to a message, zero if none was
+func (ms *Messages) Sequence() (uint64, error) {
+	value, err := ms.db.Get(sequenceKey)
+	if err == ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var sequence uint64
+	err = json.Unmarshal(value, &sequence)
+	if err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+// nextSequence assigns the next sequence number, which is persisted before any record
+// carries it so that numbers are never reused, even if the record is then lost. Callers
+// must hold the mutex.
+func (ms *Messages) nextSequence() (uint64, error) {
+	sequence, err := ms.Sequence()
+	if err != nil {
+		return 0, err
+	}
+	sequence++
+
+	value, err := json.Marshal(sequence)
+	if err != nil {
+		return 0, err
+	}
+	return sequence, ms.db.Put(sequenceKey, value)
+}
+
+// HasLabel returns whether any annotation of the message carries the label
+func (mr *MessageRecord) HasLabel(label string) bool {
+	for _, annotation := range mr.Annotations {
+		if annotation.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// SchemaVersion returns the version of the schema by which the payload is encoded
+func (mr *MessageRecord) SchemaVersion() int {
+	if mr.Schema == 0 {
+		return 1
+	}
+	return mr.Schema
+}
+
+// HasTag returns whether the message carries a tag
+func (mr *MessageRecord) HasTag(tag string) bool {
+	for _, t := range mr.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SeedJournal appends the records of a store which predates the journal to the journal, so
+// that they are kept when the records are rebuilt. Does nothing if the journal has events.
+// Returns the number of records appended.
+func (ms *Messages) SeedJournal() (int, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	sequence, err := ms.journal.Sequence()
+	if err != nil || sequence > 0 {
+		return 0, err
+	}
+
+	records, err := ms.List("", "")
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		event := statusEvent(record)
+		event.Time = record.UpdatedAt
+		err = ms.journal.Append(event)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
+
+// change appends the change of the status of a record to the journal, which stores the
+// record in place of the record with a previous ID, if any. Callers must hold the mutex.
+func (ms *Messages) change(record *MessageRecord, previousID string) error {
+	event := statusEvent(record)
+	if previousID != record.ID {
+		event.PreviousID = previousID
+	}
+	return ms.journal.Append(event)
+}
+
+// statusEvent returns the event of the status of a record, with a copy of the record without
+// its annotations
+func statusEvent(record *MessageRecord) *StateEvent {
+	kind := EventFailed
+	switch record.Status {
+	case StatusRouted:
+		kind = EventQueued
+	case StatusObserved:
+		kind = EventObserved
+	}
+
+	copied := *record
+	copied.Annotations = nil
+	return &StateEvent{Kind: kind, Time: record.UpdatedAt, Message: &copied}
+}
+
+// messageProjection stores the records of the messages in the journal, with the annotations
+// and tags attached to their records since
+type messageProjection struct {
+	messages *Messages
+	// records found when the projection was reset, whose annotations and tags are restored
+	// once they are replayed
+	kept map[string]*MessageRecord
+}
+
+func (mp *messageProjection) Name() string {
+	return "messages"
+}
+
+// Reset deletes the records, keeping their annotations and tags in memory. Records which
+// can't be decoded are deleted with theirs.
+func (mp *messageProjection) Reset() error {
+	mp.kept = make(map[string]*MessageRecord)
+
+	var keys [][]byte
+	err := mp.messages.db.Iterate(messagePrefix, func(key []byte, value []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		var record MessageRecord
+		if json.Unmarshal(value, &record) == nil {
+			mp.kept[record.ID] = &record
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = mp.messages.db.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mp *messageProjection) Apply(event *StateEvent) error {
+	if event.Message == nil {
+		return nil
+	}
+	record := *event.Message
+
+	// annotations and tags carry over from the stored record, or from the record it
+	// replaces, or from the record found when the projection was reset
+	existing, err := mp.find(record.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil && event.PreviousID != "" {
+		existing, err = mp.find(event.PreviousID)
+		if err != nil {
+			return err
+		}
+	}
+
+	record.Annotations = []Annotation{}
+	if existing != nil {
+		if existing.Annotations != nil {
+			record.Annotations = existing.Annotations
+		}
+		for _, tag := range existing.Tags {
+			if !record.HasTag(tag) {
+				record.Tags = append(record.Tags, tag)
+			}
+		}
+	}
+
+	err = mp.messages.put(&record)
+	if err != nil {
+		return err
+	}
+	if event.PreviousID == "" {
+		return nil
+	}
+	delete(mp.kept, event.PreviousID)
+	return mp.messages.db.Delete(messageKey(event.PreviousID))
+}
+
+// find returns the stored record of a message, or the record found when the projection was
+// reset, nil if there is neither
+func (mp *messageProjection) find(id string) (*MessageRecord, error) {
+	record, err := mp.messages.Get(id)
+	if err == ErrNotFound {
+		return mp.kept[id], nil
+	}
+	return record, err
+}
+
+func (ms *Messages) put(record *MessageRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ms.db.Put(messageKey(record.ID), value)
+}
+
+func messageKey(id string) []byte {
+	return append(append([]byte{}, messagePrefix...), id...)
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestMessages_Annotate(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+
+	first, err := messages.Record("ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	second, err := messages.Record("ethereum", &chain.Message{Payload: []byte{2}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	_, err = messages.Annotate(first.ID, store.Annotation{Label: "bogus"})
+	assert.Error(t, err)
+
+	_, err = messages.Annotate("missing", store.Annotation{Label: store.LabelInvestigating})
+	assert.Equal(t, store.ErrNotFound, err)
+
+	record, err := messages.Annotate(first.ID, store.Annotation{
+		Label:  store.LabelInvestigating,
+		Note:   "ticket #123",
+		Author: "support",
+	})
+	require.NoError(t, err)
+	assert.Len(t, record.Annotations, 1)
+	assert.False(t, record.Annotations[0].CreatedAt.IsZero())
+
+	// recording the message again keeps its annotations
+	record, err = messages.Record("ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Len(t, record.Annotations, 1)
+
+	all, err := messages.List("", "")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	labelled, err := messages.List(store.LabelInvestigating, "")
+	require.NoError(t, err)
+	require.Len(t, labelled, 1)
+	assert.Equal(t, first.ID, labelled[0].ID)
+}
+
+func TestMessages_Tag(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+	msg := &chain.Message{Payload: []byte{1}}
+
+	// messages are tagged before the router records them
+	require.NoError(t, messages.Tag("ethereum", msg, "watch:treasury"))
+	require.NoError(t, messages.Tag("ethereum", msg, "watch:treasury"))
+
+	record, err := messages.Record("ethereum", msg, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusRouted, record.Status)
+	assert.Equal(t, []string{"watch:treasury"}, record.Tags)
+
+	_, err = messages.Record("ethereum", &chain.Message{Payload: []byte{2}}, store.StatusRouted)
+	require.NoError(t, err)
+
+	tagged, err := messages.List("", "watch:treasury")
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, record.ID, tagged[0].ID)
+}
+
+func TestMessages_Quarantine(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+
+	msg := &chain.Message{Payload: []byte{1, 2, 3}}
+	err := messages.Quarantine("substrate", msg, "payload too large")
+	require.NoError(t, err)
+
+	id, _, err := store.MessageID("substrate", msg)
+	require.NoError(t, err)
+
+	record, err := messages.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusQuarantined, record.Status)
+	assert.Equal(t, "payload too large", record.Reason)
+}
+
+func TestMessages_Skip(t *testing.T) {
+	messages := store.NewMessages(store.NewMemoryDB())
+
+	msg := &chain.Message{Payload: []byte{1, 2, 3}}
+	assert.Equal(t, store.ErrNotFound, messages.Skip(msg, "reverted: invalid signature"))
+
+	record, err := messages.Record("substrate", msg, store.StatusRouted)
+	require.NoError(t, err)
+	msg.ID = record.ID
+	require.NoError(t, messages.Skip(msg, "reverted: invalid signature"))
+
+	record, err = messages.Get(record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusSkipped, record.Status)
+	assert.Equal(t, "reverted: invalid signature", record.Reason)
+}
+
+func TestMessages_Lookup(t *testing.T) {
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+
+	msg := &chain.Message{Payload: []byte{1, 2, 3}}
+	_, err := messages.Lookup("substrate", msg)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	// records stored before message hashes were versioned are found by their old ID
+	legacyID := hex.EncodeToString(crypto.Keccak256([]byte("substrate"), msg.AppID[:], []byte{0x0c, 1, 2, 3}))
+	value, err := json.Marshal(&store.MessageRecord{ID: legacyID, Status: store.StatusRouted})
+	require.NoError(t, err)
+	require.NoError(t, db.Put([]byte("message/"+legacyID), value))
+
+	record, err := messages.Lookup("substrate", msg)
+	require.NoError(t, err)
+	assert.Equal(t, legacyID, record.ID)
+
+	recorded, err := messages.Record("substrate", msg, store.StatusRouted)
+	require.NoError(t, err)
+	assert.NotEqual(t, legacyID, recorded.ID)
+
+	record, err = messages.Lookup("substrate", msg)
+	require.NoError(t, err)
+	assert.Equal(t, recorded.ID, record.ID)
+}
+
+func TestMessages_Sequence(t *testing.T) {
+	db := store.NewMemoryDB()
+	messages := store.NewMessages(db)
+
+	sequence, err := messages.Sequence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), sequence)
+
+	first, err := messages.Record("ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Sequence)
+
+	// messages are numbered across both directions, including those only tagged so far
+	require.NoError(t, messages.Tag("substrate", &chain.Message{Payload: []byte{2}}, "watch:treasury"))
+	second, err := messages.Record("substrate", &chain.Message{Payload: []byte{2}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Sequence)
+
+	// records keep their number, which is never reused after a restart
+	again, err := messages.Record("ethereum", &chain.Message{Payload: []byte{1}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), again.Sequence)
+
+	third, err := store.NewMessages(db).Record("ethereum", &chain.Message{Payload: []byte{3}}, store.StatusRouted)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), third.Sequence)
+
+	sequence, err = messages.Sequence()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), sequence)
+
+	records, err := messages.List("", "")
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, uint64(i+1), record.Sequence)
+	}
+}
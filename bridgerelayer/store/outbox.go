@@ -0,0 +1,221 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+)
+
+var outboxPrefix = []byte("outbox/")
+
+type OutboxConfig struct {
+	// Restarts after which a message whose delivery was never confirmed is given up and
+	// marked as skipped. Defaults to 5.
+	MaxRedeliveries int `mapstructure:"max-redeliveries"`
+}
+
+// OutboxEntry is a message awaiting confirmation of its delivery
+type OutboxEntry struct {
+	// Queue of the message, such as its direction
+	Queue   string
+	Message *chain.Message
+	// Number of times the message was delivered again after a restart
+	Redeliveries int
+	AddedAt      time.Time
+}
+
+type outboxRecord struct {
+	Queue        string          `json:"queue"`
+	Message      json.RawMessage `json:"message"`
+	Redeliveries int             `json:"redeliveries"`
+	AddedAt      time.Time       `json:"addedAt"`
+}
+
+// Outbox persists the messages handed to the writers, by the ID which the message store
+// assigned to them, until their delivery is acknowledged
+type Outbox struct {
+	db DB
+	// serializes read-modify-write updates of entries
+	mutex sync.Mutex
+}
+
+func NewOutbox(db DB) *Outbox {
+	return &Outbox{db: db}
+}
+
+// Add stores a message in a queue until it is acknowledged
+func (ob *Outbox) Add(queue string, msg *chain.Message) error {
+	if msg.ID == "" {
+		return fmt.Errorf("message was not recorded")
+	}
+
+	encoded, err := chain.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(outboxRecord{
+		Queue:   queue,
+		Message: encoded,
+		AddedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	return ob.db.Put(outboxKey(msg.ID), value)
+}
+
+// Ack removes a message, whether or not it is still stored
+func (ob *Outbox) Ack(id string) error {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	return ob.db.Delete(outboxKey(id))
+}
+
+// Redelivered counts a redelivery of a message, returning the number of redeliveries
+func (ob *Outbox) Redelivered(id string) (int, error) {
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	value, err := ob.db.Get(outboxKey(id))
+	if err != nil {
+		return 0, err
+	}
+
+	var record outboxRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return 0, err
+	}
+
+	record.Redeliveries++
+	value, err = json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+
+	return record.Redeliveries, ob.db.Put(outboxKey(id), value)
+}
+
+// Get returns the entry of a message
+func (ob *Outbox) Get(id string) (*OutboxEntry, error) {
+	value, err := ob.db.Get(outboxKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var record outboxRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, err
+	}
+	return record.entry(id)
+}
+
+// Replace moves the entry of a message to another message, such as the message with a
+// migrated payload, keeping its queue, redeliveries and the time it was added
+func (ob *Outbox) Replace(id string, msg *chain.Message) error {
+	if msg.ID == "" {
+		return fmt.Errorf("message was not recorded")
+	}
+
+	encoded, err := chain.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	ob.mutex.Lock()
+	defer ob.mutex.Unlock()
+
+	value, err := ob.db.Get(outboxKey(id))
+	if err != nil {
+		return err
+	}
+
+	var record outboxRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return err
+	}
+
+	record.Message = encoded
+	value, err = json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = ob.db.Put(outboxKey(msg.ID), value)
+	if err != nil {
+		return err
+	}
+	if msg.ID == id {
+		return nil
+	}
+	return ob.db.Delete(outboxKey(id))
+}
+
+// Pending returns the messages of a queue in the order in which they were recorded
+func (ob *Outbox) Pending(queue string) ([]OutboxEntry, error) {
+	entries := []OutboxEntry{}
+	var decodeErr error
+
+	err := ob.db.Iterate(outboxPrefix, func(key []byte, value []byte) bool {
+		var record outboxRecord
+		decodeErr = json.Unmarshal(value, &record)
+		if decodeErr != nil {
+			return false
+		}
+		if record.Queue != queue {
+			return true
+		}
+
+		var entry *OutboxEntry
+		entry, decodeErr = record.entry(string(key[len(outboxPrefix):]))
+		if decodeErr != nil {
+			return false
+		}
+		entries = append(entries, *entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Message.Sequence < entries[j].Message.Sequence
+	})
+	return entries, nil
+}
+
+func (record *outboxRecord) entry(id string) (*OutboxEntry, error) {
+	msg, err := chain.DecodeMessage(record.Message)
+	if err != nil {
+		return nil, fmt.Errorf("outbox entry %s: %w", id, err)
+	}
+
+	return &OutboxEntry{
+		Queue:        record.Queue,
+		Message:      msg,
+		Redeliveries: record.Redeliveries,
+		AddedAt:      record.AddedAt,
+	}, nil
+}
+
+func outboxKey(id string) []byte {
+	return append(append([]byte{}, outboxPrefix...), id...)
+}
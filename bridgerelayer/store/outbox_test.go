@@ -0,0 +1,74 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/chain"
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestOutbox(t *testing.T) {
+	outbox := store.NewOutbox(store.NewMemoryDB())
+
+	observedAt := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	transfer := chain.Message{ID: "b", Sequence: 2, AppID: [20]byte{1}, Payload: []byte{1, 2, 3}, ObservedAt: observedAt}
+	call := chain.Message{ID: "a", Sequence: 3, AppID: [20]byte{2}, Payload: chain.Call{Name: "Assets.mint", Args: []byte{4}}}
+	unlock := chain.Message{ID: "c", Sequence: 1, AppID: [20]byte{1}, Payload: []byte{5}}
+
+	require.NoError(t, outbox.Add("substrate-to-ethereum", &transfer))
+	require.NoError(t, outbox.Add("ethereum-to-substrate", &call))
+	require.NoError(t, outbox.Add("substrate-to-ethereum", &unlock))
+
+	// messages which were not recorded can't be acknowledged
+	assert.Error(t, outbox.Add("substrate-to-ethereum", &chain.Message{Payload: []byte{6}}))
+
+	// messages are pending in the order in which they were recorded
+	pending, err := outbox.Pending("substrate-to-ethereum")
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, unlock, *pending[0].Message)
+	assert.Equal(t, transfer, *pending[1].Message)
+
+	pending, err = outbox.Pending("ethereum-to-substrate")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, call, *pending[0].Message)
+
+	redeliveries, err := outbox.Redelivered("b")
+	require.NoError(t, err)
+	assert.Equal(t, 1, redeliveries)
+
+	// acknowledged messages are removed, and acknowledging them again is harmless
+	require.NoError(t, outbox.Ack("c"))
+	require.NoError(t, outbox.Ack("c"))
+
+	pending, err = outbox.Pending("substrate-to-ethereum")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "b", pending[0].Message.ID)
+	assert.Equal(t, 1, pending[0].Redeliveries)
+
+	_, err = outbox.Redelivered("c")
+	assert.Equal(t, store.ErrNotFound, err)
+
+	// replaced entries move to the new message, keeping their queue and redeliveries
+	migrated := transfer
+	migrated.ID = "d"
+	migrated.Payload = []byte{1, 2, 3, 4}
+	require.NoError(t, outbox.Replace("b", &migrated))
+	_, err = outbox.Get("b")
+	assert.Equal(t, store.ErrNotFound, err)
+	entry, err := outbox.Get("d")
+	require.NoError(t, err)
+	assert.Equal(t, "substrate-to-ethereum", entry.Queue)
+	assert.Equal(t, migrated, *entry.Message)
+	assert.Equal(t, 1, entry.Redeliveries)
+	assert.Equal(t, store.ErrNotFound, outbox.Replace("c", &migrated))
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var paymentPrefix = []byte("payments/")
+
+// ErrPaymentClaimed is returned when a payment was already claimed for a message
+var ErrPaymentClaimed = errors.New("payment was already claimed")
+
+// Payments records the payments which users made for self-relayed messages, so that
+// each payment is only claimed once
+type Payments struct {
+	db DB
+	// serializes claims of the same payment
+	mutex sync.Mutex
+}
+
+func NewPayments(db DB) *Payments {
+	return &Payments{db: db}
+}
+
+// Claim records a payment, given by its transaction hash, for a message, returning
+// ErrPaymentClaimed if it was already claimed
+func (ps *Payments) Claim(hash string, messageID string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	_, err := ps.db.Get(paymentKey(hash))
+	if err == nil {
+		return ErrPaymentClaimed
+	} else if err != ErrNotFound {
+		return err
+	}
+
+	return ps.db.Put(paymentKey(hash), []byte(messageID))
+}
+
+// Claimant returns the ID of the message for which a payment was claimed
+func (ps *Payments) Claimant(hash string) (string, error) {
+	value, err := ps.db.Get(paymentKey(hash))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func paymentKey(hash string) []byte {
+	return append(append([]byte{}, paymentPrefix...), strings.ToLower(hash)...)
+}
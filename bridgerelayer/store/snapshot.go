@@ -0,0 +1,117 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// snapshotMagic identifies the snapshot format and its version
+var snapshotMagic = []byte("artemis-snapshot/1\n")
+
+// WriteSnapshot writes a compressed copy of all records in the database
+func WriteSnapshot(db DB, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+
+	_, err := zw.Write(snapshotMagic)
+	if err != nil {
+		return err
+	}
+
+	var writeErr error
+	err = db.Iterate(nil, func(key []byte, value []byte) bool {
+		writeErr = writeChunk(zw, key)
+		if writeErr == nil {
+			writeErr = writeChunk(zw, value)
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return zw.Close()
+}
+
+// RestoreSnapshot writes all records of a snapshot into the database
+func RestoreSnapshot(db DB, r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	reader := bufio.NewReader(zr)
+
+	magic := make([]byte, len(snapshotMagic))
+	_, err = io.ReadFull(reader, magic)
+	if err != nil || string(magic) != string(snapshotMagic) {
+		return fmt.Errorf("not a relayer snapshot")
+	}
+
+	for {
+		key, err := readChunk(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		value, err := readChunk(reader)
+		if err != nil {
+			return err
+		}
+
+		err = db.Put(key, value)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// IsEmpty returns whether the database holds no records
+func IsEmpty(db DB) (bool, error) {
+	empty := true
+	err := db.Iterate(nil, func(_ []byte, _ []byte) bool {
+		empty = false
+		return false
+	})
+	return empty, err
+}
+
+func writeChunk(w io.Writer, data []byte) error {
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(data)))
+
+	_, err := w.Write(size[:n])
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func readChunk(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err == io.EOF {
+		return nil, errors.New("truncated snapshot")
+	}
+	return data, err
+}
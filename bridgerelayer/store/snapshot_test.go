@@ -0,0 +1,47 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestSnapshot_Restore(t *testing.T) {
+	source := store.NewMemoryDB()
+	require.NoError(t, source.Put([]byte("message/1"), []byte("one")))
+	require.NoError(t, source.Put([]byte("blocks/Ethereum"), []byte{}))
+
+	var buffer bytes.Buffer
+	require.NoError(t, store.WriteSnapshot(source, &buffer))
+
+	target := store.NewMemoryDB()
+	empty, err := store.IsEmpty(target)
+	require.NoError(t, err)
+	assert.True(t, empty)
+
+	require.NoError(t, store.RestoreSnapshot(target, bytes.NewReader(buffer.Bytes())))
+
+	value, err := target.Get([]byte("message/1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), value)
+
+	value, err = target.Get([]byte("blocks/Ethereum"))
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	empty, err = store.IsEmpty(target)
+	require.NoError(t, err)
+	assert.False(t, empty)
+}
+
+func TestSnapshot_Invalid(t *testing.T) {
+	err := store.RestoreSnapshot(store.NewMemoryDB(), bytes.NewReader([]byte("garbage")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+var statsPrefix = []byte("stats/")
+
+// DayLayout is the layout of the UTC days by which statistics are kept
+const DayLayout = "2006-01-02"
+
+// ChannelStats are the statistics of a channel, the direction in which messages are
+// relayed, over one UTC day
+type ChannelStats struct {
+	Channel string `json:"channel"`
+	Day     string `json:"day"`
+	// Messages whose delivery to the target chain was confirmed
+	Messages uint64 `json:"messages"`
+	// Amounts transferred by token, in base units
+	Volume map[string]string `json:"volume"`
+	// Fees paid for deliveries, in base units of the native asset of the target chain
+	Fees string `json:"fees"`
+	// Average seconds from observing a message to the confirmation of its delivery
+	AverageLatency float64 `json:"averageLatency"`
+	// Messages whose latency is known, over which the average is taken
+	Timed uint64 `json:"timed"`
+}
+
+// Deliver counts a delivered message with its fee, which may be nil, and its latency,
+// which is unknown if zero
+func (cs *ChannelStats) Deliver(fee *big.Int, latency time.Duration) {
+	cs.Messages++
+	if fee != nil {
+		cs.Fees = addAmounts(cs.Fees, fee)
+	}
+	if latency > 0 {
+		cs.addLatency(latency.Seconds(), 1)
+	}
+}
+
+// Transfer adds an amount of a token to the volume
+func (cs *ChannelStats) Transfer(token string, amount *big.Int) {
+	if cs.Volume == nil {
+		cs.Volume = make(map[string]string)
+	}
+	cs.Volume[token] = addAmounts(cs.Volume[token], amount)
+}
+
+// Add merges the statistics of another day into these, for example to sum a period
+func (cs *ChannelStats) Add(other *ChannelStats) {
+	cs.Messages += other.Messages
+	if other.Fees != "" {
+		fees, _ := new(big.Int).SetString(other.Fees, 10)
+		cs.Fees = addAmounts(cs.Fees, fees)
+	}
+	for token, volume := range other.Volume {
+		amount, _ := new(big.Int).SetString(volume, 10)
+		cs.Transfer(token, amount)
+	}
+	if other.Timed > 0 {
+		cs.addLatency(other.AverageLatency*float64(other.Timed), other.Timed)
+	}
+}
+
+// addLatency adds the total latency of a number of messages to the average
+func (cs *ChannelStats) addLatency(total float64, count uint64) {
+	sum := cs.AverageLatency*float64(cs.Timed) + total
+	cs.Timed += count
+	cs.AverageLatency = sum / float64(cs.Timed)
+}
+
+// addAmounts adds an amount to a decimal sum, which is zero if empty. Amounts which
+// can't be parsed are ignored.
+func addAmounts(sum string, amount *big.Int) string {
+	total, ok := new(big.Int).SetString(sum, 10)
+	if !ok {
+		total = new(big.Int)
+	}
+	if amount != nil {
+		total.Add(total, amount)
+	}
+	return total.String()
+}
+
+// Stats stores the daily statistics of each channel
+type Stats struct {
+	db DB
+	// days for which statistics are kept, forever if zero
+	retention int
+	// serializes read-modify-write updates of days
+	mutex sync.Mutex
+}
+
+func NewStats(db DB, retention int) *Stats {
+	return &Stats{db: db, retention: retention}
+}
+
+// Update applies fn to the statistics of a channel for the day of a time. Days which
+// have left the retention are deleted when a channel starts a new day.
+func (st *Stats) Update(channel string, at time.Time, fn func(*ChannelStats)) error {
+	day := at.UTC().Format(DayLayout)
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	stats, err := st.get(channel, day)
+	if err == ErrNotFound {
+		stats = &ChannelStats{Channel: channel, Day: day}
+		err = st.expire(channel, at)
+	}
+	if err != nil {
+		return err
+	}
+
+	fn(stats)
+
+	value, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return st.db.Put(statsKey(channel, day), value)
+}
+
+func (st *Stats) get(channel string, day string) (*ChannelStats, error) {
+	value, err := st.db.Get(statsKey(channel, day))
+	if err != nil {
+		return nil, err
+	}
+
+	var stats ChannelStats
+	err = json.Unmarshal(value, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// expire deletes the days of a channel which have left the retention
+func (st *Stats) expire(channel string, now time.Time) error {
+	if st.retention == 0 {
+		return nil
+	}
+	oldest := now.UTC().AddDate(0, 0, -st.retention).Format(DayLayout)
+
+	var expired [][]byte
+	err := st.db.Iterate(channelPrefix(channel), func(key []byte, _ []byte) bool {
+		if string(key[len(key)-len(DayLayout):]) >= oldest {
+			return false
+		}
+		expired = append(expired, append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		err = st.db.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset deletes the statistics of every channel
+func (st *Stats) Reset() error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	var keys [][]byte
+	err := st.db.Iterate(statsPrefix, func(key []byte, _ []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = st.db.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the statistics of a channel, or of all channels if empty, for the days
+// since a time, in order of channel and day
+func (st *Stats) List(channel string, since time.Time) ([]*ChannelStats, error) {
+	first := since.UTC().Format(DayLayout)
+	prefix := statsPrefix
+	if channel != "" {
+		prefix = channelPrefix(channel)
+	}
+
+	days := []*ChannelStats{}
+
+	var decodeErr error
+	err := st.db.Iterate(prefix, func(_ []byte, value []byte) bool {
+		var stats ChannelStats
+		decodeErr = json.Unmarshal(value, &stats)
+		if decodeErr != nil {
+			return false
+		}
+
+		if stats.Day >= first {
+			days = append(days, &stats)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return days, decodeErr
+}
+
+func channelPrefix(channel string) []byte {
+	return []byte(string(statsPrefix) + strings.ToLower(channel) + "/")
+}
+
+func statsKey(channel string, day string) []byte {
+	return append(channelPrefix(channel), day...)
+}
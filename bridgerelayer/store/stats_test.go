@@ -0,0 +1,81 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func TestStats(t *testing.T) {
+	stats := store.NewStats(store.NewMemoryDB(), 30)
+	day := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	deliver := func(at time.Time, fee int64, latency time.Duration) {
+		require.NoError(t, stats.Update("ethereum-to-substrate", at, func(cs *store.ChannelStats) {
+			cs.Deliver(big.NewInt(fee), latency)
+		}))
+	}
+
+	deliver(day, 100, 10*time.Second)
+	deliver(day.Add(time.Hour), 50, 20*time.Second)
+	// the latency of messages built without being observed is unknown
+	deliver(day.Add(2*time.Hour), 25, 0)
+	deliver(day.AddDate(0, 0, 1), 10, 60*time.Second)
+	require.NoError(t, stats.Update("ethereum-to-substrate", day, func(cs *store.ChannelStats) {
+		cs.Transfer("eth", big.NewInt(1000))
+	}))
+	require.NoError(t, stats.Update("substrate-to-ethereum", day, func(cs *store.ChannelStats) {
+		cs.Transfer("eth", big.NewInt(5))
+	}))
+
+	days, err := stats.List("ethereum-to-substrate", day)
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, "2020-09-01", days[0].Day)
+	assert.Equal(t, uint64(3), days[0].Messages)
+	assert.Equal(t, "175", days[0].Fees)
+	assert.Equal(t, 15.0, days[0].AverageLatency)
+	assert.Equal(t, map[string]string{"eth": "1000"}, days[0].Volume)
+	assert.Equal(t, "2020-09-02", days[1].Day)
+
+	total := store.ChannelStats{}
+	for _, stats := range days {
+		total.Add(stats)
+	}
+	assert.Equal(t, uint64(4), total.Messages)
+	assert.Equal(t, "185", total.Fees)
+	assert.Equal(t, 30.0, total.AverageLatency)
+
+	days, err = stats.List("ethereum-to-substrate", day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Len(t, days, 1)
+
+	days, err = stats.List("", day)
+	require.NoError(t, err)
+	assert.Len(t, days, 3)
+}
+
+func TestStats_Retention(t *testing.T) {
+	stats := store.NewStats(store.NewMemoryDB(), 7)
+	day := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{day, day.AddDate(0, 0, 3), day.AddDate(0, 0, 8)} {
+		require.NoError(t, stats.Update("ethereum-to-substrate", at, func(cs *store.ChannelStats) {
+			cs.Deliver(nil, 0)
+		}))
+	}
+
+	// the first day left the retention when the last one started
+	days, err := stats.List("ethereum-to-substrate", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, "2020-09-04", days[0].Day)
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package store provides persistent storage for relayer state.
+package store
+
+import (
+	"errors"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// ErrNotFound is returned when a key does not exist
+var ErrNotFound = errors.New("not found")
+
+// DB is a minimal key-value store. Records of different kinds are kept
+// apart by prefixing their keys.
+type DB interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// Iterate calls fn for each key with the given prefix in key order, until fn returns false
+	Iterate(prefix []byte, fn func(key []byte, value []byte) bool) error
+	Close() error
+}
+
+type Config struct {
+	// Directory of the LevelDB database. State is kept in memory if empty.
+	Path string `mapstructure:"path"`
+	// JSON file recording the last block handled by each listener. The blocks are
+	// recorded in the database if empty.
+	CursorFile string `mapstructure:"cursor-file"`
+	// Persistence of the messages handed to the writers until their delivery is confirmed,
+	// enabled with a database
+	Outbox OutboxConfig `mapstructure:"outbox"`
+}
+
+// Open opens the database described by the config
+func Open(config *Config) (DB, error) {
+	if config.Path == "" {
+		return NewMemoryDB(), nil
+	}
+
+	path, err := homedir.Expand(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLevelDB(path)
+}
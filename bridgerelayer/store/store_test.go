@@ -0,0 +1,59 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/store"
+)
+
+func testDB(t *testing.T, db store.DB) {
+	_, err := db.Get([]byte("a/1"))
+	assert.Equal(t, store.ErrNotFound, err)
+
+	require.NoError(t, db.Put([]byte("a/2"), []byte("two")))
+	require.NoError(t, db.Put([]byte("a/1"), []byte("one")))
+	require.NoError(t, db.Put([]byte("b/1"), []byte("other")))
+
+	value, err := db.Get([]byte("a/1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), value)
+
+	var keys []string
+	err = db.Iterate([]byte("a/"), func(key []byte, _ []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/1", "a/2"}, keys)
+
+	require.NoError(t, db.Delete([]byte("a/1")))
+	_, err = db.Get([]byte("a/1"))
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestMemoryDB(t *testing.T) {
+	db := store.NewMemoryDB()
+	defer db.Close()
+
+	testDB(t, db)
+}
+
+func TestLevelDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artemis-relay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := store.NewLevelDB(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	testDB(t, db)
+}
@@ -0,0 +1,303 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+type Config struct {
+	// Base URL of the OTLP/HTTP receiver of an OpenTelemetry collector, for example
+	// http://localhost:4318. Tracing is disabled if empty.
+	Endpoint string `mapstructure:"endpoint"`
+	// Share of traces which are sampled, between 0 and 1. Defaults to 1.
+	SampleRatio float64 `mapstructure:"sample-ratio"`
+	// Seconds between exports of the ended spans. Defaults to 5.
+	Interval uint64 `mapstructure:"interval"`
+	// Headers sent with each export, for example to authenticate with the collector
+	Headers map[string]string `mapstructure:"headers"`
+	// Name under which the spans are exported. Defaults to artemis-relay.
+	ServiceName string `mapstructure:"service-name"`
+}
+
+const (
+	defaultInterval    = 5
+	defaultServiceName = "artemis-relay"
+	exportTimeout      = 10 * time.Second
+	// maxQueuedSpans bounds the memory used by ended spans awaiting their export, spans
+	// ended while the queue is full are dropped
+	maxQueuedSpans = 4096
+	scopeName      = "github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+// Tracer samples traces and exports their spans in batches
+type Tracer struct {
+	url      string
+	headers  map[string]string
+	service  string
+	ratio    float64
+	interval time.Duration
+	client   *http.Client
+	random   func() float64
+	// ended spans awaiting their export, and the number dropped since the last export
+	mutex   sync.Mutex
+	queued  []*Span
+	dropped int
+	log     *logrus.Entry
+}
+
+// NewTracer returns nil if tracing is disabled
+func NewTracer(config *Config, log *logrus.Entry) (*Tracer, error) {
+	if config.Endpoint == "" {
+		return nil, nil
+	}
+
+	ratio := config.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is not between 0 and 1", config.SampleRatio)
+	}
+
+	interval := config.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+
+	service := config.ServiceName
+	if service == "" {
+		service = defaultServiceName
+	}
+
+	return &Tracer{
+		url:      strings.TrimSuffix(config.Endpoint, "/") + "/v1/traces",
+		headers:  config.Headers,
+		service:  service,
+		ratio:    ratio,
+		interval: time.Duration(interval) * time.Second,
+		client:   &http.Client{Timeout: exportTimeout},
+		random:   rand.Float64,
+		log:      log,
+	}, nil
+}
+
+// Start exports the ended spans periodically, and once more when the context is done
+func (tr *Tracer) Start(ctx context.Context, eg *errgroup.Group) {
+	eg.Go(func() error {
+		ticker := time.NewTicker(tr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				final, cancel := context.WithTimeout(context.Background(), exportTimeout)
+				tr.flush(final)
+				cancel()
+				return nil
+			case <-ticker.C:
+				tr.flush(ctx)
+			}
+		}
+	})
+}
+
+// start creates a span, sampling the trace if the span is its root
+func (tr *Tracer) start(name string, parent SpanContext, start time.Time) *Span {
+	span := &Span{tracer: tr, name: name, start: start}
+	if parent.Valid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		newID(span.context.TraceID[:])
+		span.context.Sampled = tr.random() < tr.ratio
+	}
+	newID(span.context.SpanID[:])
+	return span
+}
+
+func (tr *Tracer) queue(span *Span) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if len(tr.queued) >= maxQueuedSpans {
+		tr.dropped++
+		return
+	}
+	tr.queued = append(tr.queued, span)
+}
+
+// flush exports the ended spans. The spans of a failed export are dropped, so that an
+// unreachable collector doesn't hold back the relay.
+func (tr *Tracer) flush(ctx context.Context) {
+	tr.mutex.Lock()
+	spans := tr.queued
+	dropped := tr.dropped
+	tr.queued = nil
+	tr.dropped = 0
+	tr.mutex.Unlock()
+
+	if dropped > 0 {
+		tr.log.WithField("spans", dropped).Warn("Dropped spans while the export queue was full")
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	err := tr.export(ctx, spans)
+	if err != nil {
+		tr.log.WithError(err).WithField("spans", len(spans)).Warn("Failed to export spans")
+		return
+	}
+	tr.log.WithField("spans", len(spans)).Debug("Exported spans")
+}
+
+func (tr *Tracer) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(tr.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, tr.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range tr.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := tr.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// OTLP/JSON encoding of trace export requests, in which IDs are hex and 64-bit integers
+// are decimal strings
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanJSON `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanJSON struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type keyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	spanKindInternal = 1
+	statusOK         = 1
+	statusError      = 2
+)
+
+func (tr *Tracer) encode(spans []*Span) *exportRequest {
+	encoded := make([]spanJSON, 0, len(spans))
+	for _, span := range spans {
+		sj := spanJSON{
+			TraceID:           hex.EncodeToString(span.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.context.SpanID[:]),
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Status:            status{Code: statusOK},
+		}
+		if span.parent != [8]byte{} {
+			sj.ParentSpanID = hex.EncodeToString(span.parent[:])
+		}
+		for _, attr := range span.attributes {
+			sj.Attributes = append(sj.Attributes, keyValue{Key: attr.key, Value: encodeValue(attr.value)})
+		}
+		if span.err != nil {
+			sj.Status = status{Code: statusError, Message: span.err.Error()}
+		}
+		encoded = append(encoded, sj)
+	}
+
+	return &exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: []keyValue{
+			{Key: "service.name", Value: encodeValue(tr.service)},
+		}},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+// encodeValue encodes an attribute value as an OTLP AnyValue
+func encodeValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case uint64:
+		return map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
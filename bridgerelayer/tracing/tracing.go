@@ -0,0 +1,204 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package tracing records spans of the relay pipeline, from fetching a block to confirming
+// the delivery of its messages, so that it shows where the latency of a message accumulates.
+// Sampled spans are exported to an OpenTelemetry collector over OTLP/HTTP. Messages carry
+// the context of their last span across the channels of the pipeline and the outbox, so
+// that the spans of their delivery join the trace of their observation.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span and the trace it belongs to, with the sampling decision
+// taken for the trace. The zero SpanContext is invalid, for messages which aren't traced.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// Valid returns whether the context identifies a span
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// String formats the context as a W3C traceparent, empty if it is invalid
+func (sc SpanContext) String() string {
+	if !sc.Valid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%x-%x-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseSpanContext parses a W3C traceparent
+func ParseSpanContext(value string) (SpanContext, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", value)
+	}
+
+	var sc SpanContext
+	_, err := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace ID: %w", err)
+	}
+	_, err = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("invalid span ID: %w", err)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace flags: %w", err)
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.Valid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", value)
+	}
+	return sc, nil
+}
+
+// Span is an operation of the pipeline. Spans of traces which weren't sampled carry their
+// context to their children without being exported. A nil Span, returned while tracing is
+// disabled, records nothing.
+type Span struct {
+	tracer     *Tracer
+	name       string
+	context    SpanContext
+	parent     [8]byte
+	start      time.Time
+	end        time.Time
+	attributes []attribute
+	err        error
+	// guards the end of the span
+	once sync.Once
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type contextKey struct{}
+
+var (
+	mutex  sync.RWMutex
+	global *Tracer
+)
+
+// Install makes a tracer record the spans started by the relay, nil disables tracing. It
+// returns the tracer installed before, so that it can be restored.
+func Install(tracer *Tracer) *Tracer {
+	mutex.Lock()
+	defer mutex.Unlock()
+	previous := global
+	global = tracer
+	return previous
+}
+
+func installed() *Tracer {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return global
+}
+
+// ContextWith returns a context carrying a span context, from which the spans started
+// with it descend, such as the context carried by a message
+func ContextWith(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.Valid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// FromContext returns the span context carried by a context, invalid if it carries none
+func FromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a span as a child of the span carried by the context, or as the root of a
+// new trace, returning the context carrying it
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	tracer := installed()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := tracer.start(name, FromContext(ctx), time.Now())
+	return ContextWith(ctx, span.context), span
+}
+
+// Record records a span which already ended, such as the wait for a confirmation, as a
+// child of a span context
+func Record(name string, parent SpanContext, start time.Time, end time.Time, attributes map[string]interface{}) {
+	tracer := installed()
+	if tracer == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	span := tracer.start(name, parent, start)
+	for _, key := range keys {
+		span.SetAttribute(key, attributes[key])
+	}
+	span.finish(end, nil)
+}
+
+// Context returns the context of the span, invalid for a nil span
+func (sp *Span) Context() SpanContext {
+	if sp == nil {
+		return SpanContext{}
+	}
+	return sp.context
+}
+
+// SetAttribute records an attribute of the span, a string, integer, float or boolean
+func (sp *Span) SetAttribute(key string, value interface{}) {
+	if sp == nil || !sp.context.Sampled {
+		return
+	}
+	sp.attributes = append(sp.attributes, attribute{key: key, value: value})
+}
+
+// End ends the span, recording the error of the operation if it failed
+func (sp *Span) End(err error) {
+	if sp == nil {
+		return
+	}
+	sp.finish(time.Now(), err)
+}
+
+func (sp *Span) finish(end time.Time, err error) {
+	sp.once.Do(func() {
+		sp.end = end
+		sp.err = err
+		if sp.context.Sampled {
+			sp.tracer.queue(sp)
+		}
+	})
+}
+
+// newID fills an ID with random bytes
+func newID(id []byte) {
+	_, _ = rand.Read(id)
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/tracing"
+)
+
+func TestSpanContext_String(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := tracing.ParseSpanContext(value)
+	require.NoError(t, err)
+	assert.True(t, sc.Valid())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, value, sc.String())
+
+	_, err = tracing.ParseSpanContext("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	assert.Error(t, err)
+	_, err = tracing.ParseSpanContext("not a traceparent")
+	assert.Error(t, err)
+
+	assert.Equal(t, "", tracing.SpanContext{}.String())
+}
+
+func TestStart_Disabled(t *testing.T) {
+	tracer, err := tracing.NewTracer(&tracing.Config{}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	assert.Nil(t, tracer)
+
+	tracing.Install(nil)
+	ctx, span := tracing.Start(context.Background(), "fetch block")
+	assert.Nil(t, span)
+	span.SetAttribute("block", 1)
+	span.End(nil)
+	assert.False(t, span.Context().Valid())
+	assert.False(t, tracing.FromContext(ctx).Valid())
+}
+
+func TestNewTracer_SampleRatio(t *testing.T) {
+	_, err := tracing.NewTracer(&tracing.Config{Endpoint: "http://localhost:4318", SampleRatio: 1.5}, logrus.NewEntry(logrus.New()))
+	assert.Error(t, err)
+}
+
+func TestTracer_Export(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var request map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &request))
+		mutex.Lock()
+		requests = append(requests, request)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	tracer, err := tracing.NewTracer(&tracing.Config{
+		Endpoint: server.URL,
+		Interval: 3600,
+		Headers:  map[string]string{"Authorization": "secret"},
+	}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	tracing.Install(tracer)
+	defer tracing.Install(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eg, ctx := errgroup.WithContext(ctx)
+	tracer.Start(ctx, eg)
+
+	traced, fetch := tracing.Start(context.Background(), "fetch block")
+	fetch.SetAttribute("block", uint64(7))
+	_, enqueue := tracing.Start(traced, "enqueue message")
+	enqueue.End(nil)
+	fetch.End(errors.New("timeout"))
+
+	// the context carried by a message joins the spans of its delivery to its trace
+	carried := tracing.ContextWith(context.Background(), enqueue.Context())
+	_, submit := tracing.Start(carried, "submit message")
+	submit.End(nil)
+	assert.Equal(t, fetch.Context().TraceID, submit.Context().TraceID)
+
+	cancel()
+	require.NoError(t, eg.Wait())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, requests, 1)
+
+	resourceSpans := requests[0]["resourceSpans"].([]interface{})
+	scopeSpans := resourceSpans[0].(map[string]interface{})["scopeSpans"].([]interface{})
+	spans := scopeSpans[0].(map[string]interface{})["spans"].([]interface{})
+	require.Len(t, spans, 3)
+
+	byName := make(map[string]map[string]interface{})
+	for _, span := range spans {
+		s := span.(map[string]interface{})
+		byName[s["name"].(string)] = s
+	}
+
+	root := byName["fetch block"]
+	assert.Nil(t, root["parentSpanId"])
+	assert.Equal(t, float64(2), root["status"].(map[string]interface{})["code"])
+	assert.Equal(t, root["spanId"], byName["enqueue message"]["parentSpanId"])
+	assert.Equal(t, byName["enqueue message"]["spanId"], byName["submit message"]["parentSpanId"])
+	assert.Equal(t, root["traceId"], byName["submit message"]["traceId"])
+}
+
+func TestStart_Unsampled(t *testing.T) {
+	tracer, err := tracing.NewTracer(&tracing.Config{Endpoint: "http://localhost:4318"}, logrus.NewEntry(logrus.New()))
+	require.NoError(t, err)
+	tracing.Install(tracer)
+	defer tracing.Install(nil)
+
+	// children inherit the decision of the trace they join
+	parent, err := tracing.ParseSpanContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	_, span := tracing.Start(tracing.ContextWith(context.Background(), parent), "submit message")
+	defer span.End(nil)
+
+	assert.False(t, span.Context().Sampled)
+	assert.Equal(t, parent.TraceID, span.Context().TraceID)
+}
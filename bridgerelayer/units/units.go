@@ -0,0 +1,133 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package units implements the amount arithmetic of the relayer, such as rescaling
+// between the decimals of an asset on each chain and deducting fees. Every operation
+// rounds according to an explicit policy and returns the part which was rounded off,
+// so that totals reconcile exactly with on-chain accounting.
+package units
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rounding is the policy applied when an amount cannot be represented exactly
+type Rounding string
+
+const (
+	// RoundDown truncates towards zero, so a converted amount never exceeds its source.
+	// This is the default, as it never overstates what is backed on the other chain.
+	RoundDown Rounding = "down"
+	// RoundUp rounds away from zero
+	RoundUp Rounding = "up"
+	// RoundHalfUp rounds to the nearest unit, with halves rounded away from zero
+	RoundHalfUp Rounding = "half-up"
+	// RoundHalfEven rounds to the nearest unit, with halves rounded to the even neighbour
+	RoundHalfEven Rounding = "half-even"
+)
+
+const (
+	// MaxDecimals bounds the decimals of an asset, as 10^77 is the largest power of ten
+	// below 2^256, so an ERC20 token with more decimals couldn't represent a single whole
+	// unit in its uint256 balances
+	MaxDecimals = 77
+	// FeeDenominator is the denominator of fee rates, which are expressed in basis points
+	FeeDenominator = 10000
+)
+
+// ParseRounding parses a rounding policy, defaulting to RoundDown if empty
+func ParseRounding(value string) (Rounding, error) {
+	switch Rounding(value) {
+	case "":
+		return RoundDown, nil
+	case RoundDown, RoundUp, RoundHalfUp, RoundHalfEven:
+		return Rounding(value), nil
+	default:
+		return "", fmt.Errorf("unknown rounding policy %q", value)
+	}
+}
+
+// Divide returns numerator/denominator rounded by policy, and the remainder
+// numerator - quotient*denominator, which is negative when rounded up. Both
+// operands must be non-negative and the denominator positive.
+func Divide(numerator, denominator *big.Int, rounding Rounding) (*big.Int, *big.Int) {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient, remainder
+	}
+
+	up := false
+	switch rounding {
+	case RoundUp:
+		up = true
+	case RoundHalfUp, RoundHalfEven:
+		// compare twice the remainder to the denominator to find the nearest unit
+		cmp := new(big.Int).Lsh(remainder, 1).Cmp(denominator)
+		up = cmp > 0 || (cmp == 0 && (rounding == RoundHalfUp || quotient.Bit(0) == 1))
+	}
+
+	if up {
+		quotient.Add(quotient, big.NewInt(1))
+		remainder.Sub(remainder, denominator)
+	}
+	return quotient, remainder
+}
+
+// Rescale converts a non-negative amount between assets with different decimals. The
+// remainder is the part of the amount, in source units, which was rounded off. It is zero
+// when scaling up, and negative when rounding up.
+func Rescale(amount *big.Int, from uint8, to uint8, rounding Rounding) (*big.Int, *big.Int, error) {
+	if amount.Sign() < 0 {
+		return nil, nil, fmt.Errorf("negative amount %s", amount)
+	}
+	if from > MaxDecimals || to > MaxDecimals {
+		return nil, nil, fmt.Errorf("decimals exceed %d", MaxDecimals)
+	}
+
+	if to >= from {
+		factor := pow10(to - from)
+		return new(big.Int).Mul(amount, factor), new(big.Int), nil
+	}
+
+	result, remainder := Divide(amount, pow10(from-to), rounding)
+	return result, remainder, nil
+}
+
+// DeductFee splits a non-negative amount into the net amount and a fee of feeBps basis
+// points, with the fee rounded by policy. The net amount and fee always add up to the
+// amount, so no unit is created or lost.
+func DeductFee(amount *big.Int, feeBps uint32, rounding Rounding) (*big.Int, *big.Int, error) {
+	if amount.Sign() < 0 {
+		return nil, nil, fmt.Errorf("negative amount %s", amount)
+	}
+	if feeBps > FeeDenominator {
+		return nil, nil, fmt.Errorf("fee of %d basis points exceeds the amount", feeBps)
+	}
+
+	fee, _ := Divide(new(big.Int).Mul(amount, big.NewInt(int64(feeBps))), big.NewInt(FeeDenominator), rounding)
+	return new(big.Int).Sub(amount, fee), fee, nil
+}
+
+// Format writes an amount in base units as a decimal number of whole units, such as
+// 1.5 for 1500 base units with 3 decimals. Trailing zeros of the fraction are dropped.
+func Format(amount *big.Int, decimals uint8) string {
+	whole, fraction := new(big.Int).QuoRem(new(big.Int).Abs(amount), pow10(decimals), new(big.Int))
+
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if fraction.Sign() == 0 {
+		return sign + whole.String()
+	}
+
+	digits := fraction.String()
+	digits = strings.Repeat("0", int(decimals)-len(digits)) + digits
+	return sign + whole.String() + "." + strings.TrimRight(digits, "0")
+}
+
+func pow10(exponent uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package units_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snowfork/polkadot-ethereum/bridgerelayer/units"
+)
+
+func TestDivide(t *testing.T) {
+	cases := []struct {
+		numerator int64
+		rounding  units.Rounding
+		quotient  int64
+		remainder int64
+	}{
+		{20, units.RoundDown, 2, 0},
+		{25, units.RoundDown, 2, 5},
+		{29, units.RoundDown, 2, 9},
+		{21, units.RoundUp, 3, -9},
+		{24, units.RoundHalfUp, 2, 4},
+		{25, units.RoundHalfUp, 3, -5},
+		{35, units.RoundHalfUp, 4, -5},
+		{25, units.RoundHalfEven, 2, 5},
+		{35, units.RoundHalfEven, 4, -5},
+		{36, units.RoundHalfEven, 4, -4},
+	}
+
+	for _, c := range cases {
+		quotient, remainder := units.Divide(big.NewInt(c.numerator), big.NewInt(10), c.rounding)
+		assert.Equal(t, big.NewInt(c.quotient), quotient, "%d/10 rounded %s", c.numerator, c.rounding)
+		assert.Equal(t, big.NewInt(c.remainder), remainder, "%d/10 rounded %s", c.numerator, c.rounding)
+	}
+}
+
+func TestRescale(t *testing.T) {
+	amount, _ := new(big.Int).SetString("1234567890123456789", 10)
+
+	// 18 to 6 decimals truncates, with the dust returned in source units
+	result, remainder, err := units.Rescale(amount, 18, 6, units.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, "1234567", result.String())
+	assert.Equal(t, "890123456789", remainder.String())
+
+	// converted amount and remainder reconcile with the source amount
+	back, _, err := units.Rescale(result, 6, 18, units.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, amount, back.Add(back, remainder))
+
+	result, remainder, err = units.Rescale(amount, 18, 6, units.RoundHalfEven)
+	require.NoError(t, err)
+	assert.Equal(t, "1234568", result.String())
+	assert.Equal(t, "-109876543211", remainder.String())
+
+	// scaling up is exact
+	result, remainder, err = units.Rescale(big.NewInt(5), 6, 18, units.RoundUp)
+	require.NoError(t, err)
+	assert.Equal(t, "5000000000000", result.String())
+	assert.Zero(t, remainder.Sign())
+
+	_, _, err = units.Rescale(big.NewInt(-1), 18, 6, units.RoundDown)
+	assert.Error(t, err)
+	_, _, err = units.Rescale(big.NewInt(1), 78, 6, units.RoundDown)
+	assert.Error(t, err)
+}
+
+func TestDeductFee(t *testing.T) {
+	// 0.3% of 1001 is 3.003
+	net, fee, err := units.DeductFee(big.NewInt(1001), 30, units.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(998), net)
+	assert.Equal(t, big.NewInt(3), fee)
+
+	net, fee, err = units.DeductFee(big.NewInt(1001), 30, units.RoundUp)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(997), net)
+	assert.Equal(t, big.NewInt(4), fee)
+
+	// exact fees aren't rounded
+	net, fee, err = units.DeductFee(big.NewInt(1000), 30, units.RoundUp)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(997), net)
+	assert.Equal(t, big.NewInt(3), fee)
+
+	// net amounts and fees always add up, and a fee rounded up never overstates the net amount
+	for amount := int64(0); amount < 500; amount++ {
+		for _, rounding := range []units.Rounding{units.RoundDown, units.RoundUp, units.RoundHalfUp, units.RoundHalfEven} {
+			net, fee, err := units.DeductFee(big.NewInt(amount), 125, rounding)
+			require.NoError(t, err)
+			assert.Equal(t, amount, net.Int64()+fee.Int64())
+			assert.True(t, fee.Sign() >= 0 && net.Sign() >= 0)
+			if rounding == units.RoundUp {
+				assert.True(t, net.Int64()*units.FeeDenominator <= amount*(units.FeeDenominator-125))
+			}
+		}
+	}
+
+	_, _, err = units.DeductFee(big.NewInt(1), 10001, units.RoundDown)
+	assert.Error(t, err)
+}
+
+func TestParseRounding(t *testing.T) {
+	rounding, err := units.ParseRounding("")
+	require.NoError(t, err)
+	assert.Equal(t, units.RoundDown, rounding)
+
+	rounding, err = units.ParseRounding("half-even")
+	require.NoError(t, err)
+	assert.Equal(t, units.RoundHalfEven, rounding)
+
+	_, err = units.ParseRounding("nearest")
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		decimals uint8
+		result   string
+	}{
+		{1500, 3, "1.5"},
+		{1000, 3, "1"},
+		{5, 3, "0.005"},
+		{0, 12, "0"},
+		{-1250, 2, "-12.5"},
+		{42, 0, "42"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.result, units.Format(big.NewInt(c.amount), c.decimals))
+	}
+}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: MIT
+pragma solidity >=0.6.2;
+
+// MessageID derives the IDs by which the relayer refers to messages in its message store,
+// archive and attestations
+contract MessageID {
+
+    bytes32 public constant DOMAIN = keccak256("artemis-relay/message");
+    uint8 public constant VERSION = 1;
+
+    // Derive the ID of a message from the lower case name of its source chain, the
+    // address of its app and its canonical payload
+    function messageID(string memory source, address appId, bytes memory payload)
+        public
+        pure
+        returns (bytes32)
+    {
+        return keccak256(abi.encodePacked(DOMAIN, VERSION, keccak256(bytes(source)), appId, keccak256(payload)));
+    }
+}
@@ -0,0 +1,28 @@
+const MessageID = artifacts.require("MessageID");
+
+// Vectors shared with the relayer, which derives the same IDs
+const vectors = require("../../bridgerelayer/chain/testdata/message_ids.json");
+
+require("chai")
+  .should();
+
+contract("MessageID", function () {
+
+  describe("deriving message IDs", function () {
+    beforeEach(async function () {
+      this.messageID = await MessageID.new();
+    });
+
+    it("should hash the domain of relayer messages", async function () {
+      const domain = await this.messageID.DOMAIN();
+      domain.should.be.equal(web3.utils.keccak256("artemis-relay/message"));
+    });
+
+    it("should derive the IDs of the relayer", async function () {
+      for (const vector of vectors) {
+        const id = await this.messageID.messageID(vector.source, vector.appId, vector.payload);
+        id.should.be.equal(vector.id);
+      }
+    });
+  });
+});